@@ -2,7 +2,12 @@
 package gowindows
 
 import (
+	"context"
+	"errors"
+
 	"github.com/d-strobel/gowindows/connection"
+	"github.com/d-strobel/gowindows/dryrun"
+	"github.com/d-strobel/gowindows/telemetry"
 	"github.com/d-strobel/gowindows/windows/dhcp"
 	"github.com/d-strobel/gowindows/windows/dns"
 	"github.com/d-strobel/gowindows/windows/local/accounts"
@@ -22,7 +27,12 @@ type Client struct {
 
 // NewClient returns a new instance of the Client object, initialized with the provided configuration.
 // Use this client to execute functions within the Windows subpackages.
-func NewClient(conn connection.Connection) *Client {
+// The commands of the subpackages pass through the interceptors, see connection.Intercept.
+func NewClient(conn connection.Connection, interceptors ...connection.Interceptor) *Client {
+	if len(interceptors) > 0 {
+		conn = connection.Intercept(conn, interceptors...)
+	}
+
 	// Initialize a new client with the provided connection.
 	c := &Client{
 		Connection: conn,
@@ -36,6 +46,59 @@ func NewClient(conn connection.Connection) *Client {
 	return c
 }
 
+// DryRun returns a copy of the client in dry-run mode that shares the connection with c.
+// In dry-run mode, the mutating functions of the subpackages do not apply their changes.
+// They return a *dryrun.Change as error instead, that matches dryrun.ErrDryRun and contains the PowerShell command
+// and the diff against the current state, fetched with the matching read function.
+// The read functions work as usual. Plan returns the change as value.
+func (c *Client) DryRun() *Client {
+	dryRun := &Client{
+		Connection:    c.Connection,
+		LocalAccounts: accounts.NewClient(c.Connection),
+		Dns:           dns.NewClient(c.Connection),
+		Dhcp:          dhcp.NewClient(c.Connection),
+	}
+
+	dryRun.LocalAccounts.DryRun = true
+	dryRun.Dns.DryRun = true
+	dryRun.Dhcp.DryRun = true
+
+	dryRun.LocalAccounts.Telemetry = c.LocalAccounts.Telemetry
+	dryRun.Dns.Telemetry = c.Dns.Telemetry
+	dryRun.Dhcp.Telemetry = c.Dhcp.Telemetry
+
+	return dryRun
+}
+
+// Plan calls a mutating function of the subpackages in call with a dry-run copy of the client, see DryRun,
+// and returns the change it would apply.
+// Other errors of call are returned, e.g. one that matches winerror.ErrNotFound if the resource of an update does not exist.
+func (c *Client) Plan(ctx context.Context, call func(ctx context.Context, c *Client) error) (*dryrun.Change, error) {
+	err := call(ctx, c.DryRun())
+	if err == nil {
+		return nil, errors.New("gowindows.Plan: call did not plan a change")
+	}
+
+	var change *dryrun.Change
+	if errors.As(err, &change) {
+		return change, nil
+	}
+	return nil, err
+}
+
+// NewClientWithTelemetry returns a new instance of the Client object like NewClient,
+// whose subpackages log and trace their calls with the telemetry configuration, see telemetry.Config.
+func NewClientWithTelemetry(conn connection.Connection, config telemetry.Config, interceptors ...connection.Interceptor) *Client {
+	c := NewClient(conn, interceptors...)
+
+	t := telemetry.New(config)
+	c.LocalAccounts.Telemetry = t
+	c.Dns.Telemetry = t
+	c.Dhcp.Telemetry = t
+
+	return c
+}
+
 // Close closes any open connection.
 func (c *Client) Close() error {
 	return c.Connection.Close()
@@ -1,10 +1,22 @@
 package gowindows
 
 import (
+	"context"
+	"errors"
+	"net/netip"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/d-strobel/gowindows/connection"
+	"github.com/d-strobel/gowindows/connection/fake"
 	mockConnection "github.com/d-strobel/gowindows/connection/mocks"
+	"github.com/d-strobel/gowindows/dryrun"
+	"github.com/d-strobel/gowindows/windows/dhcp"
+	"github.com/d-strobel/gowindows/windows/dns"
 	"github.com/d-strobel/gowindows/windows/local/accounts"
+	"github.com/d-strobel/gowindows/winerror"
+	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/suite"
 )
 
@@ -32,3 +44,168 @@ func (suite *GowindowsUnitTestSuite) TestNewClient() {
 		suite.Equal(expectedClient.Connection, actualClient.Connection)
 	})
 }
+
+func (suite *GowindowsUnitTestSuite) TestNewClientWithInterceptors() {
+	suite.Run("should pass the commands through the interceptors", func() {
+		mockConn := mockConnection.NewMockConnection(suite.T())
+		mockConn.EXPECT().Run(mock.Anything, "WHOAMI").Return(connection.CmdResult{StdOut: "user"}, nil)
+
+		var intercepted []string
+		c := NewClient(mockConn, func(ctx context.Context, cmd connection.Command, next connection.Invoker) (connection.CmdResult, error) {
+			intercepted = append(intercepted, cmd.Cmd)
+			cmd.Cmd = strings.ToUpper(cmd.Cmd)
+			return next(ctx, cmd)
+		})
+
+		result, err := c.Connection.Run(context.Background(), "whoami")
+		suite.NoError(err)
+		suite.Equal("user", result.StdOut)
+		suite.Equal([]string{"whoami"}, intercepted)
+	})
+}
+
+func (suite *GowindowsUnitTestSuite) TestDryRun() {
+	ctx := context.Background()
+	host := fake.NewHost()
+	host.AddZone("example.local")
+
+	c := NewClient(host)
+	dryRun := c.DryRun()
+	suite.True(dryRun.Dns.DryRun)
+	suite.False(c.Dns.DryRun)
+
+	_, err := c.Dns.RecordACreate(ctx, dns.RecordACreateParams{
+		Name:       "web",
+		Zone:       "example.local",
+		Addresses:  []netip.Addr{netip.MustParseAddr("10.0.0.1")},
+		TimeToLive: time.Hour,
+	})
+	suite.Require().NoError(err)
+
+	scope, err := c.Dhcp.ScopeV4Create(ctx, dhcp.ScopeV4CreateParams{
+		Name:       "Test-Scope",
+		StartRange: netip.MustParseAddr("192.168.10.10"),
+		EndRange:   netip.MustParseAddr("192.168.10.100"),
+		SubnetMask: netip.MustParseAddr("255.255.255.0"),
+		Enabled:    true,
+	})
+	suite.Require().NoError(err)
+
+	_, err = c.LocalAccounts.UserCreate(ctx, accounts.UserCreateParams{Name: "Test-User", Description: "Test user"})
+	suite.Require().NoError(err)
+
+	suite.Run("should return the diff of an update", func() {
+		_, err := dryRun.Dns.RecordAUpdate(ctx, dns.RecordAUpdateParams{Name: "web", Zone: "example.local", TimeToLive: 2 * time.Hour})
+		suite.ErrorIs(err, dryrun.ErrDryRun)
+
+		var change *dryrun.Change
+		suite.Require().True(errors.As(err, &change))
+		suite.Equal("windows.dns.RecordAUpdate", change.Operation)
+		suite.Contains(change.Command, "Set-DnsServerResourceRecord")
+		suite.Equal([]dryrun.FieldDiff{{Field: "TimeToLive", Old: time.Hour, New: 2 * time.Hour}}, change.Diff)
+
+		// The record is unchanged.
+		r, err := dryRun.Dns.RecordARead(ctx, dns.RecordAReadParams{Name: "web", Zone: "example.local"})
+		suite.Require().NoError(err)
+		suite.Equal(time.Hour, r.TimeToLive)
+	})
+
+	suite.Run("should return the diff of a DHCP scope update", func() {
+		_, err := dryRun.Dhcp.ScopeV4Update(ctx, dhcp.ScopeV4UpdateParams{ScopeId: scope.ScopeId, Name: "Updated", Enabled: true})
+
+		var change *dryrun.Change
+		suite.Require().True(errors.As(err, &change))
+		suite.Equal([]dryrun.FieldDiff{{Field: "Name", Old: "Test-Scope", New: "Updated"}}, change.Diff)
+	})
+
+	suite.Run("should return the diff of a delete", func() {
+		err := dryRun.LocalAccounts.UserDelete(ctx, accounts.UserDeleteParams{Name: "Test-User"})
+
+		var change *dryrun.Change
+		suite.Require().True(errors.As(err, &change))
+		suite.Equal(`Remove-LocalUser -Name 'Test-User'`, change.Command)
+		suite.Contains(change.Diff, dryrun.FieldDiff{Field: "Description", Old: "Test user", New: ""})
+
+		_, err = c.LocalAccounts.UserRead(ctx, accounts.UserReadParams{Name: "Test-User"})
+		suite.NoError(err)
+	})
+
+	suite.Run("should return the diff of a create", func() {
+		_, err := dryRun.LocalAccounts.GroupCreate(ctx, accounts.GroupCreateParams{Name: "Test-Group", Description: "Test group"})
+
+		var change *dryrun.Change
+		suite.Require().True(errors.As(err, &change))
+		suite.Equal([]dryrun.FieldDiff{
+			{Field: "Name", Old: "", New: "Test-Group"},
+			{Field: "Description", Old: "", New: "Test group"},
+		}, change.Diff)
+
+		_, err = c.LocalAccounts.GroupRead(ctx, accounts.GroupReadParams{Name: "Test-Group"})
+		suite.ErrorIs(err, winerror.ErrNotFound)
+	})
+
+	suite.Run("should return an error for a create of an existing resource", func() {
+		_, err := dryRun.Dns.RecordACreate(ctx, dns.RecordACreateParams{
+			Name:      "web",
+			Zone:      "example.local",
+			Addresses: []netip.Addr{netip.MustParseAddr("10.0.0.2")},
+		})
+		suite.ErrorIs(err, winerror.ErrAlreadyExists)
+		suite.NotErrorIs(err, dryrun.ErrDryRun)
+		suite.EqualError(err, "windows.dns.RecordACreate: the resource already exists")
+
+		_, err = dryRun.LocalAccounts.UserCreate(ctx, accounts.UserCreateParams{Name: "Test-User"})
+		suite.ErrorIs(err, winerror.ErrAlreadyExists)
+		suite.NotErrorIs(err, dryrun.ErrDryRun)
+	})
+
+	suite.Run("should return the read error for an update or a delete of a missing resource", func() {
+		_, err := dryRun.Dns.RecordAUpdate(ctx, dns.RecordAUpdateParams{Name: "missing", Zone: "example.local", TimeToLive: time.Hour})
+		suite.ErrorIs(err, winerror.ErrNotFound)
+		suite.NotErrorIs(err, dryrun.ErrDryRun)
+
+		err = dryRun.LocalAccounts.UserDelete(ctx, accounts.UserDeleteParams{Name: "Missing-User"})
+		suite.ErrorIs(err, winerror.ErrNotFound)
+		suite.NotErrorIs(err, dryrun.ErrDryRun)
+	})
+}
+
+func (suite *GowindowsUnitTestSuite) TestPlan() {
+	ctx := context.Background()
+	host := fake.NewHost()
+
+	c := NewClient(host)
+	_, err := c.LocalAccounts.UserCreate(ctx, accounts.UserCreateParams{Name: "Test-User", Description: "Test user"})
+	suite.Require().NoError(err)
+
+	suite.Run("should return the change as value", func() {
+		change, err := c.Plan(ctx, func(ctx context.Context, c *Client) error {
+			return c.LocalAccounts.UserUpdate(ctx, accounts.UserUpdateParams{Name: "Test-User", Description: "Updated"})
+		})
+		suite.Require().NoError(err)
+		suite.Equal("windows.local.accounts.UserUpdate", change.Operation)
+		suite.Contains(change.Diff, dryrun.FieldDiff{Field: "Description", Old: "Test user", New: "Updated"})
+
+		// The user is unchanged.
+		u, err := c.LocalAccounts.UserRead(ctx, accounts.UserReadParams{Name: "Test-User"})
+		suite.Require().NoError(err)
+		suite.Equal("Test user", u.Description)
+	})
+
+	suite.Run("should return the read error of a missing resource", func() {
+		change, err := c.Plan(ctx, func(ctx context.Context, c *Client) error {
+			return c.LocalAccounts.UserDelete(ctx, accounts.UserDeleteParams{Name: "Missing-User"})
+		})
+		suite.ErrorIs(err, winerror.ErrNotFound)
+		suite.Nil(change)
+	})
+
+	suite.Run("should return an error if the call plans no change", func() {
+		change, err := c.Plan(ctx, func(ctx context.Context, c *Client) error {
+			_, err := c.LocalAccounts.UserRead(ctx, accounts.UserReadParams{Name: "Test-User"})
+			return err
+		})
+		suite.EqualError(err, "gowindows.Plan: call did not plan a change")
+		suite.Nil(change)
+	})
+}
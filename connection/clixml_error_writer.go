@@ -0,0 +1,59 @@
+package connection
+
+import (
+	"io"
+	"strings"
+
+	"github.com/d-strobel/gowindows/parsing"
+)
+
+// CliXmlErrorWriter writes PowerShell error records as a CLIXML error stream, the same way powershell.exe writes them to stderr.
+// The stream can be decoded with parsing.DecodeCliXmlErr and parsing.DecodeCliXmlErrRecords.
+// Connections that do not run powershell.exe per command use it to report the errors of a command.
+type CliXmlErrorWriter struct {
+	w       io.Writer
+	started bool
+}
+
+// NewCliXmlErrorWriter returns a new CliXmlErrorWriter that writes the error stream to w.
+func NewCliXmlErrorWriter(w io.Writer) *CliXmlErrorWriter {
+	return &CliXmlErrorWriter{w: w}
+}
+
+// WriteErrorRecord writes an error record as it is rendered by the PowerShell console to the error stream, e.g.
+//
+//	Get-LocalUser : User test was not found.
+//	At line:1 char:1
+//	+ Get-LocalUser -Name test
+//	+ ~~~~~~~~~~~~~~~~~~~~~~~~
+//	    + CategoryInfo          : ObjectNotFound: (test:String) [Get-LocalUser], UserNotFoundException
+//	    + FullyQualifiedErrorId : UserNotFound,Microsoft.PowerShell.Commands.GetLocalUserCommand
+//
+// Every line is written as an element of the error stream, followed by a blank line that separates the error records.
+func (e *CliXmlErrorWriter) WriteErrorRecord(text string) error {
+	var b strings.Builder
+	if !e.started {
+		b.WriteString("#< CLIXML\r\n" + `<Objs Version="1.1.0.1" xmlns="http://schemas.microsoft.com/powershell/2004/04">`)
+		e.started = true
+	}
+
+	lines := strings.Split(strings.ReplaceAll(strings.TrimRight(text, "\r\n"), "\r\n", "\n"), "\n")
+	for _, line := range append(lines, "") {
+		b.WriteString(`<S S="Error">` + parsing.EncodeCliXmlString(line+"\r\n") + `</S>`)
+	}
+
+	_, err := io.WriteString(e.w, b.String())
+	return err
+}
+
+// Close closes the CLIXML document of the error stream, if any error record was written.
+// It does not close the underlying writer.
+func (e *CliXmlErrorWriter) Close() error {
+	if !e.started {
+		return nil
+	}
+	e.started = false
+
+	_, err := io.WriteString(e.w, "</Objs>")
+	return err
+}
@@ -0,0 +1,66 @@
+package connection
+
+import (
+	"strings"
+
+	"github.com/d-strobel/gowindows/parsing"
+)
+
+func (suite *ConnectionUnitTestSuite) TestCliXmlErrorWriter() {
+	suite.T().Parallel()
+
+	suite.Run("should write the error records as CLIXML error stream", func() {
+		var stderr strings.Builder
+		w := NewCliXmlErrorWriter(&stderr)
+
+		suite.NoError(w.WriteErrorRecord("Get-LocalUser : User test was not found.\r\n" +
+			"At line:1 char:1\r\n" +
+			"+ Get-LocalUser -Name test\r\n" +
+			"+ ~~~~~~~~~~~~~~~~~~~~~~~~\r\n" +
+			"    + CategoryInfo          : ObjectNotFound: (test:String) [Get-LocalUser], UserNotFoundException\r\n" +
+			"    + FullyQualifiedErrorId : UserNotFound,Microsoft.PowerShell.Commands.GetLocalUserCommand\r\n"))
+		suite.NoError(w.WriteErrorRecord("boom\n" +
+			"    + CategoryInfo          : OperationStopped: (boom:String) [], RuntimeException\n" +
+			"    + FullyQualifiedErrorId : boom"))
+		suite.NoError(w.Close())
+
+		records, err := parsing.DecodeCliXmlErrRecords(stderr.String())
+		suite.NoError(err)
+		suite.Equal([]parsing.ErrorRecord{
+			{
+				FullyQualifiedErrorId: "UserNotFound,Microsoft.PowerShell.Commands.GetLocalUserCommand",
+				CategoryInfo: parsing.CategoryInfo{
+					Category:   "ObjectNotFound",
+					Activity:   "Get-LocalUser",
+					Reason:     "UserNotFoundException",
+					TargetName: "test",
+					TargetType: "String",
+				},
+				Exception:      parsing.ExceptionInfo{Type: "UserNotFoundException", Message: "User test was not found."},
+				ScriptPosition: parsing.ScriptPosition{Line: 1, Column: 1, Text: "Get-LocalUser -Name test"},
+			},
+			{
+				FullyQualifiedErrorId: "boom",
+				CategoryInfo: parsing.CategoryInfo{
+					Category:   "OperationStopped",
+					Reason:     "RuntimeException",
+					TargetName: "boom",
+					TargetType: "String",
+				},
+				Exception: parsing.ExceptionInfo{Type: "RuntimeException", Message: "boom"},
+			},
+		}, records)
+
+		decoded, err := parsing.DecodeCliXmlErr(stderr.String())
+		suite.NoError(err)
+		suite.Contains(decoded, "Get-LocalUser : User test was not found.At line:1 char:1")
+	})
+
+	suite.Run("should not write anything without error records", func() {
+		var stderr strings.Builder
+		w := NewCliXmlErrorWriter(&stderr)
+
+		suite.NoError(w.Close())
+		suite.Empty(stderr.String())
+	})
+}
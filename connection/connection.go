@@ -4,6 +4,8 @@ package connection
 
 import (
 	"context"
+	"io"
+	"strings"
 )
 
 // Connection defines the interface for a connection.
@@ -17,15 +19,71 @@ type Connection interface {
 	// It returns the result of the command execution.
 	RunWithPowershell(ctx context.Context, cmd string) (CmdResult, error)
 
+	// Stream runs a command using the configured connection and context.
+	// It writes stdout and stderr to the streams as the data arrives and returns the exit code of the command.
+	// Nothing is written to the streams after Stream returned.
+	Stream(ctx context.Context, cmd string, streams Streams) (int, error)
+
+	// StreamWithPowershell runs a command using the configured connection and context via Powershell.
+	// It writes stdout and stderr to the streams as the data arrives and returns the exit code of the command.
+	StreamWithPowershell(ctx context.Context, cmd string, streams Streams) (int, error)
+
 	// Close closes any open connection.
 	Close() error
 }
 
-// CmdResult represents the result of executing a Cmd command, including stdout and stderr.
+// CmdResult represents the result of executing a Cmd command, including stdout, stderr and the exit code.
 type CmdResult struct {
 	// StdOut contains the standard output of the command.
 	StdOut string
 
 	// StdErr contains the standard error output of the command.
 	StdErr string
+
+	// ExitCode contains the exit code of the process.
+	ExitCode int
+}
+
+// Streams contains the standard streams of a command.
+// A nil writer discards the output of the stream.
+type Streams struct {
+	// Stdin is read until EOF and sent to the standard input of the command.
+	// A PowerShell command reads the input with [Console]::In or $input.
+	// Connections that run commands in a PowerShell runspace provide the input with $input only.
+	// If Stdin is nil, the standard input of the command is closed right away.
+	Stdin io.Reader
+
+	// Stdout receives the standard output of the command.
+	Stdout io.Writer
+
+	// Stderr receives the standard error output of the command.
+	Stderr io.Writer
+}
+
+// RunWithInput runs a command using the connection and context and sends stdin to the standard input of the command.
+// It returns the result of the command execution.
+// Secrets passed via stdin do not show up in the command line of the process.
+func RunWithInput(ctx context.Context, conn Connection, cmd string, stdin io.Reader) (CmdResult, error) {
+	var stdout, stderr strings.Builder
+
+	exitCode, err := conn.Stream(ctx, cmd, Streams{Stdin: stdin, Stdout: &stdout, Stderr: &stderr})
+	if err != nil {
+		return CmdResult{}, err
+	}
+
+	return CmdResult{StdOut: stdout.String(), StdErr: stderr.String(), ExitCode: exitCode}, nil
+}
+
+// RunWithPowershellInput runs a command using the connection and context via Powershell
+// and sends stdin to the standard input of the command.
+// It returns the result of the command execution.
+func RunWithPowershellInput(ctx context.Context, conn Connection, cmd string, stdin io.Reader) (CmdResult, error) {
+	var stdout, stderr strings.Builder
+
+	exitCode, err := conn.StreamWithPowershell(ctx, cmd, Streams{Stdin: stdin, Stdout: &stdout, Stderr: &stderr})
+	if err != nil {
+		return CmdResult{}, err
+	}
+
+	return CmdResult{StdOut: stdout.String(), StdErr: stderr.String(), ExitCode: exitCode}, nil
 }
@@ -0,0 +1,669 @@
+package fake
+
+import (
+	"fmt"
+	"strings"
+	"time"
+	"unicode"
+)
+
+const (
+	// maxDescriptionLength is the maximum length of the description of a local user or group.
+	maxDescriptionLength = 48
+
+	// maxPasswordAge is the maximum age of a password that expires.
+	maxPasswordAge = 42 * 24 * time.Hour
+
+	// minPasswordLength is the minimum length of a password.
+	minPasswordLength = 8
+)
+
+// localUser is a local user account.
+type localUser struct {
+	name                  string
+	description           string
+	fullName              string
+	sid                   string
+	enabled               bool
+	accountExpires        time.Time
+	passwordRequired      bool
+	passwordNeverExpires  bool
+	userMayChangePassword bool
+	passwordLastSet       time.Time
+}
+
+// json returns the user as it is rendered by Get-LocalUser | ConvertTo-Json.
+func (u *localUser) json() any {
+	var passwordChangeableDate, passwordExpires time.Time
+	if !u.passwordLastSet.IsZero() {
+		passwordChangeableDate = u.passwordLastSet
+		if !u.passwordNeverExpires {
+			passwordExpires = u.passwordLastSet.Add(maxPasswordAge)
+		}
+	}
+
+	return struct {
+		AccountExpires         dotnetDate
+		Description            string
+		Enabled                bool
+		FullName               string
+		PasswordChangeableDate dotnetDate
+		PasswordExpires        dotnetDate
+		UserMayChangePassword  bool
+		PasswordRequired       bool
+		PasswordLastSet        dotnetDate
+		LastLogon              dotnetDate
+		Name                   string
+		SID                    securityIdentifier
+		PrincipalSource        int
+		ObjectClass            string
+	}{
+		AccountExpires:         dotnetDate{u.accountExpires},
+		Description:            u.description,
+		Enabled:                u.enabled,
+		FullName:               u.fullName,
+		PasswordChangeableDate: dotnetDate{passwordChangeableDate},
+		PasswordExpires:        dotnetDate{passwordExpires},
+		UserMayChangePassword:  u.userMayChangePassword,
+		PasswordRequired:       u.passwordRequired,
+		PasswordLastSet:        dotnetDate{u.passwordLastSet},
+		Name:                   u.name,
+		SID:                    securityIdentifier(u.sid),
+		PrincipalSource:        1,
+		ObjectClass:            "User",
+	}
+}
+
+// clone returns a copy of the user, so the output of a cmdlet is not changed by later commands.
+func (u *localUser) clone() any {
+	c := *u
+	return &c
+}
+
+// set sets the properties of the user that are bound by New-LocalUser or Set-LocalUser.
+func (u *localUser) set(inv *invocation) error {
+	if inv.has("Description") {
+		if err := descriptionParam(inv); err != nil {
+			return err
+		}
+		u.description = inv.string("Description")
+	}
+	if inv.has("FullName") {
+		u.fullName = inv.string("FullName")
+	}
+	if inv.has("AccountExpires") {
+		t, ok := inv.value("AccountExpires").(time.Time)
+		if !ok {
+			return inv.transformationError("AccountExpires", "System.DateTime")
+		}
+		u.accountExpires = t
+	}
+	if inv.bool("AccountNeverExpires") {
+		u.accountExpires = time.Time{}
+	}
+	if inv.has("PasswordNeverExpires") {
+		u.passwordNeverExpires = inv.bool("PasswordNeverExpires")
+	}
+	if inv.has("UserMayChangePassword") {
+		u.userMayChangePassword = inv.bool("UserMayChangePassword")
+	}
+	if inv.has("Password") {
+		password, ok := inv.value("Password").(secureString)
+		if !ok {
+			return inv.transformationError("Password", "System.Security.SecureString")
+		}
+		if !validPassword(string(password)) {
+			return inv.fail("InvalidArgument", "InvalidPassword", "InvalidPasswordException", u.name, "LocalUser",
+				"Unable to update the password. The value provided for the new password does not meet the length, complexity, or history requirements of the domain.")
+		}
+		u.passwordRequired = true
+		u.passwordLastSet = now()
+	}
+	return nil
+}
+
+// localGroup is a local security group.
+type localGroup struct {
+	name        string
+	description string
+	sid         string
+
+	// members are the SIDs of the members.
+	members []string
+}
+
+// json returns the group as it is rendered by Get-LocalGroup | ConvertTo-Json.
+func (g *localGroup) json() any {
+	return struct {
+		Description     string
+		Name            string
+		SID             securityIdentifier
+		PrincipalSource int
+		ObjectClass     string
+	}{
+		Description:     g.description,
+		Name:            g.name,
+		SID:             securityIdentifier(g.sid),
+		PrincipalSource: 1,
+		ObjectClass:     "Group",
+	}
+}
+
+// clone returns a copy of the group, so the output of a cmdlet is not changed by later commands.
+func (g *localGroup) clone() any {
+	c := *g
+	c.members = append([]string(nil), g.members...)
+	return &c
+}
+
+// groupMember is a member of a local group as it is returned by Get-LocalGroupMember.
+type groupMember struct {
+	user *localUser
+}
+
+// json returns the member as it is rendered by Get-LocalGroupMember | ConvertTo-Json.
+func (m groupMember) json() any {
+	return struct {
+		Name            string
+		SID             securityIdentifier
+		PrincipalSource int
+		ObjectClass     string
+	}{
+		Name:            memberName(m.user),
+		SID:             securityIdentifier(m.user.sid),
+		PrincipalSource: 1,
+		ObjectClass:     "User",
+	}
+}
+
+// memberName returns the name of a user as member of a group, e.g. WINSRV\Administrator.
+func memberName(u *localUser) string {
+	return ComputerName + `\` + u.name
+}
+
+// seedAccounts adds the built-in local users and groups of a Windows server.
+func (h *Host) seedAccounts() {
+	passwordLastSet := now()
+	administrator := &localUser{
+		name:                  "Administrator",
+		description:           "Built-in account for administering the computer/domain",
+		sid:                   machineSid + "-500",
+		enabled:               true,
+		passwordRequired:      true,
+		userMayChangePassword: true,
+		passwordLastSet:       passwordLastSet,
+	}
+	guest := &localUser{
+		name:        "Guest",
+		description: "Built-in account for guest access to the computer/domain",
+		sid:         machineSid + "-501",
+	}
+	defaultAccount := &localUser{
+		name:        "DefaultAccount",
+		description: "A user account managed by the system.",
+		sid:         machineSid + "-503",
+	}
+	h.users = []*localUser{administrator, defaultAccount, guest}
+
+	h.groups = []*localGroup{
+		{
+			name:        "Administrators",
+			description: "Administrators have complete and unrestricted access to the computer/domain",
+			sid:         "S-1-5-32-544",
+			members:     []string{administrator.sid},
+		},
+		{
+			name:        "Guests",
+			description: "Guests have the same access as members of the Users group by default, except for the Guest account which is further restricted",
+			sid:         "S-1-5-32-546",
+			members:     []string{guest.sid},
+		},
+		{
+			name:        "Remote Desktop Users",
+			description: "Members in this group are granted the right to logon remotely",
+			sid:         "S-1-5-32-555",
+		},
+		{
+			name:        "Users",
+			description: "Users are prevented from making accidental or intentional system-wide changes and can run most applications",
+			sid:         "S-1-5-32-545",
+		},
+	}
+}
+
+// newSid returns the SID of a new local user or group.
+func (h *Host) newSid() string {
+	sid := fmt.Sprintf("%s-%d", machineSid, h.nextRid)
+	h.nextRid++
+	return sid
+}
+
+// user returns the local user with the name or SID or nil.
+func (h *Host) user(name string) *localUser {
+	for _, u := range h.users {
+		if strings.EqualFold(u.name, name) || strings.EqualFold(u.sid, name) {
+			return u
+		}
+	}
+	return nil
+}
+
+// group returns the local group with the name or SID or nil.
+func (h *Host) group(name string) *localGroup {
+	for _, g := range h.groups {
+		if strings.EqualFold(g.name, name) || strings.EqualFold(g.sid, name) {
+			return g
+		}
+	}
+	return nil
+}
+
+// principal returns the local user of a member, which is a name, a name with the computer name or a SID.
+func (h *Host) principal(member string) *localUser {
+	if computer, name, ok := strings.Cut(member, `\`); ok {
+		if !strings.EqualFold(computer, ComputerName) && computer != "." {
+			return nil
+		}
+		member = name
+	}
+	return h.user(member)
+}
+
+// nameInUse reports whether the name is used by a local user or group.
+func (h *Host) nameInUse(name string) bool {
+	for _, u := range h.users {
+		if strings.EqualFold(u.name, name) {
+			return true
+		}
+	}
+	for _, g := range h.groups {
+		if strings.EqualFold(g.name, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// removeMember removes a SID from all groups.
+func (h *Host) removeMember(sid string) {
+	for _, g := range h.groups {
+		for i := range g.members {
+			if g.members[i] == sid {
+				g.members = append(g.members[:i], g.members[i+1:]...)
+				break
+			}
+		}
+	}
+}
+
+// accountsCmdlets are the cmdlets of the Microsoft.PowerShell.LocalAccounts module.
+var accountsCmdlets = []*cmdlet{
+	{
+		name:   "Get-LocalUser",
+		source: "Microsoft.PowerShell.Commands.GetLocalUserCommand",
+		params: []string{"Name", "SID"},
+		run: func(h *Host, inv *invocation) ([]any, error) {
+			if !inv.has("Name") && !inv.has("SID") {
+				users := make([]any, len(h.users))
+				for i, u := range h.users {
+					users[i] = u.clone()
+				}
+				return users, nil
+			}
+
+			u, err := userParam(h, inv)
+			if err != nil {
+				return nil, err
+			}
+			return []any{u.clone()}, nil
+		},
+	},
+	{
+		name:   "New-LocalUser",
+		source: "Microsoft.PowerShell.Commands.NewLocalUserCommand",
+		params: []string{
+			"Name", "Description", "AccountExpires", "AccountNeverExpires", "Disabled", "FullName",
+			"Password", "NoPassword", "PasswordNeverExpires", "UserMayNotChangePassword",
+		},
+		run: func(h *Host, inv *invocation) ([]any, error) {
+			name := inv.string("Name")
+			if err := validName(inv, name, 20); err != nil {
+				return nil, err
+			}
+			if inv.has("Password") == inv.bool("NoPassword") {
+				return nil, inv.fail("InvalidArgument", "AmbiguousParameterSet", "ParameterBindingException", "", "",
+					"Parameter set cannot be resolved using the specified named parameters.")
+			}
+
+			u := &localUser{
+				name:                  name,
+				enabled:               !inv.bool("Disabled"),
+				userMayChangePassword: !inv.bool("UserMayNotChangePassword"),
+			}
+			if err := u.set(inv); err != nil {
+				return nil, err
+			}
+
+			if h.user(name) != nil {
+				return nil, inv.fail("ResourceExists", "UserExists", "UserExistsException", name, "LocalUser",
+					"User %s already exists.", name)
+			}
+			if h.nameInUse(name) {
+				return nil, inv.fail("ResourceExists", "NameInUse", "NameInUseException", name, "LocalUser",
+					"The name %s is already in use.", name)
+			}
+
+			u.sid = h.newSid()
+			h.users = append(h.users, u)
+			return []any{u.clone()}, nil
+		},
+	},
+	{
+		name:   "Set-LocalUser",
+		source: "Microsoft.PowerShell.Commands.SetLocalUserCommand",
+		params: []string{
+			"Name", "SID", "Description", "AccountExpires", "AccountNeverExpires", "FullName",
+			"Password", "PasswordNeverExpires", "UserMayChangePassword",
+		},
+		run: func(h *Host, inv *invocation) ([]any, error) {
+			u, err := userParam(h, inv)
+			if err != nil {
+				return nil, err
+			}
+
+			// The user is only changed if all parameters are valid.
+			updated := u.clone().(*localUser)
+			if err := updated.set(inv); err != nil {
+				return nil, err
+			}
+			*u = *updated
+			return nil, nil
+		},
+	},
+	{
+		name:   "Enable-LocalUser",
+		source: "Microsoft.PowerShell.Commands.EnableLocalUserCommand",
+		params: []string{"Name", "SID"},
+		run: func(h *Host, inv *invocation) ([]any, error) {
+			u, err := userParam(h, inv)
+			if err != nil {
+				return nil, err
+			}
+			u.enabled = true
+			return nil, nil
+		},
+	},
+	{
+		name:   "Disable-LocalUser",
+		source: "Microsoft.PowerShell.Commands.DisableLocalUserCommand",
+		params: []string{"Name", "SID"},
+		run: func(h *Host, inv *invocation) ([]any, error) {
+			u, err := userParam(h, inv)
+			if err != nil {
+				return nil, err
+			}
+			u.enabled = false
+			return nil, nil
+		},
+	},
+	{
+		name:   "Remove-LocalUser",
+		source: "Microsoft.PowerShell.Commands.RemoveLocalUserCommand",
+		params: []string{"Name", "SID"},
+		run: func(h *Host, inv *invocation) ([]any, error) {
+			u, err := userParam(h, inv)
+			if err != nil {
+				return nil, err
+			}
+
+			for i := range h.users {
+				if h.users[i] == u {
+					h.users = append(h.users[:i], h.users[i+1:]...)
+					break
+				}
+			}
+			h.removeMember(u.sid)
+			return nil, nil
+		},
+	},
+	{
+		name:   "Get-LocalGroup",
+		source: "Microsoft.PowerShell.Commands.GetLocalGroupCommand",
+		params: []string{"Name", "SID"},
+		run: func(h *Host, inv *invocation) ([]any, error) {
+			if !inv.has("Name") && !inv.has("SID") {
+				groups := make([]any, len(h.groups))
+				for i, g := range h.groups {
+					groups[i] = g.clone()
+				}
+				return groups, nil
+			}
+
+			g, err := groupParam(h, inv)
+			if err != nil {
+				return nil, err
+			}
+			return []any{g.clone()}, nil
+		},
+	},
+	{
+		name:   "New-LocalGroup",
+		source: "Microsoft.PowerShell.Commands.NewLocalGroupCommand",
+		params: []string{"Name", "Description"},
+		run: func(h *Host, inv *invocation) ([]any, error) {
+			name := inv.string("Name")
+			if err := validName(inv, name, 256); err != nil {
+				return nil, err
+			}
+			if err := descriptionParam(inv); err != nil {
+				return nil, err
+			}
+
+			if h.group(name) != nil {
+				return nil, inv.fail("ResourceExists", "GroupExists", "GroupExistsException", name, "LocalGroup",
+					"Group %s already exists.", name)
+			}
+			if h.nameInUse(name) {
+				return nil, inv.fail("ResourceExists", "NameInUse", "NameInUseException", name, "LocalGroup",
+					"The name %s is already in use.", name)
+			}
+
+			g := &localGroup{name: name, description: inv.string("Description"), sid: h.newSid()}
+			h.groups = append(h.groups, g)
+			return []any{g.clone()}, nil
+		},
+	},
+	{
+		name:   "Set-LocalGroup",
+		source: "Microsoft.PowerShell.Commands.SetLocalGroupCommand",
+		params: []string{"Name", "SID", "Description"},
+		run: func(h *Host, inv *invocation) ([]any, error) {
+			g, err := groupParam(h, inv)
+			if err != nil {
+				return nil, err
+			}
+			if err := descriptionParam(inv); err != nil {
+				return nil, err
+			}
+
+			g.description = inv.string("Description")
+			return nil, nil
+		},
+	},
+	{
+		name:   "Remove-LocalGroup",
+		source: "Microsoft.PowerShell.Commands.RemoveLocalGroupCommand",
+		params: []string{"Name", "SID"},
+		run: func(h *Host, inv *invocation) ([]any, error) {
+			g, err := groupParam(h, inv)
+			if err != nil {
+				return nil, err
+			}
+
+			for i := range h.groups {
+				if h.groups[i] == g {
+					h.groups = append(h.groups[:i], h.groups[i+1:]...)
+					break
+				}
+			}
+			return nil, nil
+		},
+	},
+	{
+		name:   "Get-LocalGroupMember",
+		source: "Microsoft.PowerShell.Commands.GetLocalGroupMemberCommand",
+		params: []string{"Name", "SID", "Member"},
+		run: func(h *Host, inv *invocation) ([]any, error) {
+			g, err := groupParam(h, inv)
+			if err != nil {
+				return nil, err
+			}
+
+			if !inv.has("Member") {
+				var members []any
+				for _, sid := range g.members {
+					if u := h.user(sid); u != nil {
+						members = append(members, groupMember{user: u.clone().(*localUser)})
+					}
+				}
+				return members, nil
+			}
+
+			member := inv.string("Member")
+			if u := h.principal(member); u != nil {
+				for _, sid := range g.members {
+					if sid == u.sid {
+						return []any{groupMember{user: u.clone().(*localUser)}}, nil
+					}
+				}
+			}
+			return nil, inv.fail("ObjectNotFound", "PrincipalNotFound", "PrincipalNotFoundException", member, "String",
+				"Principal %s was not found.", member)
+		},
+	},
+	{
+		name:   "Add-LocalGroupMember",
+		source: "Microsoft.PowerShell.Commands.AddLocalGroupMemberCommand",
+		params: []string{"Name", "SID", "Member"},
+		run: func(h *Host, inv *invocation) ([]any, error) {
+			g, u, err := memberParams(h, inv)
+			if err != nil {
+				return nil, err
+			}
+
+			for _, sid := range g.members {
+				if sid == u.sid {
+					return nil, inv.fail("ResourceExists", "MemberExists", "MemberExistsException", memberName(u), "LocalPrincipal",
+						"%s is already a member of group %s.", memberName(u), g.name)
+				}
+			}
+			g.members = append(g.members, u.sid)
+			return nil, nil
+		},
+	},
+	{
+		name:   "Remove-LocalGroupMember",
+		source: "Microsoft.PowerShell.Commands.RemoveLocalGroupMemberCommand",
+		params: []string{"Name", "SID", "Member"},
+		run: func(h *Host, inv *invocation) ([]any, error) {
+			g, u, err := memberParams(h, inv)
+			if err != nil {
+				return nil, err
+			}
+
+			for i, sid := range g.members {
+				if sid == u.sid {
+					g.members = append(g.members[:i], g.members[i+1:]...)
+					return nil, nil
+				}
+			}
+			return nil, inv.fail("ObjectNotFound", "MemberNotFound", "MemberNotFoundException", memberName(u), "LocalPrincipal",
+				"Member %s was not found in group %s.", memberName(u), g.name)
+		},
+	},
+}
+
+// userParam returns the user of the Name or SID parameter.
+func userParam(h *Host, inv *invocation) (*localUser, error) {
+	name, targetType := inv.string("Name"), "String"
+	if inv.has("SID") {
+		name, targetType = inv.string("SID"), "SecurityIdentifier"
+	}
+
+	u := h.user(name)
+	if u == nil {
+		return nil, inv.fail("ObjectNotFound", "UserNotFound", "UserNotFoundException", name, targetType,
+			"User %s was not found.", name)
+	}
+	return u, nil
+}
+
+// groupParam returns the group of the Name or SID parameter.
+func groupParam(h *Host, inv *invocation) (*localGroup, error) {
+	name, targetType := inv.string("Name"), "String"
+	if inv.has("SID") {
+		name, targetType = inv.string("SID"), "SecurityIdentifier"
+	}
+
+	g := h.group(name)
+	if g == nil {
+		return nil, inv.fail("ObjectNotFound", "GroupNotFound", "GroupNotFoundException", name, targetType,
+			"Group %s was not found.", name)
+	}
+	return g, nil
+}
+
+// memberParams returns the group of the Name or SID parameter and the user of the Member parameter.
+func memberParams(h *Host, inv *invocation) (*localGroup, *localUser, error) {
+	g, err := groupParam(h, inv)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	member := inv.string("Member")
+	u := h.principal(member)
+	if u == nil {
+		return nil, nil, inv.fail("ObjectNotFound", "PrincipalNotFound", "PrincipalNotFoundException", member, "String",
+			"Principal %s was not found.", member)
+	}
+	return g, u, nil
+}
+
+// descriptionParam validates the length of the Description parameter.
+func descriptionParam(inv *invocation) error {
+	if n := len([]rune(inv.string("Description"))); n > maxDescriptionLength {
+		return inv.validationError("Description", "The character length of the %d argument is too long. Shorten the character length of the argument so it is fewer than or equal to \"%d\" characters, then try the command again.", n, maxDescriptionLength)
+	}
+	return nil
+}
+
+// validName returns an error if the name is not a valid name of a local user or group.
+// Names must not be longer than maxLength, must not consist of periods and spaces only
+// and must not contain the characters " / \ [ ] : ; | = , + * ? < > @.
+func validName(inv *invocation, name string, maxLength int) error {
+	if len([]rune(name)) > maxLength || strings.Trim(name, ". ") == "" || strings.ContainsAny(name, `"/\[]:;|=,+*?<>@`) {
+		return inv.fail("InvalidArgument", "InvalidName", "InvalidNameException", name, "String",
+			"The name provided is not a properly formed account name.")
+	}
+	return nil
+}
+
+// validPassword reports whether a password meets the password policy of the host.
+// Passwords must have at least minPasswordLength characters of three of the categories
+// uppercase letters, lowercase letters, digits and symbols.
+func validPassword(password string) bool {
+	var upper, lower, digit, symbol int
+	for _, r := range password {
+		switch {
+		case unicode.IsUpper(r):
+			upper = 1
+		case unicode.IsLower(r):
+			lower = 1
+		case unicode.IsDigit(r):
+			digit = 1
+		default:
+			symbol = 1
+		}
+	}
+	return len([]rune(password)) >= minPasswordLength && upper+lower+digit+symbol >= 3
+}
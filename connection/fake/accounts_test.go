@@ -0,0 +1,215 @@
+package fake_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/d-strobel/gowindows/connection/fake"
+	"github.com/d-strobel/gowindows/windows/local/accounts"
+	"github.com/d-strobel/gowindows/winerror"
+	"github.com/stretchr/testify/suite"
+)
+
+// Unit test suite for the local accounts cmdlets of the fake host.
+type AccountsUnitTestSuite struct {
+	suite.Suite
+	client *accounts.Client
+}
+
+func TestAccountsUnitTestSuite(t *testing.T) {
+	suite.Run(t, &AccountsUnitTestSuite{})
+}
+
+func (suite *AccountsUnitTestSuite) SetupTest() {
+	suite.client = accounts.NewClient(fake.NewHost())
+}
+
+func (suite *AccountsUnitTestSuite) TestBuiltinAccounts() {
+	ctx := context.Background()
+
+	g, err := suite.client.GroupRead(ctx, accounts.GroupReadParams{Name: "Administrators"})
+	suite.Require().NoError(err)
+	suite.Equal(accounts.Group{
+		Name:        "Administrators",
+		Description: "Administrators have complete and unrestricted access to the computer/domain",
+		SID:         accounts.SID{Value: "S-1-5-32-544"},
+	}, g)
+
+	members, err := suite.client.GroupMemberList(ctx, accounts.GroupMemberListParams{SID: "S-1-5-32-544"})
+	suite.Require().NoError(err)
+	suite.Equal([]accounts.GroupMember{{
+		Name:        `WINSRV\Administrator`,
+		SID:         accounts.SID{Value: "S-1-5-21-153895498-367353507-3704405138-500"},
+		ObjectClass: "User",
+	}}, members)
+
+	u, err := suite.client.UserRead(ctx, accounts.UserReadParams{Name: "Guest"})
+	suite.Require().NoError(err)
+	suite.False(u.Enabled)
+	suite.Equal("Built-in account for guest access to the computer/domain", u.Description)
+}
+
+func (suite *AccountsUnitTestSuite) TestUser() {
+	ctx := context.Background()
+	expires := time.Date(3025, 11, 10, 16, 0, 0, 0, time.UTC)
+
+	u, err := suite.client.UserCreate(ctx, accounts.UserCreateParams{
+		Name:                  "Test-User",
+		Description:           "This is a test user",
+		FullName:              "Full-Test-User",
+		AccountExpires:        expires,
+		Password:              "Start123!!!",
+		Enabled:               true,
+		UserMayChangePassword: true,
+	})
+	suite.Require().NoError(err)
+	suite.Equal("Test-User", u.Name)
+	suite.Equal("S-1-5-21-153895498-367353507-3704405138-1000", u.SID.Value)
+	suite.Equal(expires, u.AccountExpires.Time)
+	suite.True(u.Enabled)
+	suite.True(u.PasswordRequired)
+	suite.False(u.PasswordLastSet.IsZero())
+	suite.False(u.PasswordExpires.IsZero())
+
+	_, err = suite.client.UserCreate(ctx, accounts.UserCreateParams{Name: "test-user"})
+	suite.ErrorIs(err, winerror.ErrAlreadyExists)
+
+	suite.Require().NoError(suite.client.UserUpdate(ctx, accounts.UserUpdateParams{
+		SID:                  u.SID.Value,
+		Description:          "Updated",
+		PasswordNeverExpires: true,
+	}))
+
+	u, err = suite.client.UserRead(ctx, accounts.UserReadParams{Name: "Test-User"})
+	suite.Require().NoError(err)
+	suite.Equal("Updated", u.Description)
+	suite.Empty(u.FullName)
+	suite.True(u.AccountExpires.IsZero())
+	suite.True(u.PasswordExpires.IsZero())
+	suite.False(u.Enabled)
+	suite.False(u.UserMayChangePassword)
+
+	users, err := suite.client.UserList(ctx)
+	suite.Require().NoError(err)
+	suite.Len(users, 4)
+
+	suite.Require().NoError(suite.client.UserDelete(ctx, accounts.UserDeleteParams{Name: "Test-User"}))
+
+	_, err = suite.client.UserRead(ctx, accounts.UserReadParams{Name: "Test-User"})
+	suite.ErrorIs(err, winerror.ErrNotFound)
+}
+
+func (suite *AccountsUnitTestSuite) TestGroup() {
+	ctx := context.Background()
+
+	g, err := suite.client.GroupCreate(ctx, accounts.GroupCreateParams{Name: "Test-Group", Description: "Test group"})
+	suite.Require().NoError(err)
+	suite.Equal("Test group", g.Description)
+
+	_, err = suite.client.GroupCreate(ctx, accounts.GroupCreateParams{Name: "Test-Group"})
+	suite.ErrorIs(err, winerror.ErrAlreadyExists)
+
+	suite.Require().NoError(suite.client.GroupUpdate(ctx, accounts.GroupUpdateParams{SID: g.SID.Value, Description: "Updated"}))
+
+	g, err = suite.client.GroupRead(ctx, accounts.GroupReadParams{Name: "Test-Group"})
+	suite.Require().NoError(err)
+	suite.Equal("Updated", g.Description)
+
+	suite.Require().NoError(suite.client.GroupDelete(ctx, accounts.GroupDeleteParams{Name: "Test-Group"}))
+
+	_, err = suite.client.GroupRead(ctx, accounts.GroupReadParams{Name: "Test-Group"})
+	suite.ErrorIs(err, winerror.ErrNotFound)
+}
+
+func (suite *AccountsUnitTestSuite) TestGroupMember() {
+	ctx := context.Background()
+
+	_, err := suite.client.UserCreate(ctx, accounts.UserCreateParams{Name: "Test-User"})
+	suite.Require().NoError(err)
+
+	suite.Require().NoError(suite.client.GroupMemberCreate(ctx, accounts.GroupMemberCreateParams{Name: "Users", Member: "Test-User"}))
+
+	err = suite.client.GroupMemberCreate(ctx, accounts.GroupMemberCreateParams{Name: "Users", Member: `WINSRV\Test-User`})
+	suite.ErrorIs(err, winerror.ErrAlreadyExists)
+
+	m, err := suite.client.GroupMemberRead(ctx, accounts.GroupMemberReadParams{Name: "Users", Member: "Test-User"})
+	suite.Require().NoError(err)
+	suite.Equal(`WINSRV\Test-User`, m.Name)
+
+	suite.Require().NoError(suite.client.GroupMemberDelete(ctx, accounts.GroupMemberDeleteParams{Name: "Users", Member: "Test-User"}))
+
+	err = suite.client.GroupMemberDelete(ctx, accounts.GroupMemberDeleteParams{Name: "Users", Member: "Test-User"})
+	suite.ErrorIs(err, winerror.ErrNotFound)
+
+	// Removing a user removes its memberships.
+	suite.Require().NoError(suite.client.GroupMemberCreate(ctx, accounts.GroupMemberCreateParams{Name: "Users", Member: "Test-User"}))
+	suite.Require().NoError(suite.client.UserDelete(ctx, accounts.UserDeleteParams{Name: "Test-User"}))
+
+	members, err := suite.client.GroupMemberList(ctx, accounts.GroupMemberListParams{Name: "Users"})
+	suite.Require().NoError(err)
+	suite.Empty(members)
+}
+
+func (suite *AccountsUnitTestSuite) TestErrors() {
+	ctx := context.Background()
+
+	tcs := []struct {
+		description   string
+		run           func() error
+		expectedError error
+	}{
+		{
+			"assert weak password",
+			func() error {
+				_, err := suite.client.UserCreate(ctx, accounts.UserCreateParams{Name: "Test-User", Password: "weak"})
+				return err
+			},
+			winerror.ErrInvalidParameter,
+		},
+		{
+			"assert invalid name",
+			func() error {
+				_, err := suite.client.UserCreate(ctx, accounts.UserCreateParams{Name: "Test/User"})
+				return err
+			},
+			winerror.ErrInvalidParameter,
+		},
+		{
+			"assert description too long",
+			func() error {
+				_, err := suite.client.GroupCreate(ctx, accounts.GroupCreateParams{Name: "Test-Group", Description: strings.Repeat("a", 49)})
+				return err
+			},
+			winerror.ErrInvalidParameter,
+		},
+		{
+			"assert name of a group",
+			func() error {
+				_, err := suite.client.UserCreate(ctx, accounts.UserCreateParams{Name: "Users"})
+				return err
+			},
+			winerror.ErrAlreadyExists,
+		},
+		{
+			"assert missing principal",
+			func() error {
+				return suite.client.GroupMemberCreate(ctx, accounts.GroupMemberCreateParams{Name: "Users", Member: "Missing"})
+			},
+			winerror.ErrNotFound,
+		},
+		{
+			"assert missing group",
+			func() error {
+				return suite.client.GroupDelete(ctx, accounts.GroupDeleteParams{SID: "S-1-5-21-1-2-3-4"})
+			},
+			winerror.ErrNotFound,
+		},
+	}
+
+	for _, tc := range tcs {
+		suite.T().Logf("test case: %s", tc.description)
+		suite.ErrorIs(tc.run(), tc.expectedError)
+	}
+}
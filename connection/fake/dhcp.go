@@ -0,0 +1,322 @@
+package fake
+
+import (
+	"math"
+	"net/netip"
+	"time"
+)
+
+// defaultLeaseDuration is the lease duration of a DHCP scope if it is not set.
+const defaultLeaseDuration = 8 * 24 * time.Hour
+
+// dhcpScope is an IPv4 DHCP scope.
+type dhcpScope struct {
+	name             string
+	description      string
+	scopeId          netip.Addr
+	startRange       netip.Addr
+	endRange         netip.Addr
+	subnetMask       netip.Addr
+	state            string
+	maxBootpClients  uint32
+	activatePolicies bool
+	napEnable        bool
+	napProfile       string
+	delay            uint16
+	leaseDuration    time.Duration
+	superscopeName   string
+	scopeType        string
+}
+
+// json returns the scope as it is rendered by Get-DhcpServerv4Scope | ConvertTo-Json.
+func (s *dhcpScope) json() any {
+	return struct {
+		ScopeId          ipAddress
+		SubnetMask       ipAddress
+		StartRange       ipAddress
+		EndRange         ipAddress
+		ActivatePolicies bool
+		Delay            uint16
+		Description      string
+		LeaseDuration    timeSpan
+		MaxBootpClients  uint32
+		Name             string
+		NapEnable        bool
+		NapProfile       string
+		State            string
+		SuperscopeName   string
+		Type             string
+		PSComputerName   *string
+	}{
+		ScopeId:          ipAddress(s.scopeId),
+		SubnetMask:       ipAddress(s.subnetMask),
+		StartRange:       ipAddress(s.startRange),
+		EndRange:         ipAddress(s.endRange),
+		ActivatePolicies: s.activatePolicies,
+		Delay:            s.delay,
+		Description:      s.description,
+		LeaseDuration:    timeSpan(s.leaseDuration),
+		MaxBootpClients:  s.maxBootpClients,
+		Name:             s.name,
+		NapEnable:        s.napEnable,
+		NapProfile:       s.napProfile,
+		State:            s.state,
+		SuperscopeName:   s.superscopeName,
+		Type:             s.scopeType,
+	}
+}
+
+// clone returns a copy of the scope, so the output of a cmdlet is not changed by later commands.
+func (s *dhcpScope) clone() any {
+	c := *s
+	return &c
+}
+
+// set sets the optional properties of the scope that are bound by Add-DhcpServerv4Scope or Set-DhcpServerv4Scope.
+func (s *dhcpScope) set(inv *invocation) error {
+	if inv.has("Name") {
+		s.name = inv.string("Name")
+	}
+	if inv.has("Description") {
+		s.description = inv.string("Description")
+	}
+	if inv.has("State") {
+		state, err := inv.validateSet("State", "Active", "InActive")
+		if err != nil {
+			return err
+		}
+		s.state = state
+	}
+	if inv.has("Type") {
+		scopeType, err := inv.validateSet("Type", "Dhcp", "Bootp", "Both")
+		if err != nil {
+			return err
+		}
+		s.scopeType = scopeType
+	}
+	if inv.has("MaxBootpClients") {
+		n, err := inv.int("MaxBootpClients")
+		if err != nil || n < 0 || n > math.MaxUint32 {
+			return inv.transformationError("MaxBootpClients", "System.UInt32")
+		}
+		s.maxBootpClients = uint32(n)
+	}
+	if inv.has("Delay") {
+		n, err := inv.int("Delay")
+		if err != nil || n < 0 || n > math.MaxUint16 {
+			return inv.transformationError("Delay", "System.UInt16")
+		}
+		s.delay = uint16(n)
+	}
+	if inv.has("LeaseDuration") {
+		d, err := inv.duration("LeaseDuration")
+		if err != nil {
+			return err
+		}
+		s.leaseDuration = d
+	}
+	if inv.has("ActivatePolicies") {
+		s.activatePolicies = inv.bool("ActivatePolicies")
+	}
+	if inv.has("NapEnable") {
+		s.napEnable = inv.bool("NapEnable")
+	}
+	if inv.has("NapProfile") {
+		s.napProfile = inv.string("NapProfile")
+	}
+	if inv.has("SuperscopeName") {
+		s.superscopeName = inv.string("SuperscopeName")
+	}
+	return nil
+}
+
+// setRange sets the range of the scope if the StartRange and EndRange parameters are bound.
+// The range must be part of the subnet of the scope.
+func (s *dhcpScope) setRange(inv *invocation) error {
+	if !inv.has("StartRange") && !inv.has("EndRange") {
+		return nil
+	}
+
+	start, err := ipv4Param(inv, "StartRange")
+	if err != nil {
+		return err
+	}
+	end, err := ipv4Param(inv, "EndRange")
+	if err != nil {
+		return err
+	}
+
+	if start.Compare(end) > 0 || maskAddr(start, s.subnetMask) != s.scopeId || maskAddr(end, s.subnetMask) != s.scopeId {
+		return inv.fail("InvalidArgument", "DHCP 20048", "CimException", s.scopeId.String(), "root/Microsoft/...erverv4Scope",
+			"The specified IP address range %s - %s is not valid for the subnet %s on DHCP server %s.", start, end, s.scopeId, ComputerName)
+	}
+
+	s.startRange, s.endRange = start, end
+	return nil
+}
+
+// scope returns the scope with the ID or nil.
+func (h *Host) scope(scopeId netip.Addr) *dhcpScope {
+	for _, s := range h.scopes {
+		if s.scopeId == scopeId {
+			return s
+		}
+	}
+	return nil
+}
+
+// dhcpCmdlets are the cmdlets of the DhcpServer module.
+var dhcpCmdlets = []*cmdlet{
+	{
+		name:   "Get-DhcpServerv4Scope",
+		source: "Get-DhcpServerv4Scope",
+		params: []string{"ScopeId"},
+		run: func(h *Host, inv *invocation) ([]any, error) {
+			if !inv.has("ScopeId") {
+				scopes := make([]any, len(h.scopes))
+				for i, s := range h.scopes {
+					scopes[i] = s.clone()
+				}
+				return scopes, nil
+			}
+
+			s, err := dhcpScopeParam(h, inv, "Failed to get properties of scope %s on DHCP server %s.")
+			if err != nil {
+				return nil, err
+			}
+			return []any{s.clone()}, nil
+		},
+	},
+	{
+		name:   "Add-DhcpServerv4Scope",
+		source: "Add-DhcpServerv4Scope",
+		params: []string{
+			"Name", "StartRange", "EndRange", "SubnetMask", "Description", "State", "Type", "MaxBootpClients",
+			"ActivatePolicies", "NapEnable", "NapProfile", "Delay", "LeaseDuration", "SuperscopeName", "PassThru", "Confirm",
+		},
+		run: func(h *Host, inv *invocation) ([]any, error) {
+			mask, err := ipv4Param(inv, "SubnetMask")
+			if err != nil {
+				return nil, err
+			}
+			start, err := ipv4Param(inv, "StartRange")
+			if err != nil {
+				return nil, err
+			}
+
+			s := &dhcpScope{
+				scopeId:          maskAddr(start, mask),
+				subnetMask:       mask,
+				state:            "Active",
+				scopeType:        "Dhcp",
+				maxBootpClients:  math.MaxUint32,
+				activatePolicies: true,
+				leaseDuration:    defaultLeaseDuration,
+			}
+			if err := s.setRange(inv); err != nil {
+				return nil, err
+			}
+			if err := s.set(inv); err != nil {
+				return nil, err
+			}
+
+			if h.scope(s.scopeId) != nil {
+				return nil, inv.fail("ResourceExists", "DHCP 20004", "CimException", s.scopeId.String(), "root/Microsoft/...erverv4Scope",
+					"Failed to add scope %s on DHCP server %s. The subnet already exists.", s.scopeId, ComputerName)
+			}
+			h.scopes = append(h.scopes, s)
+
+			if inv.bool("PassThru") {
+				return []any{s.clone()}, nil
+			}
+			return nil, nil
+		},
+	},
+	{
+		name:   "Set-DhcpServerv4Scope",
+		source: "Set-DhcpServerv4Scope",
+		params: []string{
+			"ScopeId", "Name", "StartRange", "EndRange", "Description", "State", "Type", "MaxBootpClients",
+			"ActivatePolicies", "NapEnable", "NapProfile", "Delay", "LeaseDuration", "SuperscopeName", "PassThru", "Confirm",
+		},
+		run: func(h *Host, inv *invocation) ([]any, error) {
+			s, err := dhcpScopeParam(h, inv, "Failed to set properties of scope %s on DHCP server %s.")
+			if err != nil {
+				return nil, err
+			}
+
+			// The scope is only changed if all parameters are valid.
+			updated := s.clone().(*dhcpScope)
+			if err := updated.setRange(inv); err != nil {
+				return nil, err
+			}
+			if err := updated.set(inv); err != nil {
+				return nil, err
+			}
+			*s = *updated
+
+			if inv.bool("PassThru") {
+				return []any{s.clone()}, nil
+			}
+			return nil, nil
+		},
+	},
+	{
+		name:   "Remove-DhcpServerv4Scope",
+		source: "Remove-DhcpServerv4Scope",
+		params: []string{"ScopeId", "Force", "PassThru", "Confirm"},
+		run: func(h *Host, inv *invocation) ([]any, error) {
+			s, err := dhcpScopeParam(h, inv, "Failed to delete scope %s on DHCP server %s.")
+			if err != nil {
+				return nil, err
+			}
+
+			for i := range h.scopes {
+				if h.scopes[i] == s {
+					h.scopes = append(h.scopes[:i], h.scopes[i+1:]...)
+					break
+				}
+			}
+
+			if inv.bool("PassThru") {
+				return []any{s}, nil
+			}
+			return nil, nil
+		},
+	},
+}
+
+// dhcpScopeParam returns the scope of the ScopeId parameter.
+// The message of the error is formatted with the scope ID and the computer name if the scope does not exist.
+func dhcpScopeParam(h *Host, inv *invocation, notFound string) (*dhcpScope, error) {
+	scopeId, err := ipv4Param(inv, "ScopeId")
+	if err != nil {
+		return nil, err
+	}
+
+	s := h.scope(scopeId)
+	if s == nil {
+		return nil, inv.fail("ObjectNotFound", "DHCP 20005", "CimException", scopeId.String(), "root/Microsoft/...erverv4Scope",
+			notFound, scopeId, ComputerName)
+	}
+	return s, nil
+}
+
+// ipv4Param returns the IPv4 address of a parameter.
+func ipv4Param(inv *invocation, name string) (netip.Addr, error) {
+	addr, err := netip.ParseAddr(inv.string(name))
+	if err != nil || !addr.Is4() {
+		return netip.Addr{}, inv.fail("InvalidData", "ParameterArgumentTransformationError", "ParameterBindingArgumentTransformationException", "", "",
+			"Cannot process argument transformation on parameter '%s'. Cannot convert value \"%s\" to type \"System.Net.IPAddress\". Error: \"An invalid IP address was specified.\"", name, inv.string(name))
+	}
+	return addr, nil
+}
+
+// maskAddr returns the network address of an IPv4 address in the subnet of the mask.
+func maskAddr(addr netip.Addr, mask netip.Addr) netip.Addr {
+	a, m := addr.As4(), mask.As4()
+	for i := range a {
+		a[i] &= m[i]
+	}
+	return netip.AddrFrom4(a)
+}
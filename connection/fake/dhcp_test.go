@@ -0,0 +1,130 @@
+package fake_test
+
+import (
+	"context"
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/d-strobel/gowindows/connection/fake"
+	"github.com/d-strobel/gowindows/windows/dhcp"
+	"github.com/d-strobel/gowindows/winerror"
+	"github.com/stretchr/testify/suite"
+)
+
+// Unit test suite for the DHCP cmdlets of the fake host.
+type DhcpUnitTestSuite struct {
+	suite.Suite
+	client *dhcp.Client
+}
+
+func TestDhcpUnitTestSuite(t *testing.T) {
+	suite.Run(t, &DhcpUnitTestSuite{})
+}
+
+func (suite *DhcpUnitTestSuite) SetupTest() {
+	suite.client = dhcp.NewClient(fake.NewHost())
+}
+
+func (suite *DhcpUnitTestSuite) TestScopeV4() {
+	ctx := context.Background()
+	scopeId := netip.MustParseAddr("192.168.10.0")
+
+	s, err := suite.client.ScopeV4Create(ctx, dhcp.ScopeV4CreateParams{
+		Name:        "test-scope",
+		Description: "Test scope",
+		StartRange:  netip.MustParseAddr("192.168.10.10"),
+		EndRange:    netip.MustParseAddr("192.168.10.100"),
+		SubnetMask:  netip.MustParseAddr("255.255.255.0"),
+		Enabled:     true,
+	})
+	suite.Require().NoError(err)
+	suite.Equal(dhcp.ScopeV4{
+		Name:             "test-scope",
+		Description:      "Test scope",
+		ScopeId:          scopeId,
+		StartRange:       netip.MustParseAddr("192.168.10.10"),
+		EndRange:         netip.MustParseAddr("192.168.10.100"),
+		SubnetMask:       netip.MustParseAddr("255.255.255.0"),
+		Enabled:          true,
+		MaxBootpClients:  4294967295,
+		ActivatePolicies: true,
+		LeaseDuration:    8 * 24 * time.Hour,
+	}, s)
+
+	_, err = suite.client.ScopeV4Create(ctx, dhcp.ScopeV4CreateParams{
+		Name:       "duplicate",
+		StartRange: netip.MustParseAddr("192.168.10.110"),
+		EndRange:   netip.MustParseAddr("192.168.10.120"),
+		SubnetMask: netip.MustParseAddr("255.255.255.0"),
+	})
+	suite.ErrorIs(err, winerror.ErrAlreadyExists)
+
+	s, err = suite.client.ScopeV4Update(ctx, dhcp.ScopeV4UpdateParams{
+		ScopeId:       scopeId,
+		Name:          "updated-scope",
+		StartRange:    netip.MustParseAddr("192.168.10.20"),
+		EndRange:      netip.MustParseAddr("192.168.10.200"),
+		LeaseDuration: 2 * time.Hour,
+		Delay:         100,
+	})
+	suite.Require().NoError(err)
+	suite.Equal("updated-scope", s.Name)
+	suite.Equal(netip.MustParseAddr("192.168.10.20"), s.StartRange)
+	suite.Equal(2*time.Hour, s.LeaseDuration)
+	suite.Equal(uint16(100), s.Delay)
+	suite.False(s.Enabled)
+
+	s, err = suite.client.ScopeV4Read(ctx, dhcp.ScopeV4ReadParams{ScopeId: scopeId})
+	suite.Require().NoError(err)
+	suite.Equal("updated-scope", s.Name)
+
+	suite.Require().NoError(suite.client.ScopeV4Delete(ctx, dhcp.ScopeV4DeleteParams{ScopeId: scopeId}))
+
+	_, err = suite.client.ScopeV4Read(ctx, dhcp.ScopeV4ReadParams{ScopeId: scopeId})
+	suite.ErrorIs(err, winerror.ErrNotFound)
+}
+
+func (suite *DhcpUnitTestSuite) TestScopeV4Errors() {
+	ctx := context.Background()
+
+	tcs := []struct {
+		description   string
+		run           func() error
+		expectedError error
+	}{
+		{
+			"assert range outside of the subnet",
+			func() error {
+				_, err := suite.client.ScopeV4Create(ctx, dhcp.ScopeV4CreateParams{
+					Name:       "test-scope",
+					StartRange: netip.MustParseAddr("192.168.10.10"),
+					EndRange:   netip.MustParseAddr("192.168.11.10"),
+					SubnetMask: netip.MustParseAddr("255.255.255.0"),
+				})
+				return err
+			},
+			winerror.ErrInvalidParameter,
+		},
+		{
+			"assert update of missing scope",
+			func() error {
+				_, err := suite.client.ScopeV4Update(ctx, dhcp.ScopeV4UpdateParams{ScopeId: netip.MustParseAddr("10.0.0.0")})
+				return err
+			},
+			winerror.ErrNotFound,
+		},
+		{
+			"assert delete of missing scope",
+			func() error {
+				return suite.client.ScopeV4Delete(ctx, dhcp.ScopeV4DeleteParams{ScopeId: netip.MustParseAddr("10.0.0.0")})
+			},
+			winerror.ErrNotFound,
+		},
+	}
+
+	for _, tc := range tcs {
+		suite.T().Logf("test case: %s", tc.description)
+		suite.ErrorIs(tc.run(), tc.expectedError)
+	}
+}
@@ -0,0 +1,420 @@
+package fake
+
+import (
+	"fmt"
+	"net/netip"
+	"strings"
+	"time"
+)
+
+// dnsRecordType describes a supported type of DNS resource records.
+type dnsRecordType struct {
+	name string
+	id   int
+
+	// dataKey is the property of the record data, e.g. IPv4Address.
+	dataKey string
+}
+
+// dnsRecordTypes are the supported types of DNS resource records by their lower-case names.
+var dnsRecordTypes = map[string]dnsRecordType{
+	"a":     {name: "A", id: 1, dataKey: "IPv4Address"},
+	"aaaa":  {name: "AAAA", id: 28, dataKey: "IPv6Address"},
+	"cname": {name: "CNAME", id: 5, dataKey: "HostNameAlias"},
+	"ptr":   {name: "PTR", id: 12, dataKey: "PtrDomainName"},
+}
+
+// dnsZone is a primary DNS zone.
+type dnsZone struct {
+	name string
+}
+
+// distinguishedName returns the distinguished name of the zone in the domain partition, e.g.
+// DC=test.local,cn=MicrosoftDNS,DC=DomainDnsZones,DC=test,DC=local.
+func (z *dnsZone) distinguishedName() string {
+	dn := []string{"DC=" + z.name, "cn=MicrosoftDNS", "DC=DomainDnsZones"}
+	for _, label := range strings.Split(z.name, ".") {
+		dn = append(dn, "DC="+label)
+	}
+	return strings.Join(dn, ",")
+}
+
+// json returns the zone as it is rendered by Get-DnsServerZone | ConvertTo-Json.
+func (z *dnsZone) json() any {
+	return struct {
+		NotifyServers                     *string
+		SecondaryServers                  *string
+		AllowedDcForNsRecordsAutoCreation *string
+		DistinguishedName                 string
+		IsAutoCreated                     bool
+		IsDsIntegrated                    bool
+		IsPaused                          bool
+		IsReadOnly                        bool
+		IsReverseLookupZone               bool
+		IsShutdown                        bool
+		ZoneName                          string
+		ZoneType                          string
+		DirectoryPartitionName            string
+		DynamicUpdate                     string
+		IgnorePolicies                    bool
+		IsSigned                          bool
+		IsWinsEnabled                     bool
+		Notify                            string
+		ReplicationScope                  string
+		SecureSecondaries                 string
+		ZoneFile                          *string
+		PSComputerName                    *string
+	}{
+		DistinguishedName:      z.distinguishedName(),
+		IsDsIntegrated:         true,
+		IsReverseLookupZone:    strings.HasSuffix(strings.ToLower(z.name), ".arpa"),
+		ZoneName:               z.name,
+		ZoneType:               "Primary",
+		DirectoryPartitionName: "DomainDnsZones." + z.name,
+		DynamicUpdate:          "Secure",
+		Notify:                 "NotifyServers",
+		ReplicationScope:       "Domain",
+		SecureSecondaries:      "NoTransfer",
+	}
+}
+
+// dnsRecord is a DNS resource record of a zone.
+type dnsRecord struct {
+	zone       *dnsZone
+	name       string
+	recordType dnsRecordType
+	data       string
+	ttl        time.Duration
+}
+
+// equal reports whether both records are the same record of the zone.
+func (r *dnsRecord) equal(other *dnsRecord) bool {
+	return r.zone == other.zone &&
+		strings.EqualFold(r.name, other.name) &&
+		r.recordType == other.recordType &&
+		strings.EqualFold(r.data, other.data)
+}
+
+// clone implements the cloner interface for [ciminstance]::new.
+func (r *dnsRecord) clone() any {
+	c := *r
+	return &c
+}
+
+// setProperty implements the propertySetter interface.
+// The TimeToLive and the property of the record data can be set.
+func (r *dnsRecord) setProperty(path []string, value any) error {
+	switch {
+	case len(path) == 1 && strings.EqualFold(path[0], "TimeToLive"):
+		ttl, ok := value.(time.Duration)
+		if !ok {
+			return &errorRecord{
+				message:  fmt.Sprintf(`Exception setting "TimeToLive": "Cannot convert value "%v" to type "System.TimeSpan"."`, value),
+				category: "NotSpecified",
+				reason:   "SetValueInvocationException",
+				errorId:  "ExceptionWhenSetting",
+			}
+		}
+		r.ttl = ttl
+		return nil
+
+	case len(path) == 2 && strings.EqualFold(path[0], "RecordData") && strings.EqualFold(path[1], r.recordType.dataKey):
+		r.data = fqdn(fmt.Sprint(value))
+		return nil
+	}
+
+	return propertyNotFound(path[len(path)-1])
+}
+
+// json returns the record as it is rendered by Get-DnsServerResourceRecord | ConvertTo-Json.
+func (r *dnsRecord) json() any {
+	return struct {
+		DistinguishedName string
+		HostName          string
+		RecordClass       string
+		RecordData        any
+		RecordType        string
+		Timestamp         dotnetDate
+		TimeToLive        timeSpan
+		Type              int
+		PSComputerName    *string
+	}{
+		DistinguishedName: fmt.Sprintf("DC=%s,%s", r.name, r.zone.distinguishedName()),
+		HostName:          r.name,
+		RecordClass:       "IN",
+		RecordData: struct {
+			CimClass              string
+			CimInstanceProperties string
+			CimSystemProperties   string
+		}{
+			CimClass:              "root/Microsoft/Windows/DNS:DnsServerResourceRecord" + r.recordType.name,
+			CimInstanceProperties: fmt.Sprintf("%s = %q", r.recordType.dataKey, r.data),
+			CimSystemProperties:   "Microsoft.Management.Infrastructure.CimSystemProperties",
+		},
+		RecordType: r.recordType.name,
+		TimeToLive: timeSpan(r.ttl),
+		Type:       r.recordType.id,
+	}
+}
+
+// fqdn returns the fully qualified name of a host name alias or a pointer domain name.
+// The DNS server stores these names with a trailing dot.
+func fqdn(name string) string {
+	if strings.HasSuffix(name, ".") {
+		return name
+	}
+	return name + "."
+}
+
+// zone returns the zone with the name or nil.
+func (h *Host) zone(name string) *dnsZone {
+	for _, z := range h.zones {
+		if strings.EqualFold(z.name, name) {
+			return z
+		}
+	}
+	return nil
+}
+
+// nodeRecords returns the records of a node of the zone with the type or with any type if recordType is nil.
+func (h *Host) nodeRecords(zone *dnsZone, name string, recordType *dnsRecordType) []*dnsRecord {
+	var records []*dnsRecord
+	for _, r := range h.records {
+		if r.zone == zone && (name == "" || strings.EqualFold(r.name, name)) && (recordType == nil || r.recordType == *recordType) {
+			records = append(records, r)
+		}
+	}
+	return records
+}
+
+// dnsCmdlets are the cmdlets of the DnsServer module.
+var dnsCmdlets = []*cmdlet{
+	{
+		name:   "Get-DnsServerZone",
+		source: "Get-DnsServerZone",
+		params: []string{"Name"},
+		run: func(h *Host, inv *invocation) ([]any, error) {
+			if !inv.has("Name") {
+				zones := make([]any, len(h.zones))
+				for i, z := range h.zones {
+					zones[i] = z
+				}
+				return zones, nil
+			}
+
+			name := inv.string("Name")
+			z := h.zone(name)
+			if z == nil {
+				return nil, inv.fail("ObjectNotFound", "WIN32 9601", "CimException", name, "root/Microsoft/...S:DnsServerZone",
+					"The zone %s was not found on server %s.", name, ComputerName)
+			}
+			return []any{z}, nil
+		},
+	},
+	{
+		name:   "Get-DnsServerResourceRecord",
+		source: "Get-DnsServerResourceRecord",
+		params: []string{"Name", "ZoneName", "RRType", "Node"},
+		run: func(h *Host, inv *invocation) ([]any, error) {
+			z, err := dnsZoneParam(h, inv)
+			if err != nil {
+				return nil, err
+			}
+			recordType, err := dnsRecordTypeParam(inv)
+			if err != nil {
+				return nil, err
+			}
+
+			name := inv.string("Name")
+			records := h.nodeRecords(z, name, recordType)
+			if len(records) == 0 && name != "" {
+				return nil, inv.fail("ObjectNotFound", "WIN32 9714", "CimException", name, "root/Microsoft/...rverResourceRecord",
+					"Failed to get %s record in %s zone on %s server.", name, z.name, ComputerName)
+			}
+
+			output := make([]any, len(records))
+			for i, r := range records {
+				output[i] = r.clone()
+			}
+			return output, nil
+		},
+	},
+	addDnsRecordCmdlet("A", "IPv4Address", "CreatePtr"),
+	addDnsRecordCmdlet("AAAA", "IPv6Address", "CreatePtr"),
+	addDnsRecordCmdlet("CName", "HostNameAlias"),
+	addDnsRecordCmdlet("PTR", "PtrDomainName"),
+	{
+		name:   "Set-DnsServerResourceRecord",
+		source: "Set-DnsServerResourceRecord",
+		params: []string{"OldInputObject", "NewInputObject", "ZoneName", "PassThru", "Confirm"},
+		run: func(h *Host, inv *invocation) ([]any, error) {
+			z, err := dnsZoneParam(h, inv)
+			if err != nil {
+				return nil, err
+			}
+
+			records := map[string]*dnsRecord{}
+			for _, name := range []string{"OldInputObject", "NewInputObject"} {
+				r, ok := inv.value(name).(*dnsRecord)
+				if !ok {
+					return nil, inv.validationError(name, "The argument is null. Provide a valid value for the argument, and then try running the command again.")
+				}
+				records[name] = r
+			}
+			oldRecord, newRecord := records["OldInputObject"], records["NewInputObject"]
+
+			if !strings.EqualFold(oldRecord.name, newRecord.name) || oldRecord.recordType != newRecord.recordType {
+				return nil, inv.fail("InvalidArgument", "WIN32 87", "CimException", oldRecord.name, "root/Microsoft/...rverResourceRecord",
+					"Resource record in OldInputObject not found in %s zone on %s server. The name and the type of a resource record cannot be changed.", z.name, ComputerName)
+			}
+
+			for _, r := range h.records {
+				if r.equal(&dnsRecord{zone: z, name: oldRecord.name, recordType: oldRecord.recordType, data: oldRecord.data}) {
+					r.data = newRecord.data
+					r.ttl = newRecord.ttl
+					if inv.bool("PassThru") {
+						return []any{r.clone()}, nil
+					}
+					return nil, nil
+				}
+			}
+
+			return nil, inv.fail("ObjectNotFound", "WIN32 9714", "CimException", oldRecord.name, "root/Microsoft/...rverResourceRecord",
+				"Resource record in OldInputObject not found in %s zone on %s server.", z.name, ComputerName)
+		},
+	},
+	{
+		name:   "Remove-DnsServerResourceRecord",
+		source: "Remove-DnsServerResourceRecord",
+		params: []string{"Name", "ZoneName", "RRType", "RecordData", "Force", "Confirm"},
+		run: func(h *Host, inv *invocation) ([]any, error) {
+			z, err := dnsZoneParam(h, inv)
+			if err != nil {
+				return nil, err
+			}
+			recordType, err := dnsRecordTypeParam(inv)
+			if err != nil {
+				return nil, err
+			}
+
+			name := inv.string("Name")
+			removed := false
+			records := h.records[:0]
+			for _, r := range h.records {
+				if r.zone == z && strings.EqualFold(r.name, name) && (recordType == nil || r.recordType == *recordType) &&
+					(!inv.has("RecordData") || strings.EqualFold(r.data, inv.string("RecordData"))) {
+					removed = true
+					continue
+				}
+				records = append(records, r)
+			}
+			h.records = records
+
+			if !removed {
+				return nil, inv.fail("ObjectNotFound", "WIN32 9714", "CimException", name, "root/Microsoft/...rverResourceRecord",
+					"Failed to get %s record in %s zone on %s server.", name, z.name, ComputerName)
+			}
+			return nil, nil
+		},
+	},
+}
+
+// addDnsRecordCmdlet returns the Add-DnsServerResourceRecord cmdlet of a record type.
+// The record data is passed with dataParam, an array of addresses adds a record per address.
+func addDnsRecordCmdlet(typeName string, dataParam string, params ...string) *cmdlet {
+	name := "Add-DnsServerResourceRecord" + typeName
+	recordType := dnsRecordTypes[strings.ToLower(typeName)]
+
+	return &cmdlet{
+		name:   name,
+		source: name,
+		params: append([]string{"Name", "ZoneName", "TimeToLive", "AllowUpdateAny", "AgeRecord", "PassThru", "Confirm", dataParam}, params...),
+		run: func(h *Host, inv *invocation) ([]any, error) {
+			z, err := dnsZoneParam(h, inv)
+			if err != nil {
+				return nil, err
+			}
+
+			name := inv.string("Name")
+			if name == "" {
+				return nil, inv.validationError("Name", "The argument is null or empty. Provide an argument that is not null or empty, and then try the command again.")
+			}
+			ttl, err := inv.duration("TimeToLive")
+			if err != nil {
+				return nil, err
+			}
+			if ttl == 0 {
+				ttl = time.Hour
+			}
+
+			data := inv.strings(dataParam)
+			if len(data) == 0 {
+				return nil, inv.validationError(dataParam, "The argument is null or empty. Provide an argument that is not null or empty, and then try the command again.")
+			}
+
+			var added []*dnsRecord
+			for _, d := range data {
+				switch recordType.name {
+				case "A", "AAAA":
+					addr, err := netip.ParseAddr(d)
+					if err != nil || addr.Is4() != (recordType.name == "A") {
+						return nil, inv.fail("InvalidData", "ParameterArgumentTransformationError", "ParameterBindingArgumentTransformationException", "", "",
+							"Cannot process argument transformation on parameter '%s'. Cannot convert value \"%s\" to type \"System.Net.IPAddress\". Error: \"An invalid IP address was specified.\"", dataParam, d)
+					}
+					d = addr.String()
+				default:
+					d = fqdn(d)
+				}
+
+				record := &dnsRecord{zone: z, name: name, recordType: recordType, data: d, ttl: ttl}
+				for _, existing := range h.nodeRecords(z, name, nil) {
+					// A CNAME record must be the only record of a node.
+					if existing.recordType.name == "CNAME" || recordType.name == "CNAME" {
+						return nil, inv.fail("ResourceExists", "WIN32 9709", "CimException", name, "root/Microsoft/...ResourceRecord"+typeName,
+							"Failed to create resource record %s in %s zone on %s server.", name, z.name, ComputerName)
+					}
+					if existing.equal(record) {
+						return nil, inv.fail("ResourceExists", "WIN32 9711", "CimException", name, "root/Microsoft/...ResourceRecord"+typeName,
+							"Failed to create resource record %s in %s zone on %s server.", name, z.name, ComputerName)
+					}
+				}
+
+				h.records = append(h.records, record)
+				added = append(added, record)
+			}
+
+			if !inv.bool("PassThru") {
+				return nil, nil
+			}
+			output := make([]any, len(added))
+			for i, r := range added {
+				output[i] = r.clone()
+			}
+			return output, nil
+		},
+	}
+}
+
+// dnsZoneParam returns the zone of the ZoneName parameter.
+func dnsZoneParam(h *Host, inv *invocation) (*dnsZone, error) {
+	name := inv.string("ZoneName")
+	z := h.zone(name)
+	if z == nil {
+		return nil, inv.fail("ObjectNotFound", "WIN32 9601", "CimException", name, "root/Microsoft/...S:DnsServerZone",
+			"Failed to get the zone information for %s on server %s.", name, ComputerName)
+	}
+	return z, nil
+}
+
+// dnsRecordTypeParam returns the record type of the RRType parameter or nil if it is not bound.
+func dnsRecordTypeParam(inv *invocation) (*dnsRecordType, error) {
+	if !inv.has("RRType") {
+		return nil, nil
+	}
+
+	recordType, ok := dnsRecordTypes[strings.ToLower(inv.string("RRType"))]
+	if !ok {
+		return nil, inv.validationError("RRType", "The argument \"%s\" does not belong to the set \"A,AAAA,CName,PTR\" supported by the fake host.", inv.string("RRType"))
+	}
+	return &recordType, nil
+}
@@ -0,0 +1,141 @@
+package fake_test
+
+import (
+	"context"
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/d-strobel/gowindows/connection/fake"
+	"github.com/d-strobel/gowindows/windows/dns"
+	"github.com/d-strobel/gowindows/winerror"
+	"github.com/stretchr/testify/suite"
+)
+
+// Unit test suite for the DNS cmdlets of the fake host.
+type DnsUnitTestSuite struct {
+	suite.Suite
+	client *dns.Client
+}
+
+func TestDnsUnitTestSuite(t *testing.T) {
+	suite.Run(t, &DnsUnitTestSuite{})
+}
+
+func (suite *DnsUnitTestSuite) SetupTest() {
+	host := fake.NewHost()
+	host.AddZone("example.local")
+	host.AddZone("10.in-addr.arpa")
+	suite.client = dns.NewClient(host)
+}
+
+func (suite *DnsUnitTestSuite) TestZone() {
+	ctx := context.Background()
+
+	z, err := suite.client.ZoneRead(ctx, dns.ZoneReadParams{Name: "example.local"})
+	suite.Require().NoError(err)
+	suite.Equal("example.local", z.ZoneName)
+	suite.Equal("Primary", z.ZoneType)
+	suite.False(z.IsReverseLookupZone)
+
+	zones, err := suite.client.ZoneList(ctx)
+	suite.Require().NoError(err)
+	suite.Len(zones, 2)
+	suite.True(zones[1].IsReverseLookupZone)
+
+	_, err = suite.client.ZoneRead(ctx, dns.ZoneReadParams{Name: "missing.local"})
+	suite.ErrorIs(err, winerror.ErrNotFound)
+}
+
+func (suite *DnsUnitTestSuite) TestRecordA() {
+	ctx := context.Background()
+	addresses := []netip.Addr{netip.MustParseAddr("10.0.0.1"), netip.MustParseAddr("10.0.0.2")}
+
+	r, err := suite.client.RecordACreate(ctx, dns.RecordACreateParams{Name: "web", Zone: "example.local", Addresses: addresses})
+	suite.Require().NoError(err)
+	suite.Equal("web", r.Name)
+	suite.Equal(addresses, r.Addresses)
+	suite.Equal(24*time.Hour, r.TimeToLive)
+
+	_, err = suite.client.RecordACreate(ctx, dns.RecordACreateParams{Name: "web", Zone: "example.local", Addresses: addresses[:1]})
+	suite.ErrorIs(err, winerror.ErrAlreadyExists)
+
+	r, err = suite.client.RecordAUpdate(ctx, dns.RecordAUpdateParams{Name: "web", Zone: "example.local", TimeToLive: time.Hour})
+	suite.Require().NoError(err)
+	suite.Equal(addresses, r.Addresses)
+	suite.Equal(time.Hour, r.TimeToLive)
+
+	r, err = suite.client.RecordARead(ctx, dns.RecordAReadParams{Name: "web", Zone: "example.local"})
+	suite.Require().NoError(err)
+	suite.Equal(time.Hour, r.TimeToLive)
+
+	suite.Require().NoError(suite.client.RecordADelete(ctx, dns.RecordADeleteParams{Name: "web", Zone: "example.local"}))
+
+	_, err = suite.client.RecordARead(ctx, dns.RecordAReadParams{Name: "web", Zone: "example.local"})
+	suite.ErrorIs(err, winerror.ErrNotFound)
+}
+
+func (suite *DnsUnitTestSuite) TestRecordAAAA() {
+	ctx := context.Background()
+	addresses := []netip.Addr{netip.MustParseAddr("fd00::1")}
+
+	r, err := suite.client.RecordAAAACreate(ctx, dns.RecordAAAACreateParams{Name: "web", Zone: "example.local", Addresses: addresses, TimeToLive: time.Minute})
+	suite.Require().NoError(err)
+	suite.Equal(addresses, r.Addresses)
+	suite.Equal(time.Minute, r.TimeToLive)
+
+	r, err = suite.client.RecordAAAARead(ctx, dns.RecordAAAAReadParams{Name: "web", Zone: "example.local"})
+	suite.Require().NoError(err)
+	suite.Equal(addresses, r.Addresses)
+
+	suite.Require().NoError(suite.client.RecordAAAADelete(ctx, dns.RecordAAAADeleteParams{Name: "web", Zone: "example.local"}))
+}
+
+func (suite *DnsUnitTestSuite) TestRecordCName() {
+	ctx := context.Background()
+
+	r, err := suite.client.RecordCNameCreate(ctx, dns.RecordCNameCreateParams{Name: "www", Zone: "example.local", CName: "web.example.local"})
+	suite.Require().NoError(err)
+	suite.Equal("web.example.local.", r.CName)
+
+	r, err = suite.client.RecordCNameUpdate(ctx, dns.RecordCNameUpdateParams{Name: "www", Zone: "example.local", CName: "app.example.local", TimeToLive: time.Hour})
+	suite.Require().NoError(err)
+	suite.Equal("app.example.local.", r.CName)
+	suite.Equal(time.Hour, r.TimeToLive)
+
+	r, err = suite.client.RecordCNameRead(ctx, dns.RecordCNameReadParams{Name: "www", Zone: "example.local"})
+	suite.Require().NoError(err)
+	suite.Equal("app.example.local.", r.CName)
+
+	suite.Require().NoError(suite.client.RecordCNameDelete(ctx, dns.RecordCNameDeleteParams{Name: "www", Zone: "example.local"}))
+
+	_, err = suite.client.RecordCNameRead(ctx, dns.RecordCNameReadParams{Name: "www", Zone: "example.local"})
+	suite.ErrorIs(err, winerror.ErrNotFound)
+}
+
+func (suite *DnsUnitTestSuite) TestRecordPTR() {
+	ctx := context.Background()
+
+	r, err := suite.client.RecordPTRCreate(ctx, dns.RecordPTRCreateParams{Name: "1.0.0", Zone: "10.in-addr.arpa", PTR: "web.example.local"})
+	suite.Require().NoError(err)
+	suite.Equal("web.example.local.", r.PTR)
+
+	r, err = suite.client.RecordPTRUpdate(ctx, dns.RecordPTRUpdateParams{Name: "1.0.0", Zone: "10.in-addr.arpa", PTR: "app.example.local"})
+	suite.Require().NoError(err)
+	suite.Equal("app.example.local.", r.PTR)
+
+	suite.Require().NoError(suite.client.RecordPTRDelete(ctx, dns.RecordPTRDeleteParams{Name: "1.0.0", Zone: "10.in-addr.arpa"}))
+}
+
+func (suite *DnsUnitTestSuite) TestRecordErrors() {
+	ctx := context.Background()
+
+	_, err := suite.client.RecordACreate(ctx, dns.RecordACreateParams{Name: "web", Zone: "missing.local", Addresses: []netip.Addr{netip.MustParseAddr("10.0.0.1")}})
+	suite.ErrorIs(err, winerror.ErrNotFound)
+
+	err = suite.client.RecordADelete(ctx, dns.RecordADeleteParams{Name: "web", Zone: "example.local"})
+	suite.ErrorIs(err, winerror.ErrNotFound)
+
+	_, err = suite.client.RecordCNameUpdate(ctx, dns.RecordCNameUpdateParams{Name: "www", Zone: "example.local", CName: "web.example.local"})
+	suite.ErrorIs(err, winerror.ErrNotFound)
+}
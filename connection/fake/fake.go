@@ -0,0 +1,301 @@
+// Package fake provides an in-memory Windows host that implements connection.Connection for tests.
+//
+// The host parses the PowerShell commands emitted by the dns, dhcp and accounts packages,
+// keeps DNS zones and records, DHCP scopes, local users, groups and group memberships in memory
+// and answers with the JSON output and the CLIXML error records of a real Windows host.
+// It supports the subset of PowerShell that is rendered by the parsing.PwshCommand builder.
+// Unknown cmdlets and unsupported parameters fail like unknown commands and parameters fail on Windows.
+// Unlike on Windows, the first error stops the command, as if $ErrorActionPreference was 'Stop'.
+package fake
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/d-strobel/gowindows/connection"
+	"golang.org/x/text/encoding/unicode"
+)
+
+// ComputerName is the name of the fake host.
+// It prefixes the names of the local group members, e.g. WINSRV\Administrator.
+const ComputerName = "WINSRV"
+
+// machineSid is the SID of the fake host. The SIDs of the local users and groups start with it.
+const machineSid = "S-1-5-21-153895498-367353507-3704405138"
+
+// encodedCommandPrefix is the prefix of a command encoded by parsing.EncodePwshCmd.
+const encodedCommandPrefix = "powershell.exe -NoProfile -EncodedCommand "
+
+// Host is an in-memory Windows host.
+// It is safe for concurrent use, commands run one after another.
+type Host struct {
+	mu      sync.Mutex
+	zones   []*dnsZone
+	records []*dnsRecord
+	scopes  []*dhcpScope
+	users   []*localUser
+	groups  []*localGroup
+	nextRid int
+}
+
+// NewHost returns a new Host with the built-in local users and groups of Windows and without DNS zones and DHCP scopes.
+func NewHost() *Host {
+	h := &Host{nextRid: 1000}
+	h.seedAccounts()
+	return h
+}
+
+// AddZone adds a primary DNS zone to the host, e.g. "example.local" or "10.in-addr.arpa".
+// Adding an existing zone has no effect.
+func (h *Host) AddZone(name string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.zone(name) == nil {
+		h.zones = append(h.zones, &dnsZone{name: name})
+	}
+}
+
+// Run runs a PowerShell command on the host.
+// Commands encoded by parsing.EncodePwshCmd are decoded first.
+func (h *Host) Run(ctx context.Context, cmd string) (connection.CmdResult, error) {
+	return connection.RunWithInput(ctx, h, cmd, nil)
+}
+
+// RunWithPowershell runs a PowerShell command on the host.
+func (h *Host) RunWithPowershell(ctx context.Context, cmd string) (connection.CmdResult, error) {
+	return connection.RunWithPowershellInput(ctx, h, cmd, nil)
+}
+
+// Stream runs a PowerShell command on the host like Run and writes its output to the streams.
+// The lines of Stdin are available as $input.
+func (h *Host) Stream(ctx context.Context, cmd string, streams connection.Streams) (int, error) {
+	if encoded, ok := strings.CutPrefix(cmd, encodedCommandPrefix); ok {
+		script, err := decodeCommand(encoded)
+		if err != nil {
+			return 0, fmt.Errorf("fake: failed to decode the command: %w", err)
+		}
+		cmd = script
+	}
+
+	return h.StreamWithPowershell(ctx, cmd, streams)
+}
+
+// StreamWithPowershell runs a PowerShell command on the host and writes its output to the streams.
+// The lines of Stdin are available as $input.
+func (h *Host) StreamWithPowershell(ctx context.Context, cmd string, streams connection.Streams) (int, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	var input []any
+	if streams.Stdin != nil {
+		b, err := io.ReadAll(streams.Stdin)
+		if err != nil {
+			return 0, fmt.Errorf("fake: failed to read the input: %w", err)
+		}
+		for _, line := range strings.Split(strings.ReplaceAll(strings.TrimRight(string(b), "\r\n"), "\r\n", "\n"), "\n") {
+			input = append(input, line)
+		}
+	}
+
+	stdout, stderr := streams.Stdout, streams.Stderr
+	if stdout == nil {
+		stdout = io.Discard
+	}
+	if stderr == nil {
+		stderr = io.Discard
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	return h.execute(cmd, input, stdout, stderr)
+}
+
+// Close has no effect, the state of the host is kept.
+func (h *Host) Close() error {
+	return nil
+}
+
+// execute runs a script and writes the output of every statement to stdout.
+// The first error is written to stderr as CLIXML error record and returns the exit code 1.
+func (h *Host) execute(script string, input []any, stdout io.Writer, stderr io.Writer) (int, error) {
+	statements, err := parse(script)
+
+	r := &runner{host: h, script: script, vars: map[string]any{"input": input}}
+	for i := 0; err == nil && i < len(statements); i++ {
+		var output []any
+		output, err = r.statement(statements[i])
+		for _, v := range output {
+			text, formatErr := format(v)
+			if formatErr != nil {
+				return 0, fmt.Errorf("fake: failed to format the output: %w", formatErr)
+			}
+			if _, writeErr := fmt.Fprintf(stdout, "%s\r\n", text); writeErr != nil {
+				return 0, writeErr
+			}
+		}
+	}
+
+	switch err := err.(type) {
+	case nil:
+		return 0, nil
+
+	case *exitError:
+		return err.code, nil
+
+	case *errorRecord:
+		w := connection.NewCliXmlErrorWriter(stderr)
+		if writeErr := w.WriteErrorRecord(err.text(script)); writeErr != nil {
+			return 0, writeErr
+		}
+		if closeErr := w.Close(); closeErr != nil {
+			return 0, closeErr
+		}
+		return 1, nil
+	}
+
+	return 0, err
+}
+
+// format renders an output object of a script.
+// The host has no formatting system, so objects are rendered as JSON.
+func format(v any) (string, error) {
+	switch v := v.(type) {
+	case string:
+		return v, nil
+	case jsonObject:
+		return toJson(v)
+	}
+	return fmt.Sprint(v), nil
+}
+
+// decodeCommand decodes the base64 encoded UTF-16LE script of an encoded command.
+func decodeCommand(encoded string) (string, error) {
+	b, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return "", err
+	}
+	return unicode.UTF16(unicode.LittleEndian, unicode.IgnoreBOM).NewDecoder().String(string(b))
+}
+
+// now returns the current time of the host with the precision of a DateTime in JSON.
+func now() time.Time {
+	return time.Now().UTC().Truncate(time.Millisecond)
+}
+
+// utilityCmdlets are the cmdlets that create the values of parameters and render the output.
+var utilityCmdlets = []*cmdlet{
+	{
+		name:       "ConvertTo-Json",
+		source:     "Microsoft.PowerShell.Commands.ConvertToJsonCommand",
+		params:     []string{"InputObject", "Compress", "Depth"},
+		positional: []string{"InputObject"},
+		run: func(h *Host, inv *invocation) ([]any, error) {
+			value := collect(inv.input)
+			if inv.has("InputObject") {
+				value = inv.value("InputObject")
+			} else if inv.input == nil {
+				return nil, nil
+			}
+
+			text, err := toJson(value)
+			if err != nil {
+				return nil, err
+			}
+			return []any{text}, nil
+		},
+	},
+	{
+		name:   "New-TimeSpan",
+		source: "Microsoft.PowerShell.Commands.NewTimeSpanCommand",
+		params: []string{"Days", "Hours", "Minutes", "Seconds"},
+		run: func(h *Host, inv *invocation) ([]any, error) {
+			var d time.Duration
+			for unit, name := range map[time.Duration]string{24 * time.Hour: "Days", time.Hour: "Hours", time.Minute: "Minutes", time.Second: "Seconds"} {
+				n, err := inv.int(name)
+				if err != nil {
+					return nil, err
+				}
+				d += time.Duration(n) * unit
+			}
+			return []any{d}, nil
+		},
+	},
+	{
+		name:       "Get-Date",
+		source:     "Microsoft.PowerShell.Commands.GetDateCommand",
+		params:     []string{"Date"},
+		positional: []string{"Date"},
+		run: func(h *Host, inv *invocation) ([]any, error) {
+			if !inv.has("Date") {
+				return []any{now()}, nil
+			}
+
+			t, err := time.Parse(time.DateTime, inv.string("Date"))
+			if err != nil {
+				return nil, inv.transformationError("Date", "System.DateTime")
+			}
+			return []any{t}, nil
+		},
+	},
+	{
+		name:   "Select-Object",
+		source: "Microsoft.PowerShell.Commands.SelectObjectCommand",
+		params: []string{"First"},
+		run: func(h *Host, inv *invocation) ([]any, error) {
+			if !inv.has("First") {
+				return inv.input, nil
+			}
+
+			n, err := inv.int("First")
+			if err != nil {
+				return nil, err
+			}
+			return inv.input[:min(int(n), len(inv.input))], nil
+		},
+	},
+	{
+		name:       "ConvertTo-SecureString",
+		source:     "Microsoft.PowerShell.Commands.ConvertToSecureStringCommand",
+		params:     []string{"String", "AsPlainText", "Force"},
+		positional: []string{"String"},
+		run: func(h *Host, inv *invocation) ([]any, error) {
+			if !inv.bool("AsPlainText") || !inv.bool("Force") {
+				return nil, inv.fail("InvalidArgument", "ImportSecureString_InvalidArgument", "ArgumentException", "", "",
+					"The system cannot protect plain text input. To suppress this warning and convert the plain text to a SecureString, reissue the command specifying the Force parameter.")
+			}
+
+			// The string is bound from the pipeline, e.g. $input | Select-Object -First 1 | ConvertTo-SecureString.
+			value := inv.value("String")
+			if !inv.has("String") && len(inv.input) > 0 {
+				value = inv.input[0]
+			}
+			if value == nil {
+				return nil, inv.fail("InvalidData", "ParameterArgumentValidationErrorNullNotAllowed", "ParameterBindingValidationException", "", "",
+					"Cannot bind argument to parameter 'String' because it is null.")
+			}
+			return []any{secureString(fmt.Sprint(value))}, nil
+		},
+	},
+}
+
+// cmdlets are the cmdlets supported by the fake host by their lower-case names.
+var cmdlets = cmdletTable(utilityCmdlets, dnsCmdlets, dhcpCmdlets, accountsCmdlets)
+
+// cmdletTable returns the cmdlets by their lower-case names.
+func cmdletTable(groups ...[]*cmdlet) map[string]*cmdlet {
+	table := map[string]*cmdlet{}
+	for _, group := range groups {
+		for _, c := range group {
+			table[strings.ToLower(c.name)] = c
+		}
+	}
+	return table
+}
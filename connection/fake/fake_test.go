@@ -0,0 +1,174 @@
+package fake_test
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/d-strobel/gowindows/connection"
+	"github.com/d-strobel/gowindows/connection/fake"
+	"github.com/d-strobel/gowindows/parsing"
+	"github.com/stretchr/testify/suite"
+)
+
+// Unit test suite for the fake host.
+type FakeUnitTestSuite struct {
+	suite.Suite
+}
+
+func TestFakeUnitTestSuite(t *testing.T) {
+	suite.Run(t, &FakeUnitTestSuite{})
+}
+
+func (suite *FakeUnitTestSuite) TestImplementsConnection() {
+	suite.Implements((*connection.Connection)(nil), fake.NewHost())
+}
+
+func (suite *FakeUnitTestSuite) TestRun() {
+	tcs := []struct {
+		description    string
+		cmd            string
+		expectedStdOut string
+		expectedCode   int
+	}{
+		{
+			"assert group as json",
+			"Get-LocalGroup -Name 'Users' | ConvertTo-Json -Compress",
+			`{"Description":"Users are prevented from making accidental or intentional system-wide changes and can run most applications","Name":"Users","SID":{"BinaryLength":16,"AccountDomainSid":null,"Value":"S-1-5-32-545"},"PrincipalSource":1,"ObjectClass":"Group"}` + "\r\n",
+			0,
+		},
+		{
+			"assert single member as json array",
+			"$gm=Get-LocalGroupMember -Name 'Administrators' ;if($gm.Count -eq 1){ConvertTo-Json @($gm) -Compress}else{ConvertTo-Json $gm -Compress}",
+			`[{"Name":"WINSRV\\Administrator","SID":{"BinaryLength":28,"AccountDomainSid":"S-1-5-21-153895498-367353507-3704405138","Value":"S-1-5-21-153895498-367353507-3704405138-500"},"PrincipalSource":1,"ObjectClass":"User"}]` + "\r\n",
+			0,
+		},
+		{
+			"assert empty group as null",
+			"$gm=Get-LocalGroupMember -Name 'Users' ;if($gm.Count -eq 1){ConvertTo-Json @($gm) -Compress}else{ConvertTo-Json $gm -Compress}",
+			"null\r\n",
+			0,
+		},
+		{
+			"assert exit code",
+			"$g=Get-LocalGroup -Name 'Users' ;exit 3",
+			"",
+			3,
+		},
+	}
+
+	for _, tc := range tcs {
+		suite.T().Logf("test case: %s", tc.description)
+
+		result, err := fake.NewHost().RunWithPowershell(context.Background(), tc.cmd)
+		suite.Require().NoError(err)
+		suite.Equal(tc.expectedStdOut, result.StdOut)
+		suite.Equal(tc.expectedCode, result.ExitCode)
+		suite.Empty(result.StdErr)
+	}
+}
+
+func (suite *FakeUnitTestSuite) TestRunErrors() {
+	tcs := []struct {
+		description     string
+		cmd             string
+		expectedErrorId string
+		expectedMessage string
+		expectedLine    int
+		expectedColumn  int
+	}{
+		{
+			"assert cmdlet error",
+			"Get-LocalUser -Name 'Test-User' | ConvertTo-Json -Compress",
+			"UserNotFound,Microsoft.PowerShell.Commands.GetLocalUserCommand",
+			"User Test-User was not found.",
+			1,
+			1,
+		},
+		{
+			"assert unknown cmdlet",
+			"Get-LocalGroup -Name 'Users' ;Get-Unknown -Name 'Test'",
+			"CommandNotFoundException",
+			"The term 'Get-Unknown' is not recognized as the name of a cmdlet, function, script file, or operable program. Check the spelling of the name, or if a path was included, verify that the path is correct and try again.",
+			1,
+			31,
+		},
+		{
+			"assert unknown parameter",
+			"Get-LocalUser -Unknown 'Test'",
+			"NamedParameterNotFound,Microsoft.PowerShell.Commands.GetLocalUserCommand",
+			"A parameter cannot be found that matches parameter name 'Unknown'.",
+			1,
+			1,
+		},
+	}
+
+	for _, tc := range tcs {
+		suite.T().Logf("test case: %s", tc.description)
+
+		result, err := fake.NewHost().RunWithPowershell(context.Background(), tc.cmd)
+		suite.Require().NoError(err)
+		suite.Equal(1, result.ExitCode)
+
+		records, err := parsing.DecodeCliXmlErrRecords(result.StdErr)
+		suite.Require().NoError(err)
+		suite.Require().Len(records, 1)
+		suite.Equal(tc.expectedErrorId, records[0].FullyQualifiedErrorId)
+		suite.Equal(tc.expectedMessage, records[0].Exception.Message)
+		suite.Equal(tc.expectedLine, records[0].ScriptPosition.Line)
+		suite.Equal(tc.expectedColumn, records[0].ScriptPosition.Column)
+	}
+}
+
+func (suite *FakeUnitTestSuite) TestRunParseError() {
+	result, err := fake.NewHost().RunWithPowershell(context.Background(), "Get-LocalUser -Name 'Test")
+	suite.Require().NoError(err)
+	suite.Equal(1, result.ExitCode)
+
+	records, err := parsing.DecodeCliXmlErrRecords(result.StdErr)
+	suite.Require().NoError(err)
+	suite.Require().Len(records, 1)
+	suite.Equal("TerminatorExpectedAtEndOfString", records[0].FullyQualifiedErrorId)
+	suite.Equal("ParserError", records[0].CategoryInfo.Category)
+	suite.Equal(21, records[0].ScriptPosition.Column)
+}
+
+func (suite *FakeUnitTestSuite) TestRunEncodedCommand() {
+	cmd, err := parsing.EncodePwshCmd("Get-LocalGroup -SID 'S-1-5-32-544' | ConvertTo-Json -Compress")
+	suite.Require().NoError(err)
+
+	result, err := fake.NewHost().Run(context.Background(), cmd)
+	suite.Require().NoError(err)
+	suite.Contains(result.StdOut, `"Name":"Administrators"`)
+}
+
+func (suite *FakeUnitTestSuite) TestStreamInput() {
+	var stdout bytes.Buffer
+	code, err := fake.NewHost().StreamWithPowershell(context.Background(), "$input | ConvertTo-Json -Compress", connection.Streams{
+		Stdin:  strings.NewReader("first\r\nsecond\r\n"),
+		Stdout: &stdout,
+	})
+	suite.Require().NoError(err)
+	suite.Equal(0, code)
+	suite.Equal(`["first","second"]`+"\r\n", stdout.String())
+}
+
+func (suite *FakeUnitTestSuite) TestStreamSecureStringInput() {
+	var stdout bytes.Buffer
+	code, err := fake.NewHost().StreamWithPowershell(context.Background(), "$($input | Select-Object -First 1 | ConvertTo-SecureString -AsPlainText -Force) | ConvertTo-Json", connection.Streams{
+		Stdin:  strings.NewReader("first\r\nsecond\r\n"),
+		Stdout: &stdout,
+	})
+	suite.Require().NoError(err)
+	suite.Equal(0, code)
+	suite.Equal("\"System.Security.SecureString\"\r\n", stdout.String())
+}
+
+func (suite *FakeUnitTestSuite) TestRunCanceled() {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := fake.NewHost().RunWithPowershell(ctx, "Get-LocalUser")
+	suite.ErrorIs(err, context.Canceled)
+}
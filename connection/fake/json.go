@@ -0,0 +1,150 @@
+package fake
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"net/netip"
+	"strings"
+	"time"
+)
+
+// jsonObject is an object that is rendered by ConvertTo-Json.
+type jsonObject interface {
+	json() any
+}
+
+// toJson renders a value like ConvertTo-Json -Compress does.
+// An array is always rendered as JSON array, even with a single item.
+func toJson(v any) (string, error) {
+	var b bytes.Buffer
+	enc := json.NewEncoder(&b)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(jsonValue(v)); err != nil {
+		return "", err
+	}
+	return strings.TrimSuffix(b.String(), "\n"), nil
+}
+
+// jsonValue returns the JSON representation of a value of the script.
+func jsonValue(v any) any {
+	switch v := v.(type) {
+	case jsonObject:
+		return v.json()
+	case []any:
+		values := make([]any, len(v))
+		for i := range v {
+			values[i] = jsonValue(v[i])
+		}
+		return values
+	case time.Duration:
+		return timeSpan(v)
+	case time.Time:
+		return dotnetDate{v}
+	case secureString:
+		return "System.Security.SecureString"
+	}
+	return v
+}
+
+// dotnetDate renders a time like ConvertTo-Json renders a DateTime, e.g. "\/Date(1701379505092)\/".
+// The zero time is rendered as null.
+type dotnetDate struct {
+	time.Time
+}
+
+// MarshalJSON implements the json.Marshaler interface.
+func (d dotnetDate) MarshalJSON() ([]byte, error) {
+	if d.IsZero() {
+		return []byte("null"), nil
+	}
+	return []byte(fmt.Sprintf(`"\/Date(%d)\/"`, d.UnixMilli())), nil
+}
+
+// timeSpan renders a duration like ConvertTo-Json renders a TimeSpan.
+type timeSpan time.Duration
+
+// MarshalJSON implements the json.Marshaler interface.
+func (t timeSpan) MarshalJSON() ([]byte, error) {
+	d := time.Duration(t)
+	return json.Marshal(struct {
+		Ticks             int64
+		Days              int64
+		Hours             int64
+		Milliseconds      int64
+		Minutes           int64
+		Seconds           int64
+		TotalDays         float64
+		TotalHours        float64
+		TotalMilliseconds float64
+		TotalMinutes      float64
+		TotalSeconds      float64
+	}{
+		Ticks:             int64(d / 100),
+		Days:              int64(d / (24 * time.Hour)),
+		Hours:             int64(d/time.Hour) % 24,
+		Milliseconds:      int64(d/time.Millisecond) % 1000,
+		Minutes:           int64(d/time.Minute) % 60,
+		Seconds:           int64(d/time.Second) % 60,
+		TotalDays:         d.Hours() / 24,
+		TotalHours:        d.Hours(),
+		TotalMilliseconds: float64(d.Milliseconds()),
+		TotalMinutes:      d.Minutes(),
+		TotalSeconds:      d.Seconds(),
+	})
+}
+
+// ipAddress renders an address like ConvertTo-Json renders a System.Net.IPAddress.
+type ipAddress netip.Addr
+
+// MarshalJSON implements the json.Marshaler interface.
+func (a ipAddress) MarshalJSON() ([]byte, error) {
+	addr := netip.Addr(a)
+	ip4 := addr.As4()
+	return json.Marshal(struct {
+		Address            uint32
+		AddressFamily      int
+		ScopeId            *int64
+		IsIPv6Multicast    bool
+		IsIPv6LinkLocal    bool
+		IsIPv6SiteLocal    bool
+		IsIPv6Teredo       bool
+		IsIPv4MappedToIPv6 bool
+		IPAddressToString  string
+	}{
+		// The address is stored in network byte order, so it is rendered as little endian number.
+		Address:           binary.LittleEndian.Uint32(ip4[:]),
+		AddressFamily:     2,
+		IPAddressToString: addr.String(),
+	})
+}
+
+// securityIdentifier renders a SID like ConvertTo-Json renders a System.Security.Principal.SecurityIdentifier.
+type securityIdentifier string
+
+// MarshalJSON implements the json.Marshaler interface.
+func (s securityIdentifier) MarshalJSON() ([]byte, error) {
+	sid := string(s)
+	subAuthorities := strings.Split(sid, "-")[3:]
+
+	// Only SIDs of a machine or a domain have an account domain.
+	var domain *string
+	if len(subAuthorities) == 5 && subAuthorities[0] == "21" {
+		d := sid[:strings.LastIndex(sid, "-")]
+		domain = &d
+	}
+
+	return json.Marshal(struct {
+		BinaryLength     int
+		AccountDomainSid *string
+		Value            string
+	}{
+		BinaryLength:     8 + 4*len(subAuthorities),
+		AccountDomainSid: domain,
+		Value:            sid,
+	})
+}
+
+// secureString is a System.Security.SecureString created by ConvertTo-SecureString.
+type secureString string
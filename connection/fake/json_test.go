@@ -0,0 +1,65 @@
+package fake
+
+import (
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+// Unit test suite for the JSON rendering of the fake host.
+type JsonUnitTestSuite struct {
+	suite.Suite
+}
+
+func TestJsonUnitTestSuite(t *testing.T) {
+	suite.Run(t, &JsonUnitTestSuite{})
+}
+
+func (suite *JsonUnitTestSuite) TestToJson() {
+	tcs := []struct {
+		description  string
+		value        any
+		expectedJson string
+	}{
+		{
+			"assert date",
+			time.UnixMilli(1701379505092),
+			`"\/Date(1701379505092)\/"`,
+		},
+		{
+			"assert timespan",
+			90 * time.Minute,
+			`{"Ticks":54000000000,"Days":0,"Hours":1,"Milliseconds":0,"Minutes":30,"Seconds":0,"TotalDays":0.0625,"TotalHours":1.5,"TotalMilliseconds":5400000,"TotalMinutes":90,"TotalSeconds":5400}`,
+		},
+		{
+			"assert ip address",
+			ipAddress(netip.MustParseAddr("192.168.1.0")),
+			`{"Address":108736,"AddressFamily":2,"ScopeId":null,"IsIPv6Multicast":false,"IsIPv6LinkLocal":false,"IsIPv6SiteLocal":false,"IsIPv6Teredo":false,"IsIPv4MappedToIPv6":false,"IPAddressToString":"192.168.1.0"}`,
+		},
+		{
+			"assert machine sid",
+			securityIdentifier(machineSid + "-500"),
+			`{"BinaryLength":28,"AccountDomainSid":"S-1-5-21-153895498-367353507-3704405138","Value":"S-1-5-21-153895498-367353507-3704405138-500"}`,
+		},
+		{
+			"assert builtin sid",
+			securityIdentifier("S-1-5-32-545"),
+			`{"BinaryLength":16,"AccountDomainSid":null,"Value":"S-1-5-32-545"}`,
+		},
+		{
+			"assert array with single item",
+			[]any{"test"},
+			`["test"]`,
+		},
+	}
+
+	for _, tc := range tcs {
+		suite.T().Logf("test case: %s", tc.description)
+
+		actualJson, err := toJson(tc.value)
+		suite.Require().NoError(err)
+		suite.Equal(tc.expectedJson, actualJson)
+	}
+}
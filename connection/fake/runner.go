@@ -0,0 +1,622 @@
+package fake
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// errorRecord is a PowerShell error record that is written to the error stream of a command.
+type errorRecord struct {
+	// activity is the name of the failed command. It is empty for parse errors.
+	activity string
+
+	message    string
+	category   string
+	targetName string
+	targetType string
+	reason     string
+
+	// errorId is the fully qualified error id, e.g. "UserNotFound,Microsoft.PowerShell.Commands.GetLocalUserCommand".
+	errorId string
+
+	// pos and end are the byte offsets of the failed statement in the script.
+	pos, end int
+}
+
+// Error implements the error interface.
+func (e *errorRecord) Error() string {
+	return e.message
+}
+
+// text renders the error record as it is written by the PowerShell console, see connection.CliXmlErrorWriter.
+func (e *errorRecord) text(script string) string {
+	lineStart := strings.LastIndexAny(script[:e.pos], "\r\n") + 1
+	lineEnd := len(script)
+	if i := strings.IndexAny(script[e.pos:], "\r\n"); i >= 0 {
+		lineEnd = e.pos + i
+	}
+	line := strings.Count(script[:lineStart], "\n") + 1
+	if !strings.Contains(script, "\n") {
+		line = strings.Count(script[:lineStart], "\r") + 1
+	}
+	column := e.pos - lineStart + 1
+	width := max(min(e.end, lineEnd)-e.pos, 1)
+
+	var b strings.Builder
+	if e.activity != "" {
+		fmt.Fprintf(&b, "%s : %s\n", e.activity, e.message)
+	}
+	fmt.Fprintf(&b, "At line:%d char:%d\n", line, column)
+	fmt.Fprintf(&b, "+ %s\n", script[lineStart:lineEnd])
+	fmt.Fprintf(&b, "+ %s%s\n", strings.Repeat(" ", column-1), strings.Repeat("~", width))
+	if e.activity == "" {
+		fmt.Fprintf(&b, "%s\n", e.message)
+	}
+	fmt.Fprintf(&b, "    + CategoryInfo          : %s: (%s:%s) [%s], %s\n", e.category, e.targetName, e.targetType, e.activity, e.reason)
+	fmt.Fprintf(&b, "    + FullyQualifiedErrorId : %s\n", e.errorId)
+
+	return b.String()
+}
+
+// parseError returns the error record of a script that cannot be parsed.
+func parseError(pos int, end int, errorId string, message string) *errorRecord {
+	return &errorRecord{
+		message:  message,
+		category: "ParserError",
+		reason:   "ParentContainsErrorRecordException",
+		errorId:  errorId,
+		pos:      pos,
+		end:      max(end, pos+1),
+	}
+}
+
+// exitError ends a script with an exit code.
+type exitError struct {
+	code int
+}
+
+// Error implements the error interface.
+func (e *exitError) Error() string {
+	return fmt.Sprintf("exit %d", e.code)
+}
+
+// cmdlet is a cmdlet that is supported by the fake host.
+type cmdlet struct {
+	// name is the name of the cmdlet, e.g. Get-LocalUser.
+	name string
+
+	// source is appended to the error ids of the cmdlet, e.g. Microsoft.PowerShell.Commands.GetLocalUserCommand.
+	source string
+
+	// params are the names of the supported parameters.
+	params []string
+
+	// positional are the names of the parameters that are bound by position.
+	positional []string
+
+	// run runs the cmdlet with the bound parameters and the pipeline input.
+	run func(h *Host, inv *invocation) ([]any, error)
+}
+
+// invocation contains the bound parameters and the pipeline input of a cmdlet.
+type invocation struct {
+	cmdlet *cmdlet
+	params map[string]any
+	input  []any
+}
+
+// has reports whether the parameter is bound.
+func (inv *invocation) has(name string) bool {
+	_, ok := inv.params[strings.ToLower(name)]
+	return ok
+}
+
+// value returns the value of a bound parameter or nil.
+func (inv *invocation) value(name string) any {
+	return inv.params[strings.ToLower(name)]
+}
+
+// string returns the value of a bound parameter as string.
+func (inv *invocation) string(name string) string {
+	switch v := inv.value(name).(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case secureString:
+		return string(v)
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// bool returns the value of a bound switch or boolean parameter.
+func (inv *invocation) bool(name string) bool {
+	b, _ := inv.value(name).(bool)
+	return b
+}
+
+// int returns the value of a bound numeric parameter.
+func (inv *invocation) int(name string) (int64, error) {
+	switch v := inv.value(name).(type) {
+	case nil:
+		return 0, nil
+	case int64:
+		return v, nil
+	}
+	return 0, inv.transformationError(name, "System.Int64")
+}
+
+// duration returns the value of a bound TimeSpan parameter.
+func (inv *invocation) duration(name string) (time.Duration, error) {
+	switch v := inv.value(name).(type) {
+	case nil:
+		return 0, nil
+	case time.Duration:
+		return v, nil
+	}
+	return 0, inv.transformationError(name, "System.TimeSpan")
+}
+
+// strings returns the value of a bound string or string array parameter.
+func (inv *invocation) strings(name string) []string {
+	switch v := inv.value(name).(type) {
+	case nil:
+		return nil
+	case []any:
+		s := make([]string, len(v))
+		for i := range v {
+			s[i] = fmt.Sprint(v[i])
+		}
+		return s
+	}
+	return []string{inv.string(name)}
+}
+
+// fail returns an error record of the cmdlet.
+func (inv *invocation) fail(category string, errorId string, reason string, target string, targetType string, format string, a ...any) *errorRecord {
+	return &errorRecord{
+		activity:   inv.cmdlet.name,
+		message:    fmt.Sprintf(format, a...),
+		category:   category,
+		targetName: target,
+		targetType: targetType,
+		reason:     reason,
+		errorId:    fmt.Sprintf("%s,%s", errorId, inv.cmdlet.source),
+	}
+}
+
+// transformationError returns the error record of a parameter value that cannot be converted to the type of the parameter.
+func (inv *invocation) transformationError(name string, typeName string) *errorRecord {
+	return inv.fail("InvalidData", "ParameterArgumentTransformationError", "ParameterBindingArgumentTransformationException", "", "",
+		"Cannot process argument transformation on parameter '%s'. Cannot convert value \"%v\" to type \"%s\".", name, inv.value(name), typeName)
+}
+
+// validationError returns the error record of a parameter value that is not valid.
+func (inv *invocation) validationError(name string, format string, a ...any) *errorRecord {
+	return inv.fail("InvalidData", "ParameterArgumentValidationError", "ParameterBindingValidationException", "", "",
+		"Cannot validate argument on parameter '%s'. %s", name, fmt.Sprintf(format, a...))
+}
+
+// validateSet returns a validation error if the value of a bound parameter is not one of the allowed values.
+// It returns the allowed value with the case of the set.
+func (inv *invocation) validateSet(name string, set ...string) (string, error) {
+	value := inv.string(name)
+	for _, s := range set {
+		if strings.EqualFold(value, s) {
+			return s, nil
+		}
+	}
+	return "", inv.validationError(name, "The argument \"%s\" does not belong to the set \"%s\" specified by the ValidateSet attribute. Supply an argument that is in the set and then try the command again.", value, strings.Join(set, ","))
+}
+
+// runner runs a parsed PowerShell script against a fake host.
+type runner struct {
+	host   *Host
+	script string
+	vars   map[string]any
+}
+
+// statements runs the statements and returns their output.
+func (r *runner) statements(statements []statement) ([]any, error) {
+	var output []any
+	for _, s := range statements {
+		out, err := r.statement(s)
+		if err != nil {
+			return nil, err
+		}
+		output = append(output, out...)
+	}
+	return output, nil
+}
+
+// statement runs a single statement and returns its output.
+// Errors without a position are reported at the statement.
+func (r *runner) statement(s statement) ([]any, error) {
+	output, err := r.run(s)
+
+	var record *errorRecord
+	var exit *exitError
+	switch {
+	case err == nil, errors.As(err, &exit):
+	case !errors.As(err, &record):
+		record = &errorRecord{
+			message:  err.Error(),
+			category: "NotImplemented",
+			reason:   "NotSupportedException",
+			errorId:  "NotSupported",
+		}
+		err = record
+	}
+	if record != nil && record.end == 0 {
+		record.pos, record.end = s.pos, s.end
+	}
+
+	return output, err
+}
+
+// run runs the kind of a statement.
+func (r *runner) run(s statement) ([]any, error) {
+	switch s := s.kind.(type) {
+	case pipelineStatement:
+		output, err := r.pipeline(s.elements)
+		if err != nil || s.target == nil {
+			return output, err
+		}
+		return nil, r.assign(s.target, s.append, output)
+
+	case ifStatement:
+		ok, err := r.compare(s)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			return r.statements(s.then)
+		}
+		return r.statements(s.other)
+
+	case exitStatement:
+		code, err := r.eval(s.code)
+		if err != nil {
+			return nil, err
+		}
+		n, _ := code.(int64)
+		return nil, &exitError{code: int(n)}
+	}
+
+	return nil, fmt.Errorf("unexpected statement %T", s)
+}
+
+// pipeline runs the elements of a pipeline and returns the output of the last element.
+func (r *runner) pipeline(elements []element) ([]any, error) {
+	var output []any
+	for _, e := range elements {
+		if e.command == nil {
+			v, err := r.eval(e.expr)
+			if err != nil {
+				return nil, err
+			}
+			output = enumerate(v)
+			continue
+		}
+
+		var err error
+		if output, err = r.invoke(e.command, output); err != nil {
+			return nil, err
+		}
+	}
+	return output, nil
+}
+
+// assign assigns the output of a pipeline to a variable or a property of a variable.
+func (r *runner) assign(target []string, appendOutput bool, output []any) error {
+	value := collect(output)
+	name := strings.ToLower(target[0])
+
+	if len(target) > 1 {
+		obj, ok := r.vars[name].(propertySetter)
+		if !ok {
+			return propertyNotFound(target[len(target)-1])
+		}
+		return obj.setProperty(target[1:], value)
+	}
+
+	if appendOutput {
+		switch current := r.vars[name].(type) {
+		case nil:
+		case []any:
+			value = append(append([]any(nil), current...), output...)
+		default:
+			value = append([]any{current}, output...)
+		}
+	}
+
+	r.vars[name] = value
+	return nil
+}
+
+// compare evaluates the condition of an if statement.
+func (r *runner) compare(s ifStatement) (bool, error) {
+	left, err := r.eval(s.left)
+	if err != nil {
+		return false, err
+	}
+	right, err := r.eval(s.right)
+	if err != nil {
+		return false, err
+	}
+
+	l, lok := left.(int64)
+	rn, rok := right.(int64)
+	if !lok || !rok {
+		if s.operator == "-eq" || s.operator == "-ne" {
+			equal := strings.EqualFold(fmt.Sprint(left), fmt.Sprint(right))
+			return equal == (s.operator == "-eq"), nil
+		}
+		return false, fmt.Errorf("unsupported comparison of %v and %v", left, right)
+	}
+
+	switch s.operator {
+	case "-eq":
+		return l == rn, nil
+	case "-ne":
+		return l != rn, nil
+	case "-gt":
+		return l > rn, nil
+	case "-ge":
+		return l >= rn, nil
+	case "-lt":
+		return l < rn, nil
+	case "-le":
+		return l <= rn, nil
+	}
+	return false, fmt.Errorf("unsupported operator %s", s.operator)
+}
+
+// eval evaluates an expression.
+func (r *runner) eval(e expression) (any, error) {
+	switch e := e.(type) {
+	case stringLiteral:
+		return string(e), nil
+
+	case numberLiteral:
+		return int64(e), nil
+
+	case variable:
+		return r.variable(e)
+
+	case listLiteral:
+		values := make([]any, len(e))
+		for i := range e {
+			v, err := r.eval(e[i])
+			if err != nil {
+				return nil, err
+			}
+			values[i] = v
+		}
+		return values, nil
+
+	case subexpression:
+		output, err := r.statements(e)
+		return collect(output), err
+
+	case arrayLiteral:
+		output, err := r.statements(e)
+		if output == nil {
+			output = []any{}
+		}
+		return output, err
+
+	case scriptBlock:
+		return e, nil
+
+	case staticCall:
+		return r.staticCall(e)
+	}
+
+	return nil, fmt.Errorf("unexpected expression %T", e)
+}
+
+// variable returns the value of a variable or of a property of a variable.
+func (r *runner) variable(path []string) (any, error) {
+	var v any
+	switch name := strings.ToLower(path[0]); name {
+	case "true":
+		v = true
+	case "false":
+		v = false
+	case "null":
+		v = nil
+	default:
+		v = r.vars[name]
+	}
+
+	for _, property := range path[1:] {
+		switch {
+		case strings.EqualFold(property, "Count"), strings.EqualFold(property, "Length"):
+			v = int64(len(enumerate(v)))
+		default:
+			getter, ok := v.(propertyGetter)
+			if !ok {
+				return nil, nil
+			}
+			v = getter.property(property)
+		}
+	}
+
+	return v, nil
+}
+
+// staticCall calls a static method of a type. Only [ciminstance]::new is supported, which copies a CIM instance.
+func (r *runner) staticCall(c staticCall) (any, error) {
+	if !strings.EqualFold(c.typeName, "ciminstance") || !strings.EqualFold(c.member, "new") || len(c.args) != 1 {
+		return nil, &errorRecord{
+			message:  fmt.Sprintf("Method invocation failed because [%s] does not contain a method named '%s'.", c.typeName, c.member),
+			category: "InvalidOperation",
+			reason:   "RuntimeException",
+			errorId:  "MethodNotFound",
+			pos:      c.pos,
+			end:      c.end,
+		}
+	}
+
+	v, err := r.eval(c.args[0])
+	if err != nil {
+		return nil, err
+	}
+
+	instance, ok := v.(cloner)
+	if !ok {
+		return nil, &errorRecord{
+			message:  `Exception calling ".ctor" with "1" argument(s): "Value cannot be null."`,
+			category: "NotSpecified",
+			reason:   "MethodInvocationException",
+			errorId:  "ArgumentNullException",
+			pos:      c.pos,
+			end:      c.end,
+		}
+	}
+	return instance.clone(), nil
+}
+
+// invoke binds the arguments of a command and runs the cmdlet.
+func (r *runner) invoke(c *command, input []any) ([]any, error) {
+	output, err := r.invokeCmdlet(c, input)
+
+	// The position of the error record is the failed command.
+	var record *errorRecord
+	if errors.As(err, &record) && record.end == 0 {
+		record.pos, record.end = c.pos, c.end
+	}
+	return output, err
+}
+
+func (r *runner) invokeCmdlet(c *command, input []any) ([]any, error) {
+	if strings.EqualFold(c.name, "ForEach-Object") {
+		return r.forEach(c, input)
+	}
+
+	cmd, ok := cmdlets[strings.ToLower(c.name)]
+	if !ok {
+		return nil, &errorRecord{
+			activity:   c.name,
+			message:    fmt.Sprintf("The term '%s' is not recognized as the name of a cmdlet, function, script file, or operable program. Check the spelling of the name, or if a path was included, verify that the path is correct and try again.", c.name),
+			category:   "ObjectNotFound",
+			targetName: c.name,
+			targetType: "String",
+			reason:     "CommandNotFoundException",
+			errorId:    "CommandNotFoundException",
+		}
+	}
+
+	inv := &invocation{cmdlet: cmd, params: map[string]any{}, input: input}
+	position := 0
+	for _, arg := range c.args {
+		name := arg.name
+		if name == "" {
+			if position >= len(cmd.positional) {
+				v, _ := r.eval(arg.value)
+				return nil, inv.fail("InvalidArgument", "PositionalParameterNotFound", "ParameterBindingException", "", "",
+					"A positional parameter cannot be found that accepts argument '%v'.", v)
+			}
+			name = cmd.positional[position]
+			position++
+		}
+
+		known := false
+		for _, p := range cmd.params {
+			if strings.EqualFold(p, name) {
+				known = true
+				name = p
+			}
+		}
+		if !known {
+			return nil, inv.fail("InvalidArgument", "NamedParameterNotFound", "ParameterBindingException", "", "",
+				"A parameter cannot be found that matches parameter name '%s'.", name)
+		}
+
+		var value any = true
+		if arg.value != nil {
+			var err error
+			if value, err = r.eval(arg.value); err != nil {
+				return nil, err
+			}
+		}
+		inv.params[strings.ToLower(name)] = value
+	}
+
+	return cmd.run(r.host, inv)
+}
+
+// forEach runs the script block of ForEach-Object for every input object, which is available as $_.
+func (r *runner) forEach(c *command, input []any) ([]any, error) {
+	if len(c.args) != 1 || c.args[0].name != "" {
+		return nil, fmt.Errorf("ForEach-Object only supports a single script block")
+	}
+	block, ok := c.args[0].value.(scriptBlock)
+	if !ok {
+		return nil, fmt.Errorf("ForEach-Object only supports a single script block")
+	}
+
+	var output []any
+	for _, item := range input {
+		r.vars["_"] = item
+		out, err := r.statements(block)
+		if err != nil {
+			return nil, err
+		}
+		output = append(output, out...)
+	}
+	return output, nil
+}
+
+// propertyNotFound returns the error record of a property that cannot be set.
+func propertyNotFound(name string) *errorRecord {
+	return &errorRecord{
+		message:  fmt.Sprintf("The property '%s' cannot be found on this object. Verify that the property exists and can be set.", name),
+		category: "InvalidOperation",
+		reason:   "RuntimeException",
+		errorId:  "PropertyNotFound",
+	}
+}
+
+// propertyGetter is an object with properties that can be read by the script.
+type propertyGetter interface {
+	property(name string) any
+}
+
+// propertySetter is an object with properties that can be set by the script.
+type propertySetter interface {
+	setProperty(path []string, value any) error
+}
+
+// cloner is a CIM instance that can be copied with [ciminstance]::new.
+type cloner interface {
+	clone() any
+}
+
+// enumerate returns the items of an array or the value as single item.
+// Nil is enumerated as empty pipeline.
+func enumerate(v any) []any {
+	switch v := v.(type) {
+	case nil:
+		return nil
+	case []any:
+		return v
+	}
+	return []any{v}
+}
+
+// collect returns the output of a pipeline as value the way PowerShell assigns it to a variable:
+// nil for no output, the object for a single output and an array otherwise.
+func collect(output []any) any {
+	switch len(output) {
+	case 0:
+		return nil
+	case 1:
+		return output[0]
+	}
+	return output
+}
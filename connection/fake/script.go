@@ -0,0 +1,606 @@
+package fake
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/d-strobel/gowindows/parsing"
+)
+
+// tokenKind is the kind of a token of a PowerShell script.
+type tokenKind int
+
+const (
+	tokenEnd tokenKind = iota
+	tokenWord
+	tokenString
+	tokenNumber
+	tokenVariable
+	tokenParameter
+	tokenType
+	tokenComma
+	tokenPipe
+	tokenSeparator
+	tokenAssign
+	tokenAppend
+	tokenParen
+	tokenSubexpression
+	tokenArray
+	tokenCloseParen
+	tokenBrace
+	tokenCloseBrace
+)
+
+// token is a token of a PowerShell script.
+type token struct {
+	kind tokenKind
+
+	// text is the word, the unquoted string, the number, the variable path without $,
+	// the parameter name without - or the type name without brackets.
+	text string
+
+	// member is the static member of a type, e.g. new of [ciminstance]::new.
+	member string
+
+	// colon reports whether a parameter is bound with a colon, e.g. -Confirm:$false.
+	colon bool
+
+	// pos and end are the byte offsets of the token in the script.
+	pos, end int
+}
+
+// lex splits a PowerShell script into tokens.
+// It supports the subset of the PowerShell syntax that is emitted by the parsing.PwshCommand builder.
+func lex(script string) ([]token, error) {
+	var tokens []token
+	i := 0
+
+	for i < len(script) {
+		r, size := utf8.DecodeRuneInString(script[i:])
+		start := i
+
+		switch {
+		case r == ' ' || r == '\t':
+			i += size
+			continue
+
+		case r == '\r' || r == '\n':
+			tokens = append(tokens, token{kind: tokenSeparator, pos: start, end: start + size})
+			i += size
+			continue
+
+		// Block comments like the sensitive marker are ignored.
+		case strings.HasPrefix(script[i:], "<#"):
+			end := strings.Index(script[i:], "#>")
+			if end < 0 {
+				return nil, parseError(start, len(script), "TerminatorExpectedAtEndOfString", "The string is missing the terminator: #>.")
+			}
+			i += end + 2
+			continue
+
+		case r == '#':
+			for i < len(script) && script[i] != '\r' && script[i] != '\n' {
+				i++
+			}
+			continue
+
+		case parsing.IsPwshSingleQuote(r):
+			text, n, ok := lexString(script[i:])
+			if !ok {
+				return nil, parseError(start, len(script), "TerminatorExpectedAtEndOfString", "The string is missing the terminator: '.")
+			}
+			i += n
+			tokens = append(tokens, token{kind: tokenString, text: text, pos: start, end: i})
+			continue
+
+		case r == '$' && strings.HasPrefix(script[i:], "$("):
+			i += 2
+			tokens = append(tokens, token{kind: tokenSubexpression, pos: start, end: i})
+			continue
+
+		case r == '$':
+			i += size
+			for i < len(script) && (isWordByte(script[i]) || script[i] == '.' && i+1 < len(script) && isWordByte(script[i+1])) {
+				i++
+			}
+			if i == start+1 {
+				return nil, parseError(start, i, "InvalidVariableReference", "Variable reference is not valid. '$' was not followed by a valid variable name character.")
+			}
+			tokens = append(tokens, token{kind: tokenVariable, text: script[start+1 : i], pos: start, end: i})
+			continue
+
+		case r == '@' && strings.HasPrefix(script[i:], "@("):
+			i += 2
+			tokens = append(tokens, token{kind: tokenArray, pos: start, end: i})
+			continue
+
+		case r == '-' && i+1 < len(script) && isLetter(script[i+1]):
+			i++
+			for i < len(script) && isWordByte(script[i]) {
+				i++
+			}
+			t := token{kind: tokenParameter, text: script[start+1 : i], pos: start}
+			if i < len(script) && script[i] == ':' {
+				t.colon = true
+				i++
+			}
+			t.end = i
+			tokens = append(tokens, t)
+			continue
+
+		case r == '-' && i+1 < len(script) && isDigit(script[i+1]), isDigit(script[i]):
+			i++
+			for i < len(script) && isDigit(script[i]) {
+				i++
+			}
+			tokens = append(tokens, token{kind: tokenNumber, text: script[start:i], pos: start, end: i})
+			continue
+
+		case r == '[':
+			end := strings.IndexByte(script[i:], ']')
+			if end < 0 {
+				return nil, parseError(start, len(script), "EndSquareBracketExpectedAtEndOfType", "Missing ] at end of type token.")
+			}
+			t := token{kind: tokenType, text: script[i+1 : i+end], pos: start}
+			i += end + 1
+			if strings.HasPrefix(script[i:], "::") {
+				i += 2
+				memberStart := i
+				for i < len(script) && isWordByte(script[i]) {
+					i++
+				}
+				t.member = script[memberStart:i]
+			}
+			t.end = i
+			tokens = append(tokens, t)
+			continue
+
+		case strings.HasPrefix(script[i:], "+="):
+			i += 2
+			tokens = append(tokens, token{kind: tokenAppend, pos: start, end: i})
+			continue
+		}
+
+		kinds := map[rune]tokenKind{
+			',': tokenComma,
+			'|': tokenPipe,
+			';': tokenSeparator,
+			'=': tokenAssign,
+			'(': tokenParen,
+			')': tokenCloseParen,
+			'{': tokenBrace,
+			'}': tokenCloseBrace,
+		}
+		if kind, ok := kinds[r]; ok {
+			i += size
+			tokens = append(tokens, token{kind: kind, pos: start, end: i})
+			continue
+		}
+
+		// Everything else is a word, e.g. the name of a cmdlet or a keyword.
+		for i < len(script) {
+			r, size := utf8.DecodeRuneInString(script[i:])
+			if unicode.IsSpace(r) || strings.ContainsRune(",|;=(){}", r) {
+				break
+			}
+			i += size
+		}
+		tokens = append(tokens, token{kind: tokenWord, text: script[start:i], pos: start, end: i})
+	}
+
+	return append(tokens, token{kind: tokenEnd, pos: len(script), end: len(script)}), nil
+}
+
+// lexString returns the unquoted text and the length of the single-quoted literal at the start of s.
+// It returns false if the literal is not terminated.
+func lexString(s string) (string, int, bool) {
+	var b strings.Builder
+	_, size := utf8.DecodeRuneInString(s)
+	i := size
+
+	for i < len(s) {
+		r, size := utf8.DecodeRuneInString(s[i:])
+		i += size
+		if !parsing.IsPwshSingleQuote(r) {
+			b.WriteRune(r)
+			continue
+		}
+
+		// A quote character followed by another quote character is an escaped quote.
+		next, nextSize := utf8.DecodeRuneInString(s[i:])
+		if !parsing.IsPwshSingleQuote(next) {
+			return b.String(), i, true
+		}
+		b.WriteRune(r)
+		i += nextSize
+	}
+
+	return "", 0, false
+}
+
+func isLetter(b byte) bool {
+	return b >= 'a' && b <= 'z' || b >= 'A' && b <= 'Z'
+}
+
+func isDigit(b byte) bool {
+	return b >= '0' && b <= '9'
+}
+
+func isWordByte(b byte) bool {
+	return isLetter(b) || isDigit(b) || b == '_'
+}
+
+// statement is a statement of a parsed script with its byte offsets in the script.
+type statement struct {
+	kind     any
+	pos, end int
+}
+
+// The kinds of statements of a parsed script.
+type (
+	// pipelineStatement is a pipeline whose output is optionally assigned or appended to a variable.
+	pipelineStatement struct {
+		target   []string
+		append   bool
+		elements []element
+	}
+
+	// ifStatement is an if statement with a single comparison as condition.
+	ifStatement struct {
+		left, right expression
+		operator    string
+		then, other []statement
+	}
+
+	// exitStatement exits the script with an exit code.
+	exitStatement struct {
+		code expression
+	}
+)
+
+// element is an element of a pipeline, either a command or an expression.
+type element struct {
+	command *command
+	expr    expression
+}
+
+// command is the invocation of a cmdlet.
+type command struct {
+	name     string
+	args     []argument
+	pos, end int
+}
+
+// argument is a named or a positional argument of a command.
+// The value of a switch parameter is nil.
+type argument struct {
+	name  string
+	value expression
+}
+
+// expression is one of the expression types below.
+type expression any
+
+// The expressions of a parsed script.
+type (
+	stringLiteral string
+	numberLiteral int64
+	variable      []string
+	listLiteral   []expression
+	subexpression []statement
+	arrayLiteral  []statement
+	scriptBlock   []statement
+	staticCall    struct {
+		typeName, member string
+		args             []expression
+		pos, end         int
+	}
+)
+
+// parser parses the tokens of a PowerShell script into statements.
+type parser struct {
+	script string
+	tokens []token
+	i      int
+}
+
+// parse parses a PowerShell script into statements.
+func parse(script string) ([]statement, error) {
+	tokens, err := lex(script)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &parser{script: script, tokens: tokens}
+	return p.statements(tokenEnd)
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.i]
+}
+
+func (p *parser) next() token {
+	t := p.tokens[p.i]
+	if t.kind != tokenEnd {
+		p.i++
+	}
+	return t
+}
+
+// unexpected returns the parse error of an unexpected token.
+func (p *parser) unexpected(t token) error {
+	if t.kind == tokenEnd {
+		return parseError(t.pos, t.end, "MissingEndOfStatement", "Unexpected end of the script.")
+	}
+	return parseError(t.pos, t.end, "UnexpectedToken", fmt.Sprintf("Unexpected token '%s' in expression or statement.", p.script[t.pos:t.end]))
+}
+
+// expect consumes a token of the kind or returns a parse error.
+func (p *parser) expect(kind tokenKind) error {
+	if t := p.next(); t.kind != kind {
+		return p.unexpected(t)
+	}
+	return nil
+}
+
+// statements parses statements until the closing token, which is consumed.
+func (p *parser) statements(closing tokenKind) ([]statement, error) {
+	var statements []statement
+
+	for {
+		for p.peek().kind == tokenSeparator {
+			p.next()
+		}
+
+		if p.peek().kind == closing {
+			p.next()
+			return statements, nil
+		}
+
+		pos := p.peek().pos
+		s, err := p.statement()
+		if err != nil {
+			return nil, err
+		}
+		statements = append(statements, statement{kind: s, pos: pos, end: p.tokens[p.i-1].end})
+
+		switch t := p.peek(); t.kind {
+		case tokenSeparator, closing:
+		default:
+			return nil, p.unexpected(t)
+		}
+	}
+}
+
+// statement parses a single statement.
+func (p *parser) statement() (any, error) {
+	t := p.peek()
+
+	if t.kind == tokenWord && strings.EqualFold(t.text, "if") {
+		return p.ifStatement()
+	}
+
+	if t.kind == tokenWord && strings.EqualFold(t.text, "exit") {
+		p.next()
+		switch p.peek().kind {
+		case tokenSeparator, tokenCloseBrace, tokenEnd:
+			return exitStatement{code: numberLiteral(0)}, nil
+		}
+		code, err := p.expression()
+		return exitStatement{code: code}, err
+	}
+
+	s := pipelineStatement{}
+	if t.kind == tokenVariable {
+		if next := p.tokens[p.i+1].kind; next == tokenAssign || next == tokenAppend {
+			p.next()
+			p.next()
+			s.target = strings.Split(t.text, ".")
+			s.append = next == tokenAppend
+		}
+	}
+
+	for {
+		e, err := p.element(len(s.elements) == 0)
+		if err != nil {
+			return nil, err
+		}
+		s.elements = append(s.elements, e)
+
+		if p.peek().kind != tokenPipe {
+			return s, nil
+		}
+		p.next()
+	}
+}
+
+// ifStatement parses an if statement with an optional else block.
+func (p *parser) ifStatement() (any, error) {
+	p.next()
+	if err := p.expect(tokenParen); err != nil {
+		return nil, err
+	}
+
+	s := ifStatement{}
+	var err error
+	if s.left, err = p.expression(); err != nil {
+		return nil, err
+	}
+
+	op := p.next()
+	if op.kind != tokenParameter {
+		return nil, p.unexpected(op)
+	}
+	s.operator = "-" + strings.ToLower(op.text)
+
+	if s.right, err = p.expression(); err != nil {
+		return nil, err
+	}
+	if err := p.expect(tokenCloseParen); err != nil {
+		return nil, err
+	}
+
+	if err := p.expect(tokenBrace); err != nil {
+		return nil, err
+	}
+	if s.then, err = p.statements(tokenCloseBrace); err != nil {
+		return nil, err
+	}
+
+	if t := p.peek(); t.kind == tokenWord && strings.EqualFold(t.text, "else") {
+		p.next()
+		if err := p.expect(tokenBrace); err != nil {
+			return nil, err
+		}
+		if s.other, err = p.statements(tokenCloseBrace); err != nil {
+			return nil, err
+		}
+	}
+
+	return s, nil
+}
+
+// element parses a command or, at the start of a pipeline, an expression.
+func (p *parser) element(first bool) (element, error) {
+	t := p.peek()
+	if t.kind == tokenWord {
+		c, err := p.command()
+		return element{command: c}, err
+	}
+
+	if !first {
+		return element{}, parseError(t.pos, t.end, "ExpressionsMustBeFirstInPipeline", "Expressions are only allowed as the first element of a pipeline.")
+	}
+
+	e, err := p.expression()
+	return element{expr: e}, err
+}
+
+// command parses a command with its arguments.
+func (p *parser) command() (*command, error) {
+	t := p.next()
+	c := &command{name: t.text, pos: t.pos, end: t.end}
+
+	for {
+		t := p.peek()
+		switch t.kind {
+		case tokenPipe, tokenSeparator, tokenCloseParen, tokenCloseBrace, tokenEnd:
+			return c, nil
+		}
+
+		arg := argument{}
+		if t.kind == tokenParameter {
+			p.next()
+			arg.name = t.text
+
+			// Switch parameters are not followed by a value.
+			if !t.colon && !p.valueFollows() {
+				c.args = append(c.args, arg)
+				c.end = t.end
+				continue
+			}
+		}
+
+		value, err := p.expression()
+		if err != nil {
+			return nil, err
+		}
+		arg.value = value
+		c.args = append(c.args, arg)
+		c.end = p.tokens[p.i-1].end
+	}
+}
+
+// valueFollows reports whether the next token starts a value.
+func (p *parser) valueFollows() bool {
+	switch p.peek().kind {
+	case tokenString, tokenNumber, tokenVariable, tokenSubexpression, tokenArray, tokenParen, tokenType, tokenBrace:
+		return true
+	}
+	return false
+}
+
+// expression parses a value or a comma-separated list of values.
+func (p *parser) expression() (expression, error) {
+	first, err := p.value()
+	if err != nil || p.peek().kind != tokenComma {
+		return first, err
+	}
+
+	list := listLiteral{first}
+	for p.peek().kind == tokenComma {
+		p.next()
+		v, err := p.value()
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, v)
+	}
+	return list, nil
+}
+
+// value parses a single value.
+func (p *parser) value() (expression, error) {
+	t := p.next()
+
+	switch t.kind {
+	case tokenString:
+		return stringLiteral(t.text), nil
+
+	case tokenNumber:
+		n, err := strconv.ParseInt(t.text, 10, 64)
+		if err != nil {
+			return nil, parseError(t.pos, t.end, "BadNumericConstant", fmt.Sprintf("Bad numeric constant: %s.", t.text))
+		}
+		return numberLiteral(n), nil
+
+	case tokenVariable:
+		return variable(strings.Split(t.text, ".")), nil
+
+	case tokenSubexpression:
+		s, err := p.statements(tokenCloseParen)
+		return subexpression(s), err
+
+	case tokenArray:
+		s, err := p.statements(tokenCloseParen)
+		return arrayLiteral(s), err
+
+	case tokenParen:
+		s, err := p.statement()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect(tokenCloseParen); err != nil {
+			return nil, err
+		}
+		return subexpression{{kind: s, pos: t.pos, end: p.tokens[p.i-1].end}}, nil
+
+	case tokenBrace:
+		s, err := p.statements(tokenCloseBrace)
+		return scriptBlock(s), err
+
+	case tokenType:
+		call := staticCall{typeName: t.text, member: t.member, pos: t.pos}
+		if t.member == "" || p.peek().kind != tokenParen {
+			return nil, p.unexpected(t)
+		}
+		p.next()
+		for p.peek().kind != tokenCloseParen {
+			arg, err := p.value()
+			if err != nil {
+				return nil, err
+			}
+			call.args = append(call.args, arg)
+			if p.peek().kind == tokenComma {
+				p.next()
+			}
+		}
+		call.end = p.next().end
+		return call, nil
+	}
+
+	return nil, p.unexpected(t)
+}
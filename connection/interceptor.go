@@ -0,0 +1,102 @@
+package connection
+
+import (
+	"context"
+	"io"
+)
+
+// Command represents a command that is run on a connection.
+type Command struct {
+	// Cmd is the command line.
+	Cmd string
+
+	// Powershell reports whether the command runs via Powershell.
+	Powershell bool
+
+	// Streams are the streams of a command that is run with Stream or StreamWithPowershell.
+	// Streams is nil for Run and RunWithPowershell.
+	Streams *Streams
+}
+
+// Invoker runs a command.
+// The stdout and stderr of the CmdResult are empty for commands with streams.
+type Invoker func(ctx context.Context, cmd Command) (CmdResult, error)
+
+// Interceptor intercepts the commands of a connection.
+// It may change the context or the command, call next zero or more times and change the result or the error.
+// An interceptor is called concurrently if the connection is used concurrently.
+type Interceptor func(ctx context.Context, cmd Command, next Invoker) (CmdResult, error)
+
+// interceptedConnection is a Connection that passes every command through a chain of interceptors.
+type interceptedConnection struct {
+	conn   Connection
+	invoke Invoker
+}
+
+// Intercept returns a Connection that passes the commands through the interceptors before they run on conn.
+// The first interceptor is the outermost one, so it is called first and returns last.
+// Closing the returned connection closes conn.
+func Intercept(conn Connection, interceptors ...Interceptor) Connection {
+	invoke := func(ctx context.Context, cmd Command) (CmdResult, error) {
+		switch {
+		case cmd.Streams != nil && cmd.Powershell:
+			exitCode, err := conn.StreamWithPowershell(ctx, cmd.Cmd, *cmd.Streams)
+			return CmdResult{ExitCode: exitCode}, err
+		case cmd.Streams != nil:
+			exitCode, err := conn.Stream(ctx, cmd.Cmd, *cmd.Streams)
+			return CmdResult{ExitCode: exitCode}, err
+		case cmd.Powershell:
+			return conn.RunWithPowershell(ctx, cmd.Cmd)
+		default:
+			return conn.Run(ctx, cmd.Cmd)
+		}
+	}
+
+	for i := len(interceptors) - 1; i >= 0; i-- {
+		interceptor, next := interceptors[i], invoke
+		invoke = func(ctx context.Context, cmd Command) (CmdResult, error) {
+			return interceptor(ctx, cmd, next)
+		}
+	}
+
+	return &interceptedConnection{conn: conn, invoke: invoke}
+}
+
+// Run runs a command through the interceptors.
+func (c *interceptedConnection) Run(ctx context.Context, cmd string) (CmdResult, error) {
+	return c.invoke(ctx, Command{Cmd: cmd})
+}
+
+// RunWithPowershell runs a command via Powershell through the interceptors.
+func (c *interceptedConnection) RunWithPowershell(ctx context.Context, cmd string) (CmdResult, error) {
+	return c.invoke(ctx, Command{Cmd: cmd, Powershell: true})
+}
+
+// Stream runs a command with streams through the interceptors.
+func (c *interceptedConnection) Stream(ctx context.Context, cmd string, streams Streams) (int, error) {
+	result, err := c.invoke(ctx, Command{Cmd: cmd, Streams: &streams})
+	return result.ExitCode, err
+}
+
+// StreamWithPowershell runs a command with streams via Powershell through the interceptors.
+func (c *interceptedConnection) StreamWithPowershell(ctx context.Context, cmd string, streams Streams) (int, error) {
+	result, err := c.invoke(ctx, Command{Cmd: cmd, Powershell: true, Streams: &streams})
+	return result.ExitCode, err
+}
+
+// Close closes the underlying connection.
+func (c *interceptedConnection) Close() error {
+	return c.conn.Close()
+}
+
+// Upload writes the content of r to the remote file using the underlying connection.
+// File transfers do not pass through the interceptors.
+func (c *interceptedConnection) Upload(ctx context.Context, r io.Reader, remotePath string) error {
+	return Upload(ctx, c.conn, r, remotePath)
+}
+
+// Download writes the content of the remote file to w using the underlying connection.
+// File transfers do not pass through the interceptors.
+func (c *interceptedConnection) Download(ctx context.Context, remotePath string, w io.Writer) error {
+	return Download(ctx, c.conn, remotePath, w)
+}
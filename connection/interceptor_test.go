@@ -0,0 +1,146 @@
+package connection
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+)
+
+// recordingConnection is a Connection that records the methods called on it.
+type recordingConnection struct {
+	mu     sync.Mutex
+	calls  []string
+	closed bool
+}
+
+func (c *recordingConnection) record(method string, cmd string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.calls = append(c.calls, method+" "+cmd)
+}
+
+func (c *recordingConnection) Run(ctx context.Context, cmd string) (CmdResult, error) {
+	c.record("Run", cmd)
+	return CmdResult{StdOut: "run", ExitCode: 1}, nil
+}
+
+func (c *recordingConnection) RunWithPowershell(ctx context.Context, cmd string) (CmdResult, error) {
+	c.record("RunWithPowershell", cmd)
+	return CmdResult{StdOut: "powershell", ExitCode: 2}, nil
+}
+
+func (c *recordingConnection) Stream(ctx context.Context, cmd string, streams Streams) (int, error) {
+	c.record("Stream", cmd)
+	fmt.Fprint(streams.Stdout, "stream")
+	return 3, nil
+}
+
+func (c *recordingConnection) StreamWithPowershell(ctx context.Context, cmd string, streams Streams) (int, error) {
+	c.record("StreamWithPowershell", cmd)
+	fmt.Fprint(streams.Stdout, "powershell stream")
+	return 4, nil
+}
+
+func (c *recordingConnection) Close() error {
+	c.closed = true
+	return nil
+}
+
+func (suite *ConnectionUnitTestSuite) TestIntercept() {
+	suite.T().Parallel()
+
+	suite.Run("should dispatch the commands to the methods of the connection", func() {
+		conn := &recordingConnection{}
+		var commands []Command
+		c := Intercept(conn, func(ctx context.Context, cmd Command, next Invoker) (CmdResult, error) {
+			commands = append(commands, cmd)
+			return next(ctx, cmd)
+		})
+
+		result, err := c.Run(context.Background(), "whoami")
+		suite.NoError(err)
+		suite.Equal(CmdResult{StdOut: "run", ExitCode: 1}, result)
+
+		result, err = c.RunWithPowershell(context.Background(), "Get-LocalUser")
+		suite.NoError(err)
+		suite.Equal(CmdResult{StdOut: "powershell", ExitCode: 2}, result)
+
+		var stdout strings.Builder
+		exitCode, err := c.Stream(context.Background(), "dir", Streams{Stdout: &stdout})
+		suite.NoError(err)
+		suite.Equal(3, exitCode)
+		suite.Equal("stream", stdout.String())
+
+		stdout.Reset()
+		exitCode, err = c.StreamWithPowershell(context.Background(), "Get-ChildItem", Streams{Stdout: &stdout})
+		suite.NoError(err)
+		suite.Equal(4, exitCode)
+		suite.Equal("powershell stream", stdout.String())
+
+		suite.Equal([]string{"Run whoami", "RunWithPowershell Get-LocalUser", "Stream dir", "StreamWithPowershell Get-ChildItem"}, conn.calls)
+		suite.Require().Len(commands, 4)
+		suite.Equal(Command{Cmd: "whoami"}, commands[0])
+		suite.Equal(Command{Cmd: "Get-LocalUser", Powershell: true}, commands[1])
+		suite.False(commands[2].Powershell)
+		suite.NotNil(commands[2].Streams)
+		suite.True(commands[3].Powershell)
+		suite.NotNil(commands[3].Streams)
+
+		suite.NoError(c.Close())
+		suite.True(conn.closed)
+	})
+
+	suite.Run("should call the interceptors in order", func() {
+		var order []string
+		interceptor := func(name string) Interceptor {
+			return func(ctx context.Context, cmd Command, next Invoker) (CmdResult, error) {
+				order = append(order, "before "+name)
+				result, err := next(ctx, cmd)
+				order = append(order, "after "+name)
+				return result, err
+			}
+		}
+
+		c := Intercept(&recordingConnection{}, interceptor("first"), interceptor("second"))
+		_, err := c.Run(context.Background(), "whoami")
+		suite.NoError(err)
+		suite.Equal([]string{"before first", "before second", "after second", "after first"}, order)
+	})
+
+	suite.Run("should let interceptors change the command and the result", func() {
+		conn := &recordingConnection{}
+		c := Intercept(conn, func(ctx context.Context, cmd Command, next Invoker) (CmdResult, error) {
+			cmd.Cmd = strings.ToUpper(cmd.Cmd)
+			result, err := next(ctx, cmd)
+			result.StdOut += " intercepted"
+			return result, err
+		})
+
+		result, err := c.Run(context.Background(), "whoami")
+		suite.NoError(err)
+		suite.Equal("run intercepted", result.StdOut)
+		suite.Equal([]string{"Run WHOAMI"}, conn.calls)
+	})
+
+	suite.Run("should let interceptors skip the command", func() {
+		conn := &recordingConnection{}
+		c := Intercept(conn, func(ctx context.Context, cmd Command, next Invoker) (CmdResult, error) {
+			return CmdResult{}, fmt.Errorf("blocked %s", cmd.Cmd)
+		})
+
+		_, err := c.Stream(context.Background(), "whoami", Streams{Stdout: io.Discard})
+		suite.EqualError(err, "blocked whoami")
+		suite.Empty(conn.calls)
+	})
+
+	suite.Run("should retry with the retry interceptor", func() {
+		conn := &flakyConnection{errs: []error{errTestTransient}}
+		c := Intercept(conn, RetryInterceptor(testRetryPolicy))
+
+		_, err := c.RunWithPowershell(context.Background(), "whoami")
+		suite.NoError(err)
+		suite.Equal(2, conn.calls)
+	})
+}
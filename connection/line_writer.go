@@ -0,0 +1,48 @@
+package connection
+
+import (
+	"bytes"
+	"strings"
+)
+
+// LineWriter is an io.Writer that calls a function for every line written to it.
+// It can be used as a writer of Streams to process the output of a command line by line.
+// The line endings, including a carriage return, are removed from the lines.
+// A LineWriter must not be written to concurrently.
+type LineWriter struct {
+	fn  func(line string)
+	buf []byte
+}
+
+// NewLineWriter returns a new LineWriter that calls fn for every line.
+func NewLineWriter(fn func(line string)) *LineWriter {
+	return &LineWriter{fn: fn}
+}
+
+// Write calls the function of the LineWriter for every complete line in p.
+// An incomplete line is buffered until the next write or until the LineWriter is closed.
+func (w *LineWriter) Write(p []byte) (int, error) {
+	w.buf = append(w.buf, p...)
+
+	for {
+		i := bytes.IndexByte(w.buf, '\n')
+		if i < 0 {
+			break
+		}
+
+		w.fn(strings.TrimSuffix(string(w.buf[:i]), "\r"))
+		w.buf = w.buf[i+1:]
+	}
+
+	return len(p), nil
+}
+
+// Close calls the function of the LineWriter for the remaining incomplete line.
+func (w *LineWriter) Close() error {
+	if len(w.buf) > 0 {
+		w.fn(strings.TrimSuffix(string(w.buf), "\r"))
+		w.buf = nil
+	}
+
+	return nil
+}
@@ -0,0 +1,47 @@
+package connection
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+// Unit test suite for the connection utilities.
+type ConnectionUnitTestSuite struct {
+	suite.Suite
+}
+
+func TestConnectionUnitTestSuite(t *testing.T) {
+	suite.Run(t, &ConnectionUnitTestSuite{})
+}
+
+func (suite *ConnectionUnitTestSuite) TestLineWriter() {
+	suite.T().Parallel()
+
+	tcs := []struct {
+		description   string
+		writes        []string
+		expectedLines []string
+	}{
+		{"single line", []string{"line\n"}, []string{"line"}},
+		{"crlf line endings", []string{"line1\r\nline2\r\n"}, []string{"line1", "line2"}},
+		{"line split across writes", []string{"li", "ne1\nline", "2\n"}, []string{"line1", "line2"}},
+		{"incomplete last line", []string{"line1\nline2"}, []string{"line1", "line2"}},
+		{"empty lines", []string{"\n\n"}, []string{"", ""}},
+		{"no output", nil, nil},
+	}
+
+	for _, tc := range tcs {
+		suite.T().Logf("test case: %s", tc.description)
+
+		var lines []string
+		w := NewLineWriter(func(line string) { lines = append(lines, line) })
+		for _, s := range tc.writes {
+			n, err := w.Write([]byte(s))
+			suite.Require().NoError(err)
+			suite.Equal(len(s), n)
+		}
+		suite.Require().NoError(w.Close())
+		suite.Equal(tc.expectedLines, lines)
+	}
+}
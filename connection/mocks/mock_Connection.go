@@ -182,6 +182,122 @@ func (_c *MockConnection_RunWithPowershell_Call) RunAndReturn(run func(context.C
 	return _c
 }
 
+// Stream provides a mock function with given fields: ctx, cmd, streams
+func (_m *MockConnection) Stream(ctx context.Context, cmd string, streams connection.Streams) (int, error) {
+	ret := _m.Called(ctx, cmd, streams)
+
+	if len(ret) == 0 {
+		panic("no return value specified for Stream")
+	}
+
+	var r0 int
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, connection.Streams) (int, error)); ok {
+		return rf(ctx, cmd, streams)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string, connection.Streams) int); ok {
+		r0 = rf(ctx, cmd, streams)
+	} else {
+		r0 = ret.Get(0).(int)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string, connection.Streams) error); ok {
+		r1 = rf(ctx, cmd, streams)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// MockConnection_Stream_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'Stream'
+type MockConnection_Stream_Call struct {
+	*mock.Call
+}
+
+// Stream is a helper method to define mock.On call
+//   - ctx context.Context
+//   - cmd string
+//   - streams connection.Streams
+func (_e *MockConnection_Expecter) Stream(ctx interface{}, cmd interface{}, streams interface{}) *MockConnection_Stream_Call {
+	return &MockConnection_Stream_Call{Call: _e.mock.On("Stream", ctx, cmd, streams)}
+}
+
+func (_c *MockConnection_Stream_Call) Run(run func(ctx context.Context, cmd string, streams connection.Streams)) *MockConnection_Stream_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].(string), args[2].(connection.Streams))
+	})
+	return _c
+}
+
+func (_c *MockConnection_Stream_Call) Return(_a0 int, _a1 error) *MockConnection_Stream_Call {
+	_c.Call.Return(_a0, _a1)
+	return _c
+}
+
+func (_c *MockConnection_Stream_Call) RunAndReturn(run func(context.Context, string, connection.Streams) (int, error)) *MockConnection_Stream_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// StreamWithPowershell provides a mock function with given fields: ctx, cmd, streams
+func (_m *MockConnection) StreamWithPowershell(ctx context.Context, cmd string, streams connection.Streams) (int, error) {
+	ret := _m.Called(ctx, cmd, streams)
+
+	if len(ret) == 0 {
+		panic("no return value specified for StreamWithPowershell")
+	}
+
+	var r0 int
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, connection.Streams) (int, error)); ok {
+		return rf(ctx, cmd, streams)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string, connection.Streams) int); ok {
+		r0 = rf(ctx, cmd, streams)
+	} else {
+		r0 = ret.Get(0).(int)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string, connection.Streams) error); ok {
+		r1 = rf(ctx, cmd, streams)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// MockConnection_StreamWithPowershell_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'StreamWithPowershell'
+type MockConnection_StreamWithPowershell_Call struct {
+	*mock.Call
+}
+
+// StreamWithPowershell is a helper method to define mock.On call
+//   - ctx context.Context
+//   - cmd string
+//   - streams connection.Streams
+func (_e *MockConnection_Expecter) StreamWithPowershell(ctx interface{}, cmd interface{}, streams interface{}) *MockConnection_StreamWithPowershell_Call {
+	return &MockConnection_StreamWithPowershell_Call{Call: _e.mock.On("StreamWithPowershell", ctx, cmd, streams)}
+}
+
+func (_c *MockConnection_StreamWithPowershell_Call) Run(run func(ctx context.Context, cmd string, streams connection.Streams)) *MockConnection_StreamWithPowershell_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].(string), args[2].(connection.Streams))
+	})
+	return _c
+}
+
+func (_c *MockConnection_StreamWithPowershell_Call) Return(_a0 int, _a1 error) *MockConnection_StreamWithPowershell_Call {
+	_c.Call.Return(_a0, _a1)
+	return _c
+}
+
+func (_c *MockConnection_StreamWithPowershell_Call) RunAndReturn(run func(context.Context, string, connection.Streams) (int, error)) *MockConnection_StreamWithPowershell_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
 // NewMockConnection creates a new instance of MockConnection. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
 // The first argument is typically a *testing.T value.
 func NewMockConnection(t interface {
@@ -0,0 +1,250 @@
+package connection
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+// Default values of the PoolConfig.
+const (
+	defaultPoolSize            = 4
+	defaultHealthCheckInterval = time.Minute
+)
+
+// errPoolClosed is returned by the commands of a closed Pool.
+var errPoolClosed = errors.New("connection: the pool is closed")
+
+// PoolConfig represents the configuration of a Pool.
+type PoolConfig struct {
+	// Size is the maximum number of connections and therefore the maximum number of concurrent commands.
+	// Further commands wait until a connection is free or their context is done.
+	// Defaults to 4.
+	Size int
+
+	// HealthCheckInterval is the time a connection may be idle before it is health-checked on its next use.
+	// Defaults to 1 minute.
+	HealthCheckInterval time.Duration
+
+	// HealthCheck checks whether an idle connection is still usable.
+	// Unhealthy connections are closed and replaced by a new connection.
+	// Defaults to running "$null" via PowerShell.
+	HealthCheck func(ctx context.Context, conn Connection) error
+}
+
+// defaults sets the default values of the PoolConfig.
+func (config *PoolConfig) defaults() {
+	if config.Size == 0 {
+		config.Size = defaultPoolSize
+	}
+	if config.HealthCheckInterval == 0 {
+		config.HealthCheckInterval = defaultHealthCheckInterval
+	}
+	if config.HealthCheck == nil {
+		config.HealthCheck = healthCheck
+	}
+}
+
+// validate validates the PoolConfig.
+func (config *PoolConfig) validate() error {
+	if config.Size < 0 {
+		return fmt.Errorf("connection: pool size must not be negative, got %d", config.Size)
+	}
+	if config.HealthCheckInterval < 0 {
+		return fmt.Errorf("connection: health check interval must not be negative, got %s", config.HealthCheckInterval)
+	}
+	return nil
+}
+
+// healthCheck runs a PowerShell command that does nothing on the connection.
+// Unlike "exit", "$null" does not end the PowerShell session of a persistent connection like an ssh.PowershellConnection.
+func healthCheck(ctx context.Context, conn Connection) error {
+	_, err := conn.RunWithPowershell(ctx, "$null")
+	return err
+}
+
+// Pool is a Connection that runs commands concurrently on multiple underlying connections.
+// Connections are opened on demand with the dial function up to the size of the pool and kept open for reuse.
+// A connection that fails with a transient error, see IsTransient, is closed instead of being reused.
+// A Pool is safe for concurrent use.
+type Pool struct {
+	dial   func() (Connection, error)
+	config PoolConfig
+
+	// sem limits the number of connections in use.
+	sem chan struct{}
+
+	mu     sync.Mutex
+	idle   []*pooledConn
+	closed bool
+}
+
+// pooledConn is a connection of a Pool.
+type pooledConn struct {
+	conn     Connection
+	lastUsed time.Time
+}
+
+// NewPool returns a new Pool that opens its connections with the dial function.
+// No connection is opened before the first command.
+func NewPool(dial func() (Connection, error), config PoolConfig) (*Pool, error) {
+	if dial == nil {
+		return nil, errors.New("connection: pool dial function must not be nil")
+	}
+
+	config.defaults()
+	if err := config.validate(); err != nil {
+		return nil, err
+	}
+
+	return &Pool{
+		dial:   dial,
+		config: config,
+		sem:    make(chan struct{}, config.Size),
+	}, nil
+}
+
+// Run runs a command on a connection of the pool.
+func (p *Pool) Run(ctx context.Context, cmd string) (CmdResult, error) {
+	var result CmdResult
+	err := p.with(ctx, func(conn Connection) error {
+		var err error
+		result, err = conn.Run(ctx, cmd)
+		return err
+	})
+	return result, err
+}
+
+// RunWithPowershell runs a command via Powershell on a connection of the pool.
+func (p *Pool) RunWithPowershell(ctx context.Context, cmd string) (CmdResult, error) {
+	var result CmdResult
+	err := p.with(ctx, func(conn Connection) error {
+		var err error
+		result, err = conn.RunWithPowershell(ctx, cmd)
+		return err
+	})
+	return result, err
+}
+
+// Stream runs a command on a connection of the pool and writes its output to the streams.
+func (p *Pool) Stream(ctx context.Context, cmd string, streams Streams) (int, error) {
+	var exitCode int
+	err := p.with(ctx, func(conn Connection) error {
+		var err error
+		exitCode, err = conn.Stream(ctx, cmd, streams)
+		return err
+	})
+	return exitCode, err
+}
+
+// StreamWithPowershell runs a command via Powershell on a connection of the pool and writes its output to the streams.
+func (p *Pool) StreamWithPowershell(ctx context.Context, cmd string, streams Streams) (int, error) {
+	var exitCode int
+	err := p.with(ctx, func(conn Connection) error {
+		var err error
+		exitCode, err = conn.StreamWithPowershell(ctx, cmd, streams)
+		return err
+	})
+	return exitCode, err
+}
+
+// Upload writes the content of r to the remote file using a connection of the pool.
+func (p *Pool) Upload(ctx context.Context, r io.Reader, remotePath string) error {
+	return p.with(ctx, func(conn Connection) error {
+		return Upload(ctx, conn, r, remotePath)
+	})
+}
+
+// Download writes the content of the remote file to w using a connection of the pool.
+func (p *Pool) Download(ctx context.Context, remotePath string, w io.Writer) error {
+	return p.with(ctx, func(conn Connection) error {
+		return Download(ctx, conn, remotePath, w)
+	})
+}
+
+// Close closes the idle connections of the pool.
+// Connections in use are closed as soon as their command returned.
+func (p *Pool) Close() error {
+	p.mu.Lock()
+	idle := p.idle
+	p.idle = nil
+	p.closed = true
+	p.mu.Unlock()
+
+	var errs []error
+	for _, pc := range idle {
+		errs = append(errs, pc.conn.Close())
+	}
+	return errors.Join(errs...)
+}
+
+// with runs fn with a connection of the pool.
+func (p *Pool) with(ctx context.Context, fn func(conn Connection) error) error {
+	pc, err := p.acquire(ctx)
+	if err != nil {
+		return err
+	}
+
+	err = fn(pc.conn)
+	p.release(pc, err)
+	return err
+}
+
+// acquire waits for a free slot and returns an idle connection or a new one.
+func (p *Pool) acquire(ctx context.Context) (*pooledConn, error) {
+	select {
+	case p.sem <- struct{}{}:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+
+	for {
+		p.mu.Lock()
+		if p.closed {
+			p.mu.Unlock()
+			<-p.sem
+			return nil, errPoolClosed
+		}
+
+		if n := len(p.idle); n > 0 {
+			pc := p.idle[n-1]
+			p.idle = p.idle[:n-1]
+			p.mu.Unlock()
+
+			if time.Since(pc.lastUsed) >= p.config.HealthCheckInterval {
+				if err := p.config.HealthCheck(ctx, pc.conn); err != nil {
+					_ = pc.conn.Close()
+					continue
+				}
+			}
+			return pc, nil
+		}
+		p.mu.Unlock()
+
+		conn, err := p.dial()
+		if err != nil {
+			<-p.sem
+			return nil, err
+		}
+		return &pooledConn{conn: conn}, nil
+	}
+}
+
+// release returns the connection to the pool or closes it if the pool is closed or the command failed with a transient error.
+func (p *Pool) release(pc *pooledConn, err error) {
+	defer func() { <-p.sem }()
+
+	p.mu.Lock()
+	if p.closed || IsTransient(err) {
+		p.mu.Unlock()
+		_ = pc.conn.Close()
+		return
+	}
+
+	pc.lastUsed = time.Now()
+	p.idle = append(p.idle, pc)
+	p.mu.Unlock()
+}
@@ -0,0 +1,282 @@
+package connection
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+)
+
+// testPool counts the connections it dials and the commands running concurrently.
+type testPool struct {
+	mu      sync.Mutex
+	conns   []*testPoolConn
+	dialErr error
+
+	running    atomic.Int32
+	maxRunning atomic.Int32
+
+	// release blocks the commands until it is closed, if it is not nil.
+	release chan struct{}
+}
+
+func (p *testPool) dial() (Connection, error) {
+	if p.dialErr != nil {
+		return nil, p.dialErr
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	conn := &testPoolConn{pool: p}
+	p.conns = append(p.conns, conn)
+	return conn, nil
+}
+
+func (p *testPool) dialed() []*testPoolConn {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]*testPoolConn(nil), p.conns...)
+}
+
+// testPoolConn is a Connection of a testPool.
+type testPoolConn struct {
+	pool   *testPool
+	err    error
+	calls  atomic.Int32
+	closed atomic.Bool
+}
+
+func (c *testPoolConn) Run(ctx context.Context, cmd string) (CmdResult, error) {
+	c.calls.Add(1)
+
+	n := c.pool.running.Add(1)
+	defer c.pool.running.Add(-1)
+	for {
+		m := c.pool.maxRunning.Load()
+		if n <= m || c.pool.maxRunning.CompareAndSwap(m, n) {
+			break
+		}
+	}
+
+	if c.pool.release != nil {
+		<-c.pool.release
+	}
+	return CmdResult{StdOut: cmd}, c.err
+}
+
+func (c *testPoolConn) RunWithPowershell(ctx context.Context, cmd string) (CmdResult, error) {
+	return c.Run(ctx, cmd)
+}
+
+func (c *testPoolConn) Stream(ctx context.Context, cmd string, streams Streams) (int, error) {
+	_, err := c.Run(ctx, cmd)
+	return 0, err
+}
+
+func (c *testPoolConn) StreamWithPowershell(ctx context.Context, cmd string, streams Streams) (int, error) {
+	return c.Stream(ctx, cmd, streams)
+}
+
+func (c *testPoolConn) Close() error {
+	c.closed.Store(true)
+	return nil
+}
+
+func (suite *ConnectionUnitTestSuite) TestNewPool() {
+	suite.T().Parallel()
+
+	suite.Run("should set the default values", func() {
+		p, err := NewPool((&testPool{}).dial, PoolConfig{})
+		suite.Require().NoError(err)
+		suite.Equal(defaultPoolSize, p.config.Size)
+		suite.Equal(defaultHealthCheckInterval, p.config.HealthCheckInterval)
+		suite.NotNil(p.config.HealthCheck)
+	})
+
+	suite.Run("should return an error for an invalid configuration", func() {
+		_, err := NewPool(nil, PoolConfig{})
+		suite.EqualError(err, "connection: pool dial function must not be nil")
+
+		_, err = NewPool((&testPool{}).dial, PoolConfig{Size: -1})
+		suite.EqualError(err, "connection: pool size must not be negative, got -1")
+
+		_, err = NewPool((&testPool{}).dial, PoolConfig{HealthCheckInterval: -time.Second})
+		suite.EqualError(err, "connection: health check interval must not be negative, got -1s")
+	})
+}
+
+func (suite *ConnectionUnitTestSuite) TestPool() {
+	suite.T().Parallel()
+
+	suite.Run("should reuse idle connections", func() {
+		tp := &testPool{}
+		p, err := NewPool(tp.dial, PoolConfig{Size: 2})
+		suite.Require().NoError(err)
+
+		for range 3 {
+			result, err := p.Run(context.Background(), "whoami")
+			suite.NoError(err)
+			suite.Equal("whoami", result.StdOut)
+		}
+		suite.Len(tp.dialed(), 1)
+	})
+
+	suite.Run("should limit the concurrent commands to the size of the pool", func() {
+		tp := &testPool{release: make(chan struct{})}
+		p, err := NewPool(tp.dial, PoolConfig{Size: 3})
+		suite.Require().NoError(err)
+
+		var wg sync.WaitGroup
+		for range 10 {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				_, err := p.RunWithPowershell(context.Background(), "Get-DnsServerResourceRecord")
+				suite.NoError(err)
+			}()
+		}
+
+		suite.Eventually(func() bool { return tp.running.Load() == 3 }, time.Second, time.Millisecond)
+		close(tp.release)
+		wg.Wait()
+
+		suite.Equal(int32(3), tp.maxRunning.Load())
+		suite.Len(tp.dialed(), 3)
+	})
+
+	suite.Run("should return if the context is done while waiting for a connection", func() {
+		tp := &testPool{release: make(chan struct{})}
+		defer close(tp.release)
+		p, err := NewPool(tp.dial, PoolConfig{Size: 1})
+		suite.Require().NoError(err)
+
+		go func() { _, _ = p.Run(context.Background(), "blocking") }()
+		suite.Eventually(func() bool { return tp.running.Load() == 1 }, time.Second, time.Millisecond)
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+		_, err = p.Stream(ctx, "waiting", Streams{Stdout: io.Discard})
+		suite.ErrorIs(err, context.DeadlineExceeded)
+	})
+
+	suite.Run("should replace idle connections that fail the health check", func() {
+		tp := &testPool{}
+		p, err := NewPool(tp.dial, PoolConfig{
+			HealthCheckInterval: time.Nanosecond,
+			HealthCheck: func(ctx context.Context, conn Connection) error {
+				return errors.New("unhealthy")
+			},
+		})
+		suite.Require().NoError(err)
+
+		_, err = p.Run(context.Background(), "whoami")
+		suite.NoError(err)
+		_, err = p.Run(context.Background(), "whoami")
+		suite.NoError(err)
+
+		conns := tp.dialed()
+		suite.Require().Len(conns, 2)
+		suite.True(conns[0].closed.Load())
+		suite.False(conns[1].closed.Load())
+	})
+
+	suite.Run("should not health-check recently used connections", func() {
+		tp := &testPool{}
+		var checks atomic.Int32
+		p, err := NewPool(tp.dial, PoolConfig{
+			HealthCheck: func(ctx context.Context, conn Connection) error {
+				checks.Add(1)
+				return nil
+			},
+		})
+		suite.Require().NoError(err)
+
+		for range 2 {
+			_, err = p.Run(context.Background(), "whoami")
+			suite.NoError(err)
+		}
+		suite.Equal(int32(0), checks.Load())
+		suite.Len(tp.dialed(), 1)
+	})
+
+	suite.Run("should health-check with a command by default", func() {
+		tp := &testPool{}
+		p, err := NewPool(tp.dial, PoolConfig{HealthCheckInterval: time.Nanosecond})
+		suite.Require().NoError(err)
+
+		_, err = p.Run(context.Background(), "whoami")
+		suite.NoError(err)
+		_, err = p.Run(context.Background(), "whoami")
+		suite.NoError(err)
+
+		conns := tp.dialed()
+		suite.Require().Len(conns, 1)
+		suite.Equal(int32(3), conns[0].calls.Load())
+	})
+
+	suite.Run("should close connections that failed with a transient error", func() {
+		tp := &testPool{}
+		p, err := NewPool(tp.dial, PoolConfig{})
+		suite.Require().NoError(err)
+
+		_, err = p.Run(context.Background(), "whoami")
+		suite.NoError(err)
+		conns := tp.dialed()
+		conns[0].err = &net.OpError{Op: "read", Net: "tcp", Err: syscall.ECONNRESET}
+
+		_, err = p.Run(context.Background(), "whoami")
+		suite.ErrorIs(err, syscall.ECONNRESET)
+		suite.True(conns[0].closed.Load())
+
+		_, err = p.Run(context.Background(), "whoami")
+		suite.NoError(err)
+		suite.Len(tp.dialed(), 2)
+	})
+
+	suite.Run("should return the dial error", func() {
+		tp := &testPool{dialErr: errors.New("dial failed")}
+		p, err := NewPool(tp.dial, PoolConfig{Size: 1})
+		suite.Require().NoError(err)
+
+		for range 2 {
+			_, err = p.Run(context.Background(), "whoami")
+			suite.EqualError(err, "dial failed")
+		}
+	})
+
+	suite.Run("should close all connections", func() {
+		tp := &testPool{release: make(chan struct{})}
+		p, err := NewPool(tp.dial, PoolConfig{Size: 2})
+		suite.Require().NoError(err)
+
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			_, _ = p.Run(context.Background(), "in use")
+		}()
+		suite.Eventually(func() bool { return tp.running.Load() == 1 }, time.Second, time.Millisecond)
+
+		// Add an idle connection next to the one in use.
+		idle, err := p.dial()
+		suite.Require().NoError(err)
+		p.sem <- struct{}{}
+		p.release(&pooledConn{conn: idle}, nil)
+
+		suite.NoError(p.Close())
+		conns := tp.dialed()
+		suite.Require().Len(conns, 2)
+		suite.False(conns[0].closed.Load())
+		suite.True(conns[1].closed.Load())
+
+		close(tp.release)
+		<-done
+		suite.True(conns[0].closed.Load())
+
+		_, err = p.Run(context.Background(), "whoami")
+		suite.ErrorIs(err, errPoolClosed)
+	})
+}
@@ -0,0 +1,329 @@
+package connection
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/d-strobel/gowindows/parsing"
+)
+
+// ErrInteractionNotFound is returned by a ReplayConnection for a command that was not recorded.
+var ErrInteractionNotFound = errors.New("connection: no recorded interaction for the command")
+
+// scrubbed is the placeholder of a secret in a recording.
+const scrubbed = "***"
+
+// Interaction is a command and its result as recorded by a RecordingConnection.
+// The standard input of a command is not recorded.
+type Interaction struct {
+	// Cmd is the command line with redacted sensitive values and scrubbed secrets.
+	Cmd string `json:"cmd"`
+
+	// Powershell reports whether the command ran via Powershell.
+	Powershell bool `json:"powershell,omitempty"`
+
+	// Stream reports whether the command ran with Stream or StreamWithPowershell.
+	Stream bool `json:"stream,omitempty"`
+
+	StdOut   string `json:"stdout,omitempty"`
+	StdErr   string `json:"stderr,omitempty"`
+	ExitCode int    `json:"exitCode,omitempty"`
+
+	// Error is the message of the error returned by the connection.
+	Error string `json:"error,omitempty"`
+}
+
+// matches reports whether the interaction was recorded for the command with the scrubbed command line.
+func (i Interaction) matches(cmd Command, scrubbedCmd string) bool {
+	return i.Cmd == scrubbedCmd && i.Powershell == cmd.Powershell && i.Stream == (cmd.Streams != nil)
+}
+
+// scrubCmd returns the command line with redacted sensitive values and without the secrets.
+func scrubCmd(cmd string, secrets []string) string {
+	return scrub(parsing.RedactPwshCmd(cmd), secrets)
+}
+
+// RecordingConnection is a Connection that records the commands of another connection and their results.
+// The recorded interactions are written to a fixture file on Close, which can be served by a ReplayConnection.
+type RecordingConnection struct {
+	conn    Connection
+	path    string
+	secrets []string
+
+	mu           sync.Mutex
+	interactions []Interaction
+}
+
+// NewRecordingConnection returns a new RecordingConnection that runs the commands on conn and records them to the fixture file at path.
+// Sensitive values of the commands, see parsing.PwshSensitive, are redacted from the commands and scrubbed from the outputs.
+// The secrets, e.g. the password of the connection, are scrubbed from the commands and the outputs.
+func NewRecordingConnection(conn Connection, path string, secrets ...string) *RecordingConnection {
+	c := &RecordingConnection{path: path, secrets: secrets}
+	c.conn = Intercept(conn, c.record)
+	return c
+}
+
+// Run runs a command and records it.
+func (c *RecordingConnection) Run(ctx context.Context, cmd string) (CmdResult, error) {
+	return c.conn.Run(ctx, cmd)
+}
+
+// RunWithPowershell runs a command via Powershell and records it.
+func (c *RecordingConnection) RunWithPowershell(ctx context.Context, cmd string) (CmdResult, error) {
+	return c.conn.RunWithPowershell(ctx, cmd)
+}
+
+// Stream runs a command and records it together with the output that was written to the streams.
+func (c *RecordingConnection) Stream(ctx context.Context, cmd string, streams Streams) (int, error) {
+	return c.conn.Stream(ctx, cmd, streams)
+}
+
+// StreamWithPowershell runs a command via Powershell and records it together with the output that was written to the streams.
+func (c *RecordingConnection) StreamWithPowershell(ctx context.Context, cmd string, streams Streams) (int, error) {
+	return c.conn.StreamWithPowershell(ctx, cmd, streams)
+}
+
+// Close writes the recorded interactions to the fixture file and closes the underlying connection.
+// An existing fixture file is overwritten.
+func (c *RecordingConnection) Close() error {
+	return errors.Join(c.save(), c.conn.Close())
+}
+
+// Interactions returns the interactions that were recorded so far.
+func (c *RecordingConnection) Interactions() []Interaction {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return append([]Interaction(nil), c.interactions...)
+}
+
+// record is the Interceptor that records the commands of the connection.
+func (c *RecordingConnection) record(ctx context.Context, cmd Command, next Invoker) (CmdResult, error) {
+	var stdout, stderr bytes.Buffer
+	if cmd.Streams != nil {
+		streams := *cmd.Streams
+		streams.Stdout = io.MultiWriter(orDiscard(streams.Stdout), &stdout)
+		streams.Stderr = io.MultiWriter(orDiscard(streams.Stderr), &stderr)
+		cmd.Streams = &streams
+	}
+
+	result, err := next(ctx, cmd)
+
+	// Commands canceled by the caller are not part of the recording.
+	if ctx.Err() != nil {
+		return result, err
+	}
+
+	i := Interaction{
+		Cmd:        scrubCmd(cmd.Cmd, c.secrets),
+		Powershell: cmd.Powershell,
+		Stream:     cmd.Streams != nil,
+		StdOut:     result.StdOut,
+		StdErr:     result.StdErr,
+		ExitCode:   result.ExitCode,
+	}
+	if cmd.Streams != nil {
+		i.StdOut, i.StdErr = stdout.String(), stderr.String()
+	}
+	if err != nil {
+		i.Error = err.Error()
+	}
+
+	secrets := append(parsing.PwshSensitiveValues(cmd.Cmd), c.secrets...)
+	i.StdOut = scrub(i.StdOut, secrets)
+	i.StdErr = scrub(i.StdErr, secrets)
+	i.Error = scrub(i.Error, secrets)
+
+	c.mu.Lock()
+	c.interactions = append(c.interactions, i)
+	c.mu.Unlock()
+
+	return result, err
+}
+
+// save writes the recorded interactions to the fixture file.
+func (c *RecordingConnection) save() error {
+	// PowerShell commands contain characters like < and >, which are kept readable in the fixture file.
+	var b bytes.Buffer
+	enc := json.NewEncoder(&b)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(c.Interactions()); err != nil {
+		return fmt.Errorf("connection: failed to encode the recording: %w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(c.path), 0o755); err != nil {
+		return fmt.Errorf("connection: failed to create the directory of the recording: %w", err)
+	}
+
+	if err := os.WriteFile(c.path, b.Bytes(), 0o644); err != nil {
+		return fmt.Errorf("connection: failed to write the recording: %w", err)
+	}
+
+	return nil
+}
+
+// ReplayConnection is a Connection that serves the interactions of a fixture file written by a RecordingConnection.
+// Every interaction is served once, identical commands get the results in the order they were recorded.
+type ReplayConnection struct {
+	secrets []string
+
+	mu           sync.Mutex
+	interactions []Interaction
+	replayed     []bool
+}
+
+// NewReplayConnection returns a new ReplayConnection that serves the interactions of the fixture file at path.
+// The secrets are scrubbed from the commands before they are matched with the recorded commands,
+// so commands that contain secrets need the secrets of the RecordingConnection.
+func NewReplayConnection(path string, secrets ...string) (*ReplayConnection, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("connection: failed to read the recording: %w", err)
+	}
+
+	var interactions []Interaction
+	if err := json.Unmarshal(b, &interactions); err != nil {
+		return nil, fmt.Errorf("connection: failed to decode the recording %s: %w", path, err)
+	}
+
+	return NewReplayConnectionFromInteractions(interactions, secrets...), nil
+}
+
+// NewReplayConnectionFromInteractions returns a new ReplayConnection that serves the interactions.
+func NewReplayConnectionFromInteractions(interactions []Interaction, secrets ...string) *ReplayConnection {
+	return &ReplayConnection{
+		secrets:      secrets,
+		interactions: interactions,
+		replayed:     make([]bool, len(interactions)),
+	}
+}
+
+// Run returns the recorded result of a command.
+func (c *ReplayConnection) Run(ctx context.Context, cmd string) (CmdResult, error) {
+	return c.replay(ctx, Command{Cmd: cmd})
+}
+
+// RunWithPowershell returns the recorded result of a command that ran via Powershell.
+func (c *ReplayConnection) RunWithPowershell(ctx context.Context, cmd string) (CmdResult, error) {
+	return c.replay(ctx, Command{Cmd: cmd, Powershell: true})
+}
+
+// Stream writes the recorded output of a command to the streams and returns its exit code.
+// The standard input is read until EOF and discarded.
+func (c *ReplayConnection) Stream(ctx context.Context, cmd string, streams Streams) (int, error) {
+	result, err := c.replay(ctx, Command{Cmd: cmd, Streams: &streams})
+	return result.ExitCode, err
+}
+
+// StreamWithPowershell writes the recorded output of a command that ran via Powershell to the streams and returns its exit code.
+// The standard input is read until EOF and discarded.
+func (c *ReplayConnection) StreamWithPowershell(ctx context.Context, cmd string, streams Streams) (int, error) {
+	result, err := c.replay(ctx, Command{Cmd: cmd, Powershell: true, Streams: &streams})
+	return result.ExitCode, err
+}
+
+// Close has no effect.
+func (c *ReplayConnection) Close() error {
+	return nil
+}
+
+// Unreplayed returns the recorded interactions that were not served yet.
+// Tests can use it to detect recordings that are out of date.
+func (c *ReplayConnection) Unreplayed() []Interaction {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var interactions []Interaction
+	for i := range c.interactions {
+		if !c.replayed[i] {
+			interactions = append(interactions, c.interactions[i])
+		}
+	}
+	return interactions
+}
+
+// replay serves the first interaction of the command that was not served yet.
+func (c *ReplayConnection) replay(ctx context.Context, cmd Command) (CmdResult, error) {
+	if err := ctx.Err(); err != nil {
+		return CmdResult{}, err
+	}
+
+	scrubbedCmd := scrubCmd(cmd.Cmd, c.secrets)
+	i, ok := c.next(cmd, scrubbedCmd)
+	if !ok {
+		return CmdResult{}, fmt.Errorf("%w: %s", ErrInteractionNotFound, scrubbedCmd)
+	}
+
+	if cmd.Streams != nil {
+		if cmd.Streams.Stdin != nil {
+			if _, err := io.Copy(io.Discard, cmd.Streams.Stdin); err != nil {
+				return CmdResult{}, err
+			}
+		}
+		if _, err := io.WriteString(orDiscard(cmd.Streams.Stdout), i.StdOut); err != nil {
+			return CmdResult{}, err
+		}
+		if _, err := io.WriteString(orDiscard(cmd.Streams.Stderr), i.StdErr); err != nil {
+			return CmdResult{}, err
+		}
+	}
+
+	if i.Error != "" {
+		return CmdResult{}, errors.New(i.Error)
+	}
+
+	if cmd.Streams != nil {
+		return CmdResult{ExitCode: i.ExitCode}, nil
+	}
+	return CmdResult{StdOut: i.StdOut, StdErr: i.StdErr, ExitCode: i.ExitCode}, nil
+}
+
+// next marks the first interaction of the command that was not served yet as served and returns it.
+func (c *ReplayConnection) next(cmd Command, scrubbedCmd string) (Interaction, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for i := range c.interactions {
+		if !c.replayed[i] && c.interactions[i].matches(cmd, scrubbedCmd) {
+			c.replayed[i] = true
+			return c.interactions[i], true
+		}
+	}
+	return Interaction{}, false
+}
+
+// scrub replaces the secrets in s with a placeholder.
+// Secrets are also replaced in their quoted and XML escaped form, as they show up in PowerShell errors and CLIXML.
+func scrub(s string, secrets []string) string {
+	for _, secret := range secrets {
+		if secret == "" {
+			continue
+		}
+
+		var escaped strings.Builder
+		_ = xml.EscapeText(&escaped, []byte(secret))
+
+		s = strings.ReplaceAll(s, parsing.PwshQuote(secret), scrubbed)
+		s = strings.ReplaceAll(s, escaped.String(), scrubbed)
+		s = strings.ReplaceAll(s, secret, scrubbed)
+	}
+	return s
+}
+
+// orDiscard returns w or io.Discard if w is nil.
+func orDiscard(w io.Writer) io.Writer {
+	if w == nil {
+		return io.Discard
+	}
+	return w
+}
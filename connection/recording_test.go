@@ -0,0 +1,147 @@
+package connection
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// echoConnection is a Connection that echoes the commands to stdout and fails for the command "fail".
+type echoConnection struct {
+	closed bool
+}
+
+func (c *echoConnection) Run(ctx context.Context, cmd string) (CmdResult, error) {
+	return c.RunWithPowershell(ctx, cmd)
+}
+
+func (c *echoConnection) RunWithPowershell(ctx context.Context, cmd string) (CmdResult, error) {
+	if cmd == "fail" {
+		return CmdResult{}, errors.New("connection reset")
+	}
+	return CmdResult{StdOut: cmd, StdErr: "error: " + cmd, ExitCode: len(cmd)}, nil
+}
+
+func (c *echoConnection) Stream(ctx context.Context, cmd string, streams Streams) (int, error) {
+	return c.StreamWithPowershell(ctx, cmd, streams)
+}
+
+func (c *echoConnection) StreamWithPowershell(ctx context.Context, cmd string, streams Streams) (int, error) {
+	input, _ := io.ReadAll(streams.Stdin)
+	fmt.Fprintf(streams.Stdout, "%s %s", cmd, input)
+	return 0, nil
+}
+
+func (c *echoConnection) Close() error {
+	c.closed = true
+	return nil
+}
+
+func (suite *ConnectionUnitTestSuite) TestRecordAndReplay() {
+	suite.T().Parallel()
+
+	suite.Run("should replay the recorded interactions", func() {
+		path := filepath.Join(suite.T().TempDir(), "testdata", "recording.json")
+		conn := &echoConnection{}
+		recorder := NewRecordingConnection(conn, path)
+
+		result, err := recorder.RunWithPowershell(context.Background(), "Get-LocalUser")
+		suite.Require().NoError(err)
+		suite.Equal(CmdResult{StdOut: "Get-LocalUser", StdErr: "error: Get-LocalUser", ExitCode: 13}, result)
+
+		_, err = recorder.Run(context.Background(), "fail")
+		suite.EqualError(err, "connection reset")
+
+		exitCode, err := recorder.Stream(context.Background(), "Set-Content", Streams{Stdin: strings.NewReader("input"), Stdout: io.Discard})
+		suite.Require().NoError(err)
+		suite.Equal(0, exitCode)
+
+		suite.Require().NoError(recorder.Close())
+		suite.True(conn.closed)
+
+		replay, err := NewReplayConnection(path)
+		suite.Require().NoError(err)
+
+		result, err = replay.RunWithPowershell(context.Background(), "Get-LocalUser")
+		suite.Require().NoError(err)
+		suite.Equal(CmdResult{StdOut: "Get-LocalUser", StdErr: "error: Get-LocalUser", ExitCode: 13}, result)
+
+		_, err = replay.Run(context.Background(), "fail")
+		suite.EqualError(err, "connection reset")
+
+		var stdout strings.Builder
+		exitCode, err = replay.Stream(context.Background(), "Set-Content", Streams{Stdin: strings.NewReader("other"), Stdout: &stdout})
+		suite.Require().NoError(err)
+		suite.Equal(0, exitCode)
+		suite.Equal("Set-Content input", stdout.String())
+
+		suite.Empty(replay.Unreplayed())
+	})
+
+	suite.Run("should serve identical commands in the recorded order", func() {
+		replay := NewReplayConnectionFromInteractions([]Interaction{
+			{Cmd: "Get-LocalUser", Powershell: true, StdOut: "first"},
+			{Cmd: "Get-LocalUser", StdOut: "run"},
+			{Cmd: "Get-LocalUser", Powershell: true, StdOut: "second"},
+		})
+
+		for _, expected := range []string{"first", "second"} {
+			result, err := replay.RunWithPowershell(context.Background(), "Get-LocalUser")
+			suite.Require().NoError(err)
+			suite.Equal(expected, result.StdOut)
+		}
+
+		_, err := replay.RunWithPowershell(context.Background(), "Get-LocalUser")
+		suite.ErrorIs(err, ErrInteractionNotFound)
+		suite.Equal([]Interaction{{Cmd: "Get-LocalUser", StdOut: "run"}}, replay.Unreplayed())
+	})
+
+	suite.Run("should scrub secrets", func() {
+		path := filepath.Join(suite.T().TempDir(), "recording.json")
+		recorder := NewRecordingConnection(&echoConnection{}, path, "Passw0rd")
+
+		cmd := "New-LocalUser -Password <#sensitive#>'it''s <secret>' -Description 'Passw0rd'"
+		_, err := recorder.RunWithPowershell(context.Background(), cmd)
+		suite.Require().NoError(err)
+		suite.Require().NoError(recorder.Close())
+
+		b, err := os.ReadFile(path)
+		suite.Require().NoError(err)
+		suite.NotContains(string(b), "secret")
+		suite.NotContains(string(b), "Passw0rd")
+
+		interactions := recorder.Interactions()
+		suite.Require().Len(interactions, 1)
+		suite.Equal("New-LocalUser -Password <#sensitive#>'***' -Description ***", interactions[0].Cmd)
+		suite.Equal("New-LocalUser -Password <#sensitive#>*** -Description ***", interactions[0].StdOut)
+
+		// The sensitive values and the secrets of the command are scrubbed before it is matched.
+		replay, err := NewReplayConnection(path, "Passw0rd")
+		suite.Require().NoError(err)
+		result, err := replay.RunWithPowershell(context.Background(), "New-LocalUser -Password <#sensitive#>'other' -Description 'Passw0rd'")
+		suite.Require().NoError(err)
+		suite.Equal(interactions[0].StdOut, result.StdOut)
+	})
+
+	suite.Run("should not record canceled commands", func() {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		recorder := NewRecordingConnection(&echoConnection{}, filepath.Join(suite.T().TempDir(), "recording.json"))
+		_, err := recorder.RunWithPowershell(ctx, "Get-LocalUser")
+		suite.NoError(err)
+		suite.Empty(recorder.Interactions())
+
+		_, err = NewReplayConnectionFromInteractions(nil).RunWithPowershell(ctx, "Get-LocalUser")
+		suite.ErrorIs(err, context.Canceled)
+	})
+
+	suite.Run("should fail for a missing recording", func() {
+		_, err := NewReplayConnection(filepath.Join(suite.T().TempDir(), "missing.json"))
+		suite.ErrorIs(err, os.ErrNotExist)
+	})
+}
@@ -0,0 +1,325 @@
+package connection
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math"
+	"math/rand/v2"
+	"net"
+	"strings"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// Default values of the RetryPolicy.
+const (
+	defaultInitialBackoff = 500 * time.Millisecond
+	defaultMaxBackoff     = 10 * time.Second
+	defaultMultiplier     = 2
+)
+
+// rejectedMessages are parts of error messages of WinRM requests that were rejected before the command started.
+// The WinRM library returns these failures as plain errors.
+var rejectedMessages = []string{
+	"http error 503",
+	"MaxShellsPerUser",
+	"MaxConcurrentOperationsPerUser",
+	"MaxProcessesPerShell",
+	"shell quota",
+}
+
+// transientMessages are parts of error messages of WinRM requests that failed on the host or a gateway.
+// The command may have run before these failures.
+var transientMessages = []string{
+	"http error 500",
+	"http error 502",
+	"http error 504",
+}
+
+// RetryPolicy defines how often and how fast a command is retried after a transient error.
+type RetryPolicy struct {
+	// MaxAttempts is the maximum number of attempts, including the first one.
+	// A value of 0 or 1 disables retries.
+	MaxAttempts int
+
+	// InitialBackoff is the time to wait before the first retry.
+	// Defaults to 500ms.
+	InitialBackoff time.Duration
+
+	// MaxBackoff is the maximum time to wait between two attempts.
+	// Defaults to 10s.
+	MaxBackoff time.Duration
+
+	// Multiplier is the factor the backoff grows by after every attempt.
+	// Defaults to 2.
+	Multiplier float64
+
+	// Jitter randomizes the backoff by up to the given fraction in both directions, e.g. 0.2 for ±20%.
+	// This spreads the retries of concurrent callers. Must be between 0 and 1.
+	Jitter float64
+
+	// Retryable reports whether an error is transient and the command should be retried.
+	// Defaults to IsRetryable, or to IsTransient for idempotent commands, see WithIdempotent.
+	Retryable func(err error) bool
+}
+
+// defaults sets the default values of the RetryPolicy.
+func (p RetryPolicy) defaults() RetryPolicy {
+	if p.InitialBackoff <= 0 {
+		p.InitialBackoff = defaultInitialBackoff
+	}
+	if p.MaxBackoff <= 0 {
+		p.MaxBackoff = defaultMaxBackoff
+	}
+	if p.Multiplier < 1 {
+		p.Multiplier = defaultMultiplier
+	}
+	p.Jitter = math.Min(math.Max(p.Jitter, 0), 1)
+	if p.Retryable == nil {
+		p.Retryable = IsRetryable
+	}
+	return p
+}
+
+// backoff returns the time to wait after the given attempt, starting with 1.
+func (p RetryPolicy) backoff(attempt int) time.Duration {
+	d := float64(p.InitialBackoff) * math.Pow(p.Multiplier, float64(attempt-1))
+	d = math.Min(d, float64(p.MaxBackoff))
+
+	if p.Jitter > 0 {
+		d *= 1 + p.Jitter*(2*rand.Float64()-1)
+	}
+
+	return time.Duration(d)
+}
+
+// retryPolicyKey is the context key of the RetryPolicy.
+type retryPolicyKey struct{}
+
+// WithRetryPolicy returns a copy of the context that carries the RetryPolicy.
+// A RetryConnection uses the policy of the context instead of its own policy,
+// so retries can be enabled or disabled for a single call.
+func WithRetryPolicy(ctx context.Context, policy RetryPolicy) context.Context {
+	return context.WithValue(ctx, retryPolicyKey{}, policy)
+}
+
+// idempotentKey is the context key that marks commands as idempotent.
+type idempotentKey struct{}
+
+// WithIdempotent returns a copy of the context that marks its commands as idempotent.
+// Idempotent commands can run twice without harm, so a RetryConnection retries them after every transient error,
+// even if the command may already have run, see IsTransient.
+func WithIdempotent(ctx context.Context) context.Context {
+	return context.WithValue(ctx, idempotentKey{}, true)
+}
+
+// isIdempotent reports whether the commands of the context are marked as idempotent.
+func isIdempotent(ctx context.Context) bool {
+	idempotent, _ := ctx.Value(idempotentKey{}).(bool)
+	return idempotent
+}
+
+// IsRetryable reports whether the error is a transport error of a command that was never sent to the remote host.
+// Failures to dial the host, failures to open an SSH channel and WinRM requests rejected by shell quotas
+// or an unavailable service are retryable.
+// Other transport errors may happen after the command has started, see IsTransient.
+func IsRetryable(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+
+	var opErr *net.OpError
+	if errors.Is(err, syscall.ECONNREFUSED) || (errors.As(err, &opErr) && opErr.Op == "dial") {
+		return true
+	}
+
+	var openChannelErr *ssh.OpenChannelError
+	if errors.As(err, &openChannelErr) {
+		return true
+	}
+
+	msg := err.Error()
+	for _, m := range rejectedMessages {
+		if strings.Contains(msg, m) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// IsTransient reports whether the error is a transient transport error.
+// In addition to the retryable errors, see IsRetryable, connection resets, broken pipes,
+// unexpected ends of the response, timeouts and WinRM requests that failed with the HTTP status 500, 502 or 504 are transient.
+// The command may have run on the remote host before these errors, so only idempotent commands should be retried.
+// Canceled contexts and errors of the commands are not transient.
+func IsTransient(err error) bool {
+	if IsRetryable(err) {
+		return true
+	}
+	if err == nil || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+
+	if errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, syscall.ECONNABORTED) ||
+		errors.Is(err, syscall.EPIPE) ||
+		errors.Is(err, io.ErrUnexpectedEOF) {
+		return true
+	}
+
+	msg := err.Error()
+	for _, m := range transientMessages {
+		if strings.Contains(msg, m) {
+			return true
+		}
+	}
+
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
+
+// RetryConnection is a Connection that retries the commands of another connection after transient errors.
+type RetryConnection struct {
+	conn Connection
+}
+
+// NewRetryConnection returns a new RetryConnection that runs the commands on conn and retries them according to the policy.
+// With a zero policy, commands are only retried if the context carries a policy, see WithRetryPolicy.
+func NewRetryConnection(conn Connection, policy RetryPolicy) *RetryConnection {
+	return &RetryConnection{conn: Intercept(conn, RetryInterceptor(policy))}
+}
+
+// Run runs a command and retries it after transient errors.
+func (c *RetryConnection) Run(ctx context.Context, cmd string) (CmdResult, error) {
+	return c.conn.Run(ctx, cmd)
+}
+
+// RunWithPowershell runs a command via Powershell and retries it after transient errors.
+func (c *RetryConnection) RunWithPowershell(ctx context.Context, cmd string) (CmdResult, error) {
+	return c.conn.RunWithPowershell(ctx, cmd)
+}
+
+// Stream runs a command and retries it after transient errors.
+// The command is not retried once it has read from stdin or written to stdout or stderr,
+// since the streams cannot be rewound.
+func (c *RetryConnection) Stream(ctx context.Context, cmd string, streams Streams) (int, error) {
+	return c.conn.Stream(ctx, cmd, streams)
+}
+
+// StreamWithPowershell runs a command via Powershell and retries it after transient errors.
+// Like Stream, the command is not retried once the streams were used.
+func (c *RetryConnection) StreamWithPowershell(ctx context.Context, cmd string, streams Streams) (int, error) {
+	return c.conn.StreamWithPowershell(ctx, cmd, streams)
+}
+
+// Close closes the underlying connection.
+func (c *RetryConnection) Close() error {
+	return c.conn.Close()
+}
+
+// Upload writes the content of r to the remote file using the underlying connection.
+// File transfers are not retried, since the reader cannot be rewound.
+func (c *RetryConnection) Upload(ctx context.Context, r io.Reader, remotePath string) error {
+	return Upload(ctx, c.conn, r, remotePath)
+}
+
+// Download writes the content of the remote file to w using the underlying connection.
+// File transfers are not retried, since the writer cannot be rewound.
+func (c *RetryConnection) Download(ctx context.Context, remotePath string, w io.Writer) error {
+	return Download(ctx, c.conn, remotePath, w)
+}
+
+// RetryInterceptor returns an Interceptor that retries commands after transient errors according to the policy.
+// The policy of the context takes precedence, see WithRetryPolicy.
+// By default, only commands that were never sent are retried, unless the context marks them as idempotent, see WithIdempotent.
+// A command with streams is not retried once it has read from stdin or written to stdout or stderr.
+func RetryInterceptor(policy RetryPolicy) Interceptor {
+	return func(ctx context.Context, cmd Command, next Invoker) (CmdResult, error) {
+		policy := policy
+		if p, ok := ctx.Value(retryPolicyKey{}).(RetryPolicy); ok {
+			policy = p
+		}
+		if policy.Retryable == nil && isIdempotent(ctx) {
+			policy.Retryable = IsTransient
+		}
+		policy = policy.defaults()
+
+		for attempt := 1; ; attempt++ {
+			var s *trackedStreams
+			attemptCmd := cmd
+			if cmd.Streams != nil {
+				s = &trackedStreams{}
+				streams := s.wrap(*cmd.Streams)
+				attemptCmd.Streams = &streams
+			}
+
+			result, err := next(ctx, attemptCmd)
+			if err == nil || (s != nil && s.used.Load()) || attempt >= policy.MaxAttempts || !policy.Retryable(err) {
+				return result, err
+			}
+
+			timer := time.NewTimer(policy.backoff(attempt))
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return result, err
+			case <-timer.C:
+			}
+		}
+	}
+}
+
+// trackedStreams records whether the streams of a command were used.
+// The streams may be used concurrently.
+type trackedStreams struct {
+	used atomic.Bool
+}
+
+// wrap returns streams that mark the trackedStreams as used on the first read or write.
+func (s *trackedStreams) wrap(streams Streams) Streams {
+	wrapped := Streams{}
+	if streams.Stdin != nil {
+		wrapped.Stdin = &trackedReader{r: streams.Stdin, used: &s.used}
+	}
+	if streams.Stdout != nil {
+		wrapped.Stdout = &trackedWriter{w: streams.Stdout, used: &s.used}
+	}
+	if streams.Stderr != nil {
+		wrapped.Stderr = &trackedWriter{w: streams.Stderr, used: &s.used}
+	}
+	return wrapped
+}
+
+// trackedReader is an io.Reader that records whether it was read from.
+type trackedReader struct {
+	r    io.Reader
+	used *atomic.Bool
+}
+
+// Read implements the io.Reader interface.
+func (r *trackedReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	if n > 0 {
+		r.used.Store(true)
+	}
+	return n, err
+}
+
+// trackedWriter is an io.Writer that records whether it was written to.
+type trackedWriter struct {
+	w    io.Writer
+	used *atomic.Bool
+}
+
+// Write implements the io.Writer interface.
+func (w *trackedWriter) Write(p []byte) (int, error) {
+	if len(p) > 0 {
+		w.used.Store(true)
+	}
+	return w.w.Write(p)
+}
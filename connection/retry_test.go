@@ -0,0 +1,309 @@
+package connection
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"syscall"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// flakyConnection is a Connection that fails with the errors in order before it succeeds.
+type flakyConnection struct {
+	errs  []error
+	calls int
+
+	// output is written to stdout by Stream before the error is returned.
+	output string
+}
+
+func (c *flakyConnection) next() error {
+	c.calls++
+	if len(c.errs) == 0 {
+		return nil
+	}
+	err := c.errs[0]
+	c.errs = c.errs[1:]
+	return err
+}
+
+func (c *flakyConnection) Run(ctx context.Context, cmd string) (CmdResult, error) {
+	if err := c.next(); err != nil {
+		return CmdResult{}, err
+	}
+	return CmdResult{StdOut: cmd}, nil
+}
+
+func (c *flakyConnection) RunWithPowershell(ctx context.Context, cmd string) (CmdResult, error) {
+	return c.Run(ctx, cmd)
+}
+
+func (c *flakyConnection) Stream(ctx context.Context, cmd string, streams Streams) (int, error) {
+	if streams.Stdin != nil {
+		if _, err := io.Copy(io.Discard, streams.Stdin); err != nil {
+			return 0, err
+		}
+	}
+	if c.output != "" {
+		fmt.Fprint(streams.Stdout, c.output)
+	}
+	return 0, c.next()
+}
+
+func (c *flakyConnection) StreamWithPowershell(ctx context.Context, cmd string, streams Streams) (int, error) {
+	return c.Stream(ctx, cmd, streams)
+}
+
+func (c *flakyConnection) Close() error {
+	return nil
+}
+
+// errTestTransient is a transient error of a command that was never sent.
+var errTestTransient = &net.OpError{Op: "dial", Net: "tcp", Err: syscall.ECONNREFUSED}
+
+// errTestReset is a transient error of a command that may have run.
+var errTestReset = &net.OpError{Op: "read", Net: "tcp", Err: syscall.ECONNRESET}
+
+// testRetryPolicy retries fast to keep the tests short.
+var testRetryPolicy = RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Millisecond, MaxBackoff: 5 * time.Millisecond}
+
+func (suite *ConnectionUnitTestSuite) TestIsRetryable() {
+	suite.T().Parallel()
+
+	tcs := []struct {
+		description string
+		err         error
+		expected    bool
+	}{
+		{"connection refused", fmt.Errorf("ssh: %w", &net.OpError{Op: "dial", Net: "tcp", Err: syscall.ECONNREFUSED}), true},
+		{"dial timeout", &net.OpError{Op: "dial", Net: "tcp", Err: timeoutError{}}, true},
+		{"ssh channel open failure", &ssh.OpenChannelError{Reason: ssh.ResourceShortage, Message: "open failed"}, true},
+		{"winrm service unavailable", errors.New("http error 503: "), true},
+		{"winrm shell quota", errors.New("The WS-Management service cannot process the request. This user is allowed a maximum number of 30 concurrent shells (MaxShellsPerUser)"), true},
+		{"connection reset", &net.OpError{Op: "read", Net: "tcp", Err: syscall.ECONNRESET}, false},
+		{"broken pipe", &net.OpError{Op: "write", Net: "tcp", Err: syscall.EPIPE}, false},
+		{"unexpected EOF", fmt.Errorf("winrm: %w", io.ErrUnexpectedEOF), false},
+		{"read timeout", &net.OpError{Op: "read", Net: "tcp", Err: timeoutError{}}, false},
+		{"winrm internal server error", errors.New("http error 500: <s:Fault/>"), false},
+		{"winrm bad gateway", errors.New("http error 502: "), false},
+		{"winrm gateway timeout", errors.New("http error 504: "), false},
+		{"winrm unauthorized", errors.New("http error 401: "), false},
+		{"canceled context", fmt.Errorf("ssh: %w", context.Canceled), false},
+		{"deadline exceeded", context.DeadlineExceeded, false},
+		{"other error", errors.New("ssh: handshake failed: unable to authenticate"), false},
+		{"nil error", nil, false},
+	}
+
+	for _, tc := range tcs {
+		suite.T().Logf("test case: %s", tc.description)
+		suite.Equal(tc.expected, IsRetryable(tc.err))
+	}
+}
+
+func (suite *ConnectionUnitTestSuite) TestIsTransient() {
+	suite.T().Parallel()
+
+	tcs := []struct {
+		description string
+		err         error
+		expected    bool
+	}{
+		{"connection refused", &net.OpError{Op: "dial", Net: "tcp", Err: syscall.ECONNREFUSED}, true},
+		{"ssh channel open failure", &ssh.OpenChannelError{Reason: ssh.ResourceShortage, Message: "open failed"}, true},
+		{"connection reset", &net.OpError{Op: "read", Net: "tcp", Err: syscall.ECONNRESET}, true},
+		{"broken pipe", &net.OpError{Op: "write", Net: "tcp", Err: syscall.EPIPE}, true},
+		{"unexpected EOF", fmt.Errorf("winrm: %w", io.ErrUnexpectedEOF), true},
+		{"read timeout", &net.OpError{Op: "read", Net: "tcp", Err: timeoutError{}}, true},
+		{"winrm service unavailable", errors.New("http error 503: "), true},
+		{"winrm internal server error", errors.New("http error 500: <s:Fault/>"), true},
+		{"winrm bad gateway", errors.New("http error 502: "), true},
+		{"winrm gateway timeout", errors.New("http error 504: "), true},
+		{"winrm unauthorized", errors.New("http error 401: "), false},
+		{"canceled context", fmt.Errorf("ssh: %w", context.Canceled), false},
+		{"other error", errors.New("ssh: handshake failed: unable to authenticate"), false},
+		{"nil error", nil, false},
+	}
+
+	for _, tc := range tcs {
+		suite.T().Logf("test case: %s", tc.description)
+		suite.Equal(tc.expected, IsTransient(tc.err))
+	}
+}
+
+func (suite *ConnectionUnitTestSuite) TestRetryConnection() {
+	suite.T().Parallel()
+
+	suite.Run("should retry transient errors until the command succeeds", func() {
+		conn := &flakyConnection{errs: []error{errTestTransient, errTestTransient}}
+		c := NewRetryConnection(conn, testRetryPolicy)
+
+		result, err := c.Run(context.Background(), "whoami")
+		suite.NoError(err)
+		suite.Equal("whoami", result.StdOut)
+		suite.Equal(3, conn.calls)
+	})
+
+	suite.Run("should return the last error once the attempts are exhausted", func() {
+		conn := &flakyConnection{errs: []error{errTestTransient, errTestTransient, errTestTransient, errTestTransient}}
+		c := NewRetryConnection(conn, testRetryPolicy)
+
+		_, err := c.RunWithPowershell(context.Background(), "whoami")
+		suite.ErrorIs(err, syscall.ECONNREFUSED)
+		suite.Equal(3, conn.calls)
+	})
+
+	suite.Run("should not retry fatal errors", func() {
+		conn := &flakyConnection{errs: []error{errors.New("http error 401: ")}}
+		c := NewRetryConnection(conn, testRetryPolicy)
+
+		_, err := c.Run(context.Background(), "whoami")
+		suite.Error(err)
+		suite.Equal(1, conn.calls)
+	})
+
+	suite.Run("should not retry errors of commands that may have run", func() {
+		conn := &flakyConnection{errs: []error{errTestReset}}
+		c := NewRetryConnection(conn, testRetryPolicy)
+
+		_, err := c.Run(context.Background(), "whoami")
+		suite.ErrorIs(err, syscall.ECONNRESET)
+		suite.Equal(1, conn.calls)
+	})
+
+	suite.Run("should retry errors of idempotent commands that may have run", func() {
+		conn := &flakyConnection{errs: []error{errTestReset, errTestTransient}}
+		c := NewRetryConnection(conn, testRetryPolicy)
+
+		_, err := c.Run(WithIdempotent(context.Background()), "whoami")
+		suite.NoError(err)
+		suite.Equal(3, conn.calls)
+	})
+
+	suite.Run("should retry a server error of an idempotent read", func() {
+		conn := &flakyConnection{errs: []error{errors.New("http error 500: <s:Fault/>")}}
+		c := NewRetryConnection(conn, testRetryPolicy)
+
+		result, err := c.RunWithPowershell(WithIdempotent(context.Background()), "Get-DnsServerResourceRecord")
+		suite.NoError(err)
+		suite.Equal("Get-DnsServerResourceRecord", result.StdOut)
+		suite.Equal(2, conn.calls)
+	})
+
+	suite.Run("should not retry a server error of a write", func() {
+		conn := &flakyConnection{errs: []error{errors.New("http error 500: <s:Fault/>")}}
+		c := NewRetryConnection(conn, testRetryPolicy)
+
+		_, err := c.RunWithPowershell(context.Background(), "Add-DnsServerResourceRecordA")
+		suite.ErrorContains(err, "http error 500")
+		suite.Equal(1, conn.calls)
+	})
+
+	suite.Run("should use the classification of the policy", func() {
+		fatal := errors.New("fatal")
+		conn := &flakyConnection{errs: []error{errTestTransient}}
+		policy := testRetryPolicy
+		policy.Retryable = func(err error) bool { return errors.Is(err, fatal) }
+		c := NewRetryConnection(conn, policy)
+
+		_, err := c.Run(context.Background(), "whoami")
+		suite.ErrorIs(err, syscall.ECONNREFUSED)
+		suite.Equal(1, conn.calls)
+	})
+
+	suite.Run("should not retry without a policy", func() {
+		conn := &flakyConnection{errs: []error{errTestTransient}}
+		c := NewRetryConnection(conn, RetryPolicy{})
+
+		_, err := c.Run(context.Background(), "whoami")
+		suite.Error(err)
+		suite.Equal(1, conn.calls)
+	})
+
+	suite.Run("should use the policy of the context", func() {
+		conn := &flakyConnection{errs: []error{errTestTransient}}
+		c := NewRetryConnection(conn, RetryPolicy{})
+
+		_, err := c.Run(WithRetryPolicy(context.Background(), testRetryPolicy), "whoami")
+		suite.NoError(err)
+		suite.Equal(2, conn.calls)
+
+		conn = &flakyConnection{errs: []error{errTestTransient}}
+		c = NewRetryConnection(conn, testRetryPolicy)
+
+		_, err = c.Run(WithRetryPolicy(context.Background(), RetryPolicy{}), "whoami")
+		suite.Error(err)
+		suite.Equal(1, conn.calls)
+	})
+
+	suite.Run("should stop waiting if the context is done", func() {
+		conn := &flakyConnection{errs: []error{errTestTransient, errTestTransient}}
+		c := NewRetryConnection(conn, RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Hour})
+
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+
+		start := time.Now()
+		_, err := c.Run(ctx, "whoami")
+		suite.ErrorIs(err, syscall.ECONNREFUSED)
+		suite.Equal(1, conn.calls)
+		suite.Less(time.Since(start), 5*time.Second)
+	})
+
+	suite.Run("should retry a stream that was not used", func() {
+		conn := &flakyConnection{errs: []error{errTestTransient}}
+		c := NewRetryConnection(conn, testRetryPolicy)
+
+		_, err := c.Stream(context.Background(), "whoami", Streams{Stdout: io.Discard})
+		suite.NoError(err)
+		suite.Equal(2, conn.calls)
+	})
+
+	suite.Run("should not retry a stream that was written to", func() {
+		conn := &flakyConnection{errs: []error{errTestTransient}, output: "partial"}
+		c := NewRetryConnection(conn, testRetryPolicy)
+
+		var stdout strings.Builder
+		_, err := c.StreamWithPowershell(context.Background(), "whoami", Streams{Stdout: &stdout})
+		suite.ErrorIs(err, syscall.ECONNREFUSED)
+		suite.Equal(1, conn.calls)
+		suite.Equal("partial", stdout.String())
+	})
+
+	suite.Run("should not retry a stream whose stdin was read", func() {
+		conn := &flakyConnection{errs: []error{errTestTransient}}
+		c := NewRetryConnection(conn, testRetryPolicy)
+
+		_, err := c.Stream(context.Background(), "whoami", Streams{Stdin: strings.NewReader("input")})
+		suite.ErrorIs(err, syscall.ECONNREFUSED)
+		suite.Equal(1, conn.calls)
+	})
+}
+
+func (suite *ConnectionUnitTestSuite) TestRetryPolicyBackoff() {
+	suite.T().Parallel()
+
+	policy := RetryPolicy{InitialBackoff: time.Second, MaxBackoff: 5 * time.Second}.defaults()
+	suite.Equal(time.Second, policy.backoff(1))
+	suite.Equal(2*time.Second, policy.backoff(2))
+	suite.Equal(4*time.Second, policy.backoff(3))
+	suite.Equal(5*time.Second, policy.backoff(4))
+
+	policy.Jitter = 0.5
+	for range 100 {
+		d := policy.backoff(1)
+		suite.GreaterOrEqual(d, 500*time.Millisecond)
+		suite.LessOrEqual(d, 1500*time.Millisecond)
+	}
+}
+
+// timeoutError is a net.Error that timed out.
+type timeoutError struct{}
+
+func (timeoutError) Error() string   { return "i/o timeout" }
+func (timeoutError) Timeout() bool   { return true }
+func (timeoutError) Temporary() bool { return true }
@@ -1,18 +1,26 @@
 package ssh
 
 import (
+	"bytes"
+	"errors"
 	"fmt"
+	"io"
+	"net"
+	"net/url"
 	"os"
 	"os/user"
+	"time"
 
 	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
 	"golang.org/x/crypto/ssh/knownhosts"
 )
 
 // Default values for SSH configuration.
 const (
-	defaultPort           int    = 22
-	defaultKnownHostsPath string = ".ssh/known_hosts"
+	defaultPort           int           = 22
+	defaultKnownHostsPath string        = ".ssh/known_hosts"
+	defaultTimeout        time.Duration = 30 * time.Second
 )
 
 // Config represents the configuration details for establishing an SSH connection.
@@ -25,12 +33,79 @@ type Config struct {
 	PrivateKeyPath string
 	KnownHostsPath string
 	Insecure       bool
+
+	// PrivateKeyPaths are further private keys that are tried in order after PrivateKey or PrivateKeyPath.
+	PrivateKeyPaths []string
+
+	// PrivateKeyPassphrase decrypts passphrase-protected private keys.
+	PrivateKeyPassphrase string
+
+	// Certificate or CertificatePath sets an OpenSSH user certificate.
+	// It is presented with the private key or agent key that matches its public key.
+	Certificate     string
+	CertificatePath string
+
+	// UseAgent enables the authentication with the keys of the SSH agent.
+	UseAgent bool
+
+	// AgentSocket is the socket of the SSH agent. Defaults to the environment variable SSH_AUTH_SOCK.
+	AgentSocket string
+
+	// KeyboardInteractive answers the challenges of the keyboard-interactive authentication.
+	// If it is not set but a password is, every challenge is answered with the password.
+	KeyboardInteractive ssh.KeyboardInteractiveChallenge
+
+	// JumpHosts are the bastion hosts the connection is tunnelled through, in order.
+	// Every jump host has its own authentication and known hosts settings.
+	JumpHosts []*Config
+
+	// Timeout limits the TCP connection and the SSH handshake. Defaults to 30 seconds.
+	Timeout time.Duration
+
+	// KeepAliveInterval is the interval of the keepalive requests to the host.
+	// If the host does not answer within the interval, the connection is closed and
+	// reconnected with the next command. Keepalive requests are disabled if zero.
+	KeepAliveInterval time.Duration
+
+	// HostKeyAlgorithms, Ciphers, KeyExchanges and MACs restrict the algorithms of the connection.
+	// The defaults of golang.org/x/crypto/ssh are used if not set.
+	HostKeyAlgorithms []string
+	Ciphers           []string
+	KeyExchanges      []string
+	MACs              []string
+
+	// Dial establishes the TCP connection to the host, e.g. with a custom net.Dialer.
+	// It takes precedence over ProxyURL.
+	Dial func(network, addr string) (net.Conn, error)
+
+	// ProxyURL is the URL of a SOCKS5 (socks5://) or HTTP CONNECT (http://) proxy to connect through.
+	ProxyURL string
 }
 
 // validate validates the SSH configuration parameters.
 func (config *Config) validate() error {
-	if (config.Host == "" || config.Username == "") || (config.Password == "" && config.PrivateKey == "" && config.PrivateKeyPath == "") {
-		return fmt.Errorf("ssh: Config parameter 'Host', 'Username' and one of 'Password', 'PrivateKey', 'PrivateKeyPath' must be set")
+	if (config.Host == "" || config.Username == "") ||
+		(config.Password == "" && config.PrivateKey == "" && config.PrivateKeyPath == "" &&
+			len(config.PrivateKeyPaths) == 0 && !config.UseAgent && config.KeyboardInteractive == nil) {
+		return fmt.Errorf("ssh: Config parameter 'Host', 'Username' and one of 'Password', 'PrivateKey', 'PrivateKeyPath', 'PrivateKeyPaths', 'UseAgent', 'KeyboardInteractive' must be set")
+	}
+
+	if (config.Certificate != "" || config.CertificatePath != "") &&
+		config.PrivateKey == "" && config.PrivateKeyPath == "" && len(config.PrivateKeyPaths) == 0 && !config.UseAgent {
+		return fmt.Errorf("ssh: Config parameter 'Certificate' and 'CertificatePath' require a private key or 'UseAgent'")
+	}
+
+	if config.ProxyURL != "" {
+		proxyURL, err := url.Parse(config.ProxyURL)
+		if err != nil || (proxyURL.Scheme != "socks5" && proxyURL.Scheme != "socks5h" && proxyURL.Scheme != "http") {
+			return fmt.Errorf("ssh: Config parameter 'ProxyURL' must be a socks5:// or http:// URL")
+		}
+	}
+
+	for _, jumpHost := range config.JumpHosts {
+		if jumpHost == nil || len(jumpHost.JumpHosts) > 0 {
+			return fmt.Errorf("ssh: Config parameter 'JumpHosts' must not contain nil or nested jump hosts")
+		}
 	}
 
 	return nil
@@ -52,6 +127,14 @@ func (config *Config) defaults() error {
 		config.KnownHostsPath = fmt.Sprintf("%s/%s", user.HomeDir, defaultKnownHostsPath)
 	}
 
+	if config.Timeout == 0 {
+		config.Timeout = defaultTimeout
+	}
+
+	if config.UseAgent && config.AgentSocket == "" {
+		config.AgentSocket = os.Getenv("SSH_AUTH_SOCK")
+	}
+
 	return nil
 }
 
@@ -73,29 +156,73 @@ func (config *Config) knownHostCallback() (ssh.HostKeyCallback, error) {
 }
 
 // authenticationMethod generates authentication methods based on the SSH configuration.
-func (config *Config) authenticationMethod() ([]ssh.AuthMethod, error) {
+// The returned closer closes the connection to the SSH agent and must be called after the handshake.
+func (config *Config) authenticationMethod() ([]ssh.AuthMethod, io.Closer, error) {
 	var authMethod []ssh.AuthMethod = []ssh.AuthMethod{}
 
-	// Private key authentication
+	// Private keys in order
+	var privateKeys [][]byte
 	if config.PrivateKey != "" {
-		signer, err := ssh.ParsePrivateKey([]byte(config.PrivateKey))
+		privateKeys = append(privateKeys, []byte(config.PrivateKey))
+	} else if config.PrivateKeyPath != "" {
+		privateKey, err := os.ReadFile(config.PrivateKeyPath)
 		if err != nil {
-			return nil, err
+			return nil, nil, err
 		}
+		privateKeys = append(privateKeys, privateKey)
+	}
 
-		authMethod = append(authMethod, ssh.PublicKeys(signer))
-	} else if config.PrivateKeyPath != "" {
-		privateKey, err := os.ReadFile(config.PrivateKeyPath)
+	for _, path := range config.PrivateKeyPaths {
+		privateKey, err := os.ReadFile(path)
 		if err != nil {
-			return nil, err
+			return nil, nil, err
 		}
+		privateKeys = append(privateKeys, privateKey)
+	}
 
-		signer, err := ssh.ParsePrivateKey(privateKey)
+	var signers []ssh.Signer
+	for _, privateKey := range privateKeys {
+		signer, err := config.parsePrivateKey(privateKey)
 		if err != nil {
-			return nil, err
+			return nil, nil, err
+		}
+		signers = append(signers, signer)
+	}
+
+	// The keys of the SSH agent are tried after the private keys.
+	var agentConn net.Conn
+	if config.UseAgent {
+		if config.AgentSocket == "" {
+			return nil, nil, fmt.Errorf("ssh: no SSH agent socket set and environment variable SSH_AUTH_SOCK is empty")
+		}
+
+		var err error
+		agentConn, err = net.Dial("unix", config.AgentSocket)
+		if err != nil {
+			return nil, nil, fmt.Errorf("ssh: failed to connect to the SSH agent: %w", err)
 		}
 
-		authMethod = append(authMethod, ssh.PublicKeys(signer))
+		agentSigners, err := agent.NewClient(agentConn).Signers()
+		if err != nil {
+			agentConn.Close()
+			return nil, nil, fmt.Errorf("ssh: failed to get the keys of the SSH agent: %w", err)
+		}
+		signers = append(signers, agentSigners...)
+	}
+
+	// User certificate
+	signers, err := config.certificateSigners(signers)
+	if err != nil {
+		if agentConn != nil {
+			agentConn.Close()
+		}
+		return nil, nil, err
+	}
+
+	// Public key authentication
+	// All keys are passed to a single method, because every method is only tried once.
+	if len(signers) > 0 {
+		authMethod = append(authMethod, ssh.PublicKeys(signers...))
 	}
 
 	// Password authentication
@@ -103,5 +230,97 @@ func (config *Config) authenticationMethod() ([]ssh.AuthMethod, error) {
 		authMethod = append(authMethod, ssh.Password(config.Password))
 	}
 
-	return authMethod, nil
+	// Keyboard-interactive authentication
+	if config.KeyboardInteractive != nil {
+		authMethod = append(authMethod, config.KeyboardInteractive)
+	} else if config.Password != "" {
+		authMethod = append(authMethod, ssh.KeyboardInteractive(func(name, instruction string, questions []string, echos []bool) ([]string, error) {
+			answers := make([]string, len(questions))
+			for i := range answers {
+				answers[i] = config.Password
+			}
+			return answers, nil
+		}))
+	}
+
+	// Avoid returning a nil net.Conn as a non-nil io.Closer.
+	if agentConn == nil {
+		return authMethod, nil, nil
+	}
+
+	return authMethod, agentConn, nil
+}
+
+// parsePrivateKey parses a private key and decrypts it with the passphrase if it is protected.
+func (config *Config) parsePrivateKey(privateKey []byte) (ssh.Signer, error) {
+	signer, err := ssh.ParsePrivateKey(privateKey)
+
+	var passphraseMissing *ssh.PassphraseMissingError
+	if errors.As(err, &passphraseMissing) {
+		if config.PrivateKeyPassphrase == "" {
+			return nil, fmt.Errorf("ssh: private key is passphrase-protected, but 'PrivateKeyPassphrase' is not set")
+		}
+		return ssh.ParsePrivateKeyWithPassphrase(privateKey, []byte(config.PrivateKeyPassphrase))
+	}
+
+	return signer, err
+}
+
+// certificateSigners replaces the signer whose public key matches the user certificate with a certificate signer.
+// It returns the signers unchanged if no certificate is set.
+func (config *Config) certificateSigners(signers []ssh.Signer) ([]ssh.Signer, error) {
+	certificate := []byte(config.Certificate)
+	if config.Certificate == "" {
+		if config.CertificatePath == "" {
+			return signers, nil
+		}
+
+		var err error
+		certificate, err = os.ReadFile(config.CertificatePath)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	publicKey, _, _, _, err := ssh.ParseAuthorizedKey(certificate)
+	if err != nil {
+		return nil, fmt.Errorf("ssh: failed to parse the certificate: %w", err)
+	}
+
+	cert, ok := publicKey.(*ssh.Certificate)
+	if !ok {
+		return nil, fmt.Errorf("ssh: 'Certificate' is not an OpenSSH certificate")
+	}
+
+	for i, signer := range signers {
+		if !bytes.Equal(signer.PublicKey().Marshal(), cert.Key.Marshal()) {
+			continue
+		}
+
+		certSigner, err := ssh.NewCertSigner(cert, signer)
+		if err != nil {
+			return nil, err
+		}
+
+		// The certificate is tried right before the plain key.
+		return append(append(signers[:i:i], certSigner), signers[i:]...), nil
+	}
+
+	return nil, fmt.Errorf("ssh: no private key matches the public key of the certificate")
+}
+
+// clientConfig generates the SSH client configuration based on the SSH configuration.
+func (config *Config) clientConfig(knownHostCallback ssh.HostKeyCallback, authMethod []ssh.AuthMethod) *ssh.ClientConfig {
+	sshConfig := &ssh.ClientConfig{
+		User:              config.Username,
+		Auth:              authMethod,
+		HostKeyCallback:   knownHostCallback,
+		HostKeyAlgorithms: config.HostKeyAlgorithms,
+		Timeout:           config.Timeout,
+	}
+	sshConfig.Ciphers = config.Ciphers
+	sshConfig.KeyExchanges = config.KeyExchanges
+	sshConfig.MACs = config.MACs
+
+	return sshConfig
 }
@@ -1,7 +1,19 @@
 package ssh
 
 import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/pem"
 	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
 )
 
 func (suite *SSHUnitTestSuite) TestValidate() {
@@ -34,6 +46,33 @@ func (suite *SSHUnitTestSuite) TestValidate() {
 					PrivateKeyPath: "/test/test",
 				},
 			},
+			{
+				"Host + Username + PrivateKeyPaths",
+				&Config{
+					Host:            "test",
+					Username:        "test",
+					PrivateKeyPaths: []string{"/test/first", "/test/second"},
+				},
+			},
+			{
+				"Host + Username + UseAgent + CertificatePath",
+				&Config{
+					Host:            "test",
+					Username:        "test",
+					UseAgent:        true,
+					CertificatePath: "/test/test-cert.pub",
+				},
+			},
+			{
+				"Host + Username + KeyboardInteractive",
+				&Config{
+					Host:     "test",
+					Username: "test",
+					KeyboardInteractive: func(name, instruction string, questions []string, echos []bool) ([]string, error) {
+						return nil, nil
+					},
+				},
+			},
 		}
 
 		for _, tc := range tcs {
@@ -75,6 +114,24 @@ func (suite *SSHUnitTestSuite) TestValidate() {
 					Password: "test",
 				},
 			},
+			{
+				"Host + Username + Password + ProxyURL",
+				&Config{
+					Host:     "test",
+					Username: "test",
+					Password: "test",
+					ProxyURL: "ftp://proxy:21",
+				},
+			},
+			{
+				"Host + Username + Password + Certificate",
+				&Config{
+					Host:        "test",
+					Username:    "test",
+					Password:    "test",
+					Certificate: "test",
+				},
+			},
 		}
 
 		for _, tc := range tcs {
@@ -99,6 +156,7 @@ func (suite *SSHUnitTestSuite) TestDefaults() {
 			Password:       "test",
 			Port:           22,
 			KnownHostsPath: fmt.Sprintf("%s/%s", suite.currentUserHomeDir, defaultKnownHostsPath),
+			Timeout:        30 * time.Second,
 		}
 		err := input.defaults()
 		suite.Assertions.NoError(err)
@@ -112,6 +170,7 @@ func (suite *SSHUnitTestSuite) TestDefaults() {
 			Password:       "test",
 			Port:           2222,
 			KnownHostsPath: "/home/test/.ssh/known_hosts",
+			Timeout:        5 * time.Second,
 		}
 		expected := &Config{
 			Host:           "test",
@@ -119,9 +178,198 @@ func (suite *SSHUnitTestSuite) TestDefaults() {
 			Password:       "test",
 			Port:           2222,
 			KnownHostsPath: "/home/test/.ssh/known_hosts",
+			Timeout:        5 * time.Second,
 		}
 		err := input.defaults()
 		suite.Assertions.NoError(err)
 		suite.Assertions.EqualValues(input, expected)
 	})
 }
+
+// testPrivateKey generates a new ed25519 key and returns its signer and PEM encoded private key.
+// The private key is encrypted with the passphrase if it is not empty.
+func (suite *SSHUnitTestSuite) testPrivateKey(passphrase string) (ssh.Signer, string) {
+	_, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	suite.Require().NoError(err)
+
+	signer, err := ssh.NewSignerFromKey(privateKey)
+	suite.Require().NoError(err)
+
+	var block *pem.Block
+	if passphrase == "" {
+		block, err = ssh.MarshalPrivateKey(privateKey, "")
+	} else {
+		block, err = ssh.MarshalPrivateKeyWithPassphrase(privateKey, "", []byte(passphrase))
+	}
+	suite.Require().NoError(err)
+
+	return signer, string(pem.EncodeToMemory(block))
+}
+
+// writeFile writes the content to a new file in a temporary directory and returns its path.
+func (suite *SSHUnitTestSuite) writeFile(name string, content string) string {
+	path := filepath.Join(suite.T().TempDir(), name)
+	suite.Require().NoError(os.WriteFile(path, []byte(content), 0o600))
+	return path
+}
+
+// testAgent starts an SSH agent with the private key on a unix socket and returns the path of the socket.
+func (suite *SSHUnitTestSuite) testAgent(privateKey ed25519.PrivateKey) string {
+	keyring := agent.NewKeyring()
+	suite.Require().NoError(keyring.Add(agent.AddedKey{PrivateKey: privateKey}))
+
+	socket := filepath.Join(suite.T().TempDir(), "agent.sock")
+	listener, err := net.Listen("unix", socket)
+	suite.Require().NoError(err)
+	suite.T().Cleanup(func() { listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				_ = agent.ServeAgent(keyring, conn)
+			}()
+		}
+	}()
+
+	return socket
+}
+
+// testUserCertificate signs an OpenSSH user certificate for the public key of the test user
+// and returns the CA key and the certificate in authorized_keys format.
+func (suite *SSHUnitTestSuite) testUserCertificate(key ssh.PublicKey) (ssh.PublicKey, string) {
+	ca, _ := suite.testPrivateKey("")
+
+	cert := &ssh.Certificate{
+		Key:             key,
+		CertType:        ssh.UserCert,
+		KeyId:           testServerUsername,
+		ValidPrincipals: []string{testServerUsername},
+		ValidBefore:     ssh.CertTimeInfinity,
+	}
+	suite.Require().NoError(cert.SignCert(rand.Reader, ca))
+
+	return ca.PublicKey(), string(ssh.MarshalAuthorizedKey(cert))
+}
+
+func (suite *SSHUnitTestSuite) TestAuthenticationMethod() {
+	handler := func(cmd string, stdin io.Reader, stdout io.Writer, stderr io.Writer) int {
+		fmt.Fprint(stdout, "authenticated")
+		return 0
+	}
+
+	// connect connects to the test server and runs a command.
+	connect := func(config *Config) error {
+		conn, err := NewConnection(config)
+		if err != nil {
+			return err
+		}
+		defer conn.Close()
+
+		result, err := conn.Run(context.Background(), "whoami")
+		suite.Require().NoError(err)
+		suite.Equal("authenticated", result.StdOut)
+		return nil
+	}
+
+	suite.Run("should authenticate with a passphrase-protected private key", func() {
+		server := newTestServer(suite.T(), handler)
+		signer, privateKey := suite.testPrivateKey("secret")
+		server.authorizeKey(signer.PublicKey())
+
+		config := server.clientConfig()
+		config.Password = ""
+		config.PrivateKey = privateKey
+		config.PrivateKeyPassphrase = "secret"
+
+		suite.Require().NoError(connect(config))
+		suite.Equal([]string{"publickey"}, server.successfulAuthMethods())
+	})
+
+	suite.Run("should return an error for a passphrase-protected private key without passphrase", func() {
+		server := newTestServer(suite.T(), handler)
+		_, privateKey := suite.testPrivateKey("secret")
+
+		config := server.clientConfig()
+		config.PrivateKey = privateKey
+
+		suite.ErrorContains(connect(config), "PrivateKeyPassphrase")
+	})
+
+	suite.Run("should try multiple private keys in order", func() {
+		server := newTestServer(suite.T(), handler)
+		_, unauthorizedKey := suite.testPrivateKey("")
+		signer, authorizedKey := suite.testPrivateKey("")
+		server.authorizeKey(signer.PublicKey())
+
+		config := server.clientConfig()
+		config.Password = ""
+		config.PrivateKeyPath = suite.writeFile("id_first", unauthorizedKey)
+		config.PrivateKeyPaths = []string{suite.writeFile("id_second", authorizedKey)}
+
+		suite.Require().NoError(connect(config))
+		suite.Equal([]string{"publickey"}, server.successfulAuthMethods())
+	})
+
+	suite.Run("should authenticate with the keys of the SSH agent", func() {
+		server := newTestServer(suite.T(), handler)
+		_, privateKey, err := ed25519.GenerateKey(rand.Reader)
+		suite.Require().NoError(err)
+		signer, err := ssh.NewSignerFromKey(privateKey)
+		suite.Require().NoError(err)
+		server.authorizeKey(signer.PublicKey())
+
+		config := server.clientConfig()
+		config.Password = ""
+		config.UseAgent = true
+		config.AgentSocket = suite.testAgent(privateKey)
+
+		suite.Require().NoError(connect(config))
+		suite.Equal([]string{"publickey"}, server.successfulAuthMethods())
+	})
+
+	suite.Run("should authenticate with a user certificate", func() {
+		server := newTestServer(suite.T(), handler)
+		signer, privateKey := suite.testPrivateKey("")
+		ca, certificate := suite.testUserCertificate(signer.PublicKey())
+		server.trustUserCA(ca)
+
+		config := server.clientConfig()
+		config.Password = ""
+		config.PrivateKey = privateKey
+		config.CertificatePath = suite.writeFile("id_ed25519-cert.pub", certificate)
+
+		suite.Require().NoError(connect(config))
+		suite.Equal([]string{"publickey"}, server.successfulAuthMethods())
+	})
+
+	suite.Run("should return an error if no key matches the certificate", func() {
+		server := newTestServer(suite.T(), handler)
+		signer, _ := suite.testPrivateKey("")
+		_, privateKey := suite.testPrivateKey("")
+		_, certificate := suite.testUserCertificate(signer.PublicKey())
+
+		config := server.clientConfig()
+		config.PrivateKey = privateKey
+		config.Certificate = certificate
+
+		suite.ErrorContains(connect(config), "no private key matches")
+	})
+
+	suite.Run("should authenticate via keyboard-interactive", func() {
+		server := newTestServer(suite.T(), handler)
+
+		config := server.clientConfig()
+		config.Password = ""
+		config.KeyboardInteractive = func(name, instruction string, questions []string, echos []bool) ([]string, error) {
+			return []string{testServerPassword}, nil
+		}
+
+		suite.Require().NoError(connect(config))
+		suite.Equal([]string{"keyboard-interactive"}, server.successfulAuthMethods())
+	})
+}
@@ -0,0 +1,105 @@
+package ssh
+
+import (
+	"bufio"
+	"encoding/base64"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+
+	"golang.org/x/net/proxy"
+)
+
+// dial establishes the TCP connection to the address.
+// It uses the Dial function of the SSH configuration if set, otherwise the proxy or a direct connection.
+func (config *Config) dial(network, addr string) (net.Conn, error) {
+	if config.Dial != nil {
+		return config.Dial(network, addr)
+	}
+
+	dialer := &net.Dialer{Timeout: config.Timeout}
+
+	if config.ProxyURL == "" {
+		return dialer.Dial(network, addr)
+	}
+
+	proxyURL, err := url.Parse(config.ProxyURL)
+	if err != nil {
+		return nil, fmt.Errorf("ssh: failed to parse the proxy URL: %w", err)
+	}
+
+	switch proxyURL.Scheme {
+	case "socks5", "socks5h":
+		proxyDialer, err := proxy.FromURL(proxyURL, dialer)
+		if err != nil {
+			return nil, fmt.Errorf("ssh: failed to create the SOCKS5 proxy dialer: %w", err)
+		}
+		return proxyDialer.Dial(network, addr)
+
+	case "http":
+		return dialHTTPConnect(dialer, proxyURL, addr)
+
+	default:
+		return nil, fmt.Errorf("ssh: unsupported proxy scheme '%s'", proxyURL.Scheme)
+	}
+}
+
+// dialHTTPConnect establishes a tunnel to the address through an HTTP proxy with the CONNECT method.
+func dialHTTPConnect(dialer *net.Dialer, proxyURL *url.URL, addr string) (net.Conn, error) {
+	conn, err := dialer.Dial("tcp", proxyURL.Host)
+	if err != nil {
+		return nil, fmt.Errorf("ssh: failed to connect to the proxy: %w", err)
+	}
+
+	// The proxy must answer within the dial timeout.
+	if dialer.Timeout > 0 {
+		_ = conn.SetDeadline(time.Now().Add(dialer.Timeout))
+		defer conn.SetDeadline(time.Time{})
+	}
+
+	req := &http.Request{
+		Method: http.MethodConnect,
+		URL:    &url.URL{Opaque: addr},
+		Host:   addr,
+		Header: make(http.Header),
+	}
+	if proxyURL.User != nil {
+		password, _ := proxyURL.User.Password()
+		credentials := base64.StdEncoding.EncodeToString([]byte(proxyURL.User.Username() + ":" + password))
+		req.Header.Set("Proxy-Authorization", "Basic "+credentials)
+	}
+
+	if err := req.Write(conn); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("ssh: failed to send the CONNECT request to the proxy: %w", err)
+	}
+
+	r := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(r, req)
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("ssh: failed to read the CONNECT response of the proxy: %w", err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		conn.Close()
+		return nil, fmt.Errorf("ssh: proxy refused the CONNECT request: %s", resp.Status)
+	}
+
+	// The reader may have buffered the version of the SSH server already.
+	return &bufferedConn{Conn: conn, r: r}, nil
+}
+
+// bufferedConn is a net.Conn that reads the data of a buffered reader first.
+type bufferedConn struct {
+	net.Conn
+	r *bufio.Reader
+}
+
+// Read reads from the buffered reader.
+func (c *bufferedConn) Read(p []byte) (int, error) {
+	return c.r.Read(p)
+}
@@ -0,0 +1,236 @@
+package ssh
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"time"
+)
+
+// testHTTPProxy starts an HTTP proxy that tunnels CONNECT requests and records their targets.
+// It answers with the status if it is not zero instead.
+func (suite *SSHUnitTestSuite) testHTTPProxy(status int) (*httptest.Server, func() []string) {
+	var mu sync.Mutex
+	var targets []string
+
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodConnect {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		if status != 0 {
+			w.WriteHeader(status)
+			return
+		}
+
+		mu.Lock()
+		targets = append(targets, r.Host)
+		mu.Unlock()
+
+		target, err := net.Dial("tcp", r.Host)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadGateway)
+			return
+		}
+		defer target.Close()
+
+		conn, _, err := w.(http.Hijacker).Hijack()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		if _, err := io.WriteString(conn, "HTTP/1.1 200 Connection established\r\n\r\n"); err != nil {
+			return
+		}
+
+		// Closing both ends as soon as one side is done lets the test server see the client going away.
+		go func() {
+			_, _ = io.Copy(target, conn)
+			target.Close()
+		}()
+		_, _ = io.Copy(conn, target)
+	}))
+	suite.T().Cleanup(proxy.Close)
+
+	return proxy, func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]string(nil), targets...)
+	}
+}
+
+func (suite *SSHUnitTestSuite) TestDial() {
+	handler := func(cmd string, stdin io.Reader, stdout io.Writer, stderr io.Writer) int {
+		fmt.Fprint(stdout, "connected")
+		return 0
+	}
+
+	suite.Run("should connect with the dial function", func() {
+		server := newTestServer(suite.T(), handler)
+
+		var dialed []string
+		config := server.clientConfig()
+		config.Dial = func(network, addr string) (net.Conn, error) {
+			dialed = append(dialed, addr)
+			return net.Dial(network, addr)
+		}
+
+		conn, err := NewConnection(config)
+		suite.Require().NoError(err)
+		defer conn.Close()
+
+		result, err := conn.Run(context.Background(), "whoami")
+		suite.Require().NoError(err)
+		suite.Equal("connected", result.StdOut)
+		suite.Equal([]string{server.listener.Addr().String()}, dialed)
+	})
+
+	suite.Run("should dial IPv6 hosts", func() {
+		listener, err := net.Listen("tcp", "[::1]:0")
+		if err != nil {
+			suite.T().Skipf("IPv6 is not available: %s", err)
+		}
+		listener.Close()
+
+		tcs := []struct {
+			description string
+			jumpHosts   int
+		}{
+			{"[::1]", 0},
+			{"[::1] through two jump hosts", 2},
+		}
+
+		for _, tc := range tcs {
+			suite.T().Logf("test case: %s", tc.description)
+			server := newTestServerOn(suite.T(), "[::1]:0", handler)
+
+			var jumpServers []*testServer
+			config := server.clientConfig()
+			for range tc.jumpHosts {
+				jumpServer := newTestServerOn(suite.T(), "[::1]:0", handler)
+				jumpServers = append(jumpServers, jumpServer)
+				config.JumpHosts = append(config.JumpHosts, jumpServer.clientConfig())
+			}
+
+			// The first host is dialed directly, every further host through the jump host before it.
+			var dialed []string
+			first := config
+			if len(config.JumpHosts) > 0 {
+				first = config.JumpHosts[0]
+			}
+			first.Dial = func(network, addr string) (net.Conn, error) {
+				dialed = append(dialed, addr)
+				return net.Dial(network, addr)
+			}
+
+			conn, err := NewConnection(config)
+			suite.Require().NoError(err)
+
+			result, err := conn.Run(context.Background(), "whoami")
+			suite.Require().NoError(err)
+			suite.Equal("connected", result.StdOut)
+			suite.NoError(conn.Close())
+
+			if len(jumpServers) == 0 {
+				suite.Equal([]string{server.listener.Addr().String()}, dialed)
+				continue
+			}
+			suite.Equal([]string{jumpServers[0].listener.Addr().String()}, dialed)
+			for i, jumpServer := range jumpServers {
+				next := server.listener.Addr().String()
+				if i+1 < len(jumpServers) {
+					next = jumpServers[i+1].listener.Addr().String()
+				}
+				suite.Equal([]string{next}, jumpServer.forwarded())
+			}
+		}
+	})
+
+	suite.Run("should connect through an HTTP CONNECT proxy", func() {
+		server := newTestServer(suite.T(), handler)
+		proxy, targets := suite.testHTTPProxy(0)
+
+		config := server.clientConfig()
+		config.ProxyURL = proxy.URL
+
+		conn, err := NewConnection(config)
+		suite.Require().NoError(err)
+		defer conn.Close()
+
+		result, err := conn.Run(context.Background(), "whoami")
+		suite.Require().NoError(err)
+		suite.Equal("connected", result.StdOut)
+		suite.Equal([]string{server.listener.Addr().String()}, targets())
+	})
+
+	suite.Run("should return an error if the proxy refuses the connection", func() {
+		server := newTestServer(suite.T(), handler)
+		proxy, _ := suite.testHTTPProxy(http.StatusProxyAuthRequired)
+
+		config := server.clientConfig()
+		config.ProxyURL = proxy.URL
+
+		_, err := NewConnection(config)
+		suite.ErrorContains(err, "407")
+	})
+
+	suite.Run("should return an error if the handshake times out", func() {
+		// The host accepts connections, but never answers.
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		suite.Require().NoError(err)
+		defer listener.Close()
+		go func() {
+			var conns []net.Conn
+			for {
+				conn, err := listener.Accept()
+				if err != nil {
+					for _, conn := range conns {
+						conn.Close()
+					}
+					return
+				}
+				conns = append(conns, conn)
+			}
+		}()
+
+		addr := listener.Addr().(*net.TCPAddr)
+		config := &Config{
+			Host:     addr.IP.String(),
+			Port:     addr.Port,
+			Username: testServerUsername,
+			Password: testServerPassword,
+			Insecure: true,
+			Timeout:  200 * time.Millisecond,
+		}
+
+		start := time.Now()
+		_, err = NewConnection(config)
+		suite.Error(err)
+		suite.Less(time.Since(start), 5*time.Second)
+	})
+
+	suite.Run("should restrict the algorithms", func() {
+		server := newTestServer(suite.T(), handler)
+
+		config := server.clientConfig()
+		config.Ciphers = []string{"aes128-ctr"}
+		config.KeyExchanges = []string{"curve25519-sha256"}
+		config.MACs = []string{"hmac-sha2-256"}
+		config.HostKeyAlgorithms = []string{"ssh-ed25519"}
+
+		conn, err := NewConnection(config)
+		suite.Require().NoError(err)
+		suite.NoError(conn.Close())
+
+		config = server.clientConfig()
+		config.HostKeyAlgorithms = []string{"rsa-sha2-512"}
+
+		_, err = NewConnection(config)
+		suite.Error(err)
+	})
+}
@@ -0,0 +1,76 @@
+package ssh
+
+import (
+	"fmt"
+	"net"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// JumpDialer dials network connections through a chain of SSH jump hosts.
+// Its Dial method opens a direct-tcpip channel from the last jump host, so other transports like WinRM
+// can be tunnelled through a bastion, e.g. with the Dial parameter of winrm.Config.
+type JumpDialer struct {
+	clients []*ssh.Client
+}
+
+// NewJumpDialer connects to the jump hosts one after another.
+// The connections stay open until the dialer is closed.
+func NewJumpDialer(jumpHosts ...*Config) (*JumpDialer, error) {
+	if len(jumpHosts) == 0 {
+		return nil, fmt.Errorf("ssh: at least one jump host must be set")
+	}
+
+	clients, err := dialJumpHosts(jumpHosts)
+	if err != nil {
+		return nil, err
+	}
+
+	return &JumpDialer{clients: clients}, nil
+}
+
+// Dial connects to the address through the jump hosts.
+// Only TCP networks are supported.
+func (d *JumpDialer) Dial(network, addr string) (net.Conn, error) {
+	return d.clients[len(d.clients)-1].Dial(network, addr)
+}
+
+// Close closes the connections to the jump hosts.
+func (d *JumpDialer) Close() error {
+	closeClients(d.clients)
+	return nil
+}
+
+// dialJumpHosts connects to the jump hosts one after another, each through the previous one.
+// It returns the clients of the jump hosts in order.
+func dialJumpHosts(jumpHosts []*Config) ([]*ssh.Client, error) {
+	var clients []*ssh.Client
+
+	for _, jumpHost := range jumpHosts {
+		if jumpHost == nil || len(jumpHost.JumpHosts) > 0 {
+			closeClients(clients)
+			return nil, fmt.Errorf("ssh: Config parameter 'JumpHosts' must not contain nil or nested jump hosts")
+		}
+
+		var via *ssh.Client
+		if len(clients) > 0 {
+			via = clients[len(clients)-1]
+		}
+
+		client, err := dialHost(jumpHost, via)
+		if err != nil {
+			closeClients(clients)
+			return nil, fmt.Errorf("ssh: jump host %s: %w", jumpHost.Host, err)
+		}
+		clients = append(clients, client)
+	}
+
+	return clients, nil
+}
+
+// closeClients closes the clients in reverse order, so every client is closed before the jump host it is tunnelled through.
+func closeClients(clients []*ssh.Client) {
+	for i := len(clients) - 1; i >= 0; i-- {
+		clients[i].Close()
+	}
+}
@@ -0,0 +1,126 @@
+package ssh
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+)
+
+func (suite *SSHUnitTestSuite) TestJumpHosts() {
+	handler := func(cmd string, stdin io.Reader, stdout io.Writer, stderr io.Writer) int {
+		fmt.Fprint(stdout, "tunnelled")
+		return 0
+	}
+
+	suite.Run("should connect through the jump hosts", func() {
+		tcs := []struct {
+			description string
+			jumpHosts   int
+		}{
+			{"one jump host", 1},
+			{"two jump hosts", 2},
+		}
+
+		for _, tc := range tcs {
+			suite.T().Logf("test case: %s", tc.description)
+			server := newTestServer(suite.T(), handler)
+
+			var jumpServers []*testServer
+			config := server.clientConfig()
+			for range tc.jumpHosts {
+				jumpServer := newTestServer(suite.T(), handler)
+				jumpServers = append(jumpServers, jumpServer)
+				config.JumpHosts = append(config.JumpHosts, jumpServer.clientConfig())
+			}
+
+			conn, err := NewConnection(config)
+			suite.Require().NoError(err)
+
+			result, err := conn.Run(context.Background(), "whoami")
+			suite.Require().NoError(err)
+			suite.Equal("tunnelled", result.StdOut)
+			suite.NoError(conn.Close())
+
+			// Every jump host forwards to the next host.
+			for i, jumpServer := range jumpServers {
+				next := server.listener.Addr().String()
+				if i+1 < len(jumpServers) {
+					next = jumpServers[i+1].listener.Addr().String()
+				}
+				suite.Equal([]string{next}, jumpServer.forwarded())
+			}
+		}
+	})
+
+	suite.Run("should return an error if the jump host authentication fails", func() {
+		server := newTestServer(suite.T(), handler)
+		jumpServer := newTestServer(suite.T(), handler)
+
+		jumpConfig := jumpServer.clientConfig()
+		jumpConfig.Password = "wrong"
+
+		config := server.clientConfig()
+		config.JumpHosts = []*Config{jumpConfig}
+
+		_, err := NewConnection(config)
+		suite.ErrorContains(err, "jump host")
+		suite.Empty(jumpServer.forwarded())
+	})
+
+	suite.Run("should return an error for nested jump hosts", func() {
+		server := newTestServer(suite.T(), handler)
+		jumpServer := newTestServer(suite.T(), handler)
+
+		jumpConfig := jumpServer.clientConfig()
+		jumpConfig.JumpHosts = []*Config{jumpServer.clientConfig()}
+
+		config := server.clientConfig()
+		config.JumpHosts = []*Config{jumpConfig}
+
+		_, err := NewConnection(config)
+		suite.Error(err)
+	})
+}
+
+func (suite *SSHUnitTestSuite) TestJumpDialer() {
+	suite.Run("should dial TCP connections through the jump host", func() {
+		jumpServer := newTestServer(suite.T(), func(cmd string, stdin io.Reader, stdout io.Writer, stderr io.Writer) int {
+			return 0
+		})
+
+		// An echo server stands in for a service behind the bastion.
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		suite.Require().NoError(err)
+		suite.T().Cleanup(func() { listener.Close() })
+		go func() {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+			_, _ = io.Copy(conn, conn)
+		}()
+
+		dialer, err := NewJumpDialer(jumpServer.clientConfig())
+		suite.Require().NoError(err)
+		defer dialer.Close()
+
+		conn, err := dialer.Dial("tcp", listener.Addr().String())
+		suite.Require().NoError(err)
+		defer conn.Close()
+
+		_, err = conn.Write([]byte("ping"))
+		suite.Require().NoError(err)
+		buf := make([]byte, 4)
+		_, err = io.ReadFull(conn, buf)
+		suite.Require().NoError(err)
+		suite.Equal("ping", string(buf))
+		suite.Equal([]string{listener.Addr().String()}, jumpServer.forwarded())
+	})
+
+	suite.Run("should return an error without jump hosts", func() {
+		_, err := NewJumpDialer()
+		suite.Error(err)
+	})
+}
@@ -0,0 +1,406 @@
+package ssh
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/d-strobel/gowindows/connection"
+	"github.com/d-strobel/gowindows/parsing"
+	"golang.org/x/crypto/ssh"
+)
+
+// powershellBootstrap is the command of the persistent PowerShell session.
+// It reads the base64 encoded loop script from the first line of stdin and runs it in the current scope.
+const powershellBootstrap string = `$in = [Console]::In; ` +
+	`. ([ScriptBlock]::Create([Text.Encoding]::UTF8.GetString([Convert]::FromBase64String($in.ReadLine()))))`
+
+// powershellLoop is the script of the persistent PowerShell session.
+// It reads a request per line from stdin, runs the script of the request and writes the output objects,
+// the error records and the exit code of the script as frames to stdout.
+// A request has the format <id>:<base64 script>[:<base64 stdin>] and a frame has the format <marker><id>:<kind>:<base64 text>.
+// The placeholder {{marker}} is replaced with the random marker of the session.
+const powershellLoop string = `$marker = '{{marker}}'
+$out = [Console]::Out
+$utf8 = New-Object System.Text.UTF8Encoding $false
+function Send-Frame([string]$Id, [string]$Kind, [string]$Text) {
+	$out.WriteLine($marker + $Id + ':' + $Kind + ':' + [Convert]::ToBase64String($utf8.GetBytes($Text)))
+	$out.Flush()
+}
+while ($null -ne ($request = $in.ReadLine())) {
+	$id, $script, $stdin = $request.Split(':')
+	$text = ''
+	if ($stdin) {
+		$text = $utf8.GetString([Convert]::FromBase64String($stdin))
+	}
+	$lines = @()
+	if ($text) {
+		$lines = $text.TrimEnd([char[]]"` + "`r`n" + `") -split '\r?\n'
+	}
+	$failed = $false
+	$global:LASTEXITCODE = 0
+	try {
+		$sb = [ScriptBlock]::Create($utf8.GetString([Convert]::FromBase64String($script)))
+		$lines | & $sb 2>&1 | ForEach-Object {
+			if ($_ -is [System.Management.Automation.ErrorRecord]) {
+				Send-Frame $id 'err' ($_ | Out-String)
+			} elseif ($_ -is [string]) {
+				Send-Frame $id 'out' $_
+			} elseif ($null -ne $_) {
+				Send-Frame $id 'out' ($_ | Out-String).Trim()
+			}
+		}
+	} catch {
+		$failed = $true
+		Send-Frame $id 'err' ($_ | Out-String)
+	}
+	$exitCode = 0
+	if ($failed) {
+		$exitCode = 1
+	} elseif ($LASTEXITCODE -is [int]) {
+		$exitCode = $LASTEXITCODE
+	}
+	Send-Frame $id 'exit' ([string]$exitCode)
+}
+`
+
+// Kinds of the frames of the persistent PowerShell session.
+const (
+	powershellFrameOutput string = "out"
+	powershellFrameError  string = "err"
+	powershellFrameExit   string = "exit"
+)
+
+// PowershellConnection represents an SSH connection that runs PowerShell commands in a single long-lived powershell.exe process.
+// In contrast to Connection, no new SSH session and no new powershell.exe process is started per command,
+// which makes many small commands, e.g. reading hundreds of DNS records, a lot faster.
+//
+// The commands of a connection run one after another in the same process.
+// Their output, error records and exit code are separated by frames with a random marker.
+// If a command ends the process, e.g. with the exit keyword, or its context is done,
+// the process is stopped and the next command starts a new one.
+// It is safe to use a PowershellConnection from multiple goroutines.
+type PowershellConnection struct {
+	conn *Connection
+
+	// mu serializes the commands of the session.
+	mu      sync.Mutex
+	session *powershellSession
+	id      uint64
+	closed  bool
+}
+
+// NewPowershellConnection creates a new SSH connection based on the provided configuration
+// and starts the persistent PowerShell session on the remote host.
+func NewPowershellConnection(config *Config) (*PowershellConnection, error) {
+	conn, err := NewConnection(config)
+	if err != nil {
+		return nil, err
+	}
+
+	client, err := conn.client()
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	session, err := newPowershellSession(client)
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("ssh: failed to start the powershell session: %w", err)
+	}
+
+	return &PowershellConnection{conn: conn, session: session}, nil
+}
+
+// Close stops the PowerShell session and closes the SSH connection.
+// Satisfies the Connection interface.
+func (c *PowershellConnection) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.closed {
+		return nil
+	}
+	c.closed = true
+	c.reset()
+
+	return c.conn.Close()
+}
+
+// Run runs a command as a PowerShell script in the PowerShell session.
+// Stdout contains the string representation of the output objects and stderr contains
+// the error records as a CLIXML error stream, the same way powershell.exe reports them.
+// The exit code is 1 if the script failed with a terminating error, otherwise it is the last exit code of a native command
+// or the exit code of the exit keyword.
+func (c *PowershellConnection) Run(ctx context.Context, cmd string) (connection.CmdResult, error) {
+	var stdout, stderr strings.Builder
+
+	exitCode, err := c.Stream(ctx, cmd, connection.Streams{Stdout: &stdout, Stderr: &stderr})
+	if err != nil {
+		return connection.CmdResult{}, err
+	}
+
+	return connection.CmdResult{
+		StdOut:   stdout.String(),
+		StdErr:   stderr.String(),
+		ExitCode: exitCode,
+	}, nil
+}
+
+// RunWithPowershell runs a PowerShell command in the PowerShell session.
+// It is the same as Run, because every command of a PowershellConnection is a PowerShell script.
+func (c *PowershellConnection) RunWithPowershell(ctx context.Context, cmd string) (connection.CmdResult, error) {
+	return c.Run(ctx, cmd)
+}
+
+// StreamWithPowershell runs a PowerShell command in the PowerShell session.
+// It is the same as Stream, because every command of a PowershellConnection is a PowerShell script.
+func (c *PowershellConnection) StreamWithPowershell(ctx context.Context, cmd string, streams connection.Streams) (int, error) {
+	return c.Stream(ctx, cmd, streams)
+}
+
+// Stream runs a command as a PowerShell script in the PowerShell session.
+// It writes every output object as a line to stdout and the error records to stderr as the data arrives.
+// Stdin is read completely before the script runs and every line of stdin is passed to the script, which reads it with $input.
+// If the context is done, the PowerShell process is stopped.
+func (c *PowershellConnection) Stream(ctx context.Context, cmd string, streams connection.Streams) (int, error) {
+	stdout := streams.Stdout
+	if stdout == nil {
+		stdout = io.Discard
+	}
+	stderr := connection.NewCliXmlErrorWriter(io.Discard)
+	if streams.Stderr != nil {
+		stderr = connection.NewCliXmlErrorWriter(streams.Stderr)
+	}
+
+	request := base64.StdEncoding.EncodeToString([]byte(cmd))
+	if streams.Stdin != nil {
+		input, err := io.ReadAll(streams.Stdin)
+		if err != nil {
+			return 0, fmt.Errorf("ssh: failed to read stdin: %w", err)
+		}
+		request += ":" + base64.StdEncoding.EncodeToString(input)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.closed {
+		return 0, errors.New("ssh: the powershell session is closed")
+	}
+
+	// Start a new session if the last one was stopped.
+	if c.session == nil {
+		client, err := c.conn.client()
+		if err != nil {
+			return 0, err
+		}
+
+		session, err := newPowershellSession(client)
+		if err != nil {
+			return 0, fmt.Errorf("ssh: failed to start the powershell session: %w", err)
+		}
+		c.session = session
+	}
+	s := c.session
+
+	c.id++
+	id := strconv.FormatUint(c.id, 10)
+	if _, err := io.WriteString(s.stdin, id+":"+request+"\n"); err != nil {
+		c.reset()
+		return 0, fmt.Errorf("ssh: failed to send the command to the powershell session: %w", err)
+	}
+
+	for {
+		var line string
+		var ok bool
+
+		select {
+		case <-ctx.Done():
+			c.reset()
+			return 0, ctx.Err()
+		case line, ok = <-s.lines:
+		}
+
+		// The process exited while the command was running, e.g. with the exit keyword.
+		if !ok {
+			exitCode, err := s.wait()
+			c.reset()
+			if err != nil {
+				return 0, fmt.Errorf("ssh: the powershell session ended unexpectedly: %w", err)
+			}
+			return exitCode, stderr.Close()
+		}
+
+		kind, text, ok := s.frame(line, id)
+		if !ok {
+			// Lines without a frame are written to the console by the script, e.g. with Write-Host.
+			if _, err := io.WriteString(stdout, line); err != nil {
+				c.reset()
+				return 0, err
+			}
+			continue
+		}
+
+		var err error
+		switch kind {
+		case powershellFrameOutput:
+			_, err = io.WriteString(stdout, text+"\r\n")
+		case powershellFrameError:
+			err = stderr.WriteErrorRecord(strings.TrimRight(text, " \r\n"))
+		case powershellFrameExit:
+			exitCode, err := strconv.Atoi(text)
+			if err != nil {
+				c.reset()
+				return 0, fmt.Errorf("ssh: invalid exit code %q of the powershell session", text)
+			}
+			return exitCode, stderr.Close()
+		}
+		if err != nil {
+			c.reset()
+			return 0, err
+		}
+	}
+}
+
+// reset stops the current PowerShell session, so the next command starts a new one.
+func (c *PowershellConnection) reset() {
+	if c.session != nil {
+		c.session.close()
+		c.session = nil
+	}
+}
+
+// powershellSession is a powershell.exe process that runs the loop script in an SSH session.
+type powershellSession struct {
+	session *ssh.Session
+	stdin   io.WriteCloser
+	marker  string
+
+	// lines receives the lines of stdout and is closed when stdout is closed.
+	lines     chan string
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// newPowershellSession starts powershell.exe in a new SSH session and sends the loop script.
+func newPowershellSession(client *ssh.Client) (*powershellSession, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return nil, err
+	}
+
+	cmd, err := parsing.EncodePwshCmd(powershellBootstrap)
+	if err != nil {
+		return nil, err
+	}
+
+	session, err := client.NewSession()
+	if err != nil {
+		return nil, err
+	}
+
+	stdin, err := session.StdinPipe()
+	if err != nil {
+		session.Close()
+		return nil, err
+	}
+	stdout, err := session.StdoutPipe()
+	if err != nil {
+		session.Close()
+		return nil, err
+	}
+
+	if err := session.Start(cmd); err != nil {
+		session.Close()
+		return nil, err
+	}
+
+	s := &powershellSession{
+		session: session,
+		stdin:   stdin,
+		marker:  "#<gowindows:" + hex.EncodeToString(b) + ">",
+		lines:   make(chan string),
+		done:    make(chan struct{}),
+	}
+	go s.read(stdout)
+
+	loop := strings.ReplaceAll(powershellLoop, "{{marker}}", s.marker)
+	if _, err := io.WriteString(stdin, base64.StdEncoding.EncodeToString([]byte(loop))+"\n"); err != nil {
+		s.close()
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// read sends the lines of stdout to the lines channel until stdout is closed or the session is closed.
+func (s *powershellSession) read(stdout io.Reader) {
+	defer close(s.lines)
+
+	r := bufio.NewReader(stdout)
+	for {
+		line, err := r.ReadString('\n')
+		if line != "" {
+			select {
+			case s.lines <- line:
+			case <-s.done:
+				return
+			}
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+// frame parses a frame of the command with the given id.
+// It returns false if the line is not a frame of the command.
+func (s *powershellSession) frame(line string, id string) (string, string, bool) {
+	line, ok := strings.CutPrefix(strings.TrimRight(line, "\r\n"), s.marker)
+	if !ok {
+		return "", "", false
+	}
+
+	parts := strings.SplitN(line, ":", 3)
+	if len(parts) != 3 || parts[0] != id {
+		return "", "", false
+	}
+
+	text, err := base64.StdEncoding.DecodeString(parts[2])
+	if err != nil {
+		return "", "", false
+	}
+
+	return parts[1], string(text), true
+}
+
+// wait waits for the process to exit and returns its exit status.
+func (s *powershellSession) wait() (int, error) {
+	err := s.session.Wait()
+
+	var exitErr *ssh.ExitError
+	if errors.As(err, &exitErr) {
+		return exitErr.ExitStatus(), nil
+	}
+
+	return 0, err
+}
+
+// close stops the process and closes the SSH session.
+func (s *powershellSession) close() {
+	s.closeOnce.Do(func() {
+		close(s.done)
+		_ = s.stdin.Close()
+		_ = s.session.Close()
+	})
+}
@@ -0,0 +1,274 @@
+package ssh
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/d-strobel/gowindows/connection"
+	"github.com/d-strobel/gowindows/parsing"
+	"github.com/stretchr/testify/suite"
+)
+
+// testRenderedUserNotFound is an error record as it is rendered by Out-String in Windows PowerShell 5.1.
+const testRenderedUserNotFound string = "Get-LocalUser : User test was not found.\r\n" +
+	"At line:1 char:1\r\n" +
+	"+ Get-LocalUser -Name test\r\n" +
+	"+ ~~~~~~~~~~~~~~~~~~~~~~~~\r\n" +
+	"    + CategoryInfo          : ObjectNotFound: (test:String) [Get-LocalUser], UserNotFoundException\r\n" +
+	"    + FullyQualifiedErrorId : UserNotFound,Microsoft.PowerShell.Commands.GetLocalUserCommand\r\n" +
+	" \r\n"
+
+// Unit test suite for the persistent PowerShell session.
+type PowershellUnitTestSuite struct {
+	suite.Suite
+}
+
+func TestPowershellUnitTestSuite(t *testing.T) {
+	suite.Run(t, &PowershellUnitTestSuite{})
+}
+
+// newTestPowershellServer starts a test server that runs the persistent PowerShell session with the script handler.
+// The counter reports how many PowerShell sessions were started.
+func newTestPowershellServer(t *testing.T, handler testScriptHandler) (*testServer, *atomic.Int32) {
+	var sessions atomic.Int32
+	loop := testPowershellLoop(handler)
+
+	server := newTestServer(t, func(cmd string, stdin io.Reader, stdout io.Writer, stderr io.Writer) int {
+		sessions.Add(1)
+		return loop(cmd, stdin, stdout, stderr)
+	})
+
+	return server, &sessions
+}
+
+func (suite *PowershellUnitTestSuite) TestNewPowershellConnection() {
+	suite.Run("should start the session and close the connection", func() {
+		server, sessions := newTestPowershellServer(suite.T(), func(script string, input []string, w *testFrameWriter) (int, bool) {
+			return 0, false
+		})
+
+		conn, err := NewPowershellConnection(server.clientConfig())
+		suite.Require().NoError(err)
+
+		_, err = conn.Run(context.Background(), "Get-Date")
+		suite.NoError(err)
+		suite.Equal(int32(1), sessions.Load())
+
+		suite.NoError(conn.Close())
+
+		// A closed connection cannot run commands and is closed only once.
+		_, err = conn.Run(context.Background(), "Get-Date")
+		suite.EqualError(err, "ssh: the powershell session is closed")
+		suite.NoError(conn.Close())
+	})
+}
+
+func (suite *PowershellUnitTestSuite) TestRun() {
+	suite.Run("should run all commands in the same session", func() {
+		var scripts []string
+		server, sessions := newTestPowershellServer(suite.T(), func(script string, input []string, w *testFrameWriter) (int, bool) {
+			scripts = append(scripts, script)
+			w.output("output of " + script)
+			return 0, false
+		})
+		conn, err := NewPowershellConnection(server.clientConfig())
+		suite.Require().NoError(err)
+		defer conn.Close()
+
+		script := "Get-DnsServerResourceRecord -ZoneName 'example.com' |\r\n  ConvertTo-Json -Compress"
+		for _, cmd := range []string{script, "Get-Date", "Write-Output 'a:b'"} {
+			result, err := conn.RunWithPowershell(context.Background(), cmd)
+			suite.NoError(err)
+			suite.Equal(connection.CmdResult{StdOut: "output of " + cmd + "\r\n"}, result)
+		}
+
+		suite.Equal([]string{script, "Get-Date", "Write-Output 'a:b'"}, scripts)
+		suite.Equal(int32(1), sessions.Load())
+	})
+
+	suite.Run("should write lines without a frame to stdout", func() {
+		server, _ := newTestPowershellServer(suite.T(), func(script string, input []string, w *testFrameWriter) (int, bool) {
+			fmt.Fprint(w.w, "written by Write-Host\r\n")
+			w.output("output")
+			return 0, false
+		})
+		conn, err := NewPowershellConnection(server.clientConfig())
+		suite.Require().NoError(err)
+		defer conn.Close()
+
+		result, err := conn.Run(context.Background(), "Write-Host 'written by Write-Host'; 'output'")
+		suite.NoError(err)
+		suite.Equal("written by Write-Host\r\noutput\r\n", result.StdOut)
+	})
+
+	suite.Run("should return the error records as CLIXML error stream and the exit code", func() {
+		server, _ := newTestPowershellServer(suite.T(), func(script string, input []string, w *testFrameWriter) (int, bool) {
+			w.errorRecord(testRenderedUserNotFound)
+			return 2, false
+		})
+		conn, err := NewPowershellConnection(server.clientConfig())
+		suite.Require().NoError(err)
+		defer conn.Close()
+
+		result, err := conn.RunWithPowershell(context.Background(), "Get-LocalUser -Name test; cmd /c exit 2")
+		suite.NoError(err)
+		suite.Equal(2, result.ExitCode)
+		suite.Empty(result.StdOut)
+
+		records, err := parsing.DecodeCliXmlErrRecords(result.StdErr)
+		suite.NoError(err)
+		suite.Equal([]parsing.ErrorRecord{{
+			FullyQualifiedErrorId: "UserNotFound,Microsoft.PowerShell.Commands.GetLocalUserCommand",
+			CategoryInfo: parsing.CategoryInfo{
+				Category:   "ObjectNotFound",
+				Activity:   "Get-LocalUser",
+				Reason:     "UserNotFoundException",
+				TargetName: "test",
+				TargetType: "String",
+			},
+			Exception:      parsing.ExceptionInfo{Type: "UserNotFoundException", Message: "User test was not found."},
+			ScriptPosition: parsing.ScriptPosition{Line: 1, Column: 1, Text: "Get-LocalUser -Name test"},
+		}}, records)
+	})
+
+	suite.Run("should start a new session after the process exited", func() {
+		server, sessions := newTestPowershellServer(suite.T(), func(script string, input []string, w *testFrameWriter) (int, bool) {
+			if script == "exit 3" {
+				return 3, true
+			}
+			w.output(script)
+			return 0, false
+		})
+		conn, err := NewPowershellConnection(server.clientConfig())
+		suite.Require().NoError(err)
+		defer conn.Close()
+
+		result, err := conn.Run(context.Background(), "exit 3")
+		suite.NoError(err)
+		suite.Equal(3, result.ExitCode)
+
+		result, err = conn.Run(context.Background(), "next")
+		suite.NoError(err)
+		suite.Equal(connection.CmdResult{StdOut: "next\r\n"}, result)
+		suite.Equal(int32(2), sessions.Load())
+	})
+
+	suite.Run("should be safe for concurrent use", func() {
+		server, sessions := newTestPowershellServer(suite.T(), func(script string, input []string, w *testFrameWriter) (int, bool) {
+			w.output(script)
+			w.errorRecord("error of " + script)
+			return len(script), false
+		})
+		conn, err := NewPowershellConnection(server.clientConfig())
+		suite.Require().NoError(err)
+		defer conn.Close()
+
+		var wg sync.WaitGroup
+		results := make([]connection.CmdResult, 20)
+		errs := make([]error, len(results))
+		for i := range results {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				results[i], errs[i] = conn.Run(context.Background(), strings.Repeat("x", i+1))
+			}()
+		}
+		wg.Wait()
+
+		for i, result := range results {
+			suite.NoError(errs[i])
+			suite.Equal(strings.Repeat("x", i+1)+"\r\n", result.StdOut)
+			suite.Equal(i+1, result.ExitCode)
+
+			decoded, err := parsing.DecodeCliXmlErr(result.StdErr)
+			suite.NoError(err)
+			suite.Equal("error of "+strings.Repeat("x", i+1), decoded)
+		}
+		suite.Equal(int32(1), sessions.Load())
+	})
+}
+
+func (suite *PowershellUnitTestSuite) TestStream() {
+	suite.Run("should pass every line of stdin as input", func() {
+		server, _ := newTestPowershellServer(suite.T(), func(script string, input []string, w *testFrameWriter) (int, bool) {
+			for _, line := range input {
+				w.output(strings.ToUpper(line))
+			}
+			return 0, false
+		})
+		conn, err := NewPowershellConnection(server.clientConfig())
+		suite.Require().NoError(err)
+		defer conn.Close()
+
+		var lines []string
+		stdout := connection.NewLineWriter(func(line string) { lines = append(lines, line) })
+
+		exitCode, err := conn.StreamWithPowershell(context.Background(), "$input | ForEach-Object { $_.ToUpper() }", connection.Streams{
+			Stdin:  strings.NewReader("first\r\nsecond\nthird:x\n"),
+			Stdout: stdout,
+		})
+		suite.NoError(err)
+		suite.NoError(stdout.Close())
+		suite.Equal(0, exitCode)
+		suite.Equal([]string{"FIRST", "SECOND", "THIRD:X"}, lines)
+	})
+
+	suite.Run("should stop the session if the context is done", func() {
+		release := make(chan struct{})
+		defer close(release)
+
+		server, sessions := newTestPowershellServer(suite.T(), func(script string, input []string, w *testFrameWriter) (int, bool) {
+			if script == "Start-Sleep -Seconds 60" {
+				<-release
+				return 0, false
+			}
+			w.output(script)
+			return 0, false
+		})
+		conn, err := NewPowershellConnection(server.clientConfig())
+		suite.Require().NoError(err)
+		defer conn.Close()
+
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+		defer cancel()
+
+		_, err = conn.Stream(ctx, "Start-Sleep -Seconds 60", connection.Streams{})
+		suite.ErrorIs(err, context.DeadlineExceeded)
+
+		// The next command starts a new session.
+		result, err := conn.Run(context.Background(), "next")
+		suite.NoError(err)
+		suite.Equal("next\r\n", result.StdOut)
+		suite.Equal(int32(2), sessions.Load())
+	})
+}
+
+func (suite *PowershellUnitTestSuite) TestPool() {
+	suite.Run("should keep the session with the default health check", func() {
+		server, sessions := newTestPowershellServer(suite.T(), func(script string, input []string, w *testFrameWriter) (int, bool) {
+			if strings.HasPrefix(script, "exit") {
+				return 0, true
+			}
+			w.output(script)
+			return 0, false
+		})
+		pool, err := connection.NewPool(func() (connection.Connection, error) {
+			return NewPowershellConnection(server.clientConfig())
+		}, connection.PoolConfig{Size: 1, HealthCheckInterval: time.Nanosecond})
+		suite.Require().NoError(err)
+		defer pool.Close()
+
+		for _, cmd := range []string{"first", "second", "third"} {
+			result, err := pool.Run(context.Background(), cmd)
+			suite.NoError(err)
+			suite.Equal(cmd+"\r\n", result.StdOut)
+		}
+		suite.Equal(int32(1), sessions.Load())
+	})
+}
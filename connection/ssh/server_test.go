@@ -0,0 +1,468 @@
+package ssh
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"net"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/d-strobel/gowindows/parsing"
+	"github.com/pkg/sftp"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// Credentials of the test server.
+const (
+	testServerUsername string = "vagrant"
+	testServerPassword string = "vagrant"
+)
+
+// testCommandHandler runs a command on the test server and returns its exit status.
+type testCommandHandler func(cmd string, stdin io.Reader, stdout io.Writer, stderr io.Writer) int
+
+// testServer is an in-process SSH server that runs exec requests with a testCommandHandler.
+type testServer struct {
+	listener net.Listener
+	config   *ssh.ServerConfig
+	handler  testCommandHandler
+	wg       sync.WaitGroup
+
+	mu             sync.Mutex
+	authorizedKeys []ssh.PublicKey
+	userCA         ssh.PublicKey
+	authMethods    []string
+	forwardedAddrs []string
+	conns          []net.Conn
+	globalRequests []string
+
+	// sftpDir is the working directory of the SFTP subsystem, which is disabled if it is empty.
+	sftpDir string
+}
+
+// newTestServer starts a new test server on a random local port.
+// The server is closed when the test finishes.
+func newTestServer(t *testing.T, handler testCommandHandler) *testServer {
+	t.Helper()
+	return newTestServerOn(t, "127.0.0.1:0", handler)
+}
+
+// newTestServerOn starts a new test server on the address.
+func newTestServerOn(t *testing.T, address string, handler testCommandHandler) *testServer {
+	t.Helper()
+
+	_, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hostKey, err := ssh.NewSignerFromKey(privateKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := &testServer{handler: handler}
+	s.config = &ssh.ServerConfig{
+		PasswordCallback: func(c ssh.ConnMetadata, password []byte) (*ssh.Permissions, error) {
+			if c.User() == testServerUsername && string(password) == testServerPassword {
+				return nil, nil
+			}
+			return nil, ssh.ErrNoAuth
+		},
+		PublicKeyCallback: s.publicKeyCallback,
+		KeyboardInteractiveCallback: func(c ssh.ConnMetadata, client ssh.KeyboardInteractiveChallenge) (*ssh.Permissions, error) {
+			answers, err := client(c.User(), "", []string{"Password: "}, []bool{false})
+			if err == nil && c.User() == testServerUsername && len(answers) == 1 && answers[0] == testServerPassword {
+				return nil, nil
+			}
+			return nil, ssh.ErrNoAuth
+		},
+		AuthLogCallback: func(c ssh.ConnMetadata, method string, err error) {
+			if err == nil {
+				s.mu.Lock()
+				s.authMethods = append(s.authMethods, method)
+				s.mu.Unlock()
+			}
+		},
+	}
+	s.config.AddHostKey(hostKey)
+
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.listener = listener
+	s.wg.Add(1)
+	go s.serve()
+
+	t.Cleanup(func() {
+		listener.Close()
+		s.wg.Wait()
+	})
+
+	return s
+}
+
+// clientConfig returns a client configuration for the test server.
+func (s *testServer) clientConfig() *Config {
+	addr := s.listener.Addr().(*net.TCPAddr)
+	return &Config{
+		Host:     addr.IP.String(),
+		Port:     addr.Port,
+		Username: testServerUsername,
+		Password: testServerPassword,
+		Insecure: true,
+	}
+}
+
+// authorizeKey authorizes the public key for the test user.
+func (s *testServer) authorizeKey(key ssh.PublicKey) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.authorizedKeys = append(s.authorizedKeys, key)
+}
+
+// trustUserCA trusts the user certificates signed by the CA.
+func (s *testServer) trustUserCA(key ssh.PublicKey) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.userCA = key
+}
+
+// successfulAuthMethods returns the authentication methods the clients authenticated with.
+func (s *testServer) successfulAuthMethods() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.authMethods...)
+}
+
+// publicKeyCallback accepts the authorized keys and the user certificates of the trusted CA.
+func (s *testServer) publicKeyCallback(c ssh.ConnMetadata, key ssh.PublicKey) (*ssh.Permissions, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if c.User() != testServerUsername {
+		return nil, ssh.ErrNoAuth
+	}
+
+	if _, ok := key.(*ssh.Certificate); ok {
+		checker := &ssh.CertChecker{
+			IsUserAuthority: func(auth ssh.PublicKey) bool {
+				return s.userCA != nil && bytes.Equal(auth.Marshal(), s.userCA.Marshal())
+			},
+		}
+		return checker.Authenticate(c, key)
+	}
+
+	for _, authorizedKey := range s.authorizedKeys {
+		if bytes.Equal(authorizedKey.Marshal(), key.Marshal()) {
+			return nil, nil
+		}
+	}
+
+	return nil, ssh.ErrNoAuth
+}
+
+// serve accepts new connections until the listener is closed.
+func (s *testServer) serve() {
+	defer s.wg.Done()
+
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+
+		s.wg.Add(1)
+		go s.handleConn(conn)
+	}
+}
+
+// handleConn runs the SSH handshake and handles the session channels of a connection.
+func (s *testServer) handleConn(conn net.Conn) {
+	defer s.wg.Done()
+	defer conn.Close()
+
+	s.mu.Lock()
+	s.conns = append(s.conns, conn)
+	s.mu.Unlock()
+
+	_, chans, reqs, err := ssh.NewServerConn(conn, s.config)
+	if err != nil {
+		return
+	}
+	go s.handleGlobalRequests(reqs)
+
+	for newChannel := range chans {
+		if newChannel.ChannelType() == "direct-tcpip" {
+			s.wg.Add(1)
+			go s.handleDirectTCPIP(newChannel)
+			continue
+		}
+
+		if newChannel.ChannelType() != "session" {
+			_ = newChannel.Reject(ssh.UnknownChannelType, "unknown channel type")
+			continue
+		}
+
+		channel, requests, err := newChannel.Accept()
+		if err != nil {
+			return
+		}
+		go s.handleSession(channel, requests)
+	}
+}
+
+// handleGlobalRequests records and rejects the global requests of a connection, like keepalive requests.
+func (s *testServer) handleGlobalRequests(reqs <-chan *ssh.Request) {
+	for req := range reqs {
+		s.mu.Lock()
+		s.globalRequests = append(s.globalRequests, req.Type)
+		s.mu.Unlock()
+
+		if req.WantReply {
+			_ = req.Reply(false, nil)
+		}
+	}
+}
+
+// receivedGlobalRequests returns the types of the global requests the server received.
+func (s *testServer) receivedGlobalRequests() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.globalRequests...)
+}
+
+// dropConnections closes all connections of the server, like a firewall dropping them.
+func (s *testServer) dropConnections() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, conn := range s.conns {
+		conn.Close()
+	}
+	s.conns = nil
+}
+
+// handleDirectTCPIP forwards a direct-tcpip channel to its destination, like a jump host does.
+func (s *testServer) handleDirectTCPIP(newChannel ssh.NewChannel) {
+	defer s.wg.Done()
+
+	var payload struct {
+		Host       string
+		Port       uint32
+		OriginHost string
+		OriginPort uint32
+	}
+	if err := ssh.Unmarshal(newChannel.ExtraData(), &payload); err != nil {
+		_ = newChannel.Reject(ssh.ConnectionFailed, err.Error())
+		return
+	}
+
+	addr := net.JoinHostPort(payload.Host, strconv.Itoa(int(payload.Port)))
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		_ = newChannel.Reject(ssh.ConnectionFailed, err.Error())
+		return
+	}
+	defer conn.Close()
+
+	channel, requests, err := newChannel.Accept()
+	if err != nil {
+		return
+	}
+	defer channel.Close()
+	go ssh.DiscardRequests(requests)
+
+	s.mu.Lock()
+	s.forwardedAddrs = append(s.forwardedAddrs, addr)
+	s.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		_, _ = io.Copy(conn, channel)
+		if tcpConn, ok := conn.(*net.TCPConn); ok {
+			_ = tcpConn.CloseWrite()
+		}
+		close(done)
+	}()
+	_, _ = io.Copy(channel, conn)
+	_ = channel.CloseWrite()
+	<-done
+}
+
+// forwarded returns the addresses the server forwarded direct-tcpip channels to.
+func (s *testServer) forwarded() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.forwardedAddrs...)
+}
+
+// enableSFTP enables the SFTP subsystem of the server with a temporary working directory and returns the directory.
+func (s *testServer) enableSFTP(t *testing.T) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sftpDir = t.TempDir()
+	return s.sftpDir
+}
+
+// serveSFTP serves the SFTP subsystem on the channel until the client closes it.
+func (s *testServer) serveSFTP(channel ssh.Channel, requests <-chan *ssh.Request) {
+	s.mu.Lock()
+	dir := s.sftpDir
+	s.mu.Unlock()
+
+	go ssh.DiscardRequests(requests)
+
+	server, err := sftp.NewServer(channel, sftp.WithServerWorkingDirectory(dir))
+	if err != nil {
+		return
+	}
+	_ = server.Serve()
+	_ = server.Close()
+}
+
+// handleSession runs the command of an exec request and sends its exit status.
+// It serves the SFTP subsystem if it is enabled.
+func (s *testServer) handleSession(channel ssh.Channel, requests <-chan *ssh.Request) {
+	defer channel.Close()
+
+	for req := range requests {
+		if req.Type == "subsystem" {
+			var payload struct{ Name string }
+			s.mu.Lock()
+			enabled := s.sftpDir != ""
+			s.mu.Unlock()
+			if err := ssh.Unmarshal(req.Payload, &payload); err != nil || payload.Name != "sftp" || !enabled {
+				_ = req.Reply(false, nil)
+				continue
+			}
+			_ = req.Reply(true, nil)
+			s.serveSFTP(channel, requests)
+			return
+		}
+
+		if req.Type != "exec" {
+			if req.WantReply {
+				_ = req.Reply(req.Type == "signal", nil)
+			}
+			continue
+		}
+
+		var payload struct{ Command string }
+		if err := ssh.Unmarshal(req.Payload, &payload); err != nil {
+			_ = req.Reply(false, nil)
+			return
+		}
+		_ = req.Reply(true, nil)
+
+		// Discard further requests like signals while the command is running.
+		go func() {
+			for req := range requests {
+				if req.WantReply {
+					_ = req.Reply(req.Type == "signal", nil)
+				}
+			}
+		}()
+
+		status := s.handler(payload.Command, channel, channel, channel.Stderr())
+		_, _ = channel.SendRequest("exit-status", false, ssh.Marshal(struct{ Status uint32 }{uint32(status)}))
+		return
+	}
+}
+
+// testScriptHandler runs a script of the persistent PowerShell session on the test server.
+// It writes the output and the error records of the script with the frame writer and returns the exit code of the script.
+// If exit is true, the PowerShell process exits with the exit code, like it does for the exit keyword.
+type testScriptHandler func(script string, input []string, w *testFrameWriter) (exitCode int, exit bool)
+
+// testMarkerRegexp matches the marker in the loop script of the persistent PowerShell session.
+var testMarkerRegexp = regexp.MustCompile(`\$marker = '([^']+)'`)
+
+// testPowershellLoop returns a command handler that stands in for the loop script of the persistent PowerShell session.
+// It runs every script of the session with the script handler.
+func testPowershellLoop(handler testScriptHandler) testCommandHandler {
+	return func(cmd string, stdin io.Reader, stdout io.Writer, stderr io.Writer) int {
+		bootstrap, err := parsing.EncodePwshCmd(powershellBootstrap)
+		if err != nil || cmd != bootstrap {
+			fmt.Fprintf(stderr, "unexpected command %s", cmd)
+			return 1
+		}
+
+		// The first line contains the loop script.
+		r := bufio.NewReader(stdin)
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return 1
+		}
+		loop, err := base64.StdEncoding.DecodeString(strings.TrimSpace(line))
+		if err != nil {
+			return 1
+		}
+		marker := testMarkerRegexp.FindStringSubmatch(string(loop))
+		if marker == nil {
+			return 1
+		}
+
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				return 0
+			}
+
+			parts := strings.Split(strings.TrimSpace(line), ":")
+			script, err := base64.StdEncoding.DecodeString(parts[1])
+			if err != nil {
+				return 1
+			}
+
+			var input []string
+			if len(parts) == 3 {
+				text, err := base64.StdEncoding.DecodeString(parts[2])
+				if err != nil {
+					return 1
+				}
+				if len(text) > 0 {
+					input = regexp.MustCompile(`\r?\n`).Split(strings.TrimRight(string(text), "\r\n"), -1)
+				}
+			}
+
+			w := &testFrameWriter{w: stdout, marker: marker[1], id: parts[0]}
+			exitCode, exit := handler(string(script), input, w)
+			if exit {
+				return exitCode
+			}
+			w.frame(powershellFrameExit, strconv.Itoa(exitCode))
+		}
+	}
+}
+
+// testFrameWriter writes the frames of a script of the persistent PowerShell session.
+type testFrameWriter struct {
+	w      io.Writer
+	marker string
+	id     string
+}
+
+// output writes an output object.
+func (w *testFrameWriter) output(text string) {
+	w.frame(powershellFrameOutput, text)
+}
+
+// errorRecord writes an error record as it is rendered by Out-String.
+func (w *testFrameWriter) errorRecord(text string) {
+	w.frame(powershellFrameError, text)
+}
+
+// frame writes a frame of the given kind.
+func (w *testFrameWriter) frame(kind string, text string) {
+	fmt.Fprintf(w.w, "%s%s:%s:%s\r\n", w.marker, w.id, kind, base64.StdEncoding.EncodeToString([]byte(text)))
+}
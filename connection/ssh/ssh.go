@@ -3,15 +3,23 @@
 //
 // Key Features:
 //   - Establishes SSH connections with remote hosts based on provided configuration.
-//   - Handles authentication mechanisms such as password-based and privatekey-based authentication.
+//   - Handles authentication mechanisms such as password, private key, SSH agent, user certificate and keyboard-interactive authentication.
 //   - Supports execution of commands including cmd and powershell commands.
+//   - Supports connection timeouts, keepalives, custom algorithms, proxies and reconnects when the connection died.
+//   - Tunnels SSH and other connections like WinRM through one or more jump hosts.
+//   - Runs many PowerShell commands in a single long-lived powershell.exe process with PowershellConnection.
 package ssh
 
 import (
+	"bytes"
 	"context"
+	"errors"
 	"fmt"
 	"io"
+	"net"
+	"strconv"
 	"sync"
+	"time"
 
 	"github.com/d-strobel/gowindows/connection"
 	"github.com/d-strobel/gowindows/parsing"
@@ -20,12 +28,191 @@ import (
 
 // Connection represents an SSH connection.
 // It holds a client object for interacting with the remote system.
+// If the client dies, e.g. because a firewall dropped the connection, the connection reconnects with the next command.
 type Connection struct {
 	Client *ssh.Client
+
+	config *Config
+
+	// mu guards the client and the jump clients while reconnecting.
+	mu sync.Mutex
+	// jumpClients are the clients of the jump hosts the connection is tunnelled through.
+	jumpClients []*ssh.Client
+	// dead is closed when the client died.
+	dead   chan struct{}
+	closed bool
 }
 
 // NewConnection creates a new SSH client based on the provided configuration.
+// If jump hosts are configured, the connection is tunnelled through them.
 func NewConnection(config *Config) (*Connection, error) {
+	// Validate configuration before connecting to the jump hosts
+	if err := config.validate(); err != nil {
+		return nil, err
+	}
+
+	c := &Connection{config: config}
+	if err := c.connect(); err != nil {
+		return nil, err
+	}
+
+	return c, nil
+}
+
+// connect connects to the host through the jump hosts and watches the new client.
+func (c *Connection) connect() error {
+	jumpClients, err := dialJumpHosts(c.config.JumpHosts)
+	if err != nil {
+		return err
+	}
+
+	var via *ssh.Client
+	if len(jumpClients) > 0 {
+		via = jumpClients[len(jumpClients)-1]
+	}
+
+	client, err := dialHost(c.config, via)
+	if err != nil {
+		closeClients(jumpClients)
+		return err
+	}
+
+	c.Client = client
+	c.jumpClients = jumpClients
+	c.dead = make(chan struct{})
+
+	dead := c.dead
+	go func() {
+		_ = client.Wait()
+		close(dead)
+	}()
+
+	if c.config.KeepAliveInterval > 0 {
+		go keepAlive(client, c.config.KeepAliveInterval, dead)
+	}
+
+	return nil
+}
+
+// client returns the SSH client of the connection.
+// If the client died, it reconnects to the host first.
+func (c *Connection) client() (*ssh.Client, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.closed {
+		return nil, errors.New("ssh: the connection is closed")
+	}
+
+	// A connection without configuration was created from an existing client and cannot reconnect.
+	if c.config == nil {
+		return c.Client, nil
+	}
+
+	select {
+	case <-c.dead:
+		return c.reconnectLocked()
+	default:
+		return c.Client, nil
+	}
+}
+
+// reconnect closes the client and reconnects to the host.
+// If another command already replaced the client, the new client is returned.
+func (c *Connection) reconnect(client *ssh.Client) (*ssh.Client, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.closed {
+		return nil, errors.New("ssh: the connection is closed")
+	}
+
+	if c.Client != client {
+		return c.Client, nil
+	}
+
+	client.Close()
+	<-c.dead
+
+	return c.reconnectLocked()
+}
+
+// reconnectLocked replaces the dead client with a new one.
+// The caller must hold the mutex.
+func (c *Connection) reconnectLocked() (*ssh.Client, error) {
+	c.Client.Close()
+	closeClients(c.jumpClients)
+	if err := c.connect(); err != nil {
+		return nil, fmt.Errorf("ssh: failed to reconnect: %w", err)
+	}
+
+	return c.Client, nil
+}
+
+// newSession opens a new session on the SSH client.
+// If the client died unnoticed, it reconnects once and opens the session on the new client.
+func (c *Connection) newSession() (*ssh.Session, error) {
+	client, err := c.client()
+	if err != nil {
+		return nil, err
+	}
+
+	s, err := client.NewSession()
+	if err == nil || c.config == nil {
+		return s, err
+	}
+
+	// The session may have been rejected by a living host.
+	if _, _, keepAliveErr := client.SendRequest("keepalive@openssh.com", true, nil); keepAliveErr == nil {
+		return nil, err
+	}
+
+	client, err = c.reconnect(client)
+	if err != nil {
+		return nil, err
+	}
+
+	return client.NewSession()
+}
+
+// keepAlive sends keepalive requests to the host in the interval until the client died.
+// If the host does not answer within the interval, the client is closed.
+func keepAlive(client *ssh.Client, interval time.Duration, dead <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-dead:
+			return
+		case <-ticker.C:
+		}
+
+		// The reply is awaited in the background, because SendRequest does not support a timeout.
+		replied := make(chan error, 1)
+		go func() {
+			_, _, err := client.SendRequest("keepalive@openssh.com", true, nil)
+			replied <- err
+		}()
+
+		select {
+		case <-dead:
+			return
+		case err := <-replied:
+			if err == nil {
+				continue
+			}
+		case <-time.After(interval):
+		}
+
+		client.Close()
+		return
+	}
+}
+
+// dialHost connects to the host of the SSH configuration and performs the SSH handshake.
+// If via is not nil, the host is dialed through the client of a jump host.
+func dialHost(config *Config, via *ssh.Client) (*ssh.Client, error) {
 
 	// Validate configuration
 	if err := config.validate(); err != nil {
@@ -37,40 +224,71 @@ func NewConnection(config *Config) (*Connection, error) {
 		return nil, err
 	}
 
-	// Parse SSH host string
-	sshHost := fmt.Sprintf("%s:%d", config.Host, config.Port)
+	// Parse SSH host string, IPv6 addresses are enclosed in brackets
+	sshHost := net.JoinHostPort(config.Host, strconv.Itoa(config.Port))
 
 	// Check known host key callback
 	knownHostCallback, err := config.knownHostCallback()
 	if err != nil {
-		return nil, fmt.Errorf("ssh: known host callback failed with error: %s", err)
+		return nil, fmt.Errorf("ssh: known host callback failed with error: %w", err)
 	}
 
 	// Authentication method
-	authMethod, err := config.authenticationMethod()
+	authMethod, agentConn, err := config.authenticationMethod()
 	if err != nil {
-		return nil, fmt.Errorf("ssh: authentication method failed with error: %s", err)
+		return nil, fmt.Errorf("ssh: authentication method failed with error: %w", err)
+	}
+
+	// The SSH agent is only needed for the handshake.
+	if agentConn != nil {
+		defer agentConn.Close()
 	}
 
 	// Configuration
-	sshConfig := &ssh.ClientConfig{
-		User:            config.Username,
-		Auth:            authMethod,
-		HostKeyCallback: knownHostCallback,
+	sshConfig := config.clientConfig(knownHostCallback, authMethod)
+
+	// Connect to the remote server directly or open a direct-tcpip channel through the jump host
+	var conn net.Conn
+	if via == nil {
+		conn, err = config.dial("tcp", sshHost)
+		if err != nil {
+			return nil, fmt.Errorf("ssh: %w", err)
+		}
+	} else {
+		conn, err = via.Dial("tcp", sshHost)
+		if err != nil {
+			return nil, fmt.Errorf("ssh: failed to dial %s through the jump host: %w", sshHost, err)
+		}
 	}
 
-	// Connect to the remote server and perform the SSH handshake
-	client, err := ssh.Dial("tcp", sshHost, sshConfig)
+	// Perform the SSH handshake within the timeout.
+	// Channels of a jump host do not support deadlines, so the error is ignored.
+	_ = conn.SetDeadline(time.Now().Add(config.Timeout))
+
+	clientConn, chans, reqs, err := ssh.NewClientConn(conn, sshHost, sshConfig)
 	if err != nil {
-		return nil, fmt.Errorf("ssh: %s", err)
+		conn.Close()
+		return nil, fmt.Errorf("ssh: %w", err)
 	}
 
-	return &Connection{Client: client}, nil
+	_ = conn.SetDeadline(time.Time{})
+
+	return ssh.NewClient(clientConn, chans, reqs), nil
 }
 
-// Close closes the SSH connection.
+// Close closes the SSH connection and the connections to the jump hosts.
 func (c *Connection) Close() error {
-	return c.Client.Close()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.closed {
+		return nil
+	}
+	c.closed = true
+
+	err := c.Client.Close()
+	closeClients(c.jumpClients)
+	return err
 }
 
 // RunWithPowershell runs a command using the configured SSH connection and context via Powershell.
@@ -85,85 +303,104 @@ func (c *Connection) RunWithPowershell(ctx context.Context, cmd string) (connect
 }
 
 // Run runs a command using the configured SSH connection and context.
-// It returns the result of the command execution, including stdout and stderr.
+// It returns the result of the command execution, including stdout, stderr and the exit code.
 func (c *Connection) Run(ctx context.Context, cmd string) (connection.CmdResult, error) {
-	var r connection.CmdResult
+	var stdout, stderr bytes.Buffer
 
-	// Open a new SSH session.
-	s, err := c.Client.NewSession()
+	exitCode, err := c.Stream(ctx, cmd, connection.Streams{Stdout: &stdout, Stderr: &stderr})
 	if err != nil {
-		return r, err
+		return connection.CmdResult{}, err
 	}
-	defer s.Close()
 
-	// Prepare channels for stdout, stderr and errors.
-	stdoutChan := make(chan string)
-	stderrChan := make(chan string)
-	errChan := make(chan error)
-
-	// Use a WaitGroup to wait for the goroutines to finish.
-	var wg sync.WaitGroup
-
-	// Goroutine to handle stdout.
-	wg.Add(1)
-	go func() {
-		defer wg.Done()
-
-		stdout, err := s.StdoutPipe()
-		if err != nil {
-			errChan <- err
-			return
-		}
+	return connection.CmdResult{
+		StdOut:   stdout.String(),
+		StdErr:   stderr.String(),
+		ExitCode: exitCode,
+	}, nil
+}
 
-		stdoutBytes, err := io.ReadAll(stdout)
-		if err != nil {
-			errChan <- err
-			return
-		}
-		stdoutChan <- string(stdoutBytes)
-	}()
+// StreamWithPowershell runs a command using the configured SSH connection and context via Powershell.
+// It writes stdout and stderr to the streams as the data arrives and returns the exit code of the command.
+func (c *Connection) StreamWithPowershell(ctx context.Context, cmd string, streams connection.Streams) (int, error) {
+	// Prepare powershell command.
+	pwshCmd, err := parsing.EncodePwshCmd(cmd)
+	if err != nil {
+		return 0, err
+	}
 
-	// Goroutine to handle stderr.
-	wg.Add(1)
-	go func() {
-		defer wg.Done()
+	return c.Stream(ctx, pwshCmd, streams)
+}
 
-		stderr, err := s.StderrPipe()
-		if err != nil {
-			errChan <- err
-			return
-		}
+// Stream runs a command using the configured SSH connection and context.
+// It writes stdout and stderr to the streams as the data arrives and returns the exit code of the command.
+func (c *Connection) Stream(ctx context.Context, cmd string, streams connection.Streams) (int, error) {
+	// Open a new SSH session.
+	s, err := c.newSession()
+	if err != nil {
+		return 0, err
+	}
+	defer s.Close()
 
-		stderrBytes, err := io.ReadAll(stderr)
-		if err != nil {
-			errChan <- err
-			return
-		}
-		stderrChan <- string(stderrBytes)
-	}()
+	// The session copies the output in the background,
+	// so the writers are detached from the streams when the context is done.
+	stdout := &detachableWriter{w: streams.Stdout}
+	stderr := &detachableWriter{w: streams.Stderr}
+	s.Stdin = streams.Stdin
+	s.Stdout = stdout
+	s.Stderr = stderr
 
 	// Start the command execution.
 	if err := s.Start(cmd); err != nil {
-		return r, err
+		return 0, err
 	}
 
-	// Wait for the goroutines to finish.
+	// Wait for the command to exit.
+	// The channel is buffered, so the goroutine does not leak if the context is done first.
+	errChan := make(chan error, 1)
 	go func() {
-		wg.Wait()
-		close(stdoutChan)
-		close(stderrChan)
-		close(errChan)
+		errChan <- s.Wait()
 	}()
 
 	// Wait for the command to complete with context support.
 	select {
 	case <-ctx.Done():
 		_ = s.Signal(ssh.SIGINT)
-		return r, ctx.Err()
+		stdout.detach()
+		stderr.detach()
+		return 0, ctx.Err()
 	case err := <-errChan:
-		return r, err
-	case r.StdOut = <-stdoutChan:
-		r.StdErr = <-stderrChan
-		return r, nil
+		// A non-zero exit status is not an error of the connection.
+		var exitErr *ssh.ExitError
+		if errors.As(err, &exitErr) {
+			return exitErr.ExitStatus(), nil
+		}
+
+		return 0, err
+	}
+}
+
+// detachableWriter is an io.Writer that discards all writes after it was detached from the underlying writer.
+type detachableWriter struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+// Write writes p to the underlying writer.
+func (d *detachableWriter) Write(p []byte) (int, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.w == nil {
+		return len(p), nil
 	}
+	return d.w.Write(p)
+}
+
+// detach detaches the writer from the underlying writer.
+// It waits for a running write to finish.
+func (d *detachableWriter) detach() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.w = nil
 }
@@ -1,9 +1,19 @@
 package ssh
 
 import (
+	"context"
+	"fmt"
+	"io"
+	"net"
 	"os/user"
+	"slices"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
+	"github.com/d-strobel/gowindows/connection"
+	"github.com/d-strobel/gowindows/parsing"
 	"github.com/stretchr/testify/suite"
 )
 
@@ -26,3 +36,308 @@ func (suite *SSHUnitTestSuite) SetupSuite() {
 	suite.Require().NoError(err)
 	suite.currentUserHomeDir = user.HomeDir
 }
+
+func (suite *SSHUnitTestSuite) TestRun() {
+	suite.Run("should return stdout, stderr and the exit code", func() {
+		server := newTestServer(suite.T(), func(cmd string, stdin io.Reader, stdout io.Writer, stderr io.Writer) int {
+			fmt.Fprintf(stdout, "stdout of %s", cmd)
+			fmt.Fprint(stderr, "stderr")
+			return 0
+		})
+		conn, err := NewConnection(server.clientConfig())
+		suite.Require().NoError(err)
+		defer conn.Close()
+
+		result, err := conn.Run(context.Background(), "whoami")
+		suite.NoError(err)
+		suite.Equal(connection.CmdResult{StdOut: "stdout of whoami", StdErr: "stderr", ExitCode: 0}, result)
+	})
+
+	suite.Run("should return a non-zero exit code without an error", func() {
+		server := newTestServer(suite.T(), func(cmd string, stdin io.Reader, stdout io.Writer, stderr io.Writer) int {
+			return 3
+		})
+		conn, err := NewConnection(server.clientConfig())
+		suite.Require().NoError(err)
+		defer conn.Close()
+
+		result, err := conn.Run(context.Background(), "exit 3")
+		suite.NoError(err)
+		suite.Equal(3, result.ExitCode)
+		suite.Empty(result.StdErr)
+	})
+
+	suite.Run("should return the context error if the context is done", func() {
+		running := make(chan struct{})
+		server := newTestServer(suite.T(), func(cmd string, stdin io.Reader, stdout io.Writer, stderr io.Writer) int {
+			close(running)
+			_, _ = io.Copy(io.Discard, stdin)
+			return 0
+		})
+		conn, err := NewConnection(server.clientConfig())
+		suite.Require().NoError(err)
+		defer conn.Close()
+
+		ctx, cancel := context.WithCancel(context.Background())
+		go func() {
+			<-running
+			cancel()
+		}()
+
+		_, err = conn.Run(ctx, "Start-Sleep -Seconds 60")
+		suite.ErrorIs(err, context.Canceled)
+	})
+}
+
+func (suite *SSHUnitTestSuite) TestRunWithPowershell() {
+	suite.Run("should run the encoded command", func() {
+		server := newTestServer(suite.T(), func(cmd string, stdin io.Reader, stdout io.Writer, stderr io.Writer) int {
+			fmt.Fprint(stdout, cmd)
+			return 1
+		})
+		conn, err := NewConnection(server.clientConfig())
+		suite.Require().NoError(err)
+		defer conn.Close()
+
+		expectedCmd, err := parsing.EncodePwshCmd("Get-LocalUser")
+		suite.Require().NoError(err)
+
+		result, err := conn.RunWithPowershell(context.Background(), "Get-LocalUser")
+		suite.NoError(err)
+		suite.Equal(connection.CmdResult{StdOut: expectedCmd, ExitCode: 1}, result)
+	})
+}
+
+func (suite *SSHUnitTestSuite) TestStream() {
+	suite.Run("should write the output to the streams as the data arrives", func() {
+		received := make(chan struct{})
+		server := newTestServer(suite.T(), func(cmd string, stdin io.Reader, stdout io.Writer, stderr io.Writer) int {
+			fmt.Fprintln(stdout, "first")
+
+			// The second line is only written after the client received the first one.
+			select {
+			case <-received:
+			case <-time.After(5 * time.Second):
+				return 1
+			}
+
+			fmt.Fprintln(stdout, "second")
+			fmt.Fprintln(stderr, "error")
+			return 0
+		})
+		conn, err := NewConnection(server.clientConfig())
+		suite.Require().NoError(err)
+		defer conn.Close()
+
+		var lines []string
+		stdout := connection.NewLineWriter(func(line string) {
+			if len(lines) == 0 {
+				close(received)
+			}
+			lines = append(lines, line)
+		})
+		var stderr strings.Builder
+
+		exitCode, err := conn.Stream(context.Background(), "Get-Content -Wait", connection.Streams{Stdout: stdout, Stderr: &stderr})
+		suite.NoError(err)
+		suite.Equal(0, exitCode)
+		suite.Equal([]string{"first", "second"}, lines)
+		suite.Equal("error\n", stderr.String())
+	})
+
+	suite.Run("should discard the output of nil streams", func() {
+		server := newTestServer(suite.T(), func(cmd string, stdin io.Reader, stdout io.Writer, stderr io.Writer) int {
+			fmt.Fprint(stdout, "stdout")
+			fmt.Fprint(stderr, "stderr")
+			return 2
+		})
+		conn, err := NewConnection(server.clientConfig())
+		suite.Require().NoError(err)
+		defer conn.Close()
+
+		exitCode, err := conn.Stream(context.Background(), "whoami", connection.Streams{})
+		suite.NoError(err)
+		suite.Equal(2, exitCode)
+	})
+
+	suite.Run("should stop writing to the streams if the context is done", func() {
+		server := newTestServer(suite.T(), func(cmd string, stdin io.Reader, stdout io.Writer, stderr io.Writer) int {
+			for {
+				if _, err := fmt.Fprintln(stdout, "line"); err != nil {
+					return 1
+				}
+				time.Sleep(time.Millisecond)
+			}
+		})
+		conn, err := NewConnection(server.clientConfig())
+		suite.Require().NoError(err)
+		defer conn.Close()
+
+		ctx, cancel := context.WithCancel(context.Background())
+		var mu sync.Mutex
+		var returned bool
+		stdout := connection.NewLineWriter(func(line string) {
+			mu.Lock()
+			defer mu.Unlock()
+			suite.False(returned, "write after Stream returned")
+			cancel()
+		})
+
+		_, err = conn.Stream(ctx, "Get-Content -Wait", connection.Streams{Stdout: stdout})
+		mu.Lock()
+		returned = true
+		mu.Unlock()
+		suite.ErrorIs(err, context.Canceled)
+	})
+}
+
+func (suite *SSHUnitTestSuite) TestRunWithInput() {
+	suite.Run("should send the input to the command", func() {
+		server := newTestServer(suite.T(), func(cmd string, stdin io.Reader, stdout io.Writer, stderr io.Writer) int {
+			input, err := io.ReadAll(stdin)
+			if err != nil {
+				return 1
+			}
+			fmt.Fprintf(stdout, "%s: %s", cmd, input)
+			return 0
+		})
+		conn, err := NewConnection(server.clientConfig())
+		suite.Require().NoError(err)
+		defer conn.Close()
+
+		result, err := connection.RunWithInput(context.Background(), conn, "Read-Host", strings.NewReader("secret"))
+		suite.NoError(err)
+		suite.Equal(connection.CmdResult{StdOut: "Read-Host: secret"}, result)
+	})
+
+	suite.Run("should close the input without a reader", func() {
+		server := newTestServer(suite.T(), func(cmd string, stdin io.Reader, stdout io.Writer, stderr io.Writer) int {
+			input, err := io.ReadAll(stdin)
+			if err != nil {
+				return 1
+			}
+			return len(input)
+		})
+		conn, err := NewConnection(server.clientConfig())
+		suite.Require().NoError(err)
+		defer conn.Close()
+
+		result, err := connection.RunWithInput(context.Background(), conn, "Read-Host", nil)
+		suite.NoError(err)
+		suite.Equal(0, result.ExitCode)
+	})
+}
+
+// freezableConn is a net.Conn whose reads block after it was frozen, like a connection a firewall dropped silently.
+type freezableConn struct {
+	net.Conn
+	frozen chan struct{}
+	once   sync.Once
+}
+
+// Read reads from the connection until it is frozen.
+func (c *freezableConn) Read(p []byte) (int, error) {
+	n, err := c.Conn.Read(p)
+	select {
+	case <-c.frozen:
+		// Block until the connection is closed.
+		_, _ = io.Copy(io.Discard, c.Conn)
+		return 0, io.EOF
+	default:
+		return n, err
+	}
+}
+
+// freeze blocks all further reads.
+func (c *freezableConn) freeze() {
+	c.once.Do(func() { close(c.frozen) })
+}
+
+func (suite *SSHUnitTestSuite) TestReconnect() {
+	handler := func(cmd string, stdin io.Reader, stdout io.Writer, stderr io.Writer) int {
+		fmt.Fprint(stdout, "connected")
+		return 0
+	}
+
+	suite.Run("should reconnect if the connection was dropped", func() {
+		server := newTestServer(suite.T(), handler)
+		conn, err := NewConnection(server.clientConfig())
+		suite.Require().NoError(err)
+		defer conn.Close()
+
+		server.dropConnections()
+
+		result, err := conn.Run(context.Background(), "whoami")
+		suite.Require().NoError(err)
+		suite.Equal("connected", result.StdOut)
+	})
+
+	suite.Run("should send keepalive requests", func() {
+		server := newTestServer(suite.T(), handler)
+		config := server.clientConfig()
+		config.KeepAliveInterval = 10 * time.Millisecond
+
+		conn, err := NewConnection(config)
+		suite.Require().NoError(err)
+		defer conn.Close()
+
+		suite.Eventually(func() bool {
+			return slices.Contains(server.receivedGlobalRequests(), "keepalive@openssh.com")
+		}, 5*time.Second, 10*time.Millisecond)
+	})
+
+	suite.Run("should reconnect if the host does not answer the keepalive requests", func() {
+		server := newTestServer(suite.T(), handler)
+
+		var mu sync.Mutex
+		var conns []*freezableConn
+		config := server.clientConfig()
+		config.KeepAliveInterval = 50 * time.Millisecond
+		config.Dial = func(network, addr string) (net.Conn, error) {
+			c, err := net.Dial(network, addr)
+			if err != nil {
+				return nil, err
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			conns = append(conns, &freezableConn{Conn: c, frozen: make(chan struct{})})
+			return conns[len(conns)-1], nil
+		}
+
+		conn, err := NewConnection(config)
+		suite.Require().NoError(err)
+		defer conn.Close()
+
+		mu.Lock()
+		conns[0].freeze()
+		mu.Unlock()
+
+		// The keepalive closes the frozen client, so the next command reconnects.
+		suite.Eventually(func() bool {
+			select {
+			case <-conn.dead:
+				return true
+			default:
+				return false
+			}
+		}, 5*time.Second, 10*time.Millisecond)
+
+		result, err := conn.Run(context.Background(), "whoami")
+		suite.Require().NoError(err)
+		suite.Equal("connected", result.StdOut)
+
+		mu.Lock()
+		suite.Len(conns, 2)
+		mu.Unlock()
+	})
+
+	suite.Run("should return an error after the connection was closed", func() {
+		server := newTestServer(suite.T(), handler)
+		conn, err := NewConnection(server.clientConfig())
+		suite.Require().NoError(err)
+		suite.Require().NoError(conn.Close())
+
+		_, err = conn.Run(context.Background(), "whoami")
+		suite.Error(err)
+	})
+}
@@ -0,0 +1,147 @@
+package ssh
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/d-strobel/gowindows/connection"
+	"github.com/pkg/sftp"
+)
+
+// windowsPathRe matches a Windows path with a drive letter, e.g. C:\Windows or C:/Windows.
+var windowsPathRe = regexp.MustCompile(`^[A-Za-z]:([\\/]|$)`)
+
+// sftpPath converts a Windows path to the path format of the OpenSSH SFTP server on Windows, e.g. /C:/Windows.
+// Other paths are returned unchanged.
+func sftpPath(path string) string {
+	if !windowsPathRe.MatchString(path) {
+		return path
+	}
+	return "/" + strings.ReplaceAll(path, `\`, "/")
+}
+
+// Upload writes the content of r to the remote file via SFTP.
+// An existing remote file is overwritten.
+// The progress of the context is called after every written part, see connection.WithTransferProgress.
+// Satisfies the connection.FileTransferer interface.
+func (c *Connection) Upload(ctx context.Context, r io.Reader, remotePath string) error {
+	err := c.sftp(ctx, func(client *sftp.Client) error {
+		f, err := client.OpenFile(sftpPath(remotePath), os.O_WRONLY|os.O_CREATE|os.O_TRUNC)
+		if err != nil {
+			return err
+		}
+
+		if _, err := f.ReadFrom(&progressReader{r: r, progress: connection.TransferProgressFromContext(ctx)}); err != nil {
+			f.Close()
+			return err
+		}
+		return f.Close()
+	})
+	if err != nil {
+		return fmt.Errorf("ssh: failed to upload %s: %w", remotePath, err)
+	}
+	return nil
+}
+
+// Download writes the content of the remote file to w via SFTP.
+// The progress of the context is called after every read part, see connection.WithTransferProgress.
+// Satisfies the connection.FileTransferer interface.
+func (c *Connection) Download(ctx context.Context, remotePath string, w io.Writer) error {
+	err := c.sftp(ctx, func(client *sftp.Client) error {
+		f, err := client.Open(sftpPath(remotePath))
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+
+		_, err = f.WriteTo(&progressWriter{w: w, progress: connection.TransferProgressFromContext(ctx)})
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("ssh: failed to download %s: %w", remotePath, err)
+	}
+	return nil
+}
+
+// sftp runs fn with a new SFTP client.
+// If the context is done, the SFTP client is closed, which aborts the transfer.
+func (c *Connection) sftp(ctx context.Context, fn func(client *sftp.Client) error) error {
+	sshClient, err := c.client()
+	if err != nil {
+		return err
+	}
+
+	client, err := sftp.NewClient(sshClient)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			client.Close()
+		case <-done:
+		}
+	}()
+
+	if err := fn(client); err != nil {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		return err
+	}
+	return nil
+}
+
+// Upload writes the content of r to the remote file via SFTP on the SSH connection of the PowerShell session.
+// Satisfies the connection.FileTransferer interface.
+func (c *PowershellConnection) Upload(ctx context.Context, r io.Reader, remotePath string) error {
+	return c.conn.Upload(ctx, r, remotePath)
+}
+
+// Download writes the content of the remote file to w via SFTP on the SSH connection of the PowerShell session.
+// Satisfies the connection.FileTransferer interface.
+func (c *PowershellConnection) Download(ctx context.Context, remotePath string, w io.Writer) error {
+	return c.conn.Download(ctx, remotePath, w)
+}
+
+// progressReader is an io.Reader that reports the number of bytes read.
+type progressReader struct {
+	r        io.Reader
+	n        int64
+	progress connection.TransferProgress
+}
+
+// Read implements the io.Reader interface.
+func (r *progressReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	if n > 0 {
+		r.n += int64(n)
+		r.progress(r.n)
+	}
+	return n, err
+}
+
+// progressWriter is an io.Writer that reports the number of bytes written.
+type progressWriter struct {
+	w        io.Writer
+	n        int64
+	progress connection.TransferProgress
+}
+
+// Write implements the io.Writer interface.
+func (w *progressWriter) Write(p []byte) (int, error) {
+	n, err := w.w.Write(p)
+	if n > 0 {
+		w.n += int64(n)
+		w.progress(w.n)
+	}
+	return n, err
+}
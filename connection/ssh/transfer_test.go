@@ -0,0 +1,134 @@
+package ssh
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/d-strobel/gowindows/connection"
+)
+
+func (suite *SSHUnitTestSuite) TestSFTPPath() {
+	tcs := []struct {
+		description string
+		path        string
+		expected    string
+	}{
+		{"windows path", `C:\Windows\Temp\file.txt`, "/C:/Windows/Temp/file.txt"},
+		{"windows path with slashes", "D:/data/file.txt", "/D:/data/file.txt"},
+		{"drive root", `C:\`, "/C:/"},
+		{"drive only", "C:", "/C:"},
+		{"relative path", `temp\file.txt`, `temp\file.txt`},
+		{"unix path", "/tmp/file.txt", "/tmp/file.txt"},
+	}
+
+	for _, tc := range tcs {
+		suite.T().Logf("test case: %s", tc.description)
+		suite.Equal(tc.expected, sftpPath(tc.path))
+	}
+}
+
+func (suite *SSHUnitTestSuite) TestTransfer() {
+	handler := func(cmd string, stdin io.Reader, stdout io.Writer, stderr io.Writer) int { return 0 }
+
+	suite.Run("should upload and download a file", func() {
+		server := newTestServer(suite.T(), handler)
+		dir := server.enableSFTP(suite.T())
+
+		conn, err := NewConnection(server.clientConfig())
+		suite.Require().NoError(err)
+		defer conn.Close()
+
+		content := make([]byte, 3<<20)
+		_, err = rand.Read(content)
+		suite.Require().NoError(err)
+
+		var uploaded []int64
+		ctx := connection.WithTransferProgress(context.Background(), func(n int64) { uploaded = append(uploaded, n) })
+		suite.Require().NoError(connection.Upload(ctx, conn, bytes.NewReader(content), "file.bin"))
+
+		written, err := os.ReadFile(filepath.Join(dir, "file.bin"))
+		suite.Require().NoError(err)
+		suite.Equal(content, written)
+		suite.Require().NotEmpty(uploaded)
+		suite.Equal(int64(len(content)), uploaded[len(uploaded)-1])
+
+		var downloaded []int64
+		ctx = connection.WithTransferProgress(context.Background(), func(n int64) { downloaded = append(downloaded, n) })
+		var buf bytes.Buffer
+		suite.Require().NoError(connection.Download(ctx, conn, "file.bin", &buf))
+		suite.Equal(content, buf.Bytes())
+		suite.Require().NotEmpty(downloaded)
+		suite.Equal(int64(len(content)), downloaded[len(downloaded)-1])
+	})
+
+	suite.Run("should overwrite an existing file", func() {
+		server := newTestServer(suite.T(), handler)
+		dir := server.enableSFTP(suite.T())
+		suite.Require().NoError(os.WriteFile(filepath.Join(dir, "file.txt"), []byte("a longer old content"), 0o600))
+
+		conn, err := NewConnection(server.clientConfig())
+		suite.Require().NoError(err)
+		defer conn.Close()
+
+		suite.Require().NoError(conn.Upload(context.Background(), strings.NewReader("new"), "file.txt"))
+
+		written, err := os.ReadFile(filepath.Join(dir, "file.txt"))
+		suite.Require().NoError(err)
+		suite.Equal("new", string(written))
+	})
+
+	suite.Run("should return an error for a missing file", func() {
+		server := newTestServer(suite.T(), handler)
+		server.enableSFTP(suite.T())
+
+		conn, err := NewConnection(server.clientConfig())
+		suite.Require().NoError(err)
+		defer conn.Close()
+
+		err = conn.Download(context.Background(), "missing.txt", io.Discard)
+		suite.ErrorContains(err, "ssh: failed to download missing.txt")
+		suite.ErrorIs(err, os.ErrNotExist)
+	})
+
+	suite.Run("should return an error without the SFTP subsystem", func() {
+		server := newTestServer(suite.T(), handler)
+
+		conn, err := NewConnection(server.clientConfig())
+		suite.Require().NoError(err)
+		defer conn.Close()
+
+		err = conn.Upload(context.Background(), strings.NewReader("content"), "file.txt")
+		suite.ErrorContains(err, "ssh: failed to upload file.txt")
+	})
+
+	suite.Run("should return the context error if the context is done", func() {
+		server := newTestServer(suite.T(), handler)
+		server.enableSFTP(suite.T())
+
+		conn, err := NewConnection(server.clientConfig())
+		suite.Require().NoError(err)
+		defer conn.Close()
+
+		ctx, cancel := context.WithCancel(context.Background())
+		r := &cancelingReader{cancel: cancel}
+
+		err = conn.Upload(ctx, r, "file.txt")
+		suite.ErrorIs(err, context.Canceled)
+	})
+}
+
+// cancelingReader is an endless reader that cancels a context on the first read,
+// so a transfer is canceled while it is running.
+type cancelingReader struct {
+	cancel context.CancelFunc
+}
+
+func (r *cancelingReader) Read(p []byte) (int, error) {
+	r.cancel()
+	return len(p), nil
+}
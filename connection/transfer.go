@@ -0,0 +1,61 @@
+package connection
+
+import (
+	"context"
+	"errors"
+	"io"
+)
+
+// ErrTransferNotSupported is returned by Upload and Download if the connection cannot transfer files.
+var ErrTransferNotSupported = errors.New("connection: the connection does not support file transfers")
+
+// FileTransferer is implemented by connections that transfer files to and from the remote system.
+type FileTransferer interface {
+	// Upload writes the content of r to the remote file.
+	// An existing remote file is overwritten.
+	Upload(ctx context.Context, r io.Reader, remotePath string) error
+
+	// Download writes the content of the remote file to w.
+	Download(ctx context.Context, remotePath string, w io.Writer) error
+}
+
+// Upload writes the content of r to the remote file using the connection.
+// It returns ErrTransferNotSupported if the connection does not implement FileTransferer.
+func Upload(ctx context.Context, conn Connection, r io.Reader, remotePath string) error {
+	t, ok := conn.(FileTransferer)
+	if !ok {
+		return ErrTransferNotSupported
+	}
+	return t.Upload(ctx, r, remotePath)
+}
+
+// Download writes the content of the remote file to w using the connection.
+// It returns ErrTransferNotSupported if the connection does not implement FileTransferer.
+func Download(ctx context.Context, conn Connection, remotePath string, w io.Writer) error {
+	t, ok := conn.(FileTransferer)
+	if !ok {
+		return ErrTransferNotSupported
+	}
+	return t.Download(ctx, remotePath, w)
+}
+
+// TransferProgress is called during a file transfer with the number of bytes transferred so far.
+type TransferProgress func(transferred int64)
+
+// transferProgressKey is the context key of the TransferProgress.
+type transferProgressKey struct{}
+
+// WithTransferProgress returns a copy of the context that carries the TransferProgress.
+// Upload and Download call it whenever a part of the file has been transferred.
+func WithTransferProgress(ctx context.Context, progress TransferProgress) context.Context {
+	return context.WithValue(ctx, transferProgressKey{}, progress)
+}
+
+// TransferProgressFromContext returns the TransferProgress of the context.
+// It returns a function that does nothing if the context does not carry a TransferProgress.
+func TransferProgressFromContext(ctx context.Context) TransferProgress {
+	if progress, ok := ctx.Value(transferProgressKey{}).(TransferProgress); ok && progress != nil {
+		return progress
+	}
+	return func(int64) {}
+}
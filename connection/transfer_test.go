@@ -0,0 +1,72 @@
+package connection
+
+import (
+	"context"
+	"io"
+	"strings"
+)
+
+// transferConnection is a Connection that stores the uploaded files in memory.
+type transferConnection struct {
+	recordingConnection
+	files map[string]string
+}
+
+func (c *transferConnection) Upload(ctx context.Context, r io.Reader, remotePath string) error {
+	content, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	TransferProgressFromContext(ctx)(int64(len(content)))
+	c.files[remotePath] = string(content)
+	return nil
+}
+
+func (c *transferConnection) Download(ctx context.Context, remotePath string, w io.Writer) error {
+	n, err := io.WriteString(w, c.files[remotePath])
+	TransferProgressFromContext(ctx)(int64(n))
+	return err
+}
+
+func (suite *ConnectionUnitTestSuite) TestTransfer() {
+	suite.T().Parallel()
+
+	suite.Run("should transfer files with the connection", func() {
+		conn := &transferConnection{files: map[string]string{}}
+
+		var progress []int64
+		ctx := WithTransferProgress(context.Background(), func(n int64) { progress = append(progress, n) })
+
+		suite.NoError(Upload(ctx, conn, strings.NewReader("content"), `C:\file.txt`))
+		suite.Equal(map[string]string{`C:\file.txt`: "content"}, conn.files)
+
+		var w strings.Builder
+		suite.NoError(Download(ctx, conn, `C:\file.txt`, &w))
+		suite.Equal("content", w.String())
+		suite.Equal([]int64{7, 7}, progress)
+	})
+
+	suite.Run("should return an error if the connection cannot transfer files", func() {
+		conn := &recordingConnection{}
+
+		suite.ErrorIs(Upload(context.Background(), conn, strings.NewReader("content"), `C:\file.txt`), ErrTransferNotSupported)
+		suite.ErrorIs(Download(context.Background(), conn, `C:\file.txt`, io.Discard), ErrTransferNotSupported)
+	})
+
+	suite.Run("should transfer files through the wrappers of the connection", func() {
+		conn := &transferConnection{files: map[string]string{}}
+		pool, err := NewPool(func() (Connection, error) { return conn, nil }, PoolConfig{})
+		suite.Require().NoError(err)
+
+		wrapped := NewRetryConnection(Intercept(pool), RetryPolicy{})
+		suite.NoError(Upload(context.Background(), wrapped, strings.NewReader("content"), `C:\file.txt`))
+
+		var w strings.Builder
+		suite.NoError(Download(context.Background(), wrapped, `C:\file.txt`, &w))
+		suite.Equal("content", w.String())
+	})
+
+	suite.Run("should ignore a missing transfer progress", func() {
+		suite.NotPanics(func() { TransferProgressFromContext(context.Background())(1) })
+	})
+}
@@ -0,0 +1,546 @@
+package winrm
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bodgit/ntlmssp"
+	ntlmhttp "github.com/bodgit/ntlmssp/http"
+	"github.com/jcmturner/gokrb5/v8/client"
+	krb5config "github.com/jcmturner/gokrb5/v8/config"
+	"github.com/jcmturner/gokrb5/v8/credentials"
+	"github.com/jcmturner/gokrb5/v8/keytab"
+	"github.com/jcmturner/gokrb5/v8/spnego"
+	"github.com/masterzen/winrm"
+	"github.com/masterzen/winrm/soap"
+)
+
+// Content type of the WS-Management messages.
+const soapContentType = "application/soap+xml;charset=UTF-8"
+
+// errKerberosTicket is returned by the Kerberos transport if no service ticket could be obtained for the host.
+var errKerberosTicket = errors.New("winrm: failed to obtain a Kerberos service ticket")
+
+// transporter returns the HTTP transport of the WinRM client for the authentication method of the configuration.
+func (config *Config) transporter() (winrm.Transporter, error) {
+	switch config.Auth {
+	case AuthNTLM:
+		return config.ntlmTransporter()
+
+	case AuthKerberos:
+		return &kerberosTransport{config: config}, nil
+
+	case AuthCertificate:
+		return &certificateTransport{dial: config.Dial}, nil
+
+	case AuthNegotiate:
+		if config.Kerberos == nil {
+			return config.ntlmTransporter()
+		}
+
+		transport := &negotiateTransport{kerberos: &kerberosTransport{config: config}}
+
+		// Without a password, there is nothing to fall back to.
+		if config.Password != "" {
+			ntlm, err := config.ntlmTransporter()
+			if err != nil {
+				return nil, err
+			}
+			transport.ntlm = ntlm
+		}
+
+		return transport, nil
+
+	default:
+		return winrm.NewClientWithDial(config.Dial), nil
+	}
+}
+
+// ntlmTransporter returns the NTLM transport of the WinRM client.
+// Over HTTP, the messages are encrypted unless the encryption is disabled.
+func (config *Config) ntlmTransporter() (winrm.Transporter, error) {
+	if config.UseTLS || config.DisableEncryption {
+		return winrm.NewClientNTLMWithDial(config.Dial), nil
+	}
+
+	return &ntlmEncryptionTransport{username: config.Username, password: config.Password, dial: config.Dial}, nil
+}
+
+// ntlmMaxIdleSessions is the number of idle sessions the NTLM encryption transport keeps open.
+// A command with input sends its input while its output is received, so it uses two sessions at once.
+const ntlmMaxIdleSessions = 2
+
+// errNTLMSessionClosed is returned by an NTLM session if the host does not accept its connection anymore,
+// e.g. because the keep-alive connection was closed and the host requires a new handshake.
+var errNTLMSessionClosed = errors.New("winrm: the NTLM session was closed by the host")
+
+// ntlmEncryptionTransport is a WinRM transport that authenticates via NTLM and encrypts the messages over HTTP.
+// NTLM authenticates a connection, so every session keeps its own keep-alive connection with its security context.
+// Idle sessions are reused for the next messages and a new session is only opened if all sessions are busy.
+type ntlmEncryptionTransport struct {
+	username  string
+	password  string
+	dial      func(network, addr string) (net.Conn, error)
+	transport *http.Transport
+	url       string
+
+	mu   sync.Mutex
+	idle []*ntlmSession
+}
+
+// ntlmSession is an authenticated keep-alive connection with its NTLM security context.
+// The messages of a session are sent one after another, because the security context numbers them.
+type ntlmSession struct {
+	transport *http.Transport
+	security  *ntlmssp.SecuritySession
+}
+
+// Transport sets up the HTTP transport for the endpoint.
+// Satisfies the winrm.Transporter interface.
+func (t *ntlmEncryptionTransport) Transport(endpoint *winrm.Endpoint) error {
+	var err error
+	t.transport, err = newHTTPTransport(endpoint, t.dial)
+	if err != nil {
+		return err
+	}
+
+	t.url = endpointURL(endpoint)
+
+	return nil
+}
+
+// Post sends an encrypted message to the WinRM service and returns the decrypted response.
+// If the host closed the connection of an idle session, the message is sent again on a new session.
+// Satisfies the winrm.Transporter interface.
+func (t *ntlmEncryptionTransport) Post(_ *winrm.Client, message *soap.SoapMessage) (string, error) {
+	for {
+		session, reused, err := t.session()
+		if err != nil {
+			return "", err
+		}
+
+		body, err := session.post(t.url, []byte(message.String()))
+		if err != nil {
+			session.close()
+			if errors.Is(err, errNTLMSessionClosed) && reused {
+				continue
+			}
+			return "", err
+		}
+
+		t.release(session)
+		return body, nil
+	}
+}
+
+// session returns an idle session or opens a new one and reports whether the session was reused.
+func (t *ntlmEncryptionTransport) session() (*ntlmSession, bool, error) {
+	t.mu.Lock()
+	if n := len(t.idle); n > 0 {
+		session := t.idle[n-1]
+		t.idle = t.idle[:n-1]
+		t.mu.Unlock()
+		return session, true, nil
+	}
+	t.mu.Unlock()
+
+	session, err := t.handshake()
+	return session, false, err
+}
+
+// release returns a session to the idle sessions or closes it if there are enough idle sessions.
+func (t *ntlmEncryptionTransport) release(session *ntlmSession) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if len(t.idle) >= ntlmMaxIdleSessions {
+		session.close()
+		return
+	}
+	t.idle = append(t.idle, session)
+}
+
+// handshake opens a new connection and authenticates it via NTLM.
+func (t *ntlmEncryptionTransport) handshake() (*ntlmSession, error) {
+	user, domain := t.username, ""
+	if name, realm, ok := strings.Cut(t.username, "@"); ok {
+		user, domain = name, realm
+	} else if realm, name, ok := strings.Cut(t.username, `\`); ok {
+		user, domain = name, realm
+	}
+
+	ntlmClient, err := ntlmssp.NewClient(ntlmssp.SetUserInfo(user, t.password), ntlmssp.SetDomain(domain), ntlmssp.SetVersion(ntlmssp.DefaultVersion()))
+	if err != nil {
+		return nil, err
+	}
+
+	// The handshake and the messages must use the same connection, so the session has its own transport with a single connection.
+	transport := t.transport.Clone()
+	transport.MaxConnsPerHost = 1
+
+	client, err := ntlmhttp.NewClient(&http.Client{Transport: transport}, ntlmClient)
+	if err != nil {
+		return nil, err
+	}
+
+	// The handshake runs with an empty message, so a message is never sent unencrypted.
+	req, err := http.NewRequest(http.MethodPost, t.url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", soapContentType)
+
+	if _, err := readResponse(client.Do(req)); err != nil {
+		transport.CloseIdleConnections()
+		return nil, fmt.Errorf("winrm: NTLM handshake failed: %w", err)
+	}
+
+	security := ntlmClient.SecuritySession()
+	if security == nil {
+		transport.CloseIdleConnections()
+		return nil, errors.New("winrm: NTLM handshake failed: no session for the message encryption")
+	}
+
+	return &ntlmSession{transport: transport, security: security}, nil
+}
+
+// post sends an encrypted message on the connection of the session and returns the decrypted response.
+func (s *ntlmSession) post(url string, message []byte) (string, error) {
+	body, contentType, err := sealMessage(s.security, message)
+	if err != nil {
+		return "", err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", contentType)
+
+	resp, err := (&http.Client{Transport: s.transport}).Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	// A new connection is not authenticated.
+	if resp.StatusCode == http.StatusUnauthorized {
+		return "", errNTLMSessionClosed
+	}
+
+	sealed, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", err
+	}
+
+	// The host answers errors outside of the message encryption unencrypted.
+	if resp.StatusCode != http.StatusOK && !strings.HasPrefix(resp.Header.Get("Content-Type"), "multipart/encrypted;") {
+		return "", fmt.Errorf("http error %d: %s", resp.StatusCode, sealed)
+	}
+
+	body, err = unsealMessage(s.security, sealed, resp.Header.Get("Content-Type"))
+	if err != nil {
+		return "", err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("http error %d: %s", resp.StatusCode, body)
+	}
+
+	return string(body), nil
+}
+
+// close closes the connection of the session.
+func (s *ntlmSession) close() {
+	s.transport.CloseIdleConnections()
+}
+
+// Content type and parts of the encrypted WS-Management messages, see MS-WSMV 2.2.9.1.
+// The parts are built by hand, because the encrypted message is binary and must not be changed.
+const (
+	encryptedContentType = `multipart/encrypted;protocol="application/HTTP-SPNEGO-session-encrypted";boundary="Encrypted Boundary"`
+	encryptedHeader      = "--Encrypted Boundary\r\n" +
+		"\tContent-Type: application/HTTP-SPNEGO-session-encrypted\r\n" +
+		"\tOriginalContent: type=" + soapContentType + ";Length=%d\r\n" +
+		"--Encrypted Boundary\r\n" +
+		"\tContent-Type: application/octet-stream\r\n"
+	encryptedTrailer = "--Encrypted Boundary--\r\n"
+)
+
+// sealMessage encrypts and signs a message with the NTLM session
+// and returns it as multipart/encrypted body with its content type.
+func sealMessage(session *ntlmssp.SecuritySession, message []byte) ([]byte, string, error) {
+	sealed, signature, err := session.Wrap(message)
+	if err != nil {
+		return nil, "", fmt.Errorf("winrm: failed to encrypt the message: %w", err)
+	}
+
+	body := fmt.Appendf(nil, encryptedHeader, len(message))
+	body = binary.LittleEndian.AppendUint32(body, uint32(len(signature)))
+	body = append(append(body, signature...), sealed...)
+	body = append(body, encryptedTrailer...)
+
+	return body, encryptedContentType, nil
+}
+
+// unsealMessage decrypts a multipart/encrypted body with the NTLM session and verifies its signature.
+func unsealMessage(session *ntlmssp.SecuritySession, body []byte, contentType string) ([]byte, error) {
+	if !strings.HasPrefix(contentType, "multipart/encrypted;") {
+		return nil, fmt.Errorf("winrm: failed to decrypt the response: unexpected content type %q", contentType)
+	}
+
+	_, payload, ok := bytes.Cut(body, []byte("\tContent-Type: application/octet-stream\r\n"))
+	payload, trailer := bytes.CutSuffix(payload, []byte(encryptedTrailer))
+	if !ok || !trailer || len(payload) < 4 || len(payload) < 4+int(binary.LittleEndian.Uint32(payload)) {
+		return nil, errors.New("winrm: failed to decrypt the response: malformed encrypted message")
+	}
+	length := 4 + int(binary.LittleEndian.Uint32(payload))
+
+	message, err := session.Unwrap(payload[length:], payload[4:length])
+	if err != nil {
+		return nil, fmt.Errorf("winrm: failed to decrypt the response: %w", err)
+	}
+
+	return message, nil
+}
+
+// kerberosTransport is a WinRM transport that authenticates every request with a Kerberos SPNEGO token.
+type kerberosTransport struct {
+	config    *Config
+	client    *client.Client
+	transport http.RoundTripper
+	url       string
+}
+
+// Transport loads the Kerberos configuration and credentials and sets up the HTTP transport for the endpoint.
+// Satisfies the winrm.Transporter interface.
+func (t *kerberosTransport) Transport(endpoint *winrm.Endpoint) error {
+	settings := t.config.Kerberos
+
+	krb5Config, err := krb5config.Load(settings.ConfigPath)
+	if err != nil {
+		return fmt.Errorf("winrm: failed to load the Kerberos configuration '%s': %w", settings.ConfigPath, err)
+	}
+
+	realm := settings.Realm
+	if realm == "" {
+		realm = krb5Config.LibDefaults.DefaultRealm
+	}
+
+	switch {
+	case settings.CCachePath != "":
+		ccache, err := credentials.LoadCCache(settings.CCachePath)
+		if err != nil {
+			return fmt.Errorf("winrm: failed to load the Kerberos credential cache '%s': %w", settings.CCachePath, err)
+		}
+
+		t.client, err = client.NewFromCCache(ccache, krb5Config, client.DisablePAFXFAST(true))
+		if err != nil {
+			return fmt.Errorf("winrm: failed to create the Kerberos client from the credential cache: %w", err)
+		}
+
+	case settings.KeytabPath != "":
+		kt, err := keytab.Load(settings.KeytabPath)
+		if err != nil {
+			return fmt.Errorf("winrm: failed to load the Kerberos keytab '%s': %w", settings.KeytabPath, err)
+		}
+
+		t.client = client.NewWithKeytab(t.config.Username, realm, kt, krb5Config, client.DisablePAFXFAST(true))
+
+	default:
+		t.client = client.NewWithPassword(t.config.Username, realm, t.config.Password, krb5Config,
+			client.DisablePAFXFAST(true), client.AssumePreAuthentication(true))
+	}
+
+	t.transport, err = newHTTPTransport(endpoint, t.config.Dial)
+	if err != nil {
+		return err
+	}
+
+	t.url = endpointURL(endpoint)
+
+	return nil
+}
+
+// Post sends a message to the WinRM service and returns the response.
+// Satisfies the winrm.Transporter interface.
+func (t *kerberosTransport) Post(_ *winrm.Client, message *soap.SoapMessage) (string, error) {
+	req, err := http.NewRequest(http.MethodPost, t.url, strings.NewReader(message.String()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", soapContentType)
+
+	if err := spnego.SetSPNEGOHeader(t.client, req, t.config.Kerberos.SPN); err != nil {
+		return "", fmt.Errorf("%w: %w", errKerberosTicket, err)
+	}
+
+	return send(t.transport, req)
+}
+
+// certificateTransport is a WinRM transport that authenticates with a client certificate over HTTPS.
+type certificateTransport struct {
+	dial      func(network, addr string) (net.Conn, error)
+	transport http.RoundTripper
+	url       string
+}
+
+// Transport sets up the HTTP transport with the client certificate of the endpoint.
+// Satisfies the winrm.Transporter interface.
+func (t *certificateTransport) Transport(endpoint *winrm.Endpoint) error {
+	var err error
+	t.transport, err = newHTTPTransport(endpoint, t.dial)
+	if err != nil {
+		return err
+	}
+
+	t.url = endpointURL(endpoint)
+
+	return nil
+}
+
+// Post sends a message to the WinRM service and returns the response.
+// Satisfies the winrm.Transporter interface.
+func (t *certificateTransport) Post(_ *winrm.Client, message *soap.SoapMessage) (string, error) {
+	req, err := http.NewRequest(http.MethodPost, t.url, strings.NewReader(message.String()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", soapContentType)
+	req.Header.Set("Authorization", "http://schemas.dmtf.org/wbem/wsman/1/wsman/secprofile/https/mutual")
+
+	return send(t.transport, req)
+}
+
+// negotiateTransport is a WinRM transport that authenticates via Kerberos
+// and falls back to NTLM if no Kerberos ticket could be obtained.
+type negotiateTransport struct {
+	kerberos *kerberosTransport
+	ntlm     winrm.Transporter
+
+	mu       sync.Mutex
+	fallback bool
+}
+
+// Transport sets up the Kerberos and NTLM transports for the endpoint.
+// Satisfies the winrm.Transporter interface.
+func (t *negotiateTransport) Transport(endpoint *winrm.Endpoint) error {
+	if err := t.kerberos.Transport(endpoint); err != nil {
+		return err
+	}
+
+	if t.ntlm != nil {
+		return t.ntlm.Transport(endpoint)
+	}
+
+	return nil
+}
+
+// Post sends a message to the WinRM service and returns the response.
+// Once Kerberos failed to obtain a ticket, all further messages are sent via NTLM.
+// Satisfies the winrm.Transporter interface.
+func (t *negotiateTransport) Post(client *winrm.Client, message *soap.SoapMessage) (string, error) {
+	t.mu.Lock()
+	fallback := t.fallback
+	t.mu.Unlock()
+
+	if !fallback {
+		body, err := t.kerberos.Post(client, message)
+		if !errors.Is(err, errKerberosTicket) || t.ntlm == nil {
+			return body, err
+		}
+
+		t.mu.Lock()
+		t.fallback = true
+		t.mu.Unlock()
+	}
+
+	return t.ntlm.Post(client, message)
+}
+
+// newHTTPTransport returns an HTTP transport with the TLS settings, client certificate and timeout of the endpoint.
+// If dial is not nil, it establishes the TCP connections instead of a net.Dialer.
+func newHTTPTransport(endpoint *winrm.Endpoint, dial func(network, addr string) (net.Conn, error)) (*http.Transport, error) {
+	dialContext := (&net.Dialer{
+		Timeout:   30 * time.Second,
+		KeepAlive: 30 * time.Second,
+	}).DialContext
+	if dial != nil {
+		dialContext = func(_ context.Context, network, addr string) (net.Conn, error) {
+			return dial(network, addr)
+		}
+	}
+
+	transport := &http.Transport{
+		Proxy:       http.ProxyFromEnvironment,
+		DialContext: dialContext,
+		TLSClientConfig: &tls.Config{
+			InsecureSkipVerify: endpoint.Insecure, //nolint:gosec
+			ServerName:         endpoint.TLSServerName,
+		},
+		ResponseHeaderTimeout: endpoint.Timeout,
+	}
+
+	if len(endpoint.CACert) > 0 {
+		certPool := x509.NewCertPool()
+		if !certPool.AppendCertsFromPEM(endpoint.CACert) {
+			return nil, errors.New("winrm: failed to parse the CA certificate")
+		}
+		transport.TLSClientConfig.RootCAs = certPool
+	}
+
+	if len(endpoint.Cert) > 0 {
+		cert, err := tls.X509KeyPair(endpoint.Cert, endpoint.Key)
+		if err != nil {
+			return nil, fmt.Errorf("winrm: failed to parse the client certificate and key: %w", err)
+		}
+		transport.TLSClientConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	return transport, nil
+}
+
+// endpointURL returns the URL of the WinRM service of the endpoint.
+func endpointURL(endpoint *winrm.Endpoint) string {
+	scheme := "http"
+	if endpoint.HTTPS {
+		scheme = "https"
+	}
+
+	return fmt.Sprintf("%s://%s/wsman", scheme, net.JoinHostPort(endpoint.Host, fmt.Sprint(endpoint.Port)))
+}
+
+// send sends a request via the HTTP transport and returns the body of the response.
+func send(transport http.RoundTripper, req *http.Request) (string, error) {
+	return readResponse((&http.Client{Transport: transport}).Do(req))
+}
+
+// readResponse returns the body of the response of a request.
+func readResponse(resp *http.Response, err error) (string, error) {
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("http error %d: %s", resp.StatusCode, body)
+	}
+
+	return string(body), nil
+}
@@ -0,0 +1,408 @@
+package winrm
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/d-strobel/gowindows/connection"
+	"github.com/jcmturner/gokrb5/v8/keytab"
+	"github.com/masterzen/winrm"
+)
+
+// testKerberosConfig writes a krb5.conf with an unreachable KDC and a keytab of the test user
+// and returns the Kerberos settings for them.
+func (suite *WinRMUnitTestSuite) testKerberosConfig() *KerberosConfig {
+	dir := suite.T().TempDir()
+
+	// A port nobody listens on.
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	suite.Require().NoError(err)
+	kdc := listener.Addr().String()
+	suite.Require().NoError(listener.Close())
+
+	krb5Conf := fmt.Sprintf(`[libdefaults]
+  default_realm = EXAMPLE.COM
+  udp_preference_limit = 1
+
+[realms]
+  EXAMPLE.COM = {
+    kdc = %s
+  }
+`, kdc)
+	configPath := filepath.Join(dir, "krb5.conf")
+	suite.Require().NoError(os.WriteFile(configPath, []byte(krb5Conf), 0o600))
+
+	kt := keytab.New()
+	suite.Require().NoError(kt.AddEntry(testServerUsername, "EXAMPLE.COM", testServerPassword, time.Now(), 1, 18))
+	b, err := kt.Marshal()
+	suite.Require().NoError(err)
+	keytabPath := filepath.Join(dir, "user.keytab")
+	suite.Require().NoError(os.WriteFile(keytabPath, b, 0o600))
+
+	return &KerberosConfig{ConfigPath: configPath, KeytabPath: keytabPath}
+}
+
+func (suite *WinRMUnitTestSuite) TestTransporter() {
+	suite.T().Parallel()
+
+	suite.Run("should return the transport of the authentication method", func() {
+		tcs := []struct {
+			description string
+			config      *Config
+			expected    winrm.Transporter
+		}{
+			{
+				"NTLM over HTTP",
+				&Config{Auth: AuthNTLM},
+				&ntlmEncryptionTransport{},
+			},
+			{
+				"NTLM over HTTP without encryption",
+				&Config{Auth: AuthNTLM, DisableEncryption: true},
+				&winrm.ClientNTLM{},
+			},
+			{
+				"NTLM over HTTPS",
+				&Config{Auth: AuthNTLM, UseTLS: true},
+				&winrm.ClientNTLM{},
+			},
+			{
+				"Kerberos",
+				&Config{Auth: AuthKerberos, Kerberos: &KerberosConfig{}},
+				&kerberosTransport{},
+			},
+			{
+				"Certificate",
+				&Config{Auth: AuthCertificate, UseTLS: true},
+				&certificateTransport{},
+			},
+			{
+				"Negotiate without Kerberos",
+				&Config{Auth: AuthNegotiate, UseTLS: true},
+				&winrm.ClientNTLM{},
+			},
+			{
+				"Negotiate with Kerberos",
+				&Config{Auth: AuthNegotiate, Kerberos: &KerberosConfig{}},
+				&negotiateTransport{},
+			},
+		}
+
+		for _, tc := range tcs {
+			suite.T().Logf("test case: %s", tc.description)
+			transport, err := tc.config.transporter()
+			suite.Require().NoError(err)
+			suite.IsType(tc.expected, transport)
+		}
+	})
+}
+
+func (suite *WinRMUnitTestSuite) TestNTLM() {
+	suite.Run("should authenticate the commands via NTLM", func() {
+		server := newTestServer(suite.T(), func(cmd string, stdin io.Reader, stdout io.Writer, stderr io.Writer) int {
+			fmt.Fprint(stdout, "authenticated")
+			return 0
+		})
+		server.ntlm = true
+
+		config := server.config()
+		config.Auth = AuthNTLM
+		config.DisableEncryption = true
+
+		conn, err := NewConnection(config)
+		suite.Require().NoError(err)
+
+		result, err := conn.Run(context.Background(), "whoami")
+		suite.Require().NoError(err)
+		suite.Equal("authenticated", result.StdOut)
+		suite.NotEmpty(server.authenticatedUsers())
+		suite.Subset([]string{testServerUsername}, server.authenticatedUsers())
+	})
+
+	suite.Run("should encrypt the messages via NTLM over HTTP", func() {
+		server := newTestServer(suite.T(), func(cmd string, stdin io.Reader, stdout io.Writer, stderr io.Writer) int {
+			input, _ := io.ReadAll(stdin)
+			fmt.Fprintf(stdout, "%s %s", cmd, input)
+			return 0
+		})
+		server.ntlm = true
+		server.encryption = true
+
+		config := server.config()
+		config.Auth = AuthNTLM
+
+		conn, err := NewConnection(config)
+		suite.Require().NoError(err)
+
+		result, err := connection.RunWithInput(context.Background(), conn, "Read-Host", strings.NewReader("input"))
+		suite.Require().NoError(err)
+		suite.Equal("Read-Host input", result.StdOut)
+		suite.Equal(0, result.ExitCode)
+		suite.Positive(server.unsealedMessages())
+		suite.Subset([]string{testServerUsername}, server.authenticatedUsers())
+	})
+
+	suite.Run("should reuse the encrypted session for the messages of the commands", func() {
+		server := newTestServer(suite.T(), func(cmd string, stdin io.Reader, stdout io.Writer, stderr io.Writer) int {
+			fmt.Fprint(stdout, cmd)
+			return 0
+		})
+		server.ntlm = true
+		server.encryption = true
+
+		config := server.config()
+		config.Auth = AuthNTLM
+
+		conn, err := NewConnection(config)
+		suite.Require().NoError(err)
+
+		for _, cmd := range []string{"whoami", "hostname", "ipconfig"} {
+			result, err := conn.Run(context.Background(), cmd)
+			suite.Require().NoError(err)
+			suite.Equal(cmd, result.StdOut)
+		}
+		// The input of a command is sent while its output is received, which needs a second session.
+		suite.Greater(server.unsealedMessages(), 6)
+		suite.LessOrEqual(len(server.authenticatedUsers()), ntlmMaxIdleSessions)
+	})
+
+	suite.Run("should run a new handshake if the host closed the encrypted session", func() {
+		server := newTestServer(suite.T(), func(cmd string, stdin io.Reader, stdout io.Writer, stderr io.Writer) int {
+			fmt.Fprint(stdout, cmd)
+			return 0
+		})
+		server.ntlm = true
+		server.encryption = true
+
+		config := server.config()
+		config.Auth = AuthNTLM
+
+		conn, err := NewConnection(config)
+		suite.Require().NoError(err)
+
+		_, err = conn.Run(context.Background(), "whoami")
+		suite.Require().NoError(err)
+		handshakes := len(server.authenticatedUsers())
+
+		// The host forgets the sessions, like after the keep-alive connections were closed.
+		server.mu.Lock()
+		server.sessions = nil
+		server.mu.Unlock()
+
+		result, err := conn.Run(context.Background(), "hostname")
+		suite.Require().NoError(err)
+		suite.Equal("hostname", result.StdOut)
+		suite.Greater(len(server.authenticatedUsers()), handshakes)
+	})
+
+	suite.Run("should be rejected by a host that requires encryption if the encryption is disabled", func() {
+		server := newTestServer(suite.T(), func(cmd string, stdin io.Reader, stdout io.Writer, stderr io.Writer) int {
+			return 0
+		})
+		server.ntlm = true
+		server.encryption = true
+
+		config := server.config()
+		config.Auth = AuthNTLM
+		config.DisableEncryption = true
+
+		conn, err := NewConnection(config)
+		suite.Require().NoError(err)
+
+		_, err = conn.Run(context.Background(), "whoami")
+		suite.Error(err)
+		suite.Zero(server.unsealedMessages())
+	})
+
+	suite.Run("should fail without NTLM authentication", func() {
+		server := newTestServer(suite.T(), func(cmd string, stdin io.Reader, stdout io.Writer, stderr io.Writer) int {
+			return 0
+		})
+		server.ntlm = true
+
+		conn, err := NewConnection(server.config())
+		suite.Require().NoError(err)
+
+		_, err = conn.Run(context.Background(), "whoami")
+		suite.Error(err)
+		suite.Empty(server.authenticatedUsers())
+	})
+}
+
+func (suite *WinRMUnitTestSuite) TestKerberos() {
+	suite.Run("should return an error if the Kerberos configuration does not exist", func() {
+		server := newTestServer(suite.T(), func(cmd string, stdin io.Reader, stdout io.Writer, stderr io.Writer) int {
+			return 0
+		})
+
+		config := server.config()
+		config.Auth = AuthKerberos
+		config.DisableEncryption = true
+		config.Kerberos = &KerberosConfig{ConfigPath: filepath.Join(suite.T().TempDir(), "krb5.conf")}
+
+		_, err := NewConnection(config)
+		suite.ErrorContains(err, "failed to load the Kerberos configuration")
+	})
+
+	suite.Run("should return an error if no ticket can be obtained", func() {
+		var called bool
+		server := newTestServer(suite.T(), func(cmd string, stdin io.Reader, stdout io.Writer, stderr io.Writer) int {
+			called = true
+			return 0
+		})
+
+		config := server.config()
+		config.Auth = AuthKerberos
+		config.DisableEncryption = true
+		config.Password = ""
+		config.Kerberos = suite.testKerberosConfig()
+
+		conn, err := NewConnection(config)
+		suite.Require().NoError(err)
+
+		_, err = conn.Run(context.Background(), "whoami")
+		suite.ErrorIs(err, errKerberosTicket)
+		suite.False(called)
+	})
+}
+
+func (suite *WinRMUnitTestSuite) TestNegotiate() {
+	suite.Run("should fall back to NTLM if no Kerberos ticket can be obtained", func() {
+		server := newTestServer(suite.T(), func(cmd string, stdin io.Reader, stdout io.Writer, stderr io.Writer) int {
+			fmt.Fprint(stdout, "authenticated")
+			return 0
+		})
+		server.ntlm = true
+
+		config := server.config()
+		config.Auth = AuthNegotiate
+		config.DisableEncryption = true
+		config.Kerberos = suite.testKerberosConfig()
+
+		conn, err := NewConnection(config)
+		suite.Require().NoError(err)
+
+		result, err := conn.Run(context.Background(), "whoami")
+		suite.Require().NoError(err)
+		suite.Equal("authenticated", result.StdOut)
+		suite.Subset([]string{testServerUsername}, server.authenticatedUsers())
+	})
+}
+
+func (suite *WinRMUnitTestSuite) TestTLS() {
+	pki := newTestPKI(suite.T())
+	handler := func(cmd string, stdin io.Reader, stdout io.Writer, stderr io.Writer) int {
+		fmt.Fprint(stdout, "verified")
+		return 0
+	}
+
+	suite.Run("should verify the host with the CA and server name", func() {
+		server := newTestServerTLS(suite.T(), handler, &tls.Config{Certificates: []tls.Certificate{pki.serverCert}})
+
+		caCertPath := filepath.Join(suite.T().TempDir(), "ca.pem")
+		suite.Require().NoError(os.WriteFile(caCertPath, []byte(pki.caCert), 0o600))
+
+		tcs := []struct {
+			description string
+			config      func(config *Config)
+		}{
+			{"CACert", func(config *Config) { config.CACert = pki.caCert }},
+			{"CACertPath", func(config *Config) { config.CACertPath = caCertPath }},
+		}
+
+		for _, tc := range tcs {
+			suite.T().Logf("test case: %s", tc.description)
+			config := server.config()
+			config.TLSServerName = "winsrv.test"
+			tc.config(config)
+
+			conn, err := NewConnection(config)
+			suite.Require().NoError(err)
+
+			result, err := conn.Run(context.Background(), "whoami")
+			suite.Require().NoError(err)
+			suite.Equal("verified", result.StdOut)
+		}
+	})
+
+	suite.Run("should return an error if the host can not be verified", func() {
+		server := newTestServerTLS(suite.T(), handler, &tls.Config{Certificates: []tls.Certificate{pki.serverCert}})
+
+		tcs := []struct {
+			description string
+			config      func(config *Config)
+		}{
+			{"system trust store", func(config *Config) { config.TLSServerName = "winsrv.test" }},
+			{"wrong server name", func(config *Config) { config.CACert = pki.caCert }},
+		}
+
+		for _, tc := range tcs {
+			suite.T().Logf("test case: %s", tc.description)
+			config := server.config()
+			tc.config(config)
+
+			conn, err := NewConnection(config)
+			suite.Require().NoError(err)
+
+			_, err = conn.Run(context.Background(), "whoami")
+			suite.Error(err)
+		}
+	})
+
+	suite.Run("should authenticate with a client certificate", func() {
+		server := newTestServerTLS(suite.T(), handler, &tls.Config{
+			Certificates: []tls.Certificate{pki.serverCert},
+			ClientAuth:   tls.RequireAndVerifyClientCert,
+			ClientCAs:    pki.caPool,
+		})
+
+		config := server.config()
+		config.Username = ""
+		config.Password = ""
+		config.Auth = AuthCertificate
+		config.CACert = pki.caCert
+		config.TLSServerName = "winsrv.test"
+		config.ClientCert = pki.clientCert
+		config.ClientKey = pki.clientKey
+
+		conn, err := NewConnection(config)
+		suite.Require().NoError(err)
+
+		result, err := conn.Run(context.Background(), "whoami")
+		suite.Require().NoError(err)
+		suite.Equal("verified", result.StdOut)
+	})
+
+	suite.Run("should return an error for invalid certificates at construction time", func() {
+		tcs := []struct {
+			description string
+			config      *Config
+		}{
+			{
+				"invalid CACert",
+				&Config{Host: "test", Username: "test", Password: "test", CACert: "no certificate"},
+			},
+			{
+				"missing CACertPath",
+				&Config{Host: "test", Username: "test", Password: "test", CACertPath: filepath.Join(suite.T().TempDir(), "ca.pem")},
+			},
+			{
+				"client key does not match the certificate",
+				&Config{Host: "test", Auth: AuthCertificate, UseTLS: true, ClientCert: pki.clientCert, ClientKey: pki.caCert},
+			},
+		}
+
+		for _, tc := range tcs {
+			suite.T().Logf("test case: %s", tc.description)
+			_, err := NewConnection(tc.config)
+			suite.Error(err)
+		}
+	})
+}
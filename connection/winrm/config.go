@@ -1,17 +1,51 @@
 package winrm
 
 import (
+	"crypto/tls"
+	"crypto/x509"
 	"errors"
+	"fmt"
+	"net"
+	"os"
 	"time"
 )
 
+// AuthMethod is the authentication method of a WinRM connection.
+type AuthMethod string
+
+// Authentication methods of a WinRM connection.
+const (
+	// AuthBasic authenticates with username and password via HTTP Basic authentication.
+	AuthBasic AuthMethod = "basic"
+
+	// AuthNTLM authenticates with username and password via NTLM.
+	// Over HTTP, the messages are encrypted with the NTLM session key.
+	AuthNTLM AuthMethod = "ntlm"
+
+	// AuthKerberos authenticates via Kerberos with a password, a keytab or a credential cache.
+	// The messages are not encrypted with the Kerberos session key, so Kerberos requires HTTPS,
+	// unless the encryption is disabled explicitly.
+	AuthKerberos AuthMethod = "kerberos"
+
+	// AuthNegotiate authenticates via Kerberos if Kerberos is configured and falls back to NTLM
+	// if no Kerberos ticket could be obtained.
+	// Like AuthKerberos, it requires HTTPS if Kerberos is configured, unless the encryption is disabled explicitly.
+	AuthNegotiate AuthMethod = "negotiate"
+
+	// AuthCertificate authenticates with a client certificate over HTTPS.
+	AuthCertificate AuthMethod = "certificate"
+)
+
 // Default values for WinRM configuration.
 const (
-	defaultPort     int           = 5985
-	defaultPortTLS  int           = 5986
-	defaultUseTLS   bool          = false
-	defaultInsecure bool          = false
-	defaultTimeout  time.Duration = 0
+	defaultPort           int           = 5985
+	defaultPortTLS        int           = 5986
+	defaultUseTLS         bool          = false
+	defaultInsecure       bool          = false
+	defaultTimeout        time.Duration = 0
+	defaultAuth           AuthMethod    = AuthBasic
+	defaultKrb5ConfigPath string        = "/etc/krb5.conf"
+	defaultTransferChunk  int           = 512 << 10
 )
 
 // Config represents the configuration details for establishing a WinRM connection.
@@ -23,12 +57,147 @@ type Config struct {
 	UseTLS   bool
 	Insecure bool
 	Timeout  time.Duration
+
+	// Auth is the authentication method. Defaults to AuthBasic.
+	Auth AuthMethod
+
+	// DisableEncryption disables the message encryption of NTLM over HTTP
+	// and allows Kerberos over HTTP, which sends unencrypted messages.
+	// The WinRM service of the host must allow unencrypted messages.
+	DisableEncryption bool
+
+	// Kerberos holds the Kerberos settings for AuthKerberos and AuthNegotiate.
+	Kerberos *KerberosConfig
+
+	// CACert and CACertPath set a PEM encoded CA bundle to verify the certificate of the host
+	// instead of the system trust store.
+	CACert     string
+	CACertPath string
+
+	// TLSServerName overrides the host name to verify the certificate of the host against.
+	TLSServerName string
+
+	// ClientCert and ClientKey, or their paths, set the PEM encoded client certificate and key for AuthCertificate.
+	ClientCert     string
+	ClientCertPath string
+	ClientKey      string
+	ClientKeyPath  string
+
+	// Dial overrides how the TCP connections to the host are established,
+	// e.g. with the Dial method of an ssh.JumpDialer to tunnel WinRM through an SSH jump host.
+	Dial func(network, addr string) (net.Conn, error)
+
+	// TransferChunkSize is the number of bytes that Upload and Download transfer per command.
+	// Defaults to 512 KiB.
+	TransferChunkSize int
+}
+
+// KerberosConfig represents the Kerberos settings of a WinRM connection.
+// The credentials are taken from exactly one of the Config's password, the keytab or the credential cache.
+type KerberosConfig struct {
+	// Realm is the Kerberos realm of the user. Defaults to the default realm of the krb5.conf.
+	Realm string
+
+	// ConfigPath is the path to the krb5.conf. Defaults to /etc/krb5.conf.
+	ConfigPath string
+
+	// KeytabPath is the path to a keytab with the keys of the user.
+	KeytabPath string
+
+	// CCachePath is the path to a credential cache with a ticket of the user.
+	CCachePath string
+
+	// SPN overrides the service principal name of the host. Defaults to HTTP/<host>.
+	SPN string
 }
 
 // validate validates the WinRM configuration.
 func (config *Config) validate() error {
-	if config.Host == "" || config.Username == "" || config.Password == "" {
-		return errors.New("winrm: Config parameter 'Host', 'Username', and 'Password' must be set")
+	if config.Host == "" {
+		return errors.New("winrm: Config parameter 'Host' must be set")
+	}
+
+	switch config.Auth {
+	case "", AuthBasic, AuthNTLM:
+		if config.Username == "" || config.Password == "" {
+			return errors.New("winrm: Config parameter 'Host', 'Username', and 'Password' must be set")
+		}
+
+	case AuthKerberos:
+		if config.Kerberos == nil {
+			return errors.New("winrm: Config parameter 'Kerberos' must be set for Kerberos authentication")
+		}
+		if err := config.validateKerberos(); err != nil {
+			return err
+		}
+
+	case AuthCertificate:
+		if !config.UseTLS {
+			return errors.New("winrm: Config parameter 'UseTLS' must be set for certificate authentication")
+		}
+		if (config.ClientCert == "" && config.ClientCertPath == "") || (config.ClientKey == "" && config.ClientKeyPath == "") {
+			return errors.New("winrm: Config parameter 'ClientCert' or 'ClientCertPath' and 'ClientKey' or 'ClientKeyPath' must be set for certificate authentication")
+		}
+
+	case AuthNegotiate:
+		if config.Kerberos == nil {
+			if config.Username == "" || config.Password == "" {
+				return errors.New("winrm: Config parameter 'Username' and 'Password' must be set for Negotiate authentication without Kerberos")
+			}
+		} else if err := config.validateKerberos(); err != nil {
+			return err
+		}
+
+	default:
+		return fmt.Errorf("winrm: Config parameter 'Auth' has an unknown value '%s'", config.Auth)
+	}
+
+	// The messages of Kerberos are only encrypted by TLS.
+	if config.Kerberos != nil && (config.Auth == AuthKerberos || config.Auth == AuthNegotiate) &&
+		!config.UseTLS && !config.DisableEncryption {
+		return errors.New("winrm: Config parameter 'UseTLS' or 'DisableEncryption' must be set for Kerberos authentication")
+	}
+
+	if config.TransferChunkSize < 0 {
+		return errors.New("winrm: Config parameter 'TransferChunkSize' must not be negative")
+	}
+
+	if config.Auth != AuthCertificate &&
+		(config.ClientCert != "" || config.ClientCertPath != "" || config.ClientKey != "" || config.ClientKeyPath != "") {
+		return errors.New("winrm: Config parameter 'ClientCert' and 'ClientKey' must only be set for certificate authentication")
+	}
+
+	if (config.CACert != "" && config.CACertPath != "") ||
+		(config.ClientCert != "" && config.ClientCertPath != "") ||
+		(config.ClientKey != "" && config.ClientKeyPath != "") {
+		return errors.New("winrm: Config parameter 'CACert', 'ClientCert' and 'ClientKey' must not be set together with their path")
+	}
+
+	return nil
+}
+
+// validateKerberos validates the Kerberos settings of the WinRM configuration.
+// With AuthNegotiate, the password may be set besides a keytab or credential cache for the NTLM fallback.
+func (config *Config) validateKerberos() error {
+	keytab := config.Kerberos.KeytabPath != ""
+	ccache := config.Kerberos.CCachePath != ""
+	password := config.Password != ""
+
+	if keytab && ccache {
+		return errors.New("winrm: Config parameter 'Kerberos.KeytabPath' and 'Kerberos.CCachePath' must not be set both")
+	}
+
+	if !keytab && !ccache && !password {
+		return errors.New("winrm: one of Config parameter 'Password', 'Kerberos.KeytabPath' and 'Kerberos.CCachePath' must be set")
+	}
+
+	if config.Auth == AuthKerberos && password && (keytab || ccache) {
+		return errors.New("winrm: Config parameter 'Password' must not be set with 'Kerberos.KeytabPath' or 'Kerberos.CCachePath'")
+	}
+
+	// Only the credential cache holds the principal of the user.
+	if config.Username == "" && (!ccache || password) {
+		return errors.New("winrm: Config parameter 'Username' must be set for authentication with a password or keytab")
 	}
 
 	return nil
@@ -57,5 +226,75 @@ func (config *Config) defaults() error {
 		config.Insecure = defaultInsecure
 	}
 
+	if config.Auth == "" {
+		config.Auth = defaultAuth
+	}
+
+	if config.TransferChunkSize == 0 {
+		config.TransferChunkSize = defaultTransferChunk
+	}
+
+	if config.Kerberos != nil {
+		if config.Kerberos.ConfigPath == "" {
+			config.Kerberos.ConfigPath = defaultKrb5ConfigPath
+		}
+
+		if config.Kerberos.SPN == "" {
+			config.Kerberos.SPN = "HTTP/" + config.Host
+		}
+	}
+
 	return nil
 }
+
+// certificates returns the PEM encoded CA bundle, client certificate and client key of the WinRM configuration.
+// The contents are read from their paths if set and are parsed, so invalid certificates are reported early.
+func (config *Config) certificates() (caCert []byte, clientCert []byte, clientKey []byte, err error) {
+	caCert, err = readPEM(config.CACert, config.CACertPath)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	if caCert != nil && !x509.NewCertPool().AppendCertsFromPEM(caCert) {
+		return nil, nil, nil, errors.New("winrm: Config parameter 'CACert' contains no valid PEM encoded certificate")
+	}
+
+	if config.Auth != AuthCertificate {
+		return caCert, nil, nil, nil
+	}
+
+	clientCert, err = readPEM(config.ClientCert, config.ClientCertPath)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	clientKey, err = readPEM(config.ClientKey, config.ClientKeyPath)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	if _, err := tls.X509KeyPair(clientCert, clientKey); err != nil {
+		return nil, nil, nil, fmt.Errorf("winrm: failed to parse the client certificate and key: %w", err)
+	}
+
+	return caCert, clientCert, clientKey, nil
+}
+
+// readPEM returns the PEM content or reads it from the path.
+// It returns nil if neither is set.
+func readPEM(content string, path string) ([]byte, error) {
+	if content != "" {
+		return []byte(content), nil
+	}
+
+	if path == "" {
+		return nil, nil
+	}
+
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("winrm: failed to read '%s': %w", path, err)
+	}
+
+	return b, nil
+}
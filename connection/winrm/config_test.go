@@ -28,6 +28,86 @@ func (suite *WinRMUnitTestSuite) TestValidate() {
 					Timeout:  0,
 				},
 			},
+			{
+				"NTLM",
+				&Config{
+					Host:     "test",
+					Username: "test",
+					Password: "test",
+					Auth:     AuthNTLM,
+				},
+			},
+			{
+				"Kerberos + Password",
+				&Config{
+					Host:     "test",
+					Username: "test",
+					Password: "test",
+					UseTLS:   true,
+					Auth:     AuthKerberos,
+					Kerberos: &KerberosConfig{Realm: "EXAMPLE.COM"},
+				},
+			},
+			{
+				"Kerberos + Keytab",
+				&Config{
+					Host:     "test",
+					Username: "test",
+					UseTLS:   true,
+					Auth:     AuthKerberos,
+					Kerberos: &KerberosConfig{KeytabPath: "test.keytab"},
+				},
+			},
+			{
+				"Kerberos + CCache",
+				&Config{
+					Host:     "test",
+					UseTLS:   true,
+					Auth:     AuthKerberos,
+					Kerberos: &KerberosConfig{CCachePath: "/tmp/krb5cc_1000"},
+				},
+			},
+			{
+				"Kerberos over HTTP without encryption",
+				&Config{
+					Host:              "test",
+					Auth:              AuthKerberos,
+					DisableEncryption: true,
+					Kerberos:          &KerberosConfig{CCachePath: "/tmp/krb5cc_1000"},
+				},
+			},
+			{
+				"Negotiate without Kerberos",
+				&Config{
+					Host:     "test",
+					Username: "test",
+					Password: "test",
+					Auth:     AuthNegotiate,
+				},
+			},
+			{
+				"Certificate + TLS",
+				&Config{
+					Host:          "test",
+					UseTLS:        true,
+					Auth:          AuthCertificate,
+					ClientCert:    "cert",
+					ClientKeyPath: "client.key",
+					CACertPath:    "ca.pem",
+					TLSServerName: "winsrv.test",
+				},
+			},
+			{
+				"Negotiate + Keytab + Password",
+				&Config{
+					Host:     "test",
+					Username: "test",
+					Password: "test",
+					UseTLS:   true,
+					Auth:     AuthNegotiate,
+					Kerberos: &KerberosConfig{KeytabPath: "test.keytab"},
+				},
+			},
 		}
 
 		for _, tc := range tcs {
@@ -56,6 +136,154 @@ func (suite *WinRMUnitTestSuite) TestValidate() {
 					Password: "test",
 				},
 			},
+			{
+				"unknown Auth",
+				&Config{
+					Host:     "test",
+					Username: "test",
+					Password: "test",
+					Auth:     "credssp",
+				},
+			},
+			{
+				"negative TransferChunkSize",
+				&Config{
+					Host:              "test",
+					Username:          "test",
+					Password:          "test",
+					TransferChunkSize: -1,
+				},
+			},
+			{
+				"NTLM without Password",
+				&Config{
+					Host:     "test",
+					Username: "test",
+					Auth:     AuthNTLM,
+				},
+			},
+			{
+				"Kerberos without Kerberos",
+				&Config{
+					Host:     "test",
+					Username: "test",
+					Password: "test",
+					Auth:     AuthKerberos,
+				},
+			},
+			{
+				"Kerberos without credentials",
+				&Config{
+					Host:     "test",
+					Username: "test",
+					Auth:     AuthKerberos,
+					Kerberos: &KerberosConfig{},
+				},
+			},
+			{
+				"Kerberos + Keytab + CCache",
+				&Config{
+					Host:     "test",
+					Username: "test",
+					Auth:     AuthKerberos,
+					Kerberos: &KerberosConfig{KeytabPath: "test.keytab", CCachePath: "/tmp/krb5cc_1000"},
+				},
+			},
+			{
+				"Kerberos + Keytab + Password",
+				&Config{
+					Host:     "test",
+					Username: "test",
+					Password: "test",
+					Auth:     AuthKerberos,
+					Kerberos: &KerberosConfig{KeytabPath: "test.keytab"},
+				},
+			},
+			{
+				"Kerberos over HTTP",
+				&Config{
+					Host:     "test",
+					Username: "test",
+					Password: "test",
+					Auth:     AuthKerberos,
+					Kerberos: &KerberosConfig{Realm: "EXAMPLE.COM"},
+				},
+			},
+			{
+				"Negotiate + Kerberos over HTTP",
+				&Config{
+					Host:     "test",
+					Username: "test",
+					Password: "test",
+					Auth:     AuthNegotiate,
+					Kerberos: &KerberosConfig{KeytabPath: "test.keytab"},
+				},
+			},
+			{
+				"ClientCert without certificate authentication",
+				&Config{
+					Host:       "test",
+					Username:   "test",
+					Password:   "test",
+					UseTLS:     true,
+					ClientCert: "cert",
+					ClientKey:  "key",
+				},
+			},
+			{
+				"ClientKeyPath with NTLM",
+				&Config{
+					Host:          "test",
+					Username:      "test",
+					Password:      "test",
+					Auth:          AuthNTLM,
+					ClientKeyPath: "client.key",
+				},
+			},
+			{
+				"Kerberos + Keytab without Username",
+				&Config{
+					Host:     "test",
+					Auth:     AuthKerberos,
+					Kerberos: &KerberosConfig{KeytabPath: "test.keytab"},
+				},
+			},
+			{
+				"Certificate without TLS",
+				&Config{
+					Host:       "test",
+					Auth:       AuthCertificate,
+					ClientCert: "cert",
+					ClientKey:  "key",
+				},
+			},
+			{
+				"Certificate without ClientKey",
+				&Config{
+					Host:       "test",
+					UseTLS:     true,
+					Auth:       AuthCertificate,
+					ClientCert: "cert",
+				},
+			},
+			{
+				"CACert + CACertPath",
+				&Config{
+					Host:       "test",
+					Username:   "test",
+					Password:   "test",
+					CACert:     "cert",
+					CACertPath: "ca.pem",
+				},
+			},
+			{
+				"Negotiate without Kerberos and Password",
+				&Config{
+					Host:     "test",
+					Username: "test",
+					Auth:     AuthNegotiate,
+				},
+			},
 		}
 
 		for _, tc := range tcs {
@@ -82,14 +310,38 @@ func (suite *WinRMUnitTestSuite) TestDefaults() {
 					Username: "test",
 					Password: "test",
 				},
+				&Config{
+					Host:              "test",
+					Username:          "test",
+					Password:          "test",
+					UseTLS:            false,
+					Insecure:          false,
+					Port:              5985,
+					Timeout:           0,
+					Auth:              AuthBasic,
+					TransferChunkSize: 512 << 10,
+				},
+			},
+			{
+				"minimal config + Kerberos",
 				&Config{
 					Host:     "test",
 					Username: "test",
 					Password: "test",
-					UseTLS:   false,
-					Insecure: false,
-					Port:     5985,
-					Timeout:  0,
+					Auth:     AuthKerberos,
+					Kerberos: &KerberosConfig{},
+				},
+				&Config{
+					Host:              "test",
+					Username:          "test",
+					Password:          "test",
+					Port:              5985,
+					Auth:              AuthKerberos,
+					TransferChunkSize: 512 << 10,
+					Kerberos: &KerberosConfig{
+						ConfigPath: "/etc/krb5.conf",
+						SPN:        "HTTP/test",
+					},
 				},
 			},
 			{
@@ -101,13 +353,15 @@ func (suite *WinRMUnitTestSuite) TestDefaults() {
 					UseTLS:   true,
 				},
 				&Config{
-					Host:     "test",
-					Username: "test",
-					Password: "test",
-					UseTLS:   true,
-					Insecure: false,
-					Port:     5986,
-					Timeout:  0,
+					Host:              "test",
+					Username:          "test",
+					Password:          "test",
+					UseTLS:            true,
+					Insecure:          false,
+					Port:              5986,
+					Timeout:           0,
+					Auth:              AuthBasic,
+					TransferChunkSize: 512 << 10,
 				},
 			},
 		}
@@ -136,34 +390,39 @@ func (suite *WinRMUnitTestSuite) TestDefaults() {
 					Port:     5555,
 				},
 				&Config{
-					Host:     "test",
-					Username: "test",
-					Password: "test",
-					UseTLS:   true,
-					Insecure: false,
-					Port:     5555,
-					Timeout:  0,
+					Host:              "test",
+					Username:          "test",
+					Password:          "test",
+					UseTLS:            true,
+					Insecure:          false,
+					Port:              5555,
+					Timeout:           0,
+					Auth:              AuthBasic,
+					TransferChunkSize: 512 << 10,
 				},
 			},
 			{
 				"all",
 				&Config{
-					Host:     "test",
-					Username: "test",
-					Password: "test",
-					UseTLS:   true,
-					Port:     5555,
-					Insecure: true,
-					Timeout:  5,
+					Host:              "test",
+					Username:          "test",
+					Password:          "test",
+					UseTLS:            true,
+					Port:              5555,
+					Insecure:          true,
+					Timeout:           5,
+					TransferChunkSize: 1024,
 				},
 				&Config{
-					Host:     "test",
-					Username: "test",
-					Password: "test",
-					UseTLS:   true,
-					Port:     5555,
-					Insecure: true,
-					Timeout:  5,
+					Host:              "test",
+					Username:          "test",
+					Password:          "test",
+					UseTLS:            true,
+					Port:              5555,
+					Insecure:          true,
+					Timeout:           5,
+					Auth:              AuthBasic,
+					TransferChunkSize: 1024,
 				},
 			},
 		}
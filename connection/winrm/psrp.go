@@ -0,0 +1,667 @@
+package winrm
+
+import (
+	"bufio"
+	"context"
+	"encoding/base64"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/d-strobel/gowindows/connection"
+	"github.com/d-strobel/gowindows/parsing"
+	"github.com/masterzen/simplexml/dom"
+	"github.com/masterzen/winrm"
+	"github.com/masterzen/winrm/soap"
+)
+
+// WS-Management resource and signal of the PowerShell Remoting Protocol.
+const (
+	psrpResourceURI string = "http://schemas.microsoft.com/powershell/Microsoft.PowerShell"
+	psrpSignalStop  string = "http://schemas.microsoft.com/powershell/signal/crtl_c"
+)
+
+// WS-Management actions of the PowerShell Remoting Protocol.
+const (
+	psrpActionCreate  string = "http://schemas.xmlsoap.org/ws/2004/09/transfer/Create"
+	psrpActionDelete  string = "http://schemas.xmlsoap.org/ws/2004/09/transfer/Delete"
+	psrpActionCommand string = "http://schemas.microsoft.com/wbem/wsman/1/windows/shell/Command"
+	psrpActionSend    string = "http://schemas.microsoft.com/wbem/wsman/1/windows/shell/Send"
+	psrpActionReceive string = "http://schemas.microsoft.com/wbem/wsman/1/windows/shell/Receive"
+	psrpActionSignal  string = "http://schemas.microsoft.com/wbem/wsman/1/windows/shell/Signal"
+)
+
+// psrpStopTimeout is the time to wait for a stopped pipeline before the runspace pool is closed.
+const psrpStopTimeout = 30 * time.Second
+
+// psrpNamespace is the namespace of the creation XML of a runspace pool.
+var psrpNamespace = dom.Namespace{Prefix: "psrp", Uri: "http://schemas.microsoft.com/powershell"}
+
+// PSRPConnection represents a WinRM connection that runs PowerShell commands in a runspace pool
+// of the PowerShell Remoting Protocol (MS-PSRP).
+// In contrast to Connection, no powershell.exe process is started per command and
+// the length of a command is not limited by the command line of a process.
+//
+// The runspace pool has a single runspace, so the commands of a connection run one after another.
+// It is safe to use a PSRPConnection from multiple goroutines.
+type PSRPConnection struct {
+	client    *winrm.Client
+	transport winrm.Transporter
+	url       string
+	chunkSize int
+
+	// exitCodeMarker prefixes the exit code that is emitted at the end of every pipeline, see psrpExitCodeScript.
+	exitCodeMarker string
+
+	// stopTimeout is the time to wait for a stopped pipeline, see psrpStopTimeout.
+	stopTimeout time.Duration
+
+	// mu serializes the pipelines of the runspace pool.
+	mu       sync.Mutex
+	shellId  string
+	objectId uint64
+}
+
+// NewPSRPConnection creates a new WinRM connection based on the provided WinRM configuration
+// and opens a runspace pool on the remote host.
+// The runspace pool stays open until the connection is closed.
+func NewPSRPConnection(config *Config) (*PSRPConnection, error) {
+	client, transport, err := newClient(config)
+	if err != nil {
+		return nil, err
+	}
+
+	c := &PSRPConnection{
+		client:         client,
+		transport:      transport,
+		url:            endpointURL(&winrm.Endpoint{Host: config.Host, Port: config.Port, HTTPS: config.UseTLS}),
+		chunkSize:      config.TransferChunkSize,
+		exitCodeMarker: "gowindows-exit-code-" + newGuid() + ":",
+		stopTimeout:    psrpStopTimeout,
+	}
+
+	if err := c.open(); err != nil {
+		return nil, fmt.Errorf("winrm: failed to open the runspace pool: %w", err)
+	}
+
+	return c, nil
+}
+
+// Close closes the runspace pool on the remote host.
+// Satisfies the Connection interface.
+func (c *PSRPConnection) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.shellId == "" {
+		return nil
+	}
+
+	err := c.delete()
+	c.shellId = ""
+	if err != nil {
+		return fmt.Errorf("winrm: failed to close the runspace pool: %w", err)
+	}
+
+	return nil
+}
+
+// delete deletes the runspace pool on the remote host, which stops all of its pipelines.
+func (c *PSRPConnection) delete() error {
+	message := soap.NewMessage()
+	c.header(message, psrpActionDelete).ShellId(c.shellId).Build()
+	message.NewBody()
+
+	_, err := c.post(message)
+	return err
+}
+
+// Run runs a command as a PowerShell script in the runspace pool.
+// Stdout contains the string representation of the output objects and stderr contains
+// the error records as a CLIXML error stream, the same way powershell.exe reports them.
+// The exit code is 1 if the pipeline failed or was stopped and the exit code of the last native command, $LASTEXITCODE, otherwise.
+func (c *PSRPConnection) Run(ctx context.Context, cmd string) (connection.CmdResult, error) {
+	var stdout, stderr strings.Builder
+
+	exitCode, err := c.Stream(ctx, cmd, connection.Streams{Stdout: &stdout, Stderr: &stderr})
+	if err != nil {
+		return connection.CmdResult{}, err
+	}
+
+	return connection.CmdResult{
+		StdOut:   stdout.String(),
+		StdErr:   stderr.String(),
+		ExitCode: exitCode,
+	}, nil
+}
+
+// RunWithPowershell runs a PowerShell command in the runspace pool.
+// It is the same as Run, because every command of a PSRPConnection is a PowerShell script.
+func (c *PSRPConnection) RunWithPowershell(ctx context.Context, cmd string) (connection.CmdResult, error) {
+	return c.Run(ctx, cmd)
+}
+
+// StreamWithPowershell runs a PowerShell command in the runspace pool.
+// It is the same as Stream, because every command of a PSRPConnection is a PowerShell script.
+func (c *PSRPConnection) StreamWithPowershell(ctx context.Context, cmd string, streams connection.Streams) (int, error) {
+	return c.Stream(ctx, cmd, streams)
+}
+
+// Stream runs a command as a PowerShell script in the runspace pool.
+// It writes every output object as a line to stdout and the error records to stderr as the data arrives.
+// Every line of stdin is sent as a string to the input of the pipeline, which the script reads with $input.
+// If the context is done, the pipeline is stopped on the remote host.
+// The exit code is the same as the one of Run.
+func (c *PSRPConnection) Stream(ctx context.Context, cmd string, streams connection.Streams) (int, error) {
+	stdout := streams.Stdout
+	if stdout == nil {
+		stdout = io.Discard
+	}
+	stderr := connection.NewCliXmlErrorWriter(io.Discard)
+	if streams.Stderr != nil {
+		stderr = connection.NewCliXmlErrorWriter(streams.Stderr)
+	}
+
+	state, exitCode, err := c.invoke(ctx, cmd, streams.Stdin, pipelineHandler{
+		output: func(data []byte) error {
+			s, ok := outputString(data)
+			if !ok {
+				return nil
+			}
+			_, err := io.WriteString(stdout, s+"\r\n")
+			return err
+		},
+		error: func(record psrpErrorRecord) error {
+			return stderr.WriteErrorRecord(record.consoleText())
+		},
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	if err := stderr.Close(); err != nil {
+		return 0, err
+	}
+
+	if state != psrpPipelineCompleted {
+		return 1, nil
+	}
+	return exitCode, nil
+}
+
+// PipelineResult contains the typed output and error streams of a pipeline.
+type PipelineResult struct {
+	// Output contains the CLIXML of every output object of the pipeline.
+	Output []string
+
+	// Errors contains the error records of the error stream followed by the terminating error of a failed pipeline.
+	Errors []parsing.ErrorRecord
+
+	// Failed reports whether the pipeline failed with a terminating error or was stopped.
+	Failed bool
+
+	// ExitCode is the exit code of the last native command of a pipeline that did not fail, $LASTEXITCODE.
+	ExitCode int
+}
+
+// UnmarshalOutput unmarshals the output objects of the pipeline into v.
+// A slice receives all output objects, any other type receives the single output object.
+// See parsing.UnmarshalCliXml for the mapping of the objects to Go types.
+func (r *PipelineResult) UnmarshalOutput(v any) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Pointer || rv.IsNil() {
+		return errors.New("winrm: v must be a non-nil pointer")
+	}
+
+	switch {
+	case len(r.Output) == 0:
+		return nil
+
+	// A single output object, e.g. a collection that was not enumerated, is unmarshaled as a whole.
+	case len(r.Output) == 1:
+		return parsing.UnmarshalCliXml(cliXmlDocument(r.Output[0]), v)
+
+	// The reference ids of the output objects are unique per object only,
+	// so every object is unmarshaled on its own.
+	case rv.Elem().Kind() == reflect.Slice:
+		s := reflect.MakeSlice(rv.Elem().Type(), len(r.Output), len(r.Output))
+		for i, output := range r.Output {
+			if err := parsing.UnmarshalCliXml(cliXmlDocument(output), s.Index(i).Addr().Interface()); err != nil {
+				return err
+			}
+		}
+		rv.Elem().Set(s)
+		return nil
+
+	default:
+		return fmt.Errorf("winrm: expected a single object but got %d objects", len(r.Output))
+	}
+}
+
+// Invoke runs a PowerShell script in the runspace pool and returns the typed output and error streams.
+// In contrast to Run, the output objects are not converted to strings, so they can be unmarshaled
+// with PipelineResult.UnmarshalOutput, and the error records are not rendered as text.
+// If the context is done, the pipeline is stopped on the remote host.
+func (c *PSRPConnection) Invoke(ctx context.Context, script string) (*PipelineResult, error) {
+	result := &PipelineResult{}
+
+	state, exitCode, err := c.invoke(ctx, script, nil, pipelineHandler{
+		output: func(data []byte) error {
+			result.Output = append(result.Output, string(data))
+			return nil
+		},
+		error: func(record psrpErrorRecord) error {
+			result.Errors = append(result.Errors, record.errorRecord())
+			return nil
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	result.Failed = state != psrpPipelineCompleted
+	if !result.Failed {
+		result.ExitCode = exitCode
+	}
+	return result, nil
+}
+
+// pipelineHandler handles the output and the error records of a pipeline.
+type pipelineHandler struct {
+	output func(data []byte) error
+	error  func(record psrpErrorRecord) error
+}
+
+// psrpExitCodeScript returns the script that is appended to the script of every pipeline.
+// It emits the exit code of the last native command with the marker and resets it,
+// because $LASTEXITCODE is kept by the runspace for the next pipeline.
+func psrpExitCodeScript(marker string) string {
+	return "\n" + parsing.PwshQuote(marker) + " + [int]$global:LASTEXITCODE; $global:LASTEXITCODE = 0"
+}
+
+// invoke runs a script in a new pipeline of the runspace pool and returns the final state of the pipeline
+// and the exit code of its last native command.
+// If stdin is not nil, every line of stdin is sent to the input of the pipeline.
+// If the context is done, the pipeline is stopped. If it does not stop within the stop timeout,
+// the runspace pool is closed.
+func (c *PSRPConnection) invoke(ctx context.Context, script string, stdin io.Reader, handler pipelineHandler) (int32, int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.shellId == "" {
+		return 0, 0, errors.New("winrm: the runspace pool is closed")
+	}
+	if err := ctx.Err(); err != nil {
+		return 0, 0, err
+	}
+
+	pid := newGuid()
+	script += psrpExitCodeScript(c.exitCodeMarker)
+	fragments, err := c.fragments(pid, psrpMessage{messageType: psrpTypeCreatePipeline, data: createPipelineData(script, stdin == nil)})
+	if err != nil {
+		return 0, 0, err
+	}
+
+	// The first fragment creates the pipeline, the remaining fragments are sent to the input stream.
+	message := soap.NewMessage()
+	c.header(message, psrpActionCommand).ShellId(c.shellId).Build()
+	commandLine := message.CreateBodyElement("CommandLine", soap.DOM_NS_WIN_SHELL)
+	commandLine.SetAttr("CommandId", pid)
+	message.CreateElement(commandLine, "Command", soap.DOM_NS_WIN_SHELL)
+	message.CreateElement(commandLine, "Arguments", soap.DOM_NS_WIN_SHELL).SetContent(base64.StdEncoding.EncodeToString(fragments[0]))
+
+	if _, err := c.post(message); err != nil {
+		return 0, 0, err
+	}
+	for _, fragment := range fragments[1:] {
+		if err := c.send(pid, fragment); err != nil {
+			return 0, 0, err
+		}
+	}
+
+	var state int32
+	var exitCode int
+	done := make(chan error, 1)
+
+	go func() {
+		if stdin != nil {
+			if err := c.sendInput(pid, stdin); err != nil {
+				done <- err
+				return
+			}
+		}
+
+		done <- c.receive(pid, func(m psrpMessage) (bool, error) {
+			switch m.messageType {
+			case psrpTypePipelineOutput:
+				if s, ok := outputString(m.data); ok {
+					if code, ok := strings.CutPrefix(s, c.exitCodeMarker); ok {
+						var err error
+						exitCode, err = strconv.Atoi(code)
+						return false, err
+					}
+				}
+				return false, handler.output(m.data)
+
+			case psrpTypeErrorRecord:
+				var record psrpErrorRecord
+				if err := parsing.UnmarshalCliXml(cliXmlDocument(string(m.data)), &record); err != nil {
+					return false, err
+				}
+				return false, handler.error(record)
+
+			case psrpTypePipelineState:
+				var pipelineState struct {
+					PipelineState          int32
+					ExceptionAsErrorRecord *psrpErrorRecord
+				}
+				if err := parsing.UnmarshalCliXml(cliXmlDocument(string(m.data)), &pipelineState); err != nil {
+					return false, err
+				}
+				if pipelineState.PipelineState != psrpPipelineCompleted &&
+					pipelineState.PipelineState != psrpPipelineFailed &&
+					pipelineState.PipelineState != psrpPipelineStopped {
+					return false, nil
+				}
+
+				state = pipelineState.PipelineState
+				if pipelineState.ExceptionAsErrorRecord != nil && state == psrpPipelineFailed {
+					return true, handler.error(*pipelineState.ExceptionAsErrorRecord)
+				}
+				return true, nil
+			}
+
+			// Other streams like warnings, progress and host calls are not handled.
+			return false, nil
+		})
+	}()
+
+	select {
+	case err := <-done:
+		return state, exitCode, err
+
+	case <-ctx.Done():
+	}
+
+	// Wait for the stopped pipeline, so nothing is written to the streams after returning.
+	_ = c.signal(pid)
+
+	timer := time.NewTimer(c.stopTimeout)
+	defer timer.Stop()
+
+	select {
+	case <-done:
+		return 0, 0, ctx.Err()
+
+	case <-timer.C:
+		// Deleting the runspace pool ends the pipeline and its pending receive request.
+		_ = c.delete()
+		<-done
+		c.shellId = ""
+		return 0, 0, fmt.Errorf("winrm: the pipeline did not stop within %s, the runspace pool is closed: %w", c.stopTimeout, ctx.Err())
+	}
+}
+
+// open creates the runspace pool and waits until it is opened.
+func (c *PSRPConnection) open() error {
+	rpid := newGuid()
+	c.shellId = rpid
+
+	var creationXml []byte
+	for _, m := range []psrpMessage{
+		{messageType: psrpTypeSessionCapability, data: sessionCapabilityData()},
+		{messageType: psrpTypeInitRunspacePool, data: initRunspacePoolData()},
+	} {
+		fragments, err := c.fragments("", m)
+		if err != nil {
+			return err
+		}
+		for _, fragment := range fragments {
+			creationXml = append(creationXml, fragment...)
+		}
+	}
+
+	message := soap.NewMessage()
+	c.header(message, psrpActionCreate).AddOption(soap.NewHeaderOption("protocolversion", "2.3")).Build()
+	shell := message.CreateBodyElement("Shell", soap.DOM_NS_WIN_SHELL)
+	shell.SetAttr("ShellId", rpid)
+	message.CreateElement(shell, "InputStreams", soap.DOM_NS_WIN_SHELL).SetContent("stdin pr")
+	message.CreateElement(shell, "OutputStreams", soap.DOM_NS_WIN_SHELL).SetContent("stdout")
+	message.CreateElement(shell, "creationXml", psrpNamespace).SetContent(base64.StdEncoding.EncodeToString(creationXml))
+
+	if _, err := c.post(message); err != nil {
+		c.shellId = ""
+		return err
+	}
+
+	err := c.receive("", func(m psrpMessage) (bool, error) {
+		if m.messageType != psrpTypeRunspacePoolState {
+			return false, nil
+		}
+
+		var poolState struct {
+			RunspaceState          int32
+			ExceptionAsErrorRecord *psrpErrorRecord
+		}
+		if err := parsing.UnmarshalCliXml(cliXmlDocument(string(m.data)), &poolState); err != nil {
+			return false, err
+		}
+
+		switch poolState.RunspaceState {
+		case psrpRunspacePoolOpened:
+			return true, nil
+		case psrpRunspacePoolBroken:
+			if poolState.ExceptionAsErrorRecord != nil {
+				return false, errors.New(poolState.ExceptionAsErrorRecord.message())
+			}
+			return false, errors.New("the runspace pool is broken")
+		}
+		return false, nil
+	})
+	if err != nil {
+		c.shellId = ""
+		return err
+	}
+
+	return nil
+}
+
+// fragments encodes a message of the runspace pool or of the pipeline with the id pid and splits it into fragments.
+func (c *PSRPConnection) fragments(pid string, m psrpMessage) ([][]byte, error) {
+	m.destination = psrpDestinationServer
+	m.rpid = c.shellId
+	m.pid = pid
+
+	b, err := m.encode()
+	if err != nil {
+		return nil, err
+	}
+
+	c.objectId++
+	return fragmentPsrpMessage(c.objectId, b, psrpMaxBlobSize), nil
+}
+
+// sendInput sends every line of stdin as a PIPELINE_INPUT message followed by an END_OF_PIPELINE_INPUT message.
+// The fragments are sent in chunks of the maximum blob size.
+func (c *PSRPConnection) sendInput(pid string, stdin io.Reader) error {
+	var chunk []byte
+	add := func(m psrpMessage) error {
+		fragments, err := c.fragments(pid, m)
+		if err != nil {
+			return err
+		}
+		for _, fragment := range fragments {
+			if len(chunk) > 0 && len(chunk)+len(fragment) > psrpMaxBlobSize+psrpFragmentHeaderSize {
+				if err := c.send(pid, chunk); err != nil {
+					return err
+				}
+				chunk = nil
+			}
+			chunk = append(chunk, fragment...)
+		}
+		return nil
+	}
+
+	r := bufio.NewReader(stdin)
+	for {
+		line, err := r.ReadString('\n')
+		if len(line) > 0 {
+			if err := add(psrpMessage{messageType: psrpTypePipelineInput, data: pipelineInputData(strings.TrimRight(line, "\r\n"))}); err != nil {
+				return err
+			}
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	if err := add(psrpMessage{messageType: psrpTypeEndOfPipelineInput}); err != nil {
+		return err
+	}
+	return c.send(pid, chunk)
+}
+
+// send sends fragments to the input stream of a pipeline.
+func (c *PSRPConnection) send(pid string, data []byte) error {
+	message := soap.NewMessage()
+	c.header(message, psrpActionSend).ShellId(c.shellId).Build()
+	send := message.CreateBodyElement("Send", soap.DOM_NS_WIN_SHELL)
+	stream := message.CreateElement(send, "Stream", soap.DOM_NS_WIN_SHELL)
+	stream.SetAttr("Name", "stdin")
+	stream.SetAttr("CommandId", pid)
+	stream.SetContent(base64.StdEncoding.EncodeToString(data))
+
+	_, err := c.post(message)
+	return err
+}
+
+// signal stops a running pipeline.
+func (c *PSRPConnection) signal(pid string) error {
+	message := soap.NewMessage()
+	c.header(message, psrpActionSignal).ShellId(c.shellId).Build()
+	signal := message.CreateBodyElement("Signal", soap.DOM_NS_WIN_SHELL)
+	signal.SetAttr("CommandId", pid)
+	message.CreateElement(signal, "Code", soap.DOM_NS_WIN_SHELL).SetContent(psrpSignalStop)
+
+	_, err := c.post(message)
+	return err
+}
+
+// psrpReceiveResponse holds the parts of a receive response the client needs.
+type psrpReceiveResponse struct {
+	Streams []struct {
+		Content string `xml:",chardata"`
+	} `xml:"Body>ReceiveResponse>Stream"`
+	CommandState struct {
+		State string `xml:"State,attr"`
+	} `xml:"Body>ReceiveResponse>CommandState"`
+}
+
+// receive receives the messages of the runspace pool or, if pid is set, of a pipeline
+// and passes them to handle until handle is done or returns an error.
+func (c *PSRPConnection) receive(pid string, handle func(m psrpMessage) (bool, error)) error {
+	var defragmenter psrpDefragmenter
+
+	for {
+		message := soap.NewMessage()
+		c.header(message, psrpActionReceive).
+			ShellId(c.shellId).
+			AddOption(soap.NewHeaderOption("WSMAN_CMDSHELL_OPTION_KEEPALIVE", "TRUE")).
+			Build()
+		receive := message.CreateBodyElement("Receive", soap.DOM_NS_WIN_SHELL)
+		stream := message.CreateElement(receive, "DesiredStream", soap.DOM_NS_WIN_SHELL)
+		if pid != "" {
+			stream.SetAttr("CommandId", pid)
+		}
+		stream.SetContent("stdout")
+
+		body, err := c.post(message)
+		if err != nil {
+			// The server did not send any output within the operation timeout.
+			if strings.Contains(err.Error(), "OperationTimeout") {
+				continue
+			}
+			return err
+		}
+
+		var response psrpReceiveResponse
+		if err := xml.Unmarshal([]byte(body), &response); err != nil {
+			return fmt.Errorf("winrm: failed to parse the receive response: %w", err)
+		}
+
+		for _, stream := range response.Streams {
+			data, err := base64.StdEncoding.DecodeString(strings.TrimSpace(stream.Content))
+			if err != nil {
+				return fmt.Errorf("winrm: failed to decode the output stream: %w", err)
+			}
+
+			messages, err := defragmenter.add(data)
+			if err != nil {
+				return fmt.Errorf("winrm: %w", err)
+			}
+
+			for _, m := range messages {
+				done, err := handle(m)
+				if err != nil || done {
+					return err
+				}
+			}
+		}
+
+		if strings.HasSuffix(response.CommandState.State, "/Done") {
+			return errors.New("winrm: the pipeline finished without a state")
+		}
+	}
+}
+
+// header returns the header of a request for the PowerShell resource.
+func (c *PSRPConnection) header(message *soap.SoapMessage, action string) *soap.SoapHeader {
+	return message.Header().
+		To(c.url).
+		ReplyTo("http://schemas.xmlsoap.org/ws/2004/08/addressing/role/anonymous").
+		MaxEnvelopeSize(c.client.Parameters.EnvelopeSize).
+		Id("uuid:" + newGuid()).
+		Locale(c.client.Parameters.Locale).
+		Timeout(c.client.Parameters.Timeout).
+		Action(action).
+		ResourceURI(psrpResourceURI)
+}
+
+// post sends a request to the WinRM service and returns the response.
+func (c *PSRPConnection) post(message *soap.SoapMessage) (string, error) {
+	return c.transport.Post(c.client, message)
+}
+
+// cliXmlDocument wraps the CLIXML of a single object into a CLIXML document.
+func cliXmlDocument(object string) string {
+	return `<Objs Version="1.1.0.1" xmlns="http://schemas.microsoft.com/powershell/2004/04">` + object + `</Objs>`
+}
+
+// outputString returns the string representation of an output object.
+// It reports false for a null object.
+func outputString(data []byte) (string, bool) {
+	var v any
+	if err := parsing.UnmarshalCliXml(cliXmlDocument(string(data)), &v); err != nil {
+		return string(data), true
+	}
+
+	switch v := v.(type) {
+	case nil:
+		return "", false
+	case string:
+		return v, true
+	case parsing.PSObject:
+		return v.ToString, true
+	default:
+		return fmt.Sprint(v), true
+	}
+}
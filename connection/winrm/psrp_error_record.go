@@ -0,0 +1,108 @@
+package winrm
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/d-strobel/gowindows/parsing"
+)
+
+// psrpErrorCategories contains the names of System.Management.Automation.ErrorCategory by their value.
+var psrpErrorCategories = []string{
+	"NotSpecified", "OpenError", "CloseError", "DeviceError", "DeadlockDetected", "InvalidArgument",
+	"InvalidData", "InvalidOperation", "InvalidResult", "InvalidType", "MetadataError", "NotImplemented",
+	"NotInstalled", "ObjectNotFound", "OperationStopped", "OperationTimeout", "SyntaxError", "ParserError",
+	"PermissionDenied", "ResourceBusy", "ResourceExists", "ResourceUnavailable", "ReadError", "WriteError",
+	"FromStdErr", "SecurityError", "ProtocolError", "ConnectionError", "AuthenticationError", "LimitsExceeded",
+	"QuotaExceeded", "NotEnabled",
+}
+
+// psrpErrorRecord represents a serialized error record of an ERROR_RECORD message or of a failed pipeline.
+// https://learn.microsoft.com/en-us/openspecs/windows_protocols/ms-psrp/0fe855a7-d13c-44e2-aa88-6a0f6b2c4d46
+type psrpErrorRecord struct {
+	Exception struct {
+		Message string
+	}
+	FullyQualifiedErrorId string
+
+	Category        int32  `clixml:"ErrorCategory_Category"`
+	Activity        string `clixml:"ErrorCategory_Activity"`
+	Reason          string `clixml:"ErrorCategory_Reason"`
+	TargetName      string `clixml:"ErrorCategory_TargetName"`
+	TargetType      string `clixml:"ErrorCategory_TargetType"`
+	CategoryMessage string `clixml:"ErrorCategory_Message"`
+	DetailsMessage  string `clixml:"ErrorDetails_Message"`
+
+	// The invocation info is only serialized with the extended info of the error record.
+	ScriptName      string `clixml:"InvocationInfo_ScriptName"`
+	Line            int    `clixml:"InvocationInfo_ScriptLineNumber"`
+	Column          int    `clixml:"InvocationInfo_OffsetInLine"`
+	Statement       string `clixml:"InvocationInfo_Line"`
+	PositionMessage string `clixml:"InvocationInfo_PositionMessage"`
+}
+
+// message returns the message of the error record.
+// The error details replace the message of the exception, as in the PowerShell console.
+func (r *psrpErrorRecord) message() string {
+	if r.DetailsMessage != "" {
+		return r.DetailsMessage
+	}
+	return r.Exception.Message
+}
+
+// categoryName returns the name of the error category.
+func (r *psrpErrorRecord) categoryName() string {
+	if r.Category >= 0 && int(r.Category) < len(psrpErrorCategories) {
+		return psrpErrorCategories[r.Category]
+	}
+	return psrpErrorCategories[0]
+}
+
+// errorRecord returns the error record as a parsing.ErrorRecord.
+func (r *psrpErrorRecord) errorRecord() parsing.ErrorRecord {
+	return parsing.ErrorRecord{
+		FullyQualifiedErrorId: r.FullyQualifiedErrorId,
+		CategoryInfo: parsing.CategoryInfo{
+			Category:   r.categoryName(),
+			Activity:   r.Activity,
+			Reason:     r.Reason,
+			TargetName: r.TargetName,
+			TargetType: r.TargetType,
+		},
+		Exception: parsing.ExceptionInfo{
+			Type:    r.Reason,
+			Message: r.message(),
+		},
+		ScriptPosition: parsing.ScriptPosition{
+			ScriptName: r.ScriptName,
+			Line:       r.Line,
+			Column:     r.Column,
+			Text:       strings.TrimSpace(r.Statement),
+		},
+	}
+}
+
+// consoleText returns the error record as it is rendered by the PowerShell console.
+// See connection.CliXmlErrorWriter.WriteErrorRecord for an example.
+func (r *psrpErrorRecord) consoleText() string {
+	message := r.message()
+	if r.Activity != "" {
+		message = r.Activity + " : " + message
+	}
+
+	lines := []string{message}
+	if r.PositionMessage != "" {
+		lines = append(lines, r.PositionMessage)
+	}
+
+	category := r.CategoryMessage
+	if category == "" {
+		category = fmt.Sprintf("%s: (%s:%s) [%s], %s", r.categoryName(), r.TargetName, r.TargetType, r.Activity, r.Reason)
+	}
+
+	lines = append(lines,
+		"    + CategoryInfo          : "+category,
+		"    + FullyQualifiedErrorId : "+r.FullyQualifiedErrorId,
+	)
+	return strings.Join(lines, "\r\n")
+}
@@ -0,0 +1,304 @@
+package winrm
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/d-strobel/gowindows/parsing"
+	"github.com/gofrs/uuid"
+)
+
+// Destinations of a PSRP message.
+const (
+	psrpDestinationClient uint32 = 0x00000001
+	psrpDestinationServer uint32 = 0x00000002
+)
+
+// Types of the PSRP messages that are sent or handled by the client.
+// https://learn.microsoft.com/en-us/openspecs/windows_protocols/ms-psrp/497ac440-89fb-4cb3-9cc1-3434c1aa74c3
+const (
+	psrpTypeSessionCapability   uint32 = 0x00010002
+	psrpTypeInitRunspacePool    uint32 = 0x00010004
+	psrpTypeRunspacePoolState   uint32 = 0x00021005
+	psrpTypeCreatePipeline      uint32 = 0x00021006
+	psrpTypeApplicationPrivData uint32 = 0x00021009
+	psrpTypePipelineInput       uint32 = 0x00041002
+	psrpTypeEndOfPipelineInput  uint32 = 0x00041003
+	psrpTypePipelineOutput      uint32 = 0x00041004
+	psrpTypeErrorRecord         uint32 = 0x00041005
+	psrpTypePipelineState       uint32 = 0x00041006
+)
+
+// States of a runspace pool.
+const (
+	psrpRunspacePoolOpened int32 = 2
+	psrpRunspacePoolBroken int32 = 5
+)
+
+// States of a pipeline.
+const (
+	psrpPipelineStopped   int32 = 3
+	psrpPipelineCompleted int32 = 4
+	psrpPipelineFailed    int32 = 5
+)
+
+// Flags of a PSRP fragment.
+const (
+	psrpFragmentStart byte = 0x1
+	psrpFragmentEnd   byte = 0x2
+)
+
+const (
+	// psrpFragmentHeaderSize is the size of the object id, the fragment id, the flags and the blob length of a fragment.
+	psrpFragmentHeaderSize int = 21
+
+	// psrpMessageHeaderSize is the size of the destination, the message type and the two GUIDs of a message.
+	psrpMessageHeaderSize int = 40
+
+	// psrpMaxBlobSize is the maximum size of a fragment blob.
+	// A base64 encoded fragment of this size fits into the default maximum envelope size of 150 KiB.
+	psrpMaxBlobSize int = 100 * 1024
+)
+
+// psrpBOM is the UTF-8 byte order mark in front of the CLIXML data of a message.
+var psrpBOM = []byte{0xEF, 0xBB, 0xBF}
+
+// psrpMessage represents a message of the PowerShell Remoting Protocol.
+type psrpMessage struct {
+	destination uint32
+	messageType uint32
+	rpid        string
+	pid         string
+
+	// data contains the CLIXML of the message without the byte order mark.
+	data []byte
+}
+
+// encode returns the binary representation of the message.
+func (m psrpMessage) encode() ([]byte, error) {
+	rpid, err := guidBytes(m.rpid)
+	if err != nil {
+		return nil, err
+	}
+	pid, err := guidBytes(m.pid)
+	if err != nil {
+		return nil, err
+	}
+
+	b := make([]byte, 0, psrpMessageHeaderSize+len(psrpBOM)+len(m.data))
+	b = binary.LittleEndian.AppendUint32(b, m.destination)
+	b = binary.LittleEndian.AppendUint32(b, m.messageType)
+	b = append(b, rpid[:]...)
+	b = append(b, pid[:]...)
+	b = append(b, psrpBOM...)
+	b = append(b, m.data...)
+
+	return b, nil
+}
+
+// decodePsrpMessage decodes the binary representation of a message.
+func decodePsrpMessage(b []byte) (psrpMessage, error) {
+	if len(b) < psrpMessageHeaderSize {
+		return psrpMessage{}, fmt.Errorf("message of %d bytes is shorter than the message header", len(b))
+	}
+
+	var rpid, pid [16]byte
+	copy(rpid[:], b[8:24])
+	copy(pid[:], b[24:40])
+
+	return psrpMessage{
+		destination: binary.LittleEndian.Uint32(b[0:4]),
+		messageType: binary.LittleEndian.Uint32(b[4:8]),
+		rpid:        guidString(rpid),
+		pid:         guidString(pid),
+		data:        bytes.TrimPrefix(b[psrpMessageHeaderSize:], psrpBOM),
+	}, nil
+}
+
+// fragmentPsrpMessage splits an encoded message into fragments with blobs of at most maxBlobSize bytes.
+func fragmentPsrpMessage(objectId uint64, message []byte, maxBlobSize int) [][]byte {
+	var fragments [][]byte
+
+	for fragmentId := uint64(0); ; fragmentId++ {
+		blob := message[:min(len(message), maxBlobSize)]
+		message = message[len(blob):]
+
+		var flags byte
+		if fragmentId == 0 {
+			flags |= psrpFragmentStart
+		}
+		if len(message) == 0 {
+			flags |= psrpFragmentEnd
+		}
+
+		fragment := make([]byte, 0, psrpFragmentHeaderSize+len(blob))
+		fragment = binary.BigEndian.AppendUint64(fragment, objectId)
+		fragment = binary.BigEndian.AppendUint64(fragment, fragmentId)
+		fragment = append(fragment, flags)
+		fragment = binary.BigEndian.AppendUint32(fragment, uint32(len(blob)))
+		fragment = append(fragment, blob...)
+		fragments = append(fragments, fragment)
+
+		if len(message) == 0 {
+			return fragments
+		}
+	}
+}
+
+// psrpDefragmenter reassembles the messages from the fragments of a stream.
+// The fragments of different messages may be interleaved.
+type psrpDefragmenter struct {
+	buffers map[uint64][]byte
+}
+
+// add adds the fragments of a stream chunk and returns the messages that are complete.
+func (d *psrpDefragmenter) add(data []byte) ([]psrpMessage, error) {
+	if d.buffers == nil {
+		d.buffers = make(map[uint64][]byte)
+	}
+
+	var messages []psrpMessage
+
+	for len(data) > 0 {
+		if len(data) < psrpFragmentHeaderSize {
+			return nil, errors.New("fragment is shorter than the fragment header")
+		}
+
+		objectId := binary.BigEndian.Uint64(data[0:8])
+		flags := data[16]
+		length := int(binary.BigEndian.Uint32(data[17:21]))
+		if len(data) < psrpFragmentHeaderSize+length {
+			return nil, fmt.Errorf("fragment of object %d is truncated", objectId)
+		}
+		blob := data[psrpFragmentHeaderSize : psrpFragmentHeaderSize+length]
+		data = data[psrpFragmentHeaderSize+length:]
+
+		if flags&psrpFragmentStart != 0 {
+			d.buffers[objectId] = nil
+		} else if _, ok := d.buffers[objectId]; !ok {
+			return nil, fmt.Errorf("fragment of object %d without a start fragment", objectId)
+		}
+		d.buffers[objectId] = append(d.buffers[objectId], blob...)
+
+		if flags&psrpFragmentEnd == 0 {
+			continue
+		}
+
+		message, err := decodePsrpMessage(d.buffers[objectId])
+		delete(d.buffers, objectId)
+		if err != nil {
+			return nil, err
+		}
+		messages = append(messages, message)
+	}
+
+	return messages, nil
+}
+
+// newGuid returns a new random GUID in its string representation, e.g. "0B6D1A3E-5F6B-4C4E-9E4A-6C2E1F8B7D3A".
+func newGuid() string {
+	return strings.ToUpper(uuid.Must(uuid.NewV4()).String())
+}
+
+// guidBytes returns the binary representation of a GUID in the byte order of .NET,
+// where the first three groups are little-endian.
+// An empty GUID is the nil GUID.
+func guidBytes(guid string) ([16]byte, error) {
+	var b [16]byte
+	if guid == "" {
+		return b, nil
+	}
+
+	h, err := hex.DecodeString(strings.ReplaceAll(guid, "-", ""))
+	if err != nil || len(h) != 16 {
+		return b, fmt.Errorf("invalid GUID %q", guid)
+	}
+
+	b[0], b[1], b[2], b[3] = h[3], h[2], h[1], h[0]
+	b[4], b[5] = h[5], h[4]
+	b[6], b[7] = h[7], h[6]
+	copy(b[8:], h[8:])
+
+	return b, nil
+}
+
+// guidString returns the string representation of a GUID in the byte order of .NET.
+func guidString(b [16]byte) string {
+	h := [16]byte{b[3], b[2], b[1], b[0], b[5], b[4], b[7], b[6]}
+	copy(h[8:], b[8:])
+
+	s := strings.ToUpper(hex.EncodeToString(h[:]))
+	return s[0:8] + "-" + s[8:12] + "-" + s[12:16] + "-" + s[16:20] + "-" + s[20:32]
+}
+
+// psrpHostInfo is the host information of a runspace pool or a pipeline without a host.
+const psrpHostInfo string = `<Obj N="HostInfo" RefId="%d"><MS>` +
+	`<B N="_isHostNull">true</B><B N="_isHostUINull">true</B><B N="_isHostRawUINull">true</B><B N="_useRunspaceHost">true</B>` +
+	`</MS></Obj>`
+
+// psrpApartmentState is the unknown apartment state of a runspace pool or a pipeline.
+const psrpApartmentState string = `<Obj N="ApartmentState" RefId="%d"><TN RefId="%d">` +
+	`<T>System.Threading.ApartmentState</T><T>System.Enum</T><T>System.ValueType</T><T>System.Object</T>` +
+	`</TN><ToString>Unknown</ToString><I32>2</I32></Obj>`
+
+// sessionCapabilityData returns the CLIXML of a SESSION_CAPABILITY message.
+func sessionCapabilityData() []byte {
+	return []byte(`<Obj RefId="0"><MS>` +
+		`<Version N="protocolversion">2.3</Version>` +
+		`<Version N="PSVersion">2.0</Version>` +
+		`<Version N="SerializationVersion">1.1.0.1</Version>` +
+		`</MS></Obj>`)
+}
+
+// initRunspacePoolData returns the CLIXML of an INIT_RUNSPACEPOOL message.
+// The runspace pool has a single runspace, so the pipelines run one after another.
+func initRunspacePoolData() []byte {
+	return []byte(`<Obj RefId="0"><MS>` +
+		`<I32 N="MinRunspaces">1</I32><I32 N="MaxRunspaces">1</I32>` +
+		`<Obj N="PSThreadOptions" RefId="1"><TN RefId="0">` +
+		`<T>System.Management.Automation.Runspaces.PSThreadOptions</T><T>System.Enum</T><T>System.ValueType</T><T>System.Object</T>` +
+		`</TN><ToString>Default</ToString><I32>0</I32></Obj>` +
+		fmt.Sprintf(psrpApartmentState, 2, 1) +
+		fmt.Sprintf(psrpHostInfo, 3) +
+		`<Nil N="ApplicationArguments"/>` +
+		`</MS></Obj>`)
+}
+
+// createPipelineData returns the CLIXML of a CREATE_PIPELINE message that runs the script.
+// If noInput is false, the pipeline waits for the input until the END_OF_PIPELINE_INPUT message.
+func createPipelineData(script string, noInput bool) []byte {
+	return []byte(`<Obj RefId="0"><MS>` +
+		fmt.Sprintf(`<B N="NoInput">%t</B>`, noInput) +
+		fmt.Sprintf(psrpApartmentState, 1, 0) +
+		`<Obj N="RemoteStreamOptions" RefId="2"><TN RefId="1">` +
+		`<T>System.Management.Automation.RemoteStreamOptions</T><T>System.Enum</T><T>System.ValueType</T><T>System.Object</T>` +
+		`</TN><ToString>0</ToString><I32>0</I32></Obj>` +
+		`<B N="AddToHistory">false</B>` +
+		fmt.Sprintf(psrpHostInfo, 3) +
+		`<Obj N="PowerShell" RefId="4"><MS>` +
+		`<B N="IsNested">false</B><Nil N="ExtraCmds"/>` +
+		`<Obj N="Cmds" RefId="5"><TN RefId="2">` +
+		"<T>System.Collections.Generic.List`1[[System.Management.Automation.PSObject, System.Management.Automation, Version=1.0.0.0, Culture=neutral, PublicKeyToken=31bf3856ad364e35]]</T><T>System.Object</T>" +
+		`</TN><LST><Obj RefId="6"><MS>` +
+		`<S N="Cmd">` + parsing.EncodeCliXmlString(script) + `</S><B N="IsScript">true</B><Nil N="UseLocalScope"/>` +
+		`<Obj N="MergeMyResult" RefId="7"><TN RefId="3">` +
+		`<T>System.Management.Automation.Runspaces.PipelineResultTypes</T><T>System.Enum</T><T>System.ValueType</T><T>System.Object</T>` +
+		`</TN><ToString>None</ToString><I32>0</I32></Obj>` +
+		`<Ref N="MergeToResult" RefId="7"/><Ref N="MergePreviousResults" RefId="7"/><Ref N="MergeError" RefId="7"/>` +
+		`<Ref N="MergeWarning" RefId="7"/><Ref N="MergeVerbose" RefId="7"/><Ref N="MergeDebug" RefId="7"/><Ref N="MergeInformation" RefId="7"/>` +
+		`<Obj N="Args" RefId="8"><TNRef RefId="2"/><LST/></Obj>` +
+		`</MS></Obj></LST></Obj>` +
+		`<Nil N="History"/><B N="RedirectShellErrorOutputToPipeline">false</B>` +
+		`</MS></Obj>` +
+		`<B N="IsNested">false</B>` +
+		`</MS></Obj>`)
+}
+
+// pipelineInputData returns the CLIXML of a PIPELINE_INPUT message with a string.
+func pipelineInputData(s string) []byte {
+	return []byte(`<S>` + parsing.EncodeCliXmlString(s) + `</S>`)
+}
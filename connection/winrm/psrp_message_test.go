@@ -0,0 +1,114 @@
+package winrm
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+// Unit test suite for the PSRP messages.
+type PSRPMessageUnitTestSuite struct {
+	suite.Suite
+}
+
+func TestPSRPMessageUnitTestSuite(t *testing.T) {
+	suite.Run(t, &PSRPMessageUnitTestSuite{})
+}
+
+func (suite *PSRPMessageUnitTestSuite) TestFragments() {
+	suite.Run("should reassemble interleaved fragments", func() {
+		first := psrpMessage{
+			destination: psrpDestinationServer,
+			messageType: psrpTypePipelineInput,
+			rpid:        "0B6D1A3E-5F6B-4C4E-9E4A-6C2E1F8B7D3A",
+			pid:         "1A6DEE6B-EC68-4DD6-87E9-030C0048ECC4",
+			data:        []byte(`<S>` + strings.Repeat("first", 20) + `</S>`),
+		}
+		second := psrpMessage{
+			destination: psrpDestinationClient,
+			messageType: psrpTypePipelineState,
+			rpid:        "0B6D1A3E-5F6B-4C4E-9E4A-6C2E1F8B7D3A",
+			data:        []byte(testRecordedPipelineCompleted),
+		}
+
+		b1, err := first.encode()
+		suite.Require().NoError(err)
+		b2, err := second.encode()
+		suite.Require().NoError(err)
+
+		fragments1 := fragmentPsrpMessage(1, b1, 32)
+		fragments2 := fragmentPsrpMessage(2, b2, 32)
+		suite.Greater(len(fragments1), 2)
+
+		// The first fragment of the first message arrives between the fragments of the second message.
+		var defragmenter psrpDefragmenter
+		var got []psrpMessage
+		for i, fragment := range fragments2 {
+			messages, err := defragmenter.add(fragment)
+			suite.Require().NoError(err)
+			got = append(got, messages...)
+			if i == 0 {
+				messages, err = defragmenter.add(fragments1[0])
+				suite.Require().NoError(err)
+				got = append(got, messages...)
+			}
+		}
+		messages, err := defragmenter.add(bytesJoin(fragments1[1:]))
+		suite.Require().NoError(err)
+		got = append(got, messages...)
+
+		// The nil GUID of the pipeline id is decoded as a GUID string.
+		second.pid = "00000000-0000-0000-0000-000000000000"
+		suite.Equal([]psrpMessage{second, first}, got)
+		suite.Empty(defragmenter.buffers)
+	})
+
+	suite.Run("should return an error for a fragment without a start fragment", func() {
+		b, err := psrpMessage{data: []byte("<S>test</S>")}.encode()
+		suite.Require().NoError(err)
+
+		var defragmenter psrpDefragmenter
+		_, err = defragmenter.add(fragmentPsrpMessage(7, b, 16)[1])
+		suite.EqualError(err, "fragment of object 7 without a start fragment")
+	})
+
+	suite.Run("should return an error for a truncated fragment", func() {
+		b, err := psrpMessage{data: []byte("<S>test</S>")}.encode()
+		suite.Require().NoError(err)
+
+		var defragmenter psrpDefragmenter
+		_, err = defragmenter.add(fragmentPsrpMessage(7, b, 64)[0][:30])
+		suite.EqualError(err, "fragment of object 7 is truncated")
+	})
+}
+
+func (suite *PSRPMessageUnitTestSuite) TestGuidBytes() {
+	suite.Run("should encode a GUID in the byte order of .NET", func() {
+		b, err := guidBytes("00112233-4455-6677-8899-AABBCCDDEEFF")
+		suite.NoError(err)
+		suite.Equal([16]byte{0x33, 0x22, 0x11, 0x00, 0x55, 0x44, 0x77, 0x66, 0x88, 0x99, 0xAA, 0xBB, 0xCC, 0xDD, 0xEE, 0xFF}, b)
+		suite.Equal("00112233-4455-6677-8899-AABBCCDDEEFF", guidString(b))
+	})
+
+	suite.Run("should round trip a new GUID", func() {
+		guid := newGuid()
+		b, err := guidBytes(guid)
+		suite.NoError(err)
+		suite.Equal(guid, guidString(b))
+	})
+
+	suite.Run("should return an error for an invalid GUID", func() {
+		_, err := guidBytes("not-a-guid")
+		suite.EqualError(err, `invalid GUID "not-a-guid"`)
+	})
+}
+
+// bytesJoin concatenates the fragments.
+func bytesJoin(fragments [][]byte) []byte {
+	var b []byte
+	for _, fragment := range fragments {
+		b = append(b, fragment...)
+	}
+	return b
+}
@@ -0,0 +1,461 @@
+package winrm
+
+import (
+	"encoding/base64"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/d-strobel/gowindows/parsing"
+)
+
+// testPSRPFragmentSize is the blob size of the fragments the PSRP test server sends.
+// It is small, so the client has to reassemble the messages from multiple fragments.
+const testPSRPFragmentSize int = 64
+
+// Recorded messages of the runspace pool as a Windows PowerShell 5.1 host sends them.
+const (
+	testRecordedSessionCapability string = `<Obj RefId="0"><MS><Version N="protocolversion">2.3</Version>` +
+		`<Version N="PSVersion">2.0</Version><Version N="SerializationVersion">1.1.0.1</Version></MS></Obj>`
+
+	testRecordedApplicationPrivateData string = `<Obj RefId="0"><MS><Obj N="ApplicationPrivateData" RefId="1"><TN RefId="0">` +
+		`<T>System.Management.Automation.PSPrimitiveDictionary</T><T>System.Collections.Hashtable</T><T>System.Object</T></TN>` +
+		`<DCT><En><S N="Key">PSVersionTable</S><Obj N="Value" RefId="2"><TNRef RefId="0" /><DCT>` +
+		`<En><S N="Key">PSVersion</S><Version N="Value">5.1.17763.592</Version></En>` +
+		`<En><S N="Key">PSEdition</S><S N="Value">Desktop</S></En>` +
+		`</DCT></Obj></En></DCT></Obj></MS></Obj>`
+
+	testRecordedRunspacePoolOpened string = `<Obj RefId="0"><MS><I32 N="RunspaceState">2</I32></MS></Obj>`
+)
+
+// Recorded messages of pipelines as a Windows PowerShell 5.1 host sends them.
+const (
+	testRecordedLocalUser string = `<Obj RefId="0"><TN RefId="0"><T>Microsoft.PowerShell.Commands.LocalUser</T>` +
+		`<T>Microsoft.PowerShell.Commands.LocalPrincipal</T><T>System.Object</T></TN><ToString>Administrator</ToString>` +
+		`<Props><B N="Enabled">true</B><S N="Description">Built-in account for administering the computer/domain</S>` +
+		`<S N="Name">Administrator</S><Obj N="PrincipalSource" RefId="1"><TN RefId="1">` +
+		"<T>System.Nullable`1[[Microsoft.PowerShell.Commands.PrincipalSource, Microsoft.PowerShell.Commands.LocalAccounts, Version=1.0.0.0, Culture=neutral, PublicKeyToken=31bf3856ad364e35]]</T>" +
+		`<T>System.Enum</T><T>System.ValueType</T><T>System.Object</T></TN><ToString>Local</ToString><I32>1</I32></Obj>` +
+		`</Props></Obj>`
+
+	testRecordedUserNotFound string = `<Obj RefId="0"><TN RefId="0"><T>System.Management.Automation.ErrorRecord</T><T>System.Object</T></TN>` +
+		`<ToString>User test was not found.</ToString><MS>` +
+		`<Obj N="Exception" RefId="1"><TN RefId="1"><T>Microsoft.PowerShell.Commands.UserNotFoundException</T>` +
+		`<T>Microsoft.PowerShell.Commands.LocalAccountsException</T><T>System.Exception</T><T>System.Object</T></TN>` +
+		`<ToString>Microsoft.PowerShell.Commands.UserNotFoundException: User test was not found.</ToString>` +
+		`<Props><S N="Message">User test was not found.</S><Obj N="Data" RefId="2"><TN RefId="2"><T>System.Collections.ListDictionaryInternal</T>` +
+		`<T>System.Object</T></TN><DCT /></Obj><Nil N="InnerException" /><S N="Source">Microsoft.PowerShell.Commands.LocalAccounts</S>` +
+		`<I32 N="HResult">-2146233088</I32></Props></Obj>` +
+		`<S N="TargetObject">test</S>` +
+		`<S N="FullyQualifiedErrorId">UserNotFound,Microsoft.PowerShell.Commands.GetLocalUserCommand</S>` +
+		`<Obj N="InvocationInfo" RefId="3"><TN RefId="3"><T>System.Management.Automation.InvocationInfo</T><T>System.Object</T></TN>` +
+		`<ToString>System.Management.Automation.InvocationInfo</ToString><Props><S N="Line">Get-LocalUser -Name test</S></Props></Obj>` +
+		`<I32 N="ErrorCategory_Category">13</I32>` +
+		`<S N="ErrorCategory_Activity">Get-LocalUser</S>` +
+		`<S N="ErrorCategory_Reason">UserNotFoundException</S>` +
+		`<S N="ErrorCategory_TargetName">test</S>` +
+		`<S N="ErrorCategory_TargetType">String</S>` +
+		`<S N="ErrorCategory_Message">ObjectNotFound: (test:String) [Get-LocalUser], UserNotFoundException</S>` +
+		`<B N="SerializeExtendedInfo">true</B>` +
+		`<S N="InvocationInfo_InvocationName">Get-LocalUser</S>` +
+		`<I32 N="InvocationInfo_ScriptLineNumber">1</I32>` +
+		`<I32 N="InvocationInfo_OffsetInLine">1</I32>` +
+		`<Nil N="InvocationInfo_ScriptName" />` +
+		`<S N="InvocationInfo_Line">Get-LocalUser -Name test</S>` +
+		`<S N="InvocationInfo_PositionMessage">At line:1 char:1_x000D__x000A_+ Get-LocalUser -Name test_x000D__x000A_+ ~~~~~~~~~~~~~~~~~~~~~~~~</S>` +
+		`</MS></Obj>`
+
+	testRecordedPipelineCompleted string = `<Obj RefId="0"><MS><I32 N="PipelineState">4</I32></MS></Obj>`
+
+	testRecordedPipelineStopped string = `<Obj RefId="0"><MS><I32 N="PipelineState">3</I32></MS></Obj>`
+
+	testRecordedPipelineFailed string = `<Obj RefId="0"><MS><I32 N="PipelineState">5</I32>` +
+		`<Obj N="ExceptionAsErrorRecord" RefId="1"><TN RefId="0"><T>System.Management.Automation.ErrorRecord</T><T>System.Object</T></TN>` +
+		`<ToString>boom</ToString><MS>` +
+		`<Obj N="Exception" RefId="2"><TN RefId="1"><T>System.Management.Automation.RuntimeException</T><T>System.SystemException</T>` +
+		`<T>System.Exception</T><T>System.Object</T></TN><ToString>System.Management.Automation.RuntimeException: boom</ToString>` +
+		`<Props><S N="Message">boom</S><B N="WasThrownFromThrowStatement">true</B></Props></Obj>` +
+		`<S N="TargetObject">boom</S>` +
+		`<S N="FullyQualifiedErrorId">boom</S>` +
+		`<Nil N="InvocationInfo" />` +
+		`<I32 N="ErrorCategory_Category">14</I32>` +
+		`<S N="ErrorCategory_Activity"></S>` +
+		`<S N="ErrorCategory_Reason">RuntimeException</S>` +
+		`<S N="ErrorCategory_TargetName">boom</S>` +
+		`<S N="ErrorCategory_TargetType">String</S>` +
+		`<S N="ErrorCategory_Message">OperationStopped: (boom:String) [], RuntimeException</S>` +
+		`<B N="SerializeExtendedInfo">false</B>` +
+		`</MS></Obj></MS></Obj>`
+)
+
+// testPSRPMessage is a message the PSRP test server sends to the client.
+type testPSRPMessage struct {
+	messageType uint32
+	data        string
+}
+
+// testPipelineHandler runs the script of a pipeline on the PSRP test server
+// and returns the recorded messages the server sends for the pipeline.
+// The input contains the pipeline input of the client and stop is closed when the client stops the pipeline.
+type testPipelineHandler func(script string, input []string, stop <-chan struct{}) []testPSRPMessage
+
+// testPSRPServer is an in-process WinRM server that stands in for the PowerShell Remoting Protocol endpoint.
+// It opens a runspace pool and replays recorded messages for the pipelines of the client.
+type testPSRPServer struct {
+	server  *httptest.Server
+	handler testPipelineHandler
+
+	// poolState is the recorded RUNSPACEPOOL_STATE message of the opened runspace pool.
+	poolState string
+
+	// exitCode is the $LASTEXITCODE the server emits at the end of a completed pipeline.
+	exitCode int
+
+	// closed is closed when the client deletes the runspace pool.
+	closed chan struct{}
+
+	mu        sync.Mutex
+	rpid      string
+	objectId  uint64
+	pipelines map[string]*testPipeline
+	signals   int
+	deleted   bool
+}
+
+// testPipeline is a pipeline of the PSRP test server.
+type testPipeline struct {
+	defragmenter psrpDefragmenter
+	script       string
+	marker       string
+	input        []string
+	fragments    int
+	ready        chan struct{}
+	stop         chan struct{}
+}
+
+// testPSRPEnvelope holds the parts of a WS-Management request the PSRP test server needs.
+type testPSRPEnvelope struct {
+	Action      string `xml:"Header>Action"`
+	ResourceURI string `xml:"Header>ResourceURI"`
+	Shell       struct {
+		ShellId     string `xml:"ShellId,attr"`
+		CreationXml string `xml:"creationXml"`
+	} `xml:"Body>Shell"`
+	CommandLine struct {
+		CommandId string `xml:"CommandId,attr"`
+		Arguments string `xml:"Arguments"`
+	} `xml:"Body>CommandLine"`
+	Send struct {
+		CommandId string `xml:"CommandId,attr"`
+		Content   string `xml:",chardata"`
+	} `xml:"Body>Send>Stream"`
+	Receive struct {
+		CommandId string `xml:"CommandId,attr"`
+	} `xml:"Body>Receive>DesiredStream"`
+	Signal struct {
+		CommandId string `xml:"CommandId,attr"`
+		Code      string `xml:"Code"`
+	} `xml:"Body>Signal"`
+}
+
+// newTestPSRPServer starts a new PSRP test server on a random local port.
+// The server is closed when the test finishes.
+func newTestPSRPServer(t *testing.T, handler testPipelineHandler) *testPSRPServer {
+	t.Helper()
+	return newTestPSRPServerOn(t, handler, "127.0.0.1:0")
+}
+
+// newTestPSRPServerOn starts a new PSRP test server on the given address.
+// The test is skipped if the address is not available, e.g. an IPv6 address on a host without IPv6.
+func newTestPSRPServerOn(t *testing.T, handler testPipelineHandler, addr string) *testPSRPServer {
+	t.Helper()
+
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		t.Skipf("failed to listen on %s: %s", addr, err)
+	}
+
+	s := &testPSRPServer{
+		handler:   handler,
+		poolState: testRecordedRunspacePoolOpened,
+		pipelines: make(map[string]*testPipeline),
+		closed:    make(chan struct{}),
+	}
+	s.server = httptest.NewUnstartedServer(http.HandlerFunc(s.serveHTTP))
+	s.server.Listener.Close()
+	s.server.Listener = l
+	s.server.Start()
+	t.Cleanup(s.server.Close)
+
+	return s
+}
+
+// config returns a connection configuration for the PSRP test server.
+func (s *testPSRPServer) config() *Config {
+	addr := s.server.Listener.Addr().(*net.TCPAddr)
+	return &Config{
+		Host:     addr.IP.String(),
+		Port:     addr.Port,
+		Username: testServerUsername,
+		Password: testServerPassword,
+	}
+}
+
+// pipeline returns the pipeline with the given id.
+func (s *testPSRPServer) pipeline(pid string) *testPipeline {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.pipelines[pid]
+}
+
+// serveHTTP answers a WS-Management request.
+func (s *testPSRPServer) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var envelope testPSRPEnvelope
+	if err := xml.Unmarshal(body, &envelope); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if envelope.ResourceURI != psrpResourceURI {
+		http.Error(w, "unknown resource "+envelope.ResourceURI, http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/soap+xml;charset=UTF-8")
+
+	switch envelope.Action {
+	case actionCreate:
+		if err := s.create(envelope.Shell.ShellId, envelope.Shell.CreationXml); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		fmt.Fprint(w, testResponse("http://schemas.xmlsoap.org/ws/2004/09/transfer/CreateResponse",
+			`<rsp:Shell><rsp:ShellId>`+envelope.Shell.ShellId+`</rsp:ShellId></rsp:Shell>`))
+
+	case actionCommand:
+		p := &testPipeline{ready: make(chan struct{}), stop: make(chan struct{})}
+		s.mu.Lock()
+		s.pipelines[envelope.CommandLine.CommandId] = p
+		s.mu.Unlock()
+
+		if err := s.addFragments(p, envelope.CommandLine.Arguments); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		fmt.Fprint(w, testResponse(actionCommand+"Response",
+			`<rsp:CommandResponse><rsp:CommandId>`+envelope.CommandLine.CommandId+`</rsp:CommandId></rsp:CommandResponse>`))
+
+	case actionSend:
+		p := s.pipeline(envelope.Send.CommandId)
+		if p == nil {
+			http.Error(w, "unknown pipeline "+envelope.Send.CommandId, http.StatusBadRequest)
+			return
+		}
+		if err := s.addFragments(p, envelope.Send.Content); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		fmt.Fprint(w, testResponse(actionSend+"Response", `<rsp:SendResponse/>`))
+
+	case actionReceive:
+		// The runspace pool is opened right away.
+		if envelope.Receive.CommandId == "" {
+			s.receive(w, "", []testPSRPMessage{
+				{messageType: psrpTypeSessionCapability, data: testRecordedSessionCapability},
+				{messageType: psrpTypeApplicationPrivData, data: testRecordedApplicationPrivateData},
+				{messageType: psrpTypeRunspacePoolState, data: s.poolState},
+			})
+			return
+		}
+
+		p := s.pipeline(envelope.Receive.CommandId)
+		if p == nil {
+			http.Error(w, "unknown pipeline "+envelope.Receive.CommandId, http.StatusBadRequest)
+			return
+		}
+
+		// The pipeline runs as soon as it is created and the input is complete.
+		select {
+		case <-p.ready:
+		case <-r.Context().Done():
+			return
+		}
+
+		s.receive(w, envelope.Receive.CommandId, s.exitCodeMessages(p, s.handler(p.script, p.input, p.stop)))
+
+	case actionSignal:
+		if envelope.Signal.Code != psrpSignalStop {
+			http.Error(w, "unknown signal "+envelope.Signal.Code, http.StatusBadRequest)
+			return
+		}
+		if p := s.pipeline(envelope.Signal.CommandId); p != nil {
+			s.mu.Lock()
+			s.signals++
+			close(p.stop)
+			s.mu.Unlock()
+		}
+		fmt.Fprint(w, testResponse(actionSignal+"Response", `<rsp:SignalResponse/>`))
+
+	case actionDelete:
+		s.mu.Lock()
+		if !s.deleted {
+			close(s.closed)
+		}
+		s.deleted = true
+		s.mu.Unlock()
+		fmt.Fprint(w, testResponse(actionDelete+"Response", ""))
+
+	default:
+		http.Error(w, "unknown action "+envelope.Action, http.StatusBadRequest)
+	}
+}
+
+// create checks the creation XML of the runspace pool.
+func (s *testPSRPServer) create(rpid string, creationXml string) error {
+	data, err := base64.StdEncoding.DecodeString(strings.TrimSpace(creationXml))
+	if err != nil {
+		return err
+	}
+
+	var defragmenter psrpDefragmenter
+	messages, err := defragmenter.add(data)
+	if err != nil {
+		return err
+	}
+	if len(messages) != 2 || messages[0].messageType != psrpTypeSessionCapability || messages[1].messageType != psrpTypeInitRunspacePool {
+		return fmt.Errorf("unexpected creation messages %v", messages)
+	}
+	for _, m := range messages {
+		if m.destination != psrpDestinationServer || m.rpid != rpid {
+			return fmt.Errorf("unexpected header of message %x", m.messageType)
+		}
+	}
+
+	s.mu.Lock()
+	s.rpid = rpid
+	s.mu.Unlock()
+
+	return nil
+}
+
+// addFragments adds the fragments of a command or send request to a pipeline.
+func (s *testPSRPServer) addFragments(p *testPipeline, content string) error {
+	data, err := base64.StdEncoding.DecodeString(strings.TrimSpace(content))
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	p.fragments++
+	messages, err := p.defragmenter.add(data)
+	if err != nil {
+		return err
+	}
+
+	for _, m := range messages {
+		switch m.messageType {
+		case psrpTypeCreatePipeline:
+			var createPipeline struct {
+				NoInput    bool
+				PowerShell struct {
+					Cmds []struct {
+						Cmd      string
+						IsScript bool
+					}
+				}
+			}
+			if err := parsing.UnmarshalCliXml(cliXmlDocument(string(m.data)), &createPipeline); err != nil {
+				return err
+			}
+			if len(createPipeline.PowerShell.Cmds) != 1 || !createPipeline.PowerShell.Cmds[0].IsScript {
+				return fmt.Errorf("unexpected commands %v", createPipeline.PowerShell.Cmds)
+			}
+			// The client appends the emission of the exit code to every script.
+			p.script = createPipeline.PowerShell.Cmds[0].Cmd
+			if m := testExitCodeScriptRe.FindStringSubmatchIndex(p.script); m != nil {
+				p.marker = p.script[m[2]:m[3]]
+				p.script = p.script[:m[0]]
+			}
+			if createPipeline.NoInput {
+				close(p.ready)
+			}
+
+		case psrpTypePipelineInput:
+			var input string
+			if err := parsing.UnmarshalCliXml(cliXmlDocument(string(m.data)), &input); err != nil {
+				return err
+			}
+			p.input = append(p.input, input)
+
+		case psrpTypeEndOfPipelineInput:
+			close(p.ready)
+
+		default:
+			return fmt.Errorf("unexpected message %x", m.messageType)
+		}
+	}
+
+	return nil
+}
+
+// testExitCodeScriptRe matches the script of psrpExitCodeScript at the end of a script.
+var testExitCodeScriptRe = regexp.MustCompile(`\n'(gowindows-exit-code-[^']+)' \+ \[int\]\$global:LASTEXITCODE; \$global:LASTEXITCODE = 0$`)
+
+// exitCodeMessages inserts the emission of the exit code in front of the completed state of a pipeline.
+func (s *testPSRPServer) exitCodeMessages(p *testPipeline, messages []testPSRPMessage) []testPSRPMessage {
+	n := len(messages)
+	if p.marker == "" || n == 0 || messages[n-1] != testPipelineState(testRecordedPipelineCompleted) {
+		return messages
+	}
+
+	output := testOutput(`<S>` + p.marker + strconv.Itoa(s.exitCode) + `</S>`)
+	return append(messages[:n-1:n-1], output, messages[n-1])
+}
+
+// receive sends the messages in fragments of the test fragment size, one fragment per stream.
+func (s *testPSRPServer) receive(w http.ResponseWriter, pid string, messages []testPSRPMessage) {
+	var streams strings.Builder
+
+	s.mu.Lock()
+	for _, m := range messages {
+		b, err := psrpMessage{destination: psrpDestinationClient, messageType: m.messageType, rpid: s.rpid, pid: pid, data: []byte(m.data)}.encode()
+		if err != nil {
+			s.mu.Unlock()
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		s.objectId++
+		for _, fragment := range fragmentPsrpMessage(s.objectId, b, testPSRPFragmentSize) {
+			streams.WriteString(`<rsp:Stream Name="stdout" CommandId="` + pid + `">` + base64.StdEncoding.EncodeToString(fragment) + `</rsp:Stream>`)
+		}
+	}
+	s.mu.Unlock()
+
+	fmt.Fprint(w, testResponse(actionReceive+"Response", `<rsp:ReceiveResponse>`+streams.String()+`</rsp:ReceiveResponse>`))
+}
+
+// testPipelineState returns a recorded PIPELINE_STATE message.
+func testPipelineState(data string) testPSRPMessage {
+	return testPSRPMessage{messageType: psrpTypePipelineState, data: data}
+}
+
+// testOutput returns a PIPELINE_OUTPUT message with the CLIXML of an object.
+func testOutput(data string) testPSRPMessage {
+	return testPSRPMessage{messageType: psrpTypePipelineOutput, data: data}
+}
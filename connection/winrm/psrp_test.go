@@ -0,0 +1,334 @@
+package winrm
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/d-strobel/gowindows/connection"
+	"github.com/d-strobel/gowindows/parsing"
+	"github.com/stretchr/testify/suite"
+)
+
+// Unit test suite for the PSRP connection.
+type PSRPUnitTestSuite struct {
+	suite.Suite
+}
+
+func TestPSRPUnitTestSuite(t *testing.T) {
+	suite.Run(t, &PSRPUnitTestSuite{})
+}
+
+// completed returns a pipeline handler that sends the messages followed by the completed pipeline state.
+func completed(messages ...testPSRPMessage) testPipelineHandler {
+	return func(script string, input []string, stop <-chan struct{}) []testPSRPMessage {
+		return append(messages, testPipelineState(testRecordedPipelineCompleted))
+	}
+}
+
+func (suite *PSRPUnitTestSuite) TestNewPSRPConnection() {
+	suite.Run("should open and close the runspace pool", func() {
+		server := newTestPSRPServer(suite.T(), completed())
+
+		conn, err := NewPSRPConnection(server.config())
+		suite.Require().NoError(err)
+		suite.Equal(server.rpid, conn.shellId)
+
+		suite.NoError(conn.Close())
+		suite.True(server.deleted)
+
+		// A closed connection cannot run commands and is closed only once.
+		_, err = conn.Run(context.Background(), "whoami")
+		suite.EqualError(err, "winrm: the runspace pool is closed")
+		suite.NoError(conn.Close())
+	})
+
+	suite.Run("should open the runspace pool on an IPv6 host", func() {
+		server := newTestPSRPServerOn(suite.T(), completed(), "[::1]:0")
+
+		conn, err := NewPSRPConnection(server.config())
+		suite.Require().NoError(err)
+		suite.Contains(conn.url, "://[::1]:")
+		suite.NoError(conn.Close())
+	})
+
+	suite.Run("should return an error if the runspace pool is broken", func() {
+		server := newTestPSRPServer(suite.T(), completed())
+		server.poolState = `<Obj RefId="0"><MS><I32 N="RunspaceState">5</I32></MS></Obj>`
+
+		_, err := NewPSRPConnection(server.config())
+		suite.EqualError(err, "winrm: failed to open the runspace pool: the runspace pool is broken")
+	})
+}
+
+func (suite *PSRPUnitTestSuite) TestRun() {
+	suite.Run("should return the output objects as lines", func() {
+		server := newTestPSRPServer(suite.T(), completed(
+			testOutput(`<S>{"Name":"Administrator"}</S>`),
+			testOutput(testRecordedLocalUser),
+			testOutput(`<Nil />`),
+			testOutput(`<I32>42</I32>`),
+		))
+		conn, err := NewPSRPConnection(server.config())
+		suite.Require().NoError(err)
+
+		result, err := conn.Run(context.Background(), "Get-LocalUser")
+		suite.NoError(err)
+		suite.Equal(connection.CmdResult{StdOut: "{\"Name\":\"Administrator\"}\r\nAdministrator\r\n42\r\n"}, result)
+	})
+
+	suite.Run("should send the script in a single pipeline", func() {
+		var scripts []string
+		server := newTestPSRPServer(suite.T(), func(script string, input []string, stop <-chan struct{}) []testPSRPMessage {
+			scripts = append(scripts, script)
+			return []testPSRPMessage{testPipelineState(testRecordedPipelineCompleted)}
+		})
+		conn, err := NewPSRPConnection(server.config())
+		suite.Require().NoError(err)
+
+		// Large scripts are split into multiple fragments.
+		script := "$text = '" + strings.Repeat("_x0041_ <&>\r\n", psrpMaxBlobSize/8) + "'"
+
+		_, err = conn.RunWithPowershell(context.Background(), script)
+		suite.Require().NoError(err)
+		_, err = conn.RunWithPowershell(context.Background(), "Get-Date")
+		suite.Require().NoError(err)
+
+		suite.Equal([]string{script, "Get-Date"}, scripts)
+		suite.Len(server.pipelines, 2)
+		for _, p := range server.pipelines {
+			if strings.HasPrefix(p.script, "$text") {
+				suite.Greater(p.fragments, 1)
+			}
+		}
+	})
+
+	suite.Run("should return the error records as CLIXML error stream", func() {
+		server := newTestPSRPServer(suite.T(), completed(
+			testPSRPMessage{messageType: psrpTypeErrorRecord, data: testRecordedUserNotFound},
+		))
+		conn, err := NewPSRPConnection(server.config())
+		suite.Require().NoError(err)
+
+		result, err := conn.RunWithPowershell(context.Background(), "Get-LocalUser -Name test")
+		suite.NoError(err)
+		suite.Equal(0, result.ExitCode)
+
+		decoded, err := parsing.DecodeCliXmlErr(result.StdErr)
+		suite.NoError(err)
+		suite.Equal("Get-LocalUser : User test was not found.At line:1 char:1\nGet-LocalUser -Name test\n~~~~~~~~~~~~~~~~~~~~~~~~\n"+
+			"CategoryInfo          : ObjectNotFound: (test:String) [Get-LocalUser], UserNotFoundException\n"+
+			"FullyQualifiedErrorId : UserNotFound,Microsoft.PowerShell.Commands.GetLocalUserCommand", decoded)
+
+		records, err := parsing.DecodeCliXmlErrRecords(result.StdErr)
+		suite.NoError(err)
+		suite.Equal([]parsing.ErrorRecord{{
+			FullyQualifiedErrorId: "UserNotFound,Microsoft.PowerShell.Commands.GetLocalUserCommand",
+			CategoryInfo: parsing.CategoryInfo{
+				Category:   "ObjectNotFound",
+				Activity:   "Get-LocalUser",
+				Reason:     "UserNotFoundException",
+				TargetName: "test",
+				TargetType: "String",
+			},
+			Exception:      parsing.ExceptionInfo{Type: "UserNotFoundException", Message: "User test was not found."},
+			ScriptPosition: parsing.ScriptPosition{Line: 1, Column: 1, Text: "Get-LocalUser -Name test"},
+		}}, records)
+	})
+
+	suite.Run("should return exit code 1 and the terminating error of a failed pipeline", func() {
+		server := newTestPSRPServer(suite.T(), func(script string, input []string, stop <-chan struct{}) []testPSRPMessage {
+			return []testPSRPMessage{testPipelineState(testRecordedPipelineFailed)}
+		})
+		conn, err := NewPSRPConnection(server.config())
+		suite.Require().NoError(err)
+
+		result, err := conn.Run(context.Background(), "throw 'boom'")
+		suite.NoError(err)
+		suite.Equal(1, result.ExitCode)
+
+		records, err := parsing.DecodeCliXmlErrRecords(result.StdErr)
+		suite.NoError(err)
+		suite.Require().Len(records, 1)
+		suite.Equal("boom", records[0].Exception.Message)
+		suite.Equal("OperationStopped", records[0].CategoryInfo.Category)
+		suite.Equal("boom", records[0].FullyQualifiedErrorId)
+	})
+}
+
+func (suite *PSRPUnitTestSuite) TestExitCode() {
+	suite.Run("should return the exit code of the last native command", func() {
+		server := newTestPSRPServer(suite.T(), completed(testOutput(`<S>output</S>`)))
+		server.exitCode = 3
+		conn, err := NewPSRPConnection(server.config())
+		suite.Require().NoError(err)
+
+		result, err := conn.Run(context.Background(), "cmd.exe /c 'echo output & exit 3'")
+		suite.NoError(err)
+		suite.Equal(connection.CmdResult{StdOut: "output\r\n", ExitCode: 3}, result)
+
+		invokeResult, err := conn.Invoke(context.Background(), "cmd.exe /c 'echo output & exit 3'")
+		suite.NoError(err)
+		suite.Equal(&PipelineResult{Output: []string{`<S>output</S>`}, ExitCode: 3}, invokeResult)
+	})
+
+	suite.Run("should return exit code 1 for a failed pipeline", func() {
+		server := newTestPSRPServer(suite.T(), func(script string, input []string, stop <-chan struct{}) []testPSRPMessage {
+			return []testPSRPMessage{testPipelineState(testRecordedPipelineFailed)}
+		})
+		server.exitCode = 3
+		conn, err := NewPSRPConnection(server.config())
+		suite.Require().NoError(err)
+
+		result, err := conn.Run(context.Background(), "throw 'boom'")
+		suite.NoError(err)
+		suite.Equal(1, result.ExitCode)
+	})
+}
+
+func (suite *PSRPUnitTestSuite) TestStream() {
+	suite.Run("should send every line of stdin as pipeline input", func() {
+		server := newTestPSRPServer(suite.T(), func(script string, input []string, stop <-chan struct{}) []testPSRPMessage {
+			messages := make([]testPSRPMessage, 0, len(input)+1)
+			for _, line := range input {
+				messages = append(messages, testOutput(`<S>`+parsing.EncodeCliXmlString(strings.ToUpper(line))+`</S>`))
+			}
+			return append(messages, testPipelineState(testRecordedPipelineCompleted))
+		})
+		conn, err := NewPSRPConnection(server.config())
+		suite.Require().NoError(err)
+
+		var lines []string
+		stdout := connection.NewLineWriter(func(line string) { lines = append(lines, line) })
+
+		exitCode, err := conn.StreamWithPowershell(context.Background(), "$input | ForEach-Object { $_.ToUpper() }", connection.Streams{
+			Stdin:  strings.NewReader("first\r\nsecond\n_x0041_"),
+			Stdout: stdout,
+		})
+		suite.NoError(err)
+		suite.NoError(stdout.Close())
+		suite.Equal(0, exitCode)
+		suite.Equal([]string{"FIRST", "SECOND", "_X0041_"}, lines)
+	})
+
+	suite.Run("should stop the pipeline if the context is canceled", func() {
+		server := newTestPSRPServer(suite.T(), func(script string, input []string, stop <-chan struct{}) []testPSRPMessage {
+			<-stop
+			return []testPSRPMessage{testPipelineState(testRecordedPipelineStopped)}
+		})
+		conn, err := NewPSRPConnection(server.config())
+		suite.Require().NoError(err)
+
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+		defer cancel()
+
+		_, err = conn.Stream(ctx, "Start-Sleep -Seconds 60", connection.Streams{})
+		suite.ErrorIs(err, context.DeadlineExceeded)
+		suite.Equal(1, server.signals)
+
+		// The runspace pool can be used for the next pipeline.
+		server.handler = completed(testOutput(`<S>next</S>`))
+		result, err := conn.Run(context.Background(), "Write-Output 'next'")
+		suite.NoError(err)
+		suite.Equal("next\r\n", result.StdOut)
+	})
+}
+
+func (suite *PSRPUnitTestSuite) TestStopTimeout() {
+	suite.Run("should close the runspace pool if the pipeline does not stop", func() {
+		var server *testPSRPServer
+		server = newTestPSRPServer(suite.T(), func(script string, input []string, stop <-chan struct{}) []testPSRPMessage {
+			<-server.closed
+			return []testPSRPMessage{testPipelineState(testRecordedPipelineStopped)}
+		})
+		conn, err := NewPSRPConnection(server.config())
+		suite.Require().NoError(err)
+		conn.stopTimeout = 50 * time.Millisecond
+
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+
+		_, err = conn.Stream(ctx, "Start-Sleep -Seconds 60", connection.Streams{})
+		suite.ErrorIs(err, context.DeadlineExceeded)
+		suite.ErrorContains(err, "winrm: the pipeline did not stop within 50ms, the runspace pool is closed")
+		suite.Equal(1, server.signals)
+		suite.True(server.deleted)
+
+		_, err = conn.Run(context.Background(), "Get-Date")
+		suite.EqualError(err, "winrm: the runspace pool is closed")
+	})
+}
+
+func (suite *PSRPUnitTestSuite) TestInvoke() {
+	suite.Run("should return the typed output and error streams", func() {
+		server := newTestPSRPServer(suite.T(), completed(
+			testOutput(testRecordedLocalUser),
+			testPSRPMessage{messageType: psrpTypeErrorRecord, data: testRecordedUserNotFound},
+			testOutput(testRecordedLocalUser),
+		))
+		conn, err := NewPSRPConnection(server.config())
+		suite.Require().NoError(err)
+
+		result, err := conn.Invoke(context.Background(), "Get-LocalUser")
+		suite.Require().NoError(err)
+		suite.False(result.Failed)
+		suite.Len(result.Output, 2)
+
+		type localUser struct {
+			Name            string
+			Enabled         bool
+			PrincipalSource string
+		}
+		var users []localUser
+		suite.NoError(result.UnmarshalOutput(&users))
+		suite.Equal([]localUser{
+			{Name: "Administrator", Enabled: true, PrincipalSource: "Local"},
+			{Name: "Administrator", Enabled: true, PrincipalSource: "Local"},
+		}, users)
+
+		var user localUser
+		suite.EqualError(result.UnmarshalOutput(&user), "winrm: expected a single object but got 2 objects")
+
+		suite.Equal([]parsing.ErrorRecord{{
+			FullyQualifiedErrorId: "UserNotFound,Microsoft.PowerShell.Commands.GetLocalUserCommand",
+			CategoryInfo: parsing.CategoryInfo{
+				Category:   "ObjectNotFound",
+				Activity:   "Get-LocalUser",
+				Reason:     "UserNotFoundException",
+				TargetName: "test",
+				TargetType: "String",
+			},
+			Exception:      parsing.ExceptionInfo{Type: "UserNotFoundException", Message: "User test was not found."},
+			ScriptPosition: parsing.ScriptPosition{Line: 1, Column: 1, Text: "Get-LocalUser -Name test"},
+		}}, result.Errors)
+	})
+
+	suite.Run("should unmarshal a single output object", func() {
+		server := newTestPSRPServer(suite.T(), completed(testOutput(testRecordedLocalUser)))
+		conn, err := NewPSRPConnection(server.config())
+		suite.Require().NoError(err)
+
+		result, err := conn.Invoke(context.Background(), "Get-LocalUser -Name Administrator")
+		suite.Require().NoError(err)
+
+		var user struct{ Name string }
+		suite.NoError(result.UnmarshalOutput(&user))
+		suite.Equal("Administrator", user.Name)
+	})
+
+	suite.Run("should report a failed pipeline", func() {
+		server := newTestPSRPServer(suite.T(), func(script string, input []string, stop <-chan struct{}) []testPSRPMessage {
+			return []testPSRPMessage{testPipelineState(testRecordedPipelineFailed)}
+		})
+		conn, err := NewPSRPConnection(server.config())
+		suite.Require().NoError(err)
+
+		result, err := conn.Invoke(context.Background(), "throw 'boom'")
+		suite.Require().NoError(err)
+		suite.True(result.Failed)
+		suite.Empty(result.Output)
+		suite.Require().Len(result.Errors, 1)
+		suite.Equal(parsing.ExceptionInfo{Type: "RuntimeException", Message: "boom"}, result.Errors[0].Exception)
+	})
+}
@@ -0,0 +1,558 @@
+package winrm
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/rc4"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/pem"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+	"unicode/utf16"
+
+	"golang.org/x/crypto/md4"
+)
+
+// Credentials of the test server.
+const (
+	testServerUsername string = "vagrant"
+	testServerPassword string = "vagrant"
+)
+
+// Ids of the shell and the command of the test server.
+const (
+	testServerShellId   string = "67A74734-DD32-4F10-89DE-49A060483810"
+	testServerCommandId string = "1A6DEE6B-EC68-4DD6-87E9-030C0048ECC4"
+)
+
+// WS-Management actions of the test server.
+const (
+	actionCreate  string = "http://schemas.xmlsoap.org/ws/2004/09/transfer/Create"
+	actionDelete  string = "http://schemas.xmlsoap.org/ws/2004/09/transfer/Delete"
+	actionCommand string = "http://schemas.microsoft.com/wbem/wsman/1/windows/shell/Command"
+	actionSend    string = "http://schemas.microsoft.com/wbem/wsman/1/windows/shell/Send"
+	actionReceive string = "http://schemas.microsoft.com/wbem/wsman/1/windows/shell/Receive"
+	actionSignal  string = "http://schemas.microsoft.com/wbem/wsman/1/windows/shell/Signal"
+)
+
+// testCommandHandler runs a command on the test server and returns its exit code.
+type testCommandHandler func(cmd string, stdin io.Reader, stdout io.Writer, stderr io.Writer) int
+
+// testServer is an in-process WinRM server that runs the commands of a shell with a testCommandHandler.
+// It answers every WS-Management request the WinRM client sends for a command.
+type testServer struct {
+	server  *httptest.Server
+	handler testCommandHandler
+
+	// ntlm enables NTLM authentication of the requests.
+	// The NTLM responses are not verified, the server only records the authenticated users.
+	ntlm      bool
+	ntlmUsers []string
+
+	// encryption requires NTLM message encryption, the server rejects unencrypted messages.
+	// The sessions of the NTLM handshakes are kept per client connection.
+	encryption bool
+	sessions   map[string]*testNTLMSession
+	unsealed   int
+
+	mu       sync.Mutex
+	cmd      string
+	stdin    bytes.Buffer
+	stdinEnd chan struct{}
+}
+
+// testEnvelope holds the parts of a WS-Management request the test server needs.
+type testEnvelope struct {
+	Action      string `xml:"Header>Action"`
+	Command     string `xml:"Body>CommandLine>Command"`
+	StdinStream struct {
+		Content string `xml:",chardata"`
+		End     bool   `xml:"End,attr"`
+	} `xml:"Body>Send>Stream"`
+}
+
+// newTestServer starts a new test server on a random local port.
+// The server is closed when the test finishes.
+func newTestServer(t *testing.T, handler testCommandHandler) *testServer {
+	t.Helper()
+
+	s := &testServer{handler: handler}
+	s.server = httptest.NewServer(http.HandlerFunc(s.serveHTTP))
+	t.Cleanup(s.server.Close)
+
+	return s
+}
+
+// newTestServerTLS starts a new test server with the TLS configuration on a random local port.
+// The server is closed when the test finishes.
+func newTestServerTLS(t *testing.T, handler testCommandHandler, tlsConfig *tls.Config) *testServer {
+	t.Helper()
+
+	s := &testServer{handler: handler}
+	s.server = httptest.NewUnstartedServer(http.HandlerFunc(s.serveHTTP))
+	s.server.TLS = tlsConfig
+	s.server.StartTLS()
+	t.Cleanup(s.server.Close)
+
+	return s
+}
+
+// config returns a connection configuration for the test server.
+func (s *testServer) config() *Config {
+	addr := s.server.Listener.Addr().(*net.TCPAddr)
+	return &Config{
+		Host:     addr.IP.String(),
+		Port:     addr.Port,
+		Username: testServerUsername,
+		Password: testServerPassword,
+		UseTLS:   s.server.TLS != nil,
+	}
+}
+
+// unsealedMessages returns the number of encrypted messages the server received.
+func (s *testServer) unsealedMessages() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.unsealed
+}
+
+// session returns the NTLM session of a client connection or nil.
+func (s *testServer) session(remoteAddr string) *testNTLMSession {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.sessions[remoteAddr]
+}
+
+// authenticatedUsers returns the users the server authenticated via NTLM.
+func (s *testServer) authenticatedUsers() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.ntlmUsers...)
+}
+
+// serveHTTP answers a WS-Management request.
+func (s *testServer) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	if s.ntlm && !s.authenticateNTLM(w, r) {
+		return
+	}
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if !s.encryption {
+		s.serveEnvelope(w, r, body)
+		return
+	}
+
+	// The client runs the handshake with an empty message before it sends the encrypted message.
+	if len(body) == 0 {
+		return
+	}
+
+	session := s.session(r.RemoteAddr)
+	if session == nil || !strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/encrypted;") {
+		http.Error(w, "unencrypted messages are not allowed", http.StatusBadRequest)
+		return
+	}
+
+	body, err = session.unseal(body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	s.mu.Lock()
+	s.unsealed++
+	s.mu.Unlock()
+
+	response := httptest.NewRecorder()
+	s.serveEnvelope(response, r, body)
+
+	w.Header().Set("Content-Type", `multipart/encrypted;protocol="application/HTTP-SPNEGO-session-encrypted";boundary="Encrypted Boundary"`)
+	w.WriteHeader(response.Code)
+	w.Write(session.seal(response.Body.Bytes()))
+}
+
+// serveEnvelope answers the WS-Management request of the body.
+func (s *testServer) serveEnvelope(w http.ResponseWriter, r *http.Request, body []byte) {
+	var envelope testEnvelope
+	if err := xml.Unmarshal(body, &envelope); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/soap+xml;charset=UTF-8")
+
+	switch envelope.Action {
+	case actionCreate:
+		fmt.Fprint(w, testResponse("http://schemas.xmlsoap.org/ws/2004/09/transfer/CreateResponse",
+			`<rsp:Shell><rsp:ShellId>`+testServerShellId+`</rsp:ShellId></rsp:Shell>`))
+
+	case actionCommand:
+		s.mu.Lock()
+		s.cmd = envelope.Command
+		s.stdin.Reset()
+		s.stdinEnd = make(chan struct{})
+		s.mu.Unlock()
+
+		fmt.Fprint(w, testResponse(actionCommand+"Response",
+			`<rsp:CommandResponse><rsp:CommandId>`+testServerCommandId+`</rsp:CommandId></rsp:CommandResponse>`))
+
+	case actionSend:
+		content, err := base64.StdEncoding.DecodeString(strings.TrimSpace(envelope.StdinStream.Content))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		s.mu.Lock()
+		s.stdin.Write(content)
+		if envelope.StdinStream.End {
+			close(s.stdinEnd)
+		}
+		s.mu.Unlock()
+
+		fmt.Fprint(w, testResponse(actionSend+"Response", `<rsp:SendResponse/>`))
+
+	case actionReceive:
+		s.mu.Lock()
+		stdinEnd := s.stdinEnd
+		s.mu.Unlock()
+
+		// The command runs as soon as the client closed the input stream.
+		select {
+		case <-stdinEnd:
+		case <-r.Context().Done():
+			return
+		}
+
+		s.mu.Lock()
+		cmd := s.cmd
+		stdin := bytes.NewReader(s.stdin.Bytes())
+		s.mu.Unlock()
+
+		var stdout, stderr bytes.Buffer
+		exitCode := s.handler(cmd, stdin, &stdout, &stderr)
+
+		fmt.Fprint(w, testResponse(actionReceive+"Response", `<rsp:ReceiveResponse>`+
+			testStream("stdout", stdout.Bytes())+
+			testStream("stderr", stderr.Bytes())+
+			`<rsp:CommandState CommandId="`+testServerCommandId+`" State="http://schemas.microsoft.com/wbem/wsman/1/windows/shell/CommandState/Done">`+
+			`<rsp:ExitCode>`+strconv.Itoa(exitCode)+`</rsp:ExitCode></rsp:CommandState></rsp:ReceiveResponse>`))
+
+	case actionSignal:
+		fmt.Fprint(w, testResponse(actionSignal+"Response", `<rsp:SignalResponse/>`))
+
+	case actionDelete:
+		fmt.Fprint(w, testResponse(actionDelete+"Response", ""))
+
+	default:
+		http.Error(w, "unknown action "+envelope.Action, http.StatusBadRequest)
+	}
+}
+
+// testResponse returns a WS-Management response envelope with the given action and body.
+func testResponse(action string, body string) string {
+	return `<s:Envelope xml:lang="en-US" xmlns:s="http://www.w3.org/2003/05/soap-envelope" xmlns:a="http://schemas.xmlsoap.org/ws/2004/08/addressing" ` +
+		`xmlns:w="http://schemas.dmtf.org/wbem/wsman/1/wsman.xsd" xmlns:rsp="http://schemas.microsoft.com/wbem/wsman/1/windows/shell">` +
+		`<s:Header><a:Action>` + action + `</a:Action></s:Header><s:Body>` + body + `</s:Body></s:Envelope>`
+}
+
+// testStream returns an output stream element of a receive response.
+func testStream(name string, content []byte) string {
+	if len(content) == 0 {
+		return ""
+	}
+	return `<rsp:Stream Name="` + name + `" CommandId="` + testServerCommandId + `">` + base64.StdEncoding.EncodeToString(content) + `</rsp:Stream>`
+}
+
+// authenticateNTLM runs the NTLM handshake of a request.
+// It returns true if the request carries an NTLM authenticate message and can be answered.
+func (s *testServer) authenticateNTLM(w http.ResponseWriter, r *http.Request) bool {
+	scheme, token, _ := strings.Cut(r.Header.Get("Authorization"), " ")
+
+	// The encrypted messages are sent on the connection of the handshake without authorization.
+	if s.encryption && token == "" && r.ContentLength > 0 && s.session(r.RemoteAddr) != nil {
+		return true
+	}
+
+	message, err := base64.StdEncoding.DecodeString(token)
+	if (scheme != "Negotiate" && scheme != "NTLM") || err != nil || len(message) < 12 {
+		w.Header().Set("WWW-Authenticate", "Negotiate")
+		w.WriteHeader(http.StatusUnauthorized)
+		return false
+	}
+
+	switch binary.LittleEndian.Uint32(message[8:12]) {
+	case 1:
+		// Challenge message without target name and target info.
+		// Flags: NTLMSSP_NEGOTIATE_UNICODE | NTLMSSP_NEGOTIATE_NTLM
+		challenge := make([]byte, 48)
+		copy(challenge, "NTLMSSP\x00")
+		binary.LittleEndian.PutUint32(challenge[8:], 2)
+		binary.LittleEndian.PutUint32(challenge[20:], 0x00000201)
+		copy(challenge[24:], "testchal")
+
+		// Message encryption needs NTLMv2 with a key exchange and a target info with only the end of list.
+		// Flags: NTLMSSP_NEGOTIATE_UNICODE | NTLMSSP_NEGOTIATE_SIGN | NTLMSSP_NEGOTIATE_SEAL | NTLMSSP_NEGOTIATE_NTLM |
+		// NTLMSSP_NEGOTIATE_ALWAYS_SIGN | NTLMSSP_NEGOTIATE_EXTENDED_SESSIONSECURITY | NTLMSSP_NEGOTIATE_TARGET_INFO |
+		// NTLMSSP_NEGOTIATE_128 | NTLMSSP_NEGOTIATE_KEY_EXCH
+		if s.encryption {
+			binary.LittleEndian.PutUint32(challenge[20:], 0x60888231)
+			binary.LittleEndian.PutUint16(challenge[40:], 4)
+			binary.LittleEndian.PutUint16(challenge[42:], 4)
+			binary.LittleEndian.PutUint32(challenge[44:], 48)
+			challenge = append(challenge, 0, 0, 0, 0)
+		}
+
+		w.Header().Set("WWW-Authenticate", scheme+" "+base64.StdEncoding.EncodeToString(challenge))
+		w.WriteHeader(http.StatusUnauthorized)
+		return false
+
+	case 3:
+		// The user name field of the authenticate message.
+		length := int(binary.LittleEndian.Uint16(message[36:]))
+		offset := int(binary.LittleEndian.Uint32(message[40:]))
+		name := make([]uint16, length/2)
+		for i := range name {
+			name[i] = binary.LittleEndian.Uint16(message[offset+2*i:])
+		}
+
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		s.ntlmUsers = append(s.ntlmUsers, string(utf16.Decode(name)))
+
+		if s.encryption {
+			if s.sessions == nil {
+				s.sessions = map[string]*testNTLMSession{}
+			}
+			s.sessions[r.RemoteAddr] = newTestNTLMSession(message, testServerPassword)
+		}
+		return true
+
+	default:
+		http.Error(w, "unexpected NTLM message", http.StatusBadRequest)
+		return false
+	}
+}
+
+// testNTLMSession is the server side of an NTLMv2 session with extended session security and a 128-bit key exchange.
+// It unseals the messages of the client and seals the messages of the server, see MS-NLMP 3.4.
+type testNTLMSession struct {
+	clientSeal, serverSeal *rc4.Cipher
+	clientSign, serverSign []byte
+	clientSeq, serverSeq   uint32
+}
+
+// newTestNTLMSession derives the keys of the session from the authenticate message of the client and its password.
+func newTestNTLMSession(authenticate []byte, password string) *testNTLMSession {
+	field := func(offset int) []byte {
+		length := int(binary.LittleEndian.Uint16(authenticate[offset:]))
+		start := int(binary.LittleEndian.Uint32(authenticate[offset+4:]))
+		return authenticate[start : start+length]
+	}
+	ntResponse, domain, user, encryptedKey := field(20), field(28), field(36), field(52)
+
+	// NTOWFv2, the session base key and the exported session key of the key exchange.
+	ntHash := md4.New()
+	ntHash.Write(testUTF16(password))
+	responseKey := testHMACMD5(ntHash.Sum(nil), testUTF16(strings.ToUpper(testDecodeUTF16(user))), domain)
+	sessionBaseKey := testHMACMD5(responseKey, ntResponse[:16])
+	exportedKey := make([]byte, len(encryptedKey))
+	keyExchange, _ := rc4.NewCipher(sessionBaseKey)
+	keyExchange.XORKeyStream(exportedKey, encryptedKey)
+
+	key := func(constant string) []byte {
+		sum := md5.Sum(append(append([]byte(nil), exportedKey...), constant+"\x00"...))
+		return sum[:]
+	}
+	clientSeal, _ := rc4.NewCipher(key("session key to client-to-server sealing key magic constant"))
+	serverSeal, _ := rc4.NewCipher(key("session key to server-to-client sealing key magic constant"))
+
+	return &testNTLMSession{
+		clientSeal: clientSeal,
+		serverSeal: serverSeal,
+		clientSign: key("session key to client-to-server signing key magic constant"),
+		serverSign: key("session key to server-to-client signing key magic constant"),
+	}
+}
+
+// unseal decrypts an encrypted message of the client and verifies its signature.
+func (session *testNTLMSession) unseal(body []byte) ([]byte, error) {
+	_, payload, ok := bytes.Cut(body, []byte("\tContent-Type: application/octet-stream\r\n"))
+	payload = bytes.TrimSuffix(payload, []byte("--Encrypted Boundary--\r\n"))
+	if !ok || len(payload) < 20 || binary.LittleEndian.Uint32(payload) != 16 {
+		return nil, errors.New("malformed encrypted message")
+	}
+	signature, sealed := payload[4:20], payload[20:]
+
+	// The checksum of the signature is encrypted with the key stream after the message.
+	message := make([]byte, len(sealed))
+	session.clientSeal.XORKeyStream(message, sealed)
+	checksum := make([]byte, 8)
+	session.clientSeal.XORKeyStream(checksum, signature[4:12])
+
+	seq := binary.LittleEndian.AppendUint32(nil, session.clientSeq)
+	if !bytes.Equal(checksum, testHMACMD5(session.clientSign, seq, message)[:8]) || !bytes.Equal(seq, signature[12:16]) {
+		return nil, errors.New("invalid signature of the encrypted message")
+	}
+	session.clientSeq++
+
+	return message, nil
+}
+
+// seal encrypts and signs a message of the server.
+func (session *testNTLMSession) seal(message []byte) []byte {
+	sealed := make([]byte, len(message))
+	session.serverSeal.XORKeyStream(sealed, message)
+
+	seq := binary.LittleEndian.AppendUint32(nil, session.serverSeq)
+	checksum := testHMACMD5(session.serverSign, seq, message)[:8]
+	session.serverSeal.XORKeyStream(checksum, checksum)
+	session.serverSeq++
+
+	var b bytes.Buffer
+	b.WriteString("--Encrypted Boundary\r\n")
+	b.WriteString("\tContent-Type: application/HTTP-SPNEGO-session-encrypted\r\n")
+	fmt.Fprintf(&b, "\tOriginalContent: type=application/soap+xml;charset=UTF-8;Length=%d\r\n", len(message))
+	b.WriteString("--Encrypted Boundary\r\n")
+	b.WriteString("\tContent-Type: application/octet-stream\r\n")
+	b.Write(binary.LittleEndian.AppendUint32(nil, 16))
+	b.Write(binary.LittleEndian.AppendUint32(nil, 1))
+	b.Write(checksum)
+	b.Write(seq)
+	b.Write(sealed)
+	b.WriteString("--Encrypted Boundary--\r\n")
+
+	return b.Bytes()
+}
+
+// testHMACMD5 returns the HMAC-MD5 of the data with the key.
+func testHMACMD5(key []byte, data ...[]byte) []byte {
+	h := hmac.New(md5.New, key)
+	for _, d := range data {
+		h.Write(d)
+	}
+	return h.Sum(nil)
+}
+
+// testUTF16 returns the UTF-16LE encoding of a string.
+func testUTF16(s string) []byte {
+	var b []byte
+	for _, r := range utf16.Encode([]rune(s)) {
+		b = binary.LittleEndian.AppendUint16(b, r)
+	}
+	return b
+}
+
+// testDecodeUTF16 decodes a UTF-16LE encoded string.
+func testDecodeUTF16(b []byte) string {
+	u := make([]uint16, len(b)/2)
+	for i := range u {
+		u[i] = binary.LittleEndian.Uint16(b[2*i:])
+	}
+	return string(utf16.Decode(u))
+}
+
+// testPKI holds a CA with a server certificate for the host name "winsrv.test" and a client certificate.
+type testPKI struct {
+	caCert     string
+	caPool     *x509.CertPool
+	serverCert tls.Certificate
+	clientCert string
+	clientKey  string
+}
+
+// newTestPKI creates a new CA and issues a server and a client certificate.
+func newTestPKI(t *testing.T) *testPKI {
+	t.Helper()
+
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	caTemplate := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "gowindows test CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	caDER, err := x509.CreateCertificate(rand.Reader, caTemplate, caTemplate, &caKey.PublicKey, caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ca, err := x509.ParseCertificate(caDER)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// issue returns the PEM encoded certificate and key of a leaf certificate signed by the CA.
+	issue := func(serial int64, usage x509.ExtKeyUsage, dnsNames []string) (string, string) {
+		key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		if err != nil {
+			t.Fatal(err)
+		}
+		template := &x509.Certificate{
+			SerialNumber: big.NewInt(serial),
+			Subject:      pkix.Name{CommonName: "vagrant"},
+			NotBefore:    time.Now().Add(-time.Hour),
+			NotAfter:     time.Now().Add(time.Hour),
+			KeyUsage:     x509.KeyUsageDigitalSignature,
+			ExtKeyUsage:  []x509.ExtKeyUsage{usage},
+			DNSNames:     dnsNames,
+		}
+		der, err := x509.CreateCertificate(rand.Reader, template, ca, &key.PublicKey, caKey)
+		if err != nil {
+			t.Fatal(err)
+		}
+		keyDER, err := x509.MarshalECPrivateKey(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})),
+			string(pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}))
+	}
+
+	serverCert, serverKey := issue(2, x509.ExtKeyUsageServerAuth, []string{"winsrv.test"})
+	server, err := tls.X509KeyPair([]byte(serverCert), []byte(serverKey))
+	if err != nil {
+		t.Fatal(err)
+	}
+	clientCert, clientKey := issue(3, x509.ExtKeyUsageClientAuth, nil)
+
+	caPool := x509.NewCertPool()
+	caPool.AddCert(ca)
+
+	return &testPKI{
+		caCert:     string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: caDER})),
+		caPool:     caPool,
+		serverCert: server,
+		clientCert: clientCert,
+		clientKey:  clientKey,
+	}
+}
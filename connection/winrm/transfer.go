@@ -0,0 +1,268 @@
+package winrm
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/d-strobel/gowindows/connection"
+	"github.com/d-strobel/gowindows/parsing"
+)
+
+// partSuffix is appended to the remote path of an upload until all chunks are written and verified.
+const partSuffix = ".part"
+
+// statScript prints the size and the SHA256 hash of a file or nothing if the file does not exist.
+const statScript = `$ErrorActionPreference = 'Stop'
+$path = %s
+if (Test-Path -LiteralPath $path -PathType Leaf) {
+	"$((Get-Item -LiteralPath $path).Length) $((Get-FileHash -LiteralPath $path -Algorithm SHA256).Hash)"
+}`
+
+// writeChunkScript writes the base64 encoded input to a file at the offset and truncates the file after it.
+const writeChunkScript = `$ErrorActionPreference = 'Stop'
+$path = %s
+$offset = %d
+$bytes = [Convert]::FromBase64String((@($input) -join ''))
+$f = [IO.File]::Open($path, [IO.FileMode]::OpenOrCreate, [IO.FileAccess]::Write)
+try {
+	if ($f.Length -lt $offset) { throw "The file $path is shorter than $offset bytes." }
+	$f.SetLength($offset)
+	$f.Position = $offset
+	$f.Write($bytes, 0, $bytes.Length)
+} finally {
+	$f.Close()
+}`
+
+// commitScript verifies the SHA256 hash of an uploaded file and moves it to its destination.
+const commitScript = `$ErrorActionPreference = 'Stop'
+$part = %s
+$path = %s
+$hash = (Get-FileHash -LiteralPath $part -Algorithm SHA256).Hash
+if ($hash -ne %s) {
+	Remove-Item -LiteralPath $part -Force
+	throw "The SHA256 hash $hash of the uploaded file does not match."
+}
+Move-Item -LiteralPath $part -Destination $path -Force`
+
+// readChunkScript prints a chunk of a file at the offset base64 encoded.
+const readChunkScript = `$ErrorActionPreference = 'Stop'
+$path = %s
+$offset = %d
+$f = [IO.File]::Open($path, [IO.FileMode]::Open, [IO.FileAccess]::Read, [IO.FileShare]::ReadWrite)
+try {
+	$f.Position = $offset
+	$buffer = New-Object byte[] %d
+	$n = $f.Read($buffer, 0, $buffer.Length)
+	[Convert]::ToBase64String($buffer, 0, $n)
+} finally {
+	$f.Close()
+}`
+
+// Upload writes the content of r to the remote file in chunks of base64 encoded PowerShell input.
+// The chunks are written to a partial file next to the remote file, which replaces the remote file
+// once its SHA256 hash matches the hash of the content.
+// If r is an io.ReadSeeker, an interrupted upload of the same content resumes after the chunks written so far.
+// The progress of the context is called after every chunk, see connection.WithTransferProgress.
+// Satisfies the connection.FileTransferer interface.
+func (c *Connection) Upload(ctx context.Context, r io.Reader, remotePath string) error {
+	return upload(ctx, c, c.chunkSize, r, remotePath)
+}
+
+// Download writes the content of the remote file to w in chunks of base64 encoded PowerShell output
+// and verifies the SHA256 hash of the content.
+// The progress of the context is called after every chunk, see connection.WithTransferProgress.
+// Satisfies the connection.FileTransferer interface.
+func (c *Connection) Download(ctx context.Context, remotePath string, w io.Writer) error {
+	return download(ctx, c, c.chunkSize, remotePath, w)
+}
+
+// Upload writes the content of r to the remote file in chunks like Connection.Upload does.
+// Satisfies the connection.FileTransferer interface.
+func (c *PSRPConnection) Upload(ctx context.Context, r io.Reader, remotePath string) error {
+	return upload(ctx, c, c.chunkSize, r, remotePath)
+}
+
+// Download writes the content of the remote file to w in chunks like Connection.Download does.
+// Satisfies the connection.FileTransferer interface.
+func (c *PSRPConnection) Download(ctx context.Context, remotePath string, w io.Writer) error {
+	return download(ctx, c, c.chunkSize, remotePath, w)
+}
+
+// upload writes the content of r to the remote file in chunks of the chunk size using PowerShell commands of the connection.
+func upload(ctx context.Context, conn connection.Connection, chunkSize int, r io.Reader, remotePath string) error {
+	if chunkSize <= 0 {
+		chunkSize = defaultTransferChunk
+	}
+	progress := connection.TransferProgressFromContext(ctx)
+	partPath := remotePath + partSuffix
+	h := sha256.New()
+
+	var offset int64
+	if rs, ok := r.(io.ReadSeeker); ok {
+		var err error
+		offset, err = resumeOffset(ctx, conn, partPath, rs, h)
+		if err != nil {
+			return fmt.Errorf("winrm: failed to upload %s: %w", remotePath, err)
+		}
+		if offset > 0 {
+			progress(offset)
+		}
+	}
+
+	buf := make([]byte, chunkSize)
+	for first := true; ; first = false {
+		n, readErr := io.ReadFull(r, buf)
+		if readErr != nil && readErr != io.EOF && readErr != io.ErrUnexpectedEOF {
+			return fmt.Errorf("winrm: failed to upload %s: %w", remotePath, readErr)
+		}
+
+		// The first chunk is written even if it is empty, so empty files are created as well.
+		if n == 0 && !first {
+			break
+		}
+
+		h.Write(buf[:n])
+		script := fmt.Sprintf(writeChunkScript, parsing.PwshQuote(partPath), offset)
+		if _, err := runScript(ctx, conn, script, strings.NewReader(base64.StdEncoding.EncodeToString(buf[:n]))); err != nil {
+			return fmt.Errorf("winrm: failed to upload %s: %w", remotePath, err)
+		}
+		offset += int64(n)
+		progress(offset)
+
+		if readErr != nil {
+			break
+		}
+	}
+
+	script := fmt.Sprintf(commitScript, parsing.PwshQuote(partPath), parsing.PwshQuote(remotePath), parsing.PwshQuote(hex.EncodeToString(h.Sum(nil))))
+	if _, err := runScript(ctx, conn, script, nil); err != nil {
+		return fmt.Errorf("winrm: failed to upload %s: %w", remotePath, err)
+	}
+
+	return nil
+}
+
+// resumeOffset returns the size of the partial remote file if it matches the beginning of rs.
+// In that case, rs is positioned after the matching content and h contains its hash.
+// Otherwise, rs is not moved and 0 is returned.
+func resumeOffset(ctx context.Context, conn connection.Connection, partPath string, rs io.ReadSeeker, h hash.Hash) (int64, error) {
+	size, remoteHash, ok, err := stat(ctx, conn, partPath)
+	if err != nil || !ok || size == 0 {
+		return 0, err
+	}
+
+	start, err := rs.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return 0, err
+	}
+
+	n, err := io.CopyN(h, rs, size)
+	if err != nil && err != io.EOF {
+		return 0, err
+	}
+	if n == size && strings.EqualFold(hex.EncodeToString(h.Sum(nil)), remoteHash) {
+		return size, nil
+	}
+
+	// The partial file belongs to other content, so the upload starts over.
+	h.Reset()
+	if _, err := rs.Seek(start, io.SeekStart); err != nil {
+		return 0, err
+	}
+	return 0, nil
+}
+
+// download writes the content of the remote file to w in chunks of the chunk size using PowerShell commands of the connection.
+func download(ctx context.Context, conn connection.Connection, chunkSize int, remotePath string, w io.Writer) error {
+	if chunkSize <= 0 {
+		chunkSize = defaultTransferChunk
+	}
+	progress := connection.TransferProgressFromContext(ctx)
+
+	size, remoteHash, ok, err := stat(ctx, conn, remotePath)
+	if err != nil {
+		return fmt.Errorf("winrm: failed to download %s: %w", remotePath, err)
+	}
+	if !ok {
+		return fmt.Errorf("winrm: failed to download %s: the file does not exist", remotePath)
+	}
+
+	h := sha256.New()
+	var offset int64
+	for offset < size {
+		script := fmt.Sprintf(readChunkScript, parsing.PwshQuote(remotePath), offset, chunkSize)
+		out, err := runScript(ctx, conn, script, nil)
+		if err != nil {
+			return fmt.Errorf("winrm: failed to download %s: %w", remotePath, err)
+		}
+
+		chunk, err := base64.StdEncoding.DecodeString(out)
+		if err != nil {
+			return fmt.Errorf("winrm: failed to download %s: %w", remotePath, err)
+		}
+		if len(chunk) == 0 {
+			return fmt.Errorf("winrm: failed to download %s: the file was truncated at %d of %d bytes", remotePath, offset, size)
+		}
+
+		h.Write(chunk)
+		if _, err := w.Write(chunk); err != nil {
+			return fmt.Errorf("winrm: failed to download %s: %w", remotePath, err)
+		}
+		offset += int64(len(chunk))
+		progress(offset)
+	}
+
+	if !strings.EqualFold(hex.EncodeToString(h.Sum(nil)), remoteHash) {
+		return fmt.Errorf("winrm: failed to download %s: the SHA256 hash of the downloaded content does not match, the file may have changed", remotePath)
+	}
+
+	return nil
+}
+
+// stat returns the size and the SHA256 hash of the remote file.
+// It returns false if the file does not exist.
+func stat(ctx context.Context, conn connection.Connection, path string) (int64, string, bool, error) {
+	out, err := runScript(ctx, conn, fmt.Sprintf(statScript, parsing.PwshQuote(path)), nil)
+	if err != nil || out == "" {
+		return 0, "", false, err
+	}
+
+	sizeText, remoteHash, found := strings.Cut(out, " ")
+	size, err := strconv.ParseInt(sizeText, 10, 64)
+	if !found || err != nil {
+		return 0, "", false, fmt.Errorf("unexpected output %q of the file status", out)
+	}
+
+	return size, remoteHash, true, nil
+}
+
+// runScript runs a PowerShell script with the input and returns its trimmed output.
+// If the script fails, the error records are returned as error.
+func runScript(ctx context.Context, conn connection.Connection, script string, stdin io.Reader) (string, error) {
+	result, err := connection.RunWithPowershellInput(ctx, conn, script, stdin)
+	if err != nil {
+		return "", err
+	}
+
+	if result.ExitCode != 0 {
+		msg, decodeErr := parsing.DecodeCliXmlErr(result.StdErr)
+		if decodeErr != nil {
+			msg = result.StdErr
+		}
+		msg = strings.TrimSpace(msg)
+		if msg == "" {
+			msg = fmt.Sprintf("the command failed with exit code %d", result.ExitCode)
+		}
+		return "", errors.New(msg)
+	}
+
+	return strings.TrimSpace(result.StdOut), nil
+}
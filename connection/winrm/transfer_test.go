@@ -0,0 +1,290 @@
+package winrm
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode/utf16"
+
+	"github.com/d-strobel/gowindows/connection"
+)
+
+var (
+	// testScriptVariableRegexp matches the variable assignments of the transfer scripts.
+	testScriptVariableRegexp = regexp.MustCompile(`(?m)^\$(path|part|offset) = (.+)$`)
+
+	// testScriptHashRegexp matches the expected hash of the commit script.
+	testScriptHashRegexp = regexp.MustCompile(`\$hash -ne '([0-9a-f]+)'`)
+
+	// testScriptBufferRegexp matches the chunk size of the read chunk script.
+	testScriptBufferRegexp = regexp.MustCompile(`New-Object byte\[\] (\d+)`)
+)
+
+// testFiles stands in for the file system of the host and runs the PowerShell scripts of the file transfers.
+type testFiles struct {
+	mu      sync.Mutex
+	files   map[string][]byte
+	scripts []string
+
+	// failWrite fails the write of the chunk with the given number, starting with 1, if it is not zero.
+	failWrite int
+	writes    int
+
+	// corruptReads flips a bit in every chunk that is read.
+	corruptReads bool
+}
+
+func newTestFiles() *testFiles {
+	return &testFiles{files: map[string][]byte{}}
+}
+
+// file returns the content of a file and whether it exists.
+func (f *testFiles) file(path string) ([]byte, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	content, ok := f.files[path]
+	return content, ok
+}
+
+// ran returns the kinds of the scripts that ran.
+func (f *testFiles) ran() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]string(nil), f.scripts...)
+}
+
+// handler returns a command handler that decodes the PowerShell command and runs the transfer script.
+func (f *testFiles) handler(cmd string, stdin io.Reader, stdout io.Writer, stderr io.Writer) int {
+	script, err := decodeTestPwshCmd(cmd)
+	if err != nil {
+		fmt.Fprint(stderr, err)
+		return 1
+	}
+
+	vars := map[string]string{}
+	for _, m := range testScriptVariableRegexp.FindAllStringSubmatch(script, -1) {
+		value := m[2]
+		if strings.HasPrefix(value, "'") {
+			value = strings.ReplaceAll(strings.Trim(value, "'"), "''", "'")
+		}
+		vars[m[1]] = value
+	}
+	offset, _ := strconv.Atoi(vars["offset"])
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	switch {
+	case strings.Contains(script, "FromBase64String"):
+		f.scripts = append(f.scripts, "write")
+		f.writes++
+		if f.writes == f.failWrite {
+			fmt.Fprint(stderr, "The network path was not found.")
+			return 1
+		}
+
+		input, err := io.ReadAll(stdin)
+		if err != nil {
+			return 1
+		}
+		chunk, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(input)))
+		if err != nil {
+			fmt.Fprint(stderr, err)
+			return 1
+		}
+
+		content := f.files[vars["path"]]
+		if len(content) < offset {
+			fmt.Fprintf(stderr, "The file %s is shorter than %d bytes.", vars["path"], offset)
+			return 1
+		}
+		f.files[vars["path"]] = append(content[:offset:offset], chunk...)
+
+	case strings.Contains(script, "ToBase64String"):
+		f.scripts = append(f.scripts, "read")
+		size, _ := strconv.Atoi(testScriptBufferRegexp.FindStringSubmatch(script)[1])
+
+		content := f.files[vars["path"]]
+		chunk := bytes.Clone(content[min(offset, len(content)):min(offset+size, len(content))])
+		if f.corruptReads && len(chunk) > 0 {
+			chunk[0] ^= 1
+		}
+		fmt.Fprintln(stdout, base64.StdEncoding.EncodeToString(chunk))
+
+	case strings.Contains(script, "Move-Item"):
+		f.scripts = append(f.scripts, "commit")
+		content := f.files[vars["part"]]
+		sum := sha256.Sum256(content)
+		if hex.EncodeToString(sum[:]) != testScriptHashRegexp.FindStringSubmatch(script)[1] {
+			delete(f.files, vars["part"])
+			fmt.Fprint(stderr, "The SHA256 hash of the uploaded file does not match.")
+			return 1
+		}
+		delete(f.files, vars["part"])
+		f.files[vars["path"]] = content
+
+	case strings.Contains(script, "Get-FileHash"):
+		f.scripts = append(f.scripts, "stat")
+		if content, ok := f.files[vars["path"]]; ok {
+			sum := sha256.Sum256(content)
+			fmt.Fprintf(stdout, "%d %s\r\n", len(content), strings.ToUpper(hex.EncodeToString(sum[:])))
+		}
+
+	default:
+		fmt.Fprintf(stderr, "unexpected script %s", script)
+		return 1
+	}
+
+	return 0
+}
+
+// decodeTestPwshCmd returns the script of a command encoded by parsing.EncodePwshCmd.
+func decodeTestPwshCmd(cmd string) (string, error) {
+	encoded, ok := strings.CutPrefix(cmd, "powershell.exe -NoProfile -EncodedCommand ")
+	if !ok {
+		return "", fmt.Errorf("unexpected command %s", cmd)
+	}
+
+	b, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return "", err
+	}
+	u := make([]uint16, len(b)/2)
+	for i := range u {
+		u[i] = uint16(b[2*i]) | uint16(b[2*i+1])<<8
+	}
+
+	return strings.TrimPrefix(string(utf16.Decode(u)), "$ProgressPreference = 'SilentlyContinue'; "), nil
+}
+
+func (suite *WinRMUnitTestSuite) TestTransfer() {
+	const path = `C:\Temp\it's a file.bin`
+
+	newConnection := func(files *testFiles) *Connection {
+		server := newTestServer(suite.T(), files.handler)
+		config := server.config()
+		config.TransferChunkSize = 1000
+		conn, err := NewConnection(config)
+		suite.Require().NoError(err)
+		return conn
+	}
+
+	content := make([]byte, 3500)
+	_, err := rand.Read(content)
+	suite.Require().NoError(err)
+
+	suite.Run("should upload and download a file in chunks", func() {
+		files := newTestFiles()
+		conn := newConnection(files)
+
+		var uploaded []int64
+		ctx := progressContext(&uploaded)
+		suite.Require().NoError(conn.Upload(ctx, bytes.NewReader(content), path))
+
+		written, ok := files.file(path)
+		suite.True(ok)
+		suite.Equal(content, written)
+		_, ok = files.file(path + partSuffix)
+		suite.False(ok)
+		suite.Equal([]int64{1000, 2000, 3000, 3500}, uploaded)
+		suite.Equal([]string{"stat", "write", "write", "write", "write", "commit"}, files.ran())
+
+		var downloaded []int64
+		var buf bytes.Buffer
+		suite.Require().NoError(conn.Download(progressContext(&downloaded), path, &buf))
+		suite.Equal(content, buf.Bytes())
+		suite.Equal([]int64{1000, 2000, 3000, 3500}, downloaded)
+	})
+
+	suite.Run("should upload an empty file", func() {
+		files := newTestFiles()
+		conn := newConnection(files)
+
+		suite.Require().NoError(conn.Upload(context.Background(), strings.NewReader(""), path))
+
+		written, ok := files.file(path)
+		suite.True(ok)
+		suite.Empty(written)
+
+		var buf bytes.Buffer
+		suite.Require().NoError(conn.Download(context.Background(), path, &buf))
+		suite.Empty(buf.Bytes())
+	})
+
+	suite.Run("should resume an interrupted upload", func() {
+		files := newTestFiles()
+		files.failWrite = 3
+		conn := newConnection(files)
+
+		err := conn.Upload(context.Background(), bytes.NewReader(content), path)
+		suite.EqualError(err, `winrm: failed to upload C:\Temp\it's a file.bin: The network path was not found.`)
+		_, ok := files.file(path)
+		suite.False(ok)
+
+		var uploaded []int64
+		suite.Require().NoError(conn.Upload(progressContext(&uploaded), bytes.NewReader(content), path))
+
+		written, ok := files.file(path)
+		suite.True(ok)
+		suite.Equal(content, written)
+		suite.Equal([]int64{2000, 3000, 3500}, uploaded)
+		suite.Equal([]string{"stat", "write", "write", "write", "stat", "write", "write", "commit"}, files.ran())
+	})
+
+	suite.Run("should start over if the partial file belongs to other content", func() {
+		files := newTestFiles()
+		files.files[path+partSuffix] = []byte("other content")
+		conn := newConnection(files)
+
+		var uploaded []int64
+		suite.Require().NoError(conn.Upload(progressContext(&uploaded), bytes.NewReader(content), path))
+
+		written, ok := files.file(path)
+		suite.True(ok)
+		suite.Equal(content, written)
+		suite.Equal([]int64{1000, 2000, 3000, 3500}, uploaded)
+	})
+
+	suite.Run("should not resume an upload from a reader that cannot seek", func() {
+		files := newTestFiles()
+		conn := newConnection(files)
+
+		suite.Require().NoError(conn.Upload(context.Background(), io.MultiReader(bytes.NewReader(content)), path))
+
+		written, ok := files.file(path)
+		suite.True(ok)
+		suite.Equal(content, written)
+		suite.Equal([]string{"write", "write", "write", "write", "commit"}, files.ran())
+	})
+
+	suite.Run("should return an error if a file to download does not exist", func() {
+		conn := newConnection(newTestFiles())
+
+		err := conn.Download(context.Background(), path, io.Discard)
+		suite.EqualError(err, `winrm: failed to download C:\Temp\it's a file.bin: the file does not exist`)
+	})
+
+	suite.Run("should return an error if the hash of a download does not match", func() {
+		files := newTestFiles()
+		files.files[path] = content
+		files.corruptReads = true
+		conn := newConnection(files)
+
+		err := conn.Download(context.Background(), path, io.Discard)
+		suite.ErrorContains(err, "the SHA256 hash of the downloaded content does not match")
+	})
+}
+
+// progressContext returns a context with a transfer progress that appends to progress.
+func progressContext(progress *[]int64) context.Context {
+	return connection.WithTransferProgress(context.Background(), func(n int64) { *progress = append(*progress, n) })
+}
@@ -3,12 +3,16 @@
 //
 // Key Features:
 //   - Establishes WinRM connections based on provided configuration.
-//   - Handles authentication and secure communication with remote Windows hosts.
+//   - Handles authentication via Basic, NTLM, Kerberos or Negotiate and secure communication with remote Windows hosts.
 //   - Supports execution of commands including cmd and powershell commands.
+//   - Runs PowerShell commands in a persistent runspace pool via the PowerShell Remoting Protocol (MS-PSRP).
 package winrm
 
 import (
+	"bytes"
 	"context"
+	"io"
+	"strings"
 
 	"github.com/d-strobel/gowindows/connection"
 	"github.com/d-strobel/gowindows/parsing"
@@ -18,18 +22,38 @@ import (
 // Connection represents a WinRM connection.
 type Connection struct {
 	Client *winrm.Client
+
+	// chunkSize is the number of bytes transferred per command by Upload and Download.
+	chunkSize int
 }
 
 // NewConnection creates a new WinRM client based on the provided WinRM configuration.
 func NewConnection(config *Config) (*Connection, error) {
+	client, _, err := newClient(config)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Connection{Client: client, chunkSize: config.TransferChunkSize}, nil
+}
+
+// newClient creates a new WinRM client based on the provided WinRM configuration.
+// It returns the HTTP transport of the client as well, so requests can be sent that the client does not provide.
+func newClient(config *Config) (*winrm.Client, winrm.Transporter, error) {
 	// Validate configuration
 	if err := config.validate(); err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
 	// Set default values
 	if err := config.defaults(); err != nil {
-		return nil, err
+		return nil, nil, err
+	}
+
+	// Load the certificates
+	caCert, clientCert, clientKey, err := config.certificates()
+	if err != nil {
+		return nil, nil, err
 	}
 
 	// WinRM connection
@@ -38,19 +62,28 @@ func NewConnection(config *Config) (*Connection, error) {
 		config.Port,
 		config.UseTLS,
 		config.Insecure,
-		nil, // CA certificate
-		nil, // Client Certificate
-		nil, // Client Key
+		caCert,
+		clientCert,
+		clientKey,
 		config.Timeout,
 	)
+	winRMEndpoint.TLSServerName = config.TLSServerName
+
+	// The transport of the authentication method.
+	transport, err := config.transporter()
+	if err != nil {
+		return nil, nil, err
+	}
+	params := *winrm.DefaultParameters
+	params.TransportDecorator = func() winrm.Transporter { return transport }
 
 	// Create a new WinRM client.
-	client, err := winrm.NewClient(winRMEndpoint, config.Username, config.Password)
+	client, err := winrm.NewClientWithParameters(winRMEndpoint, config.Username, config.Password, &params)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
-	return &Connection{Client: client}, nil
+	return client, transport, nil
 }
 
 // Close closes the WinRM connection.
@@ -72,17 +105,57 @@ func (c *Connection) RunWithPowershell(ctx context.Context, cmd string) (connect
 }
 
 // Run runs a command using the configured WinRM connection and context.
-// It returns a connection.CMDResult object, including stdout and stderr.
+// It returns a connection.CMDResult object, including stdout, stderr and the exit code.
 func (c *Connection) Run(ctx context.Context, cmd string) (connection.CmdResult, error) {
-	var r connection.CmdResult
+	var stdout, stderr bytes.Buffer
 
-	stdout, stderr, _, err := c.Client.RunWithContextWithString(ctx, cmd, "")
+	exitCode, err := c.Stream(ctx, cmd, connection.Streams{Stdout: &stdout, Stderr: &stderr})
 	if err != nil {
-		return r, err
+		return connection.CmdResult{}, err
 	}
 
-	r.StdErr = stderr
-	r.StdOut = stdout
+	return connection.CmdResult{
+		StdOut:   stdout.String(),
+		StdErr:   stderr.String(),
+		ExitCode: exitCode,
+	}, nil
+}
+
+// StreamWithPowershell runs a command using the configured WinRM connection and context via Powershell.
+// It writes stdout and stderr to the streams as the data arrives and returns the exit code of the command.
+func (c *Connection) StreamWithPowershell(ctx context.Context, cmd string, streams connection.Streams) (int, error) {
+	// Prepare powershell command.
+	pwshCmd, err := parsing.EncodePwshCmd(cmd)
+	if err != nil {
+		return 0, err
+	}
+
+	return c.Stream(ctx, pwshCmd, streams)
+}
+
+// Stream runs a command using the configured WinRM connection and context.
+// It writes stdout and stderr to the streams as the data arrives and returns the exit code of the command.
+// If the context is done, the command is canceled on the remote host.
+func (c *Connection) Stream(ctx context.Context, cmd string, streams connection.Streams) (int, error) {
+	stdout := streams.Stdout
+	if stdout == nil {
+		stdout = io.Discard
+	}
+	stderr := streams.Stderr
+	if stderr == nil {
+		stderr = io.Discard
+	}
+
+	// Without input, the input stream is closed right away, so the command does not wait for input.
+	stdin := streams.Stdin
+	if stdin == nil {
+		stdin = strings.NewReader("")
+	}
+
+	exitCode, err := c.Client.RunWithContextWithInput(ctx, cmd, stdout, stderr, stdin)
+	if err != nil {
+		return 0, err
+	}
 
-	return r, nil
+	return exitCode, nil
 }
@@ -1,8 +1,15 @@
 package winrm
 
 import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"sync"
 	"testing"
 
+	"github.com/d-strobel/gowindows/connection"
 	"github.com/stretchr/testify/suite"
 )
 
@@ -14,3 +21,139 @@ type WinRMUnitTestSuite struct {
 func TestWinRMUnitTestSuite(t *testing.T) {
 	suite.Run(t, &WinRMUnitTestSuite{})
 }
+
+func (suite *WinRMUnitTestSuite) TestRun() {
+	suite.Run("should return stdout, stderr and the exit code", func() {
+		server := newTestServer(suite.T(), func(cmd string, stdin io.Reader, stdout io.Writer, stderr io.Writer) int {
+			fmt.Fprintf(stdout, "stdout of %s", cmd)
+			fmt.Fprint(stderr, "stderr")
+			return 0
+		})
+		conn, err := NewConnection(server.config())
+		suite.Require().NoError(err)
+
+		result, err := conn.Run(context.Background(), "whoami")
+		suite.NoError(err)
+		suite.Equal(connection.CmdResult{StdOut: "stdout of whoami", StdErr: "stderr", ExitCode: 0}, result)
+	})
+
+	suite.Run("should return a non-zero exit code without an error", func() {
+		server := newTestServer(suite.T(), func(cmd string, stdin io.Reader, stdout io.Writer, stderr io.Writer) int {
+			return 3
+		})
+		conn, err := NewConnection(server.config())
+		suite.Require().NoError(err)
+
+		result, err := conn.Run(context.Background(), "exit 3")
+		suite.NoError(err)
+		suite.Equal(3, result.ExitCode)
+		suite.Empty(result.StdErr)
+	})
+}
+
+func (suite *WinRMUnitTestSuite) TestStream() {
+	suite.Run("should write the output to the streams", func() {
+		server := newTestServer(suite.T(), func(cmd string, stdin io.Reader, stdout io.Writer, stderr io.Writer) int {
+			fmt.Fprint(stdout, "first\r\nsecond\r\n")
+			fmt.Fprint(stderr, "error")
+			return 0
+		})
+		conn, err := NewConnection(server.config())
+		suite.Require().NoError(err)
+
+		var lines []string
+		stdout := connection.NewLineWriter(func(line string) { lines = append(lines, line) })
+		var stderr strings.Builder
+
+		exitCode, err := conn.Stream(context.Background(), "Get-Content", connection.Streams{Stdout: stdout, Stderr: &stderr})
+		suite.NoError(err)
+		suite.Equal(0, exitCode)
+		suite.Equal([]string{"first", "second"}, lines)
+		suite.Equal("error", stderr.String())
+	})
+
+	suite.Run("should discard the output of nil streams", func() {
+		server := newTestServer(suite.T(), func(cmd string, stdin io.Reader, stdout io.Writer, stderr io.Writer) int {
+			fmt.Fprint(stdout, "stdout")
+			fmt.Fprint(stderr, "stderr")
+			return 2
+		})
+		conn, err := NewConnection(server.config())
+		suite.Require().NoError(err)
+
+		exitCode, err := conn.Stream(context.Background(), "whoami", connection.Streams{})
+		suite.NoError(err)
+		suite.Equal(2, exitCode)
+	})
+}
+
+func (suite *WinRMUnitTestSuite) TestRunWithInput() {
+	suite.Run("should send the input to the command", func() {
+		server := newTestServer(suite.T(), func(cmd string, stdin io.Reader, stdout io.Writer, stderr io.Writer) int {
+			input, err := io.ReadAll(stdin)
+			if err != nil {
+				return 1
+			}
+			fmt.Fprintf(stdout, "%s: %s", cmd, input)
+			return 0
+		})
+		conn, err := NewConnection(server.config())
+		suite.Require().NoError(err)
+
+		result, err := connection.RunWithInput(context.Background(), conn, "Read-Host", strings.NewReader("secret"))
+		suite.NoError(err)
+		suite.Equal(connection.CmdResult{StdOut: "Read-Host: secret"}, result)
+	})
+}
+
+func (suite *WinRMUnitTestSuite) TestDial() {
+	suite.Run("should establish the connections with the dial function", func() {
+		server := newTestServer(suite.T(), func(cmd string, stdin io.Reader, stdout io.Writer, stderr io.Writer) int {
+			fmt.Fprint(stdout, "tunnelled")
+			return 0
+		})
+
+		tcs := []struct {
+			description string
+			config      func(config *Config)
+		}{
+			{"Basic", func(config *Config) {}},
+			{"NTLM without encryption", func(config *Config) {
+				server.ntlm = true
+				config.Auth = AuthNTLM
+				config.DisableEncryption = true
+			}},
+			{"NTLM with encryption", func(config *Config) {
+				server.ntlm = true
+				server.encryption = true
+				config.Auth = AuthNTLM
+			}},
+		}
+
+		for _, tc := range tcs {
+			suite.T().Logf("test case: %s", tc.description)
+
+			var mu sync.Mutex
+			var dialed []string
+			config := server.config()
+			config.Dial = func(network, addr string) (net.Conn, error) {
+				mu.Lock()
+				dialed = append(dialed, addr)
+				mu.Unlock()
+				return net.Dial(network, addr)
+			}
+			tc.config(config)
+
+			conn, err := NewConnection(config)
+			suite.Require().NoError(err)
+
+			result, err := conn.Run(context.Background(), "whoami")
+			suite.Require().NoError(err)
+			suite.Equal("tunnelled", result.StdOut)
+
+			mu.Lock()
+			suite.Contains(dialed, server.server.Listener.Addr().String())
+			mu.Unlock()
+		}
+	})
+}
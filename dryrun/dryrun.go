@@ -0,0 +1,120 @@
+// Package dryrun provides the changes that the mutating functions of the Windows subpackages return in dry-run mode.
+// In dry-run mode, a mutating function does not run its PowerShell command.
+// It returns a *Change instead, that contains the command and the diff against the current state,
+// see gowindows.Client.Plan to get the change as value.
+package dryrun
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/d-strobel/gowindows/parsing"
+)
+
+// ErrDryRun is matched by a *Change with errors.Is.
+var ErrDryRun = errors.New("dry run, the change was not applied")
+
+// Change is a change that a mutating function would apply.
+// It is returned as error, so the caller does not continue with the zero value of the result.
+type Change struct {
+	// Operation is the name of the mutating function, e.g. windows.dns.RecordAUpdate.
+	Operation string
+
+	// Command is the PowerShell command that would run, with redacted sensitive values.
+	Command string
+
+	// Diff contains the fields of the resource that would change.
+	// Fields that are only known after the change, like a SID of a new user, are not part of the diff.
+	Diff []FieldDiff
+}
+
+// FieldDiff is a field of a resource with its current and its new value.
+type FieldDiff struct {
+	// Field is the name of the field, nested fields are separated by a dot, e.g. SID.Value.
+	Field string
+	Old   any
+	New   any
+}
+
+// NewChange returns a new Change with the diff between the current and the desired state of a resource.
+// A resource that does not exist is the zero value.
+func NewChange[T any](operation string, cmd string, current T, desired T) *Change {
+	return &Change{
+		Operation: operation,
+		Command:   parsing.RedactPwshCmd(cmd),
+		Diff:      Compare(current, desired),
+	}
+}
+
+// Error returns the operation and the reason why it was not applied.
+func (c *Change) Error() string {
+	return fmt.Sprintf("%s: %s", c.Operation, ErrDryRun)
+}
+
+// Is reports whether target is ErrDryRun.
+func (c *Change) Is(target error) bool {
+	return target == ErrDryRun
+}
+
+// String returns a human readable plan of the change.
+func (c *Change) String() string {
+	var b strings.Builder
+	b.WriteString(c.Operation)
+	b.WriteString("\n")
+
+	if len(c.Diff) == 0 {
+		b.WriteString("  no changes\n")
+	}
+	for _, d := range c.Diff {
+		fmt.Fprintf(&b, "  %s: %v => %v\n", d.Field, d.Old, d.New)
+	}
+
+	fmt.Fprintf(&b, "  command: %s", c.Command)
+	return b.String()
+}
+
+// stringerType is the type of fmt.Stringer.
+var stringerType = reflect.TypeFor[fmt.Stringer]()
+
+// Compare returns the exported fields of the structs current and desired that differ.
+// Nested structs are compared field by field, unless they implement fmt.Stringer like time.Time.
+func Compare[T any](current T, desired T) []FieldDiff {
+	c, d := reflect.ValueOf(current), reflect.ValueOf(desired)
+	if c.Kind() != reflect.Struct {
+		if reflect.DeepEqual(current, desired) {
+			return nil
+		}
+		return []FieldDiff{{Old: current, New: desired}}
+	}
+
+	return compareFields("", c, d)
+}
+
+// compareFields returns the exported fields of the struct values current and desired that differ.
+func compareFields(prefix string, current reflect.Value, desired reflect.Value) []FieldDiff {
+	var diffs []FieldDiff
+
+	t := current.Type()
+	for i := range t.NumField() {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+
+		name := prefix + f.Name
+		c, d := current.Field(i), desired.Field(i)
+
+		if f.Type.Kind() == reflect.Struct && !f.Type.Implements(stringerType) {
+			diffs = append(diffs, compareFields(name+".", c, d)...)
+			continue
+		}
+
+		if !reflect.DeepEqual(c.Interface(), d.Interface()) {
+			diffs = append(diffs, FieldDiff{Field: name, Old: c.Interface(), New: d.Interface()})
+		}
+	}
+
+	return diffs
+}
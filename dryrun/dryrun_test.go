@@ -0,0 +1,99 @@
+package dryrun
+
+import (
+	"errors"
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/d-strobel/gowindows/parsing"
+	"github.com/stretchr/testify/suite"
+)
+
+// Unit test suite for the dry-run changes.
+type DryRunUnitTestSuite struct {
+	suite.Suite
+}
+
+func TestDryRunUnitTestSuite(t *testing.T) {
+	suite.Run(t, &DryRunUnitTestSuite{})
+}
+
+type sid struct {
+	Value string
+}
+
+type resource struct {
+	Name       string
+	Addresses  []netip.Addr
+	Expires    parsing.DotnetTime
+	TimeToLive time.Duration
+	SID        sid
+	internal   string
+}
+
+func (suite *DryRunUnitTestSuite) TestCompare() {
+	expires := time.Date(2030, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+	tcs := []struct {
+		description  string
+		current      resource
+		desired      resource
+		expectedDiff []FieldDiff
+	}{
+		{
+			"assert no diff",
+			resource{Name: "test", Addresses: []netip.Addr{netip.MustParseAddr("10.0.0.1")}},
+			resource{Name: "test", Addresses: []netip.Addr{netip.MustParseAddr("10.0.0.1")}},
+			nil,
+		},
+		{
+			"assert changed fields",
+			resource{Name: "test", TimeToLive: time.Hour},
+			resource{Name: "test", TimeToLive: 2 * time.Hour, Addresses: []netip.Addr{netip.MustParseAddr("10.0.0.1")}},
+			[]FieldDiff{
+				{Field: "Addresses", Old: []netip.Addr(nil), New: []netip.Addr{netip.MustParseAddr("10.0.0.1")}},
+				{Field: "TimeToLive", Old: time.Hour, New: 2 * time.Hour},
+			},
+		},
+		{
+			"assert nested fields",
+			resource{SID: sid{Value: "S-1-5-32-545"}, Expires: parsing.DotnetTime{Time: expires}},
+			resource{internal: "ignored"},
+			[]FieldDiff{
+				{Field: "Expires", Old: parsing.DotnetTime{Time: expires}, New: parsing.DotnetTime{}},
+				{Field: "SID.Value", Old: "S-1-5-32-545", New: ""},
+			},
+		},
+	}
+
+	for _, tc := range tcs {
+		suite.T().Logf("test case: %s", tc.description)
+		suite.Equal(tc.expectedDiff, Compare(tc.current, tc.desired))
+	}
+}
+
+func (suite *DryRunUnitTestSuite) TestChange() {
+	suite.Run("should match ErrDryRun", func() {
+		var err error = NewChange("windows.dns.RecordAUpdate", "Set-DnsServerResourceRecord", resource{}, resource{})
+		suite.ErrorIs(err, ErrDryRun)
+		suite.EqualError(err, "windows.dns.RecordAUpdate: dry run, the change was not applied")
+
+		var change *Change
+		suite.Require().True(errors.As(err, &change))
+		suite.Empty(change.Diff)
+	})
+
+	suite.Run("should redact the command", func() {
+		change := NewChange("windows.local.accounts.UserUpdate", "Set-LocalUser -Password <#sensitive#>'secret'", resource{}, resource{})
+		suite.Equal("Set-LocalUser -Password <#sensitive#>'***'", change.Command)
+	})
+
+	suite.Run("should return a plan", func() {
+		change := NewChange("windows.dns.RecordAUpdate", "Set-DnsServerResourceRecord", resource{Name: "test", TimeToLive: time.Hour}, resource{Name: "test", TimeToLive: 2 * time.Hour})
+		suite.Equal("windows.dns.RecordAUpdate\n  TimeToLive: 1h0m0s => 2h0m0s\n  command: Set-DnsServerResourceRecord", change.String())
+
+		change = NewChange("windows.dns.RecordADelete", "Remove-DnsServerResourceRecord", resource{}, resource{})
+		suite.Equal("windows.dns.RecordADelete\n  no changes\n  command: Remove-DnsServerResourceRecord", change.String())
+	})
+}
@@ -0,0 +1,139 @@
+// Package acctest provides the connections of the acceptance tests.
+//
+// With GOWINDOWS_TEST_HOST set, the acceptance tests run against the Vagrant machines.
+// If GOWINDOWS_TEST_RECORD is set as well, the commands and their results are recorded to the fixture files
+// in the testdata directory of the package.
+// Without GOWINDOWS_TEST_HOST, the acceptance tests replay these fixture files and are skipped if they were not recorded.
+// The recording and the replay scrub the same secrets, so the replay needs GOWINDOWS_TEST_PASSWORD of the recording.
+//
+// The acceptance tests of the windows/dns package need the DNS server role with the primary zone TestZone
+// and the ones of the windows/dhcp package the DHCP server role, see the provisioning of the Vagrantfile.
+package acctest
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"github.com/d-strobel/gowindows/connection"
+	"github.com/d-strobel/gowindows/connection/ssh"
+	"github.com/d-strobel/gowindows/connection/winrm"
+)
+
+// Environment variable that enables the recording of the fixture files.
+const EnvRecord = "GOWINDOWS_TEST_RECORD"
+
+// TestZone is the DNS zone of the acceptance tests.
+const TestZone = "gowindows.test"
+
+// Fixture files of the WinRM and SSH connection.
+var (
+	WinRMFixture = filepath.Join("testdata", "acc_winrm.json")
+	SSHFixture   = filepath.Join("testdata", "acc_ssh.json")
+)
+
+// Connections returns a WinRM and an SSH connection for the acceptance tests.
+// The connections run against the Vagrant machines if GOWINDOWS_TEST_HOST is set and replay the fixture files otherwise.
+// The test is skipped if it runs against a Vagrant machine with -short or if the fixture files are missing.
+func Connections(t *testing.T) []connection.Connection {
+	t.Helper()
+
+	if os.Getenv("GOWINDOWS_TEST_HOST") == "" {
+		return replayConnections(t)
+	}
+
+	if testing.Short() {
+		t.Skip("acceptance tests against a Windows machine are skipped with -short")
+	}
+	return liveConnections(t)
+}
+
+// replayConnections returns connections that replay the fixture files.
+func replayConnections(t *testing.T) []connection.Connection {
+	t.Helper()
+
+	var conns []connection.Connection
+	for _, path := range []string{WinRMFixture, SSHFixture} {
+		conn, err := connection.NewReplayConnection(path, secrets()...)
+		if errors.Is(err, os.ErrNotExist) {
+			t.Skipf("GOWINDOWS_TEST_HOST not set and no recording at %s", path)
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		conns = append(conns, conn)
+	}
+	return conns
+}
+
+// liveConnections returns connections to the Vagrant machine that record the fixture files if GOWINDOWS_TEST_RECORD is set.
+func liveConnections(t *testing.T) []connection.Connection {
+	t.Helper()
+
+	host := os.Getenv("GOWINDOWS_TEST_HOST")
+	username := requireEnv(t, "GOWINDOWS_TEST_USERNAME")
+	password := requireEnv(t, "GOWINDOWS_TEST_PASSWORD")
+
+	winRMPort, err := strconv.Atoi(os.Getenv("GOWINDOWS_TEST_WINRM_HTTP_PORT"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sshPort, err := strconv.Atoi(os.Getenv("GOWINDOWS_TEST_SSH_PORT"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Setup WinRM connection
+	winRMConn, err := winrm.NewConnection(&winrm.Config{
+		Host:     host,
+		Username: username,
+		Password: password,
+		UseTLS:   false,
+		Insecure: true,
+		Port:     winRMPort,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Setup SSH connection
+	sshConn, err := ssh.NewConnection(&ssh.Config{
+		Host:     host,
+		Username: username,
+		Password: password,
+		Port:     sshPort,
+		Insecure: true,
+	})
+	if err != nil {
+		winRMConn.Close()
+		t.Fatal(err)
+	}
+
+	if os.Getenv(EnvRecord) == "" {
+		return []connection.Connection{winRMConn, sshConn}
+	}
+
+	return []connection.Connection{
+		connection.NewRecordingConnection(winRMConn, WinRMFixture, secrets()...),
+		connection.NewRecordingConnection(sshConn, SSHFixture, secrets()...),
+	}
+}
+
+// secrets returns the secrets that are scrubbed from the fixture files, the password of the Vagrant machine.
+func secrets() []string {
+	return []string{os.Getenv("GOWINDOWS_TEST_PASSWORD")}
+}
+
+// requireEnv returns the value of the environment variable and fails the test if it is not set.
+func requireEnv(t *testing.T, key string) string {
+	t.Helper()
+
+	value := os.Getenv(key)
+	if value == "" {
+		t.Fatalf("Environment variable not set: %s", key)
+	}
+	return value
+}
@@ -0,0 +1,38 @@
+package pwsh
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/d-strobel/gowindows/dryrun"
+	"github.com/d-strobel/gowindows/winerror"
+)
+
+// Plan returns the *dryrun.Change of a mutating function in dry-run mode.
+// current and err are the results of the matching read function.
+// The error of the read function is returned, e.g. one that matches winerror.ErrNotFound,
+// since the mutating function would fail as well without the resource.
+// desired returns the state of the resource after the change.
+func Plan[T any](operation string, cmd string, current T, err error, desired func(T) T) error {
+	if err != nil {
+		return fmt.Errorf("%s: failed to read the current state: %w", operation, err)
+	}
+
+	return dryrun.NewChange(operation, cmd, current, desired(current))
+}
+
+// PlanCreate returns the *dryrun.Change of a create function in dry-run mode.
+// A resource that does not exist yet is the zero value.
+// If the resource exists already, the create function would fail,
+// so PlanCreate returns an error that wraps winerror.ErrAlreadyExists instead of a diff against the existing resource.
+func PlanCreate[T any](operation string, cmd string, current T, err error, desired func(T) T) error {
+	if err == nil {
+		return fmt.Errorf("%s: the resource %w", operation, winerror.ErrAlreadyExists)
+	}
+	if !errors.Is(err, winerror.ErrNotFound) {
+		return fmt.Errorf("%s: failed to read the current state: %w", operation, err)
+	}
+
+	var zero T
+	return dryrun.NewChange(operation, cmd, zero, desired(zero))
+}
@@ -0,0 +1,152 @@
+// Package pwsh provides the shared engine that runs PowerShell commands for the Windows subpackages
+// and unmarshals their JSON output into Go types.
+package pwsh
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+
+	"github.com/d-strobel/gowindows/connection"
+	"github.com/d-strobel/gowindows/parsing"
+	"github.com/d-strobel/gowindows/winerror"
+)
+
+// DecodeErrFunc decodes the stderr of a PowerShell command, e.g. a CLIXML error, into a human readable string.
+type DecodeErrFunc func(string) (string, error)
+
+// Run runs a PowerShell command against a Windows system, handles the command results,
+// and unmarshals the JSON output into v.
+//
+// Errors of the connection are returned as a *winerror.WinError that matches winerror.ErrTransport.
+// A non-empty stderr is decoded with decodeErr and returned as a *winerror.WinError
+// that carries the PowerShell error records. A non-zero exit code is a failure, even if stderr is empty.
+// An empty stdout leaves v untouched.
+// ConvertTo-Json renders a single object as a JSON object and multiple objects as a JSON array,
+// so a single JSON object is unmarshaled into a slice with one element and a JSON array
+// with one element is unmarshaled into a single object.
+func Run[T any](ctx context.Context, conn connection.Connection, decodeErr DecodeErrFunc, cmd string, v *T) error {
+	return RunWithInput(ctx, conn, decodeErr, cmd, nil, v)
+}
+
+// RunWithInput runs a PowerShell command like Run and sends stdin to the standard input of the command.
+// It keeps secrets out of the command line of the process, e.g. a password read with $input.
+// A nil stdin is the same as calling Run.
+func RunWithInput[T any](ctx context.Context, conn connection.Connection, decodeErr DecodeErrFunc, cmd string, stdin io.Reader, v *T) error {
+	// Run the command
+	var result connection.CmdResult
+	var err error
+	if stdin == nil {
+		result, err = conn.RunWithPowershell(ctx, cmd)
+	} else {
+		result, err = connection.RunWithPowershellInput(ctx, conn, cmd, stdin)
+	}
+
+	// Sensitive values must not show up in the error.
+	redactedCmd := parsing.RedactPwshCmd(cmd)
+
+	if err != nil {
+		return &winerror.WinError{Err: winerror.NewTransportError(err), Command: redactedCmd}
+	}
+
+	// Handle stderr
+	if result.StdErr != "" {
+		stderr, err := decodeErr(result.StdErr)
+		if err != nil {
+			return err
+		}
+
+		// PowerShell error messages contain the failed statement of the command
+		// and can contain the sensitive values without their marker.
+		values := parsing.PwshSensitiveValues(cmd)
+		return &winerror.WinError{
+			Err:      errors.New(parsing.RedactPwshSensitiveValues(stderr, values)),
+			Command:  redactedCmd,
+			ExitCode: result.ExitCode,
+			Records:  errorRecords(result.StdErr, stderr, values),
+		}
+	}
+
+	// Native executables often fail without writing to stderr.
+	if result.ExitCode != 0 {
+		return &winerror.WinError{
+			Err:      fmt.Errorf("the command exited with code %d", result.ExitCode),
+			Command:  redactedCmd,
+			ExitCode: result.ExitCode,
+		}
+	}
+
+	return Unmarshal([]byte(result.StdOut), v)
+}
+
+// errorRecords parses the PowerShell error records from the stderr of a command.
+// If stderr is not a CLIXML document, the records are parsed from the decoded stderr.
+// The sensitive values of the command are redacted from all fields of the records.
+func errorRecords(stderr string, decoded string, values []string) []parsing.ErrorRecord {
+	records, err := parsing.DecodeCliXmlErrRecords(stderr)
+	if err != nil {
+		records = parsing.ParseErrorRecords(decoded)
+	}
+
+	redact := func(s *string) {
+		*s = parsing.RedactPwshSensitiveValues(*s, values)
+	}
+	for i := range records {
+		r := &records[i]
+		redact(&r.FullyQualifiedErrorId)
+		redact(&r.CategoryInfo.Category)
+		redact(&r.CategoryInfo.Activity)
+		redact(&r.CategoryInfo.Reason)
+		redact(&r.CategoryInfo.TargetName)
+		redact(&r.CategoryInfo.TargetType)
+		redact(&r.Exception.Type)
+		redact(&r.Exception.Message)
+		redact(&r.ScriptPosition.ScriptName)
+		redact(&r.ScriptPosition.Text)
+	}
+
+	return records
+}
+
+// Unmarshal unmarshals the JSON output of a PowerShell command into v.
+// See Run for the handling of empty output and the single-object-vs-array ambiguity.
+func Unmarshal[T any](data []byte, v *T) error {
+	data = bytes.TrimSpace(data)
+	if len(data) == 0 {
+		return nil
+	}
+
+	// Types that implement their own unmarshaling are responsible for the ambiguity themselves.
+	t := reflect.TypeFor[T]()
+	if reflect.PointerTo(t).Implements(reflect.TypeFor[json.Unmarshaler]()) {
+		return json.Unmarshal(data, v)
+	}
+
+	switch {
+	// Wrap a single object into an array.
+	case t.Kind() == reflect.Slice && data[0] == '{':
+		data = append(append([]byte{'['}, data...), ']')
+
+	// Unwrap an array with a single object.
+	case t.Kind() == reflect.Struct && data[0] == '[':
+		var objects []json.RawMessage
+		if err := json.Unmarshal(data, &objects); err != nil {
+			return err
+		}
+
+		switch len(objects) {
+		case 0:
+			return nil
+		case 1:
+			data = objects[0]
+		default:
+			return fmt.Errorf("expected a single object but got %d objects", len(objects))
+		}
+	}
+
+	return json.Unmarshal(data, v)
+}
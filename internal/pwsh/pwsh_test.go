@@ -0,0 +1,292 @@
+package pwsh
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/d-strobel/gowindows/connection"
+	mockConnection "github.com/d-strobel/gowindows/connection/mocks"
+	"github.com/d-strobel/gowindows/parsing"
+	"github.com/d-strobel/gowindows/winerror"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+)
+
+// Unit test suite for the PowerShell engine
+type PwshUnitTestSuite struct {
+	suite.Suite
+}
+
+func TestPwshUnitTestSuite(t *testing.T) {
+	suite.Run(t, &PwshUnitTestSuite{})
+}
+
+// Fixture objects
+type testObject struct {
+	Name string `json:"Name"`
+}
+
+func (suite *PwshUnitTestSuite) TestRun() {
+	suite.T().Parallel()
+
+	cmd := "Get-LocalUser | ConvertTo-Json -Compress"
+	noopDecode := func(s string) (string, error) { return s, nil }
+
+	suite.Run("should unmarshal stdout", func() {
+		ctx := context.Background()
+		mockConn := mockConnection.NewMockConnection(suite.T())
+		mockConn.EXPECT().
+			RunWithPowershell(ctx, cmd).
+			Return(connection.CmdResult{StdOut: `{"Name":"test"}`}, nil)
+
+		var o testObject
+		err := Run(ctx, mockConn, noopDecode, cmd, &o)
+		suite.NoError(err)
+		suite.Equal(testObject{Name: "test"}, o)
+	})
+
+	suite.Run("should return the connection error", func() {
+		ctx := context.Background()
+		mockConn := mockConnection.NewMockConnection(suite.T())
+		expectedErr := errors.New("connection refused")
+		mockConn.EXPECT().
+			RunWithPowershell(ctx, cmd).
+			Return(connection.CmdResult{}, expectedErr)
+
+		var o testObject
+		err := Run(ctx, mockConn, noopDecode, cmd, &o)
+		suite.ErrorIs(err, expectedErr)
+	})
+
+	suite.Run("should return the decoded stderr", func() {
+		ctx := context.Background()
+		mockConn := mockConnection.NewMockConnection(suite.T())
+		stderr := `#< CLIXML
+<Objs Version="1.1.0.1" xmlns="http://schemas.microsoft.com/powershell/2004/04"><S S="Error">User not found._x000D__x000A_</S>` +
+			`<S S="Error">    + CategoryInfo          : ObjectNotFound: (test:String) [Get-LocalUser], UserNotFoundException_x000D__x000A_</S>` +
+			`<S S="Error">    + FullyQualifiedErrorId : UserNotFound,Microsoft.PowerShell.Commands.GetLocalUserCommand_x000D__x000A_</S></Objs>`
+		mockConn.EXPECT().
+			RunWithPowershell(ctx, cmd).
+			Return(connection.CmdResult{StdOut: `{"Name":"test"}`, StdErr: stderr}, nil)
+
+		var o testObject
+		err := Run(ctx, mockConn, parsing.DecodeCliXmlErr, cmd, &o)
+		suite.ErrorContains(err, "User not found.")
+		suite.Equal(testObject{}, o)
+		suite.Equal(cmd, winerror.UnwrapCommand(err))
+		suite.True(winerror.HasErrorId(err, "UserNotFound"))
+		suite.True(winerror.HasCategory(err, "ObjectNotFound"))
+	})
+
+	suite.Run("should parse the error records from an unformatted stderr", func() {
+		ctx := context.Background()
+		mockConn := mockConnection.NewMockConnection(suite.T())
+		stderr := "Get-LocalUser : User not found.\n    + FullyQualifiedErrorId : UserNotFound,Microsoft.PowerShell.Commands.GetLocalUserCommand"
+		mockConn.EXPECT().
+			RunWithPowershell(ctx, cmd).
+			Return(connection.CmdResult{StdErr: stderr}, nil)
+
+		var o testObject
+		err := Run(ctx, mockConn, noopDecode, cmd, &o)
+		suite.EqualError(err, stderr)
+		suite.Equal([]parsing.ErrorRecord{{
+			FullyQualifiedErrorId: "UserNotFound,Microsoft.PowerShell.Commands.GetLocalUserCommand",
+			Exception:             parsing.ExceptionInfo{Message: "User not found."},
+		}}, winerror.UnwrapRecords(err))
+	})
+
+	suite.Run("should return an error on a non-zero exit code without stderr", func() {
+		ctx := context.Background()
+		mockConn := mockConnection.NewMockConnection(suite.T())
+		mockConn.EXPECT().
+			RunWithPowershell(ctx, cmd).
+			Return(connection.CmdResult{StdOut: `{"Name":"test"}`, ExitCode: 3}, nil)
+
+		var o testObject
+		err := Run(ctx, mockConn, noopDecode, cmd, &o)
+		suite.EqualError(err, "the command exited with code 3")
+		suite.Equal(testObject{}, o)
+
+		var winErr *winerror.WinError
+		suite.Require().ErrorAs(err, &winErr)
+		suite.Equal(3, winErr.ExitCode)
+		suite.Equal(cmd, winErr.Command)
+	})
+
+	suite.Run("should return the exit code with the decoded stderr", func() {
+		ctx := context.Background()
+		mockConn := mockConnection.NewMockConnection(suite.T())
+		mockConn.EXPECT().
+			RunWithPowershell(ctx, cmd).
+			Return(connection.CmdResult{StdErr: "error", ExitCode: 1}, nil)
+
+		var o testObject
+		err := Run(ctx, mockConn, noopDecode, cmd, &o)
+		suite.EqualError(err, "error")
+
+		var winErr *winerror.WinError
+		suite.Require().ErrorAs(err, &winErr)
+		suite.Equal(1, winErr.ExitCode)
+	})
+
+	suite.Run("should redact the sensitive values from the error", func() {
+		ctx := context.Background()
+		mockConn := mockConnection.NewMockConnection(suite.T())
+		sensitiveCmd := parsing.NewPwshCommand("Set-Secret").
+			Param("Value", parsing.PwshSensitive("ZQXWJK")).
+			Param("Description", strings.Repeat("x", 100)).
+			String()
+		stderr := "Set-Secret : Cannot process the value ZQXWJK.\n" +
+			"At line:1 char:1\n" +
+			"+ Set-Secret -Value <#sensitive#>'ZQXWJK' -Description 'xxxxxxxxxxxxxxxxxxxx ...\n" +
+			"+ ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~\n" +
+			"    + CategoryInfo          : InvalidArgument: (ZQXWJK:String) [Set-Secret], ArgumentException\n" +
+			"    + FullyQualifiedErrorId : InvalidValue,Set-Secret"
+		mockConn.EXPECT().
+			RunWithPowershell(ctx, sensitiveCmd).
+			Return(connection.CmdResult{StdErr: stderr, ExitCode: 1}, nil)
+
+		var o testObject
+		err := Run(ctx, mockConn, noopDecode, sensitiveCmd, &o)
+		suite.Require().Error(err)
+		suite.NotContains(err.Error(), "ZQXWJK")
+		suite.Contains(err.Error(), "Cannot process the value ***.")
+
+		records := winerror.UnwrapRecords(err)
+		suite.Require().Len(records, 1)
+		suite.Equal("Cannot process the value ***.", records[0].Exception.Message)
+		suite.Equal("***", records[0].CategoryInfo.TargetName)
+		suite.NotContains(records[0].ScriptPosition.Text, "ZQXWJK")
+	})
+
+	suite.Run("should return the decoding error", func() {
+		ctx := context.Background()
+		mockConn := mockConnection.NewMockConnection(suite.T())
+		expectedErr := errors.New("invalid clixml")
+		mockConn.EXPECT().
+			RunWithPowershell(ctx, cmd).
+			Return(connection.CmdResult{StdErr: "error"}, nil)
+
+		var o testObject
+		err := Run(ctx, mockConn, func(s string) (string, error) { return "", expectedErr }, cmd, &o)
+		suite.ErrorIs(err, expectedErr)
+	})
+}
+
+func (suite *PwshUnitTestSuite) TestRunWithInput() {
+	suite.T().Parallel()
+
+	cmd := "$input | Select-Object -First 1 | ConvertTo-Json -Compress"
+	noopDecode := func(s string) (string, error) { return s, nil }
+
+	suite.Run("should send the input to the command", func() {
+		ctx := context.Background()
+		mockConn := mockConnection.NewMockConnection(suite.T())
+		mockConn.EXPECT().
+			StreamWithPowershell(ctx, cmd, mock.Anything).
+			RunAndReturn(func(ctx context.Context, cmd string, streams connection.Streams) (int, error) {
+				input, err := io.ReadAll(streams.Stdin)
+				if err != nil {
+					return 0, err
+				}
+				fmt.Fprintf(streams.Stdout, `{"Name":%q}`, input)
+				return 0, nil
+			})
+
+		var o testObject
+		err := RunWithInput(ctx, mockConn, noopDecode, cmd, strings.NewReader("secret"), &o)
+		suite.NoError(err)
+		suite.Equal(testObject{Name: "secret"}, o)
+	})
+
+	suite.Run("should return the stderr of the command", func() {
+		ctx := context.Background()
+		mockConn := mockConnection.NewMockConnection(suite.T())
+		mockConn.EXPECT().
+			StreamWithPowershell(ctx, cmd, mock.Anything).
+			RunAndReturn(func(ctx context.Context, cmd string, streams connection.Streams) (int, error) {
+				fmt.Fprint(streams.Stderr, "error")
+				return 1, nil
+			})
+
+		var o testObject
+		err := RunWithInput(ctx, mockConn, noopDecode, cmd, strings.NewReader("secret"), &o)
+		suite.EqualError(err, "error")
+		suite.Equal(cmd, winerror.UnwrapCommand(err))
+	})
+
+	suite.Run("should run the command without input", func() {
+		ctx := context.Background()
+		mockConn := mockConnection.NewMockConnection(suite.T())
+		mockConn.EXPECT().
+			RunWithPowershell(ctx, cmd).
+			Return(connection.CmdResult{StdOut: `{"Name":"test"}`}, nil)
+
+		var o testObject
+		err := RunWithInput(ctx, mockConn, noopDecode, cmd, nil, &o)
+		suite.NoError(err)
+		suite.Equal(testObject{Name: "test"}, o)
+	})
+}
+
+func (suite *PwshUnitTestSuite) TestUnmarshal() {
+	suite.T().Parallel()
+
+	suite.Run("should leave the object untouched on empty output", func() {
+		o := testObject{Name: "untouched"}
+		err := Unmarshal([]byte(" \r\n"), &o)
+		suite.NoError(err)
+		suite.Equal(testObject{Name: "untouched"}, o)
+	})
+
+	suite.Run("should unmarshal a single object into a slice", func() {
+		var o []testObject
+		err := Unmarshal([]byte(`{"Name":"test"}`), &o)
+		suite.NoError(err)
+		suite.Equal([]testObject{{Name: "test"}}, o)
+	})
+
+	suite.Run("should unmarshal an array into a slice", func() {
+		var o []testObject
+		err := Unmarshal([]byte(`[{"Name":"test1"},{"Name":"test2"}]`), &o)
+		suite.NoError(err)
+		suite.Equal([]testObject{{Name: "test1"}, {Name: "test2"}}, o)
+	})
+
+	suite.Run("should unmarshal an array with a single object into an object", func() {
+		var o testObject
+		err := Unmarshal([]byte(`[{"Name":"test"}]`), &o)
+		suite.NoError(err)
+		suite.Equal(testObject{Name: "test"}, o)
+	})
+
+	suite.Run("should leave the object untouched on an empty array", func() {
+		var o testObject
+		err := Unmarshal([]byte(`[]`), &o)
+		suite.NoError(err)
+		suite.Equal(testObject{}, o)
+	})
+
+	suite.Run("should return an error on an array with multiple objects", func() {
+		var o testObject
+		err := Unmarshal([]byte(`[{"Name":"test1"},{"Name":"test2"}]`), &o)
+		suite.EqualError(err, "expected a single object but got 2 objects")
+	})
+
+	suite.Run("should use the unmarshaler of the type", func() {
+		var o parsing.CimClassKeyVal
+		err := Unmarshal([]byte(`["HostNameAlias = \"test.local.\""]`), &o)
+		suite.NoError(err)
+		suite.Equal(parsing.CimClassKeyVal{"HostNameAlias": "test.local."}, o)
+	})
+
+	suite.Run("should return an error on invalid json", func() {
+		var o testObject
+		err := Unmarshal([]byte(`{"Name":`), &o)
+		suite.Error(err)
+	})
+}
@@ -0,0 +1,149 @@
+// Package pwshtest provides the fuzz test of the PowerShell command builders of the Windows subpackages.
+// It asserts that user input can never escape its PowerShell argument.
+package pwshtest
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/d-strobel/gowindows/parsing"
+)
+
+// Seeds is the seed corpus of FuzzQuoting.
+var Seeds = []string{
+	"test",
+	"'",
+	"'; Remove-Item -Path 'C:\\' -Recurse -Force; '",
+	"‘’‚‛",
+	"$(whoami)",
+	"`\"",
+	"line\r\nbreak",
+	"\x00\xff",
+}
+
+// Builders maps the name of a command builder to a function that renders its command
+// with the value in every string argument.
+type Builders map[string]func(value string) string
+
+// FuzzQuoting fuzzes the builders with the Seeds, see VerifyQuoting.
+func FuzzQuoting(f *testing.F, builders Builders) {
+	for _, seed := range Seeds {
+		f.Add(seed)
+	}
+
+	f.Fuzz(func(t *testing.T, value string) {
+		// Empty values are omitted by some builders.
+		if value == "" {
+			return
+		}
+
+		for name, build := range builders {
+			if err := VerifyQuoting(build, value); err != nil {
+				t.Fatalf("%s: %s", name, err)
+			}
+		}
+	})
+}
+
+// marker is a harmless value that VerifyQuoting uses to build the reference command.
+const marker string = "gowindowsQuotingMarker"
+
+// token is a single-quoted literal or the code between two literals of a PowerShell command.
+type token struct {
+	literal bool
+	value   string
+}
+
+// VerifyQuoting returns an error if the value escapes its arguments in the PowerShell command that build renders.
+// build is called once with the value and once with a harmless marker.
+// The value must be exactly one single-quoted literal wherever the marker is one
+// and everything else must be identical in both commands.
+func VerifyQuoting(build func(string) string, value string) error {
+	expectedTokens, err := tokens(build(marker))
+	if err != nil {
+		return fmt.Errorf("reference command: %w", err)
+	}
+
+	cmd := build(value)
+	actualTokens, err := tokens(cmd)
+	if err != nil {
+		return fmt.Errorf("value %q: %w: %s", value, err, cmd)
+	}
+	if len(actualTokens) != len(expectedTokens) {
+		return fmt.Errorf("value %q changes the number of literals from %d to %d: %s", value, len(expectedTokens)/2, len(actualTokens)/2, cmd)
+	}
+
+	// Invalid UTF-8 is quoted as replacement characters.
+	quotedValue := string([]rune(value))
+	arguments := 0
+	for i, expected := range expectedTokens {
+		actual := actualTokens[i]
+		switch {
+		case expected.literal && expected.value == marker:
+			arguments++
+			if actual.value != quotedValue {
+				return fmt.Errorf("value %q is parsed as %q: %s", value, actual.value, cmd)
+			}
+		case actual != expected:
+			return fmt.Errorf("value %q changes the command: %s", value, cmd)
+		}
+	}
+
+	if arguments == 0 {
+		return fmt.Errorf("value %q is not an argument of the command: %s", value, cmd)
+	}
+	return nil
+}
+
+// tokens splits a PowerShell command into its single-quoted literals and the code between them.
+// The tokens start and end with code, which may be empty, and alternate between code and literals.
+func tokens(cmd string) ([]token, error) {
+	var tokens []token
+
+	start := 0
+	for i := 0; i < len(cmd); {
+		r, size := utf8.DecodeRuneInString(cmd[i:])
+		if !parsing.IsPwshSingleQuote(r) {
+			i += size
+			continue
+		}
+
+		value, n, ok := literal(cmd[i:])
+		if !ok {
+			return nil, fmt.Errorf("unterminated literal at offset %d", i)
+		}
+
+		tokens = append(tokens, token{value: cmd[start:i]}, token{literal: true, value: value})
+		i += n
+		start = i
+	}
+
+	return append(tokens, token{value: cmd[start:]}), nil
+}
+
+// literal returns the value and the length of the single-quoted literal at the start of s and whether it is terminated.
+// A quote character followed by another quote character is an escaped quote.
+func literal(s string) (string, int, bool) {
+	var b strings.Builder
+
+	_, size := utf8.DecodeRuneInString(s)
+	for i := size; i < len(s); {
+		r, size := utf8.DecodeRuneInString(s[i:])
+		i += size
+		if !parsing.IsPwshSingleQuote(r) {
+			b.WriteRune(r)
+			continue
+		}
+
+		next, nextSize := utf8.DecodeRuneInString(s[i:])
+		if !parsing.IsPwshSingleQuote(next) {
+			return b.String(), i, true
+		}
+		b.WriteRune(r)
+		i += nextSize
+	}
+
+	return "", len(s), false
+}
@@ -0,0 +1,105 @@
+package pwshtest
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/d-strobel/gowindows/parsing"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVerifyQuoting(t *testing.T) {
+	t.Parallel()
+
+	quoted := func(s string) string {
+		return fmt.Sprintf("Get-LocalUser -Name %s | Where-Object Description -eq %s", parsing.PwshQuote(s), parsing.PwshQuote("O'Brien"))
+	}
+	concatenated := func(s string) string {
+		return "Get-LocalUser -Name '" + s + "'"
+	}
+	doubleQuoted := func(s string) string {
+		return fmt.Sprintf("Get-LocalUser -Name \"%s\"", s)
+	}
+	ignored := func(s string) string {
+		return "Get-LocalUser -Name 'Administrator'"
+	}
+
+	tcs := []struct {
+		description string
+		build       func(string) string
+		value       string
+		expectedErr string
+	}{
+		{
+			"quoted value",
+			quoted,
+			"Test-User",
+			"",
+		},
+		{
+			"quoted injection attempt",
+			quoted,
+			"x'; Remove-LocalUser -Name 'Administrator",
+			"",
+		},
+		{
+			"quoted typographic quotes and line break",
+			quoted,
+			"‘’‚‛\r\n'",
+			"",
+		},
+		{
+			"quoted invalid utf-8",
+			quoted,
+			"\x00\xff",
+			"",
+		},
+		{
+			"concatenated injection attempt",
+			concatenated,
+			"x'; Remove-LocalUser -Name 'Administrator",
+			"changes the number of literals",
+		},
+		{
+			"concatenated escaped quote",
+			concatenated,
+			"x''",
+			"is parsed as",
+		},
+		{
+			"concatenated unterminated literal",
+			concatenated,
+			"x'",
+			"unterminated literal",
+		},
+		{
+			"concatenated typographic quote",
+			concatenated,
+			"x‘",
+			"unterminated literal",
+		},
+		{
+			"double-quoted value",
+			doubleQuoted,
+			"$(whoami)",
+			"changes the command",
+		},
+		{
+			"ignored value",
+			ignored,
+			"Test-User",
+			"is not an argument",
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.description, func(t *testing.T) {
+			err := VerifyQuoting(tc.build, tc.value)
+			if tc.expectedErr == "" {
+				assert.NoError(t, err)
+				return
+			}
+			assert.ErrorContains(t, err, tc.expectedErr)
+		})
+	}
+}
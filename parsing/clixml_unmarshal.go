@@ -0,0 +1,810 @@
+package parsing
+
+import (
+	"encoding/base64"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf16"
+)
+
+// PSObject represents a deserialized PowerShell object that is unmarshaled into an interface value.
+type PSObject struct {
+	// TypeNames contains the type names of the object, starting with the most specific one.
+	TypeNames []string
+
+	// ToString contains the string representation of the object.
+	ToString string
+
+	// Value contains the primitive value of the object, e.g. the value of an enum.
+	Value any
+
+	// Properties contains the adapted and the extended properties of the object.
+	Properties map[string]any
+}
+
+// SecureString represents a serialized System.Security.SecureString.
+// The value is encrypted with the data protection API of the Windows system and cannot be decrypted on the client.
+type SecureString struct {
+	Encrypted string
+}
+
+var (
+	timeType         = reflect.TypeOf(time.Time{})
+	durationType     = reflect.TypeOf(time.Duration(0))
+	secureStringType = reflect.TypeOf(SecureString{})
+)
+
+// clixmlNode represents an element of a CLIXML document.
+type clixmlNode struct {
+	XMLName xml.Name
+	Name    string       `xml:"N,attr"`
+	RefId   string       `xml:"RefId,attr"`
+	Stream  string       `xml:"S,attr"`
+	Text    string       `xml:",chardata"`
+	Nodes   []clixmlNode `xml:",any"`
+}
+
+// tag returns the name of the element, e.g. "Obj" or "I32".
+func (n *clixmlNode) tag() string {
+	return n.XMLName.Local
+}
+
+// clixmlObject contains the parts of an <Obj> element.
+type clixmlObject struct {
+	typeNames  []string
+	toString   *string
+	primitive  *clixmlNode
+	list       *clixmlNode
+	dict       *clixmlNode
+	properties []*clixmlNode
+}
+
+// clixmlDecoder unmarshals the elements of a single CLIXML document.
+type clixmlDecoder struct {
+	objects   map[string]*clixmlNode
+	typeNames map[string][]string
+	visiting  map[*clixmlNode]bool
+}
+
+// UnmarshalCliXml unmarshals a CLIXML document, e.g. the output of Export-Clixml, into v.
+// A slice receives all objects of the document. Any other type receives the single object of the document
+// and an empty document leaves v untouched.
+//
+// Objects are unmarshaled into structs by their properties. The property name is taken from the
+// "clixml" struct tag or the field name and is matched case-insensitively if no exact match exists.
+// Lists are unmarshaled into slices and arrays, dictionaries into maps and enums either into a string
+// by their name or into an integer by their value.
+//
+// An interface value receives the Go representation of the element:
+//   - Objects are unmarshaled into a PSObject, lists into []any and dictionaries into map[string]any.
+//   - Strings, GUIDs, URIs, versions, XML documents and script blocks are unmarshaled into a string.
+//   - Characters are unmarshaled into a rune and decimals into a float64.
+//   - DateTime is unmarshaled into a time.Time and TimeSpan into a time.Duration.
+//   - Byte arrays are unmarshaled into a []byte and secure strings into a SecureString.
+//   - Any other primitive is unmarshaled into the Go type of the same size.
+func UnmarshalCliXml(text string, v any) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Pointer || rv.IsNil() {
+		return errors.New("parsing.UnmarshalCliXml: v must be a non-nil pointer")
+	}
+
+	// Remove CLIXML identifier
+	text = strings.TrimPrefix(strings.TrimSpace(text), "#< CLIXML")
+
+	var doc clixmlNode
+	if err := xml.Unmarshal([]byte(text), &doc); err != nil {
+		return fmt.Errorf("parsing.UnmarshalCliXml: %w", err)
+	}
+	if doc.tag() != "Objs" {
+		return errors.New("parsing.UnmarshalCliXml: the input string is not a CLIXML document")
+	}
+
+	d := &clixmlDecoder{
+		objects:   make(map[string]*clixmlNode),
+		typeNames: make(map[string][]string),
+		visiting:  make(map[*clixmlNode]bool),
+	}
+	d.collect(&doc)
+
+	// Elements with a stream attribute belong to the error, warning or progress streams.
+	var objs []*clixmlNode
+	for i := range doc.Nodes {
+		if doc.Nodes[i].Stream == "" {
+			objs = append(objs, &doc.Nodes[i])
+		}
+	}
+
+	if err := d.unmarshalDocument(objs, rv.Elem()); err != nil {
+		return fmt.Errorf("parsing.UnmarshalCliXml: %w", err)
+	}
+
+	return nil
+}
+
+// unmarshalDocument unmarshals the objects of a document into v.
+func (d *clixmlDecoder) unmarshalDocument(objs []*clixmlNode, v reflect.Value) error {
+	if len(objs) == 0 {
+		return nil
+	}
+
+	isSlice := v.Kind() == reflect.Slice && v.Type().Elem().Kind() != reflect.Uint8
+
+	// A single serialized collection is unmarshaled as a whole.
+	if len(objs) == 1 {
+		if !isSlice {
+			return d.unmarshal(objs[0], v)
+		}
+		if n, err := d.resolve(objs[0]); err == nil && n.tag() == "Obj" && d.object(n).list != nil {
+			return d.unmarshal(n, v)
+		}
+	}
+
+	switch {
+	case isSlice:
+		s := reflect.MakeSlice(v.Type(), len(objs), len(objs))
+		for i, obj := range objs {
+			if err := d.unmarshal(obj, s.Index(i)); err != nil {
+				return err
+			}
+		}
+		v.Set(s)
+		return nil
+
+	case v.Kind() == reflect.Interface && v.NumMethod() == 0:
+		s := make([]any, len(objs))
+		for i, obj := range objs {
+			value, err := d.value(obj)
+			if err != nil {
+				return err
+			}
+			s[i] = value
+		}
+		v.Set(reflect.ValueOf(s))
+		return nil
+
+	default:
+		return fmt.Errorf("expected a single object but got %d objects", len(objs))
+	}
+}
+
+// collect registers all objects and type names with a reference id,
+// so they can be resolved from <Ref> and <TNRef> elements.
+func (d *clixmlDecoder) collect(n *clixmlNode) {
+	switch n.tag() {
+	case "Obj":
+		if n.RefId != "" {
+			d.objects[n.RefId] = n
+		}
+	case "TN":
+		var typeNames []string
+		for _, t := range n.Nodes {
+			typeNames = append(typeNames, decodeCliXmlString(t.Text))
+		}
+		d.typeNames[n.RefId] = typeNames
+		return
+	}
+
+	for i := range n.Nodes {
+		d.collect(&n.Nodes[i])
+	}
+}
+
+// resolve returns the referenced object of a <Ref> element.
+func (d *clixmlDecoder) resolve(n *clixmlNode) (*clixmlNode, error) {
+	if n.tag() != "Ref" {
+		return n, nil
+	}
+
+	obj, ok := d.objects[n.RefId]
+	if !ok {
+		return nil, fmt.Errorf("unknown reference id %q", n.RefId)
+	}
+	return obj, nil
+}
+
+// object splits an <Obj> element into its parts.
+func (d *clixmlDecoder) object(n *clixmlNode) clixmlObject {
+	var obj clixmlObject
+
+	for i := range n.Nodes {
+		c := &n.Nodes[i]
+		switch c.tag() {
+		case "TN":
+			obj.typeNames = d.typeNames[c.RefId]
+		case "TNRef":
+			obj.typeNames = d.typeNames[c.RefId]
+		case "ToString":
+			s := decodeCliXmlString(c.Text)
+			obj.toString = &s
+		case "LST", "IE", "STK", "QUE":
+			obj.list = c
+		case "DCT":
+			obj.dict = c
+		case "Props", "MS":
+			for j := range c.Nodes {
+				obj.properties = append(obj.properties, &c.Nodes[j])
+			}
+		default:
+			obj.primitive = c
+		}
+	}
+
+	return obj
+}
+
+// unmarshal unmarshals an element into v.
+func (d *clixmlDecoder) unmarshal(n *clixmlNode, v reflect.Value) error {
+	n, err := d.resolve(n)
+	if err != nil {
+		return err
+	}
+
+	if n.tag() == "Nil" {
+		v.Set(reflect.Zero(v.Type()))
+		return nil
+	}
+
+	switch {
+	case v.Kind() == reflect.Pointer:
+		if v.IsNil() {
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+		return d.unmarshal(n, v.Elem())
+
+	case v.Kind() == reflect.Interface && v.NumMethod() == 0:
+		value, err := d.value(n)
+		if err != nil {
+			return err
+		}
+		if value == nil {
+			v.Set(reflect.Zero(v.Type()))
+		} else {
+			v.Set(reflect.ValueOf(value))
+		}
+		return nil
+
+	case n.tag() == "Obj":
+		return d.unmarshalObject(n, v)
+
+	default:
+		return unmarshalCliXmlPrimitive(n, v)
+	}
+}
+
+// unmarshalObject unmarshals an <Obj> element into v.
+func (d *clixmlDecoder) unmarshalObject(n *clixmlNode, v reflect.Value) error {
+	if d.visiting[n] {
+		return fmt.Errorf("cyclic reference of object %q", n.RefId)
+	}
+	d.visiting[n] = true
+	defer delete(d.visiting, n)
+
+	obj := d.object(n)
+
+	switch {
+	case v.Type() == timeType || v.Type() == secureStringType:
+		// Handled as primitive below.
+
+	case v.Kind() == reflect.String:
+		// Enums are unmarshaled into a string by their name.
+		if obj.toString != nil && (obj.primitive == nil || obj.primitive.tag() != "S") {
+			v.SetString(*obj.toString)
+			return nil
+		}
+
+	case v.Kind() == reflect.Slice && v.Type().Elem().Kind() != reflect.Uint8, v.Kind() == reflect.Array:
+		if obj.list != nil {
+			return d.unmarshalList(obj.list, v)
+		}
+
+	case v.Kind() == reflect.Map:
+		if obj.dict != nil {
+			return d.unmarshalDict(obj.dict, v)
+		}
+		return d.unmarshalPropertiesMap(obj.properties, v)
+
+	case v.Kind() == reflect.Struct:
+		return d.unmarshalStruct(obj.properties, v)
+	}
+
+	if obj.primitive == nil {
+		return fmt.Errorf("cannot unmarshal object %v into %s", obj.typeNames, v.Type())
+	}
+	return d.unmarshal(obj.primitive, v)
+}
+
+// unmarshalList unmarshals the items of a list element into a slice or an array.
+func (d *clixmlDecoder) unmarshalList(n *clixmlNode, v reflect.Value) error {
+	if v.Kind() == reflect.Slice {
+		v.Set(reflect.MakeSlice(v.Type(), len(n.Nodes), len(n.Nodes)))
+	}
+
+	for i := range n.Nodes {
+		if i >= v.Len() {
+			break
+		}
+		if err := d.unmarshal(&n.Nodes[i], v.Index(i)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// unmarshalDict unmarshals the entries of a <DCT> element into a map.
+func (d *clixmlDecoder) unmarshalDict(n *clixmlNode, v reflect.Value) error {
+	if v.IsNil() {
+		v.Set(reflect.MakeMap(v.Type()))
+	}
+
+	for i := range n.Nodes {
+		key := reflect.New(v.Type().Key()).Elem()
+		value := reflect.New(v.Type().Elem()).Elem()
+
+		for j := range n.Nodes[i].Nodes {
+			c := &n.Nodes[i].Nodes[j]
+			switch c.Name {
+			case "Key":
+				if err := d.unmarshal(c, key); err != nil {
+					return err
+				}
+			case "Value":
+				if err := d.unmarshal(c, value); err != nil {
+					return err
+				}
+			}
+		}
+
+		v.SetMapIndex(key, value)
+	}
+
+	return nil
+}
+
+// unmarshalPropertiesMap unmarshals the properties of an object into a map with string keys.
+func (d *clixmlDecoder) unmarshalPropertiesMap(properties []*clixmlNode, v reflect.Value) error {
+	if v.Type().Key().Kind() != reflect.String {
+		return fmt.Errorf("cannot unmarshal object properties into %s", v.Type())
+	}
+
+	if v.IsNil() {
+		v.Set(reflect.MakeMap(v.Type()))
+	}
+
+	for _, p := range properties {
+		value := reflect.New(v.Type().Elem()).Elem()
+		if err := d.unmarshal(p, value); err != nil {
+			return err
+		}
+		v.SetMapIndex(reflect.ValueOf(decodeCliXmlString(p.Name)).Convert(v.Type().Key()), value)
+	}
+
+	return nil
+}
+
+// unmarshalStruct unmarshals the properties of an object into the fields of a struct.
+// Properties without a matching field are ignored.
+func (d *clixmlDecoder) unmarshalStruct(properties []*clixmlNode, v reflect.Value) error {
+	fields := clixmlFields(v.Type())
+
+	for _, p := range properties {
+		name := decodeCliXmlString(p.Name)
+
+		field, ok := fields[name]
+		if !ok {
+			for fieldName, f := range fields {
+				if strings.EqualFold(fieldName, name) {
+					field, ok = f, true
+					break
+				}
+			}
+		}
+		if !ok {
+			continue
+		}
+
+		if err := d.unmarshal(p, v.FieldByIndex(field)); err != nil {
+			return fmt.Errorf("property %s: %w", name, err)
+		}
+	}
+
+	return nil
+}
+
+// clixmlFields returns the index of the exported fields of a struct type by their property name.
+// The fields of embedded structs are promoted.
+func clixmlFields(t reflect.Type) map[string][]int {
+	fields := make(map[string][]int)
+
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := f.Tag.Get("clixml")
+		if tag == "-" {
+			continue
+		}
+
+		if f.Anonymous && tag == "" && f.Type.Kind() == reflect.Struct {
+			for name, index := range clixmlFields(f.Type) {
+				if _, ok := fields[name]; !ok {
+					fields[name] = append([]int{i}, index...)
+				}
+			}
+			continue
+		}
+
+		if !f.IsExported() {
+			continue
+		}
+
+		name := f.Name
+		if tag != "" {
+			name = tag
+		}
+		fields[name] = []int{i}
+	}
+
+	return fields
+}
+
+// value returns the Go representation of an element.
+func (d *clixmlDecoder) value(n *clixmlNode) (any, error) {
+	n, err := d.resolve(n)
+	if err != nil {
+		return nil, err
+	}
+
+	if n.tag() != "Obj" {
+		return cliXmlPrimitive(n)
+	}
+
+	if d.visiting[n] {
+		return nil, fmt.Errorf("cyclic reference of object %q", n.RefId)
+	}
+	d.visiting[n] = true
+	defer delete(d.visiting, n)
+
+	obj := d.object(n)
+
+	if obj.list != nil {
+		list := make([]any, len(obj.list.Nodes))
+		for i := range obj.list.Nodes {
+			if list[i], err = d.value(&obj.list.Nodes[i]); err != nil {
+				return nil, err
+			}
+		}
+		return list, nil
+	}
+
+	if obj.dict != nil {
+		dict := make(map[string]any, len(obj.dict.Nodes))
+		for i := range obj.dict.Nodes {
+			var key, value any
+			for j := range obj.dict.Nodes[i].Nodes {
+				c := &obj.dict.Nodes[i].Nodes[j]
+				switch c.Name {
+				case "Key":
+					key, err = d.value(c)
+				case "Value":
+					value, err = d.value(c)
+				}
+				if err != nil {
+					return nil, err
+				}
+			}
+			dict[fmt.Sprint(key)] = value
+		}
+		return dict, nil
+	}
+
+	psObject := PSObject{TypeNames: obj.typeNames}
+	if obj.toString != nil {
+		psObject.ToString = *obj.toString
+	}
+	if obj.primitive != nil {
+		if psObject.Value, err = d.value(obj.primitive); err != nil {
+			return nil, err
+		}
+	}
+	if len(obj.properties) > 0 {
+		psObject.Properties = make(map[string]any, len(obj.properties))
+		for _, p := range obj.properties {
+			if psObject.Properties[decodeCliXmlString(p.Name)], err = d.value(p); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return psObject, nil
+}
+
+// cliXmlPrimitive returns the Go representation of a primitive element.
+func cliXmlPrimitive(n *clixmlNode) (any, error) {
+	text := strings.TrimSpace(n.Text)
+
+	switch n.tag() {
+	case "Nil":
+		return nil, nil
+	case "S", "XD", "SBK":
+		return decodeCliXmlString(n.Text), nil
+	case "G", "URI", "Version":
+		return text, nil
+	case "C":
+		c, err := strconv.ParseUint(text, 10, 16)
+		return rune(c), err
+	case "B":
+		return strconv.ParseBool(text)
+	case "DT":
+		return parseCliXmlDateTime(text)
+	case "TS":
+		return parseCliXmlDuration(text)
+	case "By":
+		i, err := strconv.ParseUint(text, 10, 8)
+		return uint8(i), err
+	case "SB":
+		i, err := strconv.ParseInt(text, 10, 8)
+		return int8(i), err
+	case "U16":
+		i, err := strconv.ParseUint(text, 10, 16)
+		return uint16(i), err
+	case "I16":
+		i, err := strconv.ParseInt(text, 10, 16)
+		return int16(i), err
+	case "U32":
+		i, err := strconv.ParseUint(text, 10, 32)
+		return uint32(i), err
+	case "I32":
+		i, err := strconv.ParseInt(text, 10, 32)
+		return int32(i), err
+	case "U64":
+		return strconv.ParseUint(text, 10, 64)
+	case "I64":
+		return strconv.ParseInt(text, 10, 64)
+	case "Sg":
+		f, err := strconv.ParseFloat(text, 32)
+		return float32(f), err
+	case "Db", "D":
+		return strconv.ParseFloat(text, 64)
+	case "BA":
+		return base64.StdEncoding.DecodeString(text)
+	case "SS":
+		return SecureString{Encrypted: text}, nil
+	default:
+		return nil, fmt.Errorf("unknown element <%s>", n.tag())
+	}
+}
+
+// unmarshalCliXmlPrimitive unmarshals a primitive element into v.
+func unmarshalCliXmlPrimitive(n *clixmlNode, v reflect.Value) error {
+	tag := n.tag()
+	text := strings.TrimSpace(n.Text)
+
+	switch v.Type() {
+	case timeType:
+		if tag != "DT" {
+			break
+		}
+		t, err := parseCliXmlDateTime(text)
+		if err != nil {
+			return err
+		}
+		v.Set(reflect.ValueOf(t))
+		return nil
+
+	case durationType:
+		if tag != "TS" {
+			break
+		}
+		duration, err := parseCliXmlDuration(text)
+		if err != nil {
+			return err
+		}
+		v.SetInt(int64(duration))
+		return nil
+
+	case secureStringType:
+		if tag != "SS" {
+			break
+		}
+		v.Set(reflect.ValueOf(SecureString{Encrypted: text}))
+		return nil
+
+	default:
+		switch v.Kind() {
+		case reflect.String:
+			switch tag {
+			case "S", "XD", "SBK":
+				v.SetString(decodeCliXmlString(n.Text))
+			case "C":
+				c, err := strconv.ParseUint(text, 10, 16)
+				if err != nil {
+					return err
+				}
+				v.SetString(string(rune(c)))
+			default:
+				// Decimals keep their exact value in a string.
+				v.SetString(text)
+			}
+			return nil
+
+		case reflect.Bool:
+			if tag != "B" {
+				break
+			}
+			b, err := strconv.ParseBool(text)
+			if err != nil {
+				return err
+			}
+			v.SetBool(b)
+			return nil
+
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			if !isCliXmlInteger(tag) {
+				break
+			}
+			i, err := strconv.ParseInt(text, 10, 64)
+			if err != nil {
+				return err
+			}
+			if v.OverflowInt(i) {
+				return fmt.Errorf("value %s overflows %s", text, v.Type())
+			}
+			v.SetInt(i)
+			return nil
+
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			if !isCliXmlInteger(tag) {
+				break
+			}
+			i, err := strconv.ParseUint(text, 10, 64)
+			if err != nil {
+				return err
+			}
+			if v.OverflowUint(i) {
+				return fmt.Errorf("value %s overflows %s", text, v.Type())
+			}
+			v.SetUint(i)
+			return nil
+
+		case reflect.Float32, reflect.Float64:
+			if !isCliXmlInteger(tag) && tag != "Sg" && tag != "Db" && tag != "D" {
+				break
+			}
+			f, err := strconv.ParseFloat(text, v.Type().Bits())
+			if err != nil {
+				return err
+			}
+			v.SetFloat(f)
+			return nil
+
+		case reflect.Slice:
+			if tag != "BA" || v.Type().Elem().Kind() != reflect.Uint8 {
+				break
+			}
+			b, err := base64.StdEncoding.DecodeString(text)
+			if err != nil {
+				return err
+			}
+			v.SetBytes(b)
+			return nil
+		}
+	}
+
+	return fmt.Errorf("cannot unmarshal <%s> into %s", tag, v.Type())
+}
+
+// isCliXmlInteger reports whether the element is an integer.
+func isCliXmlInteger(tag string) bool {
+	switch tag {
+	case "By", "SB", "U16", "I16", "U32", "I32", "U64", "I64":
+		return true
+	}
+	return false
+}
+
+// decodeCliXmlString decodes the escaped characters of a CLIXML string, e.g. "_x000D__x000A_" for a CRLF.
+func decodeCliXmlString(s string) string {
+	if !strings.Contains(s, "_x") {
+		return s
+	}
+
+	var b strings.Builder
+	var units []uint16
+
+	for i := 0; i < len(s); {
+		if len(s)-i >= 7 && s[i] == '_' && s[i+1] == 'x' && s[i+6] == '_' {
+			if u, err := strconv.ParseUint(s[i+2:i+6], 16, 16); err == nil {
+				units = append(units, uint16(u))
+				i += 7
+				continue
+			}
+		}
+
+		// Escaped surrogate pairs are decoded together.
+		if len(units) > 0 {
+			b.WriteString(string(utf16.Decode(units)))
+			units = units[:0]
+		}
+		b.WriteByte(s[i])
+		i++
+	}
+	if len(units) > 0 {
+		b.WriteString(string(utf16.Decode(units)))
+	}
+
+	return b.String()
+}
+
+// EncodeCliXmlString returns the string as the escaped content of a CLIXML string element, e.g. "_x000D__x000A_" for a CRLF.
+// Besides the XML special characters, control characters and underscores that start an
+// escape sequence are encoded as "_xHHHH_".
+func EncodeCliXmlString(s string) string {
+	var b strings.Builder
+
+	for i, r := range s {
+		switch {
+		case r == '_' && strings.HasPrefix(s[i:], "_x"):
+			b.WriteString("_x005F_")
+		case r < 0x20 || r == 0x7F:
+			fmt.Fprintf(&b, "_x%04X_", r)
+		default:
+			_ = xml.EscapeText(&b, []byte(string(r)))
+		}
+	}
+
+	return b.String()
+}
+
+// parseCliXmlDateTime parses a serialized System.DateTime, e.g. "2024-01-02T15:04:05.1234567+01:00".
+// DateTime values without a time zone are parsed as UTC.
+func parseCliXmlDateTime(s string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339Nano, s); err == nil {
+		return t, nil
+	}
+	return time.Parse("2006-01-02T15:04:05.999999999", s)
+}
+
+// parseCliXmlDuration parses a serialized System.TimeSpan in the XML duration format, e.g. "P1DT2H3M4.5S".
+func parseCliXmlDuration(s string) (time.Duration, error) {
+	sign := ""
+	rest, ok := strings.CutPrefix(s, "-")
+	if ok {
+		sign = "-"
+	}
+
+	rest, ok = strings.CutPrefix(rest, "P")
+	if !ok {
+		return 0, fmt.Errorf("invalid duration %q", s)
+	}
+
+	datePart, timePart, _ := strings.Cut(rest, "T")
+
+	var days int64
+	if datePart != "" {
+		d, ok := strings.CutSuffix(datePart, "D")
+		if !ok {
+			return 0, fmt.Errorf("invalid duration %q", s)
+		}
+		var err error
+		if days, err = strconv.ParseInt(d, 10, 64); err != nil {
+			return 0, fmt.Errorf("invalid duration %q", s)
+		}
+	}
+
+	// The time part has the same units as a Go duration string.
+	duration := strings.ToLower(timePart)
+	if duration == "" {
+		duration = "0s"
+	}
+
+	d, err := time.ParseDuration(fmt.Sprintf("%s%dh%s", sign, days*24, duration))
+	if err != nil {
+		return 0, fmt.Errorf("invalid duration %q", s)
+	}
+
+	return d, nil
+}
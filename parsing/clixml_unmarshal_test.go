@@ -0,0 +1,362 @@
+package parsing
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+// Unit test suite for the CLIXML unmarshaling
+type CLIXMLUnmarshalUnitTestSuite struct {
+	suite.Suite
+	// Fixtures
+	cliXMLLocalUsers string
+}
+
+// Fixture objects
+type testLocalUser struct {
+	Name                   string
+	Description            string
+	Enabled                bool
+	AccountExpires         *time.Time
+	PasswordChangeableDate time.Time
+	PrincipalSource        string
+	Sid                    testSid
+	Class                  string `clixml:"ObjectClass"`
+	Ignored                string `clixml:"-"`
+}
+
+type testSid struct {
+	BinaryLength int32
+	Value        string
+}
+
+func (suite *CLIXMLUnmarshalUnitTestSuite) SetupSuite() {
+	// Fixtures
+	suite.cliXMLLocalUsers = `#< CLIXML
+<Objs Version="1.1.0.1" xmlns="http://schemas.microsoft.com/powershell/2004/04">
+  <Obj RefId="0">
+    <TN RefId="0">
+      <T>Microsoft.PowerShell.Commands.LocalUser</T>
+      <T>Microsoft.PowerShell.Commands.LocalPrincipal</T>
+      <T>System.Object</T>
+    </TN>
+    <ToString>Administrator</ToString>
+    <Props>
+      <Nil N="AccountExpires" />
+      <S N="Description">Built-in account for administering the computer/domain</S>
+      <B N="Enabled">false</B>
+      <DT N="PasswordChangeableDate">2024-03-10T12:30:15.1234567+01:00</DT>
+      <S N="Name">Administrator</S>
+      <Obj N="SID" RefId="1">
+        <TN RefId="1">
+          <T>System.Security.Principal.SecurityIdentifier</T>
+          <T>System.Security.Principal.IdentityReference</T>
+          <T>System.Object</T>
+        </TN>
+        <ToString>S-1-5-21-1234-500</ToString>
+        <Props>
+          <I32 N="BinaryLength">28</I32>
+          <S N="Value">S-1-5-21-1234-500</S>
+        </Props>
+      </Obj>
+      <Obj N="PrincipalSource" RefId="2">
+        <TN RefId="2">
+          <T>Microsoft.PowerShell.Commands.PrincipalSource</T>
+          <T>System.Enum</T>
+          <T>System.ValueType</T>
+          <T>System.Object</T>
+        </TN>
+        <ToString>Local</ToString>
+        <I32>1</I32>
+      </Obj>
+      <S N="ObjectClass">User</S>
+      <S N="Ignored">ignored</S>
+    </Props>
+  </Obj>
+  <Obj RefId="3">
+    <TNRef RefId="0" />
+    <ToString>Test-User</ToString>
+    <Props>
+      <DT N="AccountExpires">2025-01-01T00:00:00Z</DT>
+      <S N="Description">Test_x000D__x000A_User</S>
+      <B N="Enabled">true</B>
+      <DT N="PasswordChangeableDate">2024-03-10T12:30:15</DT>
+      <S N="Name">Test-User</S>
+      <Ref N="SID" RefId="1" />
+      <Ref N="PrincipalSource" RefId="2" />
+      <S N="ObjectClass">User</S>
+    </Props>
+  </Obj>
+  <S S="warning">This is a warning._x000D__x000A_</S>
+</Objs>`
+}
+
+func TestCLIXMLUnmarshalUnitTestSuite(t *testing.T) {
+	suite.Run(t, &CLIXMLUnmarshalUnitTestSuite{})
+}
+
+func (suite *CLIXMLUnmarshalUnitTestSuite) TestUnmarshalCliXml() {
+	suite.T().Parallel()
+
+	expiresAt := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	expectedUsers := []testLocalUser{
+		{
+			Name:                   "Administrator",
+			Description:            "Built-in account for administering the computer/domain",
+			Enabled:                false,
+			PasswordChangeableDate: time.Date(2024, 3, 10, 12, 30, 15, 123456700, time.FixedZone("", 3600)),
+			PrincipalSource:        "Local",
+			Sid:                    testSid{BinaryLength: 28, Value: "S-1-5-21-1234-500"},
+			Class:                  "User",
+		},
+		{
+			Name:                   "Test-User",
+			Description:            "Test\r\nUser",
+			Enabled:                true,
+			AccountExpires:         &expiresAt,
+			PasswordChangeableDate: time.Date(2024, 3, 10, 12, 30, 15, 0, time.UTC),
+			PrincipalSource:        "Local",
+			Sid:                    testSid{BinaryLength: 28, Value: "S-1-5-21-1234-500"},
+			Class:                  "User",
+		},
+	}
+
+	suite.Run("should unmarshal all objects into a slice", func() {
+		var users []testLocalUser
+		err := UnmarshalCliXml(suite.cliXMLLocalUsers, &users)
+		suite.Require().NoError(err)
+		suite.Require().Len(users, 2)
+		suite.Equal(expectedUsers[0].PasswordChangeableDate.UnixNano(), users[0].PasswordChangeableDate.UnixNano())
+		users[0].PasswordChangeableDate = expectedUsers[0].PasswordChangeableDate
+		suite.Equal(expectedUsers, users)
+	})
+
+	suite.Run("should unmarshal a single object into a struct", func() {
+		text := `<Objs Version="1.1.0.1" xmlns="http://schemas.microsoft.com/powershell/2004/04"><Obj RefId="0"><Props>` +
+			`<S N="name">Test-User</S><B N="Enabled">true</B></Props></Obj></Objs>`
+		var user testLocalUser
+		err := UnmarshalCliXml(text, &user)
+		suite.NoError(err)
+		suite.Equal(testLocalUser{Name: "Test-User", Enabled: true}, user)
+	})
+
+	suite.Run("should return an error on multiple objects for a struct", func() {
+		var user testLocalUser
+		err := UnmarshalCliXml(suite.cliXMLLocalUsers, &user)
+		suite.EqualError(err, "parsing.UnmarshalCliXml: expected a single object but got 2 objects")
+	})
+
+	suite.Run("should unmarshal an enum into an integer by its value", func() {
+		text := `<Objs Version="1.1.0.1" xmlns="http://schemas.microsoft.com/powershell/2004/04"><Obj RefId="0">` +
+			`<TN RefId="0"><T>Microsoft.PowerShell.Commands.PrincipalSource</T><T>System.Enum</T></TN>` +
+			`<ToString>Local</ToString><I32>1</I32></Obj></Objs>`
+		var i int
+		err := UnmarshalCliXml(text, &i)
+		suite.NoError(err)
+		suite.Equal(1, i)
+	})
+
+	suite.Run("should unmarshal a serialized collection into a slice", func() {
+		text := `<Objs Version="1.1.0.1" xmlns="http://schemas.microsoft.com/powershell/2004/04"><Obj RefId="0">` +
+			`<TN RefId="0"><T>System.Object[]</T><T>System.Array</T><T>System.Object</T></TN>` +
+			`<LST><I32>1</I32><I32>2</I32><I32>3</I32></LST></Obj></Objs>`
+		var numbers []int
+		err := UnmarshalCliXml(text, &numbers)
+		suite.NoError(err)
+		suite.Equal([]int{1, 2, 3}, numbers)
+	})
+
+	suite.Run("should unmarshal a dictionary into a map", func() {
+		text := `<Objs Version="1.1.0.1" xmlns="http://schemas.microsoft.com/powershell/2004/04"><Obj RefId="0">` +
+			`<TN RefId="0"><T>System.Collections.Hashtable</T><T>System.Object</T></TN>` +
+			`<DCT><En><S N="Key">a</S><I32 N="Value">1</I32></En><En><S N="Key">b</S><I64 N="Value">2</I64></En></DCT></Obj></Objs>`
+		var m map[string]int
+		err := UnmarshalCliXml(text, &m)
+		suite.NoError(err)
+		suite.Equal(map[string]int{"a": 1, "b": 2}, m)
+	})
+
+	suite.Run("should unmarshal the properties of an object into a map", func() {
+		text := `<Objs Version="1.1.0.1" xmlns="http://schemas.microsoft.com/powershell/2004/04"><Obj RefId="0">` +
+			`<MS><S N="Name">test</S><S N="Path">C:\test</S></MS></Obj></Objs>`
+		var m map[string]string
+		err := UnmarshalCliXml(text, &m)
+		suite.NoError(err)
+		suite.Equal(map[string]string{"Name": "test", "Path": `C:\test`}, m)
+	})
+
+	suite.Run("should unmarshal objects into interface values", func() {
+		text := `<Objs Version="1.1.0.1" xmlns="http://schemas.microsoft.com/powershell/2004/04"><Obj RefId="0">` +
+			`<TN RefId="0"><T>System.Management.Automation.PSCustomObject</T><T>System.Object</T></TN>` +
+			`<MS><S N="Name">test</S>` +
+			`<Obj N="Items" RefId="1"><TN RefId="1"><T>System.Collections.ArrayList</T><T>System.Object</T></TN>` +
+			`<LST><S>a</S><Nil /></LST></Obj>` +
+			`<Obj N="Table" RefId="2"><TN RefId="2"><T>System.Collections.Hashtable</T><T>System.Object</T></TN>` +
+			`<DCT><En><I32 N="Key">1</I32><B N="Value">true</B></En></DCT></Obj>` +
+			`</MS></Obj></Objs>`
+		var o any
+		err := UnmarshalCliXml(text, &o)
+		suite.NoError(err)
+		suite.Equal(PSObject{
+			TypeNames: []string{"System.Management.Automation.PSCustomObject", "System.Object"},
+			Properties: map[string]any{
+				"Name":  "test",
+				"Items": []any{"a", nil},
+				"Table": map[string]any{"1": true},
+			},
+		}, o)
+	})
+
+	suite.Run("should unmarshal primitives into interface values", func() {
+		text := `<Objs Version="1.1.0.1" xmlns="http://schemas.microsoft.com/powershell/2004/04">` +
+			`<S>_xD83D__xDE00_ and _x005F_x</S><C>97</C><B>true</B><DT>2024-03-10T12:30:15Z</DT><TS>-P1DT2H3M4.5S</TS>` +
+			`<By>255</By><SB>-128</SB><U16>65535</U16><I16>-1</I16><U32>4294967295</U32><I32>-2</I32>` +
+			`<U64>18446744073709551615</U64><I64>-3</I64><Sg>1.5</Sg><Db>INF</Db><D>79228162514264337593543950335</D>` +
+			`<G>792e5b37-4505-47ef-b7d2-8711bb7affa8</G><URI>http://localhost/</URI><Version>1.2.3.4</Version>` +
+			`<BA>AQID</BA><SS>01000000d08c9ddf</SS><Nil /></Objs>`
+		var o []any
+		err := UnmarshalCliXml(text, &o)
+		suite.Require().NoError(err)
+		suite.Equal([]any{
+			"😀 and _x",
+			rune('a'),
+			true,
+			time.Date(2024, 3, 10, 12, 30, 15, 0, time.UTC),
+			-(26*time.Hour + 3*time.Minute + 4500*time.Millisecond),
+			uint8(255),
+			int8(-128),
+			uint16(65535),
+			int16(-1),
+			uint32(4294967295),
+			int32(-2),
+			uint64(18446744073709551615),
+			int64(-3),
+			float32(1.5),
+			math.Inf(1),
+			float64(79228162514264337593543950335),
+			"792e5b37-4505-47ef-b7d2-8711bb7affa8",
+			"http://localhost/",
+			"1.2.3.4",
+			[]byte{1, 2, 3},
+			SecureString{Encrypted: "01000000d08c9ddf"},
+			nil,
+		}, o)
+	})
+
+	suite.Run("should keep the exact value of a decimal in a string", func() {
+		text := `<Objs Version="1.1.0.1" xmlns="http://schemas.microsoft.com/powershell/2004/04"><D>0.1000000000000000000000000001</D></Objs>`
+		var s string
+		err := UnmarshalCliXml(text, &s)
+		suite.NoError(err)
+		suite.Equal("0.1000000000000000000000000001", s)
+	})
+
+	suite.Run("should leave the object untouched on an empty document", func() {
+		text := `<Objs Version="1.1.0.1" xmlns="http://schemas.microsoft.com/powershell/2004/04"><S S="Error">error</S></Objs>`
+		user := testLocalUser{Name: "untouched"}
+		err := UnmarshalCliXml(text, &user)
+		suite.NoError(err)
+		suite.Equal(testLocalUser{Name: "untouched"}, user)
+	})
+
+	suite.Run("should return an error on an overflow", func() {
+		text := `<Objs Version="1.1.0.1" xmlns="http://schemas.microsoft.com/powershell/2004/04"><I32>300</I32></Objs>`
+		var i int8
+		err := UnmarshalCliXml(text, &i)
+		suite.EqualError(err, "parsing.UnmarshalCliXml: value 300 overflows int8")
+	})
+
+	suite.Run("should return an error on a mismatching type", func() {
+		text := `<Objs Version="1.1.0.1" xmlns="http://schemas.microsoft.com/powershell/2004/04"><Obj RefId="0"><Props><S N="Enabled">yes</S></Props></Obj></Objs>`
+		var user testLocalUser
+		err := UnmarshalCliXml(text, &user)
+		suite.EqualError(err, "parsing.UnmarshalCliXml: property Enabled: cannot unmarshal <S> into bool")
+	})
+
+	suite.Run("should return an error on an unknown reference", func() {
+		text := `<Objs Version="1.1.0.1" xmlns="http://schemas.microsoft.com/powershell/2004/04"><Ref RefId="5" /></Objs>`
+		var o any
+		err := UnmarshalCliXml(text, &o)
+		suite.EqualError(err, `parsing.UnmarshalCliXml: unknown reference id "5"`)
+	})
+
+	suite.Run("should return an error on a cyclic reference", func() {
+		text := `<Objs Version="1.1.0.1" xmlns="http://schemas.microsoft.com/powershell/2004/04"><Obj RefId="0"><MS><Ref N="Self" RefId="0" /></MS></Obj></Objs>`
+		var o any
+		err := UnmarshalCliXml(text, &o)
+		suite.EqualError(err, `parsing.UnmarshalCliXml: cyclic reference of object "0"`)
+	})
+
+	suite.Run("should return an error if not a clixml document", func() {
+		var o any
+		err := UnmarshalCliXml("<html></html>", &o)
+		suite.EqualError(err, "parsing.UnmarshalCliXml: the input string is not a CLIXML document")
+	})
+
+	suite.Run("should return an error if v is not a pointer", func() {
+		var o any
+		err := UnmarshalCliXml(suite.cliXMLLocalUsers, o)
+		suite.EqualError(err, "parsing.UnmarshalCliXml: v must be a non-nil pointer")
+	})
+}
+
+func (suite *CLIXMLUnmarshalUnitTestSuite) TestParseCliXmlDuration() {
+	suite.T().Parallel()
+
+	tcs := []struct {
+		description string
+		input       string
+		expected    time.Duration
+		expectErr   bool
+	}{
+		{"days and time", "P1DT2H3M4S", 26*time.Hour + 3*time.Minute + 4*time.Second, false},
+		{"fractional seconds", "PT0.1234567S", 123456700 * time.Nanosecond, false},
+		{"days only", "P2D", 48 * time.Hour, false},
+		{"negative", "-PT1M", -time.Minute, false},
+		{"zero", "PT0S", 0, false},
+		{"missing designator", "1H", 0, true},
+		{"months", "P1M", 0, true},
+	}
+
+	for _, tc := range tcs {
+		suite.T().Logf("test case: %s", tc.description)
+
+		actual, err := parseCliXmlDuration(tc.input)
+		if tc.expectErr {
+			suite.Error(err)
+			continue
+		}
+		suite.NoError(err)
+		suite.Equal(tc.expected, actual)
+	}
+}
+
+func (suite *CLIXMLUnmarshalUnitTestSuite) TestEncodeCliXmlString() {
+	suite.T().Parallel()
+
+	tcs := []struct {
+		description string
+		input       string
+		expected    string
+	}{
+		{"plain text", "Hello World", "Hello World"},
+		{"XML special characters", `<a & "b">`, "&lt;a &amp; &#34;b&#34;&gt;"},
+		{"line breaks", "first\r\nsecond", "first_x000D__x000A_second"},
+		{"escape sequence", "_x0041_ and _y", "_x005F_x0041_ and _y"},
+	}
+
+	for _, tc := range tcs {
+		suite.T().Logf("test case: %s", tc.description)
+
+		encoded := EncodeCliXmlString(tc.input)
+		suite.Equal(tc.expected, encoded)
+
+		// The encoded string is decoded to the input.
+		var decoded string
+		suite.NoError(UnmarshalCliXml(`<Objs><S>`+encoded+`</S></Objs>`, &decoded))
+		suite.Equal(tc.input, decoded)
+	}
+}
@@ -0,0 +1,192 @@
+package parsing
+
+import (
+	"fmt"
+	"net/netip"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// PwshRaw is a PowerShell expression that is rendered without quoting,
+// e.g. a variable like "$r" or a subexpression like "$(New-TimeSpan -Seconds 60)".
+// It must never contain user input.
+type PwshRaw string
+
+// pwshOutput defines how the output of a PowerShell command is rendered.
+type pwshOutput int
+
+const (
+	pwshOutputNone pwshOutput = iota
+	pwshOutputJson
+	pwshOutputJsonArray
+)
+
+// defaultPwshVariable is the variable that holds the output of a command that is rendered as a JSON array.
+const defaultPwshVariable string = "r"
+
+// PwshCommand is a builder for PowerShell commands.
+// It renders a cmdlet with its named parameters, pipelines, following statements and
+// the JSON output handling into a deterministic command string.
+//
+// Parameter values are rendered based on their Go type:
+//   - string: single-quoted literal, see PwshQuote
+//   - integer types: decimal number
+//   - bool: appended to the parameter name, e.g. -Confirm:$false
+//   - netip.Addr: single-quoted literal of the address
+//   - time.Duration: New-TimeSpan subexpression, see PwshTimespanString
+//   - time.Time: Get-Date subexpression
+//   - slices: array of the rendered values, e.g. @('a','b')
+//   - PwshRaw: the expression as it is
+//   - PwshSensitive: single-quoted literal that is redacted by RedactPwshCmd
+type PwshCommand struct {
+	cmdlet     string
+	parameters []string
+	variable   string
+	pipeline   []*PwshCommand
+	statements []*PwshCommand
+	output     pwshOutput
+}
+
+// NewPwshCommand returns a new PwshCommand for the given cmdlet.
+// The cmdlet is rendered as it is, so it must never contain user input.
+func NewPwshCommand(cmdlet string) *PwshCommand {
+	return &PwshCommand{cmdlet: cmdlet}
+}
+
+// Param adds a named parameter with a typed value to the command.
+func (c *PwshCommand) Param(name string, value any) *PwshCommand {
+	// Booleans are always bound to the parameter name.
+	if b, ok := value.(bool); ok {
+		c.parameters = append(c.parameters, fmt.Sprintf("-%s:%s", name, pwshBool(b)))
+		return c
+	}
+
+	c.parameters = append(c.parameters, fmt.Sprintf("-%s %s", name, PwshValue(value)))
+	return c
+}
+
+// Switch adds a switch parameter to the command, e.g. -PassThru.
+func (c *PwshCommand) Switch(name string) *PwshCommand {
+	c.parameters = append(c.parameters, fmt.Sprintf("-%s", name))
+	return c
+}
+
+// Assign assigns the output of the command to the given variable, e.g. $r=Get-LocalUser.
+func (c *PwshCommand) Assign(variable string) *PwshCommand {
+	c.variable = variable
+	return c
+}
+
+// Pipe pipes the output of the command into the next command.
+func (c *PwshCommand) Pipe(next *PwshCommand) *PwshCommand {
+	c.pipeline = append(c.pipeline, next)
+	return c
+}
+
+// Then adds a statement that runs after the command.
+func (c *PwshCommand) Then(next *PwshCommand) *PwshCommand {
+	c.statements = append(c.statements, next)
+	return c
+}
+
+// ToJson converts the output of the command to compressed JSON.
+func (c *PwshCommand) ToJson() *PwshCommand {
+	c.output = pwshOutputJson
+	return c
+}
+
+// ToJsonArray converts the output of the command to a compressed JSON array.
+// The output is always an array, even if the command returns a single object.
+// If no variable is assigned, the output is stored in $r.
+func (c *PwshCommand) ToJsonArray() *PwshCommand {
+	c.output = pwshOutputJsonArray
+	return c
+}
+
+// String renders the full PowerShell command.
+func (c *PwshCommand) String() string {
+	cmd := []string{c.render()}
+	for _, statement := range c.statements {
+		cmd = append(cmd, fmt.Sprintf(";%s", statement.String()))
+	}
+
+	return strings.Join(cmd, " ")
+}
+
+// render renders the command with its pipeline and output handling,
+// but without the following statements.
+func (c *PwshCommand) render() string {
+	cmd := []string{c.cmdlet}
+	cmd = append(cmd, c.parameters...)
+
+	for _, next := range c.pipeline {
+		cmd = append(cmd, "|", next.String())
+	}
+
+	if c.output == pwshOutputJson {
+		cmd = append(cmd, "| ConvertTo-Json -Compress")
+	}
+
+	variable := c.variable
+	if c.output == pwshOutputJsonArray && variable == "" {
+		variable = defaultPwshVariable
+	}
+
+	if variable != "" {
+		cmd[0] = fmt.Sprintf("$%s=%s", variable, cmd[0])
+	}
+
+	// Wrap single objects into an array.
+	if c.output == pwshOutputJsonArray {
+		cmd = append(cmd, fmt.Sprintf(";if($%[1]s.Count -ge 2){ConvertTo-Json $%[1]s -Compress}else{ConvertTo-Json @($%[1]s) -Compress}", variable))
+	}
+
+	return strings.Join(cmd, " ")
+}
+
+// PwshValue renders a Go value as a PowerShell expression.
+// See PwshCommand for the supported types. Values of other types are rendered
+// as a single-quoted literal of their default string representation.
+func PwshValue(value any) string {
+	switch v := value.(type) {
+	case PwshRaw:
+		return string(v)
+	case PwshSensitive:
+		return pwshSensitiveMarker + PwshQuote(string(v))
+	case string:
+		return PwshQuote(v)
+	case bool:
+		return pwshBool(v)
+	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64:
+		return fmt.Sprintf("%d", v)
+	case netip.Addr:
+		return PwshQuote(v.String())
+	case time.Duration:
+		return PwshTimespanString(v)
+	case time.Time:
+		return fmt.Sprintf("$(Get-Date %s)", PwshQuote(v.Format(time.DateTime)))
+	case fmt.Stringer:
+		return PwshQuote(v.String())
+	}
+
+	// Render slices and arrays as PowerShell arrays.
+	rv := reflect.ValueOf(value)
+	if rv.Kind() == reflect.Slice || rv.Kind() == reflect.Array {
+		values := make([]string, rv.Len())
+		for i := range values {
+			values[i] = PwshValue(rv.Index(i).Interface())
+		}
+		return fmt.Sprintf("@(%s)", strings.Join(values, ","))
+	}
+
+	return PwshQuote(fmt.Sprint(value))
+}
+
+// pwshBool renders a boolean as a PowerShell boolean.
+func pwshBool(b bool) string {
+	if b {
+		return "$true"
+	}
+	return "$false"
+}
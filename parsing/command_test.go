@@ -0,0 +1,154 @@
+package parsing
+
+import (
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPwshValue(t *testing.T) {
+	t.Parallel()
+
+	tcs := []struct {
+		description    string
+		inputValue     any
+		expectedString string
+	}{
+		{
+			"string",
+			"O'Brien",
+			"'O''Brien'",
+		},
+		{
+			"raw expression",
+			PwshRaw("$r"),
+			"$r",
+		},
+		{
+			"boolean",
+			true,
+			"$true",
+		},
+		{
+			"integer",
+			int32(-42),
+			"-42",
+		},
+		{
+			"unsigned integer",
+			uint16(8080),
+			"8080",
+		},
+		{
+			"ip address",
+			netip.MustParseAddr("192.168.10.1"),
+			"'192.168.10.1'",
+		},
+		{
+			"duration",
+			26*time.Hour + 30*time.Minute + 15*time.Second,
+			"$(New-TimeSpan -Days 1 -Hours 2 -Minutes 30 -Seconds 15)",
+		},
+		{
+			"time",
+			time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC),
+			"$(Get-Date '2024-01-02 03:04:05')",
+		},
+		{
+			"slice of strings",
+			[]string{"a", "b'c"},
+			"@('a','b''c')",
+		},
+		{
+			"slice of ip addresses",
+			[]netip.Addr{netip.MustParseAddr("::1"), netip.MustParseAddr("fe80::1")},
+			"@('::1','fe80::1')",
+		},
+		{
+			"empty slice",
+			[]string{},
+			"@()",
+		},
+		{
+			"fallback to quoted string",
+			1.5,
+			"'1.5'",
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.description, func(t *testing.T) {
+			actualString := PwshValue(tc.inputValue)
+			assert.Equal(t, tc.expectedString, actualString)
+		})
+	}
+}
+
+func TestPwshCommand(t *testing.T) {
+	t.Parallel()
+
+	tcs := []struct {
+		description    string
+		inputCommand   *PwshCommand
+		expectedString string
+	}{
+		{
+			"cmdlet without parameters",
+			NewPwshCommand("Get-LocalUser"),
+			"Get-LocalUser",
+		},
+		{
+			"parameters and switches",
+			NewPwshCommand("New-LocalUser").
+				Param("Name", "test").
+				Param("Disabled", false).
+				Switch("NoPassword").
+				Param("PasswordNeverExpires", true),
+			"New-LocalUser -Name 'test' -Disabled:$false -NoPassword -PasswordNeverExpires:$true",
+		},
+		{
+			"json output",
+			NewPwshCommand("Get-LocalUser").Param("Name", "test").ToJson(),
+			"Get-LocalUser -Name 'test' | ConvertTo-Json -Compress",
+		},
+		{
+			"json array output with default variable",
+			NewPwshCommand("Get-DnsServerResourceRecord").Param("RRType", "A").ToJsonArray(),
+			"$r=Get-DnsServerResourceRecord -RRType 'A' ;if($r.Count -ge 2){ConvertTo-Json $r -Compress}else{ConvertTo-Json @($r) -Compress}",
+		},
+		{
+			"json array output with assigned variable",
+			NewPwshCommand("Get-LocalGroupMember").Param("Name", "test").Assign("gm").ToJsonArray(),
+			"$gm=Get-LocalGroupMember -Name 'test' ;if($gm.Count -ge 2){ConvertTo-Json $gm -Compress}else{ConvertTo-Json @($gm) -Compress}",
+		},
+		{
+			"pipeline",
+			NewPwshCommand("Get-LocalUser").
+				Pipe(NewPwshCommand("Where-Object").Param("Property", "Enabled")).
+				ToJson(),
+			"Get-LocalUser | Where-Object -Property 'Enabled' | ConvertTo-Json -Compress",
+		},
+		{
+			"following statements",
+			NewPwshCommand("Set-LocalUser").
+				Param("Name", "test").
+				Then(NewPwshCommand("Enable-LocalUser").Param("Name", "test")).
+				Then(NewPwshCommand("Get-LocalUser").Param("Name", "test").ToJson()),
+			"Set-LocalUser -Name 'test' ;Enable-LocalUser -Name 'test' ;Get-LocalUser -Name 'test' | ConvertTo-Json -Compress",
+		},
+		{
+			"injection attempt in parameter value",
+			NewPwshCommand("Get-LocalUser").Param("Name", "x'; Remove-LocalUser -Name 'Administrator"),
+			"Get-LocalUser -Name 'x''; Remove-LocalUser -Name ''Administrator'",
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.description, func(t *testing.T) {
+			actualString := tc.inputCommand.String()
+			assert.Equal(t, tc.expectedString, actualString)
+		})
+	}
+}
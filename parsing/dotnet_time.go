@@ -26,7 +26,7 @@ func (t *DotnetTime) UnmarshalJSON(b []byte) error {
 	// Check for valid dotnet timestring
 	re, err := regexp.Compile(`^"\\/Date\(\d+\)\\/"$`)
 	if err != nil {
-		return fmt.Errorf("parsing.DotnetTime.UnmarshalJSON: %s", err)
+		return fmt.Errorf("parsing.DotnetTime.UnmarshalJSON: %w", err)
 	}
 
 	if !re.Match(b) {
@@ -36,14 +36,14 @@ func (t *DotnetTime) UnmarshalJSON(b []byte) error {
 	// Extract timestamp
 	re, err = regexp.Compile(`\d+`)
 	if err != nil {
-		return fmt.Errorf("parsing.DotnetTime.UnmarshalJSON: %s", err)
+		return fmt.Errorf("parsing.DotnetTime.UnmarshalJSON: %w", err)
 	}
 	timestamp := re.Find(b)
 
 	// Convert to seconds
 	i, err := strconv.Atoi(string(timestamp))
 	if err != nil {
-		return fmt.Errorf("parsing.DotnetTime.UnmarshalJSON: %s", err)
+		return fmt.Errorf("parsing.DotnetTime.UnmarshalJSON: %w", err)
 	}
 	seconds := int64(i / 1000)
 
@@ -0,0 +1,231 @@
+package parsing
+
+import (
+	"encoding/xml"
+	"errors"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// ErrorRecord represents a PowerShell error record parsed from the error stream.
+type ErrorRecord struct {
+	// FullyQualifiedErrorId is the error id followed by the source of the error,
+	// e.g. "UserNotFound,Microsoft.PowerShell.Commands.GetLocalUserCommand".
+	FullyQualifiedErrorId string
+
+	// CategoryInfo contains the category and the origin of the error.
+	CategoryInfo CategoryInfo
+
+	// Exception contains the type and the message of the underlying exception.
+	Exception ExceptionInfo
+
+	// ScriptPosition contains the position of the failed statement.
+	ScriptPosition ScriptPosition
+}
+
+// ErrorId returns the error id without the source of the error,
+// e.g. "UserNotFound" for "UserNotFound,Microsoft.PowerShell.Commands.GetLocalUserCommand".
+func (r ErrorRecord) ErrorId() string {
+	id, _, _ := strings.Cut(r.FullyQualifiedErrorId, ",")
+	return id
+}
+
+// CategoryInfo represents the category information of a PowerShell error record.
+// It is rendered by PowerShell as "Category: (TargetName:TargetType) [Activity], Reason".
+type CategoryInfo struct {
+	Category   string
+	Activity   string
+	Reason     string
+	TargetName string
+	TargetType string
+}
+
+// ExceptionInfo represents the exception of a PowerShell error record.
+type ExceptionInfo struct {
+	// Type is the name of the exception type as reported by the category reason.
+	Type string
+
+	// Message is the message of the exception without the name of the failed command.
+	Message string
+}
+
+// ScriptPosition represents the position of the failed statement in a PowerShell script.
+type ScriptPosition struct {
+	// ScriptName is empty for commands that are not executed from a script file.
+	ScriptName string
+	Line       int
+	Column     int
+	Text       string
+}
+
+var (
+	// errorRecordPositionRe matches the position line, e.g. "At line:1 char:101" or "At C:\test.ps1:12 char:5".
+	errorRecordPositionRe = regexp.MustCompile(`^At (?:line|(.+)):(\d+) char:(\d+)$`)
+
+	// errorRecordInfoRe matches the lines of the error record details, e.g. "+ CategoryInfo : ...".
+	errorRecordInfoRe = regexp.MustCompile(`^\+?\s*(CategoryInfo|FullyQualifiedErrorId)\s*:\s?(.*)$`)
+
+	// errorRecordCategoryRe matches the category info, e.g. "ObjectNotFound: (test:String) [Get-LocalUser], UserNotFoundException".
+	errorRecordCategoryRe = regexp.MustCompile(`^(\w+): \((.*)\) \[(.*)\], ?(.*)$`)
+
+	// errorRecordCommandRe matches the name of the failed command in front of the error message.
+	errorRecordCommandRe = regexp.MustCompile(`^[A-Za-z]+-[A-Za-z0-9]+ : `)
+)
+
+// errorRecordParser collects the lines of a single error record.
+type errorRecordParser struct {
+	message  strings.Builder
+	script   []string
+	position bool
+	info     string
+	category string
+	record   ErrorRecord
+}
+
+// empty reports whether no line was added to the error record.
+func (p *errorRecordParser) empty() bool {
+	return p.message.Len() == 0 && !p.position && p.info == ""
+}
+
+// add adds a line to the error record.
+// It returns false if the line belongs to the next error record.
+func (p *errorRecordParser) add(line string) bool {
+	trimmed := strings.TrimSpace(line)
+
+	// Details of the error record, e.g. "+ CategoryInfo : ...".
+	if m := errorRecordInfoRe.FindStringSubmatch(trimmed); m != nil {
+		p.info = m[1]
+		if p.info == "CategoryInfo" {
+			p.category = m[2]
+		} else {
+			p.record.FullyQualifiedErrorId = strings.TrimSpace(m[2])
+		}
+		return true
+	}
+
+	// Wrapped lines of the details are indented.
+	if p.info != "" {
+		if strings.TrimLeft(line, " \t") != line && !strings.HasPrefix(trimmed, "+") {
+			if p.info == "CategoryInfo" {
+				p.category += trimmed
+			} else {
+				p.record.FullyQualifiedErrorId += trimmed
+			}
+			return true
+		}
+
+		// Any other line after the details starts a new error record.
+		return false
+	}
+
+	// Position of the failed statement followed by the statement itself.
+	if m := errorRecordPositionRe.FindStringSubmatch(trimmed); m != nil {
+		p.position = true
+		p.record.ScriptPosition.ScriptName = m[1]
+		p.record.ScriptPosition.Line, _ = strconv.Atoi(m[2])
+		p.record.ScriptPosition.Column, _ = strconv.Atoi(m[3])
+		return true
+	}
+
+	if p.position {
+		text := strings.TrimSpace(strings.TrimPrefix(trimmed, "+"))
+
+		// Skip the marker line below the statement, e.g. "+ ~~~~~".
+		if strings.Trim(text, "~ ") != "" {
+			p.script = append(p.script, text)
+		}
+		return true
+	}
+
+	// Wrapped lines of the message are written as they are.
+	p.message.WriteString(line)
+	return true
+}
+
+// errorRecord returns the parsed error record.
+func (p *errorRecordParser) errorRecord() ErrorRecord {
+	r := p.record
+	r.ScriptPosition.Text = strings.Join(p.script, " ")
+
+	if m := errorRecordCategoryRe.FindStringSubmatch(strings.TrimSpace(p.category)); m != nil {
+		r.CategoryInfo.Category = m[1]
+		r.CategoryInfo.Activity = m[3]
+		r.CategoryInfo.Reason = m[4]
+
+		// The target name can contain colons, so the type is separated by the last colon.
+		if i := strings.LastIndex(m[2], ":"); i >= 0 {
+			r.CategoryInfo.TargetName = m[2][:i]
+			r.CategoryInfo.TargetType = m[2][i+1:]
+		} else {
+			r.CategoryInfo.TargetName = m[2]
+		}
+	}
+
+	r.Exception.Type = r.CategoryInfo.Reason
+	r.Exception.Message = strings.TrimSpace(errorRecordCommandRe.ReplaceAllString(strings.TrimSpace(p.message.String()), ""))
+
+	return r
+}
+
+// ParseErrorRecords parses the formatted PowerShell error output into error records.
+// Every line of the text must be a line of the PowerShell console output.
+// Error records are separated by blank lines or start after the details of the previous record.
+func ParseErrorRecords(text string) []ErrorRecord {
+	var records []ErrorRecord
+	p := &errorRecordParser{}
+
+	flush := func() {
+		if !p.empty() {
+			records = append(records, p.errorRecord())
+		}
+		p = &errorRecordParser{}
+	}
+
+	for _, line := range strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n") {
+		if strings.TrimSpace(line) == "" {
+			flush()
+			continue
+		}
+
+		if !p.add(line) {
+			flush()
+			p.add(line)
+		}
+	}
+	flush()
+
+	return records
+}
+
+// clixmlStream represents the structure for unmarshaling the streams of a CLIXML document.
+type clixmlStream struct {
+	S []struct {
+		Stream string `xml:"S,attr"`
+		Text   string `xml:",chardata"`
+	} `xml:"S"`
+}
+
+// DecodeCliXmlErrRecords converts the error stream of a CLIXML error string to PowerShell error records.
+func DecodeCliXmlErrRecords(text string) ([]ErrorRecord, error) {
+	// Check if input string is a valid CLIXML document
+	if !strings.Contains(text, "#< CLIXML") {
+		return nil, errors.New("parsing.DecodeCliXmlErrRecords: the input string is not a CLIXML error string")
+	}
+
+	// Unmarshal to XML
+	clixml := &clixmlStream{}
+	if err := xml.Unmarshal([]byte(strings.ReplaceAll(text, "#< CLIXML", "")), clixml); err != nil {
+		return nil, err
+	}
+
+	// Every line of the console output ends with an encoded CRLF.
+	var b strings.Builder
+	for _, s := range clixml.S {
+		if s.Stream == "Error" {
+			b.WriteString(strings.ReplaceAll(s.Text, "_x000D__x000A_", "\n"))
+		}
+	}
+
+	return ParseErrorRecords(b.String()), nil
+}
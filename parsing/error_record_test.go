@@ -0,0 +1,138 @@
+package parsing
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+// Unit test suite for all error record parsing functions
+type ErrorRecordUnitTestSuite struct {
+	suite.Suite
+	// Fixtures
+	cliXMLError          string
+	expectedErrorRecords []ErrorRecord
+}
+
+func (suite *ErrorRecordUnitTestSuite) SetupSuite() {
+	// Fixtures
+	suite.cliXMLError = `#< CLIXML
+<Objs Version="1.1.0.1" xmlns="http://schemas.microsoft.com/powershell/2004/04"><Obj S="progress" RefId="0"><TN RefId="0"><T>System.Management.Automation.PSCustomObject</T><T>System.Object</T></TN><MS><I64 N="SourceId">1</I64><PR N="Record"><AV>Preparing modules for first use.</AV><AI>0</AI><Nil /><PI>-1</PI><PC>-1</PC><T>Completed</T><SR>-1</SR><SD> </SD></PR></MS></Obj>` +
+		`<S S="Error">Get-LocalUser : User Test-User was not found._x000D__x000A_</S>` +
+		`<S S="Error">At line:1 char:1_x000D__x000A_</S>` +
+		`<S S="Error">+ Get-LocalUser -Name 'Test-User' | ConvertTo-Json -Compress_x000D__x000A_</S>` +
+		`<S S="Error">+ ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~_x000D__x000A_</S>` +
+		`<S S="Error">    + CategoryInfo          : ObjectNotFound: (Test-User:String) [Get-LocalUser], UserNotFoundExc _x000D__x000A_</S>` +
+		`<S S="Error">   eption_x000D__x000A_</S>` +
+		`<S S="Error">    + FullyQualifiedErrorId : UserNotFound,Microsoft.PowerShell.Commands.GetLocalUserCommand_x000D__x000A_</S>` +
+		`<S S="Error"> _x000D__x000A_</S>` +
+		`<S S="warning">This is a warning._x000D__x000A_</S>` +
+		`<S S="Error">Set-ADOrganizationalUnit : A parameter cannot be found that matches parameter _x000D__x000A_</S>` +
+		`<S S="Error">name 'Path'._x000D__x000A_</S>` +
+		`<S S="Error">At C:\scripts\ou.ps1:12 char:101_x000D__x000A_</S>` +
+		`<S S="Error">+ ... e description" -Path "DC=yourdomain,DC=com" _x000D__x000A_</S>` +
+		`<S S="Error">-ProtectedFromAccidentalDeletion $tr ..._x000D__x000A_</S>` +
+		`<S S="Error">+                    ~~~~~_x000D__x000A_</S>` +
+		`<S S="Error">    + CategoryInfo          : InvalidArgument: (:) [Set-ADOrganizationalUnit], _x000D__x000A_</S>` +
+		`<S S="Error">    ParameterBindingException_x000D__x000A_</S>` +
+		`<S S="Error">    + FullyQualifiedErrorId : NamedParameterNotFound,Microsoft.ActiveDirectory _x000D__x000A_</S>` +
+		`<S S="Error">   .Management.Commands.SetADOrganizationalUnit_x000D__x000A_</S>` +
+		`<S S="Error"> _x000D__x000A_</S></Objs>`
+
+	suite.expectedErrorRecords = []ErrorRecord{
+		{
+			FullyQualifiedErrorId: "UserNotFound,Microsoft.PowerShell.Commands.GetLocalUserCommand",
+			CategoryInfo: CategoryInfo{
+				Category:   "ObjectNotFound",
+				Activity:   "Get-LocalUser",
+				Reason:     "UserNotFoundException",
+				TargetName: "Test-User",
+				TargetType: "String",
+			},
+			Exception: ExceptionInfo{
+				Type:    "UserNotFoundException",
+				Message: "User Test-User was not found.",
+			},
+			ScriptPosition: ScriptPosition{
+				Line:   1,
+				Column: 1,
+				Text:   "Get-LocalUser -Name 'Test-User' | ConvertTo-Json -Compress",
+			},
+		},
+		{
+			FullyQualifiedErrorId: "NamedParameterNotFound,Microsoft.ActiveDirectory.Management.Commands.SetADOrganizationalUnit",
+			CategoryInfo: CategoryInfo{
+				Category: "InvalidArgument",
+				Activity: "Set-ADOrganizationalUnit",
+				Reason:   "ParameterBindingException",
+			},
+			Exception: ExceptionInfo{
+				Type:    "ParameterBindingException",
+				Message: "A parameter cannot be found that matches parameter name 'Path'.",
+			},
+			ScriptPosition: ScriptPosition{
+				ScriptName: `C:\scripts\ou.ps1`,
+				Line:       12,
+				Column:     101,
+				Text:       `... e description" -Path "DC=yourdomain,DC=com" -ProtectedFromAccidentalDeletion $tr ...`,
+			},
+		},
+	}
+}
+
+func TestErrorRecordUnitTestSuite(t *testing.T) {
+	suite.Run(t, &ErrorRecordUnitTestSuite{})
+}
+
+func (suite *ErrorRecordUnitTestSuite) TestDecodeCliXmlErrRecords() {
+	suite.T().Parallel()
+
+	suite.Run("should return the error records of the error stream", func() {
+		actualResult, err := DecodeCliXmlErrRecords(suite.cliXMLError)
+		suite.Require().NoError(err)
+		suite.Equal(suite.expectedErrorRecords, actualResult)
+	})
+	suite.Run("should return error if not a clixml string", func() {
+		actualResult, err := DecodeCliXmlErrRecords("Get-LocalUser : User Test-User was not found.")
+		suite.Error(err)
+		suite.Nil(actualResult)
+	})
+	suite.Run("should return error if the clixml is invalid", func() {
+		_, err := DecodeCliXmlErrRecords("#< CLIXML\n<Objs><S S=\"Error\">")
+		suite.Error(err)
+	})
+}
+
+func (suite *ErrorRecordUnitTestSuite) TestParseErrorRecords() {
+	suite.T().Parallel()
+
+	suite.Run("should parse records without a separating blank line", func() {
+		text := "Remove-LocalUser : User a was not found.\r\n" +
+			"    + CategoryInfo          : ObjectNotFound: (a:LocalUser) [Remove-LocalUser], UserNotFoundException\r\n" +
+			"    + FullyQualifiedErrorId : UserNotFound,Microsoft.PowerShell.Commands.RemoveLocalUserCommand\r\n" +
+			"Remove-LocalUser : Access denied.\r\n" +
+			"    + CategoryInfo          : PermissionDenied: (b:LocalUser) [Remove-LocalUser], AccessDeniedException\r\n" +
+			"    + FullyQualifiedErrorId : AccessDenied,Microsoft.PowerShell.Commands.RemoveLocalUserCommand\r\n"
+		actualResult := ParseErrorRecords(text)
+		suite.Require().Len(actualResult, 2)
+		suite.Equal("UserNotFound", actualResult[0].ErrorId())
+		suite.Equal("a", actualResult[0].CategoryInfo.TargetName)
+		suite.Equal("AccessDenied", actualResult[1].ErrorId())
+		suite.Equal("PermissionDenied", actualResult[1].CategoryInfo.Category)
+		suite.Equal("Access denied.", actualResult[1].Exception.Message)
+	})
+	suite.Run("should split the target at the last colon", func() {
+		text := "+ CategoryInfo : ObjectNotFound: (C:\\test:String) [Get-Item], ItemNotFoundException"
+		actualResult := ParseErrorRecords(text)
+		suite.Require().Len(actualResult, 1)
+		suite.Equal("C:\\test", actualResult[0].CategoryInfo.TargetName)
+		suite.Equal("String", actualResult[0].CategoryInfo.TargetType)
+	})
+	suite.Run("should return a record with the message for unformatted errors", func() {
+		actualResult := ParseErrorRecords("something went wrong")
+		suite.Equal([]ErrorRecord{{Exception: ExceptionInfo{Message: "something went wrong"}}}, actualResult)
+	})
+	suite.Run("should return no records for empty text", func() {
+		suite.Nil(ParseErrorRecords(" \r\n"))
+	})
+}
@@ -0,0 +1,36 @@
+package parsing
+
+import (
+	"strings"
+)
+
+// pwshSingleQuotes contains all characters that PowerShell treats as a single quote.
+// Besides the ASCII apostrophe, PowerShell also accepts the typographic quotation marks
+// as string delimiters, so they must be escaped as well.
+// https://github.com/PowerShell/PowerShell/blob/master/src/System.Management.Automation/engine/parser/CharTraits.cs
+const pwshSingleQuotes string = "'‘’‚‛"
+
+// IsPwshSingleQuote reports whether the rune is treated as a single quote by PowerShell.
+func IsPwshSingleQuote(r rune) bool {
+	return strings.ContainsRune(pwshSingleQuotes, r)
+}
+
+// PwshQuote returns the string as a single-quoted PowerShell string literal.
+// Every single quote character inside the string is doubled, so the input
+// can never terminate the literal and is never evaluated by PowerShell.
+func PwshQuote(s string) string {
+	var b strings.Builder
+	b.Grow(len(s) + 2)
+
+	b.WriteRune('\'')
+	for _, r := range s {
+		// A quote character is escaped by a following quote character.
+		if IsPwshSingleQuote(r) {
+			b.WriteRune(r)
+		}
+		b.WriteRune(r)
+	}
+	b.WriteRune('\'')
+
+	return b.String()
+}
@@ -0,0 +1,114 @@
+package parsing
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// unquotePwsh parses a single-quoted PowerShell string literal the same way the
+// PowerShell tokenizer does and returns the value and the unparsed remainder.
+func unquotePwsh(s string) (string, string, error) {
+	runes := []rune(s)
+	if len(runes) == 0 || !IsPwshSingleQuote(runes[0]) {
+		return "", s, errors.New("literal does not start with a single quote")
+	}
+
+	var value []rune
+	for i := 1; i < len(runes); i++ {
+		r := runes[i]
+		if IsPwshSingleQuote(r) {
+			// A single quote followed by another single quote is an escaped quote.
+			if i+1 < len(runes) && IsPwshSingleQuote(runes[i+1]) {
+				i++
+				value = append(value, runes[i])
+				continue
+			}
+			return string(value), string(runes[i+1:]), nil
+		}
+		value = append(value, r)
+	}
+
+	return "", "", errors.New("literal is not terminated")
+}
+
+func TestPwshQuote(t *testing.T) {
+	t.Parallel()
+
+	tcs := []struct {
+		description    string
+		input          string
+		expectedString string
+	}{
+		{
+			"empty string",
+			"",
+			"''",
+		},
+		{
+			"string without quotes",
+			"Test-User",
+			"'Test-User'",
+		},
+		{
+			"string with a single quote",
+			"O'Brien",
+			"'O''Brien'",
+		},
+		{
+			"string with typographic quotes",
+			"it‘s ’quoted‚‛",
+			"'it‘‘s ’’quoted‚‚‛‛'",
+		},
+		{
+			"string with subexpression and double quotes",
+			`"$(Remove-Item C:\ -Recurse)"`,
+			`'"$(Remove-Item C:\ -Recurse)"'`,
+		},
+		{
+			"injection attempt",
+			"x'; Remove-LocalUser -Name 'Administrator",
+			"'x''; Remove-LocalUser -Name ''Administrator'",
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.description, func(t *testing.T) {
+			actualString := PwshQuote(tc.input)
+			assert.Equal(t, tc.expectedString, actualString)
+		})
+	}
+}
+
+func FuzzPwshQuote(f *testing.F) {
+	for _, seed := range []string{
+		"",
+		"test",
+		"'",
+		"''",
+		"'; Get-Process; '",
+		"‘’‚‛",
+		"'‘",
+		"$(whoami)",
+		"\x00\xff",
+		"line\r\nbreak",
+	} {
+		f.Add(seed)
+	}
+
+	f.Fuzz(func(t *testing.T, s string) {
+		value, remainder, err := unquotePwsh(PwshQuote(s))
+		if err != nil {
+			t.Fatalf("quoted string %q is not a valid literal: %s", s, err)
+		}
+
+		// The literal must span the whole output and contain the unmodified input.
+		if remainder != "" {
+			t.Fatalf("input %q escapes its literal with remainder %q", s, remainder)
+		}
+		if value != string([]rune(s)) {
+			t.Fatalf("input %q is parsed as %q", s, value)
+		}
+	})
+}
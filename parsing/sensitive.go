@@ -0,0 +1,158 @@
+package parsing
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+// PwshSensitive is a string value that must not show up in errors or logs, e.g. a password.
+// It is rendered by PwshValue as a quoted literal with a leading sensitive marker comment,
+// so RedactPwshCmd can replace the value in the rendered command.
+// PowerShell ignores the marker, so the real value is still sent to the Windows system.
+type PwshSensitive string
+
+// String returns the redacted placeholder, so the value never shows up in formatted output.
+func (s PwshSensitive) String() string {
+	return pwshRedacted
+}
+
+// GoString returns the redacted placeholder for the %#v verb.
+func (s PwshSensitive) GoString() string {
+	return pwshRedacted
+}
+
+const (
+	// pwshSensitiveMarker is the block comment in front of a sensitive value.
+	pwshSensitiveMarker string = "<#sensitive#>"
+
+	// pwshRedactedValue is the placeholder of a redacted value without quotes.
+	pwshRedactedValue string = "***"
+
+	// pwshRedacted is the placeholder of a redacted value.
+	pwshRedacted string = "'" + pwshRedactedValue + "'"
+)
+
+// RedactPwshCmd replaces all sensitive values in a rendered PowerShell command with a placeholder.
+// A value that is cut off, e.g. in the script position of an error message, is redacted up to the end of its line.
+func RedactPwshCmd(cmd string) string {
+	if !strings.Contains(cmd, pwshSensitiveMarker) {
+		return cmd
+	}
+
+	var b strings.Builder
+	b.Grow(len(cmd))
+
+	for {
+		i := strings.Index(cmd, pwshSensitiveMarker)
+		if i < 0 {
+			b.WriteString(cmd)
+			return b.String()
+		}
+
+		b.WriteString(cmd[:i+len(pwshSensitiveMarker)])
+		cmd = cmd[i+len(pwshSensitiveMarker):]
+
+		// Values are always quoted, see PwshValue.
+		if r, _ := utf8.DecodeRuneInString(cmd); !IsPwshSingleQuote(r) {
+			continue
+		}
+
+		b.WriteString(pwshRedacted)
+		cmd = cmd[pwshQuotedLen(cmd):]
+	}
+}
+
+// pwshQuotedLen returns the length of the single-quoted literal at the start of s.
+// A quote character followed by another quote character is an escaped quote.
+// An unterminated literal ends at the end of its first line.
+func pwshQuotedLen(s string) int {
+	_, size := utf8.DecodeRuneInString(s)
+	i := size
+
+	for i < len(s) {
+		r, size := utf8.DecodeRuneInString(s[i:])
+		if !IsPwshSingleQuote(r) {
+			i += size
+			continue
+		}
+
+		next, nextSize := utf8.DecodeRuneInString(s[i+size:])
+		if !IsPwshSingleQuote(next) {
+			return i + size
+		}
+		i += size + nextSize
+	}
+
+	if j := strings.IndexAny(s, "\r\n"); j >= 0 {
+		return j
+	}
+	return len(s)
+}
+
+// PwshSensitiveValues returns the sensitive values of a rendered PowerShell command, see PwshSensitive.
+// The values are returned without quotes and escaped quotes, so they can be scrubbed from other texts, e.g. logs or recordings.
+func PwshSensitiveValues(cmd string) []string {
+	var values []string
+
+	for {
+		i := strings.Index(cmd, pwshSensitiveMarker)
+		if i < 0 {
+			return values
+		}
+		cmd = cmd[i+len(pwshSensitiveMarker):]
+
+		if r, _ := utf8.DecodeRuneInString(cmd); !IsPwshSingleQuote(r) {
+			continue
+		}
+
+		n := pwshQuotedLen(cmd)
+		values = append(values, pwshUnquote(cmd[:n]))
+		cmd = cmd[n:]
+	}
+}
+
+// RedactPwshSensitiveValues redacts the sensitive values of a command, see PwshSensitiveValues, from a text of its result,
+// e.g. an error message that contains a value without its marker.
+func RedactPwshSensitiveValues(text string, values []string) string {
+	text = RedactPwshCmd(text)
+
+	for _, value := range values {
+		if value == "" {
+			continue
+		}
+		text = strings.ReplaceAll(text, PwshQuote(value), pwshRedacted)
+		text = strings.ReplaceAll(text, value, pwshRedactedValue)
+	}
+
+	return text
+}
+
+// pwshUnquote returns the value of a single-quoted literal.
+// Escaped quote characters are unescaped and a missing closing quote is ignored.
+func pwshUnquote(s string) string {
+	_, size := utf8.DecodeRuneInString(s)
+	s = s[size:]
+
+	var b strings.Builder
+	b.Grow(len(s))
+
+	for i := 0; i < len(s); {
+		r, size := utf8.DecodeRuneInString(s[i:])
+		i += size
+		if !IsPwshSingleQuote(r) {
+			b.WriteRune(r)
+			continue
+		}
+
+		// A quote character followed by another quote character is an escaped quote,
+		// any other quote character closes the literal.
+		next, nextSize := utf8.DecodeRuneInString(s[i:])
+		if !IsPwshSingleQuote(next) {
+			break
+		}
+		b.WriteRune(r)
+		i += nextSize
+	}
+
+	return b.String()
+}
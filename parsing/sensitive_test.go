@@ -0,0 +1,171 @@
+package parsing
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRedactPwshCmd(t *testing.T) {
+	t.Parallel()
+
+	tcs := []struct {
+		description    string
+		input          string
+		expectedString string
+	}{
+		{
+			"command without sensitive values",
+			"Get-LocalUser -Name 'Test-User'",
+			"Get-LocalUser -Name 'Test-User'",
+		},
+		{
+			"sensitive value",
+			"$(ConvertTo-SecureString -String <#sensitive#>'secret' -AsPlainText -Force)",
+			"$(ConvertTo-SecureString -String <#sensitive#>'***' -AsPlainText -Force)",
+		},
+		{
+			"multiple sensitive values",
+			"Test -A <#sensitive#>'a' -B 'b' -C <#sensitive#>'c'",
+			"Test -A <#sensitive#>'***' -B 'b' -C <#sensitive#>'***'",
+		},
+		{
+			"sensitive value with escaped quotes",
+			"Test -A <#sensitive#>'it''s a ‘‘secret’’' -B 'b'",
+			"Test -A <#sensitive#>'***' -B 'b'",
+		},
+		{
+			"sensitive value ending with an escaped quote",
+			"Test -A <#sensitive#>'secret''' -B 'b'",
+			"Test -A <#sensitive#>'***' -B 'b'",
+		},
+		{
+			"cut off sensitive value",
+			"+ ... -String <#sensitive#>'sec ...\r\n+ ~~~~",
+			"+ ... -String <#sensitive#>'***'\r\n+ ~~~~",
+		},
+		{
+			"marker without a value",
+			"Test <#sensitive#> -A 'a'",
+			"Test <#sensitive#> -A 'a'",
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.description, func(t *testing.T) {
+			assert.Equal(t, tc.expectedString, RedactPwshCmd(tc.input))
+		})
+	}
+}
+
+func TestPwshSensitive(t *testing.T) {
+	t.Parallel()
+
+	t.Run("should render the value with the sensitive marker", func(t *testing.T) {
+		assert.Equal(t, "<#sensitive#>'it''s secret'", PwshValue(PwshSensitive("it's secret")))
+	})
+
+	t.Run("should not format the value", func(t *testing.T) {
+		s := PwshSensitive("secret")
+		assert.Equal(t, "'***' '***' '***'", fmt.Sprintf("%s %v %#v", s, s, s))
+	})
+}
+
+func FuzzRedactPwshCmd(f *testing.F) {
+	for _, seed := range []string{"secret", "'", "''", "‘’‚‛", "<#sensitive#>'x'", "line\r\nbreak"} {
+		f.Add(seed)
+	}
+
+	f.Fuzz(func(t *testing.T, s string) {
+		cmd := NewPwshCommand("Test").Param("Secret", PwshSensitive(s)).Param("Name", "name").String()
+		expectedCmd := "Test -Secret <#sensitive#>'***' -Name 'name'"
+		if actualCmd := RedactPwshCmd(cmd); actualCmd != expectedCmd {
+			t.Fatalf("input %q is not redacted: %s", s, actualCmd)
+		}
+	})
+}
+
+func TestPwshSensitiveValues(t *testing.T) {
+	t.Parallel()
+
+	tcs := []struct {
+		description    string
+		input          string
+		expectedValues []string
+	}{
+		{
+			"command without sensitive values",
+			"Get-LocalUser -Name 'Test-User'",
+			nil,
+		},
+		{
+			"multiple sensitive values",
+			"Test -A <#sensitive#>'a' -B 'b' -C <#sensitive#>'c'",
+			[]string{"a", "c"},
+		},
+		{
+			"sensitive value with escaped quotes",
+			"Test -A <#sensitive#>'it''s a ‘‘secret’’' -B 'b'",
+			[]string{"it's a ‘secret’"},
+		},
+		{
+			"cut off sensitive value",
+			"+ ... -String <#sensitive#>'sec ...\r\n+ ~~~~",
+			[]string{"sec ..."},
+		},
+		{
+			"marker without a value",
+			"Test <#sensitive#> -A 'a'",
+			nil,
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.description, func(t *testing.T) {
+			assert.Equal(t, tc.expectedValues, PwshSensitiveValues(tc.input))
+		})
+	}
+}
+
+func TestRedactPwshSensitiveValues(t *testing.T) {
+	t.Parallel()
+
+	tcs := []struct {
+		description    string
+		input          string
+		values         []string
+		expectedString string
+	}{
+		{
+			"text without sensitive values",
+			"Cannot find a user with the name 'Test-User'.",
+			[]string{"secret"},
+			"Cannot find a user with the name 'Test-User'.",
+		},
+		{
+			"marked sensitive value",
+			"+ Set-Secret -Value <#sensitive#>'secret'",
+			[]string{"secret"},
+			"+ Set-Secret -Value <#sensitive#>'***'",
+		},
+		{
+			"quoted and bare sensitive values",
+			"Cannot process the value 'it''s secret': it's secret",
+			[]string{"it's secret"},
+			"Cannot process the value '***': ***",
+		},
+		{
+			"empty value",
+			"Cannot process the value ''.",
+			[]string{""},
+			"Cannot process the value ''.",
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.description, func(t *testing.T) {
+			assert.Equal(t, tc.expectedString, RedactPwshSensitiveValues(tc.input, tc.values))
+		})
+	}
+}
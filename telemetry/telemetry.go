@@ -0,0 +1,156 @@
+// Package telemetry provides structured logging and OpenTelemetry tracing for the calls of the Windows subpackages.
+// Every call, e.g. windows.dns.RecordARead, gets a span and a log record with the host, the transport, the cmdlets,
+// the duration, the exit code and the error id of the call. Sensitive values are redacted from the command.
+package telemetry
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"regexp"
+	"time"
+
+	"github.com/d-strobel/gowindows/parsing"
+	"github.com/d-strobel/gowindows/winerror"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Name of the tracer.
+const tracerName = "github.com/d-strobel/gowindows"
+
+// Attribute keys of the spans and the log records.
+const (
+	HostKey      = attribute.Key("server.address")
+	TransportKey = attribute.Key("gowindows.transport")
+	CmdletsKey   = attribute.Key("gowindows.cmdlets")
+	CommandKey   = attribute.Key("gowindows.command")
+	DurationKey  = attribute.Key("gowindows.duration_ms")
+	ExitCodeKey  = attribute.Key("gowindows.exit_code")
+	ErrorIdKey   = attribute.Key("gowindows.error_id")
+)
+
+// cmdletRe matches the cmdlets of a PowerShell command, e.g. Get-LocalUser.
+// Quoted values like 'Test-User' are not matched.
+var cmdletRe = regexp.MustCompile(`(?:^|[\s;|({=])([A-Z][a-zA-Z]+-[A-Z][a-zA-Z0-9]+)\b`)
+
+// Config configures the logging and tracing of the calls.
+type Config struct {
+	// Logger receives a record for every call, at debug level for successful calls and at warn level for failed calls.
+	// If Logger is nil, nothing is logged.
+	Logger *slog.Logger
+
+	// TracerProvider provides the tracer of the spans.
+	// If TracerProvider is nil, the global tracer provider is used.
+	TracerProvider trace.TracerProvider
+
+	// Host is the name or the address of the Windows host.
+	Host string
+
+	// Transport is the transport of the connection, e.g. winrm or ssh.
+	Transport string
+}
+
+// Telemetry logs and traces the calls of the Windows subpackages.
+type Telemetry struct {
+	logger    *slog.Logger
+	tracer    trace.Tracer
+	host      string
+	transport string
+}
+
+// New returns a new Telemetry with the configuration.
+func New(config Config) *Telemetry {
+	tp := config.TracerProvider
+	if tp == nil {
+		tp = otel.GetTracerProvider()
+	}
+
+	return &Telemetry{
+		logger:    config.Logger,
+		tracer:    tp.Tracer(tracerName),
+		host:      config.Host,
+		transport: config.Transport,
+	}
+}
+
+// Observe runs the call of the operation with the PowerShell command in a span and logs its result.
+// The exit code and the error id are taken from a *winerror.WinError returned by the call.
+// A nil Telemetry only runs the call.
+func (t *Telemetry) Observe(ctx context.Context, operation string, cmd string, call func(context.Context) error) error {
+	if t == nil {
+		return call(ctx)
+	}
+
+	attrs := []attribute.KeyValue{
+		HostKey.String(t.host),
+		TransportKey.String(t.transport),
+		CmdletsKey.StringSlice(cmdlets(cmd)),
+		CommandKey.String(parsing.RedactPwshCmd(cmd)),
+	}
+
+	ctx, span := t.tracer.Start(ctx, operation, trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(attrs...))
+	defer span.End()
+
+	start := time.Now()
+	err := call(ctx)
+	duration := time.Since(start)
+
+	// Errors of the connection have no exit code.
+	result := []attribute.KeyValue{DurationKey.Int64(duration.Milliseconds())}
+	var winErr *winerror.WinError
+	if errors.As(err, &winErr) && !errors.Is(err, winerror.ErrTransport) {
+		result = append(result, ExitCodeKey.Int(winErr.ExitCode))
+		if len(winErr.Records) > 0 {
+			result = append(result, ErrorIdKey.String(winErr.Records[0].ErrorId()))
+		}
+	} else if err == nil {
+		result = append(result, ExitCodeKey.Int(0))
+	}
+	span.SetAttributes(result...)
+
+	if err != nil {
+		// The errors of the subpackages are redacted already, errors of the connection may contain the command.
+		msg := parsing.RedactPwshCmd(err.Error())
+		span.RecordError(errors.New(msg))
+		span.SetStatus(codes.Error, msg)
+	}
+
+	t.log(ctx, operation, append(attrs, result...), err)
+
+	return err
+}
+
+// log logs the call of the operation with the attributes of its span.
+func (t *Telemetry) log(ctx context.Context, operation string, attrs []attribute.KeyValue, err error) {
+	if t.logger == nil {
+		return
+	}
+
+	level := slog.LevelDebug
+	logAttrs := []slog.Attr{slog.String("operation", operation)}
+	for _, attr := range attrs {
+		logAttrs = append(logAttrs, slog.Any(string(attr.Key), attr.Value.AsInterface()))
+	}
+	if err != nil {
+		level = slog.LevelWarn
+		logAttrs = append(logAttrs, slog.String("error", parsing.RedactPwshCmd(err.Error())))
+	}
+
+	t.logger.LogAttrs(ctx, level, "gowindows call", logAttrs...)
+}
+
+// cmdlets returns the distinct cmdlets of a PowerShell command in the order of their first appearance.
+func cmdlets(cmd string) []string {
+	var names []string
+	seen := make(map[string]bool)
+	for _, m := range cmdletRe.FindAllStringSubmatch(cmd, -1) {
+		if !seen[m[1]] {
+			seen[m[1]] = true
+			names = append(names, m[1])
+		}
+	}
+	return names
+}
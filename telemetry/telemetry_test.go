@@ -0,0 +1,143 @@
+package telemetry_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/netip"
+	"testing"
+
+	"github.com/d-strobel/gowindows"
+	"github.com/d-strobel/gowindows/connection/fake"
+	"github.com/d-strobel/gowindows/telemetry"
+	"github.com/d-strobel/gowindows/windows/dns"
+	"github.com/d-strobel/gowindows/windows/local/accounts"
+	"github.com/stretchr/testify/suite"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Unit test suite for the logging and tracing of the calls.
+type TelemetryUnitTestSuite struct {
+	suite.Suite
+
+	// Fixtures
+	exporter *tracetest.InMemoryExporter
+	logs     *bytes.Buffer
+	client   *gowindows.Client
+}
+
+func TestTelemetryUnitTestSuite(t *testing.T) {
+	suite.Run(t, &TelemetryUnitTestSuite{})
+}
+
+func (suite *TelemetryUnitTestSuite) SetupTest() {
+	suite.exporter = tracetest.NewInMemoryExporter()
+	suite.logs = &bytes.Buffer{}
+
+	host := fake.NewHost()
+	host.AddZone("example.local")
+
+	suite.client = gowindows.NewClientWithTelemetry(host, telemetry.Config{
+		Logger:         slog.New(slog.NewJSONHandler(suite.logs, &slog.HandlerOptions{Level: slog.LevelDebug})),
+		TracerProvider: sdktrace.NewTracerProvider(sdktrace.WithSyncer(suite.exporter)),
+		Host:           "winsrv",
+		Transport:      "winrm",
+	})
+}
+
+// attributes returns the attributes of a span as a map.
+func attributes(span tracetest.SpanStub) map[attribute.Key]attribute.Value {
+	attrs := make(map[attribute.Key]attribute.Value)
+	for _, attr := range span.Attributes {
+		attrs[attr.Key] = attr.Value
+	}
+	return attrs
+}
+
+// logRecords returns the JSON log records.
+func (suite *TelemetryUnitTestSuite) logRecords() []map[string]any {
+	var records []map[string]any
+	dec := json.NewDecoder(suite.logs)
+	for dec.More() {
+		var record map[string]any
+		suite.Require().NoError(dec.Decode(&record))
+		records = append(records, record)
+	}
+	return records
+}
+
+func (suite *TelemetryUnitTestSuite) TestSuccessfulCall() {
+	_, err := suite.client.Dns.RecordACreate(context.Background(), dns.RecordACreateParams{
+		Name:      "web",
+		Zone:      "example.local",
+		Addresses: []netip.Addr{netip.MustParseAddr("10.0.0.1")},
+	})
+	suite.Require().NoError(err)
+
+	spans := suite.exporter.GetSpans()
+	suite.Require().Len(spans, 1)
+	suite.Equal("windows.dns.RecordACreate", spans[0].Name)
+	suite.Equal(trace.SpanKindClient, spans[0].SpanKind)
+	suite.Equal(codes.Unset, spans[0].Status.Code)
+
+	attrs := attributes(spans[0])
+	suite.Equal("winsrv", attrs[telemetry.HostKey].AsString())
+	suite.Equal("winrm", attrs[telemetry.TransportKey].AsString())
+	suite.Equal([]string{"Add-DnsServerResourceRecordA", "New-TimeSpan", "ConvertTo-Json"}, attrs[telemetry.CmdletsKey].AsStringSlice())
+	suite.Equal(int64(0), attrs[telemetry.ExitCodeKey].AsInt64())
+	suite.Contains(attrs, telemetry.DurationKey)
+	suite.NotContains(attrs, telemetry.ErrorIdKey)
+
+	records := suite.logRecords()
+	suite.Require().Len(records, 1)
+	suite.Equal("DEBUG", records[0]["level"])
+	suite.Equal("windows.dns.RecordACreate", records[0]["operation"])
+	suite.Equal("winsrv", records[0]["server.address"])
+}
+
+func (suite *TelemetryUnitTestSuite) TestFailedCall() {
+	_, err := suite.client.LocalAccounts.UserRead(context.Background(), accounts.UserReadParams{Name: "Missing"})
+	suite.Require().Error(err)
+
+	spans := suite.exporter.GetSpans()
+	suite.Require().Len(spans, 1)
+	suite.Equal("windows.local.accounts.UserRead", spans[0].Name)
+	suite.Equal(codes.Error, spans[0].Status.Code)
+	suite.Equal("UserNotFound", attributes(spans[0])[telemetry.ErrorIdKey].AsString())
+	suite.Require().Len(spans[0].Events, 1)
+	suite.Equal("exception", spans[0].Events[0].Name)
+
+	records := suite.logRecords()
+	suite.Require().Len(records, 1)
+	suite.Equal("WARN", records[0]["level"])
+	suite.Equal("UserNotFound", records[0]["gowindows.error_id"])
+	suite.Contains(records[0]["error"], "Missing")
+}
+
+func (suite *TelemetryUnitTestSuite) TestRedactSecrets() {
+	_, err := suite.client.LocalAccounts.UserCreate(context.Background(), accounts.UserCreateParams{Name: "Test-User", Password: "Start123!!!"})
+	suite.Require().NoError(err)
+
+	spans := suite.exporter.GetSpans()
+	suite.Require().Len(spans, 1)
+	attrs := attributes(spans[0])
+	suite.NotContains(attrs[telemetry.CommandKey].AsString(), "Start123!!!")
+	suite.Contains(attrs[telemetry.CommandKey].AsString(), "New-LocalUser -Name 'Test-User'")
+	suite.NotContains(suite.logs.String(), "Start123!!!")
+}
+
+func (suite *TelemetryUnitTestSuite) TestNilTelemetry() {
+	var t *telemetry.Telemetry
+
+	expectedErr := errors.New("test-error")
+	err := t.Observe(context.Background(), "windows.dns.RecordARead", "Get-DnsServerResourceRecord", func(ctx context.Context) error {
+		return expectedErr
+	})
+	suite.ErrorIs(err, expectedErr)
+}
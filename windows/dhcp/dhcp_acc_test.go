@@ -0,0 +1,38 @@
+package dhcp_test
+
+import (
+	"testing"
+
+	"github.com/d-strobel/gowindows/connection"
+	"github.com/d-strobel/gowindows/internal/acctest"
+	"github.com/d-strobel/gowindows/windows/dhcp"
+	"github.com/stretchr/testify/suite"
+)
+
+// Acceptance test suite for all dhcp functions.
+type DhcpAccTestSuite struct {
+	suite.Suite
+
+	// Fixtures
+	conns   []connection.Connection
+	clients []dhcp.Client
+}
+
+// SetupSuite setups the acceptance test suite for all dhcp functions.
+// We ensure that all commands return the same output with WinRM and SSH.
+func (suite *DhcpAccTestSuite) SetupSuite() {
+	for _, conn := range suite.conns {
+		suite.clients = append(suite.clients, *dhcp.NewClient(conn))
+	}
+}
+
+func (suite *DhcpAccTestSuite) TearDownSuite() {
+	// Close connections and write the recordings
+	for _, c := range suite.clients {
+		suite.NoError(c.Connection.Close())
+	}
+}
+
+func TestDhcpAccTestSuite(t *testing.T) {
+	suite.Run(t, &DhcpAccTestSuite{conns: acctest.Connections(t)})
+}
@@ -46,7 +46,7 @@ func (suite *DhcpServerUnitTestSuite) TestDhcpRun() {
 			RunWithPowershell(ctx, cmd).
 			Return(connection.CmdResult{StdOut: scopeV4Json}, nil)
 		var o scopeObject
-		err := run(ctx, c, cmd, &o)
+		err := run(ctx, c, "windows.dhcp.Test", cmd, &o)
 		suite.NoError(err)
 		suite.Equal(expectedScopeObject, o)
 	})
@@ -0,0 +1,43 @@
+package dhcp
+
+import (
+	"net/netip"
+	"testing"
+
+	"github.com/d-strobel/gowindows/internal/pwshtest"
+)
+
+// FuzzPwshCommandQuoting asserts that user input can never escape its PowerShell argument.
+func FuzzPwshCommandQuoting(f *testing.F) {
+	scopeId := netip.MustParseAddr("192.168.10.0")
+	startRange := netip.MustParseAddr("192.168.10.10")
+	endRange := netip.MustParseAddr("192.168.10.100")
+	subnetMask := netip.MustParseAddr("255.255.255.0")
+
+	builders := pwshtest.Builders{
+		"ScopeV4Create": func(s string) string {
+			return ScopeV4CreateParams{
+				Name:        s,
+				Description: s,
+				NapProfile:  s,
+				Superscope:  s,
+				Type:        s,
+				StartRange:  startRange,
+				EndRange:    endRange,
+				SubnetMask:  subnetMask,
+			}.pwshCommand()
+		},
+		"ScopeV4Update": func(s string) string {
+			return ScopeV4UpdateParams{
+				ScopeId:     scopeId,
+				Name:        s,
+				Description: s,
+				NapProfile:  s,
+				Superscope:  s,
+				Type:        s,
+			}.pwshCommand()
+		},
+	}
+
+	pwshtest.FuzzQuoting(f, builders)
+}
@@ -3,11 +3,11 @@ package dhcp
 import (
 	"context"
 	"errors"
-	"fmt"
+	"math/bits"
 	"net/netip"
-	"strings"
 	"time"
 
+	"github.com/d-strobel/gowindows/internal/pwsh"
 	"github.com/d-strobel/gowindows/parsing"
 	"github.com/d-strobel/gowindows/winerror"
 )
@@ -46,6 +46,20 @@ func (s *ScopeV4) convertOutput(o scopeObject) {
 	s.LeaseDuration = o.LeaseDuration.Duration
 }
 
+// networkAddress returns the network address of the range with the subnet mask, which is the ID of the scope.
+func networkAddress(startRange netip.Addr, subnetMask netip.Addr) netip.Addr {
+	ones := 0
+	for _, b := range subnetMask.As4() {
+		ones += bits.OnesCount8(b)
+	}
+
+	prefix, err := startRange.Prefix(ones)
+	if err != nil {
+		return netip.Addr{}
+	}
+	return prefix.Addr()
+}
+
 // ScopeV4ReadParams represents parameters for the scope read function.
 type ScopeV4ReadParams struct {
 	// Specify the ID of the scope.
@@ -55,8 +69,10 @@ type ScopeV4ReadParams struct {
 
 // pwshCommand returns the PowerShell command to read a DHCP scope.
 func (params ScopeV4ReadParams) pwshCommand() string {
-	// Base command
-	return fmt.Sprintf("Get-DhcpServerv4Scope -ScopeId '%s' | ConvertTo-Json -Compress", params.ScopeId)
+	return parsing.NewPwshCommand("Get-DhcpServerv4Scope").
+		Param("ScopeId", params.ScopeId).
+		ToJson().
+		String()
 }
 
 // ScopeV4Read gets a DHCP scope. It returns a ScopeV4 object.
@@ -72,8 +88,8 @@ func (c *Client) ScopeV4Read(ctx context.Context, params ScopeV4ReadParams) (Sco
 
 	// Run command
 	cmd := params.pwshCommand()
-	if err := run(ctx, c, cmd, &o); err != nil {
-		return s, winerror.Errorf(cmd, "windows.dhcp.ScopeV4Read: %s", err)
+	if err := run(ctx, c, "windows.dhcp.ScopeV4Read", cmd, &o); err != nil {
+		return s, winerror.Errorf(cmd, "windows.dhcp.ScopeV4Read: %w", err)
 	}
 
 	// Convert the output to a ScopeV4 object.
@@ -145,63 +161,59 @@ type ScopeV4CreateParams struct {
 // pwshCommand returns the PowerShell command to create a DHCP scope.
 func (params ScopeV4CreateParams) pwshCommand() string {
 	// Base command
-	cmd := []string{
-		fmt.Sprintf("Add-DhcpServerv4Scope -PassThru -Confirm:$false -Name '%s' -StartRange '%s' -EndRange '%s' -SubnetMask '%s'",
-			params.Name,
-			params.StartRange,
-			params.EndRange,
-			params.SubnetMask,
-		),
-	}
+	cmd := parsing.NewPwshCommand("Add-DhcpServerv4Scope").
+		Switch("PassThru").
+		Param("Confirm", false).
+		Param("Name", params.Name).
+		Param("StartRange", params.StartRange).
+		Param("EndRange", params.EndRange).
+		Param("SubnetMask", params.SubnetMask)
 
 	// Add optional parameters
 	if params.Description != "" {
-		cmd = append(cmd, fmt.Sprintf("-Description '%s'", params.Description))
+		cmd.Param("Description", params.Description)
 	}
 
 	if params.Enabled {
-		cmd = append(cmd, "-State 'Active'")
+		cmd.Param("State", "Active")
 	} else {
-		cmd = append(cmd, "-State 'InActive'")
+		cmd.Param("State", "InActive")
 	}
 
 	if params.MaxBootpClients != 0 {
-		cmd = append(cmd, fmt.Sprintf("-MaxBootpClients %d", params.MaxBootpClients))
+		cmd.Param("MaxBootpClients", params.MaxBootpClients)
 	}
 
 	if params.ActivatePolicies {
-		cmd = append(cmd, "-ActivatePolicies")
+		cmd.Switch("ActivatePolicies")
 	}
 
 	if params.NapEnable {
-		cmd = append(cmd, "-NapEnable")
+		cmd.Switch("NapEnable")
 	}
 
 	if params.NapProfile != "" {
-		cmd = append(cmd, fmt.Sprintf("-NapProfile '%s'", params.NapProfile))
+		cmd.Param("NapProfile", params.NapProfile)
 	}
 
 	if params.Delay != 0 {
-		cmd = append(cmd, fmt.Sprintf("-Delay %d", params.Delay))
+		cmd.Param("Delay", params.Delay)
 	}
 
 	if params.LeaseDuration != 0 {
-		cmd = append(cmd, fmt.Sprintf("-LeaseDuration %s", parsing.PwshTimespanString(params.LeaseDuration)))
+		cmd.Param("LeaseDuration", params.LeaseDuration)
 	}
 
 	if params.Type != "" {
-		cmd = append(cmd, fmt.Sprintf("-Type '%s'", params.Type))
+		cmd.Param("Type", params.Type)
 	}
 
 	if params.Superscope != "" {
-		cmd = append(cmd, fmt.Sprintf("-SuperscopeName '%s'", params.Superscope))
+		cmd.Param("SuperscopeName", params.Superscope)
 	}
 
-	// Convert output to json
-	cmd = append(cmd, "| ConvertTo-Json -Compress")
-
-	// Return the full command
-	return strings.Join(cmd, " ")
+	// Return the full command with json output
+	return cmd.ToJson().String()
 }
 
 // ScopeV4Create creates a new DHCP IPv4 scope. It returns a ScopeV4 object.
@@ -225,10 +237,46 @@ func (c *Client) ScopeV4Create(ctx context.Context, params ScopeV4CreateParams)
 		}
 	}
 
-	// Run command
 	cmd := params.pwshCommand()
-	if err := run(ctx, c, cmd, &o); err != nil {
-		return s, winerror.Errorf(cmd, "windows.dhcp.ScopeV4Create: %s", err)
+
+	// Plan the change in dry-run mode
+	if c.DryRun {
+		current, err := c.ScopeV4Read(ctx, ScopeV4ReadParams{ScopeId: networkAddress(params.StartRange, params.SubnetMask)})
+		return s, pwsh.PlanCreate("windows.dhcp.ScopeV4Create", cmd, current, err, func(s ScopeV4) ScopeV4 {
+			s.Name = params.Name
+			s.ScopeId = networkAddress(params.StartRange, params.SubnetMask)
+			s.StartRange = params.StartRange
+			s.EndRange = params.EndRange
+			s.SubnetMask = params.SubnetMask
+			if params.Description != "" {
+				s.Description = params.Description
+			}
+			s.Enabled = params.Enabled
+			if params.MaxBootpClients != 0 {
+				s.MaxBootpClients = params.MaxBootpClients
+			}
+			if params.ActivatePolicies {
+				s.ActivatePolicies = true
+			}
+			if params.NapEnable {
+				s.NapEnable = true
+			}
+			if params.NapProfile != "" {
+				s.NapProfile = params.NapProfile
+			}
+			if params.Delay != 0 {
+				s.Delay = params.Delay
+			}
+			if params.LeaseDuration != 0 {
+				s.LeaseDuration = params.LeaseDuration
+			}
+			return s
+		})
+	}
+
+	// Run command
+	if err := run(ctx, c, "windows.dhcp.ScopeV4Create", cmd, &o); err != nil {
+		return s, winerror.Errorf(cmd, "windows.dhcp.ScopeV4Create: %w", err)
 	}
 
 	// Convert the output to a ScopeV4 object.
@@ -299,68 +347,68 @@ type ScopeV4UpdateParams struct {
 // pwshCommand returns the PowerShell command to update a DHCP scope.
 func (params ScopeV4UpdateParams) pwshCommand() string {
 	// Base command
-	cmd := []string{fmt.Sprintf("Set-DhcpServerv4Scope -PassThru -Confirm:$false -ScopeId '%s'", params.ScopeId)}
+	cmd := parsing.NewPwshCommand("Set-DhcpServerv4Scope").
+		Switch("PassThru").
+		Param("Confirm", false).
+		Param("ScopeId", params.ScopeId)
 
 	// Add optional parameters
 	if params.Name != "" {
-		cmd = append(cmd, fmt.Sprintf("-Name '%s'", params.Name))
+		cmd.Param("Name", params.Name)
 	}
 
 	if params.StartRange.Is4() {
-		cmd = append(cmd, fmt.Sprintf("-StartRange '%s'", params.StartRange))
+		cmd.Param("StartRange", params.StartRange)
 	}
 
 	if params.EndRange.Is4() {
-		cmd = append(cmd, fmt.Sprintf("-EndRange '%s'", params.EndRange))
+		cmd.Param("EndRange", params.EndRange)
 	}
 
 	if params.Description != "" {
-		cmd = append(cmd, fmt.Sprintf("-Description '%s'", params.Description))
+		cmd.Param("Description", params.Description)
 	}
 
 	if params.Enabled {
-		cmd = append(cmd, "-State 'Active'")
+		cmd.Param("State", "Active")
 	} else {
-		cmd = append(cmd, "-State 'InActive'")
+		cmd.Param("State", "InActive")
 	}
 
 	if params.MaxBootpClients != 0 {
-		cmd = append(cmd, fmt.Sprintf("-MaxBootpClients %d", params.MaxBootpClients))
+		cmd.Param("MaxBootpClients", params.MaxBootpClients)
 	}
 
 	if params.ActivatePolicies {
-		cmd = append(cmd, "-ActivatePolicies")
+		cmd.Switch("ActivatePolicies")
 	}
 
 	if params.NapEnable {
-		cmd = append(cmd, "-NapEnable")
+		cmd.Switch("NapEnable")
 	}
 
 	if params.NapProfile != "" {
-		cmd = append(cmd, fmt.Sprintf("-NapProfile '%s'", params.NapProfile))
+		cmd.Param("NapProfile", params.NapProfile)
 	}
 
 	if params.Delay != 0 {
-		cmd = append(cmd, fmt.Sprintf("-Delay %d", params.Delay))
+		cmd.Param("Delay", params.Delay)
 	}
 
 	if params.LeaseDuration != 0 {
-		cmd = append(cmd, fmt.Sprintf("-LeaseDuration %s", parsing.PwshTimespanString(params.LeaseDuration)))
+		cmd.Param("LeaseDuration", params.LeaseDuration)
 	}
 
 	if params.Type != "" {
-		cmd = append(cmd, fmt.Sprintf("-Type '%s'", params.Type))
+		cmd.Param("Type", params.Type)
 	}
 
 	if params.Superscope != "" {
-		cmd = append(cmd, fmt.Sprintf("-SuperscopeName '%s'", params.Superscope))
+		cmd.Param("SuperscopeName", params.Superscope)
 	}
 
-	// Convert output to json
-	cmd = append(cmd, "| ConvertTo-Json -Compress")
-
-	// Return the full command
-	return strings.Join(cmd, " ")
+	// Return the full command with json output
+	return cmd.ToJson().String()
 }
 
 // ScopeV4Update updates a DHCP IPv4 scope. It returns a ScopeV4 object.
@@ -384,10 +432,48 @@ func (c *Client) ScopeV4Update(ctx context.Context, params ScopeV4UpdateParams)
 		return s, errors.New("windows.dhcp.ScopeV4Update: scope parameter 'StartRange' and 'EndRange' must be set together")
 	}
 
-	// Run command
 	cmd := params.pwshCommand()
-	if err := run(ctx, c, cmd, &o); err != nil {
-		return s, winerror.Errorf(cmd, "windows.dhcp.ScopeV4Update: %s", err)
+
+	// Plan the change in dry-run mode
+	if c.DryRun {
+		current, err := c.ScopeV4Read(ctx, ScopeV4ReadParams{ScopeId: params.ScopeId})
+		return s, pwsh.Plan("windows.dhcp.ScopeV4Update", cmd, current, err, func(s ScopeV4) ScopeV4 {
+			if params.Name != "" {
+				s.Name = params.Name
+			}
+			if params.StartRange.Is4() {
+				s.StartRange = params.StartRange
+				s.EndRange = params.EndRange
+			}
+			if params.Description != "" {
+				s.Description = params.Description
+			}
+			s.Enabled = params.Enabled
+			if params.MaxBootpClients != 0 {
+				s.MaxBootpClients = params.MaxBootpClients
+			}
+			if params.ActivatePolicies {
+				s.ActivatePolicies = true
+			}
+			if params.NapEnable {
+				s.NapEnable = true
+			}
+			if params.NapProfile != "" {
+				s.NapProfile = params.NapProfile
+			}
+			if params.Delay != 0 {
+				s.Delay = params.Delay
+			}
+			if params.LeaseDuration != 0 {
+				s.LeaseDuration = params.LeaseDuration
+			}
+			return s
+		})
+	}
+
+	// Run command
+	if err := run(ctx, c, "windows.dhcp.ScopeV4Update", cmd, &o); err != nil {
+		return s, winerror.Errorf(cmd, "windows.dhcp.ScopeV4Update: %w", err)
 	}
 
 	// Convert the output to a ScopeV4 object.
@@ -404,8 +490,10 @@ type ScopeV4DeleteParams struct {
 
 // pwshCommand returns the PowerShell command to delete a DHCP scope.
 func (params ScopeV4DeleteParams) pwshCommand() string {
-	// Base command
-	return fmt.Sprintf("Remove-DhcpServerv4Scope -Confirm:$false -ScopeId '%s'", params.ScopeId)
+	return parsing.NewPwshCommand("Remove-DhcpServerv4Scope").
+		Param("Confirm", false).
+		Param("ScopeId", params.ScopeId).
+		String()
 }
 
 // ScopeV4Delete removes a DHCP IPv4 scope.
@@ -418,10 +506,19 @@ func (c *Client) ScopeV4Delete(ctx context.Context, params ScopeV4DeleteParams)
 		return errors.New("windows.dhcp.ScopeV4Delete: scope parameter 'ScopeId' must be a valid IPv4 address")
 	}
 
-	// Run command
 	cmd := params.pwshCommand()
-	if err := run(ctx, c, cmd, &o); err != nil {
-		return winerror.Errorf(cmd, "windows.dhcp.ScopeV4Delete: %s", err)
+
+	// Plan the change in dry-run mode
+	if c.DryRun {
+		current, err := c.ScopeV4Read(ctx, ScopeV4ReadParams{ScopeId: params.ScopeId})
+		return pwsh.Plan("windows.dhcp.ScopeV4Delete", cmd, current, err, func(ScopeV4) ScopeV4 {
+			return ScopeV4{}
+		})
+	}
+
+	// Run command
+	if err := run(ctx, c, "windows.dhcp.ScopeV4Delete", cmd, &o); err != nil {
+		return winerror.Errorf(cmd, "windows.dhcp.ScopeV4Delete: %w", err)
 	}
 
 	return nil
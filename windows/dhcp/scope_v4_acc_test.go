@@ -0,0 +1,92 @@
+package dhcp_test
+
+import (
+	"context"
+	"fmt"
+	"net/netip"
+	"time"
+
+	"github.com/d-strobel/gowindows/windows/dhcp"
+	"github.com/d-strobel/gowindows/winerror"
+)
+
+// testScopeId returns the scope id of the test scope of the i-th client.
+func testScopeId(i int) netip.Addr {
+	return netip.AddrFrom4([4]byte{192, 168, byte(100 + i), 0})
+}
+
+// We insert numbers into the function names to ensure that
+// the test functions run in a specific order.
+func (suite *DhcpAccTestSuite) TestScopeV41Create() {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	for i, c := range suite.clients {
+		params := dhcp.ScopeV4CreateParams{
+			Name:          fmt.Sprintf("Test-Scope-%d", i),
+			Description:   "This is a test scope",
+			StartRange:    netip.AddrFrom4([4]byte{192, 168, byte(100 + i), 10}),
+			EndRange:      netip.AddrFrom4([4]byte{192, 168, byte(100 + i), 100}),
+			SubnetMask:    netip.MustParseAddr("255.255.255.0"),
+			LeaseDuration: 24 * time.Hour,
+			Enabled:       true,
+		}
+		s, err := c.ScopeV4Create(ctx, params)
+		suite.Require().NoError(err)
+		suite.Equal(fmt.Sprintf("Test-Scope-%d", i), s.Name)
+		suite.Equal("This is a test scope", s.Description)
+		suite.Equal(testScopeId(i), s.ScopeId)
+		suite.Equal(params.StartRange, s.StartRange)
+		suite.Equal(params.EndRange, s.EndRange)
+		suite.Equal(24*time.Hour, s.LeaseDuration)
+		suite.True(s.Enabled)
+	}
+}
+
+func (suite *DhcpAccTestSuite) TestScopeV42Read() {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	for i, c := range suite.clients {
+		s, err := c.ScopeV4Read(ctx, dhcp.ScopeV4ReadParams{ScopeId: testScopeId(i)})
+		suite.Require().NoError(err)
+		suite.Equal(fmt.Sprintf("Test-Scope-%d", i), s.Name)
+		suite.Equal(netip.MustParseAddr("255.255.255.0"), s.SubnetMask)
+	}
+}
+
+func (suite *DhcpAccTestSuite) TestScopeV43Update() {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	for i, c := range suite.clients {
+		params := dhcp.ScopeV4UpdateParams{
+			ScopeId:       testScopeId(i),
+			Name:          fmt.Sprintf("Updated-Test-Scope-%d", i),
+			Description:   "Updated - This is a test scope",
+			StartRange:    netip.AddrFrom4([4]byte{192, 168, byte(100 + i), 20}),
+			EndRange:      netip.AddrFrom4([4]byte{192, 168, byte(100 + i), 200}),
+			LeaseDuration: 48 * time.Hour,
+			Enabled:       false,
+		}
+		s, err := c.ScopeV4Update(ctx, params)
+		suite.Require().NoError(err)
+		suite.Equal(fmt.Sprintf("Updated-Test-Scope-%d", i), s.Name)
+		suite.Equal(params.StartRange, s.StartRange)
+		suite.Equal(params.EndRange, s.EndRange)
+		suite.Equal(48*time.Hour, s.LeaseDuration)
+		suite.False(s.Enabled)
+	}
+}
+
+func (suite *DhcpAccTestSuite) TestScopeV44Delete() {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	for i, c := range suite.clients {
+		suite.NoError(c.ScopeV4Delete(ctx, dhcp.ScopeV4DeleteParams{ScopeId: testScopeId(i)}))
+
+		_, err := c.ScopeV4Read(ctx, dhcp.ScopeV4ReadParams{ScopeId: testScopeId(i)})
+		suite.ErrorIs(err, winerror.ErrNotFound)
+	}
+}
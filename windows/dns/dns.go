@@ -5,20 +5,14 @@ package dns
 
 import (
 	"context"
-	"encoding/json"
 	"time"
 
-	"errors"
-
 	"github.com/d-strobel/gowindows/connection"
+	"github.com/d-strobel/gowindows/internal/pwsh"
 	"github.com/d-strobel/gowindows/parsing"
+	"github.com/d-strobel/gowindows/telemetry"
 )
 
-// dns is a type constraint for the run function, ensuring it works with specific types.
-type dns interface {
-	Zone | []Zone | recordObject | []recordObject
-}
-
 // Default Windows DNS TTL.
 // https://learn.microsoft.com/en-us/windows/win32/ad/configuration-of-ttl-limits?source=recommendations
 var defaultTimeToLive time.Duration = time.Second * 86400
@@ -42,6 +36,14 @@ type Client struct {
 	// Connection represents a connection.Connection object.
 	Connection connection.Connection
 
+	// DryRun indicates whether the mutating functions return a *dryrun.Change instead of applying the change.
+	// The change contains the PowerShell command and the diff against the current state of the resource.
+	DryRun bool
+
+	// Telemetry logs and traces the calls of the client.
+	// A nil Telemetry disables the logging and tracing.
+	Telemetry *telemetry.Telemetry
+
 	// decodeCliXmlErr represents a function that decodes a CLIXML error and returns aa  human readable string.
 	decodeCliXmlErr func(string) (string, error)
 }
@@ -57,33 +59,20 @@ func NewClientWithParser(conn connection.Connection, parsing func(string) (strin
 	return &Client{Connection: conn, decodeCliXmlErr: parsing}
 }
 
-// run runs a PowerShell command against a Windows system, handles the command results,
-// and unmarshals the output into a local object type.
-func run[T dns](ctx context.Context, c *Client, cmd string, d *T) error {
-	// Run the command
-	result, err := c.Connection.RunWithPowershell(ctx, cmd)
-	if err != nil {
-		return err
-	}
-
-	// Handle stderr
-	if result.StdErr != "" {
-		stderr, err := c.decodeCliXmlErr(result.StdErr)
-		if err != nil {
-			return err
-		}
-
-		return errors.New(stderr)
-	}
-
-	if result.StdOut == "" {
-		return nil
-	}
-
-	// Unmarshal stdout
-	if err = json.Unmarshal([]byte(result.StdOut), &d); err != nil {
-		return err
+// timeToLive returns the TTL that is set for a record, rounded to seconds.
+// A TTL of 0 is the default TTL.
+func timeToLive(ttl time.Duration) time.Duration {
+	if ttl == 0 {
+		return defaultTimeToLive
 	}
+	return ttl.Round(time.Second)
+}
 
-	return nil
+// run runs a PowerShell command against a Windows system, handles the command results,
+// and unmarshals the output into a local object type.
+// The operation is the name of the calling function, which is the name of its span, see telemetry.Telemetry.
+func run[T any](ctx context.Context, c *Client, operation string, cmd string, v *T) error {
+	return c.Telemetry.Observe(ctx, operation, cmd, func(ctx context.Context) error {
+		return pwsh.Run(ctx, c.Connection, c.decodeCliXmlErr, cmd, v)
+	})
 }
@@ -0,0 +1,38 @@
+package dns_test
+
+import (
+	"testing"
+
+	"github.com/d-strobel/gowindows/connection"
+	"github.com/d-strobel/gowindows/internal/acctest"
+	"github.com/d-strobel/gowindows/windows/dns"
+	"github.com/stretchr/testify/suite"
+)
+
+// Acceptance test suite for all dns functions.
+type DnsAccTestSuite struct {
+	suite.Suite
+
+	// Fixtures
+	conns   []connection.Connection
+	clients []dns.Client
+}
+
+// SetupSuite setups the acceptance test suite for all dns functions.
+// We ensure that all commands return the same output with WinRM and SSH.
+func (suite *DnsAccTestSuite) SetupSuite() {
+	for _, conn := range suite.conns {
+		suite.clients = append(suite.clients, *dns.NewClient(conn))
+	}
+}
+
+func (suite *DnsAccTestSuite) TearDownSuite() {
+	// Close connections and write the recordings
+	for _, c := range suite.clients {
+		suite.NoError(c.Connection.Close())
+	}
+}
+
+func TestDnsAccTestSuite(t *testing.T) {
+	suite.Run(t, &DnsAccTestSuite{conns: acctest.Connections(t)})
+}
@@ -46,7 +46,7 @@ func (suite *DnsServerUnitTestSuite) TestDnsServerRun() {
 			RunWithPowershell(ctx, cmd).
 			Return(connection.CmdResult{StdOut: zone, StdErr: ""}, nil)
 		var z Zone
-		err := run(ctx, c, cmd, &z)
+		err := run(ctx, c, "windows.dns.Test", cmd, &z)
 		suite.NoError(err)
 		suite.Equal(expectedZone, z)
 	})
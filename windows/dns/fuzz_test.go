@@ -0,0 +1,40 @@
+package dns
+
+import (
+	"net/netip"
+	"testing"
+
+	"github.com/d-strobel/gowindows/internal/pwshtest"
+)
+
+// FuzzPwshCommandQuoting asserts that user input can never escape its PowerShell argument.
+func FuzzPwshCommandQuoting(f *testing.F) {
+	addressesV4 := []netip.Addr{netip.MustParseAddr("1.1.1.1")}
+	addressesV6 := []netip.Addr{netip.MustParseAddr("fe80::1")}
+
+	builders := pwshtest.Builders{
+		"ZoneRead":    func(s string) string { return ZoneReadParams{Name: s}.pwshCommand() },
+		"RecordARead": func(s string) string { return RecordAReadParams{Name: s, Zone: s}.pwshCommand() },
+		"RecordACreate": func(s string) string {
+			return RecordACreateParams{Name: s, Zone: s, Addresses: addressesV4}.pwshCommand()
+		},
+		"RecordAUpdate":  func(s string) string { return RecordAUpdateParams{Name: s, Zone: s}.pwshCommand() },
+		"RecordADelete":  func(s string) string { return RecordADeleteParams{Name: s, Zone: s}.pwshCommand() },
+		"RecordAAAARead": func(s string) string { return RecordAAAAReadParams{Name: s, Zone: s}.pwshCommand() },
+		"RecordAAAACreate": func(s string) string {
+			return RecordAAAACreateParams{Name: s, Zone: s, Addresses: addressesV6}.pwshCommand()
+		},
+		"RecordAAAAUpdate":  func(s string) string { return RecordAAAAUpdateParams{Name: s, Zone: s}.pwshCommand() },
+		"RecordAAAADelete":  func(s string) string { return RecordAAAADeleteParams{Name: s, Zone: s}.pwshCommand() },
+		"RecordCNameRead":   func(s string) string { return RecordCNameReadParams{Name: s, Zone: s}.pwshCommand() },
+		"RecordCNameCreate": func(s string) string { return RecordCNameCreateParams{Name: s, Zone: s, CName: s}.pwshCommand() },
+		"RecordCNameUpdate": func(s string) string { return RecordCNameUpdateParams{Name: s, Zone: s, CName: s}.pwshCommand() },
+		"RecordCNameDelete": func(s string) string { return RecordCNameDeleteParams{Name: s, Zone: s}.pwshCommand() },
+		"RecordPTRRead":     func(s string) string { return RecordPTRReadParams{Name: s, Zone: s}.pwshCommand() },
+		"RecordPTRCreate":   func(s string) string { return RecordPTRCreateParams{Name: s, Zone: s, PTR: s}.pwshCommand() },
+		"RecordPTRUpdate":   func(s string) string { return RecordPTRUpdateParams{Name: s, Zone: s, PTR: s}.pwshCommand() },
+		"RecordPTRDelete":   func(s string) string { return RecordPTRDeleteParams{Name: s, Zone: s}.pwshCommand() },
+	}
+
+	pwshtest.FuzzQuoting(f, builders)
+}
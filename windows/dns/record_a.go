@@ -5,9 +5,10 @@ import (
 	"errors"
 	"fmt"
 	"net/netip"
-	"strings"
 	"time"
 
+	"github.com/d-strobel/gowindows/internal/pwsh"
+	"github.com/d-strobel/gowindows/parsing"
 	"github.com/d-strobel/gowindows/winerror"
 )
 
@@ -65,16 +66,13 @@ type RecordAReadParams struct {
 
 // pwshCommand returns the PowerShell command to read an A-Record.
 func (params RecordAReadParams) pwshCommand() string {
-	// Base command
-	cmd := []string{"$r=Get-DnsServerResourceRecord -RRType 'A' -Node"}
-
-	// Add parameters
-	cmd = append(cmd, fmt.Sprintf("-Name '%s'", params.Name))
-	cmd = append(cmd, fmt.Sprintf("-ZoneName '%s'", params.Zone))
-
-	// Ensure output is always an array.
-	cmd = append(cmd, ";if($r.Count -ge 2){ConvertTo-Json $r -Compress}else{ConvertTo-Json @($r) -Compress}")
-	return strings.Join(cmd, " ")
+	return parsing.NewPwshCommand("Get-DnsServerResourceRecord").
+		Param("RRType", "A").
+		Switch("Node").
+		Param("Name", params.Name).
+		Param("ZoneName", params.Zone).
+		ToJsonArray().
+		String()
 }
 
 // RecordARead gets an A-Record by Name and Zone. It returns a RecordA object.
@@ -90,13 +88,13 @@ func (c *Client) RecordARead(ctx context.Context, params RecordAReadParams) (Rec
 
 	// Run command
 	cmd := params.pwshCommand()
-	if err := run(ctx, c, cmd, &o); err != nil {
-		return r, winerror.Errorf(cmd, "windows.dns.RecordARead: %s", err)
+	if err := run(ctx, c, "windows.dns.RecordARead", cmd, &o); err != nil {
+		return r, winerror.Errorf(cmd, "windows.dns.RecordARead: %w", err)
 	}
 
 	// Convert the output to a RecordA object.
 	if err := r.convertOutput(o); err != nil {
-		return r, fmt.Errorf(cmd, "windows.dns.RecordARead: failed to convert output to RecordA object: %s", err)
+		return r, fmt.Errorf(cmd, "windows.dns.RecordARead: failed to convert output to RecordA object: %w", err)
 	}
 
 	return r, nil
@@ -121,15 +119,6 @@ type RecordACreateParams struct {
 
 // pwshCommand returns the PowerShell command to create a new A-Record.
 func (params RecordACreateParams) pwshCommand() string {
-	addressList := []string{}
-
-	// Base command
-	cmd := []string{"$r=Add-DnsServerResourceRecordA -AllowUpdateAny:$false -CreatePtr:$false -AgeRecord:$false -Confirm:$false -PassThru"}
-
-	// Add parameters
-	cmd = append(cmd, fmt.Sprintf("-Name '%s'", params.Name))
-	cmd = append(cmd, fmt.Sprintf("-ZoneName '%s'", params.Zone))
-
 	// Set default TTL if not provided.
 	if params.TimeToLive == 0 {
 		params.TimeToLive = defaultTimeToLive
@@ -138,16 +127,19 @@ func (params RecordACreateParams) pwshCommand() string {
 	// New-TimeSpan only allows int32 values. So we round the duration to seconds.
 	// https://learn.microsoft.com/de-de/powershell/module/microsoft.powershell.utility/new-timespan?view=powershell-7.4
 	seconds := int32(params.TimeToLive.Round(time.Second).Seconds())
-	cmd = append(cmd, fmt.Sprintf("-TimeToLive %s", fmt.Sprintf("$(New-TimeSpan -Seconds %d)", seconds)))
 
-	// Add addresses with single quotes and join them with commas.
-	for _, address := range params.Addresses {
-		addressList = append(addressList, fmt.Sprintf("'%s'", address.String()))
-	}
-	cmd = append(cmd, fmt.Sprintf("-IPv4Address @(%s)", strings.Join(addressList, ",")))
-
-	cmd = append(cmd, ";if($r.Count -ge 2){ConvertTo-Json $r -Compress}else{ConvertTo-Json @($r) -Compress}")
-	return strings.Join(cmd, " ")
+	return parsing.NewPwshCommand("Add-DnsServerResourceRecordA").
+		Param("AllowUpdateAny", false).
+		Param("CreatePtr", false).
+		Param("AgeRecord", false).
+		Param("Confirm", false).
+		Switch("PassThru").
+		Param("Name", params.Name).
+		Param("ZoneName", params.Zone).
+		Param("TimeToLive", parsing.PwshRaw(fmt.Sprintf("$(New-TimeSpan -Seconds %d)", seconds))).
+		Param("IPv4Address", params.Addresses).
+		ToJsonArray().
+		String()
 }
 
 // RecordACreate creates a new A-Record. It returns a RecordA object.
@@ -168,20 +160,34 @@ func (c *Client) RecordACreate(ctx context.Context, params RecordACreateParams)
 		}
 	}
 
-	// Run command
 	cmd := params.pwshCommand()
-	if err := run(ctx, c, cmd, &o); err != nil {
+
+	// Plan the change in dry-run mode
+	if c.DryRun {
+		current, err := c.RecordARead(ctx, RecordAReadParams{Name: params.Name, Zone: params.Zone})
+		return r, pwsh.PlanCreate("windows.dns.RecordACreate", cmd, current, err, func(r RecordA) RecordA {
+			r.Name = params.Name
+			r.Addresses = params.Addresses
+			r.TimeToLive = timeToLive(params.TimeToLive)
+			return r
+		})
+	}
+
+	// Run command
+	if err := run(ctx, c, "windows.dns.RecordACreate", cmd, &o); err != nil {
 		// Handle record already exists error.
-		if strings.Contains(err.Error(), "ResourceExists") {
-			return r, winerror.Errorf(cmd, "windows.dns.RecordACreate: the specified record already exists")
+		if winerror.HasCategory(err, "ResourceExists") {
+			winErr := winerror.Errorf(cmd, "windows.dns.RecordACreate: the specified record already exists")
+			winErr.Records = winerror.UnwrapRecords(err)
+			return r, winErr
 		}
 
-		return r, winerror.Errorf(cmd, "windows.dns.RecordACreate: %s", err)
+		return r, winerror.Errorf(cmd, "windows.dns.RecordACreate: %w", err)
 	}
 
 	// Convert the output to a RecordA object.
 	if err := r.convertOutput(o); err != nil {
-		return r, fmt.Errorf(cmd, "windows.dns.RecordACreate: failed to convert output to RecordA object: %s", err)
+		return r, fmt.Errorf(cmd, "windows.dns.RecordACreate: failed to convert output to RecordA object: %w", err)
 	}
 
 	return r, nil
@@ -212,18 +218,25 @@ func (params RecordAUpdateParams) pwshCommand() string {
 	}
 	seconds := int32(params.TimeToLive.Round(time.Second).Seconds())
 
-	// Base command
-	cmd := []string{"$nr=@();Get-DnsServerResourceRecord -RRType 'A' -Node"}
-
-	// Add parameters and logic for handling the TTL update.
-	cmd = append(cmd, fmt.Sprintf("-Name '%s'", params.Name))
-	cmd = append(cmd, fmt.Sprintf("-ZoneName '%s'", params.Zone))
-	cmd = append(cmd, fmt.Sprintf("| ForEach-Object{$r=$_;$n=[ciminstance]::new($r);$n.TimeToLive=New-TimeSpan -Seconds %d", seconds))
-	cmd = append(cmd, fmt.Sprintf(";$nr+=Set-DnsServerResourceRecord -OldInputObject $r -NewInputObject $n -ZoneName '%s' -PassThru}", params.Zone))
-	cmd = append(cmd, ";if($nr.Count -ge 2){ConvertTo-Json $nr -Compress}else{ConvertTo-Json @($nr) -Compress}")
-
-	// Return the full command.
-	return strings.Join(cmd, " ")
+	getCmd := parsing.NewPwshCommand("Get-DnsServerResourceRecord").
+		Param("RRType", "A").
+		Switch("Node").
+		Param("Name", params.Name).
+		Param("ZoneName", params.Zone)
+
+	setCmd := parsing.NewPwshCommand("Set-DnsServerResourceRecord").
+		Param("OldInputObject", parsing.PwshRaw("$r")).
+		Param("NewInputObject", parsing.PwshRaw("$n")).
+		Param("ZoneName", params.Zone).
+		Switch("PassThru")
+
+	// Update the TTL of every record of the node and ensure the output is always an array.
+	return fmt.Sprintf(
+		"$nr=@();%s | ForEach-Object{$r=$_;$n=[ciminstance]::new($r);$n.TimeToLive=New-TimeSpan -Seconds %d ;$nr+=%s} ;if($nr.Count -ge 2){ConvertTo-Json $nr -Compress}else{ConvertTo-Json @($nr) -Compress}",
+		getCmd,
+		seconds,
+		setCmd,
+	)
 }
 
 // RecordAUpdate updates an A-Record. It returns a RecordA object.
@@ -237,15 +250,25 @@ func (c *Client) RecordAUpdate(ctx context.Context, params RecordAUpdateParams)
 		return r, errors.New("windows.dns.RecordAUpdate: record parameters 'Name', 'Zone' and 'TimeToLive' must be set")
 	}
 
-	// Run command
 	cmd := params.pwshCommand()
-	if err := run(ctx, c, cmd, &o); err != nil {
-		return r, winerror.Errorf(cmd, "windows.dns.RecordAUpdate: %s", err)
+
+	// Plan the change in dry-run mode
+	if c.DryRun {
+		current, err := c.RecordARead(ctx, RecordAReadParams{Name: params.Name, Zone: params.Zone})
+		return r, pwsh.Plan("windows.dns.RecordAUpdate", cmd, current, err, func(r RecordA) RecordA {
+			r.TimeToLive = timeToLive(params.TimeToLive)
+			return r
+		})
+	}
+
+	// Run command
+	if err := run(ctx, c, "windows.dns.RecordAUpdate", cmd, &o); err != nil {
+		return r, winerror.Errorf(cmd, "windows.dns.RecordAUpdate: %w", err)
 	}
 
 	// Convert the output to a RecordA object.
 	if err := r.convertOutput(o); err != nil {
-		return r, fmt.Errorf(cmd, "windows.dns.RecordAUpdate: failed to convert output to RecordA object: %s", err)
+		return r, fmt.Errorf(cmd, "windows.dns.RecordAUpdate: failed to convert output to RecordA object: %w", err)
 	}
 
 	return r, nil
@@ -262,8 +285,12 @@ type RecordADeleteParams struct {
 
 // pwshCommand returns the PowerShell command to delete an A-Record.
 func (params RecordADeleteParams) pwshCommand() string {
-	// Base command
-	return fmt.Sprintf("Remove-DnsServerResourceRecord -RRType 'A' -Force -Name '%s' -ZoneName '%s'", params.Name, params.Zone)
+	return parsing.NewPwshCommand("Remove-DnsServerResourceRecord").
+		Param("RRType", "A").
+		Switch("Force").
+		Param("Name", params.Name).
+		Param("ZoneName", params.Zone).
+		String()
 }
 
 // RecordADelete deletes an A-Record.
@@ -276,10 +303,19 @@ func (c *Client) RecordADelete(ctx context.Context, params RecordADeleteParams)
 		return errors.New("windows.dns.RecordADelete: record parameters 'Name' and 'Zone' must be set")
 	}
 
-	// Run command
 	cmd := params.pwshCommand()
-	if err := run(ctx, c, cmd, &o); err != nil {
-		return winerror.Errorf(cmd, "windows.dns.RecordADelete: %s", err)
+
+	// Plan the change in dry-run mode
+	if c.DryRun {
+		current, err := c.RecordARead(ctx, RecordAReadParams{Name: params.Name, Zone: params.Zone})
+		return pwsh.Plan("windows.dns.RecordADelete", cmd, current, err, func(RecordA) RecordA {
+			return RecordA{}
+		})
+	}
+
+	// Run command
+	if err := run(ctx, c, "windows.dns.RecordADelete", cmd, &o); err != nil {
+		return winerror.Errorf(cmd, "windows.dns.RecordADelete: %w", err)
 	}
 
 	return nil
@@ -0,0 +1,78 @@
+package dns_test
+
+import (
+	"context"
+	"fmt"
+	"net/netip"
+	"time"
+
+	"github.com/d-strobel/gowindows/internal/acctest"
+	"github.com/d-strobel/gowindows/windows/dns"
+	"github.com/d-strobel/gowindows/winerror"
+)
+
+// We insert numbers into the function names to ensure that
+// the test functions for each record file run in a specific order.
+func (suite *DnsAccTestSuite) TestRecordA1Create() {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	for i, c := range suite.clients {
+		params := dns.RecordACreateParams{
+			Name:       fmt.Sprintf("test-a-%d", i),
+			Zone:       acctest.TestZone,
+			Addresses:  []netip.Addr{netip.MustParseAddr("10.0.0.1"), netip.MustParseAddr("10.0.0.2")},
+			TimeToLive: time.Hour,
+		}
+		r, err := c.RecordACreate(ctx, params)
+		suite.Require().NoError(err)
+		suite.Equal(fmt.Sprintf("test-a-%d", i), r.Name)
+		suite.ElementsMatch(params.Addresses, r.Addresses)
+		suite.Equal(time.Hour, r.TimeToLive)
+	}
+}
+
+func (suite *DnsAccTestSuite) TestRecordA2Read() {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	for i, c := range suite.clients {
+		r, err := c.RecordARead(ctx, dns.RecordAReadParams{Name: fmt.Sprintf("test-a-%d", i), Zone: acctest.TestZone})
+		suite.Require().NoError(err)
+		suite.Equal(fmt.Sprintf("test-a-%d", i), r.Name)
+		suite.ElementsMatch([]netip.Addr{netip.MustParseAddr("10.0.0.1"), netip.MustParseAddr("10.0.0.2")}, r.Addresses)
+		suite.Equal(time.Hour, r.TimeToLive)
+	}
+}
+
+func (suite *DnsAccTestSuite) TestRecordA3Update() {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	for i, c := range suite.clients {
+		params := dns.RecordAUpdateParams{
+			Name:       fmt.Sprintf("test-a-%d", i),
+			Zone:       acctest.TestZone,
+			TimeToLive: 2 * time.Hour,
+		}
+		r, err := c.RecordAUpdate(ctx, params)
+		suite.Require().NoError(err)
+		suite.Equal(2*time.Hour, r.TimeToLive)
+	}
+}
+
+func (suite *DnsAccTestSuite) TestRecordA4Delete() {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	for i, c := range suite.clients {
+		params := dns.RecordADeleteParams{
+			Name: fmt.Sprintf("test-a-%d", i),
+			Zone: acctest.TestZone,
+		}
+		suite.NoError(c.RecordADelete(ctx, params))
+
+		_, err := c.RecordARead(ctx, dns.RecordAReadParams{Name: params.Name, Zone: params.Zone})
+		suite.ErrorIs(err, winerror.ErrNotFound)
+	}
+}
@@ -8,6 +8,7 @@ import (
 	"github.com/d-strobel/gowindows/connection"
 	mockConnection "github.com/d-strobel/gowindows/connection/mocks"
 	"github.com/d-strobel/gowindows/parsing"
+	"github.com/d-strobel/gowindows/winerror"
 )
 
 // Fixtures
@@ -256,6 +257,7 @@ func (suite *DnsServerUnitTestSuite) TestRecordACreate() {
 
 		_, err := c.RecordACreate(ctx, RecordACreateParams{Name: "test", Zone: "test.local", Addresses: []netip.Addr{netip.MustParseAddr("1.1.1.1")}, TimeToLive: time.Second * 3600})
 		suite.EqualError(err, "windows.dns.RecordACreate: the specified record already exists")
+		suite.ErrorIs(err, winerror.ErrAlreadyExists)
 	})
 
 	suite.Run("should return 'invalid Ipv4' error", func() {
@@ -5,9 +5,10 @@ import (
 	"errors"
 	"fmt"
 	"net/netip"
-	"strings"
 	"time"
 
+	"github.com/d-strobel/gowindows/internal/pwsh"
+	"github.com/d-strobel/gowindows/parsing"
 	"github.com/d-strobel/gowindows/winerror"
 )
 
@@ -65,16 +66,13 @@ type RecordAAAAReadParams struct {
 
 // pwshCommand returns the PowerShell command to read an AAAA-Record.
 func (params RecordAAAAReadParams) pwshCommand() string {
-	// Base command
-	cmd := []string{"$r=Get-DnsServerResourceRecord -RRType 'AAAA' -Node"}
-
-	// Add parameters
-	cmd = append(cmd, fmt.Sprintf("-Name '%s'", params.Name))
-	cmd = append(cmd, fmt.Sprintf("-ZoneName '%s'", params.Zone))
-
-	// Ensure output is always an array.
-	cmd = append(cmd, ";if($r.Count -ge 2){ConvertTo-Json $r -Compress}else{ConvertTo-Json @($r) -Compress}")
-	return strings.Join(cmd, " ")
+	return parsing.NewPwshCommand("Get-DnsServerResourceRecord").
+		Param("RRType", "AAAA").
+		Switch("Node").
+		Param("Name", params.Name).
+		Param("ZoneName", params.Zone).
+		ToJsonArray().
+		String()
 }
 
 // RecordAAAARead gets an AAAA-Record. It returns a RecordAAAA object.
@@ -90,13 +88,13 @@ func (c *Client) RecordAAAARead(ctx context.Context, params RecordAAAAReadParams
 
 	// Run command
 	cmd := params.pwshCommand()
-	if err := run(ctx, c, cmd, &o); err != nil {
-		return r, winerror.Errorf(cmd, "windows.dns.RecordAAAARead: %s", err)
+	if err := run(ctx, c, "windows.dns.RecordAAAARead", cmd, &o); err != nil {
+		return r, winerror.Errorf(cmd, "windows.dns.RecordAAAARead: %w", err)
 	}
 
 	// Convert the output to a RecordAAAA object.
 	if err := r.convertOutput(o); err != nil {
-		return r, fmt.Errorf(cmd, "windows.dns.RecordAAAARead: failed to convert output to RecordAAAA object: %s", err)
+		return r, fmt.Errorf(cmd, "windows.dns.RecordAAAARead: failed to convert output to RecordAAAA object: %w", err)
 	}
 
 	return r, nil
@@ -121,32 +119,27 @@ type RecordAAAACreateParams struct {
 
 // pwshCommand returns the PowerShell command to create a new AAAA-Record.
 func (params RecordAAAACreateParams) pwshCommand() string {
-	addressList := []string{}
-
-	// Base command
-	cmd := []string{"$r=Add-DnsServerResourceRecordAAAA -AllowUpdateAny:$false -CreatePtr:$false -AgeRecord:$false -Confirm:$false -PassThru"}
-
-	// Add parameters
-	cmd = append(cmd, fmt.Sprintf("-Name '%s'", params.Name))
-	cmd = append(cmd, fmt.Sprintf("-ZoneName '%s'", params.Zone))
-
 	// Set default TTL if not provided.
-	// New-TimeSpan only allows int32 values.
-	// https://learn.microsoft.com/de-de/powershell/module/microsoft.powershell.utility/new-timespan?view=powershell-7.4
 	if params.TimeToLive == 0 {
 		params.TimeToLive = defaultTimeToLive
 	}
-	seconds := int32(params.TimeToLive.Round(time.Second).Seconds())
-	cmd = append(cmd, fmt.Sprintf("-TimeToLive %s", fmt.Sprintf("$(New-TimeSpan -Seconds %d)", seconds)))
 
-	// Add addresses with single quotes and join them with commas.
-	for _, address := range params.Addresses {
-		addressList = append(addressList, fmt.Sprintf("'%s'", address.String()))
-	}
-	cmd = append(cmd, fmt.Sprintf("-IPv6Address @(%s)", strings.Join(addressList, ",")))
+	// New-TimeSpan only allows int32 values. So we round the duration to seconds.
+	// https://learn.microsoft.com/de-de/powershell/module/microsoft.powershell.utility/new-timespan?view=powershell-7.4
+	seconds := int32(params.TimeToLive.Round(time.Second).Seconds())
 
-	cmd = append(cmd, ";if($r.Count -ge 2){ConvertTo-Json $r -Compress}else{ConvertTo-Json @($r) -Compress}")
-	return strings.Join(cmd, " ")
+	return parsing.NewPwshCommand("Add-DnsServerResourceRecordAAAA").
+		Param("AllowUpdateAny", false).
+		Param("CreatePtr", false).
+		Param("AgeRecord", false).
+		Param("Confirm", false).
+		Switch("PassThru").
+		Param("Name", params.Name).
+		Param("ZoneName", params.Zone).
+		Param("TimeToLive", parsing.PwshRaw(fmt.Sprintf("$(New-TimeSpan -Seconds %d)", seconds))).
+		Param("IPv6Address", params.Addresses).
+		ToJsonArray().
+		String()
 }
 
 // RecordAAAACreate creates an AAAA-Record. It returns a RecordAAAA object.
@@ -167,20 +160,34 @@ func (c *Client) RecordAAAACreate(ctx context.Context, params RecordAAAACreatePa
 		}
 	}
 
-	// Run command
 	cmd := params.pwshCommand()
-	if err := run(ctx, c, cmd, &o); err != nil {
+
+	// Plan the change in dry-run mode
+	if c.DryRun {
+		current, err := c.RecordAAAARead(ctx, RecordAAAAReadParams{Name: params.Name, Zone: params.Zone})
+		return r, pwsh.PlanCreate("windows.dns.RecordAAAACreate", cmd, current, err, func(r RecordAAAA) RecordAAAA {
+			r.Name = params.Name
+			r.Addresses = params.Addresses
+			r.TimeToLive = timeToLive(params.TimeToLive)
+			return r
+		})
+	}
+
+	// Run command
+	if err := run(ctx, c, "windows.dns.RecordAAAACreate", cmd, &o); err != nil {
 		// Handle record already exists error.
-		if strings.Contains(err.Error(), "ResourceExists") {
-			return r, winerror.Errorf(cmd, "windows.dns.RecordAAAACreate: the specified record already exists")
+		if winerror.HasCategory(err, "ResourceExists") {
+			winErr := winerror.Errorf(cmd, "windows.dns.RecordAAAACreate: the specified record already exists")
+			winErr.Records = winerror.UnwrapRecords(err)
+			return r, winErr
 		}
 
-		return r, winerror.Errorf(cmd, "windows.dns.RecordAAAACreate: %s", err)
+		return r, winerror.Errorf(cmd, "windows.dns.RecordAAAACreate: %w", err)
 	}
 
 	// Convert the output to a RecordAAAA object.
 	if err := r.convertOutput(o); err != nil {
-		return r, fmt.Errorf(cmd, "windows.dns.RecordAAAACreate: failed to convert output to RecordAAAA object: %s", err)
+		return r, fmt.Errorf(cmd, "windows.dns.RecordAAAACreate: failed to convert output to RecordAAAA object: %w", err)
 	}
 
 	return r, nil
@@ -211,18 +218,25 @@ func (params RecordAAAAUpdateParams) pwshCommand() string {
 	}
 	seconds := int32(params.TimeToLive.Round(time.Second).Seconds())
 
-	// Base command
-	cmd := []string{"$nr=@();Get-DnsServerResourceRecord -RRType 'AAAA' -Node"}
-
-	// Add parameters and logic for handling the TTL update.
-	cmd = append(cmd, fmt.Sprintf("-Name '%s'", params.Name))
-	cmd = append(cmd, fmt.Sprintf("-ZoneName '%s'", params.Zone))
-	cmd = append(cmd, fmt.Sprintf("| ForEach-Object{$r=$_;$n=[ciminstance]::new($r);$n.TimeToLive=New-TimeSpan -Seconds %d", seconds))
-	cmd = append(cmd, fmt.Sprintf(";$nr+=Set-DnsServerResourceRecord -OldInputObject $r -NewInputObject $n -ZoneName '%s' -PassThru}", params.Zone))
-	cmd = append(cmd, ";if($nr.Count -ge 2){ConvertTo-Json $nr -Compress}else{ConvertTo-Json @($nr) -Compress}")
-
-	// Return the full command.
-	return strings.Join(cmd, " ")
+	getCmd := parsing.NewPwshCommand("Get-DnsServerResourceRecord").
+		Param("RRType", "AAAA").
+		Switch("Node").
+		Param("Name", params.Name).
+		Param("ZoneName", params.Zone)
+
+	setCmd := parsing.NewPwshCommand("Set-DnsServerResourceRecord").
+		Param("OldInputObject", parsing.PwshRaw("$r")).
+		Param("NewInputObject", parsing.PwshRaw("$n")).
+		Param("ZoneName", params.Zone).
+		Switch("PassThru")
+
+	// Update the TTL of every record of the node and ensure the output is always an array.
+	return fmt.Sprintf(
+		"$nr=@();%s | ForEach-Object{$r=$_;$n=[ciminstance]::new($r);$n.TimeToLive=New-TimeSpan -Seconds %d ;$nr+=%s} ;if($nr.Count -ge 2){ConvertTo-Json $nr -Compress}else{ConvertTo-Json @($nr) -Compress}",
+		getCmd,
+		seconds,
+		setCmd,
+	)
 }
 
 // RecordAAAAUpdate updates an AAAA-Record. It returns a RecordAAAA object.
@@ -236,15 +250,25 @@ func (c *Client) RecordAAAAUpdate(ctx context.Context, params RecordAAAAUpdatePa
 		return r, errors.New("windows.dns.RecordAAAAUpdate: record parameters 'Name', 'Zone' and 'TimeToLive' must be set")
 	}
 
-	// Run command
 	cmd := params.pwshCommand()
-	if err := run(ctx, c, cmd, &o); err != nil {
-		return r, winerror.Errorf(cmd, "windows.dns.RecordAAAAUpdate: %s", err)
+
+	// Plan the change in dry-run mode
+	if c.DryRun {
+		current, err := c.RecordAAAARead(ctx, RecordAAAAReadParams{Name: params.Name, Zone: params.Zone})
+		return r, pwsh.Plan("windows.dns.RecordAAAAUpdate", cmd, current, err, func(r RecordAAAA) RecordAAAA {
+			r.TimeToLive = timeToLive(params.TimeToLive)
+			return r
+		})
+	}
+
+	// Run command
+	if err := run(ctx, c, "windows.dns.RecordAAAAUpdate", cmd, &o); err != nil {
+		return r, winerror.Errorf(cmd, "windows.dns.RecordAAAAUpdate: %w", err)
 	}
 
 	// Convert the output to a RecordAAAA object.
 	if err := r.convertOutput(o); err != nil {
-		return r, fmt.Errorf(cmd, "windows.dns.RecordAAAAUpdate: failed to convert output to RecordAAAA object: %s", err)
+		return r, fmt.Errorf(cmd, "windows.dns.RecordAAAAUpdate: failed to convert output to RecordAAAA object: %w", err)
 	}
 
 	return r, nil
@@ -261,8 +285,12 @@ type RecordAAAADeleteParams struct {
 
 // pwshCommand returns the PowerShell command to delete an AAAA-Record.
 func (params RecordAAAADeleteParams) pwshCommand() string {
-	// Base command
-	return fmt.Sprintf("Remove-DnsServerResourceRecord -RRType 'AAAA' -Force -Name '%s' -ZoneName '%s'", params.Name, params.Zone)
+	return parsing.NewPwshCommand("Remove-DnsServerResourceRecord").
+		Param("RRType", "AAAA").
+		Switch("Force").
+		Param("Name", params.Name).
+		Param("ZoneName", params.Zone).
+		String()
 }
 
 // RecordAAAADelete deletes an AAAA-Record.
@@ -275,10 +303,19 @@ func (c *Client) RecordAAAADelete(ctx context.Context, params RecordAAAADeletePa
 		return errors.New("windows.dns.RecordAAAADelete: record parameters 'Name' and 'Zone' must be set")
 	}
 
-	// Run command
 	cmd := params.pwshCommand()
-	if err := run(ctx, c, cmd, &o); err != nil {
-		return winerror.Errorf(cmd, "windows.dns.RecordAAAADelete: %s", err)
+
+	// Plan the change in dry-run mode
+	if c.DryRun {
+		current, err := c.RecordAAAARead(ctx, RecordAAAAReadParams{Name: params.Name, Zone: params.Zone})
+		return pwsh.Plan("windows.dns.RecordAAAADelete", cmd, current, err, func(RecordAAAA) RecordAAAA {
+			return RecordAAAA{}
+		})
+	}
+
+	// Run command
+	if err := run(ctx, c, "windows.dns.RecordAAAADelete", cmd, &o); err != nil {
+		return winerror.Errorf(cmd, "windows.dns.RecordAAAADelete: %w", err)
 	}
 
 	return nil
@@ -4,9 +4,10 @@ import (
 	"context"
 	"errors"
 	"fmt"
-	"strings"
 	"time"
 
+	"github.com/d-strobel/gowindows/internal/pwsh"
+	"github.com/d-strobel/gowindows/parsing"
 	"github.com/d-strobel/gowindows/winerror"
 )
 
@@ -39,16 +40,13 @@ type RecordCNameReadParams struct {
 
 // pwshCommand returns the PowerShell command to read a CName-Record.
 func (params RecordCNameReadParams) pwshCommand() string {
-	// Base command
-	cmd := []string{"Get-DnsServerResourceRecord -RRType 'CName' -Node"}
-
-	// Add parameters
-	cmd = append(cmd, fmt.Sprintf("-Name '%s'", params.Name))
-	cmd = append(cmd, fmt.Sprintf("-ZoneName '%s'", params.Zone))
-
-	// Ensure Json Output
-	cmd = append(cmd, "| ConvertTo-Json -Compress")
-	return strings.Join(cmd, " ")
+	return parsing.NewPwshCommand("Get-DnsServerResourceRecord").
+		Param("RRType", "CName").
+		Switch("Node").
+		Param("Name", params.Name).
+		Param("ZoneName", params.Zone).
+		ToJson().
+		String()
 }
 
 // RecordCNameRead gets a CName-Record. It returns a RecordCName object.
@@ -64,8 +62,8 @@ func (c *Client) RecordCNameRead(ctx context.Context, params RecordCNameReadPara
 
 	// Run command
 	cmd := params.pwshCommand()
-	if err := run(ctx, c, cmd, &o); err != nil {
-		return r, winerror.Errorf(cmd, "windows.dns.RecordCNameRead: %s", err)
+	if err := run(ctx, c, "windows.dns.RecordCNameRead", cmd, &o); err != nil {
+		return r, winerror.Errorf(cmd, "windows.dns.RecordCNameRead: %w", err)
 	}
 
 	// Convert the output to a RecordCName object.
@@ -93,27 +91,26 @@ type RecordCNameCreateParams struct {
 
 // pwshCommand returns the PowerShell command to create a new CName-Record.
 func (params RecordCNameCreateParams) pwshCommand() string {
-	// Base command
-	cmd := []string{"Add-DnsServerResourceRecordCName -AllowUpdateAny:$false -AgeRecord:$false -Confirm:$false -PassThru"}
-
-	// Add parameters
-	cmd = append(cmd, fmt.Sprintf("-Name '%s'", params.Name))
-	cmd = append(cmd, fmt.Sprintf("-ZoneName '%s'", params.Zone))
-	cmd = append(cmd, fmt.Sprintf("-HostNameAlias '%s'", params.CName))
-
 	// Set default TTL if not provided.
-	// New-TimeSpan only allows int32 values.
-	// https://learn.microsoft.com/de-de/powershell/module/microsoft.powershell.utility/new-timespan?view=powershell-7.4
 	if params.TimeToLive == 0 {
 		params.TimeToLive = defaultTimeToLive
 	}
-	seconds := int32(params.TimeToLive.Round(time.Second).Seconds())
-	cmd = append(cmd, fmt.Sprintf("-TimeToLive %s", fmt.Sprintf("$(New-TimeSpan -Seconds %d)", seconds)))
 
-	// Join the command and ensure Json Output
-	cmd = append(cmd, "| ConvertTo-Json -Compress")
+	// New-TimeSpan only allows int32 values. So we round the duration to seconds.
+	// https://learn.microsoft.com/de-de/powershell/module/microsoft.powershell.utility/new-timespan?view=powershell-7.4
+	seconds := int32(params.TimeToLive.Round(time.Second).Seconds())
 
-	return strings.Join(cmd, " ")
+	return parsing.NewPwshCommand("Add-DnsServerResourceRecordCName").
+		Param("AllowUpdateAny", false).
+		Param("AgeRecord", false).
+		Param("Confirm", false).
+		Switch("PassThru").
+		Param("Name", params.Name).
+		Param("ZoneName", params.Zone).
+		Param("HostNameAlias", params.CName).
+		Param("TimeToLive", parsing.PwshRaw(fmt.Sprintf("$(New-TimeSpan -Seconds %d)", seconds))).
+		ToJson().
+		String()
 }
 
 // RecordCNameCreate creates a CName-Record. It returns a RecordCName object.
@@ -127,15 +124,29 @@ func (c *Client) RecordCNameCreate(ctx context.Context, params RecordCNameCreate
 		return r, errors.New("windows.dns.RecordCNameCreate: record parameters 'Name', 'Zone' and 'CName' must be set")
 	}
 
-	// Run command
 	cmd := params.pwshCommand()
-	if err := run(ctx, c, cmd, &o); err != nil {
+
+	// Plan the change in dry-run mode
+	if c.DryRun {
+		current, err := c.RecordCNameRead(ctx, RecordCNameReadParams{Name: params.Name, Zone: params.Zone})
+		return r, pwsh.PlanCreate("windows.dns.RecordCNameCreate", cmd, current, err, func(r RecordCName) RecordCName {
+			r.Name = params.Name
+			r.CName = params.CName
+			r.TimeToLive = timeToLive(params.TimeToLive)
+			return r
+		})
+	}
+
+	// Run command
+	if err := run(ctx, c, "windows.dns.RecordCNameCreate", cmd, &o); err != nil {
 		// Handle record already exists error.
-		if strings.Contains(err.Error(), "ResourceExists") {
-			return r, winerror.Errorf(cmd, "windows.dns.RecordCNameCreate: the specified record already exists")
+		if winerror.HasCategory(err, "ResourceExists") {
+			winErr := winerror.Errorf(cmd, "windows.dns.RecordCNameCreate: the specified record already exists")
+			winErr.Records = winerror.UnwrapRecords(err)
+			return r, winErr
 		}
 
-		return r, winerror.Errorf(cmd, "windows.dns.RecordCNameCreate: %s", err)
+		return r, winerror.Errorf(cmd, "windows.dns.RecordCNameCreate: %w", err)
 	}
 
 	// Convert the output to a RecordCName object.
@@ -172,22 +183,23 @@ func (params RecordCNameUpdateParams) pwshCommand() string {
 	}
 	seconds := int32(params.TimeToLive.Round(time.Second).Seconds())
 
-	// Get command
-	cmd := []string{"$r=Get-DnsServerResourceRecord -RRType 'CName' -Node"}
-	cmd = append(cmd, fmt.Sprintf("-Name '%s'", params.Name))
-	cmd = append(cmd, fmt.Sprintf("-ZoneName '%s'", params.Zone))
-
-	// Add logic for handling TTL and CName update.
-	cmd = append(cmd, ";$n=[ciminstance]::new($r)")
-	cmd = append(cmd, fmt.Sprintf(";$n.TimeToLive=New-TimeSpan -Seconds %d", seconds))
-	cmd = append(cmd, fmt.Sprintf(";$n.RecordData.HostNameAlias='%s'", params.CName))
-	cmd = append(cmd, fmt.Sprintf(";Set-DnsServerResourceRecord -OldInputObject $r -NewInputObject $n -ZoneName '%s' -PassThru", params.Zone))
-
-	// Ensure Json Output
-	cmd = append(cmd, "| ConvertTo-Json -Compress")
-
-	// Return the full command.
-	return strings.Join(cmd, " ")
+	// Copy the record, update TTL and CName of the copy and replace the record with it.
+	return parsing.NewPwshCommand("Get-DnsServerResourceRecord").
+		Assign("r").
+		Param("RRType", "CName").
+		Switch("Node").
+		Param("Name", params.Name).
+		Param("ZoneName", params.Zone).
+		Then(parsing.NewPwshCommand("[ciminstance]::new($r)").Assign("n")).
+		Then(parsing.NewPwshCommand("New-TimeSpan").Assign("n.TimeToLive").Param("Seconds", seconds)).
+		Then(parsing.NewPwshCommand(parsing.PwshQuote(params.CName)).Assign("n.RecordData.HostNameAlias")).
+		Then(parsing.NewPwshCommand("Set-DnsServerResourceRecord").
+			Param("OldInputObject", parsing.PwshRaw("$r")).
+			Param("NewInputObject", parsing.PwshRaw("$n")).
+			Param("ZoneName", params.Zone).
+			Switch("PassThru").
+			ToJson()).
+		String()
 }
 
 // RecordCNameUpdate updates a CName-Record. It returns a RecordCName object.
@@ -201,10 +213,21 @@ func (c *Client) RecordCNameUpdate(ctx context.Context, params RecordCNameUpdate
 		return r, errors.New("windows.dns.RecordCNameUpdate: record parameters 'Name', 'Zone' and 'CName' must be set")
 	}
 
-	// Run command
 	cmd := params.pwshCommand()
-	if err := run(ctx, c, cmd, &o); err != nil {
-		return r, winerror.Errorf(cmd, "windows.dns.RecordCNameUpdate: %s", err)
+
+	// Plan the change in dry-run mode
+	if c.DryRun {
+		current, err := c.RecordCNameRead(ctx, RecordCNameReadParams{Name: params.Name, Zone: params.Zone})
+		return r, pwsh.Plan("windows.dns.RecordCNameUpdate", cmd, current, err, func(r RecordCName) RecordCName {
+			r.CName = params.CName
+			r.TimeToLive = timeToLive(params.TimeToLive)
+			return r
+		})
+	}
+
+	// Run command
+	if err := run(ctx, c, "windows.dns.RecordCNameUpdate", cmd, &o); err != nil {
+		return r, winerror.Errorf(cmd, "windows.dns.RecordCNameUpdate: %w", err)
 	}
 
 	// Convert the output to a RecordCName object.
@@ -224,8 +247,12 @@ type RecordCNameDeleteParams struct {
 
 // pwshCommand returns the PowerShell command to delete a CName-Record.
 func (params RecordCNameDeleteParams) pwshCommand() string {
-	// Base command
-	return fmt.Sprintf("Remove-DnsServerResourceRecord -RRType 'CName' -Force -Name '%s' -ZoneName '%s'", params.Name, params.Zone)
+	return parsing.NewPwshCommand("Remove-DnsServerResourceRecord").
+		Param("RRType", "CName").
+		Switch("Force").
+		Param("Name", params.Name).
+		Param("ZoneName", params.Zone).
+		String()
 }
 
 // RecordCNameDelete deletes a CName-Record.
@@ -238,10 +265,19 @@ func (c *Client) RecordCNameDelete(ctx context.Context, params RecordCNameDelete
 		return errors.New("windows.dns.RecordCNameDelete: record parameters 'Name' and 'Zone' must be set")
 	}
 
-	// Run command
 	cmd := params.pwshCommand()
-	if err := run(ctx, c, cmd, &o); err != nil {
-		return winerror.Errorf(cmd, "windows.dns.RecordCNameDelete: %s", err)
+
+	// Plan the change in dry-run mode
+	if c.DryRun {
+		current, err := c.RecordCNameRead(ctx, RecordCNameReadParams{Name: params.Name, Zone: params.Zone})
+		return pwsh.Plan("windows.dns.RecordCNameDelete", cmd, current, err, func(RecordCName) RecordCName {
+			return RecordCName{}
+		})
+	}
+
+	// Run command
+	if err := run(ctx, c, "windows.dns.RecordCNameDelete", cmd, &o); err != nil {
+		return winerror.Errorf(cmd, "windows.dns.RecordCNameDelete: %w", err)
 	}
 
 	return nil
@@ -0,0 +1,75 @@
+package dns_test
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/d-strobel/gowindows/internal/acctest"
+	"github.com/d-strobel/gowindows/windows/dns"
+	"github.com/d-strobel/gowindows/winerror"
+)
+
+func (suite *DnsAccTestSuite) TestRecordCName1Create() {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	for i, c := range suite.clients {
+		params := dns.RecordCNameCreateParams{
+			Name:       fmt.Sprintf("test-cname-%d", i),
+			Zone:       acctest.TestZone,
+			CName:      "web.gowindows.test.",
+			TimeToLive: time.Hour,
+		}
+		r, err := c.RecordCNameCreate(ctx, params)
+		suite.Require().NoError(err)
+		suite.Equal(fmt.Sprintf("test-cname-%d", i), r.Name)
+		suite.Equal("web.gowindows.test.", r.CName)
+		suite.Equal(time.Hour, r.TimeToLive)
+	}
+}
+
+func (suite *DnsAccTestSuite) TestRecordCName2Read() {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	for i, c := range suite.clients {
+		r, err := c.RecordCNameRead(ctx, dns.RecordCNameReadParams{Name: fmt.Sprintf("test-cname-%d", i), Zone: acctest.TestZone})
+		suite.Require().NoError(err)
+		suite.Equal("web.gowindows.test.", r.CName)
+	}
+}
+
+func (suite *DnsAccTestSuite) TestRecordCName3Update() {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	for i, c := range suite.clients {
+		params := dns.RecordCNameUpdateParams{
+			Name:       fmt.Sprintf("test-cname-%d", i),
+			Zone:       acctest.TestZone,
+			CName:      "app.gowindows.test.",
+			TimeToLive: 2 * time.Hour,
+		}
+		r, err := c.RecordCNameUpdate(ctx, params)
+		suite.Require().NoError(err)
+		suite.Equal("app.gowindows.test.", r.CName)
+		suite.Equal(2*time.Hour, r.TimeToLive)
+	}
+}
+
+func (suite *DnsAccTestSuite) TestRecordCName4Delete() {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	for i, c := range suite.clients {
+		params := dns.RecordCNameDeleteParams{
+			Name: fmt.Sprintf("test-cname-%d", i),
+			Zone: acctest.TestZone,
+		}
+		suite.NoError(c.RecordCNameDelete(ctx, params))
+
+		_, err := c.RecordCNameRead(ctx, dns.RecordCNameReadParams{Name: params.Name, Zone: params.Zone})
+		suite.ErrorIs(err, winerror.ErrNotFound)
+	}
+}
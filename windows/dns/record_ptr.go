@@ -4,9 +4,10 @@ import (
 	"context"
 	"errors"
 	"fmt"
-	"strings"
 	"time"
 
+	"github.com/d-strobel/gowindows/internal/pwsh"
+	"github.com/d-strobel/gowindows/parsing"
 	"github.com/d-strobel/gowindows/winerror"
 )
 
@@ -39,16 +40,13 @@ type RecordPTRReadParams struct {
 
 // pwshCommand returns the PowerShell command to read a PTR-Record.
 func (params RecordPTRReadParams) pwshCommand() string {
-	// Base command
-	cmd := []string{"Get-DnsServerResourceRecord -RRType 'PTR' -Node"}
-
-	// Add parameters
-	cmd = append(cmd, fmt.Sprintf("-Name '%s'", params.Name))
-	cmd = append(cmd, fmt.Sprintf("-ZoneName '%s'", params.Zone))
-
-	// Ensure Json Output
-	cmd = append(cmd, "| ConvertTo-Json -Compress")
-	return strings.Join(cmd, " ")
+	return parsing.NewPwshCommand("Get-DnsServerResourceRecord").
+		Param("RRType", "PTR").
+		Switch("Node").
+		Param("Name", params.Name).
+		Param("ZoneName", params.Zone).
+		ToJson().
+		String()
 }
 
 // RecordPTRRead gets a PTR-Record. It returns a RecordPTR object.
@@ -64,8 +62,8 @@ func (c *Client) RecordPTRRead(ctx context.Context, params RecordPTRReadParams)
 
 	// Run command
 	cmd := params.pwshCommand()
-	if err := run(ctx, c, cmd, &o); err != nil {
-		return r, winerror.Errorf(cmd, "windows.dns.RecordPTRRead: %s", err)
+	if err := run(ctx, c, "windows.dns.RecordPTRRead", cmd, &o); err != nil {
+		return r, winerror.Errorf(cmd, "windows.dns.RecordPTRRead: %w", err)
 	}
 
 	// Convert the output to a RecordPTR object.
@@ -93,27 +91,26 @@ type RecordPTRCreateParams struct {
 
 // pwshCommand returns the PowerShell command to create a new PTR-Record.
 func (params RecordPTRCreateParams) pwshCommand() string {
-	// Base command
-	cmd := []string{"Add-DnsServerResourceRecordPTR -AllowUpdateAny:$false -AgeRecord:$false -Confirm:$false -PassThru"}
-
-	// Add parameters
-	cmd = append(cmd, fmt.Sprintf("-Name '%s'", params.Name))
-	cmd = append(cmd, fmt.Sprintf("-ZoneName '%s'", params.Zone))
-	cmd = append(cmd, fmt.Sprintf("-PtrDomainName '%s'", params.PTR))
-
 	// Set default TTL if not provided.
-	// New-TimeSpan only allows int32 values.
-	// https://learn.microsoft.com/de-de/powershell/module/microsoft.powershell.utility/new-timespan?view=powershell-7.4
 	if params.TimeToLive == 0 {
 		params.TimeToLive = defaultTimeToLive
 	}
-	seconds := int32(params.TimeToLive.Round(time.Second).Seconds())
-	cmd = append(cmd, fmt.Sprintf("-TimeToLive %s", fmt.Sprintf("$(New-TimeSpan -Seconds %d)", seconds)))
 
-	// Join the command and ensure Json Output
-	cmd = append(cmd, "| ConvertTo-Json -Compress")
+	// New-TimeSpan only allows int32 values. So we round the duration to seconds.
+	// https://learn.microsoft.com/de-de/powershell/module/microsoft.powershell.utility/new-timespan?view=powershell-7.4
+	seconds := int32(params.TimeToLive.Round(time.Second).Seconds())
 
-	return strings.Join(cmd, " ")
+	return parsing.NewPwshCommand("Add-DnsServerResourceRecordPTR").
+		Param("AllowUpdateAny", false).
+		Param("AgeRecord", false).
+		Param("Confirm", false).
+		Switch("PassThru").
+		Param("Name", params.Name).
+		Param("ZoneName", params.Zone).
+		Param("PtrDomainName", params.PTR).
+		Param("TimeToLive", parsing.PwshRaw(fmt.Sprintf("$(New-TimeSpan -Seconds %d)", seconds))).
+		ToJson().
+		String()
 }
 
 // RecordPTRCreate creates a PTR-Record. It returns a RecordPTR object.
@@ -127,15 +124,29 @@ func (c *Client) RecordPTRCreate(ctx context.Context, params RecordPTRCreatePara
 		return r, errors.New("windows.dns.RecordPTRCreate: record parameters 'Name', 'Zone' and 'PTR' must be set")
 	}
 
-	// Run command
 	cmd := params.pwshCommand()
-	if err := run(ctx, c, cmd, &o); err != nil {
+
+	// Plan the change in dry-run mode
+	if c.DryRun {
+		current, err := c.RecordPTRRead(ctx, RecordPTRReadParams{Name: params.Name, Zone: params.Zone})
+		return r, pwsh.PlanCreate("windows.dns.RecordPTRCreate", cmd, current, err, func(r RecordPTR) RecordPTR {
+			r.Name = params.Name
+			r.PTR = params.PTR
+			r.TimeToLive = timeToLive(params.TimeToLive)
+			return r
+		})
+	}
+
+	// Run command
+	if err := run(ctx, c, "windows.dns.RecordPTRCreate", cmd, &o); err != nil {
 		// Handle record already exists error.
-		if strings.Contains(err.Error(), "ResourceExists") {
-			return r, winerror.Errorf(cmd, "windows.dns.RecordPTRCreate: the specified record already exists")
+		if winerror.HasCategory(err, "ResourceExists") {
+			winErr := winerror.Errorf(cmd, "windows.dns.RecordPTRCreate: the specified record already exists")
+			winErr.Records = winerror.UnwrapRecords(err)
+			return r, winErr
 		}
 
-		return r, winerror.Errorf(cmd, "windows.dns.RecordPTRCreate: %s", err)
+		return r, winerror.Errorf(cmd, "windows.dns.RecordPTRCreate: %w", err)
 	}
 
 	// Convert the output to a RecordPTR object.
@@ -172,22 +183,23 @@ func (params RecordPTRUpdateParams) pwshCommand() string {
 	}
 	seconds := int32(params.TimeToLive.Round(time.Second).Seconds())
 
-	// Get command
-	cmd := []string{"$r=Get-DnsServerResourceRecord -RRType 'PTR' -Node"}
-	cmd = append(cmd, fmt.Sprintf("-Name '%s'", params.Name))
-	cmd = append(cmd, fmt.Sprintf("-ZoneName '%s'", params.Zone))
-
-	// Add logic for handling TTL and PTR update.
-	cmd = append(cmd, ";$n=[ciminstance]::new($r)")
-	cmd = append(cmd, fmt.Sprintf(";$n.TimeToLive=New-TimeSpan -Seconds %d", seconds))
-	cmd = append(cmd, fmt.Sprintf(";$n.RecordData.PtrDomainName='%s'", params.PTR))
-	cmd = append(cmd, fmt.Sprintf(";Set-DnsServerResourceRecord -OldInputObject $r -NewInputObject $n -ZoneName '%s' -PassThru", params.Zone))
-
-	// Ensure Json Output
-	cmd = append(cmd, "| ConvertTo-Json -Compress")
-
-	// Return the full command.
-	return strings.Join(cmd, " ")
+	// Copy the record, update TTL and PTR of the copy and replace the record with it.
+	return parsing.NewPwshCommand("Get-DnsServerResourceRecord").
+		Assign("r").
+		Param("RRType", "PTR").
+		Switch("Node").
+		Param("Name", params.Name).
+		Param("ZoneName", params.Zone).
+		Then(parsing.NewPwshCommand("[ciminstance]::new($r)").Assign("n")).
+		Then(parsing.NewPwshCommand("New-TimeSpan").Assign("n.TimeToLive").Param("Seconds", seconds)).
+		Then(parsing.NewPwshCommand(parsing.PwshQuote(params.PTR)).Assign("n.RecordData.PtrDomainName")).
+		Then(parsing.NewPwshCommand("Set-DnsServerResourceRecord").
+			Param("OldInputObject", parsing.PwshRaw("$r")).
+			Param("NewInputObject", parsing.PwshRaw("$n")).
+			Param("ZoneName", params.Zone).
+			Switch("PassThru").
+			ToJson()).
+		String()
 }
 
 // RecordPTRUpdate updates a PTR-Record. It returns a RecordPTR object.
@@ -201,10 +213,21 @@ func (c *Client) RecordPTRUpdate(ctx context.Context, params RecordPTRUpdatePara
 		return r, errors.New("windows.dns.RecordPTRUpdate: record parameters 'Name', 'Zone' and 'PTR' must be set")
 	}
 
-	// Run command
 	cmd := params.pwshCommand()
-	if err := run(ctx, c, cmd, &o); err != nil {
-		return r, winerror.Errorf(cmd, "windows.dns.RecordPTRUpdate: %s", err)
+
+	// Plan the change in dry-run mode
+	if c.DryRun {
+		current, err := c.RecordPTRRead(ctx, RecordPTRReadParams{Name: params.Name, Zone: params.Zone})
+		return r, pwsh.Plan("windows.dns.RecordPTRUpdate", cmd, current, err, func(r RecordPTR) RecordPTR {
+			r.PTR = params.PTR
+			r.TimeToLive = timeToLive(params.TimeToLive)
+			return r
+		})
+	}
+
+	// Run command
+	if err := run(ctx, c, "windows.dns.RecordPTRUpdate", cmd, &o); err != nil {
+		return r, winerror.Errorf(cmd, "windows.dns.RecordPTRUpdate: %w", err)
 	}
 
 	// Convert the output to a RecordPTR object.
@@ -224,8 +247,12 @@ type RecordPTRDeleteParams struct {
 
 // pwshCommand returns the PowerShell command to delete a PTR-Record.
 func (params RecordPTRDeleteParams) pwshCommand() string {
-	// Base command
-	return fmt.Sprintf("Remove-DnsServerResourceRecord -RRType 'PTR' -Force -Name '%s' -ZoneName '%s'", params.Name, params.Zone)
+	return parsing.NewPwshCommand("Remove-DnsServerResourceRecord").
+		Param("RRType", "PTR").
+		Switch("Force").
+		Param("Name", params.Name).
+		Param("ZoneName", params.Zone).
+		String()
 }
 
 // RecordPTRDelete deletes a PTR-Record.
@@ -238,10 +265,19 @@ func (c *Client) RecordPTRDelete(ctx context.Context, params RecordPTRDeletePara
 		return errors.New("windows.dns.RecordPTRDelete: record parameters 'Name' and 'Zone' must be set")
 	}
 
-	// Run command
 	cmd := params.pwshCommand()
-	if err := run(ctx, c, cmd, &o); err != nil {
-		return winerror.Errorf(cmd, "windows.dns.RecordPTRDelete: %s", err)
+
+	// Plan the change in dry-run mode
+	if c.DryRun {
+		current, err := c.RecordPTRRead(ctx, RecordPTRReadParams{Name: params.Name, Zone: params.Zone})
+		return pwsh.Plan("windows.dns.RecordPTRDelete", cmd, current, err, func(RecordPTR) RecordPTR {
+			return RecordPTR{}
+		})
+	}
+
+	// Run command
+	if err := run(ctx, c, "windows.dns.RecordPTRDelete", cmd, &o); err != nil {
+		return winerror.Errorf(cmd, "windows.dns.RecordPTRDelete: %w", err)
 	}
 
 	return nil
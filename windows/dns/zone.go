@@ -3,9 +3,9 @@ package dns
 import (
 	"context"
 	"errors"
-	"fmt"
 	"strings"
 
+	"github.com/d-strobel/gowindows/parsing"
 	"github.com/d-strobel/gowindows/winerror"
 )
 
@@ -42,14 +42,10 @@ type ZoneReadParams struct {
 
 // pwshCommand returns the PowerShell command to read a local group by SID or Name.
 func (params ZoneReadParams) pwshCommand() string {
-	// Base command
-	cmd := []string{"Get-DnsServerZone"}
-
-	// Add parameters
-	cmd = append(cmd, fmt.Sprintf("-Name '%s'", params.Name))
-
-	cmd = append(cmd, "| ConvertTo-Json -Compress")
-	return strings.Join(cmd, " ")
+	return parsing.NewPwshCommand("Get-DnsServerZone").
+		Param("Name", params.Name).
+		ToJson().
+		String()
 }
 
 // ZoneRead gets a DNS server zone by Name and returns a Zone object.
@@ -69,8 +65,8 @@ func (c *Client) ZoneRead(ctx context.Context, params ZoneReadParams) (Zone, err
 
 	// Run command
 	cmd := params.pwshCommand()
-	if err := run(ctx, c, cmd, &z); err != nil {
-		return z, winerror.Errorf(cmd, "windows.dns.server.ZoneRead: %s", err)
+	if err := run(ctx, c, "windows.dns.ZoneRead", cmd, &z); err != nil {
+		return z, winerror.Errorf(cmd, "windows.dns.server.ZoneRead: %w", err)
 	}
 	return z, nil
 }
@@ -82,8 +78,8 @@ func (c *Client) ZoneList(ctx context.Context) ([]Zone, error) {
 
 	// Run command
 	cmd := "Get-DnsServerZone | ConvertTo-Json -Compress"
-	if err := run(ctx, c, cmd, &z); err != nil {
-		return z, winerror.Errorf(cmd, "windows.dns.server.ZoneList: %s", err)
+	if err := run(ctx, c, "windows.dns.ZoneList", cmd, &z); err != nil {
+		return z, winerror.Errorf(cmd, "windows.dns.server.ZoneList: %w", err)
 	}
 	return z, nil
 }
@@ -0,0 +1,38 @@
+package dns_test
+
+import (
+	"context"
+
+	"github.com/d-strobel/gowindows/internal/acctest"
+	"github.com/d-strobel/gowindows/windows/dns"
+)
+
+func (suite *DnsAccTestSuite) TestZone1Read() {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	for _, c := range suite.clients {
+		z, err := c.ZoneRead(ctx, dns.ZoneReadParams{Name: acctest.TestZone})
+		suite.Require().NoError(err)
+		suite.Equal(acctest.TestZone, z.ZoneName)
+		suite.Equal("Primary", z.ZoneType)
+		suite.False(z.IsDsIntegrated)
+		suite.False(z.IsReverseLookupZone)
+	}
+}
+
+func (suite *DnsAccTestSuite) TestZone2List() {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	for _, c := range suite.clients {
+		zones, err := c.ZoneList(ctx)
+		suite.Require().NoError(err)
+
+		var names []string
+		for _, z := range zones {
+			names = append(names, z.ZoneName)
+		}
+		suite.Contains(names, acctest.TestZone)
+	}
+}
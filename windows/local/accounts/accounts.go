@@ -5,23 +5,27 @@ package accounts
 
 import (
 	"context"
-	"encoding/json"
+	"io"
 
-	"errors"
 	"github.com/d-strobel/gowindows/connection"
+	"github.com/d-strobel/gowindows/internal/pwsh"
 	"github.com/d-strobel/gowindows/parsing"
+	"github.com/d-strobel/gowindows/telemetry"
 )
 
-// accounts is a type constraint for the run function, ensuring it works with specific types.
-type accounts interface {
-	Group | []Group | User | []User | GroupMember | []GroupMember
-}
-
 // Client represents a client for handling local Windows functions.
 type Client struct {
 	// Connection represents a connection.Connection object.
 	Connection connection.Connection
 
+	// DryRun indicates whether the mutating functions return a *dryrun.Change instead of applying the change.
+	// The change contains the PowerShell command and the diff against the current state of the resource.
+	DryRun bool
+
+	// Telemetry logs and traces the calls of the client.
+	// A nil Telemetry disables the logging and tracing.
+	Telemetry *telemetry.Telemetry
+
 	// decodeCliXmlErr represents a function that decodes a CLIXML error and returns aa  human readable string.
 	decodeCliXmlErr func(string) (string, error)
 }
@@ -45,31 +49,14 @@ type SID struct {
 
 // run runs a PowerShell command against a Windows system, handles the command results,
 // and unmarshals the output into a local object type.
-func run[T accounts](ctx context.Context, c *Client, cmd string, l *T) error {
-	// Run the command
-	result, err := c.Connection.RunWithPowershell(ctx, cmd)
-	if err != nil {
-		return err
-	}
-
-	// Handle stderr
-	if result.StdErr != "" {
-		stderr, err := c.decodeCliXmlErr(result.StdErr)
-		if err != nil {
-			return err
-		}
-
-		return errors.New(stderr)
-	}
-
-	if result.StdOut == "" {
-		return nil
-	}
-
-	// Unmarshal stdout
-	if err = json.Unmarshal([]byte(result.StdOut), &l); err != nil {
-		return err
-	}
+// The operation is the name of the calling function, which is the name of its span, see telemetry.Telemetry.
+func run[T any](ctx context.Context, c *Client, operation string, cmd string, v *T) error {
+	return runWithInput(ctx, c, operation, cmd, nil, v)
+}
 
-	return nil
+// runWithInput runs a PowerShell command like run and sends stdin to the standard input of the command.
+func runWithInput[T any](ctx context.Context, c *Client, operation string, cmd string, stdin io.Reader, v *T) error {
+	return c.Telemetry.Observe(ctx, operation, cmd, func(ctx context.Context) error {
+		return pwsh.RunWithInput(ctx, c.Connection, c.decodeCliXmlErr, cmd, stdin, v)
+	})
 }
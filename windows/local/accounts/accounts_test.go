@@ -7,6 +7,7 @@ import (
 
 	"github.com/d-strobel/gowindows/connection"
 	mockConnection "github.com/d-strobel/gowindows/connection/mocks"
+	"github.com/d-strobel/gowindows/winerror"
 	"github.com/stretchr/testify/suite"
 )
 
@@ -47,7 +48,7 @@ func (suite *LocalUnitTestSuite) TestLocalRun() {
 			RunWithPowershell(ctx, cmd).
 			Return(connection.CmdResult{StdOut: usersGroup, StdErr: ""}, nil)
 		var g Group
-		err := run(ctx, c, cmd, &g)
+		err := run(ctx, c, "windows.local.accounts.Test", cmd, &g)
 		suite.NoError(err)
 		suite.Equal(expectedUsersGroup, g)
 	})
@@ -65,7 +66,7 @@ func (suite *LocalUnitTestSuite) TestLocalRun() {
 			RunWithPowershell(ctx, cmd).
 			Return(connection.CmdResult{StdOut: groupList, StdErr: ""}, nil)
 		var g []Group
-		err := run(ctx, c, cmd, &g)
+		err := run(ctx, c, "windows.local.accounts.Test", cmd, &g)
 		suite.NoError(err)
 		suite.Equal(expectedGroupList, g)
 	})
@@ -84,7 +85,7 @@ func (suite *LocalUnitTestSuite) TestLocalRun() {
 			Return(connection.CmdResult{StdOut: "", StdErr: ""}, nil)
 		var g Group
 		var expectedGroup Group
-		err := run(ctx, c, cmd, &g)
+		err := run(ctx, c, "windows.local.accounts.Test", cmd, &g)
 		suite.NoError(err)
 		suite.Equal(expectedGroup, g)
 	})
@@ -103,9 +104,9 @@ func (suite *LocalUnitTestSuite) TestLocalRun() {
 			RunWithPowershell(ctx, cmd).
 			Return(connection.CmdResult{}, expectedErr)
 		var g Group
-		err := run(ctx, c, cmd, &g)
-		suite.Error(err)
-		suite.Equal(expectedErr, err)
+		err := run(ctx, c, "windows.local.accounts.Test", cmd, &g)
+		suite.ErrorIs(err, expectedErr)
+		suite.ErrorIs(err, winerror.ErrTransport)
 	})
 
 	suite.Run("should return powershell error", func() {
@@ -121,10 +122,10 @@ func (suite *LocalUnitTestSuite) TestLocalRun() {
 			RunWithPowershell(ctx, cmd).
 			Return(connection.CmdResult{StdOut: "", StdErr: "test-error"}, nil)
 		var g Group
-		expectedErr := errors.New("test-error")
-		err := run(ctx, c, cmd, &g)
-		suite.Error(err)
-		suite.Equal(expectedErr, err)
+		err := run(ctx, c, "windows.local.accounts.Test", cmd, &g)
+		suite.EqualError(err, "test-error")
+		suite.IsType(&winerror.WinError{}, err)
+		suite.Equal(cmd, winerror.UnwrapCommand(err))
 	})
 
 	suite.Run("should return error from json unmarshal with incorrect json", func() {
@@ -140,7 +141,7 @@ func (suite *LocalUnitTestSuite) TestLocalRun() {
 			RunWithPowershell(ctx, cmd).
 			Return(connection.CmdResult{StdOut: groupList}, nil)
 		var g Group
-		err := run(ctx, c, cmd, &g)
+		err := run(ctx, c, "windows.local.accounts.Test", cmd, &g)
 		suite.Error(err)
 	})
 }
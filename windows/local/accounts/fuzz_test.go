@@ -0,0 +1,37 @@
+package accounts
+
+import (
+	"testing"
+
+	"github.com/d-strobel/gowindows/internal/pwshtest"
+)
+
+// FuzzPwshCommandQuoting asserts that user input can never escape its PowerShell argument.
+func FuzzPwshCommandQuoting(f *testing.F) {
+	builders := pwshtest.Builders{
+		"UserRead":    func(s string) string { return UserReadParams{Name: s}.pwshCommand() },
+		"UserReadSID": func(s string) string { return UserReadParams{SID: s}.pwshCommand() },
+		"UserCreate": func(s string) string {
+			return UserCreateParams{Name: s, Description: s, FullName: s, Password: s}.pwshCommand()
+		},
+		"UserUpdate": func(s string) string {
+			return UserUpdateParams{Name: s, Description: s, FullName: s, Password: s}.pwshCommand()
+		},
+		"UserUpdateSID":     func(s string) string { return UserUpdateParams{SID: s}.pwshCommand() },
+		"UserDelete":        func(s string) string { return UserDeleteParams{Name: s}.pwshCommand() },
+		"UserDeleteSID":     func(s string) string { return UserDeleteParams{SID: s}.pwshCommand() },
+		"GroupRead":         func(s string) string { return GroupReadParams{Name: s}.pwshCommand() },
+		"GroupReadSID":      func(s string) string { return GroupReadParams{SID: s}.pwshCommand() },
+		"GroupCreate":       func(s string) string { return GroupCreateParams{Name: s, Description: s}.pwshCommand() },
+		"GroupUpdate":       func(s string) string { return GroupUpdateParams{Name: s, Description: s}.pwshCommand() },
+		"GroupUpdateSID":    func(s string) string { return GroupUpdateParams{SID: s, Description: s}.pwshCommand() },
+		"GroupDelete":       func(s string) string { return GroupDeleteParams{Name: s}.pwshCommand() },
+		"GroupDeleteSID":    func(s string) string { return GroupDeleteParams{SID: s}.pwshCommand() },
+		"GroupMemberRead":   func(s string) string { return GroupMemberReadParams{Name: s, Member: s}.pwshCommand() },
+		"GroupMemberList":   func(s string) string { return GroupMemberListParams{SID: s}.pwshCommand() },
+		"GroupMemberCreate": func(s string) string { return GroupMemberCreateParams{Name: s, Member: s}.pwshCommand() },
+		"GroupMemberDelete": func(s string) string { return GroupMemberDeleteParams{SID: s, Member: s}.pwshCommand() },
+	}
+
+	pwshtest.FuzzQuoting(f, builders)
+}
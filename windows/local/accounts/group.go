@@ -3,9 +3,10 @@ package accounts
 import (
 	"context"
 	"errors"
-	"fmt"
 	"strings"
 
+	"github.com/d-strobel/gowindows/internal/pwsh"
+	"github.com/d-strobel/gowindows/parsing"
 	"github.com/d-strobel/gowindows/winerror"
 )
 
@@ -28,18 +29,16 @@ type GroupReadParams struct {
 // pwshCommand returns the PowerShell command to read a local group by SID or Name.
 func (params GroupReadParams) pwshCommand() string {
 	// Base command
-	cmd := []string{"Get-LocalGroup"}
+	cmd := parsing.NewPwshCommand("Get-LocalGroup")
 
-	// Add parameters
 	// Prefer SID over Name
 	if params.SID != "" {
-		cmd = append(cmd, fmt.Sprintf("-SID %s", params.SID))
+		cmd.Param("SID", params.SID)
 	} else if params.Name != "" {
-		cmd = append(cmd, fmt.Sprintf("-Name '%s'", params.Name))
+		cmd.Param("Name", params.Name)
 	}
 
-	cmd = append(cmd, "| ConvertTo-Json -Compress")
-	return strings.Join(cmd, " ")
+	return cmd.ToJson().String()
 }
 
 // GroupRead gets a local group by SID or Name and returns a Group object.
@@ -59,8 +58,8 @@ func (c *Client) GroupRead(ctx context.Context, params GroupReadParams) (Group,
 
 	// Run command
 	cmd := params.pwshCommand()
-	if err := run(ctx, c, cmd, &g); err != nil {
-		return g, winerror.Errorf(cmd, "windows.local.accounts.GroupRead: %s", err)
+	if err := run(ctx, c, "windows.local.accounts.GroupRead", cmd, &g); err != nil {
+		return g, winerror.Errorf(cmd, "windows.local.accounts.GroupRead: %w", err)
 	}
 	return g, nil
 }
@@ -74,8 +73,8 @@ func (c *Client) GroupList(ctx context.Context) ([]Group, error) {
 	cmd := "Get-LocalGroup | ConvertTo-Json -Compress"
 
 	// Run command
-	if err := run(ctx, c, cmd, &g); err != nil {
-		return g, winerror.Errorf(cmd, "windows.local.accounts.GroupList: %s", err)
+	if err := run(ctx, c, "windows.local.accounts.GroupList", cmd, &g); err != nil {
+		return g, winerror.Errorf(cmd, "windows.local.accounts.GroupList: %w", err)
 	}
 	return g, nil
 }
@@ -94,17 +93,14 @@ type GroupCreateParams struct {
 // pwshCommand returns the PowerShell command to create a local group.
 func (params GroupCreateParams) pwshCommand() string {
 	// Base command
-	cmd := []string{"New-LocalGroup"}
-
-	// Add parameters
-	cmd = append(cmd, fmt.Sprintf("-Name '%s'", params.Name))
+	cmd := parsing.NewPwshCommand("New-LocalGroup").Param("Name", params.Name)
 
+	// Add optional parameters
 	if params.Description != "" {
-		cmd = append(cmd, fmt.Sprintf("-Description '%s'", params.Description))
+		cmd.Param("Description", params.Description)
 	}
 
-	cmd = append(cmd, "| ConvertTo-Json -Compress")
-	return strings.Join(cmd, " ")
+	return cmd.ToJson().String()
 }
 
 // GroupCreate creates a new local group and returns the Group object.
@@ -117,10 +113,23 @@ func (c *Client) GroupCreate(ctx context.Context, params GroupCreateParams) (Gro
 		return g, errors.New("windows.local.accounts.GroupCreate: group parameter 'Name' must be set")
 	}
 
-	// Run command
 	cmd := params.pwshCommand()
-	if err := run(ctx, c, cmd, &g); err != nil {
-		return g, winerror.Errorf(cmd, "windows.local.accounts.GroupCreate: %s", err)
+
+	// Plan the change in dry-run mode
+	if c.DryRun {
+		current, err := c.GroupRead(ctx, GroupReadParams{Name: params.Name})
+		return g, pwsh.PlanCreate("windows.local.accounts.GroupCreate", cmd, current, err, func(g Group) Group {
+			g.Name = params.Name
+			if params.Description != "" {
+				g.Description = params.Description
+			}
+			return g
+		})
+	}
+
+	// Run command
+	if err := run(ctx, c, "windows.local.accounts.GroupCreate", cmd, &g); err != nil {
+		return g, winerror.Errorf(cmd, "windows.local.accounts.GroupCreate: %w", err)
 	}
 
 	return g, nil
@@ -141,23 +150,23 @@ type GroupUpdateParams struct {
 // pwshCommand returns the PowerShell command to update a local group.
 func (params GroupUpdateParams) pwshCommand() string {
 	// Base command
-	cmd := []string{"Set-LocalGroup"}
+	cmd := parsing.NewPwshCommand("Set-LocalGroup")
 
-	// Add parameters
 	// Prefer SID over Name to identifiy group
 	if params.SID != "" {
-		cmd = append(cmd, fmt.Sprintf("-SID %s", params.SID))
+		cmd.Param("SID", params.SID)
 	} else if params.Name != "" {
-		cmd = append(cmd, fmt.Sprintf("-Name '%s'", params.Name))
+		cmd.Param("Name", params.Name)
 	}
 
+	// An empty description can not be set, so we set a whitespace instead.
 	if params.Description == "" {
-		cmd = append(cmd, "-Description ' '")
+		cmd.Param("Description", " ")
 	} else {
-		cmd = append(cmd, fmt.Sprintf("-Description '%s'", params.Description))
+		cmd.Param("Description", params.Description)
 	}
 
-	return strings.Join(cmd, " ")
+	return cmd.String()
 }
 
 // GroupUpdate updates a local group.
@@ -170,10 +179,24 @@ func (c *Client) GroupUpdate(ctx context.Context, params GroupUpdateParams) erro
 		return errors.New("windows.local.accounts.GroupUpdate: group parameter 'Name' or 'SID' must be set")
 	}
 
-	// Run command
 	cmd := params.pwshCommand()
-	if err := run(ctx, c, cmd, &g); err != nil {
-		return winerror.Errorf(cmd, "windows.local.accounts.GroupUpdate: %s", err)
+
+	// Plan the change in dry-run mode
+	if c.DryRun {
+		current, err := c.GroupRead(ctx, GroupReadParams{Name: params.Name, SID: params.SID})
+		return pwsh.Plan("windows.local.accounts.GroupUpdate", cmd, current, err, func(g Group) Group {
+			// An empty description is set as a whitespace.
+			g.Description = params.Description
+			if params.Description == "" {
+				g.Description = " "
+			}
+			return g
+		})
+	}
+
+	// Run command
+	if err := run(ctx, c, "windows.local.accounts.GroupUpdate", cmd, &g); err != nil {
+		return winerror.Errorf(cmd, "windows.local.accounts.GroupUpdate: %w", err)
 	}
 
 	return nil
@@ -191,17 +214,16 @@ type GroupDeleteParams struct {
 // pwshCommand returns the PowerShell command to delete a local group by SID or Name.
 func (params GroupDeleteParams) pwshCommand() string {
 	// Base command
-	cmd := []string{"Remove-LocalGroup"}
+	cmd := parsing.NewPwshCommand("Remove-LocalGroup")
 
-	// Add parameters
 	// Prefer SID over Name to identifiy group
 	if params.SID != "" {
-		cmd = append(cmd, fmt.Sprintf("-SID %s", params.SID))
+		cmd.Param("SID", params.SID)
 	} else if params.Name != "" {
-		cmd = append(cmd, fmt.Sprintf("-Name '%s'", params.Name))
+		cmd.Param("Name", params.Name)
 	}
 
-	return strings.Join(cmd, " ")
+	return cmd.String()
 }
 
 // GroupDelete removes a local group by SID or Name.
@@ -214,10 +236,19 @@ func (c *Client) GroupDelete(ctx context.Context, params GroupDeleteParams) erro
 		return errors.New("windows.local.accounts.GroupDelete: group parameter 'Name' or 'SID' must be set")
 	}
 
-	// Run command
 	cmd := params.pwshCommand()
-	if err := run(ctx, c, cmd, &g); err != nil {
-		return winerror.Errorf(cmd, "windows.local.accounts.GroupDelete: %s", err)
+
+	// Plan the change in dry-run mode
+	if c.DryRun {
+		current, err := c.GroupRead(ctx, GroupReadParams{Name: params.Name, SID: params.SID})
+		return pwsh.Plan("windows.local.accounts.GroupDelete", cmd, current, err, func(Group) Group {
+			return Group{}
+		})
+	}
+
+	// Run command
+	if err := run(ctx, c, "windows.local.accounts.GroupDelete", cmd, &g); err != nil {
+		return winerror.Errorf(cmd, "windows.local.accounts.GroupDelete: %w", err)
 	}
 
 	return nil
@@ -65,12 +65,12 @@ func (suite *LocalUnitTestSuite) TestGroupReadPwshCommand() {
 			{
 				"assert users group by sid",
 				GroupReadParams{SID: "123456789"},
-				"Get-LocalGroup -SID 123456789 | ConvertTo-Json -Compress",
+				"Get-LocalGroup -SID '123456789' | ConvertTo-Json -Compress",
 			},
 			{
 				"assert users group by name and sid",
 				GroupReadParams{Name: "Users", SID: "123456789"},
-				"Get-LocalGroup -SID 123456789 | ConvertTo-Json -Compress",
+				"Get-LocalGroup -SID '123456789' | ConvertTo-Json -Compress",
 			},
 		}
 
@@ -260,12 +260,12 @@ func (suite *LocalUnitTestSuite) TestGroupUpdatePwshCommand() {
 			{
 				"assert with SID and Desctiption parameter",
 				GroupUpdateParams{SID: "S-12345", Description: "Testing"},
-				"Set-LocalGroup -SID S-12345 -Description 'Testing'",
+				"Set-LocalGroup -SID 'S-12345' -Description 'Testing'",
 			},
 			{
 				"assert with Name, SID and Desctiption parameter",
 				GroupUpdateParams{Name: "Test", SID: "S-12345", Description: "Testing"},
-				"Set-LocalGroup -SID S-12345 -Description 'Testing'",
+				"Set-LocalGroup -SID 'S-12345' -Description 'Testing'",
 			},
 			{
 				"assert with Name parameter",
@@ -348,12 +348,12 @@ func (suite *LocalUnitTestSuite) TestGroupDeletePwshCommand() {
 			{
 				"assert with SID parameter",
 				GroupDeleteParams{SID: "S-12345"},
-				"Remove-LocalGroup -SID S-12345",
+				"Remove-LocalGroup -SID 'S-12345'",
 			},
 			{
 				"assert with Name and SID parameter",
 				GroupDeleteParams{Name: "Test", SID: "S-12345"},
-				"Remove-LocalGroup -SID S-12345",
+				"Remove-LocalGroup -SID 'S-12345'",
 			},
 		}
 
@@ -3,9 +3,9 @@ package accounts
 import (
 	"context"
 	"errors"
-	"fmt"
-	"strings"
 
+	"github.com/d-strobel/gowindows/internal/pwsh"
+	"github.com/d-strobel/gowindows/parsing"
 	"github.com/d-strobel/gowindows/winerror"
 )
 
@@ -31,19 +31,16 @@ type GroupMemberReadParams struct {
 // pwshCommad returns a PowerShell command for reading a local group member.
 func (params GroupMemberReadParams) pwshCommand() string {
 	// Base command
-	cmd := []string{"Get-LocalGroupMember"}
+	cmd := parsing.NewPwshCommand("Get-LocalGroupMember")
 
-	// Add parameters
 	// Prefer SID over Name
 	if params.SID != "" {
-		cmd = append(cmd, fmt.Sprintf("-SID %s", params.SID))
+		cmd.Param("SID", params.SID)
 	} else if params.Name != "" {
-		cmd = append(cmd, fmt.Sprintf("-Name '%s'", params.Name))
+		cmd.Param("Name", params.Name)
 	}
 
-	cmd = append(cmd, fmt.Sprintf("-Member '%s'", params.Member))
-	cmd = append(cmd, "| ConvertTo-Json -Compress")
-	return strings.Join(cmd, " ")
+	return cmd.Param("Member", params.Member).ToJson().String()
 }
 
 // GroupMemberRead retrieves information about a specific member in a local Windows group.
@@ -62,8 +59,8 @@ func (c *Client) GroupMemberRead(ctx context.Context, params GroupMemberReadPara
 
 	// Run command
 	cmd := params.pwshCommand()
-	if err := run(ctx, c, cmd, &gm); err != nil {
-		return gm, winerror.Errorf(cmd, "windows.local.accounts.GroupMemberRead: %s", err)
+	if err := run(ctx, c, "windows.local.accounts.GroupMemberRead", cmd, &gm); err != nil {
+		return gm, winerror.Errorf(cmd, "windows.local.accounts.GroupMemberRead: %w", err)
 	}
 
 	return gm, nil
@@ -81,19 +78,20 @@ type GroupMemberListParams struct {
 // pwshCommand returns a PowerShell command for listing members of a local group.
 func (params GroupMemberListParams) pwshCommand() string {
 	// Base command
-	cmd := []string{"$gm=Get-LocalGroupMember"}
+	cmd := parsing.NewPwshCommand("Get-LocalGroupMember")
 
-	// Add parameters
 	// Prefer SID over Name
 	if params.SID != "" {
-		cmd = append(cmd, fmt.Sprintf("-SID %s", params.SID))
+		cmd.Param("SID", params.SID)
 	} else if params.Name != "" {
-		cmd = append(cmd, fmt.Sprintf("-Name '%s'", params.Name))
+		cmd.Param("Name", params.Name)
 	}
 
-	// Ensure that groups with a single group member is also printed as an array
-	cmd = append(cmd, ";if($gm.Count -eq 1){ConvertTo-Json @($gm) -Compress}else{ConvertTo-Json $gm -Compress}")
-	return strings.Join(cmd, " ")
+	// Ensure that groups with a single group member is also printed as an array.
+	// An empty group must be printed as null instead of an array with a null element.
+	return cmd.Assign("gm").
+		Then(parsing.NewPwshCommand("if($gm.Count -eq 1){ConvertTo-Json @($gm) -Compress}else{ConvertTo-Json $gm -Compress}")).
+		String()
 }
 
 // GroupMemberList returns a list of members for a specific local Windows group.
@@ -108,8 +106,8 @@ func (c *Client) GroupMemberList(ctx context.Context, params GroupMemberListPara
 
 	// Run command
 	cmd := params.pwshCommand()
-	if err := run(ctx, c, cmd, &gm); err != nil {
-		return gm, winerror.Errorf(cmd, "windows.local.accounts.GroupMemberList: %s", err)
+	if err := run(ctx, c, "windows.local.accounts.GroupMemberList", cmd, &gm); err != nil {
+		return gm, winerror.Errorf(cmd, "windows.local.accounts.GroupMemberList: %w", err)
 	}
 
 	return gm, nil
@@ -130,18 +128,16 @@ type GroupMemberCreateParams struct {
 // pwshCommand returns a PowerShell command for adding a new member to a local group.
 func (params GroupMemberCreateParams) pwshCommand() string {
 	// Base command
-	cmd := []string{"Add-LocalGroupMember"}
+	cmd := parsing.NewPwshCommand("Add-LocalGroupMember")
 
-	// Add parameters
 	// Prefer SID over Name
 	if params.SID != "" {
-		cmd = append(cmd, fmt.Sprintf("-SID %s", params.SID))
+		cmd.Param("SID", params.SID)
 	} else if params.Name != "" {
-		cmd = append(cmd, fmt.Sprintf("-Name '%s'", params.Name))
+		cmd.Param("Name", params.Name)
 	}
 
-	cmd = append(cmd, fmt.Sprintf("-Member '%s'", params.Member))
-	return strings.Join(cmd, " ")
+	return cmd.Param("Member", params.Member).String()
 }
 
 // GroupMemberCreate adds a new member to a local Windows group.
@@ -158,10 +154,20 @@ func (c *Client) GroupMemberCreate(ctx context.Context, params GroupMemberCreate
 		return errors.New("windows.local.accounts.GroupMemberCreate: group member parameter 'Member' must be set")
 	}
 
-	// Run command
 	cmd := params.pwshCommand()
-	if err := run(ctx, c, cmd, &gm); err != nil {
-		return winerror.Errorf(cmd, "windows.local.accounts.GroupMemberCreate: %s", err)
+
+	// Plan the change in dry-run mode
+	if c.DryRun {
+		current, err := c.GroupMemberRead(ctx, GroupMemberReadParams{Name: params.Name, SID: params.SID, Member: params.Member})
+		return pwsh.PlanCreate("windows.local.accounts.GroupMemberCreate", cmd, current, err, func(m GroupMember) GroupMember {
+			m.Name = params.Member
+			return m
+		})
+	}
+
+	// Run command
+	if err := run(ctx, c, "windows.local.accounts.GroupMemberCreate", cmd, &gm); err != nil {
+		return winerror.Errorf(cmd, "windows.local.accounts.GroupMemberCreate: %w", err)
 	}
 
 	return nil
@@ -182,18 +188,16 @@ type GroupMemberDeleteParams struct {
 // pwshCommand returns a PowerShell command for removing a member from a local group.
 func (params GroupMemberDeleteParams) pwshCommand() string {
 	// Base command
-	cmd := []string{"Remove-LocalGroupMember"}
+	cmd := parsing.NewPwshCommand("Remove-LocalGroupMember")
 
-	// Add parameters
 	// Prefer SID over Name
 	if params.SID != "" {
-		cmd = append(cmd, fmt.Sprintf("-SID %s", params.SID))
+		cmd.Param("SID", params.SID)
 	} else if params.Name != "" {
-		cmd = append(cmd, fmt.Sprintf("-Name '%s'", params.Name))
+		cmd.Param("Name", params.Name)
 	}
-	cmd = append(cmd, fmt.Sprintf("-Member '%s'", params.Member))
 
-	return strings.Join(cmd, " ")
+	return cmd.Param("Member", params.Member).String()
 }
 
 // GroupMemberDelete removes a member from a local Windows group.
@@ -210,10 +214,19 @@ func (c *Client) GroupMemberDelete(ctx context.Context, params GroupMemberDelete
 		return errors.New("windows.local.accounts.GroupMemberDelete: group member parameter 'Member' must be set")
 	}
 
-	// Run command
 	cmd := params.pwshCommand()
-	if err := run(ctx, c, cmd, &gm); err != nil {
-		return winerror.Errorf(cmd, "windows.local.accounts.GroupMemberDelete: %s", err)
+
+	// Plan the change in dry-run mode
+	if c.DryRun {
+		current, err := c.GroupMemberRead(ctx, GroupMemberReadParams{Name: params.Name, SID: params.SID, Member: params.Member})
+		return pwsh.Plan("windows.local.accounts.GroupMemberDelete", cmd, current, err, func(GroupMember) GroupMember {
+			return GroupMember{}
+		})
+	}
+
+	// Run command
+	if err := run(ctx, c, "windows.local.accounts.GroupMemberDelete", cmd, &gm); err != nil {
+		return winerror.Errorf(cmd, "windows.local.accounts.GroupMemberDelete: %w", err)
 	}
 
 	return nil
@@ -57,12 +57,12 @@ func (suite *LocalUnitTestSuite) TestGroupMemberReadPwshCommand() {
 			{
 				"assert users by sid",
 				GroupMemberReadParams{SID: "123456789", Member: "Test"},
-				"Get-LocalGroupMember -SID 123456789 -Member 'Test' | ConvertTo-Json -Compress",
+				"Get-LocalGroupMember -SID '123456789' -Member 'Test' | ConvertTo-Json -Compress",
 			},
 			{
 				"assert users by name and sid",
 				GroupMemberReadParams{Name: "Users", SID: "123456789", Member: "Test"},
-				"Get-LocalGroupMember -SID 123456789 -Member 'Test' | ConvertTo-Json -Compress",
+				"Get-LocalGroupMember -SID '123456789' -Member 'Test' | ConvertTo-Json -Compress",
 			},
 		}
 
@@ -156,12 +156,12 @@ func (suite *LocalUnitTestSuite) TestGroupMemberListPwshCommand() {
 			{
 				"assert group member list by SID",
 				GroupMemberListParams{SID: "123456789"},
-				"$gm=Get-LocalGroupMember -SID 123456789 ;if($gm.Count -eq 1){ConvertTo-Json @($gm) -Compress}else{ConvertTo-Json $gm -Compress}",
+				"$gm=Get-LocalGroupMember -SID '123456789' ;if($gm.Count -eq 1){ConvertTo-Json @($gm) -Compress}else{ConvertTo-Json $gm -Compress}",
 			},
 			{
 				"assert group member list by SID and Name",
 				GroupMemberListParams{Name: "Users", SID: "123456789"},
-				"$gm=Get-LocalGroupMember -SID 123456789 ;if($gm.Count -eq 1){ConvertTo-Json @($gm) -Compress}else{ConvertTo-Json $gm -Compress}",
+				"$gm=Get-LocalGroupMember -SID '123456789' ;if($gm.Count -eq 1){ConvertTo-Json @($gm) -Compress}else{ConvertTo-Json $gm -Compress}",
 			},
 		}
 
@@ -222,7 +222,7 @@ func (suite *LocalUnitTestSuite) TestGroupMemberCreatePwshCommand() {
 			{
 				"assert user with Name + SID + Member",
 				GroupMemberCreateParams{Name: "Administrators", SID: "123456", Member: "TestUser"},
-				"Add-LocalGroupMember -SID 123456 -Member 'TestUser'",
+				"Add-LocalGroupMember -SID '123456' -Member 'TestUser'",
 			},
 		}
 
@@ -284,7 +284,7 @@ func (suite *LocalUnitTestSuite) TestGroupMemberDeletePwshCommand() {
 			{
 				"assert user with Name + SID + Member",
 				GroupMemberDeleteParams{Name: "Administrators", SID: "123456", Member: "TestUser"},
-				"Remove-LocalGroupMember -SID 123456 -Member 'TestUser'",
+				"Remove-LocalGroupMember -SID '123456' -Member 'TestUser'",
 			},
 		}
 
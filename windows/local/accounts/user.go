@@ -3,10 +3,11 @@ package accounts
 import (
 	"context"
 	"errors"
-	"fmt"
+	"io"
 	"strings"
 	"time"
 
+	"github.com/d-strobel/gowindows/internal/pwsh"
 	"github.com/d-strobel/gowindows/parsing"
 	"github.com/d-strobel/gowindows/winerror"
 )
@@ -39,18 +40,16 @@ type UserReadParams struct {
 // pwshCommand returns a PowerShell command for retrieving a local user.
 func (params UserReadParams) pwshCommand() string {
 	// Base command
-	cmd := []string{"Get-LocalUser"}
+	cmd := parsing.NewPwshCommand("Get-LocalUser")
 
-	// Add parameters
 	// Prefer SID over Name
 	if params.SID != "" {
-		cmd = append(cmd, fmt.Sprintf("-SID %s", params.SID))
+		cmd.Param("SID", params.SID)
 	} else if params.Name != "" {
-		cmd = append(cmd, fmt.Sprintf("-Name '%s'", params.Name))
+		cmd.Param("Name", params.Name)
 	}
 
-	cmd = append(cmd, "| ConvertTo-Json -Compress")
-	return strings.Join(cmd, " ")
+	return cmd.ToJson().String()
 }
 
 // UserRead gets a local user by SID or Name and returns a User object.
@@ -70,8 +69,8 @@ func (c *Client) UserRead(ctx context.Context, params UserReadParams) (User, err
 
 	// Run command
 	cmd := params.pwshCommand()
-	if err := run(ctx, c, cmd, &u); err != nil {
-		return u, winerror.Errorf(cmd, "windows.local.accounts.UserRead: %s", err)
+	if err := run(ctx, c, "windows.local.accounts.UserRead", cmd, &u); err != nil {
+		return u, winerror.Errorf(cmd, "windows.local.accounts.UserRead: %w", err)
 	}
 
 	return u, nil
@@ -86,8 +85,8 @@ func (c *Client) UserList(ctx context.Context) ([]User, error) {
 	cmd := "Get-LocalUser | ConvertTo-Json -Compress"
 
 	// Run command
-	if err := run(ctx, c, cmd, &u); err != nil {
-		return u, winerror.Errorf(cmd, "windows.local.accounts.UserList: %s", err)
+	if err := run(ctx, c, "windows.local.accounts.UserList", cmd, &u); err != nil {
+		return u, winerror.Errorf(cmd, "windows.local.accounts.UserList: %w", err)
 	}
 
 	return u, nil
@@ -126,47 +125,51 @@ type UserCreateParams struct {
 // pwshCommand returns a PowerShell command for creating a local user.
 func (params UserCreateParams) pwshCommand() string {
 	// Base command
-	cmd := []string{"New-LocalUser"}
-
-	// Add parameters
-	cmd = append(cmd, fmt.Sprintf("-Name '%s'", params.Name))
+	cmd := parsing.NewPwshCommand("New-LocalUser").Param("Name", params.Name)
 
+	// Add optional parameters
 	if params.Description != "" {
-		cmd = append(cmd, fmt.Sprintf("-Description '%s'", params.Description))
+		cmd.Param("Description", params.Description)
 	}
 
 	if params.AccountExpires.Compare(time.Now()) == 1 {
-		accountExpires := params.AccountExpires.Format(time.DateTime)
-		cmd = append(cmd, fmt.Sprintf("-AccountExpires $(Get-Date '%s')", accountExpires))
+		cmd.Param("AccountExpires", params.AccountExpires)
 	} else {
-		cmd = append(cmd, "-AccountNeverExpires")
+		cmd.Switch("AccountNeverExpires")
 	}
 
 	if params.Enabled {
-		cmd = append(cmd, "-Disabled:$false")
+		cmd.Param("Disabled", false)
 	} else {
-		cmd = append(cmd, "-Disabled")
+		cmd.Switch("Disabled")
 	}
 
 	if params.FullName != "" {
-		cmd = append(cmd, fmt.Sprintf("-FullName '%s'", params.FullName))
+		cmd.Param("FullName", params.FullName)
 	}
 
 	if params.Password != "" {
-		cmd = append(cmd, fmt.Sprintf("-Password $(ConvertTo-SecureString -String '%s' -AsPlainText -Force)", params.Password))
-		cmd = append(cmd, fmt.Sprintf("-PasswordNeverExpires:$%t", params.PasswordNeverExpires))
+		cmd.Param("Password", pwshSecureString)
+		cmd.Param("PasswordNeverExpires", params.PasswordNeverExpires)
 	} else {
-		cmd = append(cmd, "-NoPassword")
+		cmd.Switch("NoPassword")
 	}
 
 	if params.UserMayChangePassword {
-		cmd = append(cmd, "-UserMayNotChangePassword:$false")
+		cmd.Param("UserMayNotChangePassword", false)
 	} else {
-		cmd = append(cmd, "-UserMayNotChangePassword")
+		cmd.Switch("UserMayNotChangePassword")
 	}
 
-	cmd = append(cmd, "| ConvertTo-Json -Compress")
-	return strings.Join(cmd, " ")
+	return cmd.ToJson().String()
+}
+
+// accountExpires returns the expiration date of an account, that does not expire if the date is not in the future.
+func accountExpires(t time.Time) parsing.DotnetTime {
+	if t.Compare(time.Now()) == 1 {
+		return parsing.DotnetTime{Time: t}
+	}
+	return parsing.DotnetTime{}
 }
 
 // UserCreate creates a local user and returns a User object.
@@ -179,10 +182,29 @@ func (c *Client) UserCreate(ctx context.Context, params UserCreateParams) (User,
 		return u, errors.New("windows.local.accounts.UserCreate: user parameter 'Name' must be set")
 	}
 
-	// Run command
 	cmd := params.pwshCommand()
-	if err := run(ctx, c, cmd, &u); err != nil {
-		return u, winerror.Errorf(cmd, "windows.local.accounts.UserCreate: %s", err)
+
+	// Plan the change in dry-run mode
+	if c.DryRun {
+		current, err := c.UserRead(ctx, UserReadParams{Name: params.Name})
+		return u, pwsh.PlanCreate("windows.local.accounts.UserCreate", cmd, current, err, func(u User) User {
+			u.Name = params.Name
+			if params.Description != "" {
+				u.Description = params.Description
+			}
+			u.AccountExpires = accountExpires(params.AccountExpires)
+			u.Enabled = params.Enabled
+			if params.FullName != "" {
+				u.FullName = params.FullName
+			}
+			u.UserMayChangePassword = params.UserMayChangePassword
+			return u
+		})
+	}
+
+	// Run command
+	if err := runWithInput(ctx, c, "windows.local.accounts.UserCreate", cmd, passwordInput(params.Password), &u); err != nil {
+		return u, winerror.Errorf(cmd, "windows.local.accounts.UserCreate: %w", err)
 	}
 
 	return u, nil
@@ -224,46 +246,40 @@ type UserUpdateParams struct {
 // pwshCommand returns a PowerShell command for updating a local user.
 func (params UserUpdateParams) pwshCommand() string {
 	// Base commands
-	cmd1 := []string{"Set-LocalUser"}
-	cmd2 := []string{}
+	setCmd := parsing.NewPwshCommand("Set-LocalUser")
+	enableCmd := parsing.NewPwshCommand("Disable-LocalUser")
 
 	if params.Enabled {
-		cmd2 = append(cmd2, "Enable-LocalUser")
-	} else {
-		cmd2 = append(cmd2, "Disable-LocalUser")
+		enableCmd = parsing.NewPwshCommand("Enable-LocalUser")
 	}
 
-	// Add parameters
 	// Prefer SID over Name to identify group
 	if params.SID != "" {
-		cmd1 = append(cmd1, fmt.Sprintf("-SID %s", params.SID))
-		cmd2 = append(cmd2, fmt.Sprintf("-SID %s", params.SID))
+		setCmd.Param("SID", params.SID)
+		enableCmd.Param("SID", params.SID)
 	} else if params.Name != "" {
-		cmd1 = append(cmd1, fmt.Sprintf("-Name '%s'", params.Name))
-		cmd2 = append(cmd2, fmt.Sprintf("-Name '%s'", params.Name))
+		setCmd.Param("Name", params.Name)
+		enableCmd.Param("Name", params.Name)
 	}
 
 	if params.AccountExpires.Compare(time.Now()) == 1 {
-		accountExpires := params.AccountExpires.Format(time.DateTime)
-		cmd1 = append(cmd1, fmt.Sprintf("-AccountExpires $(Get-Date '%s')", accountExpires))
+		setCmd.Param("AccountExpires", params.AccountExpires)
 	} else {
-		cmd1 = append(cmd1, "-AccountNeverExpires")
+		setCmd.Switch("AccountNeverExpires")
 	}
 
 	// Always set Description and FullName to allow removal of these parameters
-	cmd1 = append(cmd1, fmt.Sprintf("-Description '%s'", params.Description))
-	cmd1 = append(cmd1, fmt.Sprintf("-FullName '%s'", params.FullName))
+	setCmd.Param("Description", params.Description)
+	setCmd.Param("FullName", params.FullName)
 
 	if params.Password != "" {
-		cmd1 = append(cmd1, fmt.Sprintf("-Password $(ConvertTo-SecureString -String '%s' -AsPlainText -Force)", params.Password))
+		setCmd.Param("Password", pwshSecureString)
 	}
 
-	cmd1 = append(cmd1, fmt.Sprintf("-PasswordNeverExpires:$%t", params.PasswordNeverExpires))
-	cmd1 = append(cmd1, fmt.Sprintf("-UserMayChangePassword:$%t", params.UserMayChangePassword))
+	setCmd.Param("PasswordNeverExpires", params.PasswordNeverExpires)
+	setCmd.Param("UserMayChangePassword", params.UserMayChangePassword)
 
-	// Append second command with a semicolon
-	cmd1 = append(cmd1, fmt.Sprintf(";%s", strings.Join(cmd2, " ")))
-	return strings.Join(cmd1, " ")
+	return setCmd.Then(enableCmd).String()
 }
 
 // UserUpdate updates a local user.
@@ -276,10 +292,27 @@ func (c *Client) UserUpdate(ctx context.Context, params UserUpdateParams) error
 		return errors.New("windows.local.accounts.UserUpdate: user parameter 'Name' or 'SID' must be set")
 	}
 
-	// Run command
 	cmd := params.pwshCommand()
-	if err := run(ctx, c, cmd, &u); err != nil {
-		return winerror.Errorf(cmd, "windows.local.accounts.UserUpdate: %s", err)
+
+	// Plan the change in dry-run mode
+	if c.DryRun {
+		current, err := c.UserRead(ctx, UserReadParams{Name: params.Name, SID: params.SID})
+		return pwsh.Plan("windows.local.accounts.UserUpdate", cmd, current, err, func(u User) User {
+			u.AccountExpires = accountExpires(params.AccountExpires)
+			u.Description = params.Description
+			u.FullName = params.FullName
+			u.Enabled = params.Enabled
+			if params.PasswordNeverExpires {
+				u.PasswordExpires = parsing.DotnetTime{}
+			}
+			u.UserMayChangePassword = params.UserMayChangePassword
+			return u
+		})
+	}
+
+	// Run command
+	if err := runWithInput(ctx, c, "windows.local.accounts.UserUpdate", cmd, passwordInput(params.Password), &u); err != nil {
+		return winerror.Errorf(cmd, "windows.local.accounts.UserUpdate: %w", err)
 	}
 
 	return nil
@@ -297,17 +330,29 @@ type UserDeleteParams struct {
 // pwshCommand returns a PowerShell command for deleting a local user.
 func (params UserDeleteParams) pwshCommand() string {
 	// Base command
-	cmd := []string{"Remove-LocalUser"}
+	cmd := parsing.NewPwshCommand("Remove-LocalUser")
 
-	// Add parameters
 	// Prefer SID over Name to identifiy group
 	if params.SID != "" {
-		cmd = append(cmd, fmt.Sprintf("-SID %s", params.SID))
+		cmd.Param("SID", params.SID)
 	} else if params.Name != "" {
-		cmd = append(cmd, fmt.Sprintf("-Name '%s'", params.Name))
+		cmd.Param("Name", params.Name)
 	}
 
-	return strings.Join(cmd, " ")
+	return cmd.String()
+}
+
+// pwshSecureString is a PowerShell subexpression that reads the password from the standard input
+// and converts it to a SecureString, so the password is not part of the command.
+const pwshSecureString parsing.PwshRaw = "$($input | Select-Object -First 1 | ConvertTo-SecureString -AsPlainText -Force)"
+
+// passwordInput returns the standard input of a command that reads the password with pwshSecureString.
+// It returns nil if the password is empty.
+func passwordInput(password string) io.Reader {
+	if password == "" {
+		return nil
+	}
+	return strings.NewReader(password + "\n")
 }
 
 // UserDelete removes a local user by SID or Name.
@@ -320,10 +365,19 @@ func (c *Client) UserDelete(ctx context.Context, params UserDeleteParams) error
 		return errors.New("windows.local.accounts.UserDelete: user parameter 'Name' or 'SID' must be set")
 	}
 
-	// Run command
 	cmd := params.pwshCommand()
-	if err := run(ctx, c, cmd, &u); err != nil {
-		return winerror.Errorf(cmd, "windows.local.accounts.UserDelete: %s", err)
+
+	// Plan the change in dry-run mode
+	if c.DryRun {
+		current, err := c.UserRead(ctx, UserReadParams{Name: params.Name, SID: params.SID})
+		return pwsh.Plan("windows.local.accounts.UserDelete", cmd, current, err, func(User) User {
+			return User{}
+		})
+	}
+
+	// Run command
+	if err := run(ctx, c, "windows.local.accounts.UserDelete", cmd, &u); err != nil {
+		return winerror.Errorf(cmd, "windows.local.accounts.UserDelete: %w", err)
 	}
 
 	return nil
@@ -93,7 +93,7 @@ func (suite *LocalAccTestSuite) TestUser3Create() {
 			FullName:             fmt.Sprintf("Full-Test-User-%d", i),
 			Password:             "Start123!!!",
 			PasswordNeverExpires: true,
-			AccountExpires:       time.Date(2025, time.November, 10, 16, 0, 0, 0, time.UTC),
+			AccountExpires:       time.Date(3025, time.November, 10, 16, 0, 0, 0, time.UTC),
 			Enabled:              true,
 		}
 		g, err := c.UserCreate(ctx, params)
@@ -102,7 +102,7 @@ func (suite *LocalAccTestSuite) TestUser3Create() {
 		suite.Equal(accounts.User{Description: "This is a test user"}.Description, g.Description)
 		suite.Equal(accounts.User{FullName: fmt.Sprintf("Full-Test-User-%d", i)}.FullName, g.FullName)
 		suite.Equal(accounts.User{PasswordExpires: parsing.DotnetTime{}}.PasswordExpires, g.PasswordExpires)
-		suite.Equal(accounts.User{AccountExpires: parsing.DotnetTime{Time: time.Date(2025, time.November, 10, 16, 0, 0, 0, time.UTC)}}.AccountExpires, g.AccountExpires)
+		suite.Equal(accounts.User{AccountExpires: parsing.DotnetTime{Time: time.Date(3025, time.November, 10, 16, 0, 0, 0, time.UTC)}}.AccountExpires, g.AccountExpires)
 		suite.Equal(accounts.User{UserMayChangePassword: false}.UserMayChangePassword, g.UserMayChangePassword)
 		suite.Equal(accounts.User{Enabled: true}.Enabled, g.Enabled)
 	}
@@ -118,7 +118,7 @@ func (suite *LocalAccTestSuite) TestUser4Update() {
 			Description:    "Updated - This is a test user",
 			FullName:       fmt.Sprintf("Updated-Full-Test-User-%d", i),
 			Password:       "Start123!!!3",
-			AccountExpires: time.Date(2026, time.November, 10, 16, 0, 0, 0, time.UTC),
+			AccountExpires: time.Date(3026, time.November, 10, 16, 0, 0, 0, time.UTC),
 			Enabled:        false,
 		}
 		err := c.UserUpdate(ctx, params)
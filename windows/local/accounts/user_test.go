@@ -3,19 +3,24 @@ package accounts
 import (
 	"context"
 	"errors"
+	"io"
 	"time"
 
 	"github.com/d-strobel/gowindows/connection"
 	"github.com/d-strobel/gowindows/parsing"
+	"github.com/d-strobel/gowindows/winerror"
+	"github.com/stretchr/testify/mock"
 
 	mockConnection "github.com/d-strobel/gowindows/connection/mocks"
 )
 
 // Fixtures
 const (
-	adminUser = `{"AccountExpires":null,"Description":"Built-in account for administering the computer/domain","Enabled":true,"FullName":"","PasswordChangeableDate":"\/Date(1701379505092)\/","PasswordExpires":null,"UserMayChangePassword":true,"PasswordRequired":true,"PasswordLastSet":"\/Date(1701379505092)\/","LastLogon":null,"Name":"Administrator","SID":{"BinaryLength":28,"AccountDomainSid":{"BinaryLength":24,"AccountDomainSid":"S-1-5-21-153895498-367353507-3704405138","Value":"S-1-5-21-153895498-367353507-3704405138"},"Value":"S-1-5-21-153895498-367353507-3704405138-500"},"PrincipalSource":1,"ObjectClass":"User"}`
-	userList  = `[{"AccountExpires":null,"Description":"Built-in account for administering the computer/domain","Enabled":true,"FullName":"","PasswordChangeableDate":"\/Date(1701379505092)\/","PasswordExpires":null,"UserMayChangePassword":true,"PasswordRequired":true,"PasswordLastSet":"\/Date(1701379505092)\/","LastLogon":null,"Name":"Administrator","SID":{"BinaryLength":28,"AccountDomainSid":"S-1-5-21-153895498-367353507-3704405138","Value":"S-1-5-21-153895498-367353507-3704405138-500"},"PrincipalSource":1,"ObjectClass":"User"},{"AccountExpires":null,"Description":"Built-in account for guest access to the computer/domain","Enabled":false,"FullName":"","PasswordChangeableDate":null,"PasswordExpires":null,"UserMayChangePassword":false,"PasswordRequired":false,"PasswordLastSet":null,"LastLogon":null,"Name":"Guest","SID":{"BinaryLength":28,"AccountDomainSid":"S-1-5-21-153895498-367353507-3704405138","Value":"S-1-5-21-153895498-367353507-3704405138-501"},"PrincipalSource":1,"ObjectClass":"User"}]`
-	testUser  = `{"AccountExpires":"\/Date(1762790400000)\/","Description":"This is a test user","Enabled":true,"FullName":"Full-Test-User","PasswordChangeableDate":null,"PasswordExpires":null,"UserMayChangePassword":true,"PasswordRequired":false,"PasswordLastSet":null,"LastLogon":null,"Name":"Test-User","SID":{"BinaryLength":28,"AccountDomainSid":{"BinaryLength":24,"AccountDomainSid":"S-1-5-21-153895498-367353507-3704405138","Value":"S-1-5-21-153895498-367353507-3704405138"},"Value":"S-1-5-21-153895498-367353507-3704405138-1016"},"PrincipalSource":1,"ObjectClass":"User"}`
+	adminUser       = `{"AccountExpires":null,"Description":"Built-in account for administering the computer/domain","Enabled":true,"FullName":"","PasswordChangeableDate":"\/Date(1701379505092)\/","PasswordExpires":null,"UserMayChangePassword":true,"PasswordRequired":true,"PasswordLastSet":"\/Date(1701379505092)\/","LastLogon":null,"Name":"Administrator","SID":{"BinaryLength":28,"AccountDomainSid":{"BinaryLength":24,"AccountDomainSid":"S-1-5-21-153895498-367353507-3704405138","Value":"S-1-5-21-153895498-367353507-3704405138"},"Value":"S-1-5-21-153895498-367353507-3704405138-500"},"PrincipalSource":1,"ObjectClass":"User"}`
+	userList        = `[{"AccountExpires":null,"Description":"Built-in account for administering the computer/domain","Enabled":true,"FullName":"","PasswordChangeableDate":"\/Date(1701379505092)\/","PasswordExpires":null,"UserMayChangePassword":true,"PasswordRequired":true,"PasswordLastSet":"\/Date(1701379505092)\/","LastLogon":null,"Name":"Administrator","SID":{"BinaryLength":28,"AccountDomainSid":"S-1-5-21-153895498-367353507-3704405138","Value":"S-1-5-21-153895498-367353507-3704405138-500"},"PrincipalSource":1,"ObjectClass":"User"},{"AccountExpires":null,"Description":"Built-in account for guest access to the computer/domain","Enabled":false,"FullName":"","PasswordChangeableDate":null,"PasswordExpires":null,"UserMayChangePassword":false,"PasswordRequired":false,"PasswordLastSet":null,"LastLogon":null,"Name":"Guest","SID":{"BinaryLength":28,"AccountDomainSid":"S-1-5-21-153895498-367353507-3704405138","Value":"S-1-5-21-153895498-367353507-3704405138-501"},"PrincipalSource":1,"ObjectClass":"User"}]`
+	testUser        = `{"AccountExpires":"\/Date(1762790400000)\/","Description":"This is a test user","Enabled":true,"FullName":"Full-Test-User","PasswordChangeableDate":null,"PasswordExpires":null,"UserMayChangePassword":true,"PasswordRequired":false,"PasswordLastSet":null,"LastLogon":null,"Name":"Test-User","SID":{"BinaryLength":28,"AccountDomainSid":{"BinaryLength":24,"AccountDomainSid":"S-1-5-21-153895498-367353507-3704405138","Value":"S-1-5-21-153895498-367353507-3704405138"},"Value":"S-1-5-21-153895498-367353507-3704405138-1016"},"PrincipalSource":1,"ObjectClass":"User"}`
+	userNotFoundErr = `#< CLIXML
+<Objs Version="1.1.0.1" xmlns="http://schemas.microsoft.com/powershell/2004/04"><S S="Error">Get-LocalUser : User Test-User was not found._x000D__x000A_</S><S S="Error">At line:1 char:1_x000D__x000A_</S><S S="Error">+ Get-LocalUser -Name 'Test-User' | ConvertTo-Json -Compress_x000D__x000A_</S><S S="Error">+ ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~_x000D__x000A_</S><S S="Error">    + CategoryInfo          : ObjectNotFound: (Test-User:String) [Get-LocalUser], UserNotFoundException_x000D__x000A_</S><S S="Error">    + FullyQualifiedErrorId : UserNotFound,Microsoft.PowerShell.Commands.GetLocalUserCommand_x000D__x000A_</S><S S="Error"> _x000D__x000A_</S></Objs>`
 )
 
 var (
@@ -103,12 +108,12 @@ func (suite *LocalUnitTestSuite) TestUserReadPwshCommand() {
 			{
 				"assert users by sid",
 				UserReadParams{SID: "123456789"},
-				"Get-LocalUser -SID 123456789 | ConvertTo-Json -Compress",
+				"Get-LocalUser -SID '123456789' | ConvertTo-Json -Compress",
 			},
 			{
 				"assert users by name and sid",
 				UserReadParams{Name: "Users", SID: "123456789"},
-				"Get-LocalUser -SID 123456789 | ConvertTo-Json -Compress",
+				"Get-LocalUser -SID '123456789' | ConvertTo-Json -Compress",
 			},
 		}
 
@@ -182,6 +187,24 @@ func (suite *LocalUnitTestSuite) TestUserRead() {
 			Return(connection.CmdResult{}, errors.New("test-error"))
 		_, err := c.UserRead(ctx, UserReadParams{Name: "Administrator"})
 		suite.EqualError(err, "windows.local.accounts.UserRead: test-error")
+		suite.ErrorIs(err, winerror.ErrTransport)
+	})
+
+	suite.Run("should return not found error if the user does not exist", func() {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		mockConn := mockConnection.NewMockConnection(suite.T())
+		c := &Client{
+			Connection:      mockConn,
+			decodeCliXmlErr: parsing.DecodeCliXmlErr,
+		}
+		mockConn.EXPECT().
+			RunWithPowershell(ctx, "Get-LocalUser -Name 'Test-User' | ConvertTo-Json -Compress").
+			Return(connection.CmdResult{StdErr: userNotFoundErr}, nil)
+		_, err := c.UserRead(ctx, UserReadParams{Name: "Test-User"})
+		suite.ErrorContains(err, "windows.local.accounts.UserRead: Get-LocalUser : User Test-User was not found.")
+		suite.ErrorIs(err, winerror.ErrNotFound)
+		suite.NotErrorIs(err, winerror.ErrTransport)
 	})
 }
 
@@ -242,6 +265,11 @@ func (suite *LocalUnitTestSuite) TestUserCreatePwshCommand() {
 				UserCreateParams{Name: "Tester", AccountExpires: time.Date(3024, time.April, 10, 15, 0, 0, 0, time.UTC)},
 				"New-LocalUser -Name 'Tester' -AccountExpires $(Get-Date '3024-04-10 15:00:00') -Disabled -NoPassword -UserMayNotChangePassword | ConvertTo-Json -Compress",
 			},
+			{
+				"assert user with Name + past AccountExpires",
+				UserCreateParams{Name: "Tester", AccountExpires: time.Date(2024, time.April, 10, 15, 0, 0, 0, time.UTC)},
+				"New-LocalUser -Name 'Tester' -AccountNeverExpires -Disabled -NoPassword -UserMayNotChangePassword | ConvertTo-Json -Compress",
+			},
 			{
 				"assert user with Name + Enabled",
 				UserCreateParams{Name: "Tester", Enabled: true},
@@ -255,7 +283,7 @@ func (suite *LocalUnitTestSuite) TestUserCreatePwshCommand() {
 			{
 				"assert user with Name + Password",
 				UserCreateParams{Name: "Tester", Password: "Start123!!!"},
-				"New-LocalUser -Name 'Tester' -AccountNeverExpires -Disabled -Password $(ConvertTo-SecureString -String 'Start123!!!' -AsPlainText -Force) -PasswordNeverExpires:$false -UserMayNotChangePassword | ConvertTo-Json -Compress",
+				"New-LocalUser -Name 'Tester' -AccountNeverExpires -Disabled -Password $($input | Select-Object -First 1 | ConvertTo-SecureString -AsPlainText -Force) -PasswordNeverExpires:$false -UserMayNotChangePassword | ConvertTo-Json -Compress",
 			},
 			{
 				"assert user with Name + PasswordNeverExpires + UserMayNotChangePassword",
@@ -282,7 +310,7 @@ func (suite *LocalUnitTestSuite) TestUserCreate() {
 			decodeCliXmlErr: func(s string) (string, error) { return s, nil },
 		}
 		mockConn.EXPECT().
-			RunWithPowershell(ctx, "New-LocalUser -Name 'Test-User' -Description 'This is a test user' -AccountExpires $(Get-Date '2025-11-10 16:00:00') -Disabled:$false -FullName 'Full-Test-User' -NoPassword -UserMayNotChangePassword:$false | ConvertTo-Json -Compress").
+			RunWithPowershell(ctx, "New-LocalUser -Name 'Test-User' -Description 'This is a test user' -AccountExpires $(Get-Date '3025-11-10 16:00:00') -Disabled:$false -FullName 'Full-Test-User' -NoPassword -UserMayNotChangePassword:$false | ConvertTo-Json -Compress").
 			Return(connection.CmdResult{StdOut: testUser}, nil)
 		actualTestUser, err := c.UserCreate(ctx, UserCreateParams{
 			Name:                  "Test-User",
@@ -290,11 +318,68 @@ func (suite *LocalUnitTestSuite) TestUserCreate() {
 			FullName:              "Full-Test-User",
 			Enabled:               true,
 			UserMayChangePassword: true,
-			AccountExpires:        time.Date(2025, time.November, 10, 16, 0, 0, 0, time.UTC),
+			AccountExpires:        time.Date(3025, time.November, 10, 16, 0, 0, 0, time.UTC),
 		})
 		suite.NoError(err)
 		suite.Equal(expectedTestUser, actualTestUser)
 	})
+	suite.Run("should send the password on stdin", func() {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		mockConn := mockConnection.NewMockConnection(suite.T())
+		c := &Client{
+			Connection:      mockConn,
+			decodeCliXmlErr: func(s string) (string, error) { return s, nil },
+		}
+		mockConn.EXPECT().
+			StreamWithPowershell(ctx, "New-LocalUser -Name 'Test-User' -AccountNeverExpires -Disabled:$false -Password $($input | Select-Object -First 1 | ConvertTo-SecureString -AsPlainText -Force) -PasswordNeverExpires:$false -UserMayNotChangePassword:$false | ConvertTo-Json -Compress", mock.Anything).
+			RunAndReturn(func(ctx context.Context, cmd string, streams connection.Streams) (int, error) {
+				suite.NotContains(cmd, "Start123!!!")
+				stdin, err := io.ReadAll(streams.Stdin)
+				suite.Require().NoError(err)
+				suite.Equal("Start123!!!\n", string(stdin))
+				_, err = io.WriteString(streams.Stdout, testUser)
+				return 0, err
+			})
+		_, err := c.UserCreate(ctx, UserCreateParams{
+			Name:                  "Test-User",
+			Enabled:               true,
+			UserMayChangePassword: true,
+			Password:              "Start123!!!",
+		})
+		suite.NoError(err)
+	})
+	suite.Run("should not contain the password in the error", func() {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		mockConn := mockConnection.NewMockConnection(suite.T())
+		c := &Client{
+			Connection:      mockConn,
+			decodeCliXmlErr: func(s string) (string, error) { return s, nil },
+		}
+		stderr := "New-LocalUser : Unable to update the password. The value provided for the new password does not meet the length, complexity, or history requirements of the domain.\r\n" +
+			"At line:1 char:1\r\n" +
+			"+ ... -Password $([Console]::In.ReadLine() | ConvertTo-SecureString -AsPlainText ...\r\n" +
+			"    + CategoryInfo          : InvalidArgument: (Test-User:LocalUser) [New-LocalUser], InvalidPasswordException\r\n" +
+			"    + FullyQualifiedErrorId : InvalidPassword,Microsoft.PowerShell.Commands.NewLocalUserCommand\r\n"
+		mockConn.EXPECT().
+			StreamWithPowershell(ctx, mock.Anything, mock.Anything).
+			RunAndReturn(func(ctx context.Context, cmd string, streams connection.Streams) (int, error) {
+				_, err := io.WriteString(streams.Stderr, stderr)
+				return 1, err
+			})
+		_, err := c.UserCreate(ctx, UserCreateParams{
+			Name:                  "Test-User",
+			Enabled:               true,
+			UserMayChangePassword: true,
+			Password:              "weak",
+		})
+		suite.ErrorIs(err, winerror.ErrInvalidParameter)
+		suite.NotContains(err.Error(), "weak")
+		suite.NotContains(winerror.UnwrapCommand(err), "weak")
+		suite.Require().Len(winerror.UnwrapRecords(err), 1)
+		suite.NotContains(winerror.UnwrapRecords(err)[0].ScriptPosition.Text, "weak")
+	})
 }
 
 // Test UserUpdate related methods.
@@ -318,13 +403,18 @@ func (suite *LocalUnitTestSuite) TestUserUpdatePwshCommand() {
 			{
 				"assert user with SID + Enabled",
 				UserUpdateParams{SID: "S-1000", Enabled: true},
-				"Set-LocalUser -SID S-1000 -AccountNeverExpires -Description '' -FullName '' -PasswordNeverExpires:$false -UserMayChangePassword:$false ;Enable-LocalUser -SID S-1000",
+				"Set-LocalUser -SID 'S-1000' -AccountNeverExpires -Description '' -FullName '' -PasswordNeverExpires:$false -UserMayChangePassword:$false ;Enable-LocalUser -SID 'S-1000'",
 			},
 			{
 				"assert user with Name + AccountExpires",
 				UserUpdateParams{Name: "Tester", AccountExpires: time.Date(3024, time.April, 10, 15, 0, 0, 0, time.UTC)},
 				"Set-LocalUser -Name 'Tester' -AccountExpires $(Get-Date '3024-04-10 15:00:00') -Description '' -FullName '' -PasswordNeverExpires:$false -UserMayChangePassword:$false ;Disable-LocalUser -Name 'Tester'",
 			},
+			{
+				"assert user with Name + past AccountExpires",
+				UserUpdateParams{Name: "Tester", AccountExpires: time.Date(2024, time.April, 10, 15, 0, 0, 0, time.UTC)},
+				"Set-LocalUser -Name 'Tester' -AccountNeverExpires -Description '' -FullName '' -PasswordNeverExpires:$false -UserMayChangePassword:$false ;Disable-LocalUser -Name 'Tester'",
+			},
 			{
 				"assert user with Name + Description + FullName",
 				UserUpdateParams{Name: "Tester", Description: "test-description", FullName: "Full-Tester"},
@@ -333,7 +423,7 @@ func (suite *LocalUnitTestSuite) TestUserUpdatePwshCommand() {
 			{
 				"assert user with Name + Password + PasswordNeverExpires + UserMayChangePassword",
 				UserUpdateParams{Name: "Tester", Password: "Start123!!!", PasswordNeverExpires: true, UserMayChangePassword: true},
-				"Set-LocalUser -Name 'Tester' -AccountNeverExpires -Description '' -FullName '' -Password $(ConvertTo-SecureString -String 'Start123!!!' -AsPlainText -Force) -PasswordNeverExpires:$true -UserMayChangePassword:$true ;Disable-LocalUser -Name 'Tester'",
+				"Set-LocalUser -Name 'Tester' -AccountNeverExpires -Description '' -FullName '' -Password $($input | Select-Object -First 1 | ConvertTo-SecureString -AsPlainText -Force) -PasswordNeverExpires:$true -UserMayChangePassword:$true ;Disable-LocalUser -Name 'Tester'",
 			},
 		}
 
@@ -390,7 +480,7 @@ func (suite *LocalUnitTestSuite) TestUserDeletePwshCommand() {
 			{
 				"assert user with SID",
 				UserDeleteParams{SID: "S-1000"},
-				"Remove-LocalUser -SID S-1000",
+				"Remove-LocalUser -SID 'S-1000'",
 			},
 		}
 
@@ -0,0 +1,159 @@
+package winerror
+
+import (
+	"errors"
+	"regexp"
+	"strconv"
+
+	"github.com/d-strobel/gowindows/parsing"
+)
+
+// Sentinel errors for the most common failures of the Windows client.
+// A WinError matches a sentinel error with errors.Is if one of its PowerShell error records
+// maps to the sentinel error by its error id, Win32 error code, HRESULT or category.
+var (
+	ErrNotFound         = errors.New("not found")
+	ErrAlreadyExists    = errors.New("already exists")
+	ErrAccessDenied     = errors.New("access denied")
+	ErrInvalidParameter = errors.New("invalid parameter")
+	ErrTransport        = errors.New("transport failure")
+)
+
+// errorIds maps the error ids of the PowerShell cmdlets to sentinel errors.
+var errorIds = map[string]error{
+	// Microsoft.PowerShell.LocalAccounts
+	"UserNotFound":      ErrNotFound,
+	"GroupNotFound":     ErrNotFound,
+	"PrincipalNotFound": ErrNotFound,
+	"MemberNotFound":    ErrNotFound,
+	"UserExists":        ErrAlreadyExists,
+	"GroupExists":       ErrAlreadyExists,
+	"MemberExists":      ErrAlreadyExists,
+	"NameInUse":         ErrAlreadyExists,
+	"AccessDenied":      ErrAccessDenied,
+	"InvalidName":       ErrInvalidParameter,
+	"InvalidPassword":   ErrInvalidParameter,
+
+	// Parameter binding
+	"NamedParameterNotFound":               ErrInvalidParameter,
+	"PositionalParameterNotFound":          ErrInvalidParameter,
+	"MissingArgument":                      ErrInvalidParameter,
+	"AmbiguousParameterSet":                ErrInvalidParameter,
+	"ParameterArgumentValidationError":     ErrInvalidParameter,
+	"ParameterArgumentTransformationError": ErrInvalidParameter,
+}
+
+// win32ErrorCodes maps Win32 error codes to sentinel errors.
+// https://learn.microsoft.com/en-us/windows/win32/debug/system-error-codes
+var win32ErrorCodes = map[int64]error{
+	2:     ErrNotFound,         // ERROR_FILE_NOT_FOUND
+	5:     ErrAccessDenied,     // ERROR_ACCESS_DENIED
+	87:    ErrInvalidParameter, // ERROR_INVALID_PARAMETER
+	183:   ErrAlreadyExists,    // ERROR_ALREADY_EXISTS
+	1317:  ErrNotFound,         // ERROR_NO_SUCH_USER
+	1378:  ErrAlreadyExists,    // ERROR_MEMBER_IN_ALIAS
+	2220:  ErrNotFound,         // NERR_GroupNotFound
+	2221:  ErrNotFound,         // NERR_UserNotFound
+	2223:  ErrAlreadyExists,    // NERR_GroupExists
+	2224:  ErrAlreadyExists,    // NERR_UserExists
+	9601:  ErrNotFound,         // DNS_ERROR_ZONE_DOES_NOT_EXIST
+	9609:  ErrAlreadyExists,    // DNS_ERROR_ZONE_ALREADY_EXISTS
+	9701:  ErrNotFound,         // DNS_ERROR_RECORD_DOES_NOT_EXIST
+	9711:  ErrAlreadyExists,    // DNS_ERROR_RECORD_ALREADY_EXISTS
+	9714:  ErrNotFound,         // DNS_ERROR_NAME_DOES_NOT_EXIST
+	20004: ErrAlreadyExists,    // ERROR_DHCP_SUBNET_EXITS
+	20005: ErrNotFound,         // ERROR_DHCP_SUBNET_NOT_PRESENT
+}
+
+// hresults maps HRESULTs that do not wrap a Win32 error code to sentinel errors.
+// https://learn.microsoft.com/en-us/windows/win32/wmisdk/wmi-error-constants
+var hresults = map[int64]error{
+	0x80041002: ErrNotFound,         // WBEM_E_NOT_FOUND
+	0x80041003: ErrAccessDenied,     // WBEM_E_ACCESS_DENIED
+	0x80041008: ErrInvalidParameter, // WBEM_E_INVALID_PARAMETER
+}
+
+// categories maps the categories of PowerShell error records to sentinel errors.
+var categories = map[string]error{
+	"ObjectNotFound":   ErrNotFound,
+	"ResourceExists":   ErrAlreadyExists,
+	"PermissionDenied": ErrAccessDenied,
+	"InvalidArgument":  ErrInvalidParameter,
+}
+
+var (
+	// errorIdCodeRe matches the error code of an error id, e.g. "WIN32 9711" or "HRESULT 0x80041002".
+	errorIdCodeRe = regexp.MustCompile(`^\w+ (0x[0-9A-Fa-f]+|\d+)$`)
+
+	// messageHresultRe matches the HRESULT of an exception message, e.g. "(Exception from HRESULT: 0x80070005 (E_ACCESSDENIED))".
+	messageHresultRe = regexp.MustCompile(`HRESULT: (0x[0-9A-Fa-f]{8})`)
+)
+
+// errorCode returns the sentinel error of a Win32 error code or an HRESULT.
+func errorCode(code string) error {
+	c, err := strconv.ParseInt(code, 0, 64)
+	if err != nil {
+		return nil
+	}
+
+	// HRESULTs with the Win32 facility wrap a Win32 error code.
+	if c&0xFFFF0000 == 0x80070000 {
+		c &= 0xFFFF
+	}
+
+	if sentinel, ok := win32ErrorCodes[c]; ok {
+		return sentinel
+	}
+	return hresults[c]
+}
+
+// sentinel returns the sentinel error of a PowerShell error record or nil if the record is unknown.
+func sentinel(r parsing.ErrorRecord) error {
+	id := r.ErrorId()
+	if sentinel, ok := errorIds[id]; ok {
+		return sentinel
+	}
+
+	if m := errorIdCodeRe.FindStringSubmatch(id); m != nil {
+		if sentinel := errorCode(m[1]); sentinel != nil {
+			return sentinel
+		}
+	}
+
+	if m := messageHresultRe.FindStringSubmatch(r.Exception.Message); m != nil {
+		if sentinel := errorCode(m[1]); sentinel != nil {
+			return sentinel
+		}
+	}
+
+	return categories[r.CategoryInfo.Category]
+}
+
+// transportError represents an error of the connection to the Windows system.
+type transportError struct {
+	err error
+}
+
+// Error implements the error interface.
+func (e *transportError) Error() string {
+	return e.err.Error()
+}
+
+// Unwrap returns the wrapped error.
+func (e *transportError) Unwrap() error {
+	return e.err
+}
+
+// Is reports whether the target is ErrTransport.
+func (e *transportError) Is(target error) bool {
+	return target == ErrTransport
+}
+
+// NewTransportError wraps an error of the connection to the Windows system, so it matches ErrTransport.
+// The error message is not changed.
+func NewTransportError(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &transportError{err: err}
+}
@@ -0,0 +1,103 @@
+package winerror
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/d-strobel/gowindows/parsing"
+)
+
+func (suite *WinErrorUnitTestSuite) TestIs() {
+	suite.T().Parallel()
+
+	suite.Run("should match the sentinel error of the error records", func() {
+		tcs := []struct {
+			description   string
+			inputRecord   parsing.ErrorRecord
+			expectedError error
+		}{
+			{
+				"assert not found by error id",
+				parsing.ErrorRecord{FullyQualifiedErrorId: "UserNotFound,Microsoft.PowerShell.Commands.GetLocalUserCommand"},
+				ErrNotFound,
+			},
+			{
+				"assert already exists by error id",
+				parsing.ErrorRecord{FullyQualifiedErrorId: "MemberExists,Microsoft.PowerShell.Commands.AddLocalGroupMemberCommand"},
+				ErrAlreadyExists,
+			},
+			{
+				"assert invalid parameter by error id",
+				parsing.ErrorRecord{FullyQualifiedErrorId: "NamedParameterNotFound,Microsoft.PowerShell.Commands.GetLocalUserCommand"},
+				ErrInvalidParameter,
+			},
+			{
+				"assert not found by win32 error code",
+				parsing.ErrorRecord{FullyQualifiedErrorId: "WIN32 9601,Get-DnsServerZone"},
+				ErrNotFound,
+			},
+			{
+				"assert already exists by win32 error code",
+				parsing.ErrorRecord{FullyQualifiedErrorId: "WIN32 9711,Add-DnsServerResourceRecordA"},
+				ErrAlreadyExists,
+			},
+			{
+				"assert access denied by win32 hresult",
+				parsing.ErrorRecord{FullyQualifiedErrorId: "HRESULT 0x80070005,Get-DhcpServerv4Scope"},
+				ErrAccessDenied,
+			},
+			{
+				"assert not found by wmi hresult",
+				parsing.ErrorRecord{FullyQualifiedErrorId: "HRESULT 0x80041002,Get-DnsServerResourceRecord"},
+				ErrNotFound,
+			},
+			{
+				"assert access denied by hresult in the exception message",
+				parsing.ErrorRecord{Exception: parsing.ExceptionInfo{Message: "Access is denied. (Exception from HRESULT: 0x80070005 (E_ACCESSDENIED))"}},
+				ErrAccessDenied,
+			},
+			{
+				"assert invalid parameter by category",
+				parsing.ErrorRecord{FullyQualifiedErrorId: "Unknown,Test", CategoryInfo: parsing.CategoryInfo{Category: "InvalidArgument"}},
+				ErrInvalidParameter,
+			},
+			{
+				"assert error code before category",
+				parsing.ErrorRecord{FullyQualifiedErrorId: "WIN32 5,Get-DnsServerZone", CategoryInfo: parsing.CategoryInfo{Category: "ObjectNotFound"}},
+				ErrAccessDenied,
+			},
+		}
+
+		sentinels := []error{ErrNotFound, ErrAlreadyExists, ErrAccessDenied, ErrInvalidParameter, ErrTransport}
+		for _, tc := range tcs {
+			suite.T().Logf("test case: %s", tc.description)
+			err := Errorf("test-command", "windows.test: %w", &WinError{Command: "test-command", Err: errors.New("error-message"), Records: []parsing.ErrorRecord{tc.inputRecord}})
+			for _, sentinel := range sentinels {
+				suite.Equal(sentinel == tc.expectedError, errors.Is(err, sentinel), sentinel.Error())
+			}
+		}
+	})
+
+	suite.Run("should not match unknown error records", func() {
+		err := &WinError{Command: "test-command", Err: errors.New("error-message"), Records: []parsing.ErrorRecord{{FullyQualifiedErrorId: "WIN32 1,Test"}}}
+		suite.NotErrorIs(err, ErrNotFound)
+		suite.NotErrorIs(err, ErrTransport)
+	})
+}
+
+func (suite *WinErrorUnitTestSuite) TestNewTransportError() {
+	suite.T().Parallel()
+
+	suite.Run("should match ErrTransport and the wrapped error", func() {
+		err := fmt.Errorf("windows.test: %w", New("test-command", NewTransportError(context.DeadlineExceeded)))
+		suite.EqualError(err, "windows.test: context deadline exceeded")
+		suite.ErrorIs(err, ErrTransport)
+		suite.ErrorIs(err, context.DeadlineExceeded)
+		suite.NotErrorIs(err, ErrNotFound)
+	})
+
+	suite.Run("should return nil for nil errors", func() {
+		suite.NoError(NewTransportError(nil))
+	})
+}
@@ -2,13 +2,18 @@
 package winerror
 
 import (
+	"errors"
 	"fmt"
+
+	"github.com/d-strobel/gowindows/parsing"
 )
 
 // WinError represents a custom error type for Windows client errors.
 type WinError struct {
-	Err     error  // Error message
-	Command string // Executed command
+	Err      error                 // Error message
+	Command  string                // Executed command with redacted sensitive values
+	ExitCode int                   // Exit code of the executed command
+	Records  []parsing.ErrorRecord // PowerShell error records of the executed command
 }
 
 // Error implements the error interface.
@@ -22,12 +27,53 @@ func (e *WinError) Unwrap() error {
 	return e.Err
 }
 
+// Is reports whether one of the error records maps to the target sentinel error, e.g. ErrNotFound.
+func (e *WinError) Is(target error) bool {
+	for _, r := range e.Records {
+		if sentinel(r) == target {
+			return true
+		}
+	}
+	return false
+}
+
+// HasErrorId reports whether one of the error records has the given error id.
+// The id is compared with the fully qualified error id and with the error id without its source.
+func (e *WinError) HasErrorId(id string) bool {
+	for _, r := range e.Records {
+		if r.FullyQualifiedErrorId == id || r.ErrorId() == id {
+			return true
+		}
+	}
+	return false
+}
+
+// HasCategory reports whether one of the error records has the given category, e.g. "ObjectNotFound".
+func (e *WinError) HasCategory(category string) bool {
+	for _, r := range e.Records {
+		if r.CategoryInfo.Category == category {
+			return true
+		}
+	}
+	return false
+}
+
 // New creates a new WinError.
+// Sensitive values in the command are redacted, see parsing.RedactPwshCmd.
+// The exit code and the error records of a wrapped WinError are passed on to the new WinError.
 func New(cmd string, err error) *WinError {
-	return &WinError{
+	winErr := &WinError{
 		Err:     err,
-		Command: cmd,
+		Command: parsing.RedactPwshCmd(cmd),
 	}
+
+	var e *WinError
+	if errors.As(err, &e) {
+		winErr.ExitCode = e.ExitCode
+		winErr.Records = e.Records
+	}
+
+	return winErr
 }
 
 // Errorf creates a new WinError object from a formatted string.
@@ -42,3 +88,24 @@ func UnwrapCommand(err error) string {
 	}
 	return ""
 }
+
+// UnwrapRecords extracts the PowerShell error records from the first WinError in the error chain.
+func UnwrapRecords(err error) []parsing.ErrorRecord {
+	var e *WinError
+	if errors.As(err, &e) {
+		return e.Records
+	}
+	return nil
+}
+
+// HasErrorId reports whether the error contains a PowerShell error record with the given error id.
+func HasErrorId(err error, id string) bool {
+	var e *WinError
+	return errors.As(err, &e) && e.HasErrorId(id)
+}
+
+// HasCategory reports whether the error contains a PowerShell error record with the given category.
+func HasCategory(err error, category string) bool {
+	var e *WinError
+	return errors.As(err, &e) && e.HasCategory(category)
+}
@@ -2,8 +2,11 @@ package winerror
 
 import (
 	"errors"
-	"github.com/stretchr/testify/suite"
+	"fmt"
 	"testing"
+
+	"github.com/d-strobel/gowindows/parsing"
+	"github.com/stretchr/testify/suite"
 )
 
 // Unit test suite for all WinError functions
@@ -46,6 +49,15 @@ func (suite *WinErrorUnitTestSuite) TestNew() {
 		suite.Error(err)
 		suite.Equal(expectedErr, err)
 	})
+
+	suite.Run("should redact sensitive values in the command", func() {
+		cmd := parsing.NewPwshCommand("Set-LocalUser").
+			Param("Name", "test").
+			Param("Password", parsing.PwshSensitive("secret")).
+			String()
+		err := New(cmd, errors.New("error-message"))
+		suite.Equal("Set-LocalUser -Name 'test' -Password <#sensitive#>'***'", err.Command)
+	})
 }
 
 func (suite *WinErrorUnitTestSuite) TestErrorf() {
@@ -98,3 +110,47 @@ func (suite *WinErrorUnitTestSuite) TestUnwrapCommand() {
 		suite.Equal(UnwrapCommand(err), "")
 	})
 }
+
+func (suite *WinErrorUnitTestSuite) TestRecords() {
+	suite.T().Parallel()
+
+	records := []parsing.ErrorRecord{
+		{
+			FullyQualifiedErrorId: "UserNotFound,Microsoft.PowerShell.Commands.GetLocalUserCommand",
+			CategoryInfo:          parsing.CategoryInfo{Category: "ObjectNotFound"},
+		},
+	}
+
+	suite.Run("should pass on the records of a wrapped WinError", func() {
+		err := Errorf("test-command", "wrapped: %w", &WinError{Command: "test-command", Err: errors.New("error-message"), Records: records})
+		suite.EqualError(err, "wrapped: error-message")
+		suite.Equal(records, err.Records)
+		suite.Equal(records, UnwrapRecords(err))
+	})
+
+	suite.Run("should pass on the exit code of a wrapped WinError", func() {
+		err := Errorf("test-command", "wrapped: %w", &WinError{Command: "test-command", Err: errors.New("error-message"), ExitCode: 2})
+		suite.Equal(2, err.ExitCode)
+	})
+
+	suite.Run("should return no records when error is not a WinError object", func() {
+		suite.Nil(UnwrapRecords(errors.New("error-message")))
+	})
+
+	suite.Run("should match the error id", func() {
+		err := &WinError{Command: "test-command", Err: errors.New("error-message"), Records: records}
+		suite.True(err.HasErrorId("UserNotFound"))
+		suite.True(err.HasErrorId("UserNotFound,Microsoft.PowerShell.Commands.GetLocalUserCommand"))
+		suite.False(err.HasErrorId("GroupNotFound"))
+		suite.True(HasErrorId(fmt.Errorf("wrapped: %w", err), "UserNotFound"))
+		suite.False(HasErrorId(errors.New("UserNotFound"), "UserNotFound"))
+	})
+
+	suite.Run("should match the category", func() {
+		err := &WinError{Command: "test-command", Err: errors.New("error-message"), Records: records}
+		suite.True(err.HasCategory("ObjectNotFound"))
+		suite.False(err.HasCategory("ResourceExists"))
+		suite.True(HasCategory(fmt.Errorf("wrapped: %w", err), "ObjectNotFound"))
+		suite.False(HasCategory(errors.New("ObjectNotFound"), "ObjectNotFound"))
+	})
+}